@@ -0,0 +1,44 @@
+// asyncapigen 根据 WebSocket 协议的 AsyncAPI 文档生成帧结构的 Go 代码，由 go generate 调用：
+//
+//	go run ../../cmd/asyncapigen -spec ../../api/asyncapi.yaml -out envelope_gen.go -package bridge
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"ollama_dev/internal/asyncapi"
+)
+
+func main() {
+	spec := flag.String("spec", "", "AsyncAPI 文档路径")
+	out := flag.String("out", "", "生成的 Go 文件")
+	pkg := flag.String("package", "", "生成代码的包名")
+	flag.Parse()
+	if *spec == "" || *out == "" || *pkg == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+	if err := run(*spec, *out, *pkg); err != nil {
+		fmt.Fprintln(os.Stderr, "asyncapigen:", err)
+		os.Exit(1)
+	}
+}
+
+func run(spec, out, pkg string) error {
+	data, err := os.ReadFile(spec)
+	if err != nil {
+		return err
+	}
+	doc, err := asyncapi.Parse(data)
+	if err != nil {
+		return err
+	}
+	src, err := asyncapi.Generate(doc, pkg, "api/"+filepath.Base(spec))
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(out, src, 0o644)
+}
@@ -0,0 +1,7 @@
+package main
+
+// 自定义动作在所在包的 init 中调用 bridge.RegisterAction 注册，在此空导入即可启用，例如：
+//
+//	import _ "ollama_dev/internal/actions/lookup"
+//
+// 示例动作 echo 只在 go build -tags example 时通过 actions_example.go 导入，不进入正式构建
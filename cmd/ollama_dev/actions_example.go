@@ -0,0 +1,8 @@
+//go:build example
+
+package main
+
+// 示例动作不进入正式构建，以 go build -tags example 构建时启用
+import (
+	_ "ollama_dev/internal/actions/echo"
+)
@@ -0,0 +1,13 @@
+package main
+
+import (
+	"os"
+
+	"ollama_dev/internal/cli"
+)
+
+func main() {
+	if err := cli.Execute(); err != nil {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,6 @@
+package main
+
+// serve 的路由插件在所在包的 init 中调用 plugins.Register 注册，在此空导入，是否挂载由 server.plugins 决定
+import (
+	_ "ollama_dev/internal/plugins/ui"
+)
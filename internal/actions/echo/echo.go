@@ -0,0 +1,29 @@
+// Package echo 注册 echo 动作：原样返回请求的 params，用于检查云端到桥接客户端的链路，
+// 也是编写自定义动作的示例
+package echo
+
+import (
+	"context"
+	"encoding/json"
+
+	"ollama_dev/internal/bridge"
+)
+
+func init() {
+	bridge.RegisterAction(bridge.Action{
+		Name: "echo",
+		New: func(bridge.OllamaClient, bridge.Logger) bridge.RequestHandler {
+			return handler{}
+		},
+	})
+}
+
+type handler struct{}
+
+func (handler) Handle(ctx context.Context, req *bridge.CloudRequest) (*bridge.CloudResponse, error) {
+	params := req.RawParams
+	if len(params) == 0 {
+		params = json.RawMessage("null")
+	}
+	return bridge.NewResponse(req, params), nil
+}
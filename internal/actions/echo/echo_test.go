@@ -0,0 +1,21 @@
+package echo
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"ollama_dev/internal/bridge"
+)
+
+func TestEchoReturnsParams(t *testing.T) {
+	req := &bridge.CloudRequest{Action: "echo", RequestID: "1", RawParams: json.RawMessage(`{"ping":1}`)}
+	resp, err := handler{}.Handle(context.Background(), req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ := json.Marshal(resp.Data)
+	if resp.Status != "done" || resp.RequestID != "1" || string(data) != `{"ping":1}` {
+		t.Errorf("unexpected response %+v (data %s)", resp, data)
+	}
+}
@@ -0,0 +1,131 @@
+package alert
+
+import (
+	"context"
+	"expvar"
+	"log/slog"
+	"sync"
+	"time"
+
+	"ollama_dev/internal/config"
+)
+
+// metrics 各规则的通知次数，发布在 /debug/vars 的 alert 字段
+var metrics = expvar.NewMap("alert")
+
+// Alert 一次告警
+type Alert struct {
+	Rule    string    `json:"rule"`
+	Message string    `json:"message"`
+	Time    time.Time `json:"time"`
+}
+
+// Rule 告警规则，Check 在每次评估时调用，返回告警描述与是否触发
+type Rule interface {
+	Name() string
+	Check(now time.Time) (string, bool)
+}
+
+// Notifier 告警通知方式
+type Notifier interface {
+	Notify(ctx context.Context, a Alert) error
+}
+
+// Manager 定期评估规则，触发的规则在 cooldown 内只通知一次
+type Manager struct {
+	cooldown  time.Duration
+	rules     []Rule
+	notifiers []Notifier
+
+	mu   sync.Mutex
+	last map[string]time.Time // 规则 -> 最近一次通知的时间
+}
+
+// New 创建告警管理器
+func New(cooldown time.Duration) *Manager {
+	return &Manager{cooldown: cooldown, last: map[string]time.Time{}}
+}
+
+// AddRule 添加规则
+func (m *Manager) AddRule(r Rule) {
+	m.rules = append(m.rules, r)
+}
+
+// AddNotifier 添加通知方式
+func (m *Manager) AddNotifier(n Notifier) {
+	m.notifiers = append(m.notifiers, n)
+}
+
+// Evaluate 评估全部规则，返回本次需要通知（触发且不在冷却中）的告警
+func (m *Manager) Evaluate(now time.Time) []Alert {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var alerts []Alert
+	for _, r := range m.rules {
+		msg, firing := r.Check(now)
+		if !firing {
+			continue
+		}
+		if last, ok := m.last[r.Name()]; ok && now.Sub(last) < m.cooldown {
+			continue
+		}
+		m.last[r.Name()] = now
+		alerts = append(alerts, Alert{Rule: r.Name(), Message: msg, Time: now})
+	}
+	return alerts
+}
+
+// notify 记录日志并发送到全部通知方式，失败只记录日志
+func (m *Manager) notify(ctx context.Context, a Alert, logger *slog.Logger) {
+	metrics.Add(a.Rule, 1)
+	logger.Warn("触发告警", "rule", a.Rule, "message", a.Message)
+	for _, n := range m.notifiers {
+		if err := n.Notify(ctx, a); err != nil {
+			logger.Error("发送告警失败", "rule", a.Rule, "error", err)
+		}
+	}
+}
+
+// Run 每隔 interval 评估一次规则，直到 ctx 结束
+func (m *Manager) Run(ctx context.Context, interval time.Duration, logger *slog.Logger) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			for _, a := range m.Evaluate(now) {
+				m.notify(ctx, a, logger)
+			}
+		}
+	}
+}
+
+// Sources 规则读取的运行指标，为 nil 的来源不创建对应规则
+type Sources struct {
+	Errors      func() int64         // 累计错误数
+	Disconnects func() int64         // 累计断开的连接数
+	BackendDown func() time.Duration // Ollama 已持续不可达的时长
+}
+
+// FromConfig 按配置创建规则与通知方式，threshold 或时长为 0 的规则不启用
+func FromConfig(cfg config.AlertConfig, src Sources) *Manager {
+	m := New(cfg.Cooldown)
+	if src.Errors != nil && cfg.ErrorRate.Threshold > 0 {
+		m.AddRule(NewRate("error_rate", src.Errors, cfg.ErrorRate.Threshold, cfg.ErrorRate.Window, "%d 个错误 (%s 内)"))
+	}
+	if src.Disconnects != nil && cfg.ReconnectStorm.Threshold > 0 {
+		m.AddRule(NewRate("reconnect_storm", src.Disconnects, cfg.ReconnectStorm.Threshold, cfg.ReconnectStorm.Window, "%d 个连接断开 (%s 内)"))
+	}
+	if src.BackendDown != nil && cfg.BackendDown > 0 {
+		m.AddRule(NewDuration("backend_down", src.BackendDown, cfg.BackendDown, "Ollama 已持续 %s 不可达"))
+	}
+	if cfg.Webhook != "" {
+		m.AddNotifier(NewWebhook(cfg.Webhook, cfg.WebhookTimeout))
+	}
+	if cfg.Email.SMTPAddr != "" {
+		m.AddNotifier(NewEmail(cfg.Email))
+	}
+	return m
+}
@@ -0,0 +1,108 @@
+package alert
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"ollama_dev/internal/config"
+)
+
+func TestRateRuleWindow(t *testing.T) {
+	var count int64
+	r := NewRate("error_rate", func() int64 { return count }, 5, time.Minute, "%d 个错误 (%s 内)")
+	start := time.Now()
+
+	r.Check(start)
+	count = 4
+	if _, firing := r.Check(start.Add(30 * time.Second)); firing {
+		t.Fatal("4 errors should not fire")
+	}
+	count = 6
+	msg, firing := r.Check(start.Add(50 * time.Second))
+	if !firing || msg != "6 个错误 (1m0s 内)" {
+		t.Fatalf("expected firing, got %q %v", msg, firing)
+	}
+	// 窗口滑过后只统计窗口内的增量
+	if _, firing := r.Check(start.Add(2 * time.Minute)); firing {
+		t.Error("no new errors in the window, should not fire")
+	}
+}
+
+func TestDurationRule(t *testing.T) {
+	var down time.Duration
+	r := NewDuration("backend_down", func() time.Duration { return down }, 5*time.Minute, "Ollama 已持续 %s 不可达")
+	if _, firing := r.Check(time.Now()); firing {
+		t.Fatal("reachable backend should not fire")
+	}
+	down = 6 * time.Minute
+	if msg, firing := r.Check(time.Now()); !firing || !strings.Contains(msg, "6m0s") {
+		t.Errorf("expected firing, got %q %v", msg, firing)
+	}
+}
+
+type staticRule struct{ firing bool }
+
+func (r *staticRule) Name() string                       { return "static" }
+func (r *staticRule) Check(now time.Time) (string, bool) { return "boom", r.firing }
+
+func TestManagerCooldown(t *testing.T) {
+	m := New(10 * time.Minute)
+	rule := &staticRule{firing: true}
+	m.AddRule(rule)
+	now := time.Now()
+
+	if alerts := m.Evaluate(now); len(alerts) != 1 || alerts[0].Rule != "static" {
+		t.Fatalf("expected one alert, got %+v", alerts)
+	}
+	// 冷却期内持续触发不重复通知
+	if alerts := m.Evaluate(now.Add(5 * time.Minute)); len(alerts) != 0 {
+		t.Fatalf("expected no alert during cooldown, got %+v", alerts)
+	}
+	if alerts := m.Evaluate(now.Add(11 * time.Minute)); len(alerts) != 1 {
+		t.Fatalf("expected alert after cooldown, got %+v", alerts)
+	}
+}
+
+func TestWebhookNotify(t *testing.T) {
+	got := make(chan Alert, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var a Alert
+		_ = json.NewDecoder(r.Body).Decode(&a)
+		got <- a
+	}))
+	defer srv.Close()
+
+	a := Alert{Rule: "reconnect_storm", Message: "25 个连接断开 (1m0s 内)", Time: time.Now()}
+	if err := NewWebhook(srv.URL, time.Second).Notify(context.Background(), a); err != nil {
+		t.Fatal(err)
+	}
+	if r := <-got; r.Rule != a.Rule || r.Message != a.Message {
+		t.Errorf("unexpected payload: %+v", r)
+	}
+}
+
+func TestFromConfig(t *testing.T) {
+	cfg := config.Default().Alert
+	cfg.ReconnectStorm.Threshold = 0
+	cfg.Email = config.EmailConfig{SMTPAddr: "localhost:25", From: "ollama_dev@example.com", To: []string{"ops@example.com"}}
+	m := FromConfig(cfg, Sources{
+		Errors:      func() int64 { return 0 },
+		Disconnects: func() int64 { return 0 },
+	})
+	// threshold 为 0 与缺少来源的规则不启用
+	if len(m.rules) != 1 || m.rules[0].Name() != "error_rate" {
+		t.Errorf("unexpected rules: %d", len(m.rules))
+	}
+	if len(m.notifiers) != 1 {
+		t.Fatalf("expected email notifier, got %d", len(m.notifiers))
+	}
+	msg := string(m.notifiers[0].(*Email).message(Alert{Rule: "error_rate", Message: "60 个错误", Time: time.Now()}))
+	if !strings.Contains(msg, "Subject: [ollama_dev] error_rate\r\n") || !strings.Contains(msg, "To: ops@example.com\r\n") {
+		t.Errorf("unexpected message:\n%s", msg)
+	}
+}
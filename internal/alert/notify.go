@@ -0,0 +1,84 @@
+package alert
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/smtp"
+	"strings"
+	"time"
+
+	"ollama_dev/internal/config"
+)
+
+// Webhook 以 JSON POST 告警
+type Webhook struct {
+	url     string
+	timeout time.Duration
+	client  *http.Client
+}
+
+// NewWebhook 创建 Webhook 通知
+func NewWebhook(url string, timeout time.Duration) *Webhook {
+	return &Webhook{url: url, timeout: timeout, client: &http.Client{}}
+}
+
+func (w *Webhook) Notify(ctx context.Context, a Alert) error {
+	data, err := json.Marshal(a)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(ctx, w.timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.url, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("Webhook 返回 %s", resp.Status)
+	}
+	return nil
+}
+
+// Email 通过 SMTP 发送告警邮件
+type Email struct {
+	cfg config.EmailConfig
+}
+
+// NewEmail 创建邮件通知，配置了 username 时使用 PLAIN 认证（net/smtp 要求 TLS 或本机地址）
+func NewEmail(cfg config.EmailConfig) *Email {
+	return &Email{cfg: cfg}
+}
+
+func (e *Email) Notify(ctx context.Context, a Alert) error {
+	var auth smtp.Auth
+	if e.cfg.Username != "" {
+		host, _, _ := net.SplitHostPort(e.cfg.SMTPAddr)
+		auth = smtp.PlainAuth("", e.cfg.Username, e.cfg.Password, host)
+	}
+	if err := smtp.SendMail(e.cfg.SMTPAddr, auth, e.cfg.From, e.cfg.To, e.message(a)); err != nil {
+		return fmt.Errorf("发送邮件失败: %w", err)
+	}
+	return nil
+}
+
+// message 生成纯文本邮件
+func (e *Email) message(a Alert) []byte {
+	var b strings.Builder
+	fmt.Fprintf(&b, "From: %s\r\n", e.cfg.From)
+	fmt.Fprintf(&b, "To: %s\r\n", strings.Join(e.cfg.To, ", "))
+	fmt.Fprintf(&b, "Subject: [ollama_dev] %s\r\n", a.Rule)
+	fmt.Fprintf(&b, "Date: %s\r\n", a.Time.Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\nContent-Type: text/plain; charset=utf-8\r\n\r\n")
+	fmt.Fprintf(&b, "%s\r\n\r\n%s\r\n", a.Message, a.Time.Format(time.RFC3339))
+	return []byte(b.String())
+}
@@ -0,0 +1,66 @@
+package alert
+
+import (
+	"fmt"
+	"time"
+)
+
+type sample struct {
+	at    time.Time
+	value int64
+}
+
+// RateRule 单调递增的计数在 window 内的增量达到 threshold 时触发
+type RateRule struct {
+	name      string
+	counter   func() int64
+	threshold int64
+	window    time.Duration
+	format    string // 告警描述，参数为增量与窗口
+
+	samples []sample
+}
+
+// NewRate 创建计数增量规则，format 接收增量 (%d) 与窗口 (%s) 两个参数
+func NewRate(name string, counter func() int64, threshold int, window time.Duration, format string) *RateRule {
+	return &RateRule{name: name, counter: counter, threshold: int64(threshold), window: window, format: format}
+}
+
+func (r *RateRule) Name() string { return r.name }
+
+// Check 记录当前计数，与窗口起点（窗口内最早的采样）比较
+func (r *RateRule) Check(now time.Time) (string, bool) {
+	r.samples = append(r.samples, sample{at: now, value: r.counter()})
+	// 保留一个不晚于窗口起点的采样作为基准
+	for len(r.samples) > 1 && !r.samples[1].at.After(now.Add(-r.window)) {
+		r.samples = r.samples[1:]
+	}
+	delta := r.samples[len(r.samples)-1].value - r.samples[0].value
+	if delta < r.threshold {
+		return "", false
+	}
+	return fmt.Sprintf(r.format, delta, r.window), true
+}
+
+// DurationRule 某个状态持续超过 limit 时触发
+type DurationRule struct {
+	name     string
+	duration func() time.Duration
+	limit    time.Duration
+	format   string // 告警描述，参数为持续时长
+}
+
+// NewDuration 创建持续时长规则，duration 返回状态已持续的时长，未处于该状态时返回 0
+func NewDuration(name string, duration func() time.Duration, limit time.Duration, format string) *DurationRule {
+	return &DurationRule{name: name, duration: duration, limit: limit, format: format}
+}
+
+func (r *DurationRule) Name() string { return r.name }
+
+func (r *DurationRule) Check(now time.Time) (string, bool) {
+	d := r.duration()
+	if d < r.limit {
+		return "", false
+	}
+	return fmt.Sprintf(r.format, d.Round(time.Second)), true
+}
@@ -0,0 +1,69 @@
+// Package app 组装桥接客户端与 Gin 服务器，组件通过选项注入，未注入的按配置创建
+package app
+
+import (
+	"log/slog"
+	"net"
+
+	"ollama_dev/internal/bridge"
+	"ollama_dev/internal/models"
+	"ollama_dev/internal/plugins/websocket"
+)
+
+// Option 替换默认组件
+type Option func(*components)
+
+// components 注入的组件，为零值的字段按配置创建
+type components struct {
+	logger   *slog.Logger
+	ollama   bridge.OllamaClient
+	cache    bridge.Cache
+	wsClient bridge.WSClient
+	hub      *websocket.Hub
+	models   models.Lister
+	listener net.Listener
+}
+
+func newComponents(opts []Option) components {
+	c := components{logger: slog.Default()}
+	for _, opt := range opts {
+		opt(&c)
+	}
+	return c
+}
+
+// WithLogger 设置日志，默认为 slog.Default()
+func WithLogger(logger *slog.Logger) Option {
+	return func(c *components) { c.logger = logger }
+}
+
+// WithOllamaClient 替换桥接客户端与 serve 的 REST 对话接口、/ws 对话 (server.websocket.chat) 使用的 Ollama 客户端；
+// 未实现 jobs.Transfer 时不支持模型拉取与推送
+func WithOllamaClient(client bridge.OllamaClient) Option {
+	return func(c *components) { c.ollama = client }
+}
+
+// WithCache 替换默认 Ollama 客户端的模型列表缓存，同时使用 WithOllamaClient 时不生效
+func WithCache(cache bridge.Cache) Option {
+	return func(c *components) { c.cache = cache }
+}
+
+// WithWSClient 替换桥接客户端的 WebSocket 连接，抓包与分片仍包装在其外层
+func WithWSClient(client bridge.WSClient) Option {
+	return func(c *components) { c.wsClient = client }
+}
+
+// WithHub 替换服务器 /ws 使用的 Hub，Hub 由服务器启动，调用方不得再调用其 Run
+func WithHub(hub *websocket.Hub) Option {
+	return func(c *components) { c.hub = hub }
+}
+
+// WithModels 替换服务器查询模型列表的方式，同时用于 /api/models 与就绪检查
+func WithModels(list models.Lister) Option {
+	return func(c *components) { c.models = list }
+}
+
+// WithListener 使服务器在已绑定的 listener 上提供服务，不再监听 server.addr
+func WithListener(ln net.Listener) Option {
+	return func(c *components) { c.listener = ln }
+}
@@ -0,0 +1,283 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	gorilla "github.com/gorilla/websocket"
+
+	"ollama_dev/internal/bridge"
+	"ollama_dev/internal/config"
+	"ollama_dev/internal/conformance"
+	"ollama_dev/internal/models"
+	"ollama_dev/internal/plugins/websocket"
+)
+
+// fakeOllama 只实现 OllamaClient，不支持模型传输
+type fakeOllama struct{}
+
+func (fakeOllama) Chat(ctx context.Context, req bridge.ChatRequest) (bridge.Reply, error) {
+	return bridge.Reply{Content: "injected"}, nil
+}
+func (fakeOllama) Embed(ctx context.Context, req bridge.EmbedRequest) (bridge.Embeddings, error) {
+	return bridge.Embeddings{}, nil
+}
+
+func (fakeOllama) ChatStream(ctx context.Context, req bridge.ChatRequest, onChunk func(string) error) (bridge.Reply, error) {
+	if err := onChunk("injected"); err != nil {
+		return bridge.Reply{}, err
+	}
+	return bridge.Reply{Content: "injected"}, nil
+}
+
+func (fakeOllama) Generate(ctx context.Context, req bridge.GenerateRequest, onChunk func(string) error) (bridge.Reply, error) {
+	return bridge.Reply{Content: "injected"}, nil
+}
+
+func (fakeOllama) ListModels(ctx context.Context) ([]bridge.ModelInfo, error) {
+	return []bridge.ModelInfo{{Name: "fake:latest"}}, nil
+}
+
+func (fakeOllama) Heartbeat(ctx context.Context) error { return nil }
+
+func discard() *slog.Logger {
+	return slog.New(slog.NewTextHandler(io.Discard, nil))
+}
+
+func TestBridgeUsesInjectedOllamaClient(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := config.Default()
+	cfg.Bridge.URL = "ws://" + ln.Addr().String() + "/"
+	cfg.Bridge.JobsFile, cfg.Bridge.OutboxFile, cfg.Bridge.DebugAddr = "", "", ""
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	done := make(chan error, 1)
+	go func() { done <- NewBridge(cfg, WithLogger(discard()), WithOllamaClient(fakeOllama{})).Run(ctx) }()
+
+	conn, err := conformance.Accept(ctx, ln, cfg.Chunking)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	caps, err := conn.Capabilities(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 注入的客户端不支持模型传输，不应声明 pull_model
+	if slices.Contains(caps.Actions, "pull_model") {
+		t.Errorf("pull_model advertised without a transfer-capable client: %v", caps.Actions)
+	}
+	results := conformance.Run(ctx, conn, conformance.Options{Timeout: 5 * time.Second, Run: []string{"list_model", "chat"}}, nil)
+	for _, r := range results {
+		if r.Status != conformance.StatusPass {
+			t.Errorf("%s: %s %s", r.Case, r.Status, r.Detail)
+		}
+	}
+
+	cancel()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("Run returned %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("bridge did not stop after cancel")
+	}
+}
+
+func TestServerUsesInjectedComponents(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := config.Default()
+	cfg.Server.DebugAddr = ""
+	cfg.Server.DrainDelay = 0
+	cfg.Server.UsageFile = ""
+	hub := websocket.NewHub()
+	list := func(ctx context.Context) ([]models.Info, error) {
+		return []models.Info{{Name: "fake:latest"}}, nil
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	done := make(chan error, 1)
+	srv := NewServer(config.NewStore("", cfg), WithLogger(discard()), WithHub(hub), WithModels(list), WithListener(ln))
+	go func() { done <- srv.Run(ctx) }()
+	base := "http://" + ln.Addr().String()
+
+	req, _ := http.NewRequest(http.MethodGet, base+"/api/models", nil)
+	req.Header.Set("Authorization", "Bearer "+cfg.Auth.Token)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var body struct {
+		Models []models.Info `json:"models"`
+	}
+	err = json.NewDecoder(resp.Body).Decode(&body)
+	resp.Body.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(body.Models) != 1 || body.Models[0].Name != "fake:latest" {
+		t.Errorf("models = %+v, want the injected list", body.Models)
+	}
+
+	// /ws 的连接登记在注入的 Hub 上
+	header := http.Header{"Authorization": {"Bearer " + cfg.Auth.Token}}
+	ws, _, err := gorilla.DefaultDialer.Dial("ws://"+ln.Addr().String()+"/ws/", header)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ws.Close()
+	deadline := time.Now().Add(time.Second)
+	for hub.Stats().Total != 1 {
+		if time.Now().After(deadline) {
+			t.Fatalf("injected hub has %d connections, want 1", hub.Stats().Total)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	cancel()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("Run returned %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("server did not stop after cancel")
+	}
+}
+
+func TestServerAppliesModelPolicy(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "policy.yaml")
+	if err := os.WriteFile(path, []byte("models:\n  - name: llama3\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	cfg := config.Default()
+	cfg.Server.DebugAddr = ""
+	cfg.Server.DrainDelay = 0
+	cfg.Server.UsageFile = ""
+	cfg.Bridge.PolicyFile = path
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	done := make(chan error, 1)
+	srv := NewServer(config.NewStore("", cfg), WithLogger(discard()), WithOllamaClient(fakeOllama{}), WithListener(ln))
+	go func() { done <- srv.Run(ctx) }()
+	base := "http://" + ln.Addr().String()
+
+	cases := []struct {
+		path, body string
+		status     int
+	}{
+		{"/api/chat", `{"model_name":"llama3","messages":[{"role":"user","content":"hi"}]}`, http.StatusOK},
+		{"/api/chat", `{"model_name":"mistral","messages":[{"role":"user","content":"hi"}]}`, http.StatusForbidden},
+		{"/api/embeddings", `{"model_name":"mistral","input":["hi"]}`, http.StatusForbidden},
+	}
+	for _, c := range cases {
+		req, _ := http.NewRequest(http.MethodPost, base+c.path, strings.NewReader(c.body))
+		req.Header.Set("Authorization", "Bearer "+cfg.Auth.Token)
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != c.status {
+			t.Errorf("%s %s: status = %d, want %d", c.path, c.body, resp.StatusCode, c.status)
+		}
+	}
+
+	cancel()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("Run returned %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("server did not stop after cancel")
+	}
+}
+
+func TestServerIgnoresSpoofedForwardedFor(t *testing.T) {
+	cases := []struct {
+		name    string
+		proxies []string
+		second  int // 换一个 X-Forwarded-For 后第二个请求的状态码
+	}{
+		// 默认不信任任何代理，伪造的请求头不会换出新的令牌桶
+		{"no trusted proxies", nil, http.StatusTooManyRequests},
+		// 来自可信代理的请求按 X-Forwarded-For 区分客户端
+		{"trusted loopback proxy", []string{"127.0.0.1"}, http.StatusOK},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			ln, err := net.Listen("tcp", "127.0.0.1:0")
+			if err != nil {
+				t.Fatal(err)
+			}
+			cfg := config.Default()
+			cfg.Server.DebugAddr = ""
+			cfg.Server.DrainDelay = 0
+			cfg.Server.UsageFile = ""
+			cfg.Server.TrustedProxies = c.proxies
+			cfg.Server.RateLimit.Key = "ip"
+			cfg.Server.RateLimit.API = config.RateLimitRule{Rate: 0.01, Burst: 1}
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			done := make(chan error, 1)
+			srv := NewServer(config.NewStore("", cfg), WithLogger(discard()), WithListener(ln))
+			go func() { done <- srv.Run(ctx) }()
+
+			get := func(forwardedFor string) int {
+				t.Helper()
+				req, _ := http.NewRequest(http.MethodGet, "http://"+ln.Addr().String()+"/api/version", nil)
+				req.Header.Set("Authorization", "Bearer "+cfg.Auth.Token)
+				req.Header.Set("X-Forwarded-For", forwardedFor)
+				resp, err := http.DefaultClient.Do(req)
+				if err != nil {
+					t.Fatal(err)
+				}
+				resp.Body.Close()
+				return resp.StatusCode
+			}
+			if status := get("203.0.113.1"); status != http.StatusOK {
+				t.Fatalf("first request: status = %d", status)
+			}
+			if status := get("203.0.113.2"); status != c.second {
+				t.Errorf("second request with another X-Forwarded-For: status = %d, want %d", status, c.second)
+			}
+
+			cancel()
+			select {
+			case err := <-done:
+				if err != nil {
+					t.Errorf("Run returned %v", err)
+				}
+			case <-time.After(5 * time.Second):
+				t.Fatal("server did not stop after cancel")
+			}
+		})
+	}
+}
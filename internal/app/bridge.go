@@ -0,0 +1,28 @@
+package app
+
+import (
+	"context"
+
+	"ollama_dev/internal/bridge"
+	"ollama_dev/internal/config"
+)
+
+// Bridge 桥接客户端
+type Bridge struct {
+	cfg *config.Config
+	c   components
+}
+
+// NewBridge 创建桥接客户端，未注入的组件在 Run 时按配置创建
+func NewBridge(cfg *config.Config, opts ...Option) *Bridge {
+	return &Bridge{cfg: cfg, c: newComponents(opts)}
+}
+
+// Run 连接到 bridge.url 并运行桥接服务，ctx 结束时关闭连接并返回
+func (b *Bridge) Run(ctx context.Context) error {
+	return bridge.Run(ctx, b.c.logger, b.cfg, bridge.Deps{
+		WSClient: b.c.wsClient,
+		Ollama:   b.c.ollama,
+		Cache:    b.c.cache,
+	})
+}
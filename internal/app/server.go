@@ -0,0 +1,263 @@
+package app
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"ollama_dev/internal/alert"
+	"ollama_dev/internal/auth"
+	"ollama_dev/internal/bridge"
+	"ollama_dev/internal/capture"
+	"ollama_dev/internal/config"
+	"ollama_dev/internal/dashboard"
+	"ollama_dev/internal/debug"
+	"ollama_dev/internal/feature"
+	"ollama_dev/internal/handlers"
+	"ollama_dev/internal/health"
+	"ollama_dev/internal/i18n"
+	"ollama_dev/internal/logging"
+	"ollama_dev/internal/models"
+	"ollama_dev/internal/openai"
+	"ollama_dev/internal/plugins"
+	"ollama_dev/internal/plugins/websocket"
+	"ollama_dev/internal/policy"
+	"ollama_dev/internal/router"
+	"ollama_dev/internal/stats"
+	"ollama_dev/internal/systemd"
+	"ollama_dev/internal/usage"
+)
+
+// Server Gin 服务器（含 /ws 插件）
+type Server struct {
+	store *config.Store
+	c     components
+}
+
+// NewServer 创建服务器，配置从 store 读取并随其重新加载，未注入的组件在 Run 时按配置创建
+func NewServer(store *config.Store, opts ...Option) *Server {
+	return &Server{store: store, c: newComponents(opts)}
+}
+
+// Run 运行服务器，ctx 结束或收到 SIGINT/SIGTERM 时先排空再关闭
+func (s *Server) Run(ctx context.Context) error {
+	cfg := s.store.Get()
+	logger := s.c.logger
+
+	// 初始化 Gin 引擎，请求日志由 TrafficLoggingMiddleware 统一输出；
+	// 只信任 server.trusted_proxies 转发的 X-Forwarded-For，否则客户端可以伪造 IP 绕过按 IP 的限流
+	r := gin.New()
+	if err := r.SetTrustedProxies(cfg.Server.TrustedProxies); err != nil {
+		return fmt.Errorf("server.trusted_proxies 无效: %w", err)
+	}
+	r.Use(gin.Recovery())
+
+	// WebSocket 抓包，未启用时为 nil
+	capt, err := capture.New(cfg.Capture, cfg.Log.Redact)
+	if err != nil {
+		return err
+	}
+	defer capt.Close()
+	debug.Register(debug.CapturePath, capt.Handler())
+	debug.Register(stats.DebugPath, stats.Handler())
+	debug.Register(dashboard.DebugPath+"/", dashboard.Handler(logger))
+
+	// 内部诊断端口 (pprof、expvar、抓包下载)
+	debug.StartServer(logger, cfg.Server.DebugAddr, cfg.Admin)
+
+	// 设置路由和中间件
+	lifecycle := health.NewLifecycle()
+	modelLister, listModels, err := s.modelListers(cfg)
+	if err != nil {
+		return fmt.Errorf("创建 Ollama 客户端失败: %w", err)
+	}
+	readiness := health.NewReadiness(lifecycle, listModels, cfg.Server.Readiness)
+	go readiness.Run(ctx, logger)
+	// 告警规则读取 stats 与就绪检查的状态，alert.interval 为 0 时不启用
+	if cfg.Alert.Interval > 0 {
+		alerts := alert.FromConfig(cfg.Alert, alert.Sources{
+			Errors: func() int64 { return stats.Get().ErrorsTotal },
+			Disconnects: func() int64 {
+				if c := stats.Get().Connections; c != nil {
+					return c.Disconnects
+				}
+				return 0
+			},
+			BackendDown: readiness.Unreachable,
+		})
+		go alerts.Run(ctx, cfg.Alert.Interval, logging.Component(logger, "alert"))
+	}
+	// 功能开关，重新加载配置时恢复配置中的取值
+	flags := feature.New(cfg.Features)
+	s.store.OnReload(func(old, cur *config.Config) {
+		flags.Reset(cur.Features)
+		logger.Info("功能开关已重置", "enabled", flags.EnabledNames())
+	})
+	// token 用量统计，未配置 server.usage_file 时为 nil
+	var usageStore *usage.Store
+	if cfg.Server.UsageFile != "" {
+		usageStore, err = usage.Open(cfg.Server.UsageFile)
+		if err != nil {
+			return err
+		}
+		defer usageStore.Close()
+	}
+	// 退出时由 shutdownServer 关闭已挂载的插件，包括 /ws 的连接
+	registry := plugins.NewRegistry()
+	hub := s.c.hub
+	if hub == nil {
+		hub = websocket.NewHub()
+	}
+	// /api/chat、/api/embeddings、/v1 与 /ws 的本地对话共用同一个 Ollama 客户端
+	ollama, err := s.ollamaClient(cfg)
+	if err != nil {
+		return fmt.Errorf("创建 Ollama 客户端失败: %w", err)
+	}
+	ollama = bridge.WithTracing(ollama)
+	// 与桥接端共用 bridge.policy_file，模型与 options 不符合策略时 REST 回复 403
+	if cfg.Bridge.PolicyFile != "" {
+		p, err := policy.Open(cfg.Bridge.PolicyFile)
+		if err != nil {
+			return err
+		}
+		go p.Run(ctx, logging.Component(logger, "policy"))
+		ollama = bridge.WithPolicy(ollama, p)
+		logger.Info("已加载模型策略", "path", cfg.Bridge.PolicyFile, "models", len(p.Policy().Models))
+	}
+	if chat := cfg.Server.WebSocket.Chat; chat.Enabled {
+		hub.SetFrameHandler(bridge.NewLocalChat(ollama, chat.Timeout, logging.Component(logger, "chat")))
+		logger.Info("/ws 的 chat 请求由 serve 直接处理", "ollama", cfg.Ollama.Host)
+	}
+	var openaiAPI *openai.API
+	if cfg.Server.OpenAI.Enabled {
+		openaiAPI = openai.New(ollama, s.store, usageStore, logging.Component(logger, "openai"))
+	}
+	router.SetupRoutes(logger, r, s.store, router.Deps{
+		Lifecycle: lifecycle,
+		Readiness: readiness,
+		Flags:     flags,
+		Capture:   capt,
+		Models:    modelLister,
+		Usage:     usageStore,
+		API:       handlers.New(ollama, usageStore, logging.Component(logger, "api")),
+		OpenAI:    openaiAPI,
+		Hub:       hub,
+		Plugins:   registry,
+	})
+
+	// 先绑定端口再通知 systemd 就绪，保证依赖本服务的单元启动时端口已可用
+	ln := s.c.listener
+	if ln == nil {
+		ln, err = net.Listen("tcp", cfg.Server.Addr)
+		if err != nil {
+			return fmt.Errorf("监听端口失败: %w", err)
+		}
+	}
+	// 配置了 server.tls 时以 HTTPS 提供服务，客户端证书由 auth.Verifier 按路径要求
+	tlsCfg, err := auth.ServerTLS(cfg.Server.TLS)
+	if err != nil {
+		ln.Close()
+		return err
+	}
+	if tlsCfg != nil {
+		ln = tls.NewListener(ln, tlsCfg)
+	}
+	if err := systemd.Notify(systemd.StateReady); err != nil {
+		logger.Warn("通知 systemd 就绪失败", "error", err)
+	}
+	systemd.StartWatchdog(ctx, nil)
+
+	// 启动 Gin 服务器
+	srv := &http.Server{Handler: r}
+	serveErr := make(chan error, 1)
+	go func() { serveErr <- srv.Serve(ln) }()
+	logger.Info(i18n.T(i18n.LogServerStarted), "addr", ln.Addr().String())
+
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	select {
+	case err := <-serveErr:
+		return err
+	case <-ctx.Done():
+	}
+	return shutdownServer(srv, registry, lifecycle, cfg.Server, logger)
+}
+
+// modelListers 返回 /api/models 与就绪检查使用的模型列表，注入 WithModels 时两者共用
+func (s *Server) modelListers(cfg *config.Config) (models.Lister, health.ModelLister, error) {
+	if list := s.c.models; list != nil {
+		return list, func(ctx context.Context) ([]string, error) {
+			infos, err := list(ctx)
+			if err != nil {
+				return nil, err
+			}
+			names := make([]string, 0, len(infos))
+			for _, info := range infos {
+				names = append(names, info.Name)
+			}
+			return names, nil
+		}, nil
+	}
+	listModels, err := health.OllamaModelLister(cfg.Ollama.Host)
+	if err != nil {
+		return nil, nil, err
+	}
+	modelLister, err := models.OllamaLister(cfg.Ollama.Host)
+	if err != nil {
+		return nil, nil, err
+	}
+	return modelLister, listModels, nil
+}
+
+// ollamaClient 返回 REST 接口、OpenAI 兼容接口与 /ws 本地对话使用的 Ollama 客户端，注入 WithOllamaClient 时使用注入的客户端
+func (s *Server) ollamaClient(cfg *config.Config) (bridge.OllamaClient, error) {
+	if s.c.ollama != nil {
+		return s.c.ollama, nil
+	}
+	cache := s.c.cache
+	if cache == nil {
+		cache = bridge.NewMemoryCache(cfg.Cache.TTL, cfg.Cache.CleanupInterval)
+	}
+	c, err := bridge.NewOllamaClient(cfg.Ollama.Host, cache, cfg.Cache.TTL)
+	if err != nil {
+		return nil, err
+	}
+	c.SetGenerationCache(cfg.Cache.Generations)
+	if len(cfg.Ollama.Hosts) > 0 {
+		if err := c.SetBackends(cfg.Ollama.Hosts, cfg.Ollama.Balance); err != nil {
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+// shutdownServer 先进入排空阶段 (/healthz 返回 503) 并等待 drain_delay，
+// 让编排系统摘除流量、进行中的生成完成，再在 shutdown_timeout 内关闭服务器；
+// http.Server.Shutdown 不处理已升级的 WebSocket 连接，由插件关闭 (websocket 插件的 Hub 写完各连接的队列后发送关闭帧)
+func shutdownServer(srv *http.Server, registry *plugins.Registry, lifecycle *health.Lifecycle, cfg config.ServerConfig, logger *slog.Logger) error {
+	_ = systemd.Notify(systemd.StateStopping)
+	lifecycle.StartDrain()
+	logger.Info(i18n.T(i18n.LogDrainStarted), "drain_delay", cfg.DrainDelay)
+	time.Sleep(cfg.DrainDelay)
+
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+	defer cancel()
+	if err := srv.Shutdown(ctx); err != nil {
+		return fmt.Errorf("关闭服务器失败: %w", err)
+	}
+	if err := registry.Shutdown(ctx); err != nil {
+		return err
+	}
+	logger.Info(i18n.T(i18n.LogServerStopped))
+	return nil
+}
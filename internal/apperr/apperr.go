@@ -0,0 +1,195 @@
+package apperr
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+)
+
+// Category 错误类别，客户端据此区分失败原因
+type Category string
+
+// 错误类别
+const (
+	Protocol   Category = "protocol"   // 消息格式、未知动作等协议错误
+	Auth       Category = "auth"       // 鉴权失败
+	Backend    Category = "backend"    // Ollama 后端错误或不可用
+	Timeout    Category = "timeout"    // 超时或被取消
+	Validation Category = "validation" // 参数或配置不合法
+	Internal   Category = "internal"   // 未分类的内部错误
+)
+
+// 错误码，同一类别下细分具体原因
+const (
+	CodeBadFrame           = "bad_frame"
+	CodeUnknownAction      = "unknown_action"
+	CodeBadSubprotocol     = "bad_subprotocol" // WebSocket 握手未提供可协商的子协议
+	CodeUnauthorized       = "unauthorized"
+	CodeForbidden          = "forbidden"           // 无权访问：角色不满足接口要求，或 /ws 握手的 Origin 不被允许
+	CodeDecryptFailed      = "decrypt_failed"      // 端到端加密的帧无法解密，例如租户不匹配
+	CodeEncryptionRequired = "encryption_required" // 启用端到端加密后收到明文请求
+	CodeBackendUnavailable = "backend_unavailable"
+	CodeBackendError       = "backend_error"
+	CodeBusy               = "busy"           // 请求队列已满，稍后重试
+	CodeCircuitOpen        = "circuit_open"   // 后端连续失败，熔断期间直接拒绝
+	CodeModelBusy          = "model_busy"     // 模型同时进行的生成已达上限
+	CodeRateLimited        = "rate_limited"   // 客户端超出限流 (server.rate_limit)
+	CodeQuotaExceeded      = "quota_exceeded" // 调用方当天的用量已达配额 (server.quota)
+	CodeTimeout            = "timeout"
+	CodeCancelled          = "cancelled" // 请求被对端取消
+	CodeInvalidParams      = "invalid_params"
+	CodeNotFound           = "not_found"         // 请求的资源（例如任务）不存在
+	CodeModelNotFound      = "model_not_found"   // Ollama 上没有请求的模型
+	CodeModelNotAllowed    = "model_not_allowed" // 模型不在策略文件 (bridge.policy_file) 的允许列表中
+	CodePolicyViolation    = "policy_violation"  // options 超出策略文件为该模型设置的范围
+	CodeUnknownFeature     = "unknown_feature"
+	CodeInternal           = "internal"
+)
+
+// Error 带类别与错误码的错误
+type Error struct {
+	Category Category
+	Code     string
+	Message  string
+	Err      error // 原始错误，可为 nil
+}
+
+func (e *Error) Error() string {
+	if e.Err == nil {
+		return e.Message
+	}
+	if e.Message == "" {
+		return e.Err.Error()
+	}
+	return e.Message + ": " + e.Err.Error()
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// New 创建错误
+func New(category Category, code, message string) *Error {
+	return &Error{Category: category, Code: code, Message: message}
+}
+
+// Wrap 为已有错误附加类别与错误码，err 为 nil 时返回 nil
+func Wrap(err error, category Category, code, message string) error {
+	if err == nil {
+		return nil
+	}
+	return &Error{Category: category, Code: code, Message: message, Err: err}
+}
+
+// From 返回 err 链中的 *Error，未分类的错误按 Timeout 或 Internal 归类
+func From(err error) *Error {
+	if err == nil {
+		return nil
+	}
+	var e *Error
+	if errors.As(err, &e) {
+		return e
+	}
+	if isTimeout(err) {
+		return &Error{Category: Timeout, Code: CodeTimeout, Message: err.Error(), Err: err}
+	}
+	return &Error{Category: Internal, Code: CodeInternal, Message: err.Error(), Err: err}
+}
+
+// CategoryOf 返回错误类别
+func CategoryOf(err error) Category {
+	if e := From(err); e != nil {
+		return e.Category
+	}
+	return ""
+}
+
+// CodeOf 返回错误码
+func CodeOf(err error) string {
+	if e := From(err); e != nil {
+		return e.Code
+	}
+	return ""
+}
+
+// Is 判断 err 链中是否包含指定类别的错误
+func Is(err error, category Category) bool {
+	return err != nil && CategoryOf(err) == category
+}
+
+func isTimeout(err error) bool {
+	if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, context.Canceled) {
+		return true
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
+
+// Retryable 判断请求方原样重试是否可能成功：后端错误与超时可以重试，被取消、模型不存在以及参数、协议、鉴权错误不可以
+func Retryable(err error) bool {
+	e := From(err)
+	if e == nil {
+		return false
+	}
+	switch e.Category {
+	case Backend:
+		// 配额到次日才重置，重试没有意义
+		return e.Code != CodeQuotaExceeded
+	case Timeout:
+		return e.Code != CodeCancelled
+	default:
+		return false
+	}
+}
+
+// HTTPStatus 返回错误类别对应的 HTTP 状态码
+func HTTPStatus(err error) int {
+	switch CategoryOf(err) {
+	case Protocol, Validation:
+		switch CodeOf(err) {
+		case CodeNotFound:
+			return http.StatusNotFound
+		case CodeModelNotAllowed:
+			return http.StatusForbidden
+		}
+		return http.StatusBadRequest
+	case Auth:
+		if CodeOf(err) == CodeForbidden {
+			return http.StatusForbidden
+		}
+		return http.StatusUnauthorized
+	case Backend:
+		if code := CodeOf(err); code == CodeRateLimited || code == CodeQuotaExceeded {
+			return http.StatusTooManyRequests
+		}
+		return http.StatusServiceUnavailable
+	case Timeout:
+		return http.StatusGatewayTimeout
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// Data 错误的线上表示，用于 HTTP 响应体与 WebSocket 错误帧 (status 为 error) 的 data 字段
+type Data struct {
+	Category  Category `json:"category"`
+	Code      string   `json:"code"`
+	Message   string   `json:"message"`
+	Retryable bool     `json:"retryable"` // 原样重试是否可能成功，见 Retryable
+}
+
+// ToData 将错误转换为线上表示
+func ToData(err error) Data {
+	e := From(err)
+	return Data{Category: e.Category, Code: e.Code, Message: e.Error(), Retryable: Retryable(e)}
+}
+
+// FromData 将线上表示还原为错误，便于客户端按类别判断
+func FromData(d Data) *Error {
+	category := d.Category
+	if category == "" {
+		category = Internal
+	}
+	return &Error{Category: category, Code: d.Code, Message: d.Message}
+}
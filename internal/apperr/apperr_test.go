@@ -0,0 +1,96 @@
+package apperr
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+)
+
+func TestWrapAndClassify(t *testing.T) {
+	cause := errors.New("connection refused")
+	err := fmt.Errorf("处理请求失败: %w", Wrap(cause, Backend, CodeBackendError, "调用 Ollama 失败"))
+
+	if !Is(err, Backend) || CodeOf(err) != CodeBackendError {
+		t.Fatalf("unexpected classification: %s/%s", CategoryOf(err), CodeOf(err))
+	}
+	if !errors.Is(err, cause) {
+		t.Error("wrapped error should unwrap to its cause")
+	}
+	if HTTPStatus(err) != http.StatusServiceUnavailable {
+		t.Errorf("unexpected status: %d", HTTPStatus(err))
+	}
+	if Wrap(nil, Backend, CodeBackendError, "x") != nil {
+		t.Error("Wrap(nil) should return nil")
+	}
+}
+
+func TestForbiddenStatus(t *testing.T) {
+	// 同属 auth 类别，角色不足时返回 403 而不是 401
+	if got := HTTPStatus(New(Auth, CodeForbidden, "需要 admin 角色")); got != http.StatusForbidden {
+		t.Errorf("forbidden: got %d", got)
+	}
+	if got := HTTPStatus(New(Auth, CodeUnauthorized, "Token 无效")); got != http.StatusUnauthorized {
+		t.Errorf("unauthorized: got %d", got)
+	}
+}
+
+func TestNotFoundStatus(t *testing.T) {
+	if got := HTTPStatus(New(Validation, CodeNotFound, "连接不存在")); got != http.StatusNotFound {
+		t.Errorf("not found: got %d", got)
+	}
+	if got := HTTPStatus(New(Validation, CodeModelNotAllowed, "模型不在允许列表中")); got != http.StatusForbidden {
+		t.Errorf("model not allowed: got %d", got)
+	}
+}
+
+func TestRateLimitedStatus(t *testing.T) {
+	err := New(Backend, CodeRateLimited, "请求过于频繁")
+	if got := HTTPStatus(err); got != http.StatusTooManyRequests {
+		t.Errorf("rate limited: got %d", got)
+	}
+	if !Retryable(err) {
+		t.Error("rate limited should be retryable")
+	}
+}
+
+func TestFromUnclassified(t *testing.T) {
+	if got := CategoryOf(context.DeadlineExceeded); got != Timeout {
+		t.Errorf("deadline exceeded: got %s, want %s", got, Timeout)
+	}
+	if got := CategoryOf(errors.New("boom")); got != Internal {
+		t.Errorf("plain error: got %s, want %s", got, Internal)
+	}
+}
+
+func TestDataRoundTrip(t *testing.T) {
+	d := ToData(New(Protocol, CodeUnknownAction, "未知的动作: foo"))
+	err := FromData(d)
+	if err.Category != Protocol || err.Code != CodeUnknownAction || err.Error() != "未知的动作: foo" {
+		t.Errorf("unexpected round trip: %+v", err)
+	}
+}
+
+func TestRetryable(t *testing.T) {
+	cases := []struct {
+		err  error
+		want bool
+	}{
+		{New(Backend, CodeBackendUnavailable, "Ollama 不可用"), true},
+		{New(Backend, CodeBusy, "队列已满"), true},
+		{context.DeadlineExceeded, true},
+		{New(Timeout, CodeCancelled, "请求已被取消"), false},
+		{New(Validation, CodeModelNotFound, "模型不存在"), false},
+		{New(Protocol, CodeBadFrame, "无法解析"), false},
+		{errors.New("boom"), false},
+	}
+	for _, c := range cases {
+		if got := Retryable(c.err); got != c.want {
+			t.Errorf("Retryable(%v) = %v, want %v", c.err, got, c.want)
+		}
+		if got := ToData(c.err).Retryable; got != c.want {
+			t.Errorf("ToData(%v).Retryable = %v, want %v", c.err, got, c.want)
+		}
+	}
+}
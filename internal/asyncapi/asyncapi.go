@@ -0,0 +1,146 @@
+// Package asyncapi 读取 WebSocket 协议的 AsyncAPI 文档 (api/asyncapi.yaml)，生成帧结构的 Go 类型与校验
+package asyncapi
+
+import (
+	"fmt"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Document AsyncAPI 文档中生成器用到的部分
+type Document struct {
+	AsyncAPI   string     `yaml:"asyncapi"`
+	Info       Info       `yaml:"info"`
+	Components Components `yaml:"components"`
+}
+
+// Info 文档标题与协议版本
+type Info struct {
+	Title   string `yaml:"title"`
+	Version string `yaml:"version"`
+}
+
+// Components 可复用的消息与 schema
+type Components struct {
+	Messages map[string]Message `yaml:"messages"`
+	Schemas  Schemas            `yaml:"schemas"`
+}
+
+// Message 一种帧
+type Message struct {
+	Name    string `yaml:"name"`
+	Payload Schema `yaml:"payload"`
+}
+
+// Schema JSON Schema 的子集与生成器的扩展字段
+type Schema struct {
+	Ref         string   `yaml:"$ref"`
+	Type        string   `yaml:"type"`
+	Format      string   `yaml:"format"`
+	Title       string   `yaml:"title"`
+	Description string   `yaml:"description"`
+	Required    []string `yaml:"required"`
+	Enum        []string `yaml:"enum"`
+	Items       *Schema  `yaml:"items"`
+	Properties  Schemas  `yaml:"properties"`
+
+	GoType           string   `yaml:"x-go-type"`           // 使用已有的类型，不生成
+	GoImport         string   `yaml:"x-go-import"`         // GoType 所在的包
+	GoPointer        bool     `yaml:"x-go-pointer"`        // 非必需时生成指针，区分未设置与零值；对象类型总是如此
+	GoConstPrefix    string   `yaml:"x-go-const-prefix"`   // 为 Enum 生成常量
+	EnumDescriptions []string `yaml:"x-enum-descriptions"` // 与 Enum 一一对应
+	GoValidate       bool     `yaml:"x-go-validate"`       // 生成 Validate 方法
+}
+
+// NamedSchema 带名称的 schema
+type NamedSchema struct {
+	Name   string
+	Schema *Schema
+}
+
+// Schemas 保持文档中顺序的 schema 列表，生成的字段与类型按此顺序排列
+type Schemas []NamedSchema
+
+// UnmarshalYAML 按映射中的顺序读取
+func (s *Schemas) UnmarshalYAML(n *yaml.Node) error {
+	if n.Kind != yaml.MappingNode {
+		return fmt.Errorf("第 %d 行: 应为映射", n.Line)
+	}
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		var schema Schema
+		if err := n.Content[i+1].Decode(&schema); err != nil {
+			return err
+		}
+		*s = append(*s, NamedSchema{Name: n.Content[i].Value, Schema: &schema})
+	}
+	return nil
+}
+
+// Get 返回指定名称的 schema
+func (s Schemas) Get(name string) *Schema {
+	for _, ns := range s {
+		if ns.Name == name {
+			return ns.Schema
+		}
+	}
+	return nil
+}
+
+const schemaRef = "#/components/schemas/"
+
+// Parse 解析文档并检查引用与扩展字段
+func Parse(data []byte) (*Document, error) {
+	var doc Document
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("解析 AsyncAPI 文档失败: %w", err)
+	}
+	if !strings.HasPrefix(doc.AsyncAPI, "2.") {
+		return nil, fmt.Errorf("不支持的 AsyncAPI 版本: %q", doc.AsyncAPI)
+	}
+	var check func(path string, s *Schema) error
+	check = func(path string, s *Schema) error {
+		if s.Ref != "" {
+			if !strings.HasPrefix(s.Ref, schemaRef) || doc.Components.Schemas.Get(strings.TrimPrefix(s.Ref, schemaRef)) == nil {
+				return fmt.Errorf("%s: 引用的 schema 不存在: %s", path, s.Ref)
+			}
+		}
+		if len(s.EnumDescriptions) > 0 && len(s.EnumDescriptions) != len(s.Enum) {
+			return fmt.Errorf("%s: x-enum-descriptions 与 enum 的数量不一致", path)
+		}
+		if s.GoConstPrefix != "" && len(s.Enum) == 0 {
+			return fmt.Errorf("%s: x-go-const-prefix 需要 enum", path)
+		}
+		if s.Items != nil {
+			if err := check(path+".items", s.Items); err != nil {
+				return err
+			}
+		}
+		for _, p := range s.Properties {
+			if err := check(path+"."+p.Name, p.Schema); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	for _, ns := range doc.Components.Schemas {
+		if err := check(ns.Name, ns.Schema); err != nil {
+			return nil, err
+		}
+	}
+	for name, m := range doc.Components.Messages {
+		if err := check("messages."+name, &m.Payload); err != nil {
+			return nil, err
+		}
+	}
+	return &doc, nil
+}
+
+// resolve 返回引用指向的 schema，不是引用时返回自身
+func (d *Document) resolve(s *Schema) (string, *Schema) {
+	if s.Ref == "" {
+		return "", s
+	}
+	name := strings.TrimPrefix(s.Ref, schemaRef)
+	return name, d.Components.Schemas.Get(name)
+}
@@ -0,0 +1,84 @@
+package asyncapi
+
+import (
+	"strings"
+	"testing"
+)
+
+const sample = `
+asyncapi: 2.6.0
+info: {title: t, version: "1"}
+components:
+  schemas:
+    Frame:
+      description: 测试帧
+      type: object
+      x-go-validate: true
+      required: [kind]
+      properties:
+        kind:
+          type: string
+          title: 帧的种类
+          enum: [ping, pong]
+          x-go-const-prefix: Kind
+          x-enum-descriptions: [请求, 回复]
+        at:
+          type: string
+          format: date-time
+        meta:
+          $ref: "#/components/schemas/Meta"
+          description: 附加信息
+        items:
+          type: array
+          items:
+            $ref: "#/components/schemas/Meta"
+        ttl:
+          $ref: "#/components/schemas/TTL"
+    TTL:
+      x-go-type: meta.TTL
+      x-go-import: example.com/meta
+      x-go-pointer: true
+    Meta:
+      type: object
+      x-go-type: meta.Info
+      x-go-import: example.com/meta
+`
+
+func TestGenerate(t *testing.T) {
+	doc, err := Parse([]byte(sample))
+	if err != nil {
+		t.Fatal(err)
+	}
+	src, err := Generate(doc, "frames", "sample.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	code := string(src)
+	for _, want := range []string{
+		"// 帧的种类",
+		`KindPing = "ping" // 请求`,
+		"// Frame 测试帧",
+		"`json:\"kind\"`",
+		"time.Time",
+		"*meta.Info  `json:\"meta,omitempty\"` // 附加信息",
+		"[]meta.Info",
+		"*meta.TTL",
+		"case KindPing, KindPong:",
+		"\"time\"\n\n\t\"example.com/meta\"",
+	} {
+		if !strings.Contains(code, want) {
+			t.Errorf("生成的代码缺少 %q:\n%s", want, code)
+		}
+	}
+	// x-go-type 的 schema 不生成类型
+	if strings.Contains(code, "type Meta") {
+		t.Error("不应生成 Meta")
+	}
+}
+
+func TestParseRejectsDanglingRef(t *testing.T) {
+	bad := strings.Replace(sample, "#/components/schemas/Meta\"\n          description", "#/components/schemas/Missing\"\n          description", 1)
+	if _, err := Parse([]byte(bad)); err == nil || !strings.Contains(err.Error(), "Missing") {
+		t.Fatalf("expected dangling ref error, got %v", err)
+	}
+}
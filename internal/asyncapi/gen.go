@@ -0,0 +1,227 @@
+package asyncapi
+
+import (
+	"bytes"
+	"fmt"
+	"go/build"
+	"go/format"
+	"slices"
+	"strings"
+)
+
+// Generate 生成 Go 代码：带 x-go-const-prefix 的 enum 生成为常量，未指定 x-go-type 的对象 schema
+// 生成为结构体，带 x-go-validate 的 schema 生成 Validate 方法。source 为文档路径，写入文件头
+func Generate(doc *Document, pkg, source string) ([]byte, error) {
+	g := &generator{doc: doc, imports: map[string]bool{}}
+	var generated []NamedSchema
+	for _, ns := range doc.Components.Schemas {
+		if ns.Schema.GoType == "" && ns.Schema.Type == "object" {
+			generated = append(generated, ns)
+		}
+	}
+	for _, ns := range generated {
+		g.consts(ns.Schema)
+	}
+	for _, ns := range generated {
+		g.structType(ns.Name, ns.Schema)
+		if ns.Schema.GoValidate {
+			g.validate(ns.Name, ns.Schema)
+		}
+	}
+
+	var out bytes.Buffer
+	fmt.Fprintf(&out, "// Code generated by asyncapigen from %s; DO NOT EDIT.\n\npackage %s\n\n", source, pkg)
+	if len(g.imports) > 0 {
+		imports := make([]string, 0, len(g.imports))
+		for imp := range g.imports {
+			imports = append(imports, imp)
+		}
+		// 标准库在前，其余的包另起一组
+		slices.SortFunc(imports, func(a, b string) int {
+			if sa, sb := isStdlib(a), isStdlib(b); sa != sb {
+				if sa {
+					return -1
+				}
+				return 1
+			}
+			return strings.Compare(a, b)
+		})
+		out.WriteString("import (\n")
+		for i, imp := range imports {
+			if i > 0 && isStdlib(imports[i-1]) && !isStdlib(imp) {
+				out.WriteString("\n")
+			}
+			fmt.Fprintf(&out, "\t%q\n", imp)
+		}
+		out.WriteString(")\n\n")
+	}
+	out.Write(g.body.Bytes())
+	src, err := format.Source(out.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("格式化生成的代码失败: %w", err)
+	}
+	return src, nil
+}
+
+type generator struct {
+	doc     *Document
+	body    bytes.Buffer
+	imports map[string]bool
+}
+
+func (g *generator) printf(format string, args ...any) {
+	fmt.Fprintf(&g.body, format, args...)
+}
+
+// consts 为对象中带 x-go-const-prefix 的字段生成常量组
+func (g *generator) consts(s *Schema) {
+	for _, p := range s.Properties {
+		if p.Schema.GoConstPrefix == "" {
+			continue
+		}
+		if p.Schema.Title != "" {
+			g.printf("// %s\n", p.Schema.Title)
+		}
+		g.printf("const (\n")
+		for i, v := range p.Schema.Enum {
+			g.printf("\t%s = %q", constName(p.Schema, v), v)
+			if i < len(p.Schema.EnumDescriptions) {
+				g.printf(" // %s", p.Schema.EnumDescriptions[i])
+			}
+			g.printf("\n")
+		}
+		g.printf(")\n\n")
+	}
+}
+
+func (g *generator) structType(name string, s *Schema) {
+	g.printf("// %s %s\n", name, s.Description)
+	g.printf("type %s struct {\n", name)
+	for _, p := range s.Properties {
+		required := slices.Contains(s.Required, p.Name)
+		tag := p.Name
+		if !required {
+			tag += ",omitempty"
+		}
+		g.printf("\t%s %s `json:%q`", fieldName(p.Name), g.goType(p.Schema, required), tag)
+		if p.Schema.Description != "" {
+			g.printf(" // %s", p.Schema.Description)
+		}
+		g.printf("\n")
+	}
+	g.printf("}\n\n")
+}
+
+// validate 生成校验方法：必需的字符串字段不得为空，字符串 enum 字段有值时须为列出的取值之一
+func (g *generator) validate(name string, s *Schema) {
+	recv := strings.ToLower(name[:1])
+	g.imports["fmt"] = true
+	g.printf("// Validate 校验必需字段与枚举取值\n")
+	g.printf("func (%s *%s) Validate() error {\n", recv, name)
+	for _, p := range s.Properties {
+		if g.goType(p.Schema, true) != "string" {
+			continue
+		}
+		field := recv + "." + fieldName(p.Name)
+		if slices.Contains(s.Required, p.Name) {
+			g.printf("if %s == \"\" {\nreturn fmt.Errorf(\"缺少必需字段 %s\")\n}\n", field, p.Name)
+		}
+		if len(p.Schema.Enum) == 0 {
+			continue
+		}
+		values := make([]string, len(p.Schema.Enum))
+		for i, v := range p.Schema.Enum {
+			values[i] = constName(p.Schema, v)
+		}
+		if !slices.Contains(s.Required, p.Name) {
+			g.printf("if %s != \"\" {\n", field)
+		}
+		g.printf("switch %s {\ncase %s:\ndefault:\nreturn fmt.Errorf(\"%s 的取值无效: %%q\", %s)\n}\n", field, strings.Join(values, ", "), p.Name, field)
+		if !slices.Contains(s.Required, p.Name) {
+			g.printf("}\n")
+		}
+	}
+	g.printf("return nil\n}\n\n")
+}
+
+// goType 返回 schema 对应的 Go 类型，可选的对象字段使用指针
+func (g *generator) goType(s *Schema, required bool) string {
+	if s.GoType != "" {
+		return g.use(s)
+	}
+	if name, target := g.doc.resolve(s); name != "" {
+		typ := name
+		if target.GoType != "" {
+			typ = g.use(target)
+		}
+		if (target.Type == "object" || target.GoPointer) && !required {
+			typ = "*" + typ
+		}
+		return typ
+	}
+	switch s.Type {
+	case "string":
+		switch s.Format {
+		case "date-time":
+			g.imports["time"] = true
+			return "time.Time"
+		case "byte":
+			return "[]byte"
+		}
+		return "string"
+	case "integer":
+		if s.Format == "int64" {
+			return "int64"
+		}
+		return "int"
+	case "number":
+		return "float64"
+	case "boolean":
+		return "bool"
+	case "array":
+		return "[]" + g.goType(s.Items, true)
+	case "object":
+		return "map[string]any"
+	default:
+		return "any"
+	}
+}
+
+func (g *generator) use(s *Schema) string {
+	if s.GoImport != "" {
+		g.imports[s.GoImport] = true
+	}
+	return s.GoType
+}
+
+// isStdlib 按 GOROOT 判断是否为标准库，本模块的路径没有域名，不能按首段是否含点判断
+func isStdlib(path string) bool {
+	pkg, err := build.Default.Import(path, "", build.FindOnly)
+	return err == nil && pkg.Goroot
+}
+
+// constName 返回 enum 取值对应的常量名，未指定前缀时为带引号的字面量
+func constName(s *Schema, value string) string {
+	if s.GoConstPrefix == "" {
+		return fmt.Sprintf("%q", value)
+	}
+	return s.GoConstPrefix + fieldName(value)
+}
+
+var initialisms = map[string]string{"id": "ID", "url": "URL", "api": "API", "http": "HTTP", "json": "JSON"}
+
+// fieldName 将 request_id 转换为 RequestID
+func fieldName(s string) string {
+	var b strings.Builder
+	for _, part := range strings.Split(s, "_") {
+		if part == "" {
+			continue
+		}
+		if v, ok := initialisms[part]; ok {
+			b.WriteString(v)
+			continue
+		}
+		b.WriteString(strings.ToUpper(part[:1]) + part[1:])
+	}
+	return b.String()
+}
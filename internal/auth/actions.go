@@ -0,0 +1,20 @@
+package auth
+
+import "slices"
+
+// adminActions 修改或传输 Ollama 上的模型、读写 bridge 主机上文件的动作，只允许 admin 角色经 /ws 发出
+var adminActions = []string{
+	"delete_model", "copy_model", "create_model",
+	"pull_model", "push_model",
+	"load_model", "unload_model",
+	"file_upload", "file_download",
+}
+
+// ActionRole 返回发出 /ws 请求帧的动作所需的角色，未列出的动作 (包括自定义动作) 只需 user 角色；
+// serve 在转发前校验，bridge 在处理前按 serve 写入帧的 role 再次校验
+func ActionRole(action string) string {
+	if slices.Contains(adminActions, action) {
+		return RoleAdmin
+	}
+	return RoleUser
+}
@@ -0,0 +1,198 @@
+// Package auth 为 WebSocket 握手附加凭据并在服务器端校验：
+// Bearer Token (或 JWT)、客户端证书 (mTLS) 与 HMAC 签名可以组合使用；服务器端识别的调用方为 Identity
+package auth
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"ollama_dev/internal/config"
+)
+
+// 握手签名使用的请求头
+const (
+	HeaderTimestamp = "X-Ollama-Dev-Timestamp" // 签名时间，Unix 秒
+	HeaderNonce     = "X-Ollama-Dev-Nonce"     // 随机数，防止重放
+	HeaderSignature = "X-Ollama-Dev-Signature" // hex(HMAC-SHA256(secret, 签名内容))
+)
+
+// Provider 为 WebSocket 握手附加凭据，每次拨号 (包括重连) 调用一次
+type Provider interface {
+	// Apply 设置 dialer 的 TLS 配置或 header 中的请求头，target 为要连接的地址
+	Apply(d *websocket.Dialer, header http.Header, target *url.URL) error
+}
+
+// Token 以 Authorization: Bearer 携带 Token，token 为空时不设置
+type Token string
+
+func (t Token) Apply(d *websocket.Dialer, header http.Header, target *url.URL) error {
+	if t != "" {
+		header.Set("Authorization", "Bearer "+string(t))
+	}
+	return nil
+}
+
+// ClientCert 以客户端证书连接 wss:// 服务器
+type ClientCert struct {
+	tls *tls.Config
+}
+
+// NewClientCert 读取证书与私钥，caFile 非空时只信任该 CA 签发的服务器证书
+func NewClientCert(cfg config.ClientTLSConfig) (*ClientCert, error) {
+	tlsCfg := &tls.Config{MinVersion: tls.VersionTLS12}
+	if cfg.CertFile != "" {
+		cert, err := tls.LoadX509KeyPair(cfg.CertFile, cfg.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("读取客户端证书失败: %w", err)
+		}
+		tlsCfg.Certificates = []tls.Certificate{cert}
+	}
+	if cfg.CAFile != "" {
+		pool, err := loadPool(cfg.CAFile)
+		if err != nil {
+			return nil, err
+		}
+		tlsCfg.RootCAs = pool
+	}
+	return &ClientCert{tls: tlsCfg}, nil
+}
+
+func (c *ClientCert) Apply(d *websocket.Dialer, header http.Header, target *url.URL) error {
+	d.TLSClientConfig = c.tls.Clone()
+	return nil
+}
+
+// HMAC 用共享密钥签名握手
+type HMAC struct {
+	secret []byte
+	now    func() time.Time
+}
+
+func NewHMAC(secret string) *HMAC {
+	return &HMAC{secret: []byte(secret), now: time.Now}
+}
+
+func (h *HMAC) Apply(d *websocket.Dialer, header http.Header, target *url.URL) error {
+	nonce := make([]byte, 16)
+	if _, err := rand.Read(nonce); err != nil {
+		return fmt.Errorf("生成签名随机数失败: %w", err)
+	}
+	ts := strconv.FormatInt(h.now().Unix(), 10)
+	n := hex.EncodeToString(nonce)
+	header.Set(HeaderTimestamp, ts)
+	header.Set(HeaderNonce, n)
+	header.Set(HeaderSignature, sign(h.secret, target.Path, ts, n))
+	return nil
+}
+
+// sign 签名内容为请求路径、时间与随机数，以换行分隔；路径为空时按 / 计算
+func sign(secret []byte, path, ts, nonce string) string {
+	if path == "" {
+		path = "/"
+	}
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(path + "\n" + ts + "\n" + nonce))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Chain 依次应用多个 Provider
+type Chain []Provider
+
+func (c Chain) Apply(d *websocket.Dialer, header http.Header, target *url.URL) error {
+	for _, p := range c {
+		if err := p.Apply(d, header, target); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// FromConfig 按 auth 配置组合 Provider：auth.token、auth.tls 与 auth.hmac.secret 中已配置的项都会生效
+func FromConfig(cfg config.AuthConfig) (Provider, error) {
+	chain := Chain{Token(cfg.Token)}
+	if cfg.TLS.CertFile != "" || cfg.TLS.CAFile != "" {
+		cert, err := NewClientCert(cfg.TLS)
+		if err != nil {
+			return nil, err
+		}
+		chain = append(chain, cert)
+	}
+	if cfg.HMAC.Secret != "" {
+		chain = append(chain, NewHMAC(cfg.HMAC.Secret))
+	}
+	return chain, nil
+}
+
+// Dial 以 p 附加的凭据建立 WebSocket 连接，header 中已有的请求头 (例如 Origin) 保留，p 可为 nil；
+// header 未指定 Sec-WebSocket-Protocol 时提供 ollama.v1.json 子协议，以便连接要求子协议的 serve；opts 在附加凭据前调整 Dialer (例如启用压缩)
+func Dial(ctx context.Context, p Provider, rawURL string, header http.Header, opts ...func(*websocket.Dialer)) (*websocket.Conn, *http.Response, error) {
+	target, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, nil, fmt.Errorf("无效的 WebSocket 地址: %w", err)
+	}
+	dialer := *websocket.DefaultDialer
+	header = header.Clone()
+	if header == nil {
+		header = make(http.Header)
+	}
+	if header.Get("Sec-WebSocket-Protocol") == "" {
+		dialer.Subprotocols = []string{"ollama.v1.json"}
+	}
+	for _, opt := range opts {
+		opt(&dialer)
+	}
+	if p != nil {
+		if err := p.Apply(&dialer, header, target); err != nil {
+			return nil, nil, err
+		}
+	}
+	return dialer.DialContext(ctx, rawURL, header)
+}
+
+// ServerTLS 按 server.tls 创建服务器的 TLS 配置，未配置证书时返回 nil；
+// 配置了 client_ca_file 时校验客户端提供的证书，是否必须提供由 Verifier 按路径判断
+func ServerTLS(cfg config.ServerTLSConfig) (*tls.Config, error) {
+	if cfg.CertFile == "" {
+		return nil, nil
+	}
+	cert, err := tls.LoadX509KeyPair(cfg.CertFile, cfg.KeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("读取服务器证书失败: %w", err)
+	}
+	tlsCfg := &tls.Config{MinVersion: tls.VersionTLS12, Certificates: []tls.Certificate{cert}}
+	if cfg.ClientCAFile != "" {
+		pool, err := loadPool(cfg.ClientCAFile)
+		if err != nil {
+			return nil, err
+		}
+		tlsCfg.ClientCAs = pool
+		tlsCfg.ClientAuth = tls.VerifyClientCertIfGiven
+	}
+	return tlsCfg, nil
+}
+
+func loadPool(path string) (*x509.CertPool, error) {
+	pem, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("读取 CA 证书失败: %w", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, errors.New("CA 证书文件中没有有效的 PEM 证书: " + path)
+	}
+	return pool, nil
+}
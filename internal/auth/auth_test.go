@@ -0,0 +1,118 @@
+package auth
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"ollama_dev/internal/apperr"
+	"ollama_dev/internal/config"
+)
+
+func hmacConfig(secret string) *config.Config {
+	cfg := config.Default()
+	cfg.Auth.HMAC.Secret = secret
+	return cfg
+}
+
+func TestDialWithSignedHandshake(t *testing.T) {
+	server := hmacConfig("s3cret")
+	v := NewVerifier()
+	var gotToken string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := v.Verify(server, r); err != nil {
+			http.Error(w, err.Error(), apperr.HTTPStatus(err))
+			return
+		}
+		gotToken = r.Header.Get("Authorization")
+		conn, err := (&websocket.Upgrader{}).Upgrade(w, r, nil)
+		if err == nil {
+			conn.Close()
+		}
+	}))
+	defer srv.Close()
+	wsURL := "ws" + strings.TrimPrefix(srv.URL, "http") + "/ws/"
+
+	p, err := FromConfig(server.Auth)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn, _, err := Dial(context.Background(), p, wsURL, nil)
+	if err != nil {
+		t.Fatalf("signed handshake rejected: %v", err)
+	}
+	conn.Close()
+	if gotToken != "Bearer valid-token" {
+		t.Errorf("unexpected Authorization header %q", gotToken)
+	}
+
+	// 密钥不一致或未签名时拒绝
+	for _, p := range []Provider{Chain{Token("valid-token"), NewHMAC("wrong")}, Token("valid-token")} {
+		_, resp, err := Dial(context.Background(), p, wsURL, nil)
+		if err == nil || resp == nil || resp.StatusCode != http.StatusUnauthorized {
+			t.Errorf("expected 401, got %v", err)
+		}
+	}
+}
+
+func TestHMACRejectsReplayAndSkew(t *testing.T) {
+	cfg := hmacConfig("s3cret")
+	target, _ := url.Parse("ws://example.com/ws/")
+	signed := func(h *HMAC) *http.Request {
+		r := httptest.NewRequest(http.MethodGet, "/ws/", nil)
+		if err := h.Apply(&websocket.Dialer{}, r.Header, target); err != nil {
+			t.Fatal(err)
+		}
+		return r
+	}
+
+	v := NewVerifier()
+	r := signed(NewHMAC("s3cret"))
+	if err := v.Verify(cfg, r); err != nil {
+		t.Fatalf("valid signature rejected: %v", err)
+	}
+	if err := v.Verify(cfg, r); apperr.CodeOf(err) != apperr.CodeUnauthorized {
+		t.Errorf("replayed handshake accepted: %v", err)
+	}
+
+	// 签名时间超出 max_skew
+	old := NewHMAC("s3cret")
+	old.now = func() time.Time { return time.Now().Add(-cfg.Auth.HMAC.MaxSkew - time.Minute) }
+	if err := v.Verify(cfg, signed(old)); apperr.CodeOf(err) != apperr.CodeUnauthorized {
+		t.Errorf("stale signature accepted: %v", err)
+	}
+
+	// 签名绑定路径
+	r = signed(NewHMAC("s3cret"))
+	r.URL.Path = "/api/version"
+	if err := v.Verify(cfg, r); apperr.CodeOf(err) != apperr.CodeUnauthorized {
+		t.Errorf("signature for another path accepted: %v", err)
+	}
+}
+
+func TestClientCertRequired(t *testing.T) {
+	cfg := config.Default()
+	cfg.Server.TLS = config.ServerTLSConfig{CertFile: "server.pem", KeyFile: "server.key", ClientCAFile: "ca.pem"}
+	v := NewVerifier()
+
+	r := httptest.NewRequest(http.MethodGet, "/ws/", nil)
+	if err := v.Verify(cfg, r); apperr.CodeOf(err) != apperr.CodeUnauthorized {
+		t.Errorf("plain request accepted: %v", err)
+	}
+	r.TLS = &tls.ConnectionState{}
+	if err := v.Verify(cfg, r); apperr.CodeOf(err) != apperr.CodeUnauthorized {
+		t.Errorf("TLS request without client certificate accepted: %v", err)
+	}
+	r.TLS.VerifiedChains = [][]*x509.Certificate{{{}}}
+	if err := v.Verify(cfg, r); err != nil {
+		t.Errorf("verified client certificate rejected: %v", err)
+	}
+}
@@ -0,0 +1,46 @@
+package auth
+
+import (
+	"context"
+	"slices"
+)
+
+// 角色：user 可调用 /ws、/api 与 /v1，admin 还可访问管理接口，并满足所有角色要求
+const (
+	RoleAdmin = "admin"
+	RoleUser  = "user"
+)
+
+// 鉴权方式
+const (
+	MethodJWT       = "jwt"
+	MethodToken     = "token"     // auth.token 或租户 Token
+	MethodBasic     = "basic"     // 管理员账号
+	MethodAnonymous = "anonymous" // 未配置 JWT、auth.token 与多租户时不带凭据的请求
+)
+
+// Identity 通过鉴权的调用方，由 middleware.AuthMiddleware 写入 gin.Context 与请求的 ctx
+type Identity struct {
+	Subject string   // JWT 的 sub，管理员账号时为用户名，其他方式为空
+	Tenant  string   // 所属租户
+	Roles   []string // JWT 的 roles 声明，其他方式按鉴权方式固定
+	Method  string   // 鉴权方式
+}
+
+// HasRole 是否具有 role，admin 满足所有角色
+func (id Identity) HasRole(role string) bool {
+	return slices.Contains(id.Roles, role) || slices.Contains(id.Roles, RoleAdmin)
+}
+
+type identityKey struct{}
+
+// WithIdentity 将调用方写入 ctx
+func WithIdentity(ctx context.Context, id Identity) context.Context {
+	return context.WithValue(ctx, identityKey{}, id)
+}
+
+// FromContext 读取 ctx 中的调用方，未经过鉴权时返回 false
+func FromContext(ctx context.Context) (Identity, bool) {
+	id, ok := ctx.Value(identityKey{}).(Identity)
+	return id, ok
+}
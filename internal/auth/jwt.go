@@ -0,0 +1,113 @@
+package auth
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/golang-jwt/jwt/v5"
+
+	"ollama_dev/internal/apperr"
+	"ollama_dev/internal/config"
+	"ollama_dev/internal/i18n"
+	"ollama_dev/internal/tenant"
+)
+
+// Claims serve 接受的 JWT 声明，roles 可以是字符串或字符串数组，缺省为 user
+type Claims struct {
+	jwt.RegisteredClaims
+	Tenant string           `json:"tenant,omitempty"`
+	Roles  jwt.ClaimStrings `json:"roles,omitempty"`
+}
+
+// Authenticate 识别请求的调用方，并按 Verify 校验客户端证书与握手签名：
+// 配置了 auth.jwt 时接受 JWT 或 Token，且必须携带其一；否则按 Token 识别租户，auth.token 与多租户均未配置时允许匿名访问 (default 租户)。
+// Token 与匿名访问的角色为 user
+func (v *Verifier) Authenticate(cfg *config.Config, r *http.Request) (Identity, error) {
+	id, err := v.identify(cfg, r)
+	if err != nil {
+		return Identity{}, err
+	}
+	if err := v.Verify(cfg, r); err != nil {
+		return Identity{}, err
+	}
+	return id, nil
+}
+
+func (v *Verifier) identify(cfg *config.Config, r *http.Request) (Identity, error) {
+	token := tenant.Token(r)
+	if cfg.Auth.JWT.Algorithm != "" {
+		// JWT 由三段组成，Token 不含 "."
+		if strings.Count(token, ".") == 2 {
+			return v.parseJWT(cfg.Auth.JWT, token)
+		}
+		if id, ok := tenant.Resolve(cfg.Auth, token); ok {
+			return Identity{Tenant: id, Roles: []string{RoleUser}, Method: MethodToken}, nil
+		}
+		return Identity{}, apperr.New(apperr.Auth, apperr.CodeUnauthorized, i18n.Tr(i18n.FromRequest(r), i18n.ErrUnauthorized))
+	}
+	id, ok := tenant.FromRequest(cfg.Auth, r)
+	if !ok {
+		return Identity{}, apperr.New(apperr.Auth, apperr.CodeUnauthorized, i18n.Tr(i18n.FromRequest(r), i18n.ErrUnauthorized))
+	}
+	method := MethodToken
+	if token == "" {
+		method = MethodAnonymous
+	}
+	return Identity{Tenant: id, Roles: []string{RoleUser}, Method: method}, nil
+}
+
+// parseJWT 校验签名、exp (必需)、nbf 以及配置的 iss 与 aud
+func (v *Verifier) parseJWT(cfg config.JWTConfig, raw string) (Identity, error) {
+	key, err := v.jwtKey(cfg)
+	if err != nil {
+		return Identity{}, apperr.Wrap(err, apperr.Internal, apperr.CodeInternal, "读取 JWT 公钥失败")
+	}
+	opts := []jwt.ParserOption{
+		jwt.WithValidMethods([]string{cfg.Algorithm}),
+		jwt.WithExpirationRequired(),
+		jwt.WithLeeway(cfg.Leeway),
+		jwt.WithTimeFunc(v.now),
+	}
+	if cfg.Issuer != "" {
+		opts = append(opts, jwt.WithIssuer(cfg.Issuer))
+	}
+	if cfg.Audience != "" {
+		opts = append(opts, jwt.WithAudience(cfg.Audience))
+	}
+	var claims Claims
+	if _, err := jwt.ParseWithClaims(raw, &claims, func(*jwt.Token) (any, error) { return key, nil }, opts...); err != nil {
+		return Identity{}, apperr.Wrap(err, apperr.Auth, apperr.CodeUnauthorized, "JWT 无效")
+	}
+	id := Identity{Subject: claims.Subject, Tenant: claims.Tenant, Roles: claims.Roles, Method: MethodJWT}
+	if id.Tenant == "" {
+		id.Tenant = tenant.Default
+	}
+	if len(id.Roles) == 0 {
+		id.Roles = []string{RoleUser}
+	}
+	return id, nil
+}
+
+// jwtKey 返回校验签名的密钥，RS256 的公钥按文件路径缓存
+func (v *Verifier) jwtKey(cfg config.JWTConfig) (any, error) {
+	if cfg.Algorithm != "RS256" {
+		return []byte(cfg.Secret), nil
+	}
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if key, ok := v.keys[cfg.PublicKeyFile]; ok {
+		return key, nil
+	}
+	pem, err := os.ReadFile(cfg.PublicKeyFile)
+	if err != nil {
+		return nil, err
+	}
+	key, err := jwt.ParseRSAPublicKeyFromPEM(pem)
+	if err != nil {
+		return nil, fmt.Errorf("解析 %s 失败: %w", cfg.PublicKeyFile, err)
+	}
+	v.keys[cfg.PublicKeyFile] = key
+	return key, nil
+}
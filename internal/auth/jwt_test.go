@@ -0,0 +1,165 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+
+	"ollama_dev/internal/apperr"
+	"ollama_dev/internal/config"
+)
+
+const testSecret = "0123456789abcdef0123456789abcdef"
+
+func jwtConfig() *config.Config {
+	cfg := config.Default()
+	cfg.Auth.JWT.Algorithm = "HS256"
+	cfg.Auth.JWT.Secret = testSecret
+	cfg.Auth.JWT.Issuer = "ollama-dev-test"
+	return cfg
+}
+
+func bearer(token string) *http.Request {
+	r := httptest.NewRequest(http.MethodGet, "/api/version", nil)
+	if token != "" {
+		r.Header.Set("Authorization", "Bearer "+token)
+	}
+	return r
+}
+
+func signHS256(t *testing.T, claims Claims) string {
+	t.Helper()
+	s, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(testSecret))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func validClaims() Claims {
+	return Claims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   "alice",
+			Issuer:    "ollama-dev-test",
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+		},
+		Tenant: "acme",
+		Roles:  jwt.ClaimStrings{RoleAdmin},
+	}
+}
+
+func TestAuthenticateJWT(t *testing.T) {
+	cfg, v := jwtConfig(), NewVerifier()
+	id, err := v.Authenticate(cfg, bearer(signHS256(t, validClaims())))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id.Subject != "alice" || id.Tenant != "acme" || id.Method != MethodJWT || !id.HasRole(RoleAdmin) || !id.HasRole(RoleUser) {
+		t.Errorf("identity = %+v", id)
+	}
+
+	// 未声明 tenant 与 roles 时为 default 租户的 user
+	claims := validClaims()
+	claims.Tenant, claims.Roles = "", nil
+	id, err = v.Authenticate(cfg, bearer(signHS256(t, claims)))
+	if err != nil || id.Tenant != "default" || !slices.Equal(id.Roles, []string{RoleUser}) || id.HasRole(RoleAdmin) {
+		t.Errorf("identity = %+v, %v", id, err)
+	}
+}
+
+func TestAuthenticateRejectsInvalidJWT(t *testing.T) {
+	cfg, v := jwtConfig(), NewVerifier()
+	expired := validClaims()
+	expired.ExpiresAt = jwt.NewNumericDate(time.Now().Add(-time.Hour))
+	noExp := validClaims()
+	noExp.ExpiresAt = nil
+	wrongIssuer := validClaims()
+	wrongIssuer.Issuer = "someone-else"
+	other, _ := jwt.NewWithClaims(jwt.SigningMethodHS256, validClaims()).SignedString([]byte("another-secret-another-secret-xx"))
+	// 不接受配置之外的算法
+	none, _ := jwt.NewWithClaims(jwt.SigningMethodNone, validClaims()).SignedString(jwt.UnsafeAllowNoneSignatureType)
+
+	for name, token := range map[string]string{
+		"expired":      signHS256(t, expired),
+		"no exp":       signHS256(t, noExp),
+		"wrong issuer": signHS256(t, wrongIssuer),
+		"wrong key":    other,
+		"alg none":     none,
+		"garbage":      "a.b.c",
+		"missing":      "",
+		"wrong token":  "not-a-token",
+	} {
+		if _, err := v.Authenticate(cfg, bearer(token)); apperr.CodeOf(err) != apperr.CodeUnauthorized {
+			t.Errorf("%s: expected unauthorized, got %v", name, err)
+		}
+	}
+}
+
+func TestAuthenticateTokens(t *testing.T) {
+	v := NewVerifier()
+	// 启用 JWT 后 Token 仍然有效，角色为 user
+	cfg := jwtConfig()
+	cfg.Auth.Tenants = []config.TenantConfig{{ID: "acme", Token: "acme-token"}}
+	id, err := v.Authenticate(cfg, bearer("acme-token"))
+	if err != nil || id.Tenant != "acme" || id.Method != MethodToken || id.HasRole(RoleAdmin) {
+		t.Errorf("identity = %+v, %v", id, err)
+	}
+
+	// 配置了 auth.token 时不带 Token 的请求返回 401
+	cfg = config.Default()
+	if _, err := v.Authenticate(cfg, bearer("")); apperr.CodeOf(err) != apperr.CodeUnauthorized {
+		t.Errorf("expected unauthorized without token, got %v", err)
+	}
+	id, err = v.Authenticate(cfg, bearer(cfg.Auth.Token))
+	if err != nil || id.Tenant != "default" || id.Method != MethodToken {
+		t.Errorf("identity = %+v, %v", id, err)
+	}
+
+	// 未配置 JWT、auth.token 与多租户时允许匿名访问
+	cfg.Auth.Token = ""
+	id, err = v.Authenticate(cfg, bearer(""))
+	if err != nil || id.Tenant != "default" || id.Method != MethodAnonymous || !id.HasRole(RoleUser) {
+		t.Errorf("identity = %+v, %v", id, err)
+	}
+}
+
+func TestAuthenticateRS256(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "jwt.pub")
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	cfg := jwtConfig()
+	cfg.Auth.JWT.Algorithm, cfg.Auth.JWT.PublicKeyFile = "RS256", path
+
+	token, err := jwt.NewWithClaims(jwt.SigningMethodRS256, validClaims()).SignedString(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	v := NewVerifier()
+	if id, err := v.Authenticate(cfg, bearer(token)); err != nil || id.Subject != "alice" {
+		t.Errorf("identity = %+v, %v", id, err)
+	}
+	// 配置为 RS256 时不接受以公钥内容作为 HS256 密钥签名的令牌
+	forged, _ := jwt.NewWithClaims(jwt.SigningMethodHS256, validClaims()).SignedString(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}))
+	if _, err := v.Authenticate(cfg, bearer(forged)); apperr.CodeOf(err) != apperr.CodeUnauthorized {
+		t.Errorf("expected HS256 token rejected, got %v", err)
+	}
+}
@@ -0,0 +1,66 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/rsa"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/patrickmn/go-cache"
+
+	"ollama_dev/internal/apperr"
+	"ollama_dev/internal/config"
+)
+
+// Verifier 识别调用方 (JWT 或 Token) 并校验握手的客户端证书与 HMAC 签名；
+// 每次校验读取传入的配置，支持热加载
+type Verifier struct {
+	nonces *cache.Cache // 已使用的随机数，保留 max_skew 以拒绝重放
+	now    func() time.Time
+
+	mu   sync.Mutex
+	keys map[string]*rsa.PublicKey // RS256 公钥，按文件路径缓存
+}
+
+func NewVerifier() *Verifier {
+	return &Verifier{nonces: cache.New(5*time.Minute, 10*time.Minute), now: time.Now, keys: make(map[string]*rsa.PublicKey)}
+}
+
+// Verify 配置了 server.tls.client_ca_file 时要求已校验的客户端证书，配置了 auth.hmac.secret 时要求有效的签名
+func (v *Verifier) Verify(cfg *config.Config, r *http.Request) error {
+	if cfg.Server.TLS.ClientCAFile != "" && (r.TLS == nil || len(r.TLS.VerifiedChains) == 0) {
+		return unauthorized("缺少有效的客户端证书")
+	}
+	if cfg.Auth.HMAC.Secret != "" {
+		return v.verifyHMAC(cfg.Auth.HMAC, r)
+	}
+	return nil
+}
+
+func (v *Verifier) verifyHMAC(cfg config.HMACConfig, r *http.Request) error {
+	ts, nonce, sig := r.Header.Get(HeaderTimestamp), r.Header.Get(HeaderNonce), r.Header.Get(HeaderSignature)
+	if ts == "" || nonce == "" || sig == "" {
+		return unauthorized("缺少握手签名")
+	}
+	sec, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil {
+		return unauthorized("无效的签名时间")
+	}
+	if skew := v.now().Sub(time.Unix(sec, 0)).Abs(); skew > cfg.MaxSkew {
+		return unauthorized("签名时间与服务器相差 " + skew.Round(time.Second).String() + "，请检查时钟")
+	}
+	if !hmac.Equal([]byte(sig), []byte(sign([]byte(cfg.Secret), r.URL.Path, ts, nonce))) {
+		return unauthorized("签名无效")
+	}
+	// 时间窗口内同一随机数只接受一次
+	if err := v.nonces.Add(nonce, struct{}{}, 2*cfg.MaxSkew); err != nil {
+		return unauthorized("重复的握手签名")
+	}
+	return nil
+}
+
+func unauthorized(msg string) error {
+	return apperr.New(apperr.Auth, apperr.CodeUnauthorized, msg)
+}
@@ -0,0 +1,129 @@
+package breaker
+
+import (
+	"expvar"
+	"fmt"
+	"sync"
+	"time"
+
+	"ollama_dev/internal/apperr"
+	"ollama_dev/internal/config"
+)
+
+// metrics 各熔断器的状态与打开次数，发布在 /debug/vars 的 breaker 字段
+var metrics = expvar.NewMap("breaker")
+
+// State 熔断器状态
+type State string
+
+const (
+	Closed   State = "closed"    // 正常调用
+	Open     State = "open"      // 熔断中，调用直接失败
+	HalfOpen State = "half_open" // 冷却结束，放行少量探测调用
+)
+
+// Status 熔断器状态快照，随心跳上报
+type Status struct {
+	State    State     `json:"state"`
+	Failures int       `json:"failures,omitempty"` // 连续失败次数
+	RetryAt  time.Time `json:"retry_at,omitzero"`  // 熔断中时下一次放行探测的时间
+}
+
+// Breaker 连续失败 failures 次后打开，open_timeout 后进入半开状态放行 half_open_probes 个探测调用，
+// 探测成功则关闭，失败则重新打开。为 nil 时不熔断
+type Breaker struct {
+	name        string
+	threshold   int
+	openTimeout time.Duration
+	maxProbes   int
+	isFailure   func(error) bool
+	now         func() time.Time
+
+	mu       sync.Mutex
+	state    State
+	failures int
+	openedAt time.Time
+	probes   int // 半开状态下进行中的探测调用
+}
+
+// New 创建熔断器，cfg.Failures 为 0 时返回 nil；isFailure 判断错误是否计入失败（例如模型不存在不应触发熔断），为 nil 时全部计入
+func New(name string, cfg config.BreakerConfig, isFailure func(error) bool) *Breaker {
+	if cfg.Failures <= 0 {
+		return nil
+	}
+	if isFailure == nil {
+		isFailure = func(error) bool { return true }
+	}
+	b := &Breaker{
+		name:        name,
+		threshold:   cfg.Failures,
+		openTimeout: cfg.OpenTimeout,
+		maxProbes:   max(cfg.HalfOpenProbes, 1),
+		isFailure:   isFailure,
+		now:         time.Now,
+		state:       Closed,
+	}
+	metrics.Set(name, expvar.Func(func() any { return b.Status() }))
+	return b
+}
+
+// Allow 判断是否放行调用，放行时返回的 done 需在调用结束后以调用结果调用一次；熔断中返回 circuit_open 错误
+func (b *Breaker) Allow() (done func(err error), err error) {
+	if b == nil {
+		return func(error) {}, nil
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.state == Open && b.now().Sub(b.openedAt) >= b.openTimeout {
+		b.state, b.probes = HalfOpen, 0
+	}
+	switch b.state {
+	case Open:
+		retry := b.openedAt.Add(b.openTimeout).Sub(b.now()).Round(time.Second)
+		return nil, apperr.New(apperr.Backend, apperr.CodeCircuitOpen, fmt.Sprintf("%s 连续失败 %d 次，熔断中，%s 后重试", b.name, b.failures, retry))
+	case HalfOpen:
+		if b.probes >= b.maxProbes {
+			return nil, apperr.New(apperr.Backend, apperr.CodeCircuitOpen, fmt.Sprintf("%s 熔断恢复中，正在探测", b.name))
+		}
+		b.probes++
+		return func(err error) { b.record(err, true) }, nil
+	}
+	return func(err error) { b.record(err, false) }, nil
+}
+
+func (b *Breaker) record(err error, probe bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if probe {
+		b.probes--
+	}
+	if err == nil || !b.isFailure(err) {
+		// 后端有响应（包括不计入失败的错误）即视为恢复，清零失败次数
+		if b.state != Closed {
+			metrics.Add(b.name+"_closes", 1)
+		}
+		b.state, b.failures = Closed, 0
+		return
+	}
+	b.failures++
+	if b.state == HalfOpen || b.failures >= b.threshold {
+		if b.state != Open {
+			metrics.Add(b.name+"_opens", 1)
+		}
+		b.state, b.openedAt = Open, b.now()
+	}
+}
+
+// Status 返回当前状态
+func (b *Breaker) Status() Status {
+	if b == nil {
+		return Status{State: Closed}
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	s := Status{State: b.state, Failures: b.failures}
+	if b.state == Open {
+		s.RetryAt = b.openedAt.Add(b.openTimeout)
+	}
+	return s
+}
@@ -0,0 +1,105 @@
+package breaker
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"ollama_dev/internal/apperr"
+	"ollama_dev/internal/config"
+)
+
+var errDown = errors.New("connection refused")
+
+func newTestBreaker(probes int) (*Breaker, *time.Time) {
+	now := time.Unix(0, 0)
+	b := New("test", config.BreakerConfig{Failures: 3, OpenTimeout: 10 * time.Second, HalfOpenProbes: probes}, nil)
+	b.now = func() time.Time { return now }
+	return b, &now
+}
+
+// call 执行一次调用并返回 Allow 的错误
+func call(b *Breaker, result error) error {
+	done, err := b.Allow()
+	if err != nil {
+		return err
+	}
+	done(result)
+	return nil
+}
+
+func TestOpensAfterConsecutiveFailures(t *testing.T) {
+	b, _ := newTestBreaker(1)
+	call(b, errDown)
+	call(b, errDown)
+	// 成功调用清零失败次数
+	call(b, nil)
+	for range 3 {
+		if err := call(b, errDown); err != nil {
+			t.Fatalf("breaker opened too early: %v", err)
+		}
+	}
+	err := call(b, nil)
+	if apperr.CodeOf(err) != apperr.CodeCircuitOpen || apperr.CategoryOf(err) != apperr.Backend {
+		t.Fatalf("expected circuit_open, got %v", err)
+	}
+	if s := b.Status(); s.State != Open || s.Failures != 3 || s.RetryAt.IsZero() {
+		t.Errorf("unexpected status %+v", s)
+	}
+}
+
+func TestHalfOpenProbes(t *testing.T) {
+	b, now := newTestBreaker(1)
+	for range 3 {
+		call(b, errDown)
+	}
+
+	// 冷却结束后只放行一个探测调用，探测失败重新打开
+	*now = now.Add(10 * time.Second)
+	done, err := b.Allow()
+	if err != nil {
+		t.Fatalf("expected probe to be allowed, got %v", err)
+	}
+	if _, err := b.Allow(); err == nil {
+		t.Error("only one probe should be in flight")
+	}
+	done(errDown)
+	if b.Status().State != Open {
+		t.Fatalf("failed probe should reopen, got %s", b.Status().State)
+	}
+	if err := call(b, nil); err == nil {
+		t.Error("reopened breaker should reject until the next timeout")
+	}
+
+	// 再次冷却后探测成功，恢复正常
+	*now = now.Add(10 * time.Second)
+	if err := call(b, nil); err != nil {
+		t.Fatal(err)
+	}
+	if s := b.Status(); s.State != Closed || s.Failures != 0 {
+		t.Errorf("expected closed after successful probe, got %+v", s)
+	}
+}
+
+func TestIgnoredErrorsDoNotTrip(t *testing.T) {
+	notFound := errors.New("model not found")
+	b := New("ignored", config.BreakerConfig{Failures: 1, OpenTimeout: time.Minute, HalfOpenProbes: 1}, func(err error) bool { return err != notFound })
+	for range 5 {
+		if err := call(b, notFound); err != nil {
+			t.Fatalf("ignored error tripped the breaker: %v", err)
+		}
+	}
+}
+
+func TestNilBreakerAllowsEverything(t *testing.T) {
+	if b := New("off", config.BreakerConfig{}, nil); b != nil {
+		t.Fatal("failures 0 should disable the breaker")
+	}
+	var b *Breaker
+	if err := call(b, errDown); err != nil {
+		t.Fatal(err)
+	}
+	if b.Status().State != Closed {
+		t.Error("nil breaker should report closed")
+	}
+}
@@ -0,0 +1,108 @@
+package bridge
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/ollama/ollama/api"
+
+	"ollama_dev/internal/breaker"
+)
+
+// breakerClient 为 Chat、ChatStream、Generate、Embed 与 ListModels 加上熔断，Heartbeat 作为健康探测不经过熔断
+type breakerClient struct {
+	OllamaClient
+	breaker *breaker.Breaker
+}
+
+// withBreaker 返回经过熔断的客户端，b 为 nil 时原样返回
+func withBreaker(c OllamaClient, b *breaker.Breaker) OllamaClient {
+	if b == nil {
+		return c
+	}
+	return &breakerClient{OllamaClient: c, breaker: b}
+}
+
+func (c *breakerClient) Chat(ctx context.Context, req ChatRequest) (Reply, error) {
+	done, err := c.breaker.Allow()
+	if err != nil {
+		return Reply{}, err
+	}
+	reply, err := c.OllamaClient.Chat(ctx, req)
+	done(err)
+	return reply, err
+}
+
+func (c *breakerClient) ChatStream(ctx context.Context, req ChatRequest, onChunk func(string) error) (Reply, error) {
+	done, err := c.breaker.Allow()
+	if err != nil {
+		return Reply{}, err
+	}
+	// onChunk 返回的错误（例如流控超时）来自云端，不计入 Ollama 的失败
+	var chunkErr error
+	reply, err := c.OllamaClient.ChatStream(ctx, req, func(chunk string) error {
+		chunkErr = onChunk(chunk)
+		return chunkErr
+	})
+	if chunkErr != nil {
+		done(nil)
+	} else {
+		done(err)
+	}
+	return reply, err
+}
+
+func (c *breakerClient) Generate(ctx context.Context, req GenerateRequest, onChunk func(string) error) (Reply, error) {
+	done, err := c.breaker.Allow()
+	if err != nil {
+		return Reply{}, err
+	}
+	var chunkErr error
+	if onChunk != nil {
+		fn := onChunk
+		onChunk = func(chunk string) error {
+			chunkErr = fn(chunk)
+			return chunkErr
+		}
+	}
+	reply, err := c.OllamaClient.Generate(ctx, req, onChunk)
+	if chunkErr != nil {
+		done(nil)
+	} else {
+		done(err)
+	}
+	return reply, err
+}
+
+func (c *breakerClient) Embed(ctx context.Context, req EmbedRequest) (Embeddings, error) {
+	done, err := c.breaker.Allow()
+	if err != nil {
+		return Embeddings{}, err
+	}
+	e, err := c.OllamaClient.Embed(ctx, req)
+	done(err)
+	return e, err
+}
+
+func (c *breakerClient) ListModels(ctx context.Context) ([]ModelInfo, error) {
+	done, err := c.breaker.Allow()
+	if err != nil {
+		return nil, err
+	}
+	models, err := c.OllamaClient.ListModels(ctx)
+	done(err)
+	return models, err
+}
+
+// isOllamaFailure 判断错误是否说明 Ollama 不可用：连接失败与 5xx 计入，模型不存在等 4xx、取消与超过动作时限不计入
+func isOllamaFailure(err error) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	var status api.StatusError
+	if errors.As(err, &status) {
+		return status.StatusCode >= http.StatusInternalServerError
+	}
+	return true
+}
@@ -0,0 +1,294 @@
+package bridge
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+
+	"github.com/gorilla/websocket"
+	"github.com/ollama/ollama/api"
+
+	"ollama_dev/internal/breaker"
+	"ollama_dev/internal/capture"
+	"ollama_dev/internal/config"
+	"ollama_dev/internal/dashboard"
+	"ollama_dev/internal/debug"
+	"ollama_dev/internal/jobs"
+	"ollama_dev/internal/keystore"
+	"ollama_dev/internal/logging"
+	"ollama_dev/internal/metrics"
+	"ollama_dev/internal/policy"
+	"ollama_dev/internal/retry"
+	"ollama_dev/internal/scheduler"
+	"ollama_dev/internal/script"
+	"ollama_dev/internal/stats"
+	"ollama_dev/internal/throttle"
+	"ollama_dev/internal/util"
+	"ollama_dev/internal/util/wsutils"
+	"ollama_dev/internal/wasm"
+)
+
+// Logger 接口定义日志操作
+type Logger interface {
+	Info(msg string, args ...any)
+	Error(msg string, args ...any)
+}
+
+// WSClient 接口定义 WebSocket 操作
+type WSClient interface {
+	Connect(url string) error
+	ReadMessage() ([]byte, error)
+	WriteMessage(message []byte) error // message 可能来自缓冲池，返回后不得再引用
+	Close() error
+	Conn() *websocket.Conn // 新增接口方法
+}
+
+// OllamaClient 接口定义 Ollama 操作
+type OllamaClient interface {
+	Chat(ctx context.Context, req ChatRequest) (Reply, error)
+	// ChatStream 流式对话，每个增量片段调用 onChunk，onChunk 返回错误时中止生成，返回完整回复
+	ChatStream(ctx context.Context, req ChatRequest, onChunk func(string) error) (Reply, error)
+	ListModels(ctx context.Context) ([]ModelInfo, error)
+	Heartbeat(ctx context.Context) error
+	// Generate 文本补全，onChunk 为 nil 时不使用流式，否则每个增量片段调用 onChunk，返回完整结果
+	Generate(ctx context.Context, req GenerateRequest, onChunk func(string) error) (Reply, error)
+	// Embed 计算 req.Input 中每条文本的向量
+	Embed(ctx context.Context, req EmbedRequest) (Embeddings, error)
+}
+
+// ChatRequest chat 动作的参数
+type ChatRequest struct {
+	Model     string
+	Messages  []api.Message
+	Tools     api.Tools      // 模型可以调用的工具，为空时不发送
+	Options   map[string]any // 原样传给 Ollama 的模型参数，启用策略时已补全默认的 num_predict
+	KeepAlive *api.Duration  // 生成后模型在内存中保留的时长，nil 时按 Ollama 的默认值
+	NoCache   bool           // 既不读取也不写入生成结果缓存，对应 params.no_cache
+}
+
+// GenerateRequest generate 动作的参数
+type GenerateRequest struct {
+	Model     string
+	Prompt    string
+	System    string
+	Template  string
+	Options   map[string]any // 原样传给 Ollama 的模型参数，启用策略时已补全默认的 num_predict
+	KeepAlive *api.Duration  // 生成后模型在内存中保留的时长，nil 时按 Ollama 的默认值
+	NoCache   bool           // 既不读取也不写入生成结果缓存，对应 params.no_cache
+}
+
+// EmbedRequest embeddings 动作的参数
+type EmbedRequest struct {
+	Model   string
+	Input   []string
+	Options map[string]any
+}
+
+// Embeddings 向量结果，Vectors 与输入一一对应
+type Embeddings struct {
+	Vectors [][]float32
+	Usage   Usage
+}
+
+// Deps 桥接服务的可替换组件，为 nil 的字段按配置创建
+type Deps struct {
+	WSClient WSClient     // 默认为 NewWebSocketClient，抓包与分片包装在其外层
+	Ollama   OllamaClient // 默认为 NewOllamaClient；未实现 jobs.Transfer 时不支持模型拉取与推送
+	Cache    Cache        // 默认 Ollama 客户端的模型列表缓存，默认按 cache.backend 创建；注入 Ollama 时不使用
+}
+
+// Run 连接到配置的 WebSocket 地址并运行桥接服务，ctx 结束时关闭连接并返回
+func Run(ctx context.Context, logger *slog.Logger, cfg *config.Config, deps Deps) error {
+	targets := cfg.Bridge.Targets()
+	if targets[0].URL == "" {
+		return fmt.Errorf("未提供有效的 WebSocket 地址")
+	}
+	if deps.WSClient != nil && len(targets) > 1 {
+		return fmt.Errorf("注入的 WSClient 只能连接一个上游，配置了 %d 个", len(targets))
+	}
+
+	// WebSocket 抓包，未启用时为 nil，可通过诊断端口下载
+	capt, err := capture.New(cfg.Capture, cfg.Log.Redact)
+	if err != nil {
+		return err
+	}
+	defer capt.Close()
+	debug.Register(debug.CapturePath, capt.Handler())
+	debug.Register(stats.DebugPath, stats.Handler())
+	debug.Register(dashboard.DebugPath+"/", dashboard.Handler(logger))
+	sched := scheduler.New()
+	debug.Register(scheduler.DebugPath, sched.Handler())
+	// 模型拉取与推送任务，重启后恢复未完成的任务
+	jobStore, err := jobs.Open(cfg.Bridge.JobsFile)
+	if err != nil {
+		return err
+	}
+	defer jobStore.Close()
+	debug.Register(jobs.DebugPath, jobStore.Handler())
+
+	debug.StartServer(logger, cfg.Bridge.DebugAddr, cfg.Admin)
+	metrics.StartServer(logger, cfg.Bridge.MetricsAddr)
+
+	// 模型传输共用的带宽限制，包括进度流与 mirror 的 blob 传输
+	transferLimiter := throttle.New(cfg.Bridge.TransferRate)
+	mirrorCtx, stopMirror := context.WithCancel(ctx)
+	blobMirror, err := startMirror(mirrorCtx, cfg.Bridge.Mirror, transferLimiter, logger)
+	if err != nil {
+		stopMirror()
+		return fmt.Errorf("启动模型层缓存失败: %w", err)
+	}
+	// 退出时中断后台下载，未完成的临时文件在下次启动时清理
+	defer func() {
+		stopMirror()
+		if blobMirror != nil {
+			blobMirror.Wait()
+		}
+	}()
+
+	ollamaClient := deps.Ollama
+	if ollamaClient == nil {
+		cache := deps.Cache
+		if cache == nil {
+			if cache, err = NewCache(cfg.Cache); err != nil {
+				return err
+			}
+			if closer, ok := cache.(io.Closer); ok {
+				defer closer.Close()
+			}
+		}
+		c, err := NewOllamaClient(cfg.Ollama.Host, cache, cfg.Cache.TTL)
+		if err != nil {
+			return fmt.Errorf("创建Ollama客户端失败: %w", err)
+		}
+		c.SetPullVia(cfg.Bridge.Mirror.PullVia)
+		c.SetGenerationCache(cfg.Cache.Generations)
+		if len(cfg.Ollama.Hosts) > 0 {
+			if err := c.SetBackends(cfg.Ollama.Hosts, cfg.Ollama.Balance); err != nil {
+				return fmt.Errorf("创建Ollama客户端失败: %w", err)
+			}
+		}
+		ollamaClient = c
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	// 定时任务，未配置 schedule.jobs 时 Run 直接返回
+	if err := addScheduledJobs(sched, cfg, ollamaClient); err != nil {
+		return err
+	}
+	go sched.Run(ctx, logger)
+
+	health := NewHealthChecker(ollamaClient.Heartbeat, cfg.Bridge.Health)
+	go health.Run(ctx, logger)
+	// 状态端口先于连接启动，连接建立前 /readyz 返回 503
+	status := newStatusReporter(targets, health, ollamaClient, cfg.Bridge.Health.Timeout)
+	startStatusServer(ctx, cfg.Bridge.StatusAddr, status, logger)
+
+	// 熔断只作用于请求处理，健康探测与模型传输任务直接调用 Ollama
+	ollamaBreaker := breaker.New("ollama", cfg.Bridge.Breaker, isOllamaFailure)
+	// 并发限制在熔断之外，排队中的请求不占用熔断的探测名额；span 在重试之内，每次尝试各有一个
+	handlerFactory := NewHandlerFactory(withBulkhead(withRetry(withBreaker(WithTracing(ollamaClient), ollamaBreaker), retry.New(cfg.Bridge.OllamaRetry)), newBulkhead(cfg.Bridge.ModelConcurrency)), logger)
+	scripts, err := script.Load(cfg.Bridge.Scripts, logging.Component(logger, "script"))
+	if err != nil {
+		return fmt.Errorf("加载脚本失败: %w", err)
+	}
+	defer scripts.Close()
+	if err := handlerFactory.SetScripts(scripts); err != nil {
+		return err
+	}
+	if scripts != nil {
+		logger.Info("已加载脚本", "dir", cfg.Bridge.Scripts.Dir, "actions", scripts.Actions(), "before", scripts.HasBefore(), "after", scripts.HasAfter())
+	}
+	transforms, err := wasm.Load(ctx, cfg.Bridge.WASM, logging.Component(logger, "wasm"))
+	if err != nil {
+		return fmt.Errorf("加载 WASM 模块失败: %w", err)
+	}
+	defer transforms.Close()
+	handlerFactory.SetTransforms(transforms)
+	if transforms != nil {
+		logger.Info("已加载 WASM 模块", "dir", cfg.Bridge.WASM.Dir, "request", transforms.HasBefore(), "response", transforms.HasAfter())
+	}
+	if transfer, ok := ollamaClient.(jobs.Transfer); ok {
+		jobManager := jobs.NewManager(ctx, jobStore, transfer, logger)
+		jobManager.SetTimeout(jobs.KindPull, cfg.Bridge.Timeouts.For("pull_model"))
+		jobManager.SetTimeout(jobs.KindPush, cfg.Bridge.Timeouts.For("push_model"))
+		// 退出时中断运行中的任务，任务保持 running 状态，下次启动时恢复
+		defer func() {
+			cancel()
+			jobManager.Wait()
+		}()
+		if n := jobManager.Resume(); n > 0 {
+			logger.Info("已恢复上次未完成的模型传输任务", "count", n)
+		}
+		handlerFactory.SetJobs(jobManager, transferLimiter)
+	} else {
+		logger.Warn("Ollama 客户端不支持模型传输，pull_model 与 push_model 不可用")
+	}
+	if m, ok := ollamaClient.(ModelManager); ok {
+		handlerFactory.SetModelManager(m)
+	}
+	handlerFactory.SetSessions(NewSessionStore(cfg.Bridge.Sessions))
+	handlerFactory.SetImages(cfg.Bridge.Images)
+	if files := cfg.Bridge.Files; files.Dir != "" {
+		dir, err := wsutils.NewTransferDir(files.Dir, files.MaxSize, files.ChunkSize)
+		if err != nil {
+			return err
+		}
+		handlerFactory.SetFiles(dir)
+		logger.Info("已启用文件传输", "dir", files.Dir)
+	}
+	if cfg.Bridge.PolicyFile != "" {
+		p, err := policy.Open(cfg.Bridge.PolicyFile)
+		if err != nil {
+			return err
+		}
+		go p.Run(ctx, logging.Component(logger, "policy"))
+		handlerFactory.SetPolicy(p)
+		logger.Info("已加载模型策略", "path", cfg.Bridge.PolicyFile, "models", len(p.Policy().Models))
+	}
+	if m, ok := ollamaClient.(ModelInspector); ok {
+		handlerFactory.SetModelInspector(m)
+	}
+	if m, ok := ollamaClient.(ModelLoader); ok {
+		handlerFactory.SetModelLoader(m)
+	}
+	if m, ok := ollamaClient.(ModelCreator); ok {
+		handlerFactory.SetModelCreator(m)
+	}
+	shared := &upstreamShared{factory: handlerFactory, health: health, breaker: ollamaBreaker}
+	if cfg.Bridge.RecordFile != "" {
+		recorder, err := NewRecorder(cfg.Bridge.RecordFile)
+		if err != nil {
+			return err
+		}
+		defer recorder.Close()
+		shared.recorder = recorder
+		logger.Warn("已启用请求录制，录制文件包含完整提示词，仅用于调试", "path", cfg.Bridge.RecordFile)
+	}
+	if cfg.Features.E2EEncryption {
+		keys, err := keystore.Open(cfg.E2E.Keystore, []byte(cfg.E2E.Passphrase))
+		if err != nil {
+			return err
+		}
+		c, err := util.ParseCipher(cfg.E2E.Cipher)
+		if err != nil {
+			return err
+		}
+		shared.keys, shared.cipher = keys, c
+		logger.Info("已启用端到端加密，只接受加密的请求", "keystore", cfg.E2E.Keystore, "cipher", c)
+	}
+
+	upstreams := make([]*upstream, len(targets))
+	for i, target := range targets {
+		if upstreams[i], err = newUpstream(cfg, target, deps.WSClient, capt, status.conns[i], logger); err != nil {
+			return err
+		}
+	}
+	if len(upstreams) > 1 {
+		logger.Info("连接多个上游", "count", len(upstreams))
+	}
+	// Ollama 客户端与状态端口就绪后再连接云端
+	return runUpstreams(ctx, cfg, upstreams, shared)
+}
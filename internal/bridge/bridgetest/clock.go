@@ -0,0 +1,80 @@
+package bridgetest
+
+import (
+	"sync"
+	"time"
+
+	"ollama_dev/internal/bridge"
+)
+
+// Clock 手动推进的时钟，实现 bridge.Clock：时间只在 Advance 时前进，期间到期的 Ticker 各触发一次
+type Clock struct {
+	mu      sync.Mutex
+	now     time.Time
+	tickers []*ticker
+}
+
+// NewClock 返回从当前系统时间开始的时钟；从系统时间开始使按 Now 计算的读取超时仍然有效
+func NewClock() *Clock {
+	return &Clock{now: time.Now()}
+}
+
+func (c *Clock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *Clock) NewTicker(d time.Duration) bridge.Ticker {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	t := &ticker{clock: c, period: d, next: c.now.Add(d), c: make(chan time.Time, 1)}
+	c.tickers = append(c.tickers, t)
+	return t
+}
+
+// Advance 将时间推进 d；与 time.Ticker 相同，接收方来不及读取时多余的触发被丢弃
+func (c *Clock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+	for _, t := range c.tickers {
+		if t.period <= 0 || t.next.After(c.now) {
+			continue
+		}
+		for !t.next.After(c.now) {
+			t.next = t.next.Add(t.period)
+		}
+		select {
+		case t.c <- c.now:
+		default:
+		}
+	}
+}
+
+// Tickers 返回尚未停止的 Ticker 数量，可用于等待被测代码创建 Ticker 后再推进时间
+func (c *Clock) Tickers() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.tickers)
+}
+
+type ticker struct {
+	clock  *Clock
+	period time.Duration
+	next   time.Time
+	c      chan time.Time
+}
+
+func (t *ticker) C() <-chan time.Time { return t.c }
+
+func (t *ticker) Stop() {
+	t.clock.mu.Lock()
+	defer t.clock.mu.Unlock()
+	for i, other := range t.clock.tickers {
+		if other == t {
+			t.clock.tickers = append(t.clock.tickers[:i], t.clock.tickers[i+1:]...)
+			return
+		}
+	}
+}
@@ -0,0 +1,9 @@
+// Package bridgetest 提供测试桥接服务用的替身：内存中的 WebSocket 连接对、可配置并记录调用的 Ollama 客户端
+// 与手动推进的时钟，用法类似 net/http/httptest：
+//
+//	client, cloud := bridgetest.Pipe()
+//	s := bridge.NewServer(client, bridge.NewHandlerFactory(&bridgetest.Ollama{}, logger), nil, cfg, logger)
+//	s.SetClock(bridgetest.NewClock())
+//	go s.Run()
+//	cloud.WriteJSON(req)
+package bridgetest
@@ -0,0 +1,118 @@
+package bridgetest
+
+import (
+	"context"
+	"slices"
+	"sync"
+
+	"github.com/ollama/ollama/api"
+
+	"ollama_dev/internal/bridge"
+)
+
+// Call 一次对 Ollama 的调用
+type Call struct {
+	Method   string // Chat、ChatStream、Generate、Embed、ListModels 或 Heartbeat
+	Model    string
+	Messages []api.Message
+	Chat     bridge.ChatRequest
+	Generate bridge.GenerateRequest
+	Embed    bridge.EmbedRequest
+}
+
+// Ollama 实现 bridge.OllamaClient 并记录每次调用：设置了对应的 *Func 时按它返回；否则对话与补全回复 Reply，
+// 流式调用将 Chunks 逐个交给 onChunk，ListModels 返回 Models，Embed 为每条输入返回 Vector，出错时都返回 Err
+type Ollama struct {
+	Reply  bridge.Reply
+	Chunks []string
+	Models []bridge.ModelInfo
+	Vector []float32
+	Err    error
+
+	ChatFunc     func(ctx context.Context, req bridge.ChatRequest, onChunk func(string) error) (bridge.Reply, error)
+	GenerateFunc func(ctx context.Context, req bridge.GenerateRequest, onChunk func(string) error) (bridge.Reply, error)
+
+	mu    sync.Mutex
+	calls []Call
+}
+
+// Calls 返回到目前为止的调用
+func (o *Ollama) Calls() []Call {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return slices.Clone(o.calls)
+}
+
+func (o *Ollama) record(c Call) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.calls = append(o.calls, c)
+}
+
+func (o *Ollama) Chat(ctx context.Context, req bridge.ChatRequest) (bridge.Reply, error) {
+	o.record(Call{Method: "Chat", Model: req.Model, Messages: req.Messages, Chat: req})
+	if o.ChatFunc != nil {
+		return o.ChatFunc(ctx, req, nil)
+	}
+	return o.Reply, o.Err
+}
+
+func (o *Ollama) ChatStream(ctx context.Context, req bridge.ChatRequest, onChunk func(string) error) (bridge.Reply, error) {
+	o.record(Call{Method: "ChatStream", Model: req.Model, Messages: req.Messages, Chat: req})
+	if o.ChatFunc != nil {
+		return o.ChatFunc(ctx, req, onChunk)
+	}
+	return o.stream(onChunk)
+}
+
+func (o *Ollama) Generate(ctx context.Context, req bridge.GenerateRequest, onChunk func(string) error) (bridge.Reply, error) {
+	o.record(Call{Method: "Generate", Model: req.Model, Generate: req})
+	if o.GenerateFunc != nil {
+		return o.GenerateFunc(ctx, req, onChunk)
+	}
+	if onChunk == nil {
+		return o.Reply, o.Err
+	}
+	return o.stream(onChunk)
+}
+
+func (o *Ollama) Embed(ctx context.Context, req bridge.EmbedRequest) (bridge.Embeddings, error) {
+	o.record(Call{Method: "Embed", Model: req.Model, Embed: req})
+	if o.Err != nil {
+		return bridge.Embeddings{}, o.Err
+	}
+	var e bridge.Embeddings
+	for range req.Input {
+		e.Vectors = append(e.Vectors, slices.Clone(o.Vector))
+	}
+	return e, nil
+}
+
+func (o *Ollama) ListModels(ctx context.Context) ([]bridge.ModelInfo, error) {
+	o.record(Call{Method: "ListModels"})
+	return o.Models, o.Err
+}
+
+func (o *Ollama) Heartbeat(ctx context.Context) error {
+	o.record(Call{Method: "Heartbeat"})
+	return o.Err
+}
+
+// stream 逐个发送 Chunks，没有设置 Reply.Content 时回复它们的拼接
+func (o *Ollama) stream(onChunk func(string) error) (bridge.Reply, error) {
+	if o.Err != nil {
+		return bridge.Reply{}, o.Err
+	}
+	reply := o.Reply
+	content := ""
+	for _, c := range o.Chunks {
+		if err := onChunk(c); err != nil {
+			return bridge.Reply{}, err
+		}
+		content += c
+	}
+	if reply.Content == "" {
+		reply.Content = content
+	}
+	return reply, nil
+}
@@ -0,0 +1,104 @@
+package bridgetest
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// Conn 内存连接对的一端，实现 bridge.WSClient；一端写入的帧按顺序由另一端读出
+type Conn struct {
+	in   <-chan []byte
+	out  chan<- []byte
+	done chan struct{} // 两端共用，任一端 Close 后关闭
+
+	closeOnce *sync.Once
+	mu        sync.Mutex
+	deadline  time.Time
+}
+
+// Pipe 返回连接在一起的两端，通常一端交给 bridge.Server，另一端在测试中扮演云端
+func Pipe() (*Conn, *Conn) {
+	a, b := make(chan []byte, 64), make(chan []byte, 64)
+	done, once := make(chan struct{}), new(sync.Once)
+	return &Conn{in: a, out: b, done: done, closeOnce: once},
+		&Conn{in: b, out: a, done: done, closeOnce: once}
+}
+
+func (c *Conn) Connect(url string) error { return nil }
+
+// Conn 内存连接没有底层的 WebSocket 连接
+func (c *Conn) Conn() *websocket.Conn { return nil }
+
+// ReadMessage 等待对端写入的下一帧；连接关闭后返回 io.EOF，超过 SetReadDeadline 设置的时间返回 os.ErrDeadlineExceeded
+func (c *Conn) ReadMessage() ([]byte, error) {
+	c.mu.Lock()
+	deadline := c.deadline
+	c.mu.Unlock()
+	var timeout <-chan time.Time
+	if !deadline.IsZero() {
+		timer := time.NewTimer(time.Until(deadline))
+		defer timer.Stop()
+		timeout = timer.C
+	}
+	select {
+	case frame := <-c.in:
+		return frame, nil
+	case <-c.done:
+		return nil, io.EOF
+	case <-timeout:
+		return nil, os.ErrDeadlineExceeded
+	}
+}
+
+// WriteMessage 复制 message 后交给对端，连接关闭后返回 io.ErrClosedPipe
+func (c *Conn) WriteMessage(message []byte) error {
+	select {
+	case <-c.done:
+		return io.ErrClosedPipe
+	default:
+	}
+	select {
+	case c.out <- bytes.Clone(message):
+		return nil
+	case <-c.done:
+		return io.ErrClosedPipe
+	}
+}
+
+// SetReadDeadline 设置读取的截止时间，按系统时间计算；零值表示不超时
+func (c *Conn) SetReadDeadline(t time.Time) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.deadline = t
+	return nil
+}
+
+// Close 同时关闭两端
+func (c *Conn) Close() error {
+	c.closeOnce.Do(func() { close(c.done) })
+	return nil
+}
+
+// WriteJSON 将 v 编码为一帧写入
+func (c *Conn) WriteJSON(v any) error {
+	frame, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return c.WriteMessage(frame)
+}
+
+// ReadJSON 读取一帧并解码到 v
+func (c *Conn) ReadJSON(v any) error {
+	frame, err := c.ReadMessage()
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(frame, v)
+}
@@ -0,0 +1,148 @@
+package bridge
+
+import (
+	"context"
+	"expvar"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"ollama_dev/internal/apperr"
+	"ollama_dev/internal/config"
+)
+
+// bulkheadStats 各模型进行中的生成数与被拒绝的请求数，发布在 /debug/vars 的 bulkhead 字段
+var bulkheadStats = expvar.NewMap("bulkhead")
+
+// bulkhead 按模型限制同时进行的生成，超出上限时最多排队 wait，仍未轮到则返回 model_busy
+type bulkhead struct {
+	def    int
+	limits map[string]int
+	wait   time.Duration
+
+	mu    sync.Mutex
+	slots map[string]chan struct{} // 模型 -> 信号量
+}
+
+// newBulkhead 按配置创建，未设置任何上限时返回 nil
+func newBulkhead(cfg config.ModelConcurrencyConfig) *bulkhead {
+	if cfg.Default <= 0 && len(cfg.Models) == 0 {
+		return nil
+	}
+	b := &bulkhead{def: cfg.Default, limits: map[string]int{}, wait: cfg.QueueTimeout, slots: map[string]chan struct{}{}}
+	for model, n := range cfg.Models {
+		b.limits[normalizeModel(model)] = n
+	}
+	return b
+}
+
+// normalizeModel 为未写标签的模型名补上 :latest，与 Ollama 的解析一致
+func normalizeModel(model string) string {
+	if i := strings.LastIndex(model, "/"); !strings.Contains(model[i+1:], ":") {
+		return model + ":latest"
+	}
+	return model
+}
+
+// semaphore 返回模型的信号量，不限并发时返回 nil
+func (b *bulkhead) semaphore(model string) chan struct{} {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if sem, ok := b.slots[model]; ok {
+		return sem
+	}
+	n, ok := b.limits[model]
+	if !ok {
+		n = b.def
+	}
+	var sem chan struct{}
+	if n > 0 {
+		sem = make(chan struct{}, n)
+	}
+	b.slots[model] = sem
+	return sem
+}
+
+// acquire 占用模型的一个并发名额，返回的 release 在生成结束后调用
+func (b *bulkhead) acquire(model string) (release func(), err error) {
+	model = normalizeModel(model)
+	sem := b.semaphore(model)
+	if sem == nil {
+		return func() {}, nil
+	}
+	select {
+	case sem <- struct{}{}:
+	default:
+		if b.wait <= 0 {
+			return nil, b.busy(model, cap(sem))
+		}
+		timer := time.NewTimer(b.wait)
+		defer timer.Stop()
+		select {
+		case sem <- struct{}{}:
+		case <-timer.C:
+			return nil, b.busy(model, cap(sem))
+		}
+	}
+	bulkheadStats.Add(model, 1)
+	return func() {
+		<-sem
+		bulkheadStats.Add(model, -1)
+	}, nil
+}
+
+func (b *bulkhead) busy(model string, limit int) error {
+	bulkheadStats.Add("rejected", 1)
+	return apperr.New(apperr.Backend, apperr.CodeModelBusy, fmt.Sprintf("模型 %s 同时进行的对话已达上限 (%d)，稍后重试", model, limit))
+}
+
+// bulkheadClient 为 Chat、ChatStream、Generate 与 Embed 加上按模型的并发限制
+type bulkheadClient struct {
+	OllamaClient
+	bulkhead *bulkhead
+}
+
+// withBulkhead 返回限制并发的客户端，b 为 nil 时原样返回
+func withBulkhead(c OllamaClient, b *bulkhead) OllamaClient {
+	if b == nil {
+		return c
+	}
+	return &bulkheadClient{OllamaClient: c, bulkhead: b}
+}
+
+func (c *bulkheadClient) Chat(ctx context.Context, req ChatRequest) (Reply, error) {
+	release, err := c.bulkhead.acquire(req.Model)
+	if err != nil {
+		return Reply{}, err
+	}
+	defer release()
+	return c.OllamaClient.Chat(ctx, req)
+}
+
+func (c *bulkheadClient) ChatStream(ctx context.Context, req ChatRequest, onChunk func(string) error) (Reply, error) {
+	release, err := c.bulkhead.acquire(req.Model)
+	if err != nil {
+		return Reply{}, err
+	}
+	defer release()
+	return c.OllamaClient.ChatStream(ctx, req, onChunk)
+}
+
+func (c *bulkheadClient) Generate(ctx context.Context, req GenerateRequest, onChunk func(string) error) (Reply, error) {
+	release, err := c.bulkhead.acquire(req.Model)
+	if err != nil {
+		return Reply{}, err
+	}
+	defer release()
+	return c.OllamaClient.Generate(ctx, req, onChunk)
+}
+
+func (c *bulkheadClient) Embed(ctx context.Context, req EmbedRequest) (Embeddings, error) {
+	release, err := c.bulkhead.acquire(req.Model)
+	if err != nil {
+		return Embeddings{}, err
+	}
+	defer release()
+	return c.OllamaClient.Embed(ctx, req)
+}
@@ -0,0 +1,72 @@
+package bridge
+
+import (
+	"testing"
+	"time"
+
+	"ollama_dev/internal/apperr"
+	"ollama_dev/internal/config"
+)
+
+func TestBulkheadRejectsExcessPerModel(t *testing.T) {
+	b := newBulkhead(config.ModelConcurrencyConfig{Default: 2, Models: map[string]int{"llama3:70b": 1}})
+
+	release, err := b.acquire("llama3:70b")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := b.acquire("llama3:70b"); apperr.CodeOf(err) != apperr.CodeModelBusy {
+		t.Fatalf("expected model_busy, got %v", err)
+	}
+	// 其他模型使用默认上限，不受影响；未写标签的名称视为 :latest
+	r1, err1 := b.acquire("qwen2")
+	r2, err2 := b.acquire("qwen2:latest")
+	if err1 != nil || err2 != nil {
+		t.Fatalf("default limit should allow 2: %v %v", err1, err2)
+	}
+	if _, err := b.acquire("qwen2"); apperr.CodeOf(err) != apperr.CodeModelBusy {
+		t.Errorf("expected qwen2 and qwen2:latest to share a limit, got %v", err)
+	}
+	r1()
+	r2()
+
+	release()
+	if r, err := b.acquire("llama3:70b"); err != nil {
+		t.Errorf("slot should be free after release: %v", err)
+	} else {
+		r()
+	}
+}
+
+func TestBulkheadQueuesUntilTimeout(t *testing.T) {
+	b := newBulkhead(config.ModelConcurrencyConfig{Default: 1, QueueTimeout: time.Second})
+	release, _ := b.acquire("llama3")
+
+	// 排队中的请求在名额释放后继续
+	got := make(chan error, 1)
+	go func() {
+		r, err := b.acquire("llama3")
+		if err == nil {
+			r()
+		}
+		got <- err
+	}()
+	time.Sleep(20 * time.Millisecond)
+	release()
+	if err := <-got; err != nil {
+		t.Fatalf("queued request should get the slot, got %v", err)
+	}
+
+	b.wait = 10 * time.Millisecond
+	release, _ = b.acquire("llama3")
+	defer release()
+	if _, err := b.acquire("llama3"); apperr.CodeOf(err) != apperr.CodeModelBusy {
+		t.Errorf("expected model_busy after queue timeout, got %v", err)
+	}
+}
+
+func TestBulkheadDisabledByDefault(t *testing.T) {
+	if newBulkhead(config.Default().Bridge.ModelConcurrency) != nil {
+		t.Error("default config should not limit model concurrency")
+	}
+}
@@ -0,0 +1,137 @@
+package bridge
+
+import (
+	"bytes"
+	"encoding/gob"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/patrickmn/go-cache"
+
+	"ollama_dev/internal/config"
+)
+
+// Cache 接口定义缓存操作；d 为 0 时使用默认过期时间，为 -1 时不过期
+type Cache interface {
+	Get(key string) (interface{}, bool)
+	Set(key string, value interface{}, d time.Duration)
+	Delete(key string)
+	// Flush 清空当前命名空间下的全部条目
+	Flush()
+}
+
+// MemoryCache 实现缓存
+type MemoryCache struct {
+	cache *cache.Cache
+}
+
+func NewMemoryCache(ttl, cleanupInterval time.Duration) *MemoryCache {
+	return &MemoryCache{
+		cache: cache.New(ttl, cleanupInterval),
+	}
+}
+
+func (m *MemoryCache) Get(key string) (interface{}, bool) {
+	return m.cache.Get(key)
+}
+
+func (m *MemoryCache) Set(key string, value interface{}, d time.Duration) {
+	m.cache.Set(key, value, d)
+}
+
+func (m *MemoryCache) Delete(key string) {
+	m.cache.Delete(key)
+}
+
+func (m *MemoryCache) Flush() {
+	m.cache.Flush()
+}
+
+// CacheFactory 按配置创建缓存后端
+type CacheFactory func(cfg config.CacheConfig) (Cache, error)
+
+var (
+	cachesMu sync.RWMutex
+	caches   = map[string]CacheFactory{
+		"memory": func(cfg config.CacheConfig) (Cache, error) {
+			return NewMemoryCache(cfg.TTL, cfg.CleanupInterval), nil
+		},
+		"redis": func(cfg config.CacheConfig) (Cache, error) { return NewRedisCache(cfg) },
+		"bolt":  func(cfg config.CacheConfig) (Cache, error) { return OpenBoltCache(cfg) },
+	}
+)
+
+// RegisterCache 注册缓存后端，之后可通过 cache.backend 选择；通常在后端所在包的 init 中调用，重复注册时 panic
+func RegisterCache(name string, f CacheFactory) {
+	cachesMu.Lock()
+	defer cachesMu.Unlock()
+	if _, dup := caches[name]; dup {
+		panic(fmt.Sprintf("bridge: 缓存后端 %s 重复注册", name))
+	}
+	caches[name] = f
+}
+
+// NewCache 创建 cache.backend 指定的缓存，后端实现 io.Closer 时由调用方关闭
+func NewCache(cfg config.CacheConfig) (Cache, error) {
+	cachesMu.RLock()
+	f, ok := caches[cfg.Backend]
+	names := make([]string, 0, len(caches))
+	for name := range caches {
+		names = append(names, name)
+	}
+	cachesMu.RUnlock()
+	if !ok {
+		slices.Sort(names)
+		return nil, fmt.Errorf("未知的缓存后端 %q，可选 %v", cfg.Backend, names)
+	}
+	return f(cfg)
+}
+
+// RegisterCacheType 登记需要保存到 redis、bolt 等持久化后端的值类型，Get 时还原为同一类型
+func RegisterCacheType(value any) {
+	gob.Register(value)
+}
+
+func init() {
+	RegisterCacheType([]ModelInfo{})
+}
+
+// cacheEntry 持久化后端保存的条目
+type cacheEntry struct {
+	Value   any
+	Expires time.Time // 零值表示不过期
+}
+
+// expiry 按 go-cache 的约定计算过期时间：d 为 0 使用 ttl，为负数不过期
+func expiry(d, ttl time.Duration) time.Time {
+	if d == 0 {
+		d = ttl
+	}
+	if d < 0 {
+		return time.Time{}
+	}
+	return time.Now().Add(d)
+}
+
+func encodeEntry(e cacheEntry) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(&e); err != nil {
+		return nil, fmt.Errorf("编码缓存条目失败: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+func decodeEntry(data []byte) (cacheEntry, error) {
+	var e cacheEntry
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&e); err != nil {
+		return e, fmt.Errorf("解码缓存条目失败: %w", err)
+	}
+	return e, nil
+}
+
+// cacheError 记录持久化缓存后端的错误，发布在 /debug/vars 的 ollama 字段；出错时按未命中处理
+func cacheError() {
+	ollamaStats.Add("cache_errors", 1)
+}
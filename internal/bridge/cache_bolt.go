@@ -0,0 +1,101 @@
+package bridge
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+
+	"ollama_dev/internal/config"
+)
+
+// BoltCache 保存到 bbolt 文件的缓存，进程重启后保留未过期的条目；每个命名空间一个 bucket
+type BoltCache struct {
+	db     *bolt.DB
+	bucket []byte
+	ttl    time.Duration
+}
+
+// OpenBoltCache 打开或创建 cache.bolt.path，过期条目在读取时删除
+func OpenBoltCache(cfg config.CacheConfig) (*BoltCache, error) {
+	path := cfg.Bolt.Path
+	if dir := filepath.Dir(path); dir != "." {
+		if err := os.MkdirAll(dir, 0o700); err != nil {
+			return nil, fmt.Errorf("创建缓存目录失败: %w", err)
+		}
+	}
+	db, err := bolt.Open(path, 0o600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("打开缓存文件失败: %w", err)
+	}
+	return &BoltCache{db: db, bucket: []byte("cache:" + cfg.Namespace), ttl: cfg.TTL}, nil
+}
+
+func (c *BoltCache) Get(key string) (interface{}, bool) {
+	var data []byte
+	err := c.db.View(func(tx *bolt.Tx) error {
+		if b := tx.Bucket(c.bucket); b != nil {
+			data = append([]byte(nil), b.Get([]byte(key))...)
+		}
+		return nil
+	})
+	if err != nil || len(data) == 0 {
+		return nil, false
+	}
+	e, err := decodeEntry(data)
+	if err != nil {
+		cacheError()
+		return nil, false
+	}
+	if !e.Expires.IsZero() && time.Now().After(e.Expires) {
+		c.Delete(key)
+		return nil, false
+	}
+	return e.Value, true
+}
+
+func (c *BoltCache) Set(key string, value interface{}, d time.Duration) {
+	data, err := encodeEntry(cacheEntry{Value: value, Expires: expiry(d, c.ttl)})
+	if err == nil {
+		err = c.db.Update(func(tx *bolt.Tx) error {
+			b, err := tx.CreateBucketIfNotExists(c.bucket)
+			if err != nil {
+				return err
+			}
+			return b.Put([]byte(key), data)
+		})
+	}
+	if err != nil {
+		cacheError()
+	}
+}
+
+func (c *BoltCache) Delete(key string) {
+	err := c.db.Update(func(tx *bolt.Tx) error {
+		if b := tx.Bucket(c.bucket); b != nil {
+			return b.Delete([]byte(key))
+		}
+		return nil
+	})
+	if err != nil {
+		cacheError()
+	}
+}
+
+func (c *BoltCache) Flush() {
+	err := c.db.Update(func(tx *bolt.Tx) error {
+		if tx.Bucket(c.bucket) == nil {
+			return nil
+		}
+		return tx.DeleteBucket(c.bucket)
+	})
+	if err != nil {
+		cacheError()
+	}
+}
+
+func (c *BoltCache) Close() error {
+	return c.db.Close()
+}
@@ -0,0 +1,244 @@
+package bridge
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+
+	"ollama_dev/internal/config"
+)
+
+// RedisCache 保存在 Redis 中的缓存，多个 bridge 可以共享；键以 "<namespace>:" 为前缀，过期由 Redis 处理。
+// 只使用 GET、SET PX、DEL 与 SCAN，通过一条连接顺序发送，出错时断开并在下次调用时重连
+type RedisCache struct {
+	cfg    config.CacheRedisConfig
+	prefix string
+	ttl    time.Duration
+
+	mu   sync.Mutex
+	conn net.Conn
+	r    *bufio.Reader
+}
+
+// NewRedisCache 连接 cache.redis.addr，连接或认证失败时返回错误
+func NewRedisCache(cfg config.CacheConfig) (*RedisCache, error) {
+	c := &RedisCache{cfg: cfg.Redis, prefix: cfg.Namespace + ":", ttl: cfg.TTL}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.connect(); err != nil {
+		return nil, fmt.Errorf("连接 Redis 缓存失败: %w", err)
+	}
+	return c, nil
+}
+
+func (c *RedisCache) Get(key string) (interface{}, bool) {
+	reply, err := c.do("GET", c.prefix+key)
+	if err != nil {
+		cacheError()
+		return nil, false
+	}
+	data, ok := reply.([]byte)
+	if !ok {
+		return nil, false
+	}
+	e, err := decodeEntry(data)
+	if err != nil {
+		cacheError()
+		return nil, false
+	}
+	return e.Value, true
+}
+
+func (c *RedisCache) Set(key string, value interface{}, d time.Duration) {
+	exp := expiry(d, c.ttl)
+	data, err := encodeEntry(cacheEntry{Value: value, Expires: exp})
+	if err == nil && int64(len(data)) > c.cfg.MaxEntrySize {
+		// 读取时会拒绝超过上限的回复，不写入
+		err = fmt.Errorf("缓存条目 %d 字节，超过 cache.redis.max_entry_size", len(data))
+	}
+	if err == nil {
+		args := []string{"SET", c.prefix + key, string(data)}
+		if !exp.IsZero() {
+			args = append(args, "PX", strconv.FormatInt(max(time.Until(exp).Milliseconds(), 1), 10))
+		}
+		_, err = c.do(args...)
+	}
+	if err != nil {
+		cacheError()
+	}
+}
+
+func (c *RedisCache) Delete(key string) {
+	if _, err := c.do("DEL", c.prefix+key); err != nil {
+		cacheError()
+	}
+}
+
+// Flush 以 SCAN 找出命名空间下的键并删除，不影响其他命名空间
+func (c *RedisCache) Flush() {
+	cursor := "0"
+	for {
+		reply, err := c.do("SCAN", cursor, "MATCH", c.prefix+"*", "COUNT", "100")
+		page, ok := reply.([]any)
+		if err != nil || !ok || len(page) != 2 {
+			cacheError()
+			return
+		}
+		next, _ := page[0].([]byte)
+		keys, _ := page[1].([]any)
+		if len(keys) > 0 {
+			args := []string{"DEL"}
+			for _, k := range keys {
+				if b, ok := k.([]byte); ok {
+					args = append(args, string(b))
+				}
+			}
+			if _, err := c.do(args...); err != nil {
+				cacheError()
+				return
+			}
+		}
+		cursor = string(next)
+		if cursor == "0" || cursor == "" {
+			return
+		}
+	}
+}
+
+func (c *RedisCache) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.conn == nil {
+		return nil
+	}
+	err := c.conn.Close()
+	c.conn = nil
+	return err
+}
+
+// connect 建立连接并按配置认证、选择数据库，调用方持有 mu
+func (c *RedisCache) connect() error {
+	conn, err := net.DialTimeout("tcp", c.cfg.Addr, c.cfg.Timeout)
+	if err != nil {
+		return err
+	}
+	c.conn, c.r = conn, bufio.NewReader(conn)
+	if c.cfg.Password != "" {
+		if _, err := c.roundTrip("AUTH", c.cfg.Password); err != nil {
+			c.drop()
+			return err
+		}
+	}
+	if c.cfg.DB != 0 {
+		if _, err := c.roundTrip("SELECT", strconv.Itoa(c.cfg.DB)); err != nil {
+			c.drop()
+			return err
+		}
+	}
+	return nil
+}
+
+// do 发送一条命令并读取回复，连接断开时先重连
+func (c *RedisCache) do(args ...string) (any, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.conn == nil {
+		if err := c.connect(); err != nil {
+			return nil, err
+		}
+	}
+	reply, err := c.roundTrip(args...)
+	var redisErr redisError
+	if err != nil && !errors.As(err, &redisErr) {
+		// 网络错误后连接状态未知，丢弃连接
+		c.drop()
+	}
+	return reply, err
+}
+
+func (c *RedisCache) drop() {
+	c.conn.Close()
+	c.conn = nil
+}
+
+func (c *RedisCache) roundTrip(args ...string) (any, error) {
+	c.conn.SetDeadline(time.Now().Add(c.cfg.Timeout))
+	buf := make([]byte, 0, 64)
+	buf = append(buf, '*')
+	buf = strconv.AppendInt(buf, int64(len(args)), 10)
+	buf = append(buf, '\r', '\n')
+	for _, a := range args {
+		buf = append(buf, '$')
+		buf = strconv.AppendInt(buf, int64(len(a)), 10)
+		buf = append(buf, '\r', '\n')
+		buf = append(buf, a...)
+		buf = append(buf, '\r', '\n')
+	}
+	if _, err := c.conn.Write(buf); err != nil {
+		return nil, err
+	}
+	return readRESP(c.r, c.cfg.MaxEntrySize)
+}
+
+// redisError Redis 返回的错误回复，连接仍然可用
+type redisError string
+
+func (e redisError) Error() string { return "redis: " + string(e) }
+
+// readRESP 读取一条 RESP2 回复：简单字符串与批量字符串为 []byte，整数为 int64，数组为 []any，空值为 nil；
+// 长度在分配前校验，批量字符串不超过 maxBulk 字节，数组按实际读到的元素增长，不按声明的长度预先分配
+func readRESP(r *bufio.Reader, maxBulk int64) (any, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	if len(line) < 3 || line[len(line)-2] != '\r' {
+		return nil, fmt.Errorf("无效的 Redis 回复 %q", line)
+	}
+	kind, body := line[0], line[1:len(line)-2]
+	switch kind {
+	case '+':
+		return []byte(body), nil
+	case '-':
+		return nil, redisError(body)
+	case ':':
+		return strconv.ParseInt(body, 10, 64)
+	case '$':
+		n, err := strconv.ParseInt(body, 10, 64)
+		if err != nil || n < -1 || n > maxBulk {
+			return nil, fmt.Errorf("无效的 Redis 批量字符串长度 %q", body)
+		}
+		if n == -1 {
+			return nil, nil
+		}
+		data := make([]byte, n+2)
+		if _, err := io.ReadFull(r, data); err != nil {
+			return nil, err
+		}
+		return data[:n], nil
+	case '*':
+		n, err := strconv.ParseInt(body, 10, 64)
+		if err != nil || n < -1 {
+			return nil, fmt.Errorf("无效的 Redis 数组长度 %q", body)
+		}
+		if n == -1 {
+			return nil, nil
+		}
+		var items []any
+		for range n {
+			item, err := readRESP(r, maxBulk)
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, item)
+		}
+		return items, nil
+	default:
+		return nil, fmt.Errorf("无效的 Redis 回复 %q", line)
+	}
+}
@@ -0,0 +1,228 @@
+package bridge
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"ollama_dev/internal/config"
+)
+
+func TestBoltCachePersistsAndExpires(t *testing.T) {
+	cfg := config.Default().Cache
+	cfg.Bolt.Path = filepath.Join(t.TempDir(), "cache.db")
+	c, err := OpenBoltCache(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	models := []ModelInfo{{Name: "llama3", Digest: "abc"}}
+	c.Set("models", models, 0)
+	c.Set("short", "x", time.Millisecond)
+	c.Close()
+
+	// 重新打开后仍能读出原类型的值
+	if c, err = OpenBoltCache(cfg); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	got, ok := c.Get("models")
+	if m, typed := got.([]ModelInfo); !ok || !typed || len(m) != 1 || m[0].Digest != "abc" {
+		t.Fatalf("unexpected cached value %#v", got)
+	}
+	time.Sleep(5 * time.Millisecond)
+	if _, ok := c.Get("short"); ok {
+		t.Error("expired entry returned")
+	}
+
+	// 命名空间相互隔离，Flush 只清空自己的
+	other := &BoltCache{db: c.db, bucket: []byte("cache:other"), ttl: cfg.TTL}
+	other.Set("models", models, 0)
+	c.Flush()
+	if _, ok := c.Get("models"); ok {
+		t.Error("entry survived Flush")
+	}
+	if _, ok := other.Get("models"); !ok {
+		t.Error("Flush removed another namespace")
+	}
+	other.Delete("models")
+	if _, ok := other.Get("models"); ok {
+		t.Error("entry survived Delete")
+	}
+}
+
+// fakeRedis 支持 AUTH、GET、SET、DEL 与 SCAN 的最小 Redis 服务器，忽略过期时间
+type fakeRedis struct {
+	mu   sync.Mutex
+	data map[string]string
+}
+
+func startFakeRedis(t *testing.T) string {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	s := &fakeRedis{data: map[string]string{}}
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn)
+		}
+	}()
+	return ln.Addr().String()
+}
+
+func (s *fakeRedis) serve(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	for {
+		reply, err := readRESP(r, 1<<20)
+		if err != nil {
+			return
+		}
+		var args []string
+		for _, a := range reply.([]any) {
+			args = append(args, string(a.([]byte)))
+		}
+		s.mu.Lock()
+		switch strings.ToUpper(args[0]) {
+		case "AUTH":
+			if args[1] == "secret" {
+				fmt.Fprint(conn, "+OK\r\n")
+			} else {
+				fmt.Fprint(conn, "-WRONGPASS invalid password\r\n")
+			}
+		case "SET":
+			s.data[args[1]] = args[2]
+			fmt.Fprint(conn, "+OK\r\n")
+		case "GET":
+			if v, ok := s.data[args[1]]; ok {
+				fmt.Fprintf(conn, "$%d\r\n%s\r\n", len(v), v)
+			} else {
+				fmt.Fprint(conn, "$-1\r\n")
+			}
+		case "DEL":
+			for _, k := range args[1:] {
+				delete(s.data, k)
+			}
+			fmt.Fprintf(conn, ":%d\r\n", len(args)-1)
+		case "SCAN":
+			prefix := strings.TrimSuffix(args[3], "*")
+			var keys []string
+			for k := range s.data {
+				if strings.HasPrefix(k, prefix) {
+					keys = append(keys, k)
+				}
+			}
+			fmt.Fprintf(conn, "*2\r\n$1\r\n0\r\n*%d\r\n", len(keys))
+			for _, k := range keys {
+				fmt.Fprintf(conn, "$%d\r\n%s\r\n", len(k), k)
+			}
+		}
+		s.mu.Unlock()
+	}
+}
+
+func TestRedisCache(t *testing.T) {
+	cfg := config.Default().Cache
+	cfg.Redis.Addr = startFakeRedis(t)
+	cfg.Redis.Password = "wrong"
+	if _, err := NewRedisCache(cfg); err == nil {
+		t.Fatal("expected auth failure")
+	}
+	cfg.Redis.Password = "secret"
+	c, err := NewRedisCache(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	c.Set("models", []ModelInfo{{Name: "llama3"}}, 0)
+	got, ok := c.Get("models")
+	if m, typed := got.([]ModelInfo); !ok || !typed || m[0].Name != "llama3" {
+		t.Fatalf("unexpected cached value %#v", got)
+	}
+
+	// 共享同一 Redis 的另一命名空间不受 Flush 影响
+	cfg.Namespace = "other"
+	other, err := NewRedisCache(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer other.Close()
+	other.Set("models", "x", 0)
+	c.Flush()
+	if _, ok := c.Get("models"); ok {
+		t.Error("entry survived Flush")
+	}
+	if _, ok := other.Get("models"); !ok {
+		t.Error("Flush removed another namespace")
+	}
+
+	// 连接断开后下次调用时重连
+	c.conn.Close()
+	c.Get("models")
+	c.Set("k", "v", 0)
+	if v, ok := c.Get("k"); !ok || v != "v" {
+		t.Errorf("expected reconnect, got %v %v", v, ok)
+	}
+}
+
+func TestReadRESPRejectsBadLengths(t *testing.T) {
+	for _, c := range []struct {
+		reply string
+		ok    bool
+	}{
+		{"$5\r\nhello\r\n", true},
+		{"$-1\r\n", true},
+		{"*-1\r\n", true},
+		{"$-2\r\n", false},
+		{"$17\r\n", false}, // 超过上限，不分配
+		{"$9223372036854775807\r\n", false},
+		{"*-2\r\n", false},
+		{"*9223372036854775807\r\n$1\r\na\r\n", false}, // 按实际读到的元素增长，不按声明的长度分配
+	} {
+		_, err := readRESP(bufio.NewReader(strings.NewReader(c.reply)), 16)
+		if (err == nil) != c.ok {
+			t.Errorf("%q: unexpected error %v", c.reply, err)
+		}
+	}
+
+	// 超过上限的条目不写入
+	cfg := config.Default().Cache
+	cfg.Redis.Addr = startFakeRedis(t)
+	cfg.Redis.Password = "secret"
+	cfg.Redis.MaxEntrySize = 1024
+	c, err := NewRedisCache(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	c.Set("big", strings.Repeat("x", 2048), 0)
+	if _, ok := c.Get("big"); ok {
+		t.Error("expected an oversized entry to be skipped")
+	}
+	c.Set("small", "x", 0)
+	if v, ok := c.Get("small"); !ok || v != "x" {
+		t.Errorf("expected the small entry, got %v %v", v, ok)
+	}
+}
+
+func TestNewCacheUnknownBackend(t *testing.T) {
+	cfg := config.Default().Cache
+	if c, err := NewCache(cfg); err != nil || c == nil {
+		t.Fatalf("default backend: %v", err)
+	}
+	cfg.Backend = "memcached"
+	if _, err := NewCache(cfg); err == nil || !strings.Contains(err.Error(), "redis") {
+		t.Errorf("expected error listing backends, got %v", err)
+	}
+}
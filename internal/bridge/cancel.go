@@ -0,0 +1,102 @@
+package bridge
+
+import (
+	"context"
+	"sync"
+
+	"ollama_dev/internal/apperr"
+)
+
+// ActionCancel 云端取消进行中请求的动作，request_id 与要取消的请求相同
+const ActionCancel = "cancel"
+
+// errCancelled 请求被云端取消，作为 context 的 cause 传给处理器
+var errCancelled = apperr.New(apperr.Timeout, apperr.CodeCancelled, "请求已被取消")
+
+// inflightRegistry 已接收、尚未回复的请求，按 request_id 查找以便取消，关闭时等待其全部结束
+type inflightRegistry struct {
+	mu      sync.Mutex
+	cancels map[string]context.CancelCauseFunc
+	n       int           // 进行中的请求数，含没有 request_id 的
+	idle    chan struct{} // n 降为 0 时关闭
+}
+
+func newInflightRegistry() *inflightRegistry {
+	idle := make(chan struct{})
+	close(idle)
+	return &inflightRegistry{cancels: make(map[string]context.CancelCauseFunc), idle: idle}
+}
+
+// begin 登记请求并返回可被取消的 context，排队中的请求同样可以取消；
+// 返回的函数在请求结束时调用
+func (r *inflightRegistry) begin(id string) (context.Context, func()) {
+	ctx, cancel := context.WithCancelCause(context.Background())
+	r.mu.Lock()
+	if r.n == 0 {
+		r.idle = make(chan struct{})
+	}
+	r.n++
+	if id != "" {
+		r.cancels[id] = cancel
+	}
+	r.mu.Unlock()
+	return ctx, func() {
+		r.mu.Lock()
+		if id != "" {
+			delete(r.cancels, id)
+		}
+		if r.n--; r.n == 0 {
+			close(r.idle)
+		}
+		r.mu.Unlock()
+		cancel(nil)
+	}
+}
+
+// wait 等待进行中的请求全部结束，ctx 先结束时返回 false
+func (r *inflightRegistry) wait(ctx context.Context) bool {
+	r.mu.Lock()
+	idle := r.idle
+	r.mu.Unlock()
+	select {
+	case <-idle:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// len 返回进行中的请求数
+func (r *inflightRegistry) len() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.n
+}
+
+// cancelAll 以 cause 取消全部带 request_id 的请求
+func (r *inflightRegistry) cancelAll(cause error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, cancel := range r.cancels {
+		cancel(cause)
+	}
+}
+
+// cancel 取消 request_id 对应的请求，请求不存在或已结束时返回 false
+func (r *inflightRegistry) cancel(id string) bool {
+	r.mu.Lock()
+	cancel, ok := r.cancels[id]
+	r.mu.Unlock()
+	if ok {
+		cancel(errCancelled)
+	}
+	return ok
+}
+
+// cancelled 请求被取消时以 errCancelled (或关闭时的 errShuttingDown) 代替处理器返回的错误（通常是包装后的 context.Canceled）
+func cancelled(ctx context.Context, err error) error {
+	if cause := context.Cause(ctx); err != nil && (cause == errCancelled || cause == errShuttingDown) {
+		return cause
+	}
+	return err
+}
@@ -0,0 +1,226 @@
+package bridge
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"ollama_dev/internal/apperr"
+	"ollama_dev/internal/config"
+)
+
+// ActionPart 超过 chunking.max_frame_size 的消息被拆分为多个该动作的帧，接收方重组后按原消息处理
+const ActionPart = "part"
+
+// partOverhead 分片帧中除数据外的字段预留的字节数
+const partOverhead = 256
+
+// Part 分片信息，Data 为原消息的一段，JSON 中以 base64 编码
+type Part struct {
+	ID    string `json:"id"`    // 同一消息的分片共用
+	Seq   int    `json:"seq"`   // 从 0 开始
+	Total int    `json:"total"` // 分片总数
+	Data  []byte `json:"data"`
+}
+
+// partFrame 分片帧，type 与 request_id 沿用原消息以便中间节点按原规则转发
+type partFrame struct {
+	V         int             `json:"v"`
+	Type      string          `json:"type"`
+	Action    string          `json:"action"`
+	RequestID string          `json:"request_id,omitempty"`
+	TenantID  string          `json:"tenant_id,omitempty"`
+	Usage     json.RawMessage `json:"usage,omitempty"` // 仅第一个分片携带，使中间节点无需重组即可统计用量
+	Part      Part            `json:"part"`
+}
+
+// SplitFrame 按 maxFrameSize 拆分消息，未超过限制或 maxFrameSize 不大于 0 时原样返回
+func SplitFrame(frame []byte, maxFrameSize int) ([][]byte, error) {
+	if maxFrameSize <= 0 || len(frame) <= maxFrameSize {
+		return [][]byte{frame}, nil
+	}
+	// base64 编码后体积增大 4/3
+	partSize := (maxFrameSize - partOverhead) * 3 / 4
+	if partSize <= 0 {
+		return nil, fmt.Errorf("max_frame_size 过小: %d", maxFrameSize)
+	}
+
+	var head struct {
+		Type      string          `json:"type"`
+		RequestID string          `json:"request_id"`
+		TenantID  string          `json:"tenant_id"`
+		Status    string          `json:"status"`
+		Usage     json.RawMessage `json:"usage"`
+	}
+	_ = json.Unmarshal(frame, &head)
+
+	id := uuid.NewString()
+	total := (len(frame) + partSize - 1) / partSize
+	frames := make([][]byte, 0, total)
+	for seq := range total {
+		data := frame[seq*partSize : min((seq+1)*partSize, len(frame))]
+		var u json.RawMessage
+		if seq == 0 && head.Status == StatusDone {
+			u = head.Usage
+		}
+		part, err := json.Marshal(partFrame{
+			V:         ProtocolVersion,
+			Type:      head.Type,
+			Action:    ActionPart,
+			RequestID: head.RequestID,
+			TenantID:  head.TenantID,
+			Usage:     u,
+			Part:      Part{ID: id, Seq: seq, Total: total, Data: data},
+		})
+		if err != nil {
+			return nil, fmt.Errorf("分片序列化失败: %w", err)
+		}
+		frames = append(frames, part)
+	}
+	return frames, nil
+}
+
+// partial 正在重组的消息，分片按 seq 保存；不按 total 预先分配，total 由对端声明，不可信
+type partial struct {
+	parts   map[int][]byte
+	total   int
+	size    int
+	started time.Time
+}
+
+// Reassembler 重组分片帧，丢弃超过 timeout 仍未收齐或总大小超过 maxMessageSize 的消息
+type Reassembler struct {
+	mu             sync.Mutex
+	pending        map[string]*partial
+	maxMessageSize int
+	timeout        time.Duration
+}
+
+// NewReassembler 创建重组器
+func NewReassembler(cfg config.ChunkingConfig) *Reassembler {
+	return &Reassembler{
+		pending:        make(map[string]*partial),
+		maxMessageSize: cfg.MaxMessageSize,
+		timeout:        cfg.Timeout,
+	}
+}
+
+// isPart 快速判断帧是否可能为分片帧，避免对每一帧都完整解码
+func isPart(frame []byte) bool {
+	return bytes.Contains(frame, []byte(`"part"`))
+}
+
+// Add 处理收到的一帧：非分片帧原样返回；分片帧在收齐前返回 nil，收齐后返回重组的消息
+func (r *Reassembler) Add(frame []byte) ([]byte, error) {
+	if !isPart(frame) {
+		return frame, nil
+	}
+	var pf partFrame
+	if err := json.Unmarshal(frame, &pf); err != nil || pf.Action != ActionPart {
+		// 内容中恰好包含 "part" 的普通帧
+		return frame, nil
+	}
+	p := pf.Part
+	if p.ID == "" || p.Total <= 0 || p.Seq < 0 || p.Seq >= p.Total {
+		return nil, apperr.New(apperr.Protocol, apperr.CodeBadFrame, fmt.Sprintf("分片信息无效: seq=%d total=%d", p.Seq, p.Total))
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.expire(time.Now())
+
+	msg, ok := r.pending[p.ID]
+	if !ok {
+		msg = &partial{parts: make(map[int][]byte), total: p.Total, started: time.Now()}
+		r.pending[p.ID] = msg
+	}
+	if msg.total != p.Total {
+		delete(r.pending, p.ID)
+		return nil, apperr.New(apperr.Protocol, apperr.CodeBadFrame, "同一消息的分片总数不一致")
+	}
+	if _, ok := msg.parts[p.Seq]; ok {
+		return nil, nil // 重复的分片
+	}
+	msg.size += len(p.Data)
+	if r.maxMessageSize > 0 && msg.size > r.maxMessageSize {
+		delete(r.pending, p.ID)
+		return nil, apperr.New(apperr.Protocol, apperr.CodeBadFrame, fmt.Sprintf("消息超过 max_message_size (%d 字节)", r.maxMessageSize))
+	}
+	msg.parts[p.Seq] = p.Data
+	if len(msg.parts) < msg.total {
+		return nil, nil
+	}
+
+	delete(r.pending, p.ID)
+	out := make([]byte, 0, msg.size)
+	for seq := range msg.total {
+		out = append(out, msg.parts[seq]...)
+	}
+	return out, nil
+}
+
+// Sweep 丢弃超时未收齐的消息，返回丢弃的条数
+func (r *Reassembler) Sweep(now time.Time) int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.expire(now)
+}
+
+// expire 丢弃超时未收齐的消息，调用方需持有锁
+func (r *Reassembler) expire(now time.Time) int {
+	if r.timeout <= 0 {
+		return 0
+	}
+	n := 0
+	for id, msg := range r.pending {
+		if now.Sub(msg.started) > r.timeout {
+			delete(r.pending, id)
+			n++
+		}
+	}
+	return n
+}
+
+// chunkingClient 写入时拆分超过帧大小限制的消息，读取时重组分片
+type chunkingClient struct {
+	WSClient
+	maxFrameSize int
+	reassembler  *Reassembler
+}
+
+func newChunkingClient(ws WSClient, cfg config.ChunkingConfig) *chunkingClient {
+	return &chunkingClient{WSClient: ws, maxFrameSize: cfg.MaxFrameSize, reassembler: NewReassembler(cfg)}
+}
+
+func (c *chunkingClient) ReadMessage() ([]byte, error) {
+	for {
+		frame, err := c.WSClient.ReadMessage()
+		if err != nil {
+			return nil, err
+		}
+		msg, err := c.reassembler.Add(frame)
+		if err != nil {
+			return nil, err
+		}
+		if msg != nil {
+			return msg, nil
+		}
+	}
+}
+
+func (c *chunkingClient) WriteMessage(message []byte) error {
+	frames, err := SplitFrame(message, c.maxFrameSize)
+	if err != nil {
+		return err
+	}
+	for _, f := range frames {
+		if err := c.WSClient.WriteMessage(f); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,211 @@
+package bridge
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"ollama_dev/internal/apperr"
+	"ollama_dev/internal/config"
+)
+
+func chunkingConfig(maxFrame int) config.ChunkingConfig {
+	cfg := config.Default().Chunking
+	cfg.MaxFrameSize = maxFrame
+	return cfg
+}
+
+func largeFrame(t *testing.T, size int) []byte {
+	t.Helper()
+	frame, err := json.Marshal(CloudResponse{
+		V: ProtocolVersion, Type: TypeClientToServer, Action: "chat", RequestID: "r1",
+		Data: strings.Repeat("字", size/3), Status: "done",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return frame
+}
+
+func TestSplitAndReassemble(t *testing.T) {
+	frame := largeFrame(t, 20<<10)
+	parts, err := SplitFrame(frame, 4096)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(parts) < 2 {
+		t.Fatalf("expected the frame to be split, got %d parts", len(parts))
+	}
+
+	var head partFrame
+	for _, p := range parts {
+		if len(p) > 4096 {
+			t.Errorf("part of %d bytes exceeds max_frame_size", len(p))
+		}
+		if err := json.Unmarshal(p, &head); err != nil || head.Action != ActionPart || head.RequestID != "r1" || head.Type != TypeClientToServer {
+			t.Fatalf("unexpected part header: %+v (%v)", head, err)
+		}
+	}
+
+	// 乱序且含重复分片时仍能正确重组：先收到最后一片，再收到重复的第一片
+	r := NewReassembler(chunkingConfig(4096))
+	order := append([][]byte{parts[len(parts)-1], parts[0]}, parts[:len(parts)-1]...)
+	var got []byte
+	for i, p := range order {
+		msg, err := r.Add(p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if msg != nil {
+			if i != len(order)-1 {
+				t.Fatalf("message completed early at part %d", i)
+			}
+			got = msg
+		}
+	}
+	if !bytes.Equal(got, frame) {
+		t.Fatal("reassembled frame differs from the original")
+	}
+}
+
+func TestSplitFrameCarriesUsage(t *testing.T) {
+	frame, err := json.Marshal(CloudResponse{
+		V: ProtocolVersion, Type: TypeClientToServer, Action: "chat", RequestID: "r1", Status: "done",
+		Data: strings.Repeat("字", 20<<10/3), Usage: &Usage{Model: "llama3", PromptTokens: 3, CompletionTokens: 5},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	parts, err := SplitFrame(frame, 4096)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 只有第一个分片携带用量，服务器无需重组即可统计且不会重复计数
+	for i, p := range parts {
+		var head partFrame
+		if err := json.Unmarshal(p, &head); err != nil {
+			t.Fatal(err)
+		}
+		if (i == 0) != (head.Usage != nil) {
+			t.Errorf("part %d: unexpected usage %s", i, head.Usage)
+		}
+	}
+}
+
+func TestSmallFramesPassThrough(t *testing.T) {
+	frame := []byte(`{"type":"server_to_client","action":"chat","params":{"messages":[{"role":"user","content":"part"}]}}`)
+	parts, err := SplitFrame(frame, 4096)
+	if err != nil || len(parts) != 1 || !bytes.Equal(parts[0], frame) {
+		t.Fatalf("small frame should not be split: %v", err)
+	}
+	// 内容中包含 "part" 的普通帧原样返回
+	msg, err := NewReassembler(chunkingConfig(4096)).Add(frame)
+	if err != nil || !bytes.Equal(msg, frame) {
+		t.Fatalf("regular frame should pass through: %v", err)
+	}
+	// max_frame_size 为 0 时不拆分
+	if parts, _ := SplitFrame(largeFrame(t, 20<<10), 0); len(parts) != 1 {
+		t.Errorf("expected no split with max_frame_size 0, got %d parts", len(parts))
+	}
+}
+
+func TestReassemblerLimits(t *testing.T) {
+	parts, err := SplitFrame(largeFrame(t, 20<<10), 4096)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 超过 max_message_size 时丢弃
+	cfg := chunkingConfig(4096)
+	cfg.MaxMessageSize = 8 << 10
+	r := NewReassembler(cfg)
+	var lastErr error
+	for _, p := range parts {
+		if _, err := r.Add(p); err != nil {
+			lastErr = err
+			break
+		}
+	}
+	if apperr.CodeOf(lastErr) != apperr.CodeBadFrame {
+		t.Errorf("expected bad_frame for oversized message, got %v", lastErr)
+	}
+
+	// 声明的分片总数极大时不按 total 分配内存
+	r = NewReassembler(chunkingConfig(4096))
+	if msg, err := r.Add([]byte(`{"action":"part","part":{"id":"x","seq":0,"total":4000000000000,"data":"e30="}}`)); msg != nil || err != nil {
+		t.Errorf("first of many parts should be kept pending, got %q %v", msg, err)
+	}
+
+	// 超时未收齐的分片被丢弃，之后的分片重新开始计数
+	cfg = chunkingConfig(4096)
+	cfg.Timeout = time.Millisecond
+	r = NewReassembler(cfg)
+	if _, err := r.Add(parts[0]); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(5 * time.Millisecond)
+	for _, p := range parts[1:] {
+		if msg, err := r.Add(p); err != nil || msg != nil {
+			t.Fatalf("expired message must not complete: %v", err)
+		}
+	}
+}
+
+func TestReassemblerSweep(t *testing.T) {
+	parts, err := SplitFrame(largeFrame(t, 20<<10), 4096)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := NewReassembler(chunkingConfig(4096))
+	if _, err := r.Add(parts[0]); err != nil {
+		t.Fatal(err)
+	}
+	// 未超时时保留，超时后由 Sweep 回收
+	if n := r.Sweep(time.Now()); n != 0 {
+		t.Errorf("expected nothing reclaimed before timeout, got %d", n)
+	}
+	if n := r.Sweep(time.Now().Add(2 * time.Minute)); n != 1 {
+		t.Errorf("expected 1 partial message reclaimed, got %d", n)
+	}
+}
+
+func TestChunkingClient(t *testing.T) {
+	ws := &fakeWSClient{}
+	c := newChunkingClient(ws, chunkingConfig(4096))
+	frame := largeFrame(t, 20<<10)
+	if err := c.WriteMessage(frame); err != nil {
+		t.Fatal(err)
+	}
+	if len(ws.written) < 2 {
+		t.Fatalf("expected split frames, got %d", len(ws.written))
+	}
+
+	// 将写出的分片作为输入读回
+	reader := newChunkingClient(&queueWSClient{frames: ws.written}, chunkingConfig(4096))
+	got, err := reader.ReadMessage()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, frame) {
+		t.Fatal("chunking client did not reassemble the message")
+	}
+}
+
+// queueWSClient 依次返回预置的帧
+type queueWSClient struct {
+	fakeWSClient
+	frames [][]byte
+}
+
+func (q *queueWSClient) ReadMessage() ([]byte, error) {
+	if len(q.frames) == 0 {
+		return nil, errQueueEmpty
+	}
+	f := q.frames[0]
+	q.frames = q.frames[1:]
+	return f, nil
+}
+
+var errQueueEmpty = apperr.New(apperr.Internal, apperr.CodeInternal, "queue empty")
@@ -0,0 +1,54 @@
+package bridge
+
+import "time"
+
+// Clock 主循环使用的时钟：心跳的定时、读取超时、心跳往返时延与请求处理耗时都从这里取时间，测试中可替换为手动推进的时钟
+type Clock interface {
+	Now() time.Time
+	NewTicker(d time.Duration) Ticker
+}
+
+// Ticker 对应 time.Ticker
+type Ticker interface {
+	C() <-chan time.Time
+	Stop()
+}
+
+// SystemClock 使用系统时间的 Clock，NewServer 默认使用
+var SystemClock Clock = systemClock{}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time { return time.Now() }
+
+func (systemClock) NewTicker(d time.Duration) Ticker { return systemTicker{time.NewTicker(d)} }
+
+type systemTicker struct{ t *time.Ticker }
+
+func (t systemTicker) C() <-chan time.Time { return t.t.C }
+func (t systemTicker) Stop()               { t.t.Stop() }
+
+// SetClock 替换主循环使用的时钟，c 为 nil 时使用系统时间；需要在 Run 之前调用
+func (s *Server) SetClock(c Clock) {
+	if c == nil {
+		c = SystemClock
+	}
+	s.clock = c
+	s.latency.now = c.Now
+}
+
+// readDeadliner 可以直接设置读取超时的连接，未实现时通过 Conn() 设置
+type readDeadliner interface {
+	SetReadDeadline(t time.Time) error
+}
+
+// setReadDeadline 设置下一次读取的超时；连接不是 WebSocket (如测试中的内存连接) 且不支持超时时跳过
+func (s *Server) setReadDeadline(t time.Time) error {
+	if d, ok := s.wsClient.(readDeadliner); ok {
+		return d.SetReadDeadline(t)
+	}
+	if conn := s.wsClient.Conn(); conn != nil {
+		return conn.SetReadDeadline(t)
+	}
+	return nil
+}
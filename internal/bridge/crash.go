@@ -0,0 +1,167 @@
+package bridge
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime/debug"
+	"sync"
+	"time"
+
+	"ollama_dev/internal/apperr"
+	"ollama_dev/internal/config"
+	"ollama_dev/internal/logging"
+	"ollama_dev/internal/version"
+)
+
+// RequestSummary 崩溃报告中记录的请求摘要，对话内容已脱敏
+type RequestSummary struct {
+	Time      time.Time     `json:"time"`
+	Type      string        `json:"type"`
+	Action    string        `json:"action"`
+	RequestID string        `json:"request_id,omitempty"`
+	ModelName string        `json:"model_name,omitempty"`
+	Messages  []ChatMessage `json:"messages,omitempty"`
+}
+
+// CrashReport 崩溃报告
+type CrashReport struct {
+	Time           time.Time        `json:"time"`
+	Version        version.Info     `json:"version"`
+	Where          string           `json:"where"` // 发生 panic 的位置，例如 handler:chat
+	Panic          string           `json:"panic"`
+	Stack          string           `json:"stack"`
+	RecentRequests []RequestSummary `json:"recent_requests"`
+}
+
+// CrashReporter 记录最近处理的请求，panic 时写入报告文件并可选发送到 Webhook
+type CrashReporter struct {
+	cfg    config.CrashConfig
+	logger Logger
+	client *http.Client
+
+	mu     sync.Mutex
+	recent []RequestSummary // 环形缓冲，next 指向最旧的一条
+	next   int
+}
+
+// NewCrashReporter 创建崩溃报告器
+func NewCrashReporter(cfg config.CrashConfig, logger Logger) *CrashReporter {
+	return &CrashReporter{
+		cfg:    cfg,
+		logger: logger,
+		client: &http.Client{Timeout: cfg.WebhookTimeout},
+	}
+}
+
+// Record 记录一条请求摘要，对话内容替换为脱敏占位符
+func (c *CrashReporter) Record(req *CloudRequest) {
+	if c.cfg.History <= 0 || req == nil {
+		return
+	}
+
+	summary := RequestSummary{
+		Time:      time.Now(),
+		Type:      req.Type,
+		Action:    req.Action,
+		RequestID: req.RequestID,
+		ModelName: req.Params.ModelName,
+	}
+	for _, msg := range req.Params.Messages {
+		summary.Messages = append(summary.Messages, ChatMessage{Role: msg.Role, Content: logging.RedactedValue})
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.recent) < c.cfg.History {
+		c.recent = append(c.recent, summary)
+		return
+	}
+	c.recent[c.next] = summary
+	c.next = (c.next + 1) % len(c.recent)
+}
+
+// Recent 按时间顺序返回最近的请求摘要
+func (c *CrashReporter) Recent() []RequestSummary {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	out := make([]RequestSummary, 0, len(c.recent))
+	out = append(out, c.recent[c.next:]...)
+	return append(out, c.recent[:c.next]...)
+}
+
+// Guard 执行 fn，发生 panic 时写入崩溃报告并返回 Internal 类别的错误，调用方可继续运行
+func (c *CrashReporter) Guard(where string, fn func() error) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			c.Report(where, r, debug.Stack())
+			err = apperr.New(apperr.Internal, apperr.CodeInternal, fmt.Sprintf("%s 发生 panic: %v", where, r))
+		}
+	}()
+	return fn()
+}
+
+// Report 写入崩溃报告文件并发送 Webhook，失败只记录日志
+func (c *CrashReporter) Report(where string, recovered any, stack []byte) {
+	report := CrashReport{
+		Time:           time.Now(),
+		Version:        version.Get(),
+		Where:          where,
+		Panic:          fmt.Sprint(recovered),
+		Stack:          string(stack),
+		RecentRequests: c.Recent(),
+	}
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		c.logger.Error("崩溃报告序列化失败", "error", err)
+		return
+	}
+
+	path, err := c.write(report.Time, data)
+	if err != nil {
+		c.logger.Error("写入崩溃报告失败", "error", err)
+	} else {
+		c.logger.Error("发生 panic，已写入崩溃报告", "where", where, "panic", report.Panic, "path", path)
+	}
+
+	if c.cfg.Webhook != "" {
+		if err := c.post(data); err != nil {
+			c.logger.Error("发送崩溃报告失败", "error", err)
+		}
+	}
+}
+
+func (c *CrashReporter) write(at time.Time, data []byte) (string, error) {
+	if err := os.MkdirAll(c.cfg.Dir, 0o700); err != nil {
+		return "", fmt.Errorf("创建崩溃报告目录失败: %w", err)
+	}
+	name := fmt.Sprintf("crash-%s-%d.json", at.UTC().Format("20060102T150405.000"), os.Getpid())
+	path := filepath.Join(c.cfg.Dir, name)
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+func (c *CrashReporter) post(data []byte) error {
+	ctx, cancel := context.WithTimeout(context.Background(), c.cfg.WebhookTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.cfg.Webhook, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("Webhook 返回 %s", resp.Status)
+	}
+	return nil
+}
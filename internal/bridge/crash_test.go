@@ -0,0 +1,90 @@
+package bridge
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"ollama_dev/internal/apperr"
+	"ollama_dev/internal/config"
+	"ollama_dev/internal/logging"
+)
+
+// panicOllama 调用时 panic
+type panicOllama struct{}
+
+func (panicOllama) Chat(ctx context.Context, req ChatRequest) (Reply, error) {
+	panic("boom")
+}
+func (panicOllama) ChatStream(ctx context.Context, req ChatRequest, onChunk func(string) error) (Reply, error) {
+	panic("boom")
+}
+func (panicOllama) Generate(ctx context.Context, req GenerateRequest, onChunk func(string) error) (Reply, error) {
+	panic("boom")
+}
+func (panicOllama) Embed(ctx context.Context, req EmbedRequest) (Embeddings, error) { panic("boom") }
+func (panicOllama) ListModels(ctx context.Context) ([]ModelInfo, error)             { return nil, nil }
+func (panicOllama) Heartbeat(ctx context.Context) error                             { return nil }
+
+func TestCrashReporterKeepsRecentRequests(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	c := NewCrashReporter(config.CrashConfig{History: 2}, logger)
+	for i := 1; i <= 3; i++ {
+		c.Record(&CloudRequest{Action: "chat", RequestID: fmt.Sprint(i)})
+	}
+
+	recent := c.Recent()
+	if len(recent) != 2 || recent[0].RequestID != "2" || recent[1].RequestID != "3" {
+		t.Fatalf("unexpected recent requests: %+v", recent)
+	}
+}
+
+func TestHandlerPanicWritesReport(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	cfg := config.Default().Bridge
+	cfg.Crash.Dir = t.TempDir()
+
+	ws := &fakeWSClient{}
+	s := NewServer(ws, NewHandlerFactory(panicOllama{}, logger), nil, cfg, logger)
+
+	req := &CloudRequest{Type: "server_to_client", Action: "chat", RequestID: "1"}
+	req.Params.ModelName = "llama3"
+	req.Params.Messages = []ChatMessage{{Role: "user", Content: "secret prompt"}}
+
+	err := s.handleServerRequest(&Message{Request: req})
+	if apperr.CategoryOf(err) != apperr.Internal {
+		t.Fatalf("expected an internal error, got %v", err)
+	}
+	if len(ws.written) != 1 {
+		t.Fatalf("expected an error frame, got %d frames", len(ws.written))
+	}
+
+	files, _ := filepath.Glob(filepath.Join(cfg.Crash.Dir, "crash-*.json"))
+	if len(files) != 1 {
+		t.Fatalf("expected one crash report, got %v", files)
+	}
+	data, err := os.ReadFile(files[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), "secret prompt") {
+		t.Error("crash report should not contain prompt content")
+	}
+
+	var report CrashReport
+	if err := json.Unmarshal(data, &report); err != nil {
+		t.Fatal(err)
+	}
+	if report.Where != "handler:chat" || report.Panic != "boom" || report.Stack == "" {
+		t.Errorf("unexpected report: where=%q panic=%q", report.Where, report.Panic)
+	}
+	if len(report.RecentRequests) != 1 || report.RecentRequests[0].Messages[0].Content != logging.RedactedValue {
+		t.Errorf("unexpected recent requests: %+v", report.RecentRequests)
+	}
+}
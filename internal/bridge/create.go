@@ -0,0 +1,139 @@
+package bridge
+
+import (
+	"context"
+	"maps"
+	"strings"
+
+	"github.com/ollama/ollama/api"
+	"github.com/ollama/ollama/parser"
+
+	"ollama_dev/internal/apperr"
+	"ollama_dev/internal/policy"
+)
+
+// ModelCreator 按 Modelfile 创建模型，Ollama 客户端实现时提供 create_model 动作
+type ModelCreator interface {
+	Create(ctx context.Context, req *api.CreateRequest, fn api.CreateProgressFunc) error
+}
+
+// createActions 模型创建相关的动作
+var createActions = []string{"create_model"}
+
+// SetModelCreator 启用 create_model 动作，m 为 nil 时不启用
+func (f *HandlerFactory) SetModelCreator(m ModelCreator) {
+	f.creator = m
+}
+
+// CreateHandler create_model 以 params.model_name 为名创建模型：params.modelfile 为 Modelfile 的内容，
+// params.from、system、template 与 parameters 逐项覆盖其中的对应指令，也可以不带 Modelfile 只用这些参数；
+// 带 params.stream 时以 streaming 帧发送构建进度 ({status, digest, total, completed})，done 帧的 data 为模型名；
+// 启用策略时合并后的 FROM 与新模型名都须在允许列表中
+type CreateHandler struct {
+	creator ModelCreator
+	policy  *policy.Watcher // 可为 nil
+}
+
+func NewCreateHandler(m ModelCreator) *CreateHandler {
+	return &CreateHandler{creator: m}
+}
+
+func (h *CreateHandler) Handle(ctx context.Context, req *CloudRequest) (*CloudResponse, error) {
+	return h.HandleStream(ctx, req, func(any) error { return nil })
+}
+
+func (h *CreateHandler) HandleStream(ctx context.Context, req *CloudRequest, emit func(data any) error) (*CloudResponse, error) {
+	create, err := createRequest(&req.Params)
+	if err != nil {
+		return nil, err
+	}
+	if err := h.policy.Allow(create.From); err != nil {
+		return nil, err
+	}
+	if err := h.policy.Allow(create.Model); err != nil {
+		return nil, err
+	}
+	err = h.creator.Create(ctx, create, func(p api.ProgressResponse) error {
+		return emit(p)
+	})
+	if err != nil {
+		if apperr.CategoryOf(err) == apperr.Timeout {
+			return nil, err
+		}
+		return nil, BackendError(err, "创建模型失败")
+	}
+	return newResponse(req, map[string]string{"model": create.Model}), nil
+}
+
+// createRequest 合并 Modelfile 与单独的参数，要求模型名与 FROM
+func createRequest(p *CloudParams) (*api.CreateRequest, error) {
+	if p.ModelName == "" {
+		return nil, apperr.New(apperr.Validation, apperr.CodeInvalidParams, "model_name 不能为空")
+	}
+	req := &api.CreateRequest{}
+	if p.Modelfile != "" {
+		var err error
+		if req, err = parseModelfile(p.Modelfile); err != nil {
+			return nil, err
+		}
+	}
+	req.Model = p.ModelName
+	if p.From != "" {
+		req.From = p.From
+	}
+	if p.System != "" {
+		req.System = p.System
+	}
+	if p.Template != "" {
+		req.Template = p.Template
+	}
+	if len(p.Parameters) > 0 {
+		if req.Parameters == nil {
+			req.Parameters = map[string]any{}
+		}
+		maps.Copy(req.Parameters, p.Parameters)
+	}
+	if req.From == "" {
+		return nil, apperr.New(apperr.Validation, apperr.CodeInvalidParams, "缺少基础模型，请在 Modelfile 中写 FROM 或指定 from")
+	}
+	return req, nil
+}
+
+// parseModelfile 将 Modelfile 转换为创建请求；FROM 只接受模型名，本地的模型文件与 ADAPTER 需要先上传 blob，不支持
+func parseModelfile(body string) (*api.CreateRequest, error) {
+	f, err := parser.ParseFile(strings.NewReader(body))
+	if err != nil {
+		return nil, apperr.Wrap(err, apperr.Validation, apperr.CodeInvalidParams, "解析 Modelfile 失败: "+err.Error())
+	}
+	req := &api.CreateRequest{}
+	params := map[string][]string{}
+	var licenses []string
+	for _, c := range f.Commands {
+		switch c.Name {
+		case "model":
+			req.From = c.Args
+		case "adapter":
+			return nil, apperr.New(apperr.Validation, apperr.CodeInvalidParams, "不支持 ADAPTER 指令")
+		case "template":
+			req.Template = c.Args
+		case "system":
+			req.System = c.Args
+		case "license":
+			licenses = append(licenses, c.Args)
+		case "message":
+			role, content, _ := strings.Cut(c.Args, ": ")
+			req.Messages = append(req.Messages, api.Message{Role: role, Content: content})
+		default:
+			params[c.Name] = append(params[c.Name], c.Args)
+		}
+	}
+	if len(params) > 0 {
+		if req.Parameters, err = api.FormatParams(params); err != nil {
+			return nil, apperr.Wrap(err, apperr.Validation, apperr.CodeInvalidParams, "Modelfile 的 PARAMETER 无效: "+err.Error())
+		}
+	}
+	if len(licenses) > 0 {
+		req.License = licenses
+	}
+	return req, nil
+}
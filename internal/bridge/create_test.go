@@ -0,0 +1,90 @@
+package bridge
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+
+	"github.com/ollama/ollama/api"
+
+	"ollama_dev/internal/apperr"
+	"ollama_dev/internal/auth"
+)
+
+func TestCreateModelFromModelfile(t *testing.T) {
+	var created api.CreateRequest
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/create" {
+			json.NewEncoder(w).Encode(api.ListResponse{})
+			return
+		}
+		json.NewDecoder(r.Body).Decode(&created)
+		enc := json.NewEncoder(w)
+		for _, status := range []string{"using existing layer", "writing manifest", "success"} {
+			enc.Encode(api.ProgressResponse{Status: status})
+		}
+	}))
+	defer srv.Close()
+	c, err := NewOllamaClient(srv.URL, NewMemoryCache(0, 0), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	factory := NewHandlerFactory(c, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	factory.SetModelCreator(c)
+	if !slices.Contains(factory.Actions(), "create_model") {
+		t.Fatalf("expected create_model to be advertised, got %v", factory.Actions())
+	}
+
+	req := &CloudRequest{Action: "create_model", RequestID: "1", Role: auth.RoleAdmin, Params: CloudParams{
+		ModelName:  "ops-assistant",
+		Modelfile:  "FROM llama3\nSYSTEM 旧的提示词\nPARAMETER temperature 0.7\nPARAMETER stop <|eot_id|>\nMESSAGE user 你好\n",
+		System:     "你是运维助手",
+		Parameters: map[string]any{"num_ctx": 8192},
+	}}
+	var progress []string
+	resp, err := factory.CreateHandler(req.Action).(StreamHandler).HandleStream(context.Background(), req, func(data any) error {
+		progress = append(progress, data.(api.ProgressResponse).Status)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Data.(map[string]string)["model"] != "ops-assistant" {
+		t.Errorf("unexpected response %+v", resp.Data)
+	}
+	if !slices.Equal(progress, []string{"using existing layer", "writing manifest", "success"}) {
+		t.Errorf("unexpected progress %v", progress)
+	}
+	// 单独的参数覆盖 Modelfile 中的指令，其余指令原样保留
+	if created.Model != "ops-assistant" || created.From != "llama3" || created.System != "你是运维助手" {
+		t.Errorf("unexpected create request %+v", created)
+	}
+	if created.Parameters["temperature"] != 0.7 || created.Parameters["num_ctx"] != float64(8192) {
+		t.Errorf("unexpected parameters %v", created.Parameters)
+	}
+	if stop, _ := created.Parameters["stop"].([]any); len(stop) != 1 || stop[0] != "<|eot_id|>" {
+		t.Errorf("unexpected stop %v", created.Parameters["stop"])
+	}
+	if len(created.Messages) != 1 || created.Messages[0].Content != "你好" {
+		t.Errorf("unexpected messages %v", created.Messages)
+	}
+}
+
+func TestCreateModelRejectsInvalidParams(t *testing.T) {
+	h := NewCreateHandler(nil)
+	for name, p := range map[string]CloudParams{
+		"no name":       {From: "llama3"},
+		"no base model": {ModelName: "x", System: "hi"},
+		"adapter":       {ModelName: "x", Modelfile: "FROM llama3\nADAPTER ./lora.gguf\n"},
+		"bad parameter": {ModelName: "x", Modelfile: "FROM llama3\nPARAMETER temperature hot\n"},
+	} {
+		if _, err := h.Handle(context.Background(), &CloudRequest{Action: "create_model", Params: p}); apperr.CodeOf(err) != apperr.CodeInvalidParams {
+			t.Errorf("%s: expected invalid_params, got %v", name, err)
+		}
+	}
+}
@@ -0,0 +1,68 @@
+package bridge
+
+import (
+	"time"
+
+	"github.com/patrickmn/go-cache"
+)
+
+// inFlight 标记请求正在处理，尚无可重发的响应
+type inFlight struct{}
+
+// dedup 记录最近处理过的 request_id，云端在网络抖动后重试时直接重发原响应，避免重复生成
+type dedup struct {
+	cache *cache.Cache
+}
+
+// newDedup ttl 不大于 0 时返回 nil，表示不做去重
+func newDedup(ttl time.Duration) *dedup {
+	if ttl <= 0 {
+		return nil
+	}
+	// 过期记录由 janitor 调用 sweep 清理
+	return &dedup{cache: cache.New(ttl, 0)}
+}
+
+// sweep 删除过期记录，返回删除的条数
+func (d *dedup) sweep(now time.Time) int {
+	if d == nil {
+		return 0
+	}
+	before := d.cache.ItemCount()
+	d.cache.DeleteExpired()
+	return max(before-d.cache.ItemCount(), 0)
+}
+
+// begin 登记请求，返回值 seen 为 true 表示重复请求，frame 为原响应帧，仍在处理中时为 nil
+func (d *dedup) begin(id string) (frame []byte, seen bool) {
+	if d == nil || id == "" {
+		return nil, false
+	}
+	if err := d.cache.Add(id, inFlight{}, cache.DefaultExpiration); err == nil {
+		return nil, false
+	}
+	v, found := d.cache.Get(id)
+	if !found {
+		// 恰好过期，按新请求处理
+		d.cache.SetDefault(id, inFlight{})
+		return nil, false
+	}
+	frame, _ = v.([]byte)
+	return frame, true
+}
+
+// done 保存成功响应的帧，重试时原样重发
+func (d *dedup) done(id string, frame []byte) {
+	if d == nil || id == "" {
+		return
+	}
+	d.cache.SetDefault(id, frame)
+}
+
+// forget 处理失败时移除记录，使重试可以重新执行
+func (d *dedup) forget(id string) {
+	if d == nil || id == "" {
+		return
+	}
+	d.cache.Delete(id)
+}
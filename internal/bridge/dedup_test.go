@@ -0,0 +1,129 @@
+package bridge
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+
+	"ollama_dev/internal/config"
+)
+
+// countingOllama 记录调用次数，err 非 nil 时返回错误
+type countingOllama struct {
+	calls int
+	err   error
+}
+
+func (c *countingOllama) Chat(ctx context.Context, req ChatRequest) (Reply, error) {
+	c.calls++
+	return Reply{Content: "hello", Usage: Usage{PromptTokens: 3, CompletionTokens: 5}}, c.err
+}
+func (c *countingOllama) Embed(ctx context.Context, req EmbedRequest) (Embeddings, error) {
+	c.calls++
+	return Embeddings{}, c.err
+}
+func (c *countingOllama) ChatStream(ctx context.Context, req ChatRequest, onChunk func(string) error) (Reply, error) {
+	c.calls++
+	return Reply{Content: "hello", Usage: Usage{PromptTokens: 3, CompletionTokens: 5}}, c.err
+}
+func (c *countingOllama) Generate(ctx context.Context, req GenerateRequest, onChunk func(string) error) (Reply, error) {
+	c.calls++
+	return Reply{Content: "hello", Usage: Usage{PromptTokens: 3, CompletionTokens: 5}}, c.err
+}
+func (c *countingOllama) ListModels(ctx context.Context) ([]ModelInfo, error) { return nil, c.err }
+func (c *countingOllama) Heartbeat(ctx context.Context) error                 { return nil }
+
+func replayFrames(t *testing.T, ollama OllamaClient, cfg config.BridgeConfig, frames ...string) []CloudResponse {
+	t.Helper()
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	var out bytes.Buffer
+	s := NewServer(&replayClient{out: &out}, NewHandlerFactory(ollama, logger), nil, cfg, logger)
+	for _, f := range frames {
+		_ = s.replay([]byte(f))
+	}
+
+	var resps []CloudResponse
+	dec := json.NewDecoder(&out)
+	for {
+		var resp CloudResponse
+		if err := dec.Decode(&resp); err != nil {
+			return resps
+		}
+		resps = append(resps, resp)
+	}
+}
+
+const chatFrame = `{"type":"server_to_client","action":"chat","request_id":"r1","params":{"model_name":"llama3"}}`
+
+func TestDuplicateRequestResendsResponse(t *testing.T) {
+	ollama := &countingOllama{}
+	resps := replayFrames(t, ollama, config.Default().Bridge, chatFrame, chatFrame)
+
+	// 重试只重发原响应，不会再次生成
+	if ollama.calls != 1 {
+		t.Errorf("expected one generation, got %d", ollama.calls)
+	}
+	if len(resps) != 2 || resps[0].Status != "done" || resps[1].Status != "done" || resps[1].RequestID != "r1" {
+		t.Fatalf("unexpected responses: %+v", resps)
+	}
+}
+
+func TestFailedRequestIsRetried(t *testing.T) {
+	ollama := &countingOllama{err: errors.New("connection refused")}
+	resps := replayFrames(t, ollama, config.Default().Bridge, chatFrame, chatFrame)
+
+	// 失败的请求不记录结果，重试时重新执行
+	if ollama.calls != 2 {
+		t.Errorf("expected failed request to be retried, got %d calls", ollama.calls)
+	}
+	if len(resps) != 2 || resps[1].Status != "error" {
+		t.Fatalf("unexpected responses: %+v", resps)
+	}
+}
+
+func TestDedupDisabled(t *testing.T) {
+	cfg := config.Default().Bridge
+	cfg.DedupTTL = 0
+	ollama := &countingOllama{}
+	replayFrames(t, ollama, cfg, chatFrame, chatFrame)
+	if ollama.calls != 2 {
+		t.Errorf("expected every request to run with dedup disabled, got %d calls", ollama.calls)
+	}
+}
+
+func TestDedupInFlight(t *testing.T) {
+	d := newDedup(config.Default().Bridge.DedupTTL)
+	if _, seen := d.begin("r1"); seen {
+		t.Fatal("first request must not be a duplicate")
+	}
+	// 仍在处理中的重复请求没有可重发的帧
+	if frame, seen := d.begin("r1"); !seen || frame != nil {
+		t.Errorf("expected in-flight duplicate, got seen=%v frame=%s", seen, frame)
+	}
+	d.done("r1", []byte("frame"))
+	if frame, seen := d.begin("r1"); !seen || string(frame) != "frame" {
+		t.Errorf("expected stored frame, got seen=%v frame=%s", seen, frame)
+	}
+	// 没有 request_id 的请求不去重
+	if _, seen := d.begin(""); seen {
+		t.Error("empty request_id must not be deduplicated")
+	}
+}
+
+func TestDedupSweep(t *testing.T) {
+	d := newDedup(time.Millisecond)
+	d.begin("r1")
+	d.done("r2", []byte("frame"))
+	time.Sleep(5 * time.Millisecond)
+	if n := d.sweep(time.Now()); n != 2 {
+		t.Errorf("expected 2 expired entries reclaimed, got %d", n)
+	}
+	if n := (*dedup)(nil).sweep(time.Now()); n != 0 {
+		t.Errorf("nil dedup must reclaim nothing, got %d", n)
+	}
+}
@@ -0,0 +1,69 @@
+package bridge
+
+import (
+	"encoding/json"
+
+	"ollama_dev/internal/apperr"
+	"ollama_dev/internal/keystore"
+	"ollama_dev/internal/util"
+)
+
+// e2e 端到端加密：解密请求的 params，并用同一租户的密钥加密对应响应的 data
+type e2e struct {
+	keys   *keystore.Store
+	cipher util.Cipher
+}
+
+// openRequest 解密请求，e 为 nil（未启用）时拒绝加密的请求，启用时拒绝明文请求（流控额度帧除外）
+func (e *e2e) openRequest(env *Envelope, req *CloudRequest) error {
+	if env.Sealed == nil {
+		if e != nil && env.Action != ActionCredit {
+			return apperr.New(apperr.Auth, apperr.CodeEncryptionRequired, "已启用端到端加密，拒绝明文请求")
+		}
+		return nil
+	}
+	if e == nil {
+		return apperr.New(apperr.Auth, apperr.CodeDecryptFailed, "未启用端到端加密，无法解密请求")
+	}
+	plaintext, err := e.keys.Open(env.TenantID, keystore.AAD(env.TenantID, env.RequestID), env.Sealed)
+	if err != nil {
+		return apperr.Wrap(err, apperr.Auth, apperr.CodeDecryptFailed, "解密请求失败")
+	}
+	env.Params = plaintext
+	req.sealed, req.Sealed = true, env.Sealed
+	return nil
+}
+
+// openQueued 解密从任务队列文件恢复的请求：文件中只保存密文，执行前解码 params
+func (e *e2e) openQueued(req *CloudRequest) error {
+	if e == nil {
+		return apperr.New(apperr.Auth, apperr.CodeDecryptFailed, "未启用端到端加密，无法解密排队任务")
+	}
+	plaintext, err := e.keys.Open(req.TenantID, keystore.AAD(req.TenantID, req.RequestID), req.Sealed)
+	if err != nil {
+		return apperr.Wrap(err, apperr.Auth, apperr.CodeDecryptFailed, "解密排队任务失败")
+	}
+	env := &Envelope{Params: plaintext}
+	if err := env.decodeParams(&req.Params, false); err != nil {
+		return err
+	}
+	req.RawParams = plaintext
+	return nil
+}
+
+// sealResponse 加密的请求对应的响应同样加密，data 移入 sealed
+func (e *e2e) sealResponse(resp *CloudResponse) error {
+	if e == nil || !resp.sealed {
+		return nil
+	}
+	data, err := json.Marshal(resp.Data)
+	if err != nil {
+		return err
+	}
+	sealed, err := e.keys.Seal(e.cipher, resp.TenantID, keystore.AAD(resp.TenantID, resp.RequestID), data)
+	if err != nil {
+		return err
+	}
+	resp.Data, resp.Sealed = nil, sealed
+	return nil
+}
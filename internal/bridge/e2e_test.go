@@ -0,0 +1,88 @@
+package bridge
+
+import (
+	"encoding/json"
+	"io"
+	"log/slog"
+	"path/filepath"
+	"testing"
+
+	"ollama_dev/internal/apperr"
+	"ollama_dev/internal/config"
+	"ollama_dev/internal/keystore"
+	"ollama_dev/internal/util"
+)
+
+// sealedChatFrame 构造租户 tenant 加密的 chat 请求帧，声明为 claimed 租户
+func sealedChatFrame(t *testing.T, keys *keystore.Store, tenant, claimed string) string {
+	t.Helper()
+	sealed, err := keys.Seal(util.AESGCM, tenant, keystore.AAD(tenant, "r1"), []byte(`{"model_name":"llama3"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	frame, _ := json.Marshal(CloudRequest{V: ProtocolVersion, Type: TypeServerToClient, Action: "chat", RequestID: "r1", TenantID: claimed, Sealed: sealed})
+	return string(frame)
+}
+
+func e2eServer(t *testing.T, keys *keystore.Store) (*Server, *fakeWSClient) {
+	t.Helper()
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	ws := &fakeWSClient{}
+	s := NewServer(ws, NewHandlerFactory(&countingOllama{}, logger), nil, config.Default().Bridge, logger)
+	s.SetKeystore(keys, util.ChaCha20Poly1305)
+	return s, ws
+}
+
+func TestSealedRequestGetsSealedResponse(t *testing.T) {
+	keys, _ := keystore.Open(filepath.Join(t.TempDir(), "keys.json"), []byte("test"))
+	_, _ = keys.Rotate("acme", 0)
+	s, ws := e2eServer(t, keys)
+
+	if err := s.replay([]byte(sealedChatFrame(t, keys, "acme", "acme"))); err != nil {
+		t.Fatal(err)
+	}
+	var resp Envelope
+	if err := json.Unmarshal(ws.written[0], &resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.Sealed == nil || resp.TenantID != "acme" || string(resp.Data) != "null" {
+		t.Fatalf("expected sealed response, got %s", ws.written[0])
+	}
+	data, err := keys.Open("acme", keystore.AAD("acme", "r1"), resp.Sealed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var chat struct {
+		Message ChatMessage `json:"message"`
+	}
+	if err := json.Unmarshal(data, &chat); err != nil || chat.Message.Content != "hello" {
+		t.Errorf("unexpected decrypted data %s: %v", data, err)
+	}
+}
+
+func TestE2ERejectsPlaintextAndCrossTenantFrames(t *testing.T) {
+	keys, _ := keystore.Open(filepath.Join(t.TempDir(), "keys.json"), []byte("test"))
+	_, _ = keys.Rotate("acme", 0)
+	_, _ = keys.Rotate("other", 0)
+	s, _ := e2eServer(t, keys)
+
+	cases := []struct {
+		frame string
+		code  string
+	}{
+		{chatFrame, apperr.CodeEncryptionRequired},
+		// other 租户的帧被改写为 acme，acme 的密钥无法解密
+		{sealedChatFrame(t, keys, "other", "acme"), apperr.CodeDecryptFailed},
+	}
+	for _, c := range cases {
+		if err := s.replay([]byte(c.frame)); apperr.CodeOf(err) != c.code {
+			t.Errorf("expected %s, got %v", c.code, err)
+		}
+	}
+
+	// 未启用加密的 bridge 拒绝加密的请求
+	plain := replayFrames(t, &countingOllama{}, config.Default().Bridge, sealedChatFrame(t, keys, "acme", "acme"))
+	if len(plain) != 1 || plain[0].Status != "error" {
+		t.Errorf("expected error frame without keystore, got %+v", plain)
+	}
+}
@@ -0,0 +1,88 @@
+package bridge
+
+//go:generate go run ../../cmd/asyncapigen -spec ../../api/asyncapi.yaml -out envelope_gen.go -package bridge
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+
+	"ollama_dev/internal/apperr"
+)
+
+// Kind 帧的种类，由 type 字段决定
+type Kind int
+
+const (
+	KindRequest   Kind = iota + 1 // 需要处理并回复的请求
+	KindResponse                  // 响应帧，桥接客户端收到时忽略（例如服务端广播回来的自身响应）
+	KindHeartbeat                 // 心跳帧，收到时忽略
+)
+
+// String 返回种类名称
+func (k Kind) String() string {
+	switch k {
+	case KindRequest:
+		return "request"
+	case KindResponse:
+		return "response"
+	case KindHeartbeat:
+		return "heartbeat"
+	default:
+		return "unknown"
+	}
+}
+
+// Kind 根据 type 字段判断帧的种类
+func (e *Envelope) Kind() (Kind, error) {
+	switch e.Type {
+	case TypeServerToClient:
+		return KindRequest, nil
+	case TypeClientToServer:
+		return KindResponse, nil
+	case TypeHeartbeat:
+		return KindHeartbeat, nil
+	default:
+		return 0, apperr.New(apperr.Protocol, apperr.CodeBadFrame, fmt.Sprintf("未知的帧类型: %q", e.Type))
+	}
+}
+
+// decodeEnvelope 解码外层结构，strict 时拒绝未知字段，并按 AsyncAPI 文档校验必需字段与枚举取值
+func decodeEnvelope(raw []byte, strict bool) (*Envelope, error) {
+	var env Envelope
+	if err := unmarshal(raw, &env, strict); err != nil {
+		return nil, apperr.Wrap(err, apperr.Protocol, apperr.CodeBadFrame, "解析帧失败")
+	}
+	if strict {
+		if err := env.Validate(); err != nil {
+			return nil, apperr.Wrap(err, apperr.Protocol, apperr.CodeBadFrame, "帧不符合协议")
+		}
+	}
+	return &env, nil
+}
+
+// decodeParams 将请求参数解码到 params，strict 时拒绝未知字段
+func (e *Envelope) decodeParams(params *CloudParams, strict bool) error {
+	if len(e.Params) == 0 || bytes.Equal(e.Params, []byte("null")) {
+		return nil
+	}
+	if err := unmarshal(e.Params, params, strict); err != nil {
+		return apperr.Wrap(err, apperr.Protocol, apperr.CodeBadFrame, "解析请求参数失败")
+	}
+	return nil
+}
+
+// unmarshal 解码单个 JSON 值，strict 时拒绝未知字段，不允许尾随数据
+func unmarshal(data []byte, v any, strict bool) error {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	if strict {
+		dec.DisallowUnknownFields()
+	}
+	if err := dec.Decode(v); err != nil {
+		return err
+	}
+	if dec.More() {
+		return fmt.Errorf("JSON 值之后存在多余数据")
+	}
+	return nil
+}
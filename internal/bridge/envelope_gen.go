@@ -0,0 +1,103 @@
+// Code generated by asyncapigen from api/asyncapi.yaml; DO NOT EDIT.
+
+package bridge
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/ollama/ollama/api"
+	"ollama_dev/internal/keystore"
+	"ollama_dev/internal/util/wsutils"
+)
+
+// 帧的 type 字段，表示消息方向
+const (
+	TypeServerToClient = "server_to_client" // 云端发往桥接客户端的请求
+	TypeClientToServer = "client_to_server" // 桥接客户端的响应或主动上报
+	TypeHeartbeat      = "heartbeat"        // 桥接客户端的心跳
+)
+
+// 响应帧的 status 字段，未携带时等同于 done
+const (
+	StatusStreaming = "streaming" // 流式响应中间分片的状态，最后一帧仍为 done
+	StatusDone      = "done"      // 请求处理完成
+	StatusError     = "error"     // 请求失败，data 为 ErrorData
+	StatusDuplicate = "duplicate" // 相同 request_id 的请求仍在处理中时回复的状态
+	StatusQueued    = "queued"    // 请求在任务队列中等待，data 为 QueuedJob；排队位置变化时再次发送
+)
+
+// Envelope 所有帧共用的外层结构，请求携带 params，响应携带 data 与 status
+type Envelope struct {
+	V         int              `json:"v,omitempty"` // 协议版本，旧版帧没有该字段
+	Type      string           `json:"type"`
+	Action    string           `json:"action"`
+	RequestID string           `json:"request_id,omitempty"`
+	TenantID  string           `json:"tenant_id,omitempty"`
+	User      string           `json:"user,omitempty"`
+	Upstream  string           `json:"upstream,omitempty"` // 配置 bridge.upstreams 时桥接客户端发出的帧带上游名称，同一客户端连接多个云端时用于区分
+	Role      string           `json:"role,omitempty"`     // 需要 admin 角色的请求由 serve 写入发送方握手时核实的角色，发送方自带的值被覆盖；bridge 据此再次校验
+	Params    json.RawMessage  `json:"params,omitempty"`
+	Data      json.RawMessage  `json:"data,omitempty"`
+	Status    string           `json:"status,omitempty"`
+	Sealed    *keystore.Sealed `json:"sealed,omitempty"` // 端到端加密时代替 params 或 data
+	Usage     *Usage           `json:"usage,omitempty"`
+}
+
+// Validate 校验必需字段与枚举取值
+func (e *Envelope) Validate() error {
+	if e.Type == "" {
+		return fmt.Errorf("缺少必需字段 type")
+	}
+	switch e.Type {
+	case TypeServerToClient, TypeClientToServer, TypeHeartbeat:
+	default:
+		return fmt.Errorf("type 的取值无效: %q", e.Type)
+	}
+	if e.Action == "" {
+		return fmt.Errorf("缺少必需字段 action")
+	}
+	if e.Status != "" {
+		switch e.Status {
+		case StatusStreaming, StatusDone, StatusError, StatusDuplicate, StatusQueued:
+		default:
+			return fmt.Errorf("status 的取值无效: %q", e.Status)
+		}
+	}
+	return nil
+}
+
+// CloudParams 请求参数
+type CloudParams struct {
+	ModelName   string                 `json:"model_name,omitempty"`
+	Messages    []ChatMessage          `json:"messages,omitempty"`
+	Backend     *BackendStatus         `json:"backend,omitempty"`     // 心跳中携带的后端状态
+	Stream      bool                   `json:"stream,omitempty"`      // 以 streaming 状态的中间帧逐片段返回
+	Credits     int                    `json:"credits,omitempty"`     // 流式响应的初始额度，或 credit 动作追加的额度；0 表示不限
+	JobID       string                 `json:"job_id,omitempty"`      // get_job 查询的任务，或 job_status 与 job_cancel 的排队任务 (即排队请求的 request_id)
+	Destination string                 `json:"destination,omitempty"` // copy_model 的目标名称，源为 model_name
+	SentAt      int64                  `json:"sent_at,omitempty"`     // 心跳的发送时间（Unix 毫秒），云端确认时原样带回
+	Latency     *LatencyStats          `json:"latency,omitempty"`     // 心跳中携带的往返时延
+	Prompt      string                 `json:"prompt,omitempty"`      // generate 的提示词
+	System      string                 `json:"system,omitempty"`      // generate 的系统提示词，覆盖模型自带的；create_model 中覆盖 Modelfile 的 SYSTEM
+	Template    string                 `json:"template,omitempty"`    // generate 的提示词模板，覆盖模型自带的；create_model 中覆盖 Modelfile 的 TEMPLATE
+	Options     map[string]any         `json:"options,omitempty"`     // 原样传给 Ollama 的模型参数，例如 temperature、num_ctx
+	Input       []string               `json:"input,omitempty"`       // embeddings 的输入文本，一次请求可以包含多条，结果按相同顺序返回
+	SessionID   string                 `json:"session_id,omitempty"`  // chat 的会话，bridge 保存历史并拼接在 messages 之前
+	NoCache     bool                   `json:"no_cache,omitempty"`    // chat 与 generate 不读取也不写入 bridge 的结果缓存 (cache.generations)
+	KeepAlive   *api.Duration          `json:"keep_alive,omitempty"`  // chat、generate 与 load_model 之后模型在内存中保留的时长，未指定时按 Ollama 的默认值
+	Tools       api.Tools              `json:"tools,omitempty"`       // chat 可调用的工具，模型决定调用时 done 帧的 data.message 带有 tool_calls
+	Priority    string                 `json:"priority,omitempty"`    // 启用任务队列 (bridge.job_queue) 时的优先级，high、normal 或 batch，未指定时为 normal
+	Modelfile   string                 `json:"modelfile,omitempty"`   // create_model 的 Modelfile 内容，FROM 只接受模型名
+	From        string                 `json:"from,omitempty"`        // create_model 的基础模型，覆盖 Modelfile 的 FROM
+	Parameters  map[string]any         `json:"parameters,omitempty"`  // create_model 的模型参数 (如 temperature、stop)，覆盖 Modelfile 中的同名 PARAMETER
+	Transfer    *wsutils.TransferFrame `json:"transfer,omitempty"`    // file_upload 的一帧 (begin、chunk 或 end)，或 file_download 的 name 与续传的 offset
+}
+
+// ChatMessage 对话消息；工具的执行结果以 role 为 tool 的消息在下一轮发送
+type ChatMessage struct {
+	Role      string         `json:"role"`
+	Content   string         `json:"content"`
+	ToolCalls []api.ToolCall `json:"tool_calls,omitempty"` // 模型在上一轮回复中请求的工具调用，原样带回
+	Images    []string       `json:"images,omitempty"`     // 图片附件，base64 (可带 data:image/...;base64, 前缀) 或 bridge.images.fetch_urls 开启时的 http(s) 地址，供 llava 等视觉模型使用
+}
@@ -0,0 +1,191 @@
+package bridge
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"os"
+	"reflect"
+	"strconv"
+	"testing"
+
+	"ollama_dev/internal/apperr"
+	"ollama_dev/internal/asyncapi"
+	"ollama_dev/internal/config"
+)
+
+// envelope_gen.go 需与 AsyncAPI 文档一致，修改文档后运行 go generate ./internal/bridge
+func TestEnvelopeMatchesAsyncAPI(t *testing.T) {
+	data, err := os.ReadFile("../../api/asyncapi.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	doc, err := asyncapi.Parse(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if doc.Info.Version != strconv.Itoa(ProtocolVersion) {
+		t.Errorf("文档的 info.version 为 %s，协议版本为 %d", doc.Info.Version, ProtocolVersion)
+	}
+	want, err := asyncapi.Generate(doc, "bridge", "api/asyncapi.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := os.ReadFile("envelope_gen.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Fatal("envelope_gen.go 已过期，请运行 go generate ./internal/bridge")
+	}
+}
+
+func TestStrictRejectsFramesViolatingSchema(t *testing.T) {
+	for _, raw := range []string{
+		`{"type":"server_to_client","request_id":"r1"}`,
+		`{"type":"client_to_server","action":"chat","status":"finished"}`,
+	} {
+		if _, err := decodeEnvelope([]byte(raw), true); apperr.CodeOf(err) != apperr.CodeBadFrame {
+			t.Errorf("%s: expected bad_frame, got %v", raw, err)
+		}
+		// 非 strict 时保持兼容，由后续处理决定如何对待
+		if _, err := decodeEnvelope([]byte(raw), false); err != nil {
+			t.Errorf("%s: lenient decode failed: %v", raw, err)
+		}
+	}
+}
+
+func TestParseMessageDecodesRequest(t *testing.T) {
+	// 携带 request_id 的请求帧此前会被误判为响应，且 params 从未解析
+	raw := []byte(`{"type":"server_to_client","action":"chat","request_id":"r1",
+		"params":{"model_name":"llama3","messages":[{"role":"user","content":"hi"}]}}`)
+	msg, err := parseMessage(raw, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer msg.release()
+
+	if msg.Kind != KindRequest {
+		t.Fatalf("expected request, got %s", msg.Kind)
+	}
+	req := msg.Request
+	if req.Action != "chat" || req.RequestID != "r1" || req.Params.ModelName != "llama3" {
+		t.Errorf("unexpected request: %+v", req)
+	}
+	if !reflect.DeepEqual(req.Params.Messages, []ChatMessage{{Role: "user", Content: "hi"}}) {
+		t.Errorf("unexpected messages: %+v", req.Params.Messages)
+	}
+}
+
+func TestResponseEchoesTenant(t *testing.T) {
+	msg, err := parseMessage([]byte(`{"type":"server_to_client","action":"list_model","request_id":"r1","tenant_id":"acme"}`), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer msg.release()
+	resp := newResponse(msg.Request, nil)
+	defer releaseResponse(resp)
+	if resp.TenantID != "acme" {
+		t.Errorf("expected tenant_id echoed, got %q", resp.TenantID)
+	}
+}
+
+func TestChatResponseReportsUsage(t *testing.T) {
+	frame := `{"type":"server_to_client","action":"chat","request_id":"r1","user":"alice","params":{"model_name":"llama3"}}`
+	resps := replayFrames(t, &countingOllama{}, config.Default().Bridge, frame)
+	if len(resps) != 1 || resps[0].Usage == nil {
+		t.Fatalf("expected done frame with usage, got %+v", resps)
+	}
+	// 用户来自请求，模型未由 Ollama 返回时取请求的模型
+	want := Usage{Model: "llama3", User: "alice", PromptTokens: 3, CompletionTokens: 5}
+	if *resps[0].Usage != want {
+		t.Errorf("expected %+v, got %+v", want, *resps[0].Usage)
+	}
+}
+
+func TestParseMessageKinds(t *testing.T) {
+	cases := []struct {
+		frame string
+		kind  Kind
+	}{
+		{`{"type":"client_to_server","action":"chat","request_id":"r1","data":{},"status":"done"}`, KindResponse},
+		{`{"type":"heartbeat","action":"ping","request_id":"r2","params":{"backend":{"healthy":true}}}`, KindHeartbeat},
+		{`{"type":"server_to_client","action":"version","params":null}`, KindRequest},
+	}
+	for _, c := range cases {
+		msg, err := parseMessage([]byte(c.frame), false)
+		if err != nil {
+			t.Fatalf("%s: %v", c.frame, err)
+		}
+		if msg.Kind != c.kind {
+			t.Errorf("%s: expected %s, got %s", c.frame, c.kind, msg.Kind)
+		}
+		msg.release()
+	}
+}
+
+func TestParseMessageRejectsBadFrames(t *testing.T) {
+	cases := []struct {
+		name   string
+		frame  string
+		strict bool
+		id     string
+	}{
+		{"invalid json", `not json`, false, ""},
+		{"trailing data", `{"type":"server_to_client","action":"version"} {}`, false, ""},
+		{"unknown type", `{"type":"mystery","action":"chat","request_id":"r1"}`, false, "r1"},
+		{"unknown field strict", `{"type":"server_to_client","action":"chat","request_id":"r2","extra":1}`, true, "r2"},
+		{"unknown param strict", `{"type":"server_to_client","action":"chat","request_id":"r3","params":{"temperature":1}}`, true, "r3"},
+		{"wrong param type", `{"type":"server_to_client","action":"chat","request_id":"r4","params":{"messages":"hi"}}`, false, "r4"},
+	}
+	for _, c := range cases {
+		msg, err := parseMessage([]byte(c.frame), c.strict)
+		if apperr.CodeOf(err) != apperr.CodeBadFrame {
+			t.Errorf("%s: expected bad_frame, got %v", c.name, err)
+			continue
+		}
+		// 错误帧需要带上已知的 request_id 以便对端关联
+		if msg == nil || msg.Request.RequestID != c.id {
+			t.Errorf("%s: expected request_id %q in rejected message", c.name, c.id)
+		}
+	}
+
+	// 非 strict 时忽略未知字段
+	msg, err := parseMessage([]byte(`{"type":"server_to_client","action":"chat","extra":1,"params":{"temperature":1}}`), false)
+	if err != nil {
+		t.Errorf("lenient decoding should ignore unknown fields: %v", err)
+	} else {
+		msg.release()
+	}
+}
+
+func TestReplayRoutesByKind(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	var out bytes.Buffer
+	cfg := config.Default().Bridge
+	s := NewServer(&replayClient{out: &out}, NewHandlerFactory(&fakeOllama{err: errors.New("connection refused")}, logger), nil, cfg, logger)
+
+	// 响应帧（例如服务端广播回来的自身响应）不处理也不回复
+	if err := s.replay([]byte(`{"type":"client_to_server","action":"chat","request_id":"r0","status":"done"}`)); err != nil {
+		t.Fatal(err)
+	}
+	if out.Len() != 0 {
+		t.Fatalf("response frames must be ignored, got %s", out.String())
+	}
+
+	// 带 model_name 的对话请求应到达 Ollama，而不是因参数未解析报 validation 错误
+	_ = s.replay([]byte(`{"type":"server_to_client","action":"chat","request_id":"r1","params":{"model_name":"llama3"}}`))
+	var resp struct {
+		RequestID string      `json:"request_id"`
+		Status    string      `json:"status"`
+		Data      apperr.Data `json:"data"`
+	}
+	if err := json.Unmarshal(out.Bytes(), &resp); err != nil {
+		t.Fatalf("decode frame: %v", err)
+	}
+	if resp.RequestID != "r1" || resp.Status != "error" || resp.Data.Category != apperr.Backend {
+		t.Errorf("unexpected response: %+v", resp)
+	}
+}
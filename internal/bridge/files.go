@@ -0,0 +1,108 @@
+package bridge
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+
+	"ollama_dev/internal/apperr"
+	"ollama_dev/internal/util/wsutils"
+)
+
+// fileActions 文件传输相关的动作
+var fileActions = []string{"file_upload", "file_download"}
+
+// SetFiles 启用 file_upload 与 file_download 动作，在 d 中收发文件；d 为 nil 时不启用
+func (f *HandlerFactory) SetFiles(d *wsutils.TransferDir) {
+	f.files = d
+}
+
+// FileHandler 按 wsutils 的分块传输协议收发 bridge.files.dir 下的文件，params.transfer 为 TransferFrame：
+// file_upload 逐帧接收云端推送的文件 (begin、chunk、end 各为一次请求，回复的 offset 为下一个分块的偏移)，
+// 用于下发 Modelfile 等；file_download 以 streaming 帧发送 begin 与各 chunk，done 帧为 end，
+// 用于取回日志与产物，未指定 params.stream 时只回复 begin (大小与 SHA-256)
+type FileHandler struct {
+	dir *wsutils.TransferDir
+}
+
+func NewFileHandler(d *wsutils.TransferDir) *FileHandler {
+	return &FileHandler{dir: d}
+}
+
+func (h *FileHandler) Handle(ctx context.Context, req *CloudRequest) (*CloudResponse, error) {
+	t := req.Params.Transfer
+	if t == nil {
+		return nil, apperr.New(apperr.Validation, apperr.CodeInvalidParams, "transfer 不能为空")
+	}
+	if req.Action == "file_download" {
+		begin, err := h.dir.Stat(transferID(req), t.Name)
+		if err != nil {
+			return nil, transferError(err, "读取文件失败")
+		}
+		begin.Offset = t.Offset
+		return newResponse(req, begin), nil
+	}
+
+	var (
+		reply wsutils.TransferFrame
+		err   error
+	)
+	switch t.Phase {
+	case wsutils.TransferBegin:
+		reply, err = h.dir.Begin(*t)
+	case wsutils.TransferChunk:
+		var next int64
+		next, err = h.dir.Chunk(*t)
+		reply = wsutils.TransferFrame{ID: t.ID, Phase: wsutils.TransferChunk, Offset: next}
+	case wsutils.TransferEnd:
+		reply, err = h.dir.End(*t)
+	default:
+		return nil, apperr.New(apperr.Validation, apperr.CodeInvalidParams, "未知的 transfer.phase: "+t.Phase)
+	}
+	if err != nil {
+		return nil, transferError(err, "接收文件失败")
+	}
+	return newResponse(req, reply), nil
+}
+
+// HandleStream file_download 从 params.transfer.offset 开始发送，file_upload 不分片，直接回复
+func (h *FileHandler) HandleStream(ctx context.Context, req *CloudRequest, emit func(data any) error) (*CloudResponse, error) {
+	t := req.Params.Transfer
+	if req.Action != "file_download" || t == nil {
+		return h.Handle(ctx, req)
+	}
+	end, err := h.dir.Send(ctx, transferID(req), t.Name, t.Offset, func(f wsutils.TransferFrame) error {
+		return emit(f)
+	})
+	if err != nil {
+		return nil, transferError(err, "发送文件失败")
+	}
+	return newResponse(req, end), nil
+}
+
+// transferID 下载未指定 transfer.id 时使用 request_id
+func transferID(req *CloudRequest) string {
+	if req.Params.Transfer.ID != "" {
+		return req.Params.Transfer.ID
+	}
+	return req.RequestID
+}
+
+// transferError 将传输错误归类：文件或传输不存在为 not_found，路径、大小、偏移与校验和问题为 invalid_params
+func transferError(err error, msg string) error {
+	var e *apperr.Error
+	switch {
+	case errors.As(err, &e):
+		return err
+	case errors.Is(err, context.Canceled):
+		return err
+	case errors.Is(err, context.DeadlineExceeded):
+		return apperr.Wrap(err, apperr.Timeout, apperr.CodeTimeout, msg+"：超过处理时限")
+	case errors.Is(err, fs.ErrNotExist), errors.Is(err, wsutils.ErrTransferUnknown):
+		return apperr.Wrap(err, apperr.Validation, apperr.CodeNotFound, msg+": "+err.Error())
+	case errors.Is(err, wsutils.ErrTransferPath), errors.Is(err, wsutils.ErrTransferTooLarge),
+		errors.Is(err, wsutils.ErrTransferOffset), errors.Is(err, wsutils.ErrTransferChecksum):
+		return apperr.Wrap(err, apperr.Validation, apperr.CodeInvalidParams, msg+": "+err.Error())
+	}
+	return apperr.Wrap(err, apperr.Internal, apperr.CodeInternal, msg)
+}
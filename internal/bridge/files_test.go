@@ -0,0 +1,121 @@
+package bridge
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"ollama_dev/internal/apperr"
+	"ollama_dev/internal/config"
+	"ollama_dev/internal/janitor"
+	"ollama_dev/internal/util/wsutils"
+)
+
+func TestFileUploadAndDownload(t *testing.T) {
+	dir := t.TempDir()
+	files, err := wsutils.NewTransferDir(dir, 1<<20, 64)
+	if err != nil {
+		t.Fatal(err)
+	}
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	factory := NewHandlerFactory(&fakeOllama{}, logger)
+	factory.SetFiles(files)
+	if !slices.Contains(factory.Actions(), "file_upload") || factory.NeedsBackend("file_download") {
+		t.Fatalf("expected file actions without a backend, got %v", factory.Actions())
+	}
+
+	// 云端按 begin、chunk、end 逐帧下发，帧经 JSON 编码，data 为 base64
+	content := []byte("FROM llama3\nPARAMETER temperature 0.2\nSYSTEM \"你是运维助手\"\n")
+	sum, _ := wsutils.Checksum(bytes.NewReader(content))
+	handle := func(action string, t0 wsutils.TransferFrame) (*CloudResponse, error) {
+		raw, _ := json.Marshal(map[string]any{"action": action, "request_id": "r", "role": "admin", "params": map[string]any{"transfer": t0}})
+		var req CloudRequest
+		if err := json.Unmarshal(raw, &req); err != nil {
+			t.Fatal(err)
+		}
+		return factory.CreateHandler(action).Handle(context.Background(), &req)
+	}
+	steps := []wsutils.TransferFrame{
+		{ID: "up", Phase: wsutils.TransferBegin, Name: "Modelfile", Size: int64(len(content)), SHA256: sum},
+		wsutils.NewChunk("up", 0, content[:20]),
+		wsutils.NewChunk("up", 20, content[20:]),
+		{ID: "up", Phase: wsutils.TransferEnd},
+	}
+	for _, step := range steps {
+		if _, err := handle("file_upload", step); err != nil {
+			t.Fatalf("%s: %v", step.Phase, err)
+		}
+	}
+	if got, _ := os.ReadFile(filepath.Join(dir, "Modelfile")); !bytes.Equal(got, content) {
+		t.Fatalf("uploaded file = %q", got)
+	}
+	if _, err := handle("file_upload", wsutils.TransferFrame{ID: "x", Phase: wsutils.TransferBegin, Name: "../x", Size: 1, SHA256: sum}); apperr.CodeOf(err) != apperr.CodeInvalidParams {
+		t.Errorf("expected invalid_params for a path outside the directory, got %v", err)
+	}
+	if _, err := handle("file_download", wsutils.TransferFrame{Name: "missing.log"}); apperr.CodeOf(err) != apperr.CodeNotFound {
+		t.Errorf("expected not_found for a missing file, got %v", err)
+	}
+
+	// 流式下载：streaming 帧依次为 begin 与各 chunk，done 帧为 end
+	ws := &syncWSClient{wrote: make(chan struct{}, 100)}
+	s := NewServer(ws, factory, nil, config.Default().Bridge, logger)
+	req := &CloudRequest{Type: TypeServerToClient, Action: "file_download", RequestID: "down", Role: "admin", Params: CloudParams{Stream: true, Transfer: &wsutils.TransferFrame{Name: "Modelfile"}}}
+	if err := s.handleServerRequest(&Message{Request: req}); err != nil {
+		t.Fatal(err)
+	}
+	waitUntil(t, ws, "download done", func() bool {
+		frames := ws.statuses("down")
+		return len(frames) > 0 && frames[len(frames)-1].Status != StatusStreaming
+	})
+	var received []byte
+	frames := ws.statuses("down")
+	for i, f := range frames {
+		var tf wsutils.TransferFrame
+		if err := json.Unmarshal(f.Data, &tf); err != nil {
+			t.Fatal(err)
+		}
+		switch {
+		case i == 0 && tf.Phase == wsutils.TransferBegin:
+		case f.Status == StatusStreaming && tf.Phase == wsutils.TransferChunk && tf.Verify() == nil:
+			received = append(received, tf.Data...)
+		case f.Status == StatusDone && tf.Phase == wsutils.TransferEnd && tf.SHA256 == sum:
+		default:
+			t.Fatalf("unexpected frame %d: %s %+v", i, f.Status, tf)
+		}
+	}
+	if len(frames) != 4 || !bytes.Equal(received, content) {
+		t.Errorf("downloaded %d frames, content %q", len(frames), received)
+	}
+}
+
+func TestJanitorSweepsAbandonedUploads(t *testing.T) {
+	dir := t.TempDir()
+	files, err := wsutils.NewTransferDir(dir, 1<<20, 64)
+	if err != nil {
+		t.Fatal(err)
+	}
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	factory := NewHandlerFactory(&fakeOllama{}, logger)
+	factory.SetFiles(files)
+	s := NewServer(&fakeWSClient{}, factory, nil, config.Default().Bridge, logger)
+	j := janitor.New(time.Minute)
+	s.RegisterSweepers(j)
+
+	sum, _ := wsutils.Checksum(bytes.NewReader([]byte("hello")))
+	if _, err := files.Begin(wsutils.TransferFrame{ID: "u1", Name: "a.txt", Size: 5, SHA256: sum}); err != nil {
+		t.Fatal(err)
+	}
+	if n := j.Sweep(time.Now().Add(2 * time.Hour))["transfers"]; n != 1 {
+		t.Errorf("transfers sweeper removed %d files, want 1", n)
+	}
+	if parts, _ := filepath.Glob(filepath.Join(dir, "*.part")); len(parts) != 0 {
+		t.Errorf("abandoned upload left %v", parts)
+	}
+}
@@ -0,0 +1,136 @@
+package bridge
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"ollama_dev/internal/apperr"
+)
+
+// ActionCredit 云端为流式响应追加额度的动作，params.credits 为追加的分片数
+const ActionCredit = "credit"
+
+// errStreamClosed 连接断开时等待额度的流被终止
+var errStreamClosed = errors.New("连接已关闭")
+
+// creditWindow 一个流式响应的剩余额度，每发送一个分片消耗 1，耗尽时暂停生成直到云端追加额度
+type creditWindow struct {
+	mu      sync.Mutex
+	credits int
+	closed  bool
+	wake    chan struct{} // 追加额度或关闭时非阻塞通知
+}
+
+func newCreditWindow(initial int) *creditWindow {
+	return &creditWindow{credits: initial, wake: make(chan struct{}, 1)}
+}
+
+// grant 追加额度
+func (w *creditWindow) grant(n int) {
+	w.mu.Lock()
+	w.credits += n
+	w.mu.Unlock()
+	w.notify()
+}
+
+// close 终止等待
+func (w *creditWindow) close() {
+	w.mu.Lock()
+	w.closed = true
+	w.mu.Unlock()
+	w.notify()
+}
+
+func (w *creditWindow) notify() {
+	select {
+	case w.wake <- struct{}{}:
+	default:
+	}
+}
+
+// acquire 消耗 1 个额度，额度耗尽时最多等待 timeout
+func (w *creditWindow) acquire(timeout time.Duration) error {
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	for {
+		w.mu.Lock()
+		switch {
+		case w.closed:
+			w.mu.Unlock()
+			return errStreamClosed
+		case w.credits > 0:
+			w.credits--
+			w.mu.Unlock()
+			return nil
+		}
+		w.mu.Unlock()
+
+		select {
+		case <-w.wake:
+		case <-timer.C:
+			return apperr.New(apperr.Timeout, apperr.CodeTimeout, fmt.Sprintf("等待流控额度超时 (%s)", timeout))
+		}
+	}
+}
+
+// streamRegistry 进行中的流式响应，按 request_id 查找以便追加额度
+type streamRegistry struct {
+	mu      sync.Mutex
+	windows map[string]*creditWindow
+}
+
+func newStreamRegistry() *streamRegistry {
+	return &streamRegistry{windows: make(map[string]*creditWindow)}
+}
+
+// open 登记流式响应，initial 不大于 0 时不做流控，返回 nil
+func (r *streamRegistry) open(id string, initial int) *creditWindow {
+	if initial <= 0 || id == "" {
+		return nil
+	}
+	w := newCreditWindow(initial)
+	r.mu.Lock()
+	r.windows[id] = w
+	r.mu.Unlock()
+	return w
+}
+
+// done 流式响应结束
+func (r *streamRegistry) done(id string) {
+	r.mu.Lock()
+	delete(r.windows, id)
+	r.mu.Unlock()
+}
+
+// grant 为 request_id 对应的流追加额度，流不存在时返回 false
+func (r *streamRegistry) grant(id string, n int) bool {
+	r.mu.Lock()
+	w, ok := r.windows[id]
+	r.mu.Unlock()
+	if ok {
+		w.grant(n)
+	}
+	return ok
+}
+
+// close 终止 request_id 对应的流等待额度，流不存在时忽略
+func (r *streamRegistry) close(id string) {
+	r.mu.Lock()
+	w, ok := r.windows[id]
+	r.mu.Unlock()
+	if ok {
+		w.close()
+	}
+}
+
+// closeAll 连接断开时终止所有等待额度的流
+func (r *streamRegistry) closeAll() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for id, w := range r.windows {
+		w.close()
+		delete(r.windows, id)
+	}
+}
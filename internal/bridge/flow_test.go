@@ -0,0 +1,285 @@
+package bridge
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"ollama_dev/internal/apperr"
+	"ollama_dev/internal/config"
+	"ollama_dev/internal/policy"
+)
+
+// chanWSClient 将写出的帧送入 channel，可被流式 goroutine 并发写入
+type chanWSClient struct {
+	frames chan CloudResponse
+}
+
+func (c *chanWSClient) Connect(url string) error     { return nil }
+func (c *chanWSClient) ReadMessage() ([]byte, error) { return nil, io.EOF }
+func (c *chanWSClient) Close() error                 { return nil }
+func (c *chanWSClient) Conn() *websocket.Conn        { return nil }
+func (c *chanWSClient) WriteMessage(message []byte) error {
+	var resp CloudResponse
+	if err := json.Unmarshal(message, &resp); err != nil {
+		return err
+	}
+	c.frames <- resp
+	return nil
+}
+
+// streamingOllama 依次输出 chunks，记录最近一次 chat 与 generate 的参数
+type streamingOllama struct {
+	fakeOllama
+	chunks   []string
+	chat     ChatRequest
+	generate GenerateRequest
+}
+
+func (s *streamingOllama) Chat(ctx context.Context, req ChatRequest) (Reply, error) {
+	s.chat = req
+	return Reply{Content: strings.Join(s.chunks, "")}, nil
+}
+
+func (s *streamingOllama) Generate(ctx context.Context, req GenerateRequest, onChunk func(string) error) (Reply, error) {
+	s.generate = req
+	if onChunk == nil {
+		return Reply{Content: strings.Join(s.chunks, ""), Usage: Usage{PromptTokens: 2, CompletionTokens: len(s.chunks)}}, nil
+	}
+	return s.ChatStream(ctx, ChatRequest{Model: req.Model}, onChunk)
+}
+
+func (s *streamingOllama) ChatStream(ctx context.Context, req ChatRequest, onChunk func(string) error) (Reply, error) {
+	var full string
+	for _, c := range s.chunks {
+		if err := onChunk(c); err != nil {
+			return Reply{Content: full}, err
+		}
+		full += c
+	}
+	return Reply{Content: full}, nil
+}
+
+func newStreamServer(t *testing.T, cfg config.BridgeConfig, chunks ...string) (*Server, *chanWSClient) {
+	t.Helper()
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	ws := &chanWSClient{frames: make(chan CloudResponse, 16)}
+	return NewServer(ws, NewHandlerFactory(&streamingOllama{chunks: chunks}, logger), nil, cfg, logger), ws
+}
+
+func expectFrame(t *testing.T, ws *chanWSClient) CloudResponse {
+	t.Helper()
+	select {
+	case f := <-ws.frames:
+		return f
+	case <-time.After(2 * time.Second):
+		t.Fatal("timed out waiting for frame")
+		return CloudResponse{}
+	}
+}
+
+func expectNoFrame(t *testing.T, ws *chanWSClient) {
+	t.Helper()
+	select {
+	case f := <-ws.frames:
+		t.Fatalf("unexpected frame while out of credits: %+v", f)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func streamRequest(credits int) *Message {
+	req := acquireRequest()
+	req.Type, req.Action, req.RequestID = TypeServerToClient, "chat", "s1"
+	req.Params.ModelName, req.Params.Stream, req.Params.Credits = "llama3", true, credits
+	return &Message{Kind: KindRequest, Request: req}
+}
+
+func TestStreamPausesWhenCreditsExhausted(t *testing.T) {
+	s, ws := newStreamServer(t, config.Default().Bridge, "a", "b", "c")
+	if err := s.handleServerRequest(streamRequest(2)); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, want := range []string{"a", "b"} {
+		f := expectFrame(t, ws)
+		if f.Status != StatusStreaming || f.Data.(map[string]any)["message"].(map[string]any)["content"] != want {
+			t.Fatalf("unexpected chunk: %+v", f)
+		}
+	}
+	// 额度耗尽，暂停发送
+	expectNoFrame(t, ws)
+
+	credit := acquireRequest()
+	credit.Type, credit.Action, credit.RequestID, credit.Params.Credits = TypeServerToClient, ActionCredit, "s1", 5
+	if err := s.handleServerRequest(&Message{Kind: KindRequest, Request: credit}); err != nil {
+		t.Fatal(err)
+	}
+
+	if f := expectFrame(t, ws); f.Status != StatusStreaming {
+		t.Fatalf("expected third chunk, got %+v", f)
+	}
+	done := expectFrame(t, ws)
+	if done.Status != "done" || done.Data.(map[string]any)["message"].(map[string]any)["content"] != "abc" {
+		t.Fatalf("final frame should carry the full reply: %+v", done)
+	}
+}
+
+func TestStreamCreditTimeout(t *testing.T) {
+	cfg := config.Default().Bridge
+	cfg.CreditTimeout = 20 * time.Millisecond
+	s, ws := newStreamServer(t, cfg, "a", "b")
+	if err := s.handleServerRequest(streamRequest(1)); err != nil {
+		t.Fatal(err)
+	}
+
+	expectFrame(t, ws)
+	f := expectFrame(t, ws)
+	data, _ := json.Marshal(f.Data)
+	var errData apperr.Data
+	_ = json.Unmarshal(data, &errData)
+	if f.Status != "error" || errData.Category != apperr.Timeout {
+		t.Fatalf("expected timeout error frame, got %+v", f)
+	}
+}
+
+func TestStreamWithoutCredits(t *testing.T) {
+	// credits 为 0 时不做流控
+	s, ws := newStreamServer(t, config.Default().Bridge, "a", "b", "c")
+	if err := s.handleServerRequest(streamRequest(0)); err != nil {
+		t.Fatal(err)
+	}
+	for range 3 {
+		expectFrame(t, ws)
+	}
+	if f := expectFrame(t, ws); f.Status != "done" {
+		t.Fatalf("expected done frame, got %+v", f)
+	}
+}
+
+func TestCancelStreamWaitingForCredits(t *testing.T) {
+	s, ws := newStreamServer(t, config.Default().Bridge, "a", "b")
+	if err := s.handleServerRequest(streamRequest(1)); err != nil {
+		t.Fatal(err)
+	}
+	expectFrame(t, ws)
+
+	// 额度耗尽时取消，流停止等待并回复 cancelled
+	cancel := acquireRequest()
+	cancel.Type, cancel.Action, cancel.RequestID = TypeServerToClient, ActionCancel, "s1"
+	if err := s.handleServerRequest(&Message{Kind: KindRequest, Request: cancel}); err != nil {
+		t.Fatal(err)
+	}
+	f := expectFrame(t, ws)
+	data, _ := json.Marshal(f.Data)
+	var errData apperr.Data
+	_ = json.Unmarshal(data, &errData)
+	if f.Status != StatusError || errData.Code != apperr.CodeCancelled {
+		t.Fatalf("expected cancelled error frame, got %+v", f)
+	}
+}
+
+func TestClosedWindowStopsWaiting(t *testing.T) {
+	w := newStreamRegistry().open("s1", 1)
+	if err := w.acquire(time.Second); err != nil {
+		t.Fatal(err)
+	}
+	go w.close()
+	if err := w.acquire(time.Second); err != errStreamClosed {
+		t.Errorf("expected errStreamClosed, got %v", err)
+	}
+}
+
+func TestGenerateAction(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	ollama := &streamingOllama{chunks: []string{"foo", "bar"}}
+	frame := `{"type":"server_to_client","action":"generate","request_id":"g1","user":"alice",` +
+		`"params":{"model_name":"llama3","prompt":"hi","system":"be brief","template":"{{ .Prompt }}","options":{"temperature":0.2}}}`
+	resps := replayFrames(t, ollama, config.Default().Bridge, frame)
+
+	want := GenerateRequest{Model: "llama3", Prompt: "hi", System: "be brief", Template: "{{ .Prompt }}", Options: map[string]any{"temperature": 0.2}}
+	if !reflect.DeepEqual(ollama.generate, want) {
+		t.Errorf("unexpected generate request %+v", ollama.generate)
+	}
+	if len(resps) != 1 || resps[0].Status != StatusDone || resps[0].Data.(map[string]any)["response"] != "foobar" {
+		t.Fatalf("unexpected responses %+v", resps)
+	}
+	if u := resps[0].Usage; u == nil || u.User != "alice" || u.Model != "llama3" || u.CompletionTokens != 2 {
+		t.Errorf("unexpected usage %+v", u)
+	}
+
+	// 流式请求逐片段返回
+	ws := &chanWSClient{frames: make(chan CloudResponse, 16)}
+	s := NewServer(ws, NewHandlerFactory(ollama, logger), nil, config.Default().Bridge, logger)
+	req := acquireRequest()
+	req.Type, req.Action, req.RequestID = TypeServerToClient, "generate", "g2"
+	req.Params.ModelName, req.Params.Stream = "llama3", true
+	if err := s.handleServerRequest(&Message{Kind: KindRequest, Request: req}); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"foo", "bar"} {
+		if f := expectFrame(t, ws); f.Status != StatusStreaming || f.Data.(map[string]any)["response"] != want {
+			t.Fatalf("unexpected chunk %+v", f)
+		}
+	}
+	if f := expectFrame(t, ws); f.Status != StatusDone || f.Data.(map[string]any)["response"] != "foobar" {
+		t.Fatalf("unexpected final frame %+v", f)
+	}
+}
+
+func TestPolicyRejectsRequests(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	path := filepath.Join(t.TempDir(), "policy.yaml")
+	if err := os.WriteFile(path, []byte("models:\n  - name: llama3\n    max_num_predict: 64\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	p, err := policy.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ollama := &streamingOllama{chunks: []string{"ok"}}
+	factory := NewHandlerFactory(ollama, logger)
+	factory.SetPolicy(p)
+
+	cases := []struct {
+		action  string
+		model   string
+		options map[string]any
+		code    string
+	}{
+		{"generate", "llama3", nil, ""},
+		{"generate", "mistral", nil, apperr.CodeModelNotAllowed},
+		{"generate", "llama3", map[string]any{"num_predict": 128.0}, apperr.CodePolicyViolation},
+		{"chat", "llama3", nil, ""},
+		{"chat", "mistral", nil, apperr.CodeModelNotAllowed},
+		{"chat", "llama3", map[string]any{"num_predict": 128.0}, apperr.CodePolicyViolation},
+		{"embeddings", "llama3", nil, ""},
+		{"embeddings", "mistral", nil, apperr.CodeModelNotAllowed},
+	}
+	for _, c := range cases {
+		req := &CloudRequest{Action: c.action, RequestID: "p1"}
+		req.Params.ModelName, req.Params.Options = c.model, c.options
+		if c.action == "embeddings" {
+			req.Params.Input = []string{"hello"}
+		}
+		_, err := factory.CreateHandler(c.action).Handle(context.Background(), req)
+		if got := apperr.CodeOf(err); got != c.code {
+			t.Errorf("%s %s %v: expected %q, got %v", c.action, c.model, c.options, c.code, err)
+		}
+	}
+	// 未指定 num_predict 时按策略的上限生成
+	if got := ollama.generate.Options["num_predict"]; got != 64 {
+		t.Errorf("expected num_predict 64, got %v", got)
+	}
+	if got := ollama.chat.Options["num_predict"]; got != 64 {
+		t.Errorf("expected chat num_predict 64, got %v", got)
+	}
+}
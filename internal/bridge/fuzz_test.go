@@ -0,0 +1,162 @@
+package bridge
+
+import (
+	"bytes"
+	"encoding/json"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"ollama_dev/internal/apperr"
+	"ollama_dev/internal/config"
+	"ollama_dev/internal/keystore"
+	"ollama_dev/internal/util"
+)
+
+// recordedFrames 读取 testdata 中 bridge --record 录制的帧，作为模糊测试的种子
+func recordedFrames(f *testing.F) [][]byte {
+	f.Helper()
+	records, err := ReadRecords(filepath.Join("testdata", "recorded.jsonl"))
+	if err != nil {
+		f.Fatal(err)
+	}
+	frames := make([][]byte, 0, len(records)+2)
+	for _, r := range records {
+		frames = append(frames, []byte(r.Frame))
+	}
+	// 录制中没有的旧版帧与分片帧
+	frames = append(frames,
+		[]byte(`{"type":"client_to_server","action":"list_model","data":[{"name":"llama3","status":"sha256:abc"}]}`),
+		[]byte(`{"v":2,"type":"server_to_client","action":"part","request_id":"r1","part":{"id":"p","seq":0,"total":2,"data":"e30="}}`),
+	)
+	return frames
+}
+
+func FuzzParseMessage(f *testing.F) {
+	for _, frame := range recordedFrames(f) {
+		f.Add(frame)
+	}
+	f.Fuzz(func(t *testing.T, raw []byte) {
+		for _, strict := range []bool{false, true} {
+			msg, err := parseMessage(raw, strict)
+			if msg == nil || msg.Request == nil {
+				t.Fatalf("parseMessage should always return a message carrying the known ids (strict=%v)", strict)
+			}
+			if err != nil {
+				// 解析失败只能是协议错误，读取循环据此回复 bad_frame 而不断开连接
+				if !apperr.Is(err, apperr.Protocol) {
+					t.Fatalf("expected a protocol error, got %v (strict=%v)", err, strict)
+				}
+				if _, mErr := json.Marshal(errorResponse(msg.Request, err)); mErr != nil {
+					t.Fatalf("error frame cannot be encoded: %v", mErr)
+				}
+			} else if msg.Kind.String() == "unknown" {
+				t.Fatalf("parsed frame has no kind: %q", raw)
+			}
+			msg.release()
+		}
+	})
+}
+
+func FuzzReassembler(f *testing.F) {
+	for _, frame := range recordedFrames(f) {
+		parts, err := SplitFrame(frame, partOverhead+64)
+		if err != nil {
+			f.Fatal(err)
+		}
+		parts = append(parts, nil, nil)
+		f.Add(parts[0], parts[1], parts[2])
+	}
+	// total 过大时曾按其分配索引，导致内存耗尽
+	f.Add([]byte(`{"action":"part","part":{"id":"x","seq":0,"total":4000000000000,"data":"e30="}}`), []byte(nil), []byte(nil))
+	f.Fuzz(func(t *testing.T, a, b, c []byte) {
+		cfg := config.Default().Chunking
+		cfg.MaxMessageSize = 4096
+		r := NewReassembler(cfg)
+		for _, frame := range [][]byte{a, b, c} {
+			msg, err := r.Add(frame)
+			if err != nil && !apperr.Is(err, apperr.Protocol) {
+				t.Fatalf("expected a protocol error, got %v", err)
+			}
+			// 非分片帧原样返回，重组出的消息不超过上限
+			if msg != nil && !bytes.Equal(msg, frame) && len(msg) > cfg.MaxMessageSize {
+				t.Fatalf("reassembled %d bytes, limit %d", len(msg), cfg.MaxMessageSize)
+			}
+		}
+	})
+}
+
+func FuzzSplitReassemble(f *testing.F) {
+	for _, frame := range recordedFrames(f) {
+		f.Add(frame, uint16(0))
+	}
+	f.Fuzz(func(t *testing.T, msg []byte, size uint16) {
+		maxFrameSize := partOverhead + 2 + int(size)%2048
+		frames, err := SplitFrame(msg, maxFrameSize)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(frames) == 1 {
+			return // 未拆分的消息原样发送，本身就是分片帧时按分片处理
+		}
+		r := NewReassembler(config.ChunkingConfig{Timeout: time.Minute})
+		var got []byte
+		for i, frame := range frames {
+			out, err := r.Add(frame)
+			if err != nil {
+				t.Fatalf("part %d: %v", i, err)
+			}
+			if out != nil {
+				if i != len(frames)-1 {
+					t.Fatalf("message completed after part %d of %d", i, len(frames))
+				}
+				got = out
+			}
+		}
+		if !bytes.Equal(got, msg) {
+			t.Fatalf("round trip changed the message: %q -> %q", msg, got)
+		}
+	})
+}
+
+func FuzzOpenSealedRequest(f *testing.F) {
+	keys, err := keystore.Open(filepath.Join(f.TempDir(), "keys.json"), []byte("test"))
+	if err != nil {
+		f.Fatal(err)
+	}
+	if _, err := keys.Rotate("acme", 0); err != nil {
+		f.Fatal(err)
+	}
+	// 以录制帧的 params 构造合法的加密请求，再加上篡改 payload 的版本
+	for _, frame := range recordedFrames(f) {
+		var env Envelope
+		if json.Unmarshal(frame, &env) != nil || env.Type != TypeServerToClient {
+			continue
+		}
+		sealed, err := keys.Seal(util.AESGCM, "acme", keystore.AAD("acme", env.RequestID), env.Params)
+		if err != nil {
+			f.Fatal(err)
+		}
+		env.TenantID, env.Params, env.Sealed = "acme", nil, sealed
+		valid, _ := json.Marshal(env)
+		f.Add(valid)
+		env.Sealed.Payload = "A" + sealed.Payload[1:]
+		tampered, _ := json.Marshal(env)
+		f.Add(tampered)
+	}
+	e := &e2e{keys: keys, cipher: util.AESGCM}
+	f.Fuzz(func(t *testing.T, raw []byte) {
+		msg, err := parseFrame(raw, false, e, nil)
+		defer msg.release()
+		if err != nil {
+			if !apperr.Is(err, apperr.Protocol) && !apperr.Is(err, apperr.Auth) {
+				t.Fatalf("expected a protocol or auth error, got %v", err)
+			}
+			return
+		}
+		// 启用加密后只有解密成功的请求（或流控额度帧）能通过
+		if msg.Kind == KindRequest && !msg.Request.sealed && msg.Request.Action != ActionCredit {
+			t.Fatalf("plaintext request accepted with e2e enabled: %q", raw)
+		}
+	})
+}
@@ -0,0 +1,135 @@
+package bridge
+
+import (
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"strings"
+	"sync"
+	"time"
+
+	"ollama_dev/internal/auth"
+	"ollama_dev/internal/config"
+	"ollama_dev/internal/metrics"
+	"ollama_dev/internal/tenant"
+)
+
+// generationCacheRequests 生成结果缓存的查询次数
+var generationCacheRequests = metrics.NewCounter("ollama_dev_generation_cache_requests_total",
+	"chat 与 generate 结果缓存的查询次数，result 为 hit 或 miss", "result")
+
+// generationCache 按 hash(租户与调用方, 模型, 消息或提示词, tools 与 options) 缓存 temperature 为 0 的 chat 与 generate 的完整回复，
+// 超过 max_entries 时淘汰最久未使用的条目，条目在写入 ttl 后过期
+type generationCache struct {
+	ttl        time.Duration
+	maxEntries int
+
+	mu      sync.Mutex
+	order   *list.List // 最近使用的在前
+	entries map[string]*list.Element
+}
+
+type generationEntry struct {
+	key     string
+	reply   Reply
+	expires time.Time
+}
+
+// newGenerationCache max_entries 为 0 时返回 nil，表示不缓存
+func newGenerationCache(cfg config.GenerationCacheConfig) *generationCache {
+	if cfg.MaxEntries <= 0 {
+		return nil
+	}
+	return &generationCache{ttl: cfg.TTL, maxEntries: cfg.MaxEntries, order: list.New(), entries: map[string]*list.Element{}}
+}
+
+// generationKey 计算缓存键，参与计算的字段相同即视为相同的请求；键包含 ctx 中的租户与调用方 (JWT 的 sub)，
+// 不同租户或调用方的相同请求互不命中，缓存不会泄露其他调用方的回复，命中与否也无从探知其他调用方的提示词
+func generationKey(ctx context.Context, op string, v any) string {
+	id, _ := auth.FromContext(ctx)
+	data, _ := json.Marshal(v) // map 的键按字典序编码，options 的顺序不影响结果
+	scope := strings.Join([]string{op, tenant.FromContext(ctx), id.Subject, ""}, "\x00")
+	sum := sha256.Sum256(append([]byte(scope), data...))
+	return hex.EncodeToString(sum[:])
+}
+
+// chatKey 请求要求跳过缓存或结果不确定时返回空串；keep_alive 不影响生成结果，不参与计算
+func chatKey(ctx context.Context, req ChatRequest) string {
+	if req.NoCache || !deterministic(req.Options) {
+		return ""
+	}
+	req.KeepAlive = nil
+	return generationKey(ctx, "chat", req)
+}
+
+// generateKey 与 chatKey 相同
+func generateKey(ctx context.Context, req GenerateRequest) string {
+	if req.NoCache || !deterministic(req.Options) {
+		return ""
+	}
+	req.KeepAlive = nil
+	return generationKey(ctx, "generate", req)
+}
+
+// deterministic options 的 temperature 为 0 时同样的输入得到同样的结果，只有这样的请求参与缓存
+func deterministic(options map[string]any) bool {
+	switch t := options["temperature"].(type) {
+	case float64:
+		return t == 0
+	case float32:
+		return t == 0
+	case int:
+		return t == 0
+	case int64:
+		return t == 0
+	}
+	return false
+}
+
+// get 返回未过期的缓存回复，命中时 usage 只保留模型名：缓存的回复不消耗 token，不计入用量与配额；
+// c 为 nil 或 key 为空时总是未命中
+func (c *generationCache) get(key string) (Reply, bool) {
+	if c == nil || key == "" {
+		return Reply{}, false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	el, ok := c.entries[key]
+	if ok && time.Now().After(el.Value.(*generationEntry).expires) {
+		c.order.Remove(el)
+		delete(c.entries, key)
+		ok = false
+	}
+	if !ok {
+		generationCacheRequests.Inc("miss")
+		return Reply{}, false
+	}
+	generationCacheRequests.Inc("hit")
+	c.order.MoveToFront(el)
+	reply := el.Value.(*generationEntry).reply
+	reply.Usage = Usage{Model: reply.Usage.Model}
+	return reply, true
+}
+
+// put 保存成功的回复，key 为空时不保存
+func (c *generationCache) put(key string, reply Reply) {
+	if c == nil || key == "" {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry := &generationEntry{key: key, reply: reply, expires: time.Now().Add(c.ttl)}
+	if el, ok := c.entries[key]; ok {
+		el.Value = entry
+		c.order.MoveToFront(el)
+		return
+	}
+	c.entries[key] = c.order.PushFront(entry)
+	for c.order.Len() > c.maxEntries {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*generationEntry).key)
+	}
+}
@@ -0,0 +1,148 @@
+package bridge
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ollama/ollama/api"
+
+	"ollama_dev/internal/auth"
+	"ollama_dev/internal/config"
+	"ollama_dev/internal/tenant"
+)
+
+// countingOllamaServer 统计 /api/chat 与 /api/generate 的调用次数，回复为第几次调用，用量固定为 3+2
+func countingOllamaServer(t *testing.T, calls *atomic.Int64) *DefaultOllamaClient {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := calls.Add(1)
+		content := string(rune('0' + n))
+		metrics := api.Metrics{PromptEvalCount: 3, EvalCount: 2}
+		switch r.URL.Path {
+		case "/api/chat":
+			json.NewEncoder(w).Encode(api.ChatResponse{Message: api.Message{Role: "assistant", Content: content}, Done: true, Metrics: metrics})
+		case "/api/generate":
+			json.NewEncoder(w).Encode(api.GenerateResponse{Response: content, Done: true, Metrics: metrics})
+		}
+	}))
+	t.Cleanup(srv.Close)
+	c, err := NewOllamaClient(srv.URL, NewMemoryCache(0, 0), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func TestGenerationCache(t *testing.T) {
+	var calls atomic.Int64
+	c := countingOllamaServer(t, &calls)
+	c.SetGenerationCache(config.GenerationCacheConfig{MaxEntries: 2, TTL: time.Minute})
+	ctx := context.Background()
+	chat := ChatRequest{
+		Model:    "llama3",
+		Messages: []api.Message{{Role: "user", Content: "positive or negative?"}},
+		Options:  map[string]any{"temperature": 0.0},
+	}
+
+	first, _ := c.Chat(ctx, chat)
+	second, _ := c.Chat(ctx, chat)
+	if calls.Load() != 1 || second.Content != first.Content {
+		t.Fatalf("expected the second chat to be served from cache, calls=%d %q %q", calls.Load(), first.Content, second.Content)
+	}
+	// 命中时不计 token
+	if first.Usage.PromptTokens != 3 || second.Usage != (Usage{Model: "llama3"}) {
+		t.Errorf("expected a cache hit to report no tokens, got %+v then %+v", first.Usage, second.Usage)
+	}
+	var streamed string
+	if _, err := c.ChatStream(ctx, chat, func(s string) error { streamed += s; return nil }); err != nil || streamed != first.Content {
+		t.Errorf("expected a cached stream to emit the full reply, got %q %v", streamed, err)
+	}
+
+	// no_cache 既不读取也不写入
+	noCache := chat
+	noCache.NoCache = true
+	if reply, _ := c.Chat(ctx, noCache); reply.Content == first.Content {
+		t.Error("expected NoCache to bypass the cache")
+	}
+	if reply, _ := c.Chat(ctx, chat); reply.Content != first.Content {
+		t.Errorf("expected the bypassed reply not to replace the cached one, got %q", reply.Content)
+	}
+
+	// options 不同视为不同的请求；超出 max_entries 时淘汰最久未使用的
+	req := GenerateRequest{Model: "llama3", Prompt: "hi", Options: map[string]any{"temperature": 0}}
+	_, _ = c.Generate(ctx, req, nil)
+	req.Options = map[string]any{"temperature": 0, "seed": 1}
+	_, _ = c.Generate(ctx, req, nil)
+	before := calls.Load()
+	_, _ = c.Generate(ctx, req, nil)
+	if calls.Load() != before {
+		t.Error("expected identical generate requests to hit the cache")
+	}
+	_, _ = c.Chat(ctx, chat)
+	if calls.Load() != before+1 {
+		t.Errorf("expected the least recently used chat entry to be evicted, calls=%d", calls.Load())
+	}
+}
+
+func TestGenerationCacheOnlyDeterministic(t *testing.T) {
+	var calls atomic.Int64
+	c := countingOllamaServer(t, &calls)
+	c.SetGenerationCache(config.GenerationCacheConfig{MaxEntries: 10, TTL: time.Minute})
+	ctx := context.Background()
+
+	for _, options := range []map[string]any{nil, {"temperature": 0.7}, {"num_predict": 16}} {
+		chat := ChatRequest{Model: "llama3", Messages: []api.Message{{Role: "user", Content: "hi"}}, Options: options}
+		before := calls.Load()
+		_, _ = c.Chat(ctx, chat)
+		_, _ = c.Chat(ctx, chat)
+		if calls.Load() != before+2 {
+			t.Errorf("options %v: expected sampled chats not to be cached", options)
+		}
+	}
+}
+
+func TestGenerationCacheExpires(t *testing.T) {
+	c := newGenerationCache(config.GenerationCacheConfig{MaxEntries: 10, TTL: time.Millisecond})
+	c.put("k", Reply{Content: "v"})
+	if _, ok := c.get("k"); !ok {
+		t.Fatal("expected a fresh entry to hit")
+	}
+	time.Sleep(5 * time.Millisecond)
+	if _, ok := c.get("k"); ok {
+		t.Error("expected an expired entry to miss")
+	}
+	if newGenerationCache(config.GenerationCacheConfig{TTL: time.Minute}) != nil {
+		t.Error("expected max_entries 0 to disable the cache")
+	}
+}
+
+func TestGenerationCacheIsolatesCallers(t *testing.T) {
+	var calls atomic.Int64
+	c := countingOllamaServer(t, &calls)
+	c.SetGenerationCache(config.GenerationCacheConfig{MaxEntries: 10, TTL: time.Minute})
+	chat := ChatRequest{
+		Model:    "llama3",
+		Messages: []api.Message{{Role: "user", Content: "what did acme ask?"}},
+		Options:  map[string]any{"temperature": 0.0},
+	}
+	caller := func(tenantID, sub string) context.Context {
+		return tenant.WithTenant(auth.WithIdentity(context.Background(), auth.Identity{Tenant: tenantID, Subject: sub}), tenantID)
+	}
+
+	acme, _ := c.Chat(caller("acme", "alice"), chat)
+	if reply, _ := c.Chat(caller("acme", "alice"), chat); calls.Load() != 1 || reply.Content != acme.Content {
+		t.Fatalf("expected the same caller to hit the cache, calls=%d", calls.Load())
+	}
+	// 其他租户与同一租户的其他调用方不命中，且按实际调用计入用量
+	for name, ctx := range map[string]context.Context{"other tenant": caller("other", "alice"), "other subject": caller("acme", "bob")} {
+		before := calls.Load()
+		reply, _ := c.Chat(ctx, chat)
+		if calls.Load() != before+1 || reply.Content == acme.Content || reply.Usage.PromptTokens != 3 {
+			t.Errorf("%s: expected a cache miss, got %+v", name, reply)
+		}
+	}
+}
@@ -0,0 +1,617 @@
+package bridge
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"slices"
+
+	"github.com/ollama/ollama/api"
+
+	"ollama_dev/internal/apperr"
+	"ollama_dev/internal/auth"
+	"ollama_dev/internal/config"
+	"ollama_dev/internal/jobs"
+	"ollama_dev/internal/policy"
+	"ollama_dev/internal/script"
+	"ollama_dev/internal/throttle"
+	"ollama_dev/internal/util/wsutils"
+	"ollama_dev/internal/version"
+	"ollama_dev/internal/wasm"
+)
+
+// HandlerFactory 请求处理器工厂
+type HandlerFactory struct {
+	ollamaClient OllamaClient
+	logger       Logger
+	jobs         *jobs.Manager   // 可为 nil，表示不支持模型拉取与推送
+	scripts      *script.Runtime // 可为 nil，表示未启用脚本
+	transforms   *wasm.Runtime   // 可为 nil，表示未启用 WASM 变换
+	models       ModelManager    // 可为 nil，表示不支持删除与复制模型
+	inspector    ModelInspector  // 可为 nil，表示不支持查询模型详情与运行状态
+	loader       ModelLoader     // 可为 nil，表示不支持预加载与卸载模型
+	creator      ModelCreator    // 可为 nil，表示不支持创建模型
+	sessions     *SessionStore   // 可为 nil，表示不支持 session_id
+	policy       *policy.Watcher // 可为 nil，表示不限制模型与参数
+	images       *imageLoader
+	queue        JobQueue             // 可为 nil，表示未启用任务队列
+	files        *wsutils.TransferDir // 可为 nil，表示不支持文件传输
+
+	transferLimiter *throttle.Limiter
+}
+
+func NewHandlerFactory(ollamaClient OllamaClient, logger Logger) *HandlerFactory {
+	return &HandlerFactory{
+		ollamaClient: ollamaClient,
+		logger:       logger,
+		images:       newImageLoader(config.Default().Bridge.Images),
+	}
+}
+
+// SetJobs 启用 pull_model、push_model、get_job 与 list_jobs 动作，
+// limiter 限制流式传输进度占用的带宽，为 nil 时不限
+func (f *HandlerFactory) SetJobs(m *jobs.Manager, limiter *throttle.Limiter) {
+	f.jobs = m
+	f.transferLimiter = limiter
+}
+
+// SetPolicy 按策略文件限制 chat、generate、embeddings 与自定义动作可用的模型与 options，
+// 以及 copy_model、create_model 与 load_model 涉及的模型，p 为 nil 时不限制
+func (f *HandlerFactory) SetPolicy(p *policy.Watcher) {
+	f.policy = p
+}
+
+// CreateHandler 返回动作的处理器，已知动作的处理器外层运行脚本钩子，再外层运行 WASM 变换：
+// 请求先经 WASM 变换再交给脚本，响应先经脚本再交给 WASM 变换；需要 admin 角色的动作在最外层校验请求的 role
+func (f *HandlerFactory) CreateHandler(action string) RequestHandler {
+	h := f.createHandler(action)
+	if !slices.Contains(f.Actions(), action) {
+		return h
+	}
+	if f.scripts != nil {
+		h = withHooks(h, f.scripts)
+	}
+	if f.transforms != nil {
+		h = withHooks(h, f.transforms)
+	}
+	return withRole(h, auth.ActionRole(action))
+}
+
+// withRole 处理前校验 serve 写入请求的 role 满足 role，与 serve 转发前的校验相同；
+// 未经 serve 核实的请求没有 role，需要 admin 角色的动作一律拒绝
+func withRole(h RequestHandler, role string) RequestHandler {
+	if role == auth.RoleUser {
+		return h
+	}
+	checked := &roleHandler{next: h, role: role}
+	if sh, ok := h.(StreamHandler); ok {
+		return &roleStreamHandler{roleHandler: checked, stream: sh}
+	}
+	return checked
+}
+
+type roleHandler struct {
+	next RequestHandler
+	role string
+}
+
+func (h *roleHandler) Handle(ctx context.Context, req *CloudRequest) (*CloudResponse, error) {
+	if err := h.check(req); err != nil {
+		return nil, err
+	}
+	return h.next.Handle(ctx, req)
+}
+
+func (h *roleHandler) check(req *CloudRequest) error {
+	if (auth.Identity{Roles: []string{req.Role}}).HasRole(h.role) {
+		return nil
+	}
+	return apperr.New(apperr.Auth, apperr.CodeForbidden, req.Action+" 需要 "+h.role+" 角色")
+}
+
+type roleStreamHandler struct {
+	*roleHandler
+	stream StreamHandler
+}
+
+func (h *roleStreamHandler) HandleStream(ctx context.Context, req *CloudRequest, emit func(data any) error) (*CloudResponse, error) {
+	if err := h.check(req); err != nil {
+		return nil, err
+	}
+	return h.stream.HandleStream(ctx, req, emit)
+}
+
+// actionGroup 由同一组件提供的一组内置动作，enabled 为 nil 时总是启用
+type actionGroup struct {
+	actions []string
+	enabled func(f *HandlerFactory) bool
+	handler func(f *HandlerFactory, action string) RequestHandler
+}
+
+// coreActions 不依赖可选组件的内置动作
+var coreActions = []string{"list_model", "chat", "generate", "embeddings", "version"}
+
+// actionGroups 内置动作及其处理器，按能力握手中的顺序排列
+var actionGroups = []actionGroup{
+	{coreActions, nil, (*HandlerFactory).coreHandler},
+	{jobActions, func(f *HandlerFactory) bool { return f.jobs != nil },
+		func(f *HandlerFactory, _ string) RequestHandler { return NewJobHandler(f.jobs, f.transferLimiter) }},
+	{manageActions, func(f *HandlerFactory) bool { return f.models != nil },
+		func(f *HandlerFactory, _ string) RequestHandler {
+			h := NewManageHandler(f.models)
+			h.policy = f.policy
+			return h
+		}},
+	{inspectActions, func(f *HandlerFactory) bool { return f.inspector != nil },
+		func(f *HandlerFactory, _ string) RequestHandler { return NewInspectHandler(f.inspector) }},
+	{loadActions, func(f *HandlerFactory) bool { return f.loader != nil },
+		func(f *HandlerFactory, _ string) RequestHandler {
+			h := NewLoadHandler(f.loader)
+			h.policy = f.policy
+			return h
+		}},
+	{createActions, func(f *HandlerFactory) bool { return f.creator != nil },
+		func(f *HandlerFactory, _ string) RequestHandler {
+			h := NewCreateHandler(f.creator)
+			h.policy = f.policy
+			return h
+		}},
+	{queueActions, func(f *HandlerFactory) bool { return f.queue != nil },
+		func(f *HandlerFactory, _ string) RequestHandler { return NewQueueHandler(f.queue) }},
+	{fileActions, func(f *HandlerFactory) bool { return f.files != nil },
+		func(f *HandlerFactory, _ string) RequestHandler { return NewFileHandler(f.files) }},
+}
+
+func (f *HandlerFactory) createHandler(action string) RequestHandler {
+	for _, g := range actionGroups {
+		if (g.enabled == nil || g.enabled(f)) && slices.Contains(g.actions, action) {
+			return g.handler(f, action)
+		}
+	}
+	if f.scripts.Defines(action) {
+		return &ScriptHandler{scripts: f.scripts}
+	}
+	// 自定义动作拿到的客户端同样按策略检查模型与 options
+	if a, ok := registeredAction(action); ok {
+		return a.New(WithPolicy(f.ollamaClient, f.policy), f.logger)
+	}
+	return NewDefaultHandler(f.logger)
+}
+
+// coreHandler 返回 coreActions 中动作的处理器
+func (f *HandlerFactory) coreHandler(action string) RequestHandler {
+	switch action {
+	case "list_model":
+		return NewListModelHandler(f.ollamaClient, f.logger)
+	case "chat":
+		h := NewChatHandler(f.ollamaClient, f.logger)
+		h.sessions = f.sessions
+		h.policy = f.policy
+		h.images = f.images
+		return h
+	case "generate":
+		h := NewGenerateHandler(f.ollamaClient, f.logger)
+		h.policy = f.policy
+		return h
+	case "embeddings":
+		h := NewEmbedHandler(f.ollamaClient, f.logger)
+		h.policy = f.policy
+		return h
+	case "version":
+		return NewVersionHandler()
+	default:
+		return NewDefaultHandler(f.logger)
+	}
+}
+
+// Actions 返回支持的动作列表（含注册的与脚本定义的自定义动作），用于能力握手
+func (f *HandlerFactory) Actions() []string {
+	var actions []string
+	for _, g := range actionGroups {
+		if g.enabled == nil || g.enabled(f) {
+			actions = append(actions, g.actions...)
+		}
+	}
+	actions = append(actions, registeredActions()...)
+	return append(actions, f.scripts.Actions()...)
+}
+
+// NeedsBackend 动作是否依赖 Ollama 后端，查询任务、排队任务、文件传输与脚本动作不需要；未知动作直接回复 unknown_action，同样不需要
+func (f *HandlerFactory) NeedsBackend(action string) bool {
+	if a, ok := registeredAction(action); ok {
+		return a.NeedsBackend
+	}
+	if f.scripts.Defines(action) {
+		return false
+	}
+	if !slices.Contains(f.Actions(), action) {
+		return false
+	}
+	return action != "version" && action != "get_job" && action != "list_jobs" && !slices.Contains(queueActions, action) && !slices.Contains(fileActions, action)
+}
+
+// ChatHandler 实现
+type ChatHandler struct {
+	ollamaClient OllamaClient
+	logger       Logger
+	sessions     *SessionStore   // 可为 nil
+	policy       *policy.Watcher // 可为 nil
+	images       *imageLoader
+}
+
+func NewChatHandler(ollamaClient OllamaClient, logger Logger) *ChatHandler {
+	return &ChatHandler{ollamaClient: ollamaClient, logger: logger, images: newImageLoader(config.Default().Bridge.Images)}
+}
+
+func (h *ChatHandler) Handle(ctx context.Context, req *CloudRequest) (*CloudResponse, error) {
+	messages, options, err := h.messages(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	reply, err := h.sessions.withSession(ctx, req, messages, func(messages []api.Message) (Reply, error) {
+		return h.ollamaClient.Chat(ctx, chatRequest(req, messages, options))
+	})
+	if err != nil {
+		return nil, BackendError(err, "Ollama 对话失败")
+	}
+
+	return chatResponse(req, reply), nil
+}
+
+// HandleStream 逐片段调用 emit，最终 done 帧携带完整回复
+func (h *ChatHandler) HandleStream(ctx context.Context, req *CloudRequest, emit func(data any) error) (*CloudResponse, error) {
+	messages, options, err := h.messages(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	reply, err := h.sessions.withSession(ctx, req, messages, func(messages []api.Message) (Reply, error) {
+		return h.ollamaClient.ChatStream(ctx, chatRequest(req, messages, options), func(chunk string) error {
+			return emit(chatData(chunk))
+		})
+	})
+	if err != nil {
+		if apperr.CategoryOf(err) == apperr.Timeout {
+			return nil, err
+		}
+		return nil, BackendError(err, "Ollama 对话失败")
+	}
+
+	return chatResponse(req, reply), nil
+}
+
+// chatRequest 组装发给 Ollama 的对话请求，messages 已包含会话历史，options 已按策略补全
+func chatRequest(req *CloudRequest, messages []api.Message, options map[string]any) ChatRequest {
+	return ChatRequest{
+		Model:     req.Params.ModelName,
+		Messages:  messages,
+		Tools:     req.Params.Tools,
+		Options:   options,
+		KeepAlive: req.Params.KeepAlive,
+		NoCache:   req.Params.NoCache,
+	}
+}
+
+// messages 校验参数与策略，返回 Ollama 消息与按策略补全的 options
+func (h *ChatHandler) messages(ctx context.Context, req *CloudRequest) ([]api.Message, map[string]any, error) {
+	messages, err := chatMessages(ctx, req, h.images)
+	if err != nil {
+		return nil, nil, err
+	}
+	options, err := h.policy.Apply(req.Params.ModelName, req.Params.Options)
+	if err != nil {
+		return nil, nil, err
+	}
+	return messages, options, nil
+}
+
+// GenerateHandler 文本补全，prompt、system、template 与 options 原样传给 Ollama；
+// 启用策略时 options 须在模型的限制内，未指定 num_predict 时按策略的上限生成
+type GenerateHandler struct {
+	ollamaClient OllamaClient
+	logger       Logger
+	policy       *policy.Watcher // 可为 nil
+}
+
+func NewGenerateHandler(ollamaClient OllamaClient, logger Logger) *GenerateHandler {
+	return &GenerateHandler{ollamaClient: ollamaClient, logger: logger}
+}
+
+func (h *GenerateHandler) Handle(ctx context.Context, req *CloudRequest) (*CloudResponse, error) {
+	return h.generate(ctx, req, nil)
+}
+
+// HandleStream 逐片段调用 emit，最终 done 帧携带完整结果
+func (h *GenerateHandler) HandleStream(ctx context.Context, req *CloudRequest, emit func(data any) error) (*CloudResponse, error) {
+	return h.generate(ctx, req, func(chunk string) error {
+		return emit(generateData(chunk))
+	})
+}
+
+func (h *GenerateHandler) generate(ctx context.Context, req *CloudRequest, onChunk func(string) error) (*CloudResponse, error) {
+	if req.Params.ModelName == "" {
+		return nil, apperr.New(apperr.Validation, apperr.CodeInvalidParams, "model_name 不能为空")
+	}
+	options, err := h.policy.Apply(req.Params.ModelName, req.Params.Options)
+	if err != nil {
+		return nil, err
+	}
+	reply, err := h.ollamaClient.Generate(ctx, GenerateRequest{
+		Model:     req.Params.ModelName,
+		Prompt:    req.Params.Prompt,
+		System:    req.Params.System,
+		Template:  req.Params.Template,
+		Options:   options,
+		KeepAlive: req.Params.KeepAlive,
+		NoCache:   req.Params.NoCache,
+	}, onChunk)
+	if err != nil {
+		return nil, BackendError(err, "Ollama 生成失败")
+	}
+
+	resp := newResponse(req, generateData(reply.Content))
+	resp.Usage = replyUsage(req, reply)
+	return resp, nil
+}
+
+// EmbedHandler 计算 params.input 中每条文本的向量，多条输入在一次 Ollama 调用中完成；
+// 启用策略时与 serve 的 /api/embeddings 一致，只检查模型与 options，不补全 num_predict
+type EmbedHandler struct {
+	ollamaClient OllamaClient
+	logger       Logger
+	policy       *policy.Watcher // 可为 nil
+}
+
+func NewEmbedHandler(ollamaClient OllamaClient, logger Logger) *EmbedHandler {
+	return &EmbedHandler{ollamaClient: ollamaClient, logger: logger}
+}
+
+func (h *EmbedHandler) Handle(ctx context.Context, req *CloudRequest) (*CloudResponse, error) {
+	if req.Params.ModelName == "" {
+		return nil, apperr.New(apperr.Validation, apperr.CodeInvalidParams, "model_name 不能为空")
+	}
+	if len(req.Params.Input) == 0 {
+		return nil, apperr.New(apperr.Validation, apperr.CodeInvalidParams, "input 不能为空")
+	}
+	if _, err := h.policy.Apply(req.Params.ModelName, req.Params.Options); err != nil {
+		return nil, err
+	}
+	e, err := h.ollamaClient.Embed(ctx, EmbedRequest{Model: req.Params.ModelName, Input: req.Params.Input, Options: req.Params.Options})
+	if err != nil {
+		return nil, BackendError(err, "Ollama 计算向量失败")
+	}
+
+	resp := newResponse(req, map[string]any{"embeddings": e.Vectors})
+	resp.Usage = replyUsage(req, Reply{Usage: e.Usage})
+	return resp, nil
+}
+
+// generateData generate 响应的 data 字段
+func generateData(content string) map[string]string {
+	return map[string]string{"response": content}
+}
+
+// BackendError 将 Ollama 调用的错误归类为 backend_error，超过动作时限的归类为 timeout，已分类的错误（例如熔断）原样返回；
+// REST 接口同样使用，两种协议的错误码保持一致
+func BackendError(err error, msg string) error {
+	var e *apperr.Error
+	if errors.As(err, &e) {
+		return err
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return apperr.Wrap(err, apperr.Timeout, apperr.CodeTimeout, msg+"：超过处理时限")
+	}
+	// Ollama 的 4xx 是请求本身的问题，重试不会成功
+	var status api.StatusError
+	if errors.As(err, &status) && status.StatusCode >= http.StatusBadRequest && status.StatusCode < http.StatusInternalServerError {
+		if status.StatusCode == http.StatusNotFound {
+			return apperr.Wrap(err, apperr.Validation, apperr.CodeModelNotFound, msg)
+		}
+		return apperr.Wrap(err, apperr.Validation, apperr.CodeInvalidParams, msg)
+	}
+	return apperr.Wrap(err, apperr.Backend, apperr.CodeBackendError, msg)
+}
+
+// chatResponse 构造对话的 done 帧，附带请求方用户的 token 用量；模型请求调用工具时 message 带有 tool_calls
+func chatResponse(req *CloudRequest, reply Reply) *CloudResponse {
+	data := chatData(reply.Content)
+	if len(reply.ToolCalls) > 0 {
+		data["message"].(map[string]any)["tool_calls"] = reply.ToolCalls
+	}
+	resp := newResponse(req, data)
+	resp.Usage = replyUsage(req, reply)
+	return resp
+}
+
+// replyUsage 返回 done 帧的 token 用量，记在请求方用户名下
+func replyUsage(req *CloudRequest, reply Reply) *Usage {
+	u := reply.Usage
+	u.User = req.User
+	if u.Model == "" {
+		u.Model = req.Params.ModelName
+	}
+	return &u
+}
+
+// chatMessages 校验参数并转换为 Ollama 消息，images 按 loader 的限制读取
+func chatMessages(ctx context.Context, req *CloudRequest, loader *imageLoader) ([]api.Message, error) {
+	if req.Params.ModelName == "" {
+		return nil, apperr.New(apperr.Validation, apperr.CodeInvalidParams, "model_name 不能为空")
+	}
+
+	var messages []api.Message
+	count := 0
+	for _, msg := range req.Params.Messages {
+		images, err := loader.load(ctx, msg.Images, count)
+		if err != nil {
+			return nil, err
+		}
+		count += len(images)
+		messages = append(messages, api.Message{
+			Role:      msg.Role,
+			Content:   msg.Content,
+			Images:    images,
+			ToolCalls: msg.ToolCalls,
+		})
+	}
+	return messages, nil
+}
+
+// chatData 对话响应的 data 字段
+func chatData(content string) map[string]any {
+	return map[string]any{
+		"message": map[string]any{
+			"role":    "assistant",
+			"content": content,
+		},
+	}
+}
+
+// RequestHandler 接口，返回的响应发送后会被回收复用，不得返回共享实例
+type RequestHandler interface {
+	Handle(ctx context.Context, req *CloudRequest) (*CloudResponse, error)
+}
+
+// StreamHandler 支持流式输出的处理器，请求 params.stream 为 true 时使用；
+// emit 以 data 发送一个中间分片，返回错误时应停止生成；返回值为最终的 done 帧
+type StreamHandler interface {
+	HandleStream(ctx context.Context, req *CloudRequest, emit func(data any) error) (*CloudResponse, error)
+}
+
+// DefaultHandler 实现
+type DefaultHandler struct {
+	logger Logger
+}
+
+func NewDefaultHandler(logger Logger) *DefaultHandler {
+	return &DefaultHandler{logger: logger}
+}
+
+func (h *DefaultHandler) Handle(ctx context.Context, req *CloudRequest) (*CloudResponse, error) {
+	return nil, apperr.New(apperr.Protocol, apperr.CodeUnknownAction, "未知的动作: "+req.Action)
+}
+
+// ListModelHandler 实现
+type ListModelHandler struct {
+	ollamaClient OllamaClient
+	logger       Logger
+}
+
+func NewListModelHandler(ollamaClient OllamaClient, logger Logger) *ListModelHandler {
+	return &ListModelHandler{ollamaClient: ollamaClient, logger: logger}
+}
+
+func (h *ListModelHandler) Handle(ctx context.Context, req *CloudRequest) (*CloudResponse, error) {
+	models, err := h.ollamaClient.ListModels(ctx)
+	if err != nil {
+		return nil, BackendError(err, "获取 Ollama 模型列表失败")
+	}
+
+	return newResponse(req, models), nil
+}
+
+// jobActions 模型传输任务相关的动作
+var jobActions = []string{"pull_model", "push_model", "get_job", "list_jobs"}
+
+// JobHandler 创建与查询模型拉取、推送任务；任务在后台运行，响应立即返回任务的当前状态，
+// 流式请求则以中间帧持续返回进度，直到任务结束
+type JobHandler struct {
+	jobs    *jobs.Manager
+	limiter *throttle.Limiter
+}
+
+func NewJobHandler(m *jobs.Manager, limiter *throttle.Limiter) *JobHandler {
+	return &JobHandler{jobs: m, limiter: limiter}
+}
+
+func (h *JobHandler) Handle(ctx context.Context, req *CloudRequest) (*CloudResponse, error) {
+	switch req.Action {
+	case "pull_model", "push_model":
+		j, err := h.start(req)
+		if err != nil {
+			return nil, err
+		}
+		return newResponse(req, j), nil
+	case "get_job":
+		j, ok := h.jobs.Get(req.Params.JobID)
+		if !ok {
+			return nil, apperr.New(apperr.Validation, apperr.CodeNotFound, "任务不存在: "+req.Params.JobID)
+		}
+		return newResponse(req, j), nil
+	default:
+		return newResponse(req, h.jobs.List()), nil
+	}
+}
+
+// start 创建或复用 pull_model、push_model 请求对应的任务
+func (h *JobHandler) start(req *CloudRequest) (jobs.Job, error) {
+	if req.Params.ModelName == "" {
+		return jobs.Job{}, apperr.New(apperr.Validation, apperr.CodeInvalidParams, "model_name 不能为空")
+	}
+	kind := jobs.KindPull
+	if req.Action == "push_model" {
+		kind = jobs.KindPush
+	}
+	j, err := h.jobs.Start(kind, req.Params.ModelName)
+	if err != nil {
+		return jobs.Job{}, apperr.Wrap(err, apperr.Internal, apperr.CodeInternal, "创建任务失败")
+	}
+	return j, nil
+}
+
+// HandleStream 在任务进度变化时发送中间帧，done 帧为结束后的任务；
+// 超出带宽限制时合并进度，只发送最新的一次
+func (h *JobHandler) HandleStream(ctx context.Context, req *CloudRequest, emit func(data any) error) (*CloudResponse, error) {
+	if req.Action != "pull_model" && req.Action != "push_model" {
+		return h.Handle(ctx, req)
+	}
+	j, err := h.start(req)
+	if err != nil {
+		return nil, err
+	}
+	var sent jobs.Job
+	for {
+		changed := h.jobs.Changed()
+		if err := h.limiter.Wait(ctx); err != nil {
+			return nil, err
+		}
+		cur, _ := h.jobs.Get(j.ID)
+		if cur.Finished() {
+			return newResponse(req, cur), nil
+		}
+		if cur != sent {
+			if err := emit(cur); err != nil {
+				return nil, err
+			}
+			sent = cur
+			h.limiter.Take(progressFrameSize(cur))
+		}
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			// 停止发送进度，任务继续在后台运行，可通过 get_job 查询
+			return nil, apperr.Wrap(ctx.Err(), apperr.Timeout, apperr.CodeTimeout, "跟踪任务进度超过处理时限")
+		case <-h.jobs.Done():
+			return nil, apperr.New(apperr.Internal, apperr.CodeInternal, "bridge 正在退出，任务将在重启后继续")
+		}
+	}
+}
+
+// progressFrameSize 估算进度帧的字节数
+func progressFrameSize(j jobs.Job) int {
+	data, _ := json.Marshal(j)
+	return len(data)
+}
+
+// VersionHandler 返回桥接客户端的版本与构建信息
+type VersionHandler struct{}
+
+func NewVersionHandler() *VersionHandler {
+	return &VersionHandler{}
+}
+
+func (h *VersionHandler) Handle(ctx context.Context, req *CloudRequest) (*CloudResponse, error) {
+	return newResponse(req, version.Get()), nil
+}
@@ -0,0 +1,68 @@
+package bridge
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"slices"
+	"testing"
+
+	"ollama_dev/internal/jobs"
+	"ollama_dev/internal/util/wsutils"
+)
+
+func TestHandlerFactoryActions(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	client, err := NewOllamaClient("http://127.0.0.1:1", NewMemoryCache(0, 0), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	files, err := wsutils.NewTransferDir(t.TempDir(), 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	store, _ := jobs.Open("")
+	manager := jobs.NewManager(context.Background(), store, fakeTransfer{}, logger)
+
+	cases := []struct {
+		name    string
+		enable  func(f *HandlerFactory)
+		action  string
+		handler string // 处理器类型，不支持的动作为 *bridge.DefaultHandler
+		backend bool
+	}{
+		{"chat", nil, "chat", "*bridge.ChatHandler", true},
+		{"list_model", nil, "list_model", "*bridge.ListModelHandler", true},
+		{"version", nil, "version", "*bridge.VersionHandler", false},
+		{"unknown action", nil, "reboot", "*bridge.DefaultHandler", false},
+		{"jobs disabled", nil, "pull_model", "*bridge.DefaultHandler", false},
+		{"pull_model", func(f *HandlerFactory) { f.SetJobs(manager, nil) }, "pull_model", "*bridge.JobHandler", true},
+		{"get_job", func(f *HandlerFactory) { f.SetJobs(manager, nil) }, "get_job", "*bridge.JobHandler", false},
+		{"delete_model", func(f *HandlerFactory) { f.SetModelManager(client) }, "delete_model", "*bridge.ManageHandler", true},
+		{"show_model", func(f *HandlerFactory) { f.SetModelInspector(client) }, "show_model", "*bridge.InspectHandler", true},
+		{"load_model", func(f *HandlerFactory) { f.SetModelLoader(client) }, "load_model", "*bridge.LoadHandler", true},
+		{"create_model", func(f *HandlerFactory) { f.SetModelCreator(client) }, "create_model", "*bridge.CreateHandler", true},
+		{"create disabled", nil, "create_model", "*bridge.DefaultHandler", false},
+		{"file_upload", func(f *HandlerFactory) { f.SetFiles(files) }, "file_upload", "*bridge.FileHandler", false},
+		{"file_download", func(f *HandlerFactory) { f.SetFiles(files) }, "file_download", "*bridge.FileHandler", false},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			f := NewHandlerFactory(&fakeOllama{}, logger)
+			if c.enable != nil {
+				c.enable(f)
+			}
+			if got := fmt.Sprintf("%T", f.createHandler(c.action)); got != c.handler {
+				t.Errorf("createHandler(%q) = %s, want %s", c.action, got, c.handler)
+			}
+			// 能力握手中的动作与可以创建处理器的动作一致
+			if advertised := slices.Contains(f.Actions(), c.action); advertised != (c.handler != "*bridge.DefaultHandler") {
+				t.Errorf("Actions() contains %q = %v", c.action, advertised)
+			}
+			if got := f.NeedsBackend(c.action); got != c.backend {
+				t.Errorf("NeedsBackend(%q) = %v, want %v", c.action, got, c.backend)
+			}
+		})
+	}
+}
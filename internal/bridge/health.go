@@ -0,0 +1,116 @@
+package bridge
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"ollama_dev/internal/breaker"
+	"ollama_dev/internal/config"
+	"ollama_dev/internal/i18n"
+)
+
+// BackendStatus Ollama 后端可用状态，随心跳上报
+type BackendStatus struct {
+	Healthy   bool      `json:"healthy"`
+	Error     string    `json:"error,omitempty"`
+	CheckedAt time.Time `json:"checked_at"`
+	Failures  int       `json:"failures,omitempty"` // 连续失败次数
+
+	Breaker *breaker.Status `json:"breaker,omitempty"` // Ollama 调用的熔断状态，未启用熔断时为空
+}
+
+// HealthChecker 后台探测 Ollama 可达性：健康时按固定间隔探测，
+// 不健康时以指数退避重试，直到恢复
+type HealthChecker struct {
+	probe func(ctx context.Context) error
+	cfg   config.HealthConfig
+
+	mu     sync.RWMutex
+	status BackendStatus
+}
+
+// NewHealthChecker 创建探测器，启动前默认视为健康，避免误拒首批请求
+func NewHealthChecker(probe func(ctx context.Context) error, cfg config.HealthConfig) *HealthChecker {
+	return &HealthChecker{
+		probe:  probe,
+		cfg:    cfg,
+		status: BackendStatus{Healthy: true},
+	}
+}
+
+// Run 循环探测直到 ctx 结束
+func (h *HealthChecker) Run(ctx context.Context, logger Logger) {
+	for {
+		healthy := h.check(ctx, logger)
+
+		delay := h.cfg.Interval
+		if !healthy {
+			delay = h.backoff()
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(delay):
+		}
+	}
+}
+
+// check 执行一次探测并更新状态，返回是否健康
+func (h *HealthChecker) check(ctx context.Context, logger Logger) bool {
+	probeCtx, cancel := context.WithTimeout(ctx, h.cfg.Timeout)
+	err := h.probe(probeCtx)
+	cancel()
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	wasHealthy := h.status.Healthy
+	h.status.CheckedAt = time.Now()
+	if err != nil {
+		h.status.Healthy = false
+		h.status.Error = err.Error()
+		h.status.Failures++
+		if wasHealthy {
+			logger.Error(i18n.T(i18n.LogBackendUnavailable), "error", err)
+		}
+		return false
+	}
+
+	if !wasHealthy {
+		logger.Info(i18n.T(i18n.LogBackendRecovered), "failures", h.status.Failures)
+	}
+	h.status = BackendStatus{Healthy: true, CheckedAt: h.status.CheckedAt}
+	return true
+}
+
+// backoff 按连续失败次数计算下次探测间隔
+func (h *HealthChecker) backoff() time.Duration {
+	h.mu.RLock()
+	failures := h.status.Failures
+	h.mu.RUnlock()
+
+	delay := h.cfg.MinBackoff
+	for i := 1; i < failures && delay < h.cfg.MaxBackoff; i++ {
+		delay *= 2
+	}
+	if delay > h.cfg.MaxBackoff {
+		delay = h.cfg.MaxBackoff
+	}
+	return delay
+}
+
+// Healthy 返回最近一次探测是否成功
+func (h *HealthChecker) Healthy() bool {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return h.status.Healthy
+}
+
+// Status 返回当前状态快照
+func (h *HealthChecker) Status() BackendStatus {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return h.status
+}
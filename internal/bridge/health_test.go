@@ -0,0 +1,65 @@
+package bridge
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+
+	"ollama_dev/internal/config"
+)
+
+func TestHealthCheckerBackoffAndRecovery(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	probeErr := errors.New("connection refused")
+	h := NewHealthChecker(func(ctx context.Context) error { return probeErr }, config.HealthConfig{
+		Interval:   time.Second,
+		Timeout:    time.Second,
+		MinBackoff: time.Second,
+		MaxBackoff: 4 * time.Second,
+	})
+
+	if !h.Healthy() {
+		t.Fatal("checker should start healthy")
+	}
+
+	// 连续失败时退避时间翻倍并受上限约束
+	want := []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 4 * time.Second}
+	for i, w := range want {
+		if h.check(context.Background(), logger) {
+			t.Fatalf("check %d should fail", i)
+		}
+		if got := h.backoff(); got != w {
+			t.Errorf("after %d failures expected backoff %s, got %s", i+1, w, got)
+		}
+	}
+	if status := h.Status(); status.Healthy || status.Failures != 4 || status.Error == "" {
+		t.Errorf("unexpected status: %+v", status)
+	}
+
+	probeErr = nil
+	if !h.check(context.Background(), logger) || !h.Healthy() {
+		t.Error("checker should recover after a successful probe")
+	}
+	if status := h.Status(); status.Failures != 0 || status.Error != "" {
+		t.Errorf("status should reset after recovery: %+v", status)
+	}
+}
+
+func TestUnknownActionIsRejectedWhileBackendDown(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	cfg := config.Default().Bridge
+	h := NewHealthChecker(func(ctx context.Context) error { return errors.New("connection refused") }, cfg.Health)
+	h.check(context.Background(), logger)
+	s := NewServer(&fakeWSClient{}, NewHandlerFactory(&fakeOllama{}, logger), h, cfg, logger)
+
+	// 后端不可用只影响依赖 Ollama 的动作，未知动作仍回复 unknown_action
+	if resp := s.backendUnavailable(&CloudRequest{Action: "no_such_action"}); resp != nil {
+		t.Errorf("unknown action should not be reported as backend_unavailable: %+v", resp.Data)
+	}
+	if resp := s.backendUnavailable(&CloudRequest{Action: "chat"}); resp == nil {
+		t.Error("chat should be rejected while the backend is down")
+	}
+}
@@ -0,0 +1,105 @@
+package bridge
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/ollama/ollama/api"
+
+	"ollama_dev/internal/apperr"
+	"ollama_dev/internal/config"
+)
+
+// imageLoader 将 chat 消息 images 中的 base64 或 http(s) 地址转换为 Ollama 的图片数据，并校验数量、大小与类型
+type imageLoader struct {
+	cfg    config.ImagesConfig
+	client *http.Client
+}
+
+func newImageLoader(cfg config.ImagesConfig) *imageLoader {
+	return &imageLoader{cfg: cfg, client: &http.Client{Timeout: cfg.FetchTimeout}}
+}
+
+// SetImages 按配置限制 chat 消息中的图片，默认使用 config.Default 的限制且不下载图片地址
+func (f *HandlerFactory) SetImages(cfg config.ImagesConfig) {
+	f.images = newImageLoader(cfg)
+}
+
+// load 依次转换 images，count 为本请求中已经转换的图片数
+func (l *imageLoader) load(ctx context.Context, images []string, count int) ([]api.ImageData, error) {
+	if len(images) == 0 {
+		return nil, nil
+	}
+	if count+len(images) > l.cfg.MaxCount {
+		if l.cfg.MaxCount == 0 {
+			return nil, imageError("未启用图片 (bridge.images.max_count 为 0)")
+		}
+		return nil, imageError(fmt.Sprintf("图片数超过上限 %d", l.cfg.MaxCount))
+	}
+	out := make([]api.ImageData, 0, len(images))
+	for i, ref := range images {
+		data, err := l.read(ctx, ref)
+		if err != nil {
+			return nil, apperr.Wrap(err, apperr.Validation, apperr.CodeInvalidParams, fmt.Sprintf("第 %d 张图片不可用", count+i+1))
+		}
+		if int64(len(data)) > l.cfg.MaxBytes {
+			return nil, imageError(fmt.Sprintf("第 %d 张图片超过 %d 字节", count+i+1, l.cfg.MaxBytes))
+		}
+		if t := http.DetectContentType(data); !slices.Contains(l.cfg.AllowedTypes, t) {
+			return nil, imageError(fmt.Sprintf("第 %d 张图片的类型 %s 不被允许，可选 %s", count+i+1, t, strings.Join(l.cfg.AllowedTypes, "、")))
+		}
+		out = append(out, data)
+	}
+	return out, nil
+}
+
+// read 读取一张图片：http(s) 地址在 fetch_urls 开启时下载，其余按 base64 解码，可带 data:...;base64, 前缀
+func (l *imageLoader) read(ctx context.Context, ref string) ([]byte, error) {
+	if strings.HasPrefix(ref, "http://") || strings.HasPrefix(ref, "https://") {
+		if !l.cfg.FetchURLs {
+			return nil, fmt.Errorf("未启用图片地址下载 (bridge.images.fetch_urls)")
+		}
+		return l.fetch(ctx, ref)
+	}
+	if strings.HasPrefix(ref, "data:") {
+		header, payload, ok := strings.Cut(ref, ",")
+		if !ok || !strings.HasSuffix(header, ";base64") {
+			return nil, fmt.Errorf("data URL 须为 base64 编码")
+		}
+		ref = payload
+	}
+	if base64.StdEncoding.DecodedLen(len(ref)) > int(l.cfg.MaxBytes)+3 {
+		return nil, fmt.Errorf("超过 %d 字节", l.cfg.MaxBytes)
+	}
+	data, err := base64.StdEncoding.DecodeString(ref)
+	if err != nil {
+		return nil, fmt.Errorf("base64 解码失败: %w", err)
+	}
+	return data, nil
+}
+
+// fetch 下载图片，最多读取 max_bytes+1 字节以判断是否超限
+func (l *imageLoader) fetch(ctx context.Context, url string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := l.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("下载失败: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("下载失败: HTTP %d", resp.StatusCode)
+	}
+	return io.ReadAll(io.LimitReader(resp.Body, l.cfg.MaxBytes+1))
+}
+
+func imageError(msg string) error {
+	return apperr.New(apperr.Validation, apperr.CodeInvalidParams, msg)
+}
@@ -0,0 +1,69 @@
+package bridge
+
+import (
+	"context"
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"ollama_dev/internal/apperr"
+	"ollama_dev/internal/config"
+)
+
+// pngHeader 足以被识别为 image/png 的最小内容
+var pngHeader = []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR")
+
+func TestChatMessageImages(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/cat.png" {
+			w.Write(pngHeader)
+			return
+		}
+		w.Write([]byte("<html></html>"))
+	}))
+	defer srv.Close()
+
+	cfg := config.Default().Bridge.Images
+	cfg.MaxCount, cfg.MaxBytes = 2, 64
+	b64 := base64.StdEncoding.EncodeToString(pngHeader)
+	chat := func(loader *imageLoader, images ...string) error {
+		req := &CloudRequest{Action: "chat"}
+		req.Params.ModelName = "llava"
+		req.Params.Messages = []ChatMessage{{Role: "user", Content: "这是什么？", Images: images}}
+		messages, err := chatMessages(context.Background(), req, loader)
+		if err == nil && len(messages[0].Images) != len(images) {
+			t.Errorf("expected %d images, got %d", len(images), len(messages[0].Images))
+		}
+		return err
+	}
+
+	if err := chat(newImageLoader(cfg), b64, "data:image/png;base64,"+b64); err != nil {
+		t.Fatalf("expected base64 images to be accepted, got %v", err)
+	}
+	cases := map[string][]string{
+		"too many":     {b64, b64, b64},
+		"not base64":   {"@@@"},
+		"too large":    {base64.StdEncoding.EncodeToString(append(pngHeader, make([]byte, 64)...))},
+		"wrong type":   {base64.StdEncoding.EncodeToString([]byte("plain text"))},
+		"url disabled": {srv.URL + "/cat.png"},
+	}
+	for name, images := range cases {
+		if err := chat(newImageLoader(cfg), images...); apperr.CodeOf(err) != apperr.CodeInvalidParams {
+			t.Errorf("%s: expected invalid_params, got %v", name, err)
+		}
+	}
+
+	cfg.FetchURLs = true
+	if err := chat(newImageLoader(cfg), srv.URL+"/cat.png"); err != nil {
+		t.Errorf("expected the fetched image to be accepted, got %v", err)
+	}
+	if err := chat(newImageLoader(cfg), srv.URL+"/page"); err == nil || !strings.Contains(err.Error(), "text/html") {
+		t.Errorf("expected a fetched page to be rejected by content type, got %v", err)
+	}
+	cfg.MaxCount = 0
+	if err := chat(newImageLoader(cfg), b64); apperr.CodeOf(err) != apperr.CodeInvalidParams {
+		t.Errorf("expected images to be rejected when disabled, got %v", err)
+	}
+}
@@ -0,0 +1,49 @@
+package bridge
+
+import (
+	"context"
+
+	"ollama_dev/internal/apperr"
+)
+
+// ModelInspector 查询模型详情与运行状态，Ollama 客户端实现时提供 show_model 与 ps 动作
+type ModelInspector interface {
+	Show(ctx context.Context, model string) (ModelDetail, error)
+	Running(ctx context.Context) ([]RunningModel, error)
+}
+
+// inspectActions 模型查询相关的动作
+var inspectActions = []string{"show_model", "ps"}
+
+// SetModelInspector 启用 show_model 与 ps 动作，m 为 nil 时不启用
+func (f *HandlerFactory) SetModelInspector(m ModelInspector) {
+	f.inspector = m
+}
+
+// InspectHandler show_model 返回 params.model_name 的参数、模板、许可证与 Modelfile，
+// ps 返回已加载到内存的模型及其显存占用与卸载时间
+type InspectHandler struct {
+	inspector ModelInspector
+}
+
+func NewInspectHandler(m ModelInspector) *InspectHandler {
+	return &InspectHandler{inspector: m}
+}
+
+func (h *InspectHandler) Handle(ctx context.Context, req *CloudRequest) (*CloudResponse, error) {
+	if req.Action == "ps" {
+		running, err := h.inspector.Running(ctx)
+		if err != nil {
+			return nil, BackendError(err, "查询运行中的模型失败")
+		}
+		return newResponse(req, running), nil
+	}
+	if req.Params.ModelName == "" {
+		return nil, apperr.New(apperr.Validation, apperr.CodeInvalidParams, "model_name 不能为空")
+	}
+	detail, err := h.inspector.Show(ctx, req.Params.ModelName)
+	if err != nil {
+		return nil, BackendError(err, "查询模型详情失败")
+	}
+	return newResponse(req, detail), nil
+}
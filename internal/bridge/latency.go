@@ -0,0 +1,128 @@
+package bridge
+
+import (
+	"expvar"
+	"sync"
+	"time"
+)
+
+// latencyMetrics 心跳往返时延，发布在 /debug/vars 的 latency 字段
+var (
+	latencyMetrics = expvar.NewMap("latency")
+	latencyLast    = new(expvar.Float)
+	latencyAvg     = new(expvar.Float)
+)
+
+func init() {
+	latencyMetrics.Set("last_ms", latencyLast)
+	latencyMetrics.Set("avg_ms", latencyAvg)
+}
+
+const (
+	latencyWindow = 20 // 计算滑动平均的样本数
+	maxPending    = 8  // 最多跟踪的未确认心跳数，超出时丢弃最早的
+)
+
+// LatencyStats 与云端之间的往返时延，随心跳与能力握手上报，云端可据此优先选择时延低的桥接客户端
+type LatencyStats struct {
+	LastMs     float64   `json:"last_ms"`
+	AvgMs      float64   `json:"avg_ms"` // 最近 samples 次的平均
+	MinMs      float64   `json:"min_ms"`
+	MaxMs      float64   `json:"max_ms"`
+	Samples    int       `json:"samples"`
+	MeasuredAt time.Time `json:"measured_at"`
+}
+
+type pendingPing struct {
+	id     string
+	sentAt time.Time
+}
+
+// latencyTracker 记录已发送的心跳，收到同一 request_id 的心跳帧（云端的确认或 hub 的回显）时计算往返时延
+type latencyTracker struct {
+	mu       sync.Mutex
+	pending  []pendingPing
+	samples  [latencyWindow]time.Duration
+	n, next  int // 已有样本数与下一个写入位置
+	last     time.Duration
+	measured time.Time
+	now      func() time.Time
+}
+
+func newLatencyTracker() *latencyTracker {
+	return &latencyTracker{now: time.Now}
+}
+
+// sent 记录一次心跳的发送时间
+func (t *latencyTracker) sent(id string, at time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if len(t.pending) == maxPending {
+		t.pending = append(t.pending[:0], t.pending[1:]...)
+	}
+	t.pending = append(t.pending, pendingPing{id: id, sentAt: at})
+}
+
+// ack 收到心跳确认，id 不是本端发出的心跳时返回 false
+func (t *latencyTracker) ack(id string) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for i, p := range t.pending {
+		if p.id != id {
+			continue
+		}
+		now := t.now()
+		rtt := now.Sub(p.sentAt)
+		t.pending = append(t.pending[:i], t.pending[i+1:]...)
+		t.samples[t.next] = rtt
+		t.next = (t.next + 1) % latencyWindow
+		t.n = min(t.n+1, latencyWindow)
+		t.last, t.measured = rtt, now
+
+		latencyMetrics.Add("acks", 1)
+		latencyLast.Set(ms(rtt))
+		latencyAvg.Set(t.statsLocked().AvgMs)
+		return true
+	}
+	return false
+}
+
+// reset 丢弃未确认的心跳，连接重建时调用，旧连接上的心跳不会再收到确认
+func (t *latencyTracker) reset() {
+	t.mu.Lock()
+	t.pending = t.pending[:0]
+	t.mu.Unlock()
+}
+
+// stats 返回滑动窗口内的统计，尚无样本时返回 nil
+func (t *latencyTracker) stats() *LatencyStats {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.n == 0 {
+		return nil
+	}
+	s := t.statsLocked()
+	return &s
+}
+
+// statsLocked 调用方需持有锁且已有样本
+func (t *latencyTracker) statsLocked() LatencyStats {
+	var sum time.Duration
+	lo, hi := t.samples[0], t.samples[0]
+	for _, d := range t.samples[:t.n] {
+		sum += d
+		lo, hi = min(lo, d), max(hi, d)
+	}
+	return LatencyStats{
+		LastMs:     ms(t.last),
+		AvgMs:      ms(sum / time.Duration(t.n)),
+		MinMs:      ms(lo),
+		MaxMs:      ms(hi),
+		Samples:    t.n,
+		MeasuredAt: t.measured,
+	}
+}
+
+func ms(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000
+}
@@ -0,0 +1,85 @@
+package bridge
+
+import (
+	"encoding/json"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+
+	"ollama_dev/internal/config"
+)
+
+func TestLatencyTrackerRollingStats(t *testing.T) {
+	tr := newLatencyTracker()
+	now := time.Unix(0, 0)
+	tr.now = func() time.Time { return now }
+
+	if tr.stats() != nil {
+		t.Fatal("expected no stats before the first ack")
+	}
+	for i, rtt := range []time.Duration{10, 30, 20} {
+		id := string(rune('a' + i))
+		tr.sent(id, now)
+		now = now.Add(rtt * time.Millisecond)
+		if !tr.ack(id) {
+			t.Fatalf("ack(%s) should match", id)
+		}
+	}
+	s := tr.stats()
+	if s.LastMs != 20 || s.AvgMs != 20 || s.MinMs != 10 || s.MaxMs != 30 || s.Samples != 3 {
+		t.Errorf("stats = %+v", s)
+	}
+	// 其他桥接客户端的心跳与重复的确认不计入
+	if tr.ack("other") || tr.ack("a") {
+		t.Error("unknown or already acked heartbeat should not match")
+	}
+
+	// 超过窗口后只保留最近的样本
+	for i := range latencyWindow {
+		id := string(rune('A' + i))
+		tr.sent(id, now)
+		now = now.Add(5 * time.Millisecond)
+		tr.ack(id)
+	}
+	if s := tr.stats(); s.AvgMs != 5 || s.Samples != latencyWindow {
+		t.Errorf("stats after window = %+v", s)
+	}
+}
+
+func TestHeartbeatEchoMeasuresLatency(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	ws := &fakeWSClient{}
+	s := NewServer(ws, NewHandlerFactory(&fakeOllama{}, logger), nil, config.Default().Bridge, logger)
+
+	if err := s.sendHeartbeat(); err != nil {
+		t.Fatal(err)
+	}
+	var hb CloudRequest
+	if err := json.Unmarshal(ws.written[0], &hb); err != nil {
+		t.Fatal(err)
+	}
+	if hb.Params.SentAt == 0 || hb.Params.Latency != nil {
+		t.Fatalf("first heartbeat should carry sent_at but no latency, got %+v", hb.Params)
+	}
+
+	// 云端（或 hub 的广播）带回同一 request_id 的心跳帧
+	msg, err := parseMessage(ws.written[0], true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.dispatch(msg); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.sendHeartbeat(); err != nil {
+		t.Fatal(err)
+	}
+	hb = CloudRequest{}
+	if err := json.Unmarshal(ws.written[1], &hb); err != nil {
+		t.Fatal(err)
+	}
+	if hb.Params.Latency == nil || hb.Params.Latency.Samples != 1 {
+		t.Errorf("second heartbeat should report latency, got %+v", hb.Params.Latency)
+	}
+}
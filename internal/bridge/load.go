@@ -0,0 +1,56 @@
+package bridge
+
+import (
+	"context"
+
+	"github.com/ollama/ollama/api"
+
+	"ollama_dev/internal/apperr"
+	"ollama_dev/internal/policy"
+)
+
+// ModelLoader 预加载与卸载模型，Ollama 客户端实现时提供 load_model 与 unload_model 动作
+type ModelLoader interface {
+	// Load 将模型加载到内存，keepAlive 为 nil 时按 Ollama 的默认时长保留
+	Load(ctx context.Context, model string, keepAlive *api.Duration) error
+	// Unload 立即从内存中卸载模型
+	Unload(ctx context.Context, model string) error
+}
+
+// loadActions 模型预加载相关的动作
+var loadActions = []string{"load_model", "unload_model"}
+
+// SetModelLoader 启用 load_model 与 unload_model 动作，m 为 nil 时不启用
+func (f *HandlerFactory) SetModelLoader(m ModelLoader) {
+	f.loader = m
+}
+
+// LoadHandler load_model 在流量高峰前加载 params.model_name 并按 params.keep_alive 保留，
+// unload_model 在之后卸载以释放显存；成功时 data 为模型名；启用策略时只能加载允许列表中的模型
+type LoadHandler struct {
+	loader ModelLoader
+	policy *policy.Watcher // 可为 nil
+}
+
+func NewLoadHandler(m ModelLoader) *LoadHandler {
+	return &LoadHandler{loader: m}
+}
+
+func (h *LoadHandler) Handle(ctx context.Context, req *CloudRequest) (*CloudResponse, error) {
+	if req.Params.ModelName == "" {
+		return nil, apperr.New(apperr.Validation, apperr.CodeInvalidParams, "model_name 不能为空")
+	}
+	if req.Action == "unload_model" {
+		if err := h.loader.Unload(ctx, req.Params.ModelName); err != nil {
+			return nil, BackendError(err, "卸载模型失败")
+		}
+	} else {
+		if err := h.policy.Allow(req.Params.ModelName); err != nil {
+			return nil, err
+		}
+		if err := h.loader.Load(ctx, req.Params.ModelName, req.Params.KeepAlive); err != nil {
+			return nil, BackendError(err, "加载模型失败")
+		}
+	}
+	return newResponse(req, map[string]string{"model": req.Params.ModelName}), nil
+}
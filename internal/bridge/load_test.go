@@ -0,0 +1,89 @@
+package bridge
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/ollama/ollama/api"
+
+	"ollama_dev/internal/auth"
+)
+
+func TestLoadUnloadAndKeepAlive(t *testing.T) {
+	var bodies []map[string]any
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]any
+		json.NewDecoder(r.Body).Decode(&body)
+		body["path"] = r.URL.Path
+		bodies = append(bodies, body)
+		if r.URL.Path == "/api/chat" {
+			json.NewEncoder(w).Encode(api.ChatResponse{Message: api.Message{Role: "assistant", Content: "ok"}, Done: true})
+			return
+		}
+		json.NewEncoder(w).Encode(api.GenerateResponse{Done: true})
+	}))
+	defer srv.Close()
+	c, err := NewOllamaClient(srv.URL, NewMemoryCache(0, 0), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	factory := NewHandlerFactory(c, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	factory.SetModelLoader(c)
+	if !slices.Contains(factory.Actions(), "load_model") || !factory.NeedsBackend("unload_model") {
+		t.Fatalf("expected load actions to be advertised, got %v", factory.Actions())
+	}
+
+	frames := []string{
+		`{"action":"load_model","request_id":"1","params":{"model_name":"llama3","keep_alive":"1h"}}`,
+		`{"action":"chat","request_id":"2","params":{"model_name":"llama3","keep_alive":-1,"messages":[{"role":"user","content":"hi"}]}}`,
+		`{"action":"generate","request_id":"3","params":{"model_name":"llama3","prompt":"hi","keep_alive":"30s"}}`,
+		`{"action":"unload_model","request_id":"4","params":{"model_name":"llama3"}}`,
+	}
+	for _, f := range frames {
+		var req CloudRequest
+		if err := json.Unmarshal([]byte(f), &req); err != nil {
+			t.Fatal(err)
+		}
+		req.Role = auth.RoleAdmin
+		resp, err := factory.CreateHandler(req.Action).Handle(context.Background(), &req)
+		if err != nil {
+			t.Fatalf("%s: %v", req.Action, err)
+		}
+		if req.Action == "load_model" && resp.Data.(map[string]string)["model"] != "llama3" {
+			t.Errorf("unexpected load response %+v", resp.Data)
+		}
+	}
+
+	want := []struct {
+		path      string
+		keepAlive any
+	}{
+		{"/api/generate", time.Hour.String()},
+		{"/api/chat", nil}, // 负数表示一直保留，编码为最大时长，只检查已转发
+		{"/api/generate", "30s"},
+		{"/api/generate", "0s"},
+	}
+	if len(bodies) != len(want) {
+		t.Fatalf("expected %d Ollama calls, got %d", len(want), len(bodies))
+	}
+	for i, w := range want {
+		if bodies[i]["path"] != w.path {
+			t.Errorf("call %d: expected %s, got %v", i, w.path, bodies[i]["path"])
+		}
+		if _, ok := bodies[i]["keep_alive"]; !ok {
+			t.Errorf("call %d: expected keep_alive to be forwarded, got %v", i, bodies[i])
+		} else if w.keepAlive != nil && bodies[i]["keep_alive"] != w.keepAlive {
+			t.Errorf("call %d: expected keep_alive %v, got %v", i, w.keepAlive, bodies[i]["keep_alive"])
+		}
+	}
+	if p, _ := bodies[0]["prompt"].(string); p != "" {
+		t.Errorf("expected load_model to send an empty prompt, got %q", p)
+	}
+}
@@ -0,0 +1,117 @@
+package bridge
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"time"
+
+	"ollama_dev/internal/tracing"
+	"ollama_dev/internal/util/wsutils"
+)
+
+// LocalChat 由 serve 直接处理 /ws 连接发出的 chat 请求 (server.websocket.chat)，实现 websocket.FrameHandler：
+// 协议与经 bridge 处理时相同，params.stream 为 true 时逐片回复，云端可用 cancel 帧取消
+type LocalChat struct {
+	handler  *ChatHandler
+	timeout  time.Duration
+	inflight *inflightRegistry
+	logger   Logger
+}
+
+// NewLocalChat timeout 为单次对话的处理时限
+func NewLocalChat(ollama OllamaClient, timeout time.Duration, logger Logger) *LocalChat {
+	return &LocalChat{
+		handler:  NewHandlerFactory(ollama, logger).CreateHandler("chat").(*ChatHandler),
+		timeout:  timeout,
+		inflight: newInflightRegistry(),
+		logger:   logger,
+	}
+}
+
+// Accept 处理 chat 请求，以及取消本地处理中请求的 cancel 帧
+func (l *LocalChat) Accept(frame []byte) bool {
+	if !bytes.Contains(frame, []byte(`"chat"`)) && !bytes.Contains(frame, []byte(`"cancel"`)) {
+		return false
+	}
+	var head struct {
+		Type      string `json:"type"`
+		Action    string `json:"action"`
+		RequestID string `json:"request_id"`
+	}
+	if json.Unmarshal(frame, &head) != nil || head.Type != TypeServerToClient {
+		return false
+	}
+	switch head.Action {
+	case "chat":
+		return true
+	case ActionCancel:
+		return l.inflight.cancel(head.RequestID)
+	}
+	return false
+}
+
+// Serve 调用 Ollama 并通过 reply 回复，cancel 帧在 Accept 中已处理
+func (l *LocalChat) Serve(ctx context.Context, tenant string, frame []byte, reply func([]byte) error) {
+	msg, err := parseMessage(frame, false)
+	defer msg.release()
+	req := msg.Request
+	if req.Action == ActionCancel {
+		return
+	}
+	send := func(resp *CloudResponse) error {
+		buf, err := wsutils.EncodeJSON(resp)
+		if err != nil {
+			return err
+		}
+		defer wsutils.PutBuffer(buf)
+		return reply(bytes.Clone(buf.Bytes()))
+	}
+	if err == nil {
+		var resp *CloudResponse
+		resp, err = l.chat(ctx, req, send)
+		if err == nil {
+			defer releaseResponse(resp)
+			if err := send(resp); err != nil {
+				l.logger.Error("回复对话失败", "request_id", req.RequestID, "error", err)
+			}
+			return
+		}
+	}
+	if ctx.Err() != nil {
+		// 连接已断开，无需回复
+		return
+	}
+	l.logger.Error("处理对话失败", "tenant", tenant, "request_id", req.RequestID, "error", err)
+	if err := send(errorResponse(req, err)); err != nil {
+		l.logger.Error("回复错误帧失败", "request_id", req.RequestID, "error", err)
+	}
+}
+
+// chat 在时限内调用处理器，流式请求的分片经 send 发出
+func (l *LocalChat) chat(parent context.Context, req *CloudRequest, send func(*CloudResponse) error) (*CloudResponse, error) {
+	reqCtx, done := l.inflight.begin(req.RequestID)
+	defer done()
+	ctx, span := traceRequest(reqCtx, "ws", req)
+	ctx, cancel := context.WithTimeout(ctx, l.timeout)
+	defer cancel()
+	// 连接断开时同样中止
+	stop := context.AfterFunc(parent, cancel)
+	defer stop()
+
+	var resp *CloudResponse
+	var err error
+	if req.Params.Stream {
+		resp, err = l.handler.HandleStream(ctx, req, func(data any) error {
+			frame := newResponse(req, data)
+			defer releaseResponse(frame)
+			frame.Status = StatusStreaming
+			return send(frame)
+		})
+	} else {
+		resp, err = l.handler.Handle(ctx, req)
+	}
+	err = cancelled(reqCtx, err)
+	tracing.End(span, err)
+	return resp, err
+}
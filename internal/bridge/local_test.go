@@ -0,0 +1,69 @@
+package bridge
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+)
+
+func TestLocalChatAccept(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	l := NewLocalChat(&fakeOllama{}, time.Second, logger)
+	tests := []struct {
+		frame string
+		want  bool
+	}{
+		{`{"type":"server_to_client","action":"chat","request_id":"1"}`, true},
+		{`{"type":"server_to_client","action":"embed","request_id":"1"}`, false},
+		{`{"type":"client_to_server","action":"chat","request_id":"1"}`, false},
+		{`{"type":"room","action":"join","params":{"room":"chat"}}`, false},
+		// 不在本地处理中的 cancel 帧照常广播
+		{`{"type":"server_to_client","action":"cancel","request_id":"1"}`, false},
+	}
+	for _, tt := range tests {
+		if got := l.Accept([]byte(tt.frame)); got != tt.want {
+			t.Errorf("Accept(%s) = %v, want %v", tt.frame, got, tt.want)
+		}
+	}
+}
+
+func TestLocalChatServe(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	ollama := &gatedOllama{started: make(chan struct{}, 1), release: make(chan struct{})}
+	l := NewLocalChat(ollama, time.Second, logger)
+	frame := []byte(`{"type":"server_to_client","action":"chat","request_id":"1","params":{"model_name":"llama3"}}`)
+
+	replies := make(chan []byte, 1)
+	go l.Serve(context.Background(), "", frame, func(b []byte) error {
+		replies <- b
+		return nil
+	})
+	<-ollama.started
+	close(ollama.release)
+	if status, _ := responseStatus(t, <-replies); status == StatusError {
+		t.Errorf("expected successful reply, got %s", status)
+	}
+}
+
+func TestLocalChatCancel(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	ollama := &gatedOllama{started: make(chan struct{}, 1), release: make(chan struct{})}
+	l := NewLocalChat(ollama, time.Second, logger)
+	frame := []byte(`{"type":"server_to_client","action":"chat","request_id":"1","params":{"model_name":"llama3"}}`)
+
+	replies := make(chan []byte, 1)
+	go l.Serve(context.Background(), "", frame, func(b []byte) error {
+		replies <- b
+		return nil
+	})
+	<-ollama.started
+	// 本地处理中的请求由 Accept 直接取消，取消后回复错误帧
+	if !l.Accept([]byte(`{"type":"server_to_client","action":"cancel","request_id":"1"}`)) {
+		t.Fatal("expected cancel for in-flight request to be accepted")
+	}
+	if status, _ := responseStatus(t, <-replies); status != StatusError {
+		t.Errorf("expected error reply after cancel, got %s", status)
+	}
+}
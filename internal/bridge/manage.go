@@ -0,0 +1,60 @@
+package bridge
+
+import (
+	"context"
+
+	"ollama_dev/internal/apperr"
+	"ollama_dev/internal/policy"
+)
+
+// ModelManager 删除与复制本地模型，Ollama 客户端实现时提供 delete_model 与 copy_model 动作
+type ModelManager interface {
+	Delete(ctx context.Context, model string) error
+	Copy(ctx context.Context, source, destination string) error
+}
+
+// manageActions 模型管理相关的动作
+var manageActions = []string{"delete_model", "copy_model"}
+
+// SetModelManager 启用 delete_model 与 copy_model 动作，m 为 nil 时不启用
+func (f *HandlerFactory) SetModelManager(m ModelManager) {
+	f.models = m
+}
+
+// ManageHandler 删除 (params.model_name) 或复制 (params.model_name 到 params.destination) 模型，
+// 成功时 data 为操作后的模型名；启用策略时复制的源模型与目标模型名都须在允许列表中
+type ManageHandler struct {
+	models ModelManager
+	policy *policy.Watcher // 可为 nil
+}
+
+func NewManageHandler(m ModelManager) *ManageHandler {
+	return &ManageHandler{models: m}
+}
+
+func (h *ManageHandler) Handle(ctx context.Context, req *CloudRequest) (*CloudResponse, error) {
+	if req.Params.ModelName == "" {
+		return nil, apperr.New(apperr.Validation, apperr.CodeInvalidParams, "model_name 不能为空")
+	}
+	switch req.Action {
+	case "delete_model":
+		if err := h.models.Delete(ctx, req.Params.ModelName); err != nil {
+			return nil, BackendError(err, "删除模型失败")
+		}
+		return newResponse(req, map[string]string{"model": req.Params.ModelName}), nil
+	default:
+		if req.Params.Destination == "" {
+			return nil, apperr.New(apperr.Validation, apperr.CodeInvalidParams, "destination 不能为空")
+		}
+		if err := h.policy.Allow(req.Params.ModelName); err != nil {
+			return nil, err
+		}
+		if err := h.policy.Allow(req.Params.Destination); err != nil {
+			return nil, err
+		}
+		if err := h.models.Copy(ctx, req.Params.ModelName, req.Params.Destination); err != nil {
+			return nil, BackendError(err, "复制模型失败")
+		}
+		return newResponse(req, map[string]string{"model": req.Params.Destination}), nil
+	}
+}
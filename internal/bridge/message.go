@@ -0,0 +1,76 @@
+package bridge
+
+import (
+	"encoding/json"
+
+	"github.com/ollama/ollama/api"
+
+	"ollama_dev/internal/apperr"
+	"ollama_dev/internal/keystore"
+	"ollama_dev/internal/models"
+	"ollama_dev/internal/usage"
+)
+
+// Message 结构体
+type Message struct {
+	Raw      []byte
+	Kind     Kind
+	Request  *CloudRequest
+	Response *CloudResponse
+}
+
+// CloudRequest 结构体
+type CloudRequest struct {
+	V         int         `json:"v"` // 协议版本，发送时为 ProtocolVersion
+	Type      string      `json:"type"`
+	Action    string      `json:"action"`
+	RequestID string      `json:"request_id,omitempty"`
+	TenantID  string      `json:"tenant_id,omitempty"` // 多租户部署时请求所属的租户，响应原样带回
+	User      string      `json:"user,omitempty"`      // 请求方自报的用户，写入用量统计
+	Upstream  string      `json:"upstream,omitempty"`  // 发出请求与心跳的上游名称，只连接 bridge.url 时为空
+	Role      string      `json:"role,omitempty"`      // serve 核实的发送方角色，只有需要 admin 角色的请求携带
+	Params    CloudParams `json:"params"`
+
+	Sealed *keystore.Sealed `json:"sealed,omitempty"` // 端到端加密的 params，发送方加密后 Params 为空
+	sealed bool             // 收到的请求经过加密，响应需同样加密
+
+	RawParams json.RawMessage `json:"-"` // 收到的原始 params（已解密），供自定义动作解码自有字段
+}
+
+// CloudResponse 结构体
+type CloudResponse struct {
+	V         int    `json:"v"` // 协议版本，发送时为 ProtocolVersion
+	Type      string `json:"type"`
+	Action    string `json:"action"`
+	RequestID string `json:"request_id,omitempty"`
+	TenantID  string `json:"tenant_id,omitempty"`
+	Upstream  string `json:"upstream,omitempty"` // 回复所经的上游名称，只连接 bridge.url 时为空
+	Data      any    `json:"data"`
+	Status    string `json:"status,omitempty"`
+
+	Sealed *keystore.Sealed `json:"sealed,omitempty"` // 端到端加密的 data
+	Usage  *Usage           `json:"usage,omitempty"`  // 对话 done 帧的 token 用量
+	sealed bool             // 发送前加密 Data
+}
+
+// Usage 对话的 token 用量，随 done 帧的 usage 字段上报；端到端加密时同样以明文发送，供云端计费
+type Usage = usage.Tokens
+
+// Reply 一次对话的完整回复与用量
+type Reply struct {
+	Content   string
+	ToolCalls []api.ToolCall // 模型请求的工具调用，请求未携带 tools 时为空
+	Usage     Usage
+}
+
+// ErrorData 错误响应 (status 为 error) 的 data 字段，包含错误类别、错误码与描述
+type ErrorData = apperr.Data
+
+// ModelInfo list_model 响应 data 数组的元素
+type ModelInfo = models.Info
+
+// ModelDetail show_model 响应的 data
+type ModelDetail = models.Detail
+
+// RunningModel ps 响应 data 数组的元素
+type RunningModel = models.Running
@@ -0,0 +1,57 @@
+package bridge
+
+import (
+	"ollama_dev/internal/metrics"
+)
+
+// bridge 的 Prometheus 指标，由 bridge.metrics_addr 上的 /metrics 导出
+var (
+	messagesTotal = metrics.NewCounter("ollama_dev_bridge_messages_total",
+		"与云端收发的 WebSocket 帧数，direction 为 in 或 out", "direction")
+	requestDuration = metrics.NewHistogram("ollama_dev_bridge_request_duration_seconds",
+		"按动作统计的请求处理耗时，status 为 done 或 error", metrics.DefBuckets, "action", "status")
+	reconnectsTotal = metrics.NewCounter("ollama_dev_bridge_reconnects_total", "连接断开后的重连次数", "upstream")
+	connectedGauge  = metrics.NewGauge("ollama_dev_bridge_connected", "与云端的连接状态，1 为已连接", "upstream")
+	cacheRequests   = metrics.NewCounter("ollama_dev_cache_requests_total",
+		"模型列表缓存的查询次数，result 为 hit 或 miss", "result")
+)
+
+func init() {
+	metrics.NewGaugeFunc("ollama_dev_cache_hit_ratio", "模型列表缓存的命中率，尚无查询时为 0", func() float64 {
+		hit, miss := cacheRequests.Value("hit"), cacheRequests.Value("miss")
+		if hit+miss == 0 {
+			return 0
+		}
+		return hit / (hit + miss)
+	})
+}
+
+// meteredClient 统计收发的帧数
+type meteredClient struct {
+	WSClient
+}
+
+func (c *meteredClient) ReadMessage() ([]byte, error) {
+	message, err := c.WSClient.ReadMessage()
+	if err == nil {
+		messagesTotal.Inc("in")
+	}
+	return message, err
+}
+
+func (c *meteredClient) WriteMessage(message []byte) error {
+	err := c.WSClient.WriteMessage(message)
+	if err == nil {
+		messagesTotal.Inc("out")
+	}
+	return err
+}
+
+// observeRequest 记录一次请求的处理耗时
+func observeRequest(action string, seconds float64, err error) {
+	status := StatusDone
+	if err != nil {
+		status = StatusError
+	}
+	requestDuration.Observe(seconds, action, status)
+}
@@ -0,0 +1,40 @@
+package bridge
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net"
+	"net/http"
+
+	"ollama_dev/internal/config"
+	"ollama_dev/internal/mirror"
+	"ollama_dev/internal/throttle"
+)
+
+// startMirror 在 cfg.Addr 上提供模型层缓存，ctx 结束时关闭；未配置 addr 时返回 nil
+func startMirror(ctx context.Context, cfg config.MirrorConfig, limiter *throttle.Limiter, logger *slog.Logger) (*mirror.Mirror, error) {
+	if cfg.Addr == "" {
+		return nil, nil
+	}
+	m, err := mirror.New(ctx, cfg, limiter, logger)
+	if err != nil {
+		return nil, err
+	}
+	ln, err := net.Listen("tcp", cfg.Addr)
+	if err != nil {
+		return nil, err
+	}
+	srv := &http.Server{Handler: m}
+	go func() {
+		<-ctx.Done()
+		_ = srv.Close()
+	}()
+	go func() {
+		logger.Info("模型层缓存已启动", "addr", ln.Addr().String(), "upstream", cfg.Upstream, "dir", cfg.Dir)
+		if err := srv.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			logger.Error("模型层缓存运行错误", "error", err)
+		}
+	}()
+	return m, nil
+}
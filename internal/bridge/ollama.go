@@ -0,0 +1,369 @@
+package bridge
+
+import (
+	"context"
+	"expvar"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/ollama/ollama/api"
+
+	"ollama_dev/internal/config"
+	"ollama_dev/internal/jobs"
+	"ollama_dev/internal/mirror"
+	"ollama_dev/internal/models"
+	"ollama_dev/internal/stats"
+)
+
+// ollamaStats Ollama 调用计数，发布在 /debug/vars 的 ollama 字段
+var ollamaStats = expvar.NewMap("ollama")
+
+// DefaultOllamaClient 实现 OllamaClient，可以连接多个 Ollama 后端并按模型与负载调度
+type DefaultOllamaClient struct {
+	router   *ollamaRouter
+	cache    Cache
+	cacheTTL time.Duration
+	pullVia  string
+
+	generations *generationCache // 可为 nil，表示不缓存生成结果
+}
+
+// NewOllamaClient 创建 Ollama 客户端，host 为空时读取 OLLAMA_HOST 环境变量
+func NewOllamaClient(host string, cache Cache, cacheTTL time.Duration) (*DefaultOllamaClient, error) {
+	router, err := newOllamaRouter([]string{host}, BalanceLeastLoaded)
+	if err != nil {
+		return nil, err
+	}
+	return &DefaultOllamaClient{
+		router:   router,
+		cache:    cache,
+		cacheTTL: cacheTTL,
+	}, nil
+}
+
+// SetBackends 改为连接多个 Ollama 后端：对话等请求优先发往有该模型的可用后端，再按 balance 选择，
+// 连接失败时换用下一个；心跳探测全部后端并刷新各自的模型列表
+func (c *DefaultOllamaClient) SetBackends(hosts []string, balance string) error {
+	router, err := newOllamaRouter(hosts, balance)
+	if err != nil {
+		return err
+	}
+	c.router = router
+	return nil
+}
+
+// SetGenerationCache 缓存 temperature 为 0 的 chat 与 generate 的回复，同一租户与调用方 (按 ctx) 相同的模型、消息或提示词与 options
+// 直接返回缓存的结果；max_entries 为 0 时不缓存
+func (c *DefaultOllamaClient) SetGenerationCache(cfg config.GenerationCacheConfig) {
+	c.generations = newGenerationCache(cfg)
+}
+
+func (c *DefaultOllamaClient) Chat(ctx context.Context, req ChatRequest) (Reply, error) {
+	key := chatKey(ctx, req)
+	if reply, ok := c.generations.get(key); ok {
+		return reply, nil
+	}
+	r := &api.ChatRequest{
+		Model:     req.Model,
+		Messages:  req.Messages,
+		Stream:    new(bool),
+		Tools:     req.Tools,
+		Options:   req.Options,
+		KeepAlive: req.KeepAlive,
+	}
+
+	ollamaStats.Add("chat_calls", 1)
+	var reply Reply
+	start := time.Now()
+	err := c.router.do(ctx, req.Model, nil, func(client *api.Client) error {
+		return client.Chat(ctx, r, func(resp api.ChatResponse) error {
+			reply.Content = resp.Message.Content
+			reply.ToolCalls = resp.Message.ToolCalls
+			reply.Usage = usageOf(req.Model, resp.Metrics)
+			return nil
+		})
+	})
+	stats.ObserveModel(req.Model, time.Since(start), err)
+	if err != nil {
+		ollamaStats.Add("chat_errors", 1)
+		return reply, err
+	}
+
+	c.generations.put(key, reply)
+	return reply, nil
+}
+
+// usageOf 读取最后一条响应中的 token 计数与生成耗时
+func usageOf(modelName string, m api.Metrics) Usage {
+	return Usage{Model: modelName, PromptTokens: m.PromptEvalCount, CompletionTokens: m.EvalCount, DurationMs: m.TotalDuration.Milliseconds()}
+}
+
+// ChatStream 流式对话，onChunk 阻塞时 Ollama 的 HTTP 流随之暂停；命中缓存时以一个片段返回完整回复
+func (c *DefaultOllamaClient) ChatStream(ctx context.Context, req ChatRequest, onChunk func(string) error) (Reply, error) {
+	key := chatKey(ctx, req)
+	if reply, ok := c.generations.get(key); ok {
+		return reply, onChunk(reply.Content)
+	}
+	r := &api.ChatRequest{
+		Model:     req.Model,
+		Messages:  req.Messages,
+		Tools:     req.Tools,
+		Options:   req.Options,
+		KeepAlive: req.KeepAlive,
+	}
+
+	ollamaStats.Add("chat_calls", 1)
+	var result strings.Builder
+	var toolCalls []api.ToolCall
+	var u Usage
+	start := time.Now()
+	err := c.router.do(ctx, req.Model, func() bool { return result.Len() > 0 || len(toolCalls) > 0 }, func(client *api.Client) error {
+		return client.Chat(ctx, r, func(resp api.ChatResponse) error {
+			if resp.Done {
+				u = usageOf(req.Model, resp.Metrics)
+			}
+			// 工具调用不是文本片段，随最终回复返回
+			toolCalls = append(toolCalls, resp.Message.ToolCalls...)
+			if resp.Message.Content == "" {
+				return nil
+			}
+			result.WriteString(resp.Message.Content)
+			return onChunk(resp.Message.Content)
+		})
+	})
+	stats.ObserveModel(req.Model, time.Since(start), err)
+	reply := Reply{Content: result.String(), ToolCalls: toolCalls, Usage: u}
+	if err != nil {
+		ollamaStats.Add("chat_errors", 1)
+		return reply, err
+	}
+
+	c.generations.put(key, reply)
+	return reply, nil
+}
+
+// Generate 文本补全，流式调用命中缓存时以一个片段返回完整结果
+func (c *DefaultOllamaClient) Generate(ctx context.Context, req GenerateRequest, onChunk func(string) error) (Reply, error) {
+	key := generateKey(ctx, req)
+	if reply, ok := c.generations.get(key); ok {
+		if onChunk == nil {
+			return reply, nil
+		}
+		return reply, onChunk(reply.Content)
+	}
+	r := &api.GenerateRequest{
+		Model:     req.Model,
+		Prompt:    req.Prompt,
+		System:    req.System,
+		Template:  req.Template,
+		Options:   req.Options,
+		KeepAlive: req.KeepAlive,
+	}
+	if onChunk == nil {
+		r.Stream = new(bool)
+	}
+
+	ollamaStats.Add("generate_calls", 1)
+	var result strings.Builder
+	var u Usage
+	start := time.Now()
+	err := c.router.do(ctx, req.Model, func() bool { return result.Len() > 0 }, func(client *api.Client) error {
+		return client.Generate(ctx, r, func(resp api.GenerateResponse) error {
+			if resp.Done {
+				u = usageOf(req.Model, resp.Metrics)
+			}
+			if resp.Response == "" {
+				return nil
+			}
+			result.WriteString(resp.Response)
+			if onChunk == nil {
+				return nil
+			}
+			return onChunk(resp.Response)
+		})
+	})
+	stats.ObserveModel(req.Model, time.Since(start), err)
+	reply := Reply{Content: result.String(), Usage: u}
+	if err != nil {
+		ollamaStats.Add("generate_errors", 1)
+		return reply, err
+	}
+
+	c.generations.put(key, reply)
+	return reply, nil
+}
+
+// Embed 批量计算向量，一次请求发送全部输入
+func (c *DefaultOllamaClient) Embed(ctx context.Context, req EmbedRequest) (Embeddings, error) {
+	ollamaStats.Add("embed_calls", 1)
+	start := time.Now()
+	var resp *api.EmbedResponse
+	err := c.router.do(ctx, req.Model, nil, func(client *api.Client) (err error) {
+		resp, err = client.Embed(ctx, &api.EmbedRequest{Model: req.Model, Input: req.Input, Options: req.Options})
+		return err
+	})
+	stats.ObserveModel(req.Model, time.Since(start), err)
+	if err != nil {
+		ollamaStats.Add("embed_errors", 1)
+		return Embeddings{}, err
+	}
+	return Embeddings{Vectors: resp.Embeddings, Usage: Usage{Model: req.Model, PromptTokens: resp.PromptEvalCount, DurationMs: resp.TotalDuration.Milliseconds()}}, nil
+}
+
+// ListModels 列出模型及其加载状态，结果按 cache.ttl 缓存，加载状态可能滞后
+func (c *DefaultOllamaClient) ListModels(ctx context.Context) ([]ModelInfo, error) {
+	if cached, found := c.cache.Get("models"); found {
+		ollamaStats.Add("list_cache_hits", 1)
+		cacheRequests.Inc("hit")
+		return cached.([]ModelInfo), nil
+	}
+	cacheRequests.Inc("miss")
+
+	ollamaStats.Add("list_calls", 1)
+	data, err := c.router.list(ctx)
+	if err != nil {
+		ollamaStats.Add("list_errors", 1)
+		return nil, err
+	}
+
+	c.cache.Set("models", data, c.cacheTTL)
+	return data, nil
+}
+
+// RefreshModels 重新查询模型列表并写入缓存
+func (c *DefaultOllamaClient) RefreshModels(ctx context.Context) error {
+	ollamaStats.Add("list_calls", 1)
+	data, err := c.router.list(ctx)
+	if err != nil {
+		ollamaStats.Add("list_errors", 1)
+		return err
+	}
+	c.cache.Set("models", data, c.cacheTTL)
+	return nil
+}
+
+// WarmModel 将模型加载到内存，按 Ollama 的默认时长保留
+func (c *DefaultOllamaClient) WarmModel(ctx context.Context, model string) error {
+	return c.Load(ctx, model, nil)
+}
+
+// SetPullVia 通过局域网内的 mirror 拉取模型，为空时直接从 registry 拉取
+func (c *DefaultOllamaClient) SetPullVia(via string) {
+	c.pullVia = via
+}
+
+// Pull 拉取模型，Ollama 保留未下载完的层，再次拉取时从断点继续；
+// 配置了 mirror 时从 mirror 拉取，完成后复制为原名称并删除 mirror 名称（层文件共享，不占额外空间）
+func (c *DefaultOllamaClient) Pull(ctx context.Context, model string, fn func(jobs.Progress)) error {
+	client := c.router.pick(model, nil).client
+	pull, local, ok := mirror.Rewrite(c.pullVia, model)
+	if !ok {
+		return client.Pull(ctx, &api.PullRequest{Model: model}, progressFunc(fn))
+	}
+	req := &api.PullRequest{Model: pull, Insecure: strings.HasPrefix(pull, "http://")}
+	if err := client.Pull(ctx, req, progressFunc(fn)); err != nil {
+		return fmt.Errorf("从 mirror 拉取 %s 失败: %w", pull, err)
+	}
+	if err := client.Copy(ctx, &api.CopyRequest{Source: local, Destination: model}); err != nil {
+		return fmt.Errorf("复制模型 %s 失败: %w", local, err)
+	}
+	return client.Delete(ctx, &api.DeleteRequest{Model: local})
+}
+
+// Push 推送模型到其名称对应的仓库
+func (c *DefaultOllamaClient) Push(ctx context.Context, model string, fn func(jobs.Progress)) error {
+	return c.router.pick(model, nil).client.Push(ctx, &api.PushRequest{Model: model}, api.PushProgressFunc(progressFunc(fn)))
+}
+
+// progressFunc 将 Ollama 的进度回调转换为 jobs.Progress
+func progressFunc(fn func(jobs.Progress)) api.PullProgressFunc {
+	return func(p api.ProgressResponse) error {
+		fn(jobs.Progress{Status: p.Status, Digest: p.Digest, Completed: p.Completed, Total: p.Total})
+		return nil
+	}
+}
+
+// Delete 删除模型并刷新模型列表缓存
+func (c *DefaultOllamaClient) Delete(ctx context.Context, model string) error {
+	err := c.router.all(func(client *api.Client) error {
+		return client.Delete(ctx, &api.DeleteRequest{Model: model})
+	})
+	if err != nil {
+		return err
+	}
+	_ = c.RefreshModels(ctx)
+	return nil
+}
+
+// Copy 以新名称复制模型 (层文件共享) 并刷新模型列表缓存
+func (c *DefaultOllamaClient) Copy(ctx context.Context, source, destination string) error {
+	err := c.router.all(func(client *api.Client) error {
+		return client.Copy(ctx, &api.CopyRequest{Source: source, Destination: destination})
+	})
+	if err != nil {
+		return err
+	}
+	_ = c.RefreshModels(ctx)
+	return nil
+}
+
+// Create 在每个后端上按创建请求构建模型 (FROM 的模型须已存在)，完成后刷新模型列表缓存
+func (c *DefaultOllamaClient) Create(ctx context.Context, req *api.CreateRequest, fn api.CreateProgressFunc) error {
+	err := c.router.all(func(client *api.Client) error {
+		return client.Create(ctx, req, fn)
+	})
+	if err != nil {
+		return err
+	}
+	_ = c.RefreshModels(ctx)
+	return nil
+}
+
+// Load 以空提示词调用 generate，Ollama 只加载模型而不生成
+func (c *DefaultOllamaClient) Load(ctx context.Context, model string, keepAlive *api.Duration) error {
+	return c.router.do(ctx, model, nil, func(client *api.Client) error {
+		return client.Generate(ctx, &api.GenerateRequest{Model: model, KeepAlive: keepAlive}, func(api.GenerateResponse) error { return nil })
+	})
+}
+
+// Unload 以 keep_alive 为 0 调用 generate，从每个加载了该模型的后端卸载
+func (c *DefaultOllamaClient) Unload(ctx context.Context, model string) error {
+	return c.router.all(func(client *api.Client) error {
+		return client.Generate(ctx, &api.GenerateRequest{Model: model, KeepAlive: &api.Duration{}}, func(api.GenerateResponse) error { return nil })
+	})
+}
+
+// Show 查询模型的参数、模板、许可证与 Modelfile
+func (c *DefaultOllamaClient) Show(ctx context.Context, model string) (ModelDetail, error) {
+	var resp *api.ShowResponse
+	err := c.router.do(ctx, model, nil, func(client *api.Client) (err error) {
+		resp, err = client.Show(ctx, &api.ShowRequest{Model: model})
+		return err
+	})
+	if err != nil {
+		return ModelDetail{}, err
+	}
+	return models.DetailFromShow(model, resp), nil
+}
+
+// Running 查询已加载到内存的模型，不使用模型列表缓存
+func (c *DefaultOllamaClient) Running(ctx context.Context) ([]RunningModel, error) {
+	var running []RunningModel
+	err := c.router.all(func(client *api.Client) error {
+		resp, err := client.ListRunning(ctx)
+		if err == nil {
+			running = append(running, models.FromProcess(resp)...)
+		}
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return running, nil
+}
+
+// Heartbeat 探测 Ollama 服务是否可达
+func (c *DefaultOllamaClient) Heartbeat(ctx context.Context) error {
+	return c.router.heartbeat(ctx)
+}
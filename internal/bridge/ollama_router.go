@@ -0,0 +1,241 @@
+package bridge
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"sync"
+	"sync/atomic"
+
+	"github.com/ollama/ollama/api"
+
+	"ollama_dev/internal/models"
+)
+
+// 多个 Ollama 后端之间的调度策略
+const (
+	BalanceLeastLoaded = "least_loaded" // 进行中请求最少的后端
+	BalanceRoundRobin  = "round_robin"  // 依次轮换
+	BalanceFirst       = "first"        // 按配置顺序，前面的不可用时才使用后面的
+)
+
+// ollamaBackend 一个 Ollama 服务
+type ollamaBackend struct {
+	host     string
+	client   *api.Client
+	inflight atomic.Int64
+
+	mu     sync.RWMutex
+	down   error           // 最近一次探测或调用的连接错误，nil 表示可用
+	models map[string]bool // 已拉取的模型，nil 表示尚未查询
+}
+
+func (b *ollamaBackend) healthy() bool {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return b.down == nil
+}
+
+// has 后端是否有该模型，尚未查询过模型列表时视为有
+func (b *ollamaBackend) has(model string) bool {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return b.models == nil || model == "" || b.models[model]
+}
+
+func (b *ollamaBackend) setDown(err error) {
+	b.mu.Lock()
+	b.down = err
+	b.mu.Unlock()
+}
+
+func (b *ollamaBackend) setModels(list []ModelInfo) {
+	names := make(map[string]bool, len(list))
+	for _, m := range list {
+		names[m.Name] = true
+	}
+	b.mu.Lock()
+	b.models = names
+	b.mu.Unlock()
+}
+
+// ollamaRouter 为每次调用选择后端，连接失败时换用下一个
+type ollamaRouter struct {
+	backends []*ollamaBackend
+	balance  string
+	next     atomic.Uint64 // round_robin 的轮换位置
+}
+
+func newOllamaRouter(hosts []string, balance string) (*ollamaRouter, error) {
+	if len(hosts) == 0 {
+		hosts = []string{""}
+	}
+	r := &ollamaRouter{balance: balance}
+	for _, host := range hosts {
+		client, err := models.NewClient(host)
+		if err != nil {
+			return nil, err
+		}
+		r.backends = append(r.backends, &ollamaBackend{host: host, client: client})
+	}
+	return r, nil
+}
+
+// pick 在未尝试过的后端中选择：优先可用且有该模型的，其次可用的，最后是标记为不可用的 (可能已恢复)
+func (r *ollamaRouter) pick(model string, tried []*ollamaBackend) *ollamaBackend {
+	var candidates []*ollamaBackend
+	for _, match := range []func(b *ollamaBackend) bool{
+		func(b *ollamaBackend) bool { return b.healthy() && b.has(model) },
+		func(b *ollamaBackend) bool { return b.healthy() },
+		func(b *ollamaBackend) bool { return true },
+	} {
+		for _, b := range r.backends {
+			if !slices.Contains(tried, b) && match(b) {
+				candidates = append(candidates, b)
+			}
+		}
+		if len(candidates) > 0 {
+			break
+		}
+	}
+	if len(candidates) == 0 {
+		return nil
+	}
+	switch r.balance {
+	case BalanceRoundRobin:
+		return candidates[int(r.next.Add(1)-1)%len(candidates)]
+	case BalanceFirst:
+		return candidates[0]
+	default:
+		best := candidates[0]
+		for _, b := range candidates[1:] {
+			if b.inflight.Load() < best.inflight.Load() {
+				best = b
+			}
+		}
+		return best
+	}
+}
+
+// do 在选出的后端上调用 fn，连接错误或 5xx 时标记该后端并换下一个，404 (该后端没有模型) 时同样换下一个；
+// started 非 nil 且返回 true 时 (已向云端输出部分结果) 不再换后端
+func (r *ollamaRouter) do(ctx context.Context, model string, started func() bool, fn func(client *api.Client) error) error {
+	var tried []*ollamaBackend
+	var err error
+	for {
+		b := r.pick(model, tried)
+		if b == nil {
+			return err
+		}
+		tried = append(tried, b)
+		b.inflight.Add(1)
+		err = fn(b.client)
+		b.inflight.Add(-1)
+		if err == nil {
+			b.setDown(nil)
+			return nil
+		}
+		// 404 表示该后端没有模型 (模型列表尚未刷新)，其他后端仍可能有
+		var status api.StatusError
+		notFound := errors.As(err, &status) && status.StatusCode == http.StatusNotFound
+		if !notFound {
+			if !isRetryable(err) {
+				return err
+			}
+			b.setDown(err)
+		}
+		if started != nil && started() || len(tried) == len(r.backends) || ctx.Err() != nil {
+			return err
+		}
+		ollamaStats.Add("failovers", 1)
+	}
+}
+
+// all 对全部后端调用 fn，删除、复制等操作需要作用于每个有该模型的后端：
+// 任一后端成功时忽略其余后端的 404 (没有该模型)，否则返回第一个错误
+func (r *ollamaRouter) all(fn func(client *api.Client) error) error {
+	var first error
+	ok := false
+	for _, b := range r.backends {
+		err := fn(b.client)
+		var status api.StatusError
+		switch {
+		case err == nil:
+			ok = true
+		case errors.As(err, &status) && status.StatusCode == http.StatusNotFound:
+			if first == nil {
+				first = err
+			}
+		default:
+			if len(r.backends) > 1 {
+				err = fmt.Errorf("%s: %w", b.host, err)
+			}
+			return err
+		}
+	}
+	if ok {
+		return nil
+	}
+	return first
+}
+
+// list 汇总各可用后端的模型，同名模型只保留一次，任一后端已加载即视为已加载；全部失败时返回错误
+func (r *ollamaRouter) list(ctx context.Context) ([]ModelInfo, error) {
+	if len(r.backends) == 1 {
+		return models.List(ctx, r.backends[0].client)
+	}
+	var all []ModelInfo
+	var errs []error
+	index := make(map[string]int)
+	for _, b := range r.backends {
+		list, err := models.List(ctx, b.client)
+		if err != nil {
+			b.setDown(err)
+			errs = append(errs, fmt.Errorf("%s: %w", b.host, err))
+			continue
+		}
+		b.setDown(nil)
+		b.setModels(list)
+		for _, m := range list {
+			if i, ok := index[m.Name]; ok {
+				all[i].Loaded = all[i].Loaded || m.Loaded
+				continue
+			}
+			index[m.Name] = len(all)
+			all = append(all, m)
+		}
+	}
+	if len(errs) == len(r.backends) {
+		return nil, errors.Join(errs...)
+	}
+	return all, nil
+}
+
+// heartbeat 探测全部后端并更新可用状态与模型列表，任一可用即返回 nil
+func (r *ollamaRouter) heartbeat(ctx context.Context) error {
+	if len(r.backends) == 1 {
+		err := r.backends[0].client.Heartbeat(ctx)
+		r.backends[0].setDown(err)
+		return err
+	}
+	var errs []error
+	for _, b := range r.backends {
+		err := b.client.Heartbeat(ctx)
+		if err == nil {
+			// 模型列表只用于调度，失败时保留上次的结果
+			if list, err := models.List(ctx, b.client); err == nil {
+				b.setModels(list)
+			}
+			b.setDown(nil)
+			continue
+		}
+		b.setDown(err)
+		errs = append(errs, fmt.Errorf("%s: %w", b.host, err))
+	}
+	if len(errs) == len(r.backends) {
+		return errors.Join(errs...)
+	}
+	return nil
+}
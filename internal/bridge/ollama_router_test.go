@@ -0,0 +1,124 @@
+package bridge
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ollama/ollama/api"
+)
+
+// fakeOllamaServer 模拟一个只有 models 中模型的 Ollama，对话回复为 name
+func fakeOllamaServer(t *testing.T, name string, models ...string) *httptest.Server {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/tags":
+			var list api.ListResponse
+			for _, m := range models {
+				list.Models = append(list.Models, api.ListModelResponse{Name: m})
+			}
+			json.NewEncoder(w).Encode(list)
+		case "/api/ps":
+			json.NewEncoder(w).Encode(api.ProcessResponse{})
+		case "/api/chat":
+			var req api.ChatRequest
+			json.NewDecoder(r.Body).Decode(&req)
+			for _, m := range models {
+				if m == req.Model {
+					json.NewEncoder(w).Encode(api.ChatResponse{Model: m, Message: api.Message{Role: "assistant", Content: name}, Done: true})
+					return
+				}
+			}
+			w.WriteHeader(http.StatusNotFound)
+			json.NewEncoder(w).Encode(map[string]string{"error": "model not found"})
+		default:
+			w.WriteHeader(http.StatusOK)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func routedClient(t *testing.T, balance string, hosts ...string) *DefaultOllamaClient {
+	c, err := NewOllamaClient("", NewMemoryCache(0, 0), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.SetBackends(hosts, balance); err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func TestRouterPrefersBackendWithModel(t *testing.T) {
+	a := fakeOllamaServer(t, "a", "llama3")
+	b := fakeOllamaServer(t, "b", "qwen2")
+	c := routedClient(t, BalanceFirst, a.URL, b.URL)
+	ctx := context.Background()
+
+	// 尚未获取模型列表时按顺序尝试，a 返回 404 后切换到 b
+	reply, err := c.Chat(ctx, ChatRequest{Model: "qwen2"})
+	if err != nil || reply.Content != "b" {
+		t.Fatalf("expected failover to b on 404, got %q %v", reply.Content, err)
+	}
+	if err := c.Heartbeat(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if got := c.router.pick("qwen2", nil); got.host != b.URL {
+		t.Errorf("expected qwen2 routed to b, got %s", got.host)
+	}
+
+	// 模型列表为各后端的并集
+	list, err := c.ListModels(ctx)
+	if err != nil || len(list) != 2 {
+		t.Errorf("expected merged model list, got %+v %v", list, err)
+	}
+}
+
+func TestRouterFailsOverWhenBackendDown(t *testing.T) {
+	down := fakeOllamaServer(t, "down", "llama3")
+	down.Close()
+	up := fakeOllamaServer(t, "up", "llama3")
+	c := routedClient(t, BalanceFirst, down.URL, up.URL)
+	ctx := context.Background()
+
+	reply, err := c.Chat(ctx, ChatRequest{Model: "llama3"})
+	if err != nil || reply.Content != "up" {
+		t.Fatalf("expected failover to healthy backend, got %q %v", reply.Content, err)
+	}
+	// 失败的后端被标记为不可用，之后直接使用可用的
+	if c.router.backends[0].healthy() || c.router.pick("llama3", nil).host != up.URL {
+		t.Error("down backend should be skipped")
+	}
+	// 任一后端可用时心跳成功
+	if err := c.Heartbeat(ctx); err != nil {
+		t.Errorf("heartbeat should succeed with one healthy backend: %v", err)
+	}
+}
+
+func TestRouterBalance(t *testing.T) {
+	a := fakeOllamaServer(t, "a", "llama3")
+	b := fakeOllamaServer(t, "b", "llama3")
+
+	c := routedClient(t, BalanceRoundRobin, a.URL, b.URL)
+	var got []string
+	for range 4 {
+		reply, err := c.Chat(context.Background(), ChatRequest{Model: "llama3"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, reply.Content)
+	}
+	if got[0] == got[1] || got[0] != got[2] || got[1] != got[3] {
+		t.Errorf("round_robin should alternate, got %v", got)
+	}
+
+	// least_loaded 选择进行中请求最少的后端
+	c = routedClient(t, BalanceLeastLoaded, a.URL, b.URL)
+	c.router.backends[0].inflight.Add(2)
+	if reply, _ := c.Chat(context.Background(), ChatRequest{Model: "llama3"}); reply.Content != "b" {
+		t.Errorf("expected least loaded backend b, got %q", reply.Content)
+	}
+}
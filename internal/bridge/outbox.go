@@ -0,0 +1,68 @@
+package bridge
+
+import (
+	"sync"
+)
+
+// Outbox 暂存写入失败的响应帧，重连后按原顺序重发，帧内保留原 request_id
+type Outbox interface {
+	Push(frame []byte) error
+	Peek() ([]byte, bool) // 最早的一帧，队列为空时返回 false
+	Pop() error           // 移除最早的一帧
+	Len() int
+}
+
+// memoryOutbox 内存中的有界队列，满时丢弃最早的帧
+type memoryOutbox struct {
+	mu      sync.Mutex
+	frames  [][]byte
+	max     int
+	dropped func(frame []byte) // 丢弃帧时回调，可为 nil
+}
+
+// newMemoryOutbox 创建容量为 size 的队列，size 为 0 时返回 nil，表示不缓存
+func newMemoryOutbox(size int) *memoryOutbox {
+	if size <= 0 {
+		return nil
+	}
+	return &memoryOutbox{max: size}
+}
+
+func (o *memoryOutbox) Push(frame []byte) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if len(o.frames) >= o.max {
+		if o.dropped != nil {
+			o.dropped(o.frames[0])
+		}
+		o.frames[0] = nil
+		o.frames = o.frames[1:]
+	}
+	o.frames = append(o.frames, frame)
+	return nil
+}
+
+func (o *memoryOutbox) Peek() ([]byte, bool) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if len(o.frames) == 0 {
+		return nil, false
+	}
+	return o.frames[0], true
+}
+
+func (o *memoryOutbox) Pop() error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if len(o.frames) > 0 {
+		o.frames[0] = nil
+		o.frames = o.frames[1:]
+	}
+	return nil
+}
+
+func (o *memoryOutbox) Len() int {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return len(o.frames)
+}
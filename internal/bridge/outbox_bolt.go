@@ -0,0 +1,116 @@
+package bridge
+
+import (
+	"encoding/binary"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+var outboxBucket = []byte("outbox")
+
+// BoltOutbox 持久化到 bbolt 文件的待发送队列，进程崩溃重启后仍可送达已生成的响应
+type BoltOutbox struct {
+	db      *bolt.DB
+	max     int
+	dropped func(frame []byte) // 丢弃帧时回调，可为 nil
+}
+
+// OpenBoltOutbox 打开或创建队列文件，已有的帧保留，最多保存 size 条
+func OpenBoltOutbox(path string, size int) (*BoltOutbox, error) {
+	if dir := filepath.Dir(path); dir != "." {
+		if err := os.MkdirAll(dir, 0o700); err != nil {
+			return nil, fmt.Errorf("创建待发送队列目录失败: %w", err)
+		}
+	}
+	// 文件被其他 bridge 进程占用时不无限等待
+	db, err := bolt.Open(path, 0o600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("打开待发送队列文件失败: %w", err)
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(outboxBucket)
+		return err
+	})
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("初始化待发送队列失败: %w", err)
+	}
+	return &BoltOutbox{db: db, max: size}, nil
+}
+
+// Push 以递增序号为键追加一帧，超过容量时删除最早的帧
+func (o *BoltOutbox) Push(frame []byte) error {
+	return o.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(outboxBucket)
+		seq, err := b.NextSequence()
+		if err != nil {
+			return err
+		}
+		key := make([]byte, 8)
+		binary.BigEndian.PutUint64(key, seq)
+		if err := b.Put(key, frame); err != nil {
+			return err
+		}
+		c := b.Cursor()
+		for n := countKeys(b); n > o.max; n-- {
+			_, v := c.First()
+			if o.dropped != nil {
+				o.dropped(v)
+			}
+			if err := c.Delete(); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Peek 返回最早的一帧，返回值在事务外复制，可安全持有
+func (o *BoltOutbox) Peek() ([]byte, bool) {
+	var frame []byte
+	_ = o.db.View(func(tx *bolt.Tx) error {
+		if _, v := tx.Bucket(outboxBucket).Cursor().First(); v != nil {
+			frame = append([]byte(nil), v...)
+		}
+		return nil
+	})
+	return frame, frame != nil
+}
+
+func (o *BoltOutbox) Pop() error {
+	return o.db.Update(func(tx *bolt.Tx) error {
+		c := tx.Bucket(outboxBucket).Cursor()
+		if k, _ := c.First(); k != nil {
+			return c.Delete()
+		}
+		return nil
+	})
+}
+
+func (o *BoltOutbox) Len() int {
+	n := 0
+	_ = o.db.View(func(tx *bolt.Tx) error {
+		n = countKeys(tx.Bucket(outboxBucket))
+		return nil
+	})
+	return n
+}
+
+// countKeys 遍历计数，写事务中 Stats 不反映尚未提交的修改
+func countKeys(b *bolt.Bucket) int {
+	n := 0
+	c := b.Cursor()
+	for k, _ := c.First(); k != nil; k, _ = c.Next() {
+		n++
+	}
+	return n
+}
+
+// Close 关闭队列文件
+func (o *BoltOutbox) Close() error {
+	return o.db.Close()
+}
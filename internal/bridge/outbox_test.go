@@ -0,0 +1,133 @@
+package bridge
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"ollama_dev/internal/config"
+)
+
+// flakyWSClient down 为 true 时写入失败，模拟生成途中连接断开
+type flakyWSClient struct {
+	fakeWSClient
+	down bool
+}
+
+func (f *flakyWSClient) WriteMessage(message []byte) error {
+	if f.down {
+		return errors.New("broken pipe")
+	}
+	return f.fakeWSClient.WriteMessage(message)
+}
+
+func TestMemoryOutboxDropsOldest(t *testing.T) {
+	o := newMemoryOutbox(2)
+	var dropped []string
+	o.dropped = func(frame []byte) { dropped = append(dropped, string(frame)) }
+	for _, f := range []string{"a", "b", "c"} {
+		_ = o.Push([]byte(f))
+	}
+
+	if len(dropped) != 1 || dropped[0] != "a" {
+		t.Fatalf("expected oldest frame dropped, got %v", dropped)
+	}
+	if frame, ok := o.Peek(); !ok || string(frame) != "b" {
+		t.Fatalf("expected b at head, got %q", frame)
+	}
+	_ = o.Pop()
+	_ = o.Pop()
+	if _, ok := o.Peek(); ok || o.Len() != 0 {
+		t.Errorf("expected empty outbox, len %d", o.Len())
+	}
+	if newMemoryOutbox(0) != nil {
+		t.Error("expected nil outbox for size 0")
+	}
+}
+
+func TestFailedResponseResentAfterReconnect(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	ws := &flakyWSClient{down: true}
+	s := NewServer(ws, NewHandlerFactory(&countingOllama{}, logger), nil, config.Default().Bridge, logger)
+
+	msg, err := parseMessage([]byte(chatFrame), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.handleServerRequest(msg); err == nil {
+		t.Fatal("expected write error while connection is down")
+	}
+	if s.outbox.Len() != 1 {
+		t.Fatalf("expected response queued, got %d", s.outbox.Len())
+	}
+
+	// 重连后按原 request_id 重发
+	ws.down = false
+	s.flushOutbox()
+	if s.outbox.Len() != 0 || len(ws.written) != 1 {
+		t.Fatalf("expected queued response flushed, pending %d written %d", s.outbox.Len(), len(ws.written))
+	}
+	var resp CloudResponse
+	if err := json.Unmarshal(ws.written[0], &resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.RequestID != "r1" || resp.Status != "done" {
+		t.Errorf("unexpected resent frame: %s", ws.written[0])
+	}
+}
+
+func TestOutboxDisabled(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	cfg := config.Default().Bridge
+	cfg.OutboxSize = 0
+	s := NewServer(&flakyWSClient{down: true}, NewHandlerFactory(&countingOllama{}, logger), nil, cfg, logger)
+	if s.outbox != nil {
+		t.Fatal("expected no outbox when outbox_size is 0")
+	}
+	// 未启用时写入失败直接丢弃
+	s.flushOutbox()
+}
+
+func TestBoltOutboxSurvivesRestart(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "outbox.db")
+	o, err := OpenBoltOutbox(path, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range []string{"a", "b", "c"} {
+		if err := o.Push([]byte(f)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := o.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// 模拟重启：重新打开后按原顺序取出，超出容量的最早一帧已丢弃
+	o, err = OpenBoltOutbox(path, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer o.Close()
+	if o.Len() != 2 {
+		t.Fatalf("expected 2 frames, got %d", o.Len())
+	}
+	var got []string
+	for {
+		frame, ok := o.Peek()
+		if !ok {
+			break
+		}
+		got = append(got, string(frame))
+		if err := o.Pop(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if strings.Join(got, ",") != "b,c" {
+		t.Errorf("expected b,c, got %v", got)
+	}
+}
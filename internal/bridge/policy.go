@@ -0,0 +1,57 @@
+package bridge
+
+import (
+	"context"
+
+	"ollama_dev/internal/policy"
+)
+
+// policyClient 在调用 Ollama 前按 bridge.policy_file 检查模型与 options，
+// 供 serve 的 /api、/v1 与 /ws 本地对话，以及桥接端的自定义动作使用；桥接端的内置动作由各自的 handler 检查
+type policyClient struct {
+	OllamaClient
+	policy *policy.Watcher
+}
+
+// WithPolicy 返回按策略检查请求的客户端，p 为 nil 时原样返回
+func WithPolicy(c OllamaClient, p *policy.Watcher) OllamaClient {
+	if p == nil {
+		return c
+	}
+	return &policyClient{OllamaClient: c, policy: p}
+}
+
+func (c *policyClient) Chat(ctx context.Context, req ChatRequest) (Reply, error) {
+	options, err := c.policy.Apply(req.Model, req.Options)
+	if err != nil {
+		return Reply{}, err
+	}
+	req.Options = options
+	return c.OllamaClient.Chat(ctx, req)
+}
+
+func (c *policyClient) ChatStream(ctx context.Context, req ChatRequest, onChunk func(string) error) (Reply, error) {
+	options, err := c.policy.Apply(req.Model, req.Options)
+	if err != nil {
+		return Reply{}, err
+	}
+	req.Options = options
+	return c.OllamaClient.ChatStream(ctx, req, onChunk)
+}
+
+func (c *policyClient) Generate(ctx context.Context, req GenerateRequest, onChunk func(string) error) (Reply, error) {
+	options, err := c.policy.Apply(req.Model, req.Options)
+	if err != nil {
+		return Reply{}, err
+	}
+	req.Options = options
+	return c.OllamaClient.Generate(ctx, req, onChunk)
+}
+
+// Embed 只检查模型与 options，不补全 num_predict
+func (c *policyClient) Embed(ctx context.Context, req EmbedRequest) (Embeddings, error) {
+	if _, err := c.policy.Apply(req.Model, req.Options); err != nil {
+		return Embeddings{}, err
+	}
+	return c.OllamaClient.Embed(ctx, req)
+}
@@ -0,0 +1,141 @@
+package bridge
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ollama/ollama/api"
+
+	"ollama_dev/internal/apperr"
+	"ollama_dev/internal/auth"
+	"ollama_dev/internal/policy"
+)
+
+func TestWithPolicyChecksDirectCalls(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "policy.yaml")
+	if err := os.WriteFile(path, []byte("models:\n  - name: llama3\n    max_num_predict: 64\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	p, err := policy.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ollama := &streamingOllama{chunks: []string{"ok"}}
+	c := WithPolicy(ollama, p)
+	ctx := context.Background()
+
+	if _, err := c.Chat(ctx, ChatRequest{Model: "llama3"}); err != nil {
+		t.Fatalf("allowed chat: %v", err)
+	}
+	if got := ollama.chat.Options["num_predict"]; got != 64 {
+		t.Errorf("num_predict = %v, want the policy default 64", got)
+	}
+	cases := []struct {
+		name string
+		call func() error
+		code string
+	}{
+		{"chat", func() error { _, err := c.Chat(ctx, ChatRequest{Model: "mistral"}); return err }, apperr.CodeModelNotAllowed},
+		{"chat_stream", func() error {
+			_, err := c.ChatStream(ctx, ChatRequest{Model: "mistral"}, func(string) error { return nil })
+			return err
+		}, apperr.CodeModelNotAllowed},
+		{"generate", func() error {
+			_, err := c.Generate(ctx, GenerateRequest{Model: "llama3", Options: map[string]any{"num_predict": 128.0}}, nil)
+			return err
+		}, apperr.CodePolicyViolation},
+		{"embed", func() error { _, err := c.Embed(ctx, EmbedRequest{Model: "mistral", Input: []string{"x"}}); return err }, apperr.CodeModelNotAllowed},
+		{"embed_allowed", func() error { _, err := c.Embed(ctx, EmbedRequest{Model: "llama3", Input: []string{"x"}}); return err }, ""},
+	}
+	for _, tc := range cases {
+		if got := apperr.CodeOf(tc.call()); got != tc.code {
+			t.Errorf("%s: code = %q, want %q", tc.name, got, tc.code)
+		}
+	}
+
+	if WithPolicy(ollama, nil) != OllamaClient(ollama) {
+		t.Error("WithPolicy(nil) should return the client unchanged")
+	}
+}
+
+// policyChatHandler 测试用的自定义动作，以 params.model_name 调用 Ollama 对话
+type policyChatHandler struct{ ollama OllamaClient }
+
+func (h policyChatHandler) Handle(ctx context.Context, req *CloudRequest) (*CloudResponse, error) {
+	if _, err := h.ollama.Chat(ctx, ChatRequest{Model: req.Params.ModelName}); err != nil {
+		return nil, err
+	}
+	return newResponse(req, nil), nil
+}
+
+func init() {
+	RegisterAction(Action{Name: "test_policy_chat", New: func(c OllamaClient, _ Logger) RequestHandler { return policyChatHandler{c} }})
+}
+
+func TestPolicyCoversModelActions(t *testing.T) {
+	var calls []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls = append(calls, r.URL.Path)
+		if r.URL.Path == "/api/create" {
+			json.NewEncoder(w).Encode(api.ProgressResponse{Status: "success"})
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]any{})
+	}))
+	defer srv.Close()
+	c, err := NewOllamaClient(srv.URL, NewMemoryCache(0, 0), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "policy.yaml")
+	if err := os.WriteFile(path, []byte("models:\n  - name: llama3\n  - name: ops-*\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	p, err := policy.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	factory := NewHandlerFactory(&fakeOllama{}, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	factory.SetModelManager(c)
+	factory.SetModelLoader(c)
+	factory.SetModelCreator(c)
+	factory.SetPolicy(p)
+
+	cases := []struct {
+		name   string
+		action string
+		params CloudParams
+		code   string
+	}{
+		{"copy from a disallowed source", "copy_model", CloudParams{ModelName: "mistral", Destination: "ops-copy"}, apperr.CodeModelNotAllowed},
+		{"copy to a disallowed name", "copy_model", CloudParams{ModelName: "llama3", Destination: "mine"}, apperr.CodeModelNotAllowed},
+		{"copy allowed", "copy_model", CloudParams{ModelName: "llama3", Destination: "ops-copy"}, ""},
+		{"create from a disallowed FROM", "create_model", CloudParams{ModelName: "ops-a", Modelfile: "FROM mistral\n"}, apperr.CodeModelNotAllowed},
+		{"create with a disallowed from", "create_model", CloudParams{ModelName: "ops-a", Modelfile: "FROM llama3\n", From: "mistral"}, apperr.CodeModelNotAllowed},
+		{"create a disallowed name", "create_model", CloudParams{ModelName: "mine", From: "llama3"}, apperr.CodeModelNotAllowed},
+		{"create allowed", "create_model", CloudParams{ModelName: "ops-a", From: "llama3"}, ""},
+		{"load a disallowed model", "load_model", CloudParams{ModelName: "mistral"}, apperr.CodeModelNotAllowed},
+		{"load allowed", "load_model", CloudParams{ModelName: "llama3"}, ""},
+		{"custom action with a disallowed model", "test_policy_chat", CloudParams{ModelName: "mistral"}, apperr.CodeModelNotAllowed},
+		{"custom action allowed", "test_policy_chat", CloudParams{ModelName: "llama3"}, ""},
+	}
+	for _, tc := range cases {
+		calls = nil
+		req := &CloudRequest{Action: tc.action, RequestID: "1", Role: auth.RoleAdmin, Params: tc.params}
+		_, err := factory.CreateHandler(tc.action).Handle(context.Background(), req)
+		if got := apperr.CodeOf(err); got != tc.code {
+			t.Errorf("%s: code = %q (%v), want %q", tc.name, got, err, tc.code)
+		}
+		// 被策略拒绝的请求不到达 Ollama
+		if tc.code != "" && len(calls) != 0 {
+			t.Errorf("%s: Ollama was called: %v", tc.name, calls)
+		}
+	}
+}
@@ -0,0 +1,69 @@
+package bridge
+
+import "sync"
+
+// 请求与响应信封在每条消息上都会创建，高消息速率下复用以降低 GC 压力
+var (
+	requestPool  = sync.Pool{New: func() any { return new(CloudRequest) }}
+	responsePool = sync.Pool{New: func() any { return new(CloudResponse) }}
+)
+
+// Reset 清空请求，保留 Messages 的底层数组以便复用
+func (r *CloudRequest) Reset() {
+	clear(r.Params.Messages)
+	*r = CloudRequest{Params: CloudParams{Messages: r.Params.Messages[:0]}}
+}
+
+// Reset 清空响应
+func (r *CloudResponse) Reset() {
+	*r = CloudResponse{}
+}
+
+// acquireRequest 从池中取出已清空的请求
+func acquireRequest() *CloudRequest {
+	return requestPool.Get().(*CloudRequest)
+}
+
+// releaseRequest 清空请求并放回池中，调用后不得再使用 req
+func releaseRequest(req *CloudRequest) {
+	if req == nil {
+		return
+	}
+	req.Reset()
+	requestPool.Put(req)
+}
+
+// acquireResponse 从池中取出已清空的响应
+func acquireResponse() *CloudResponse {
+	return responsePool.Get().(*CloudResponse)
+}
+
+// releaseResponse 清空响应并放回池中，调用后不得再使用 resp
+func releaseResponse(resp *CloudResponse) {
+	if resp == nil {
+		return
+	}
+	resp.Reset()
+	responsePool.Put(resp)
+}
+
+// newResponse 从池中取出响应并填充为 req 的成功应答
+func newResponse(req *CloudRequest, data any) *CloudResponse {
+	resp := acquireResponse()
+	resp.V = ProtocolVersion
+	resp.Type = TypeClientToServer
+	resp.Action = req.Action
+	resp.RequestID = req.RequestID
+	resp.TenantID = req.TenantID
+	resp.sealed = req.sealed
+	resp.Data = data
+	resp.Status = StatusDone
+	return resp
+}
+
+// release 消息处理完毕后归还请求与响应
+func (m *Message) release() {
+	releaseRequest(m.Request)
+	releaseResponse(m.Response)
+	m.Request, m.Response = nil, nil
+}
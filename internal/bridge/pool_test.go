@@ -0,0 +1,99 @@
+package bridge
+
+import (
+	"io"
+	"log/slog"
+	"reflect"
+	"runtime"
+	"testing"
+
+	"ollama_dev/internal/config"
+)
+
+func TestRequestReset(t *testing.T) {
+	req := &CloudRequest{
+		Type:      "server_to_client",
+		Action:    "chat",
+		RequestID: "1",
+		Params: CloudParams{
+			ModelName: "llama3",
+			Messages:  []ChatMessage{{Role: "user", Content: "secret"}},
+			Backend:   &BackendStatus{},
+		},
+	}
+	backing := req.Params.Messages[:1]
+	req.Reset()
+
+	if req.Type != "" || req.Action != "" || req.RequestID != "" || req.Params.ModelName != "" || req.Params.Backend != nil {
+		t.Errorf("Reset left fields set: %+v", req)
+	}
+	if len(req.Params.Messages) != 0 || cap(req.Params.Messages) == 0 {
+		t.Errorf("Reset should keep the Messages backing array, got len=%d cap=%d", len(req.Params.Messages), cap(req.Params.Messages))
+	}
+	// 复用前必须清掉旧内容，避免提示词残留在池中
+	if !reflect.DeepEqual(backing[0], ChatMessage{}) {
+		t.Errorf("Reset left message content in backing array: %+v", backing[0])
+	}
+}
+
+func TestReleasedEnvelopesAreClean(t *testing.T) {
+	resp := newResponse(&CloudRequest{Action: "version", RequestID: "1"}, "data")
+	releaseResponse(resp)
+	if got := acquireResponse(); *got != (CloudResponse{}) {
+		t.Errorf("acquireResponse returned dirty response: %+v", got)
+	}
+
+	msg, err := parseMessage([]byte(`{"type":"server_to_client","action":"version"}`), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg.release()
+	if msg.Request != nil || msg.Response != nil {
+		t.Error("release should detach request and response")
+	}
+	// nil 安全
+	releaseRequest(nil)
+	releaseResponse(nil)
+}
+
+var sinkResponse *CloudResponse
+
+// BenchmarkEnvelope 对比每条消息新建信封与复用池中信封的分配情况
+func BenchmarkEnvelope(b *testing.B) {
+	b.Run("alloc", func(b *testing.B) {
+		b.ReportAllocs()
+		for b.Loop() {
+			req := &CloudRequest{Type: "server_to_client", Action: "version", RequestID: "1"}
+			sinkResponse = &CloudResponse{Type: "client_to_server", Action: req.Action, RequestID: req.RequestID, Status: "done"}
+		}
+	})
+	b.Run("pool", func(b *testing.B) {
+		b.ReportAllocs()
+		for b.Loop() {
+			req := acquireRequest()
+			req.Type, req.Action, req.RequestID = "server_to_client", "version", "1"
+			sinkResponse = newResponse(req, nil)
+			releaseResponse(sinkResponse)
+			releaseRequest(req)
+		}
+	})
+}
+
+// BenchmarkReplayFrame 测量单帧解析、处理与回复的完整路径，并报告每千条消息触发的 GC 次数
+func BenchmarkReplayFrame(b *testing.B) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	factory := NewHandlerFactory(&fakeOllama{}, logger)
+	s := NewServer(&replayClient{out: io.Discard}, factory, nil, config.Default().Bridge, logger)
+	frame := []byte(`{"type":"server_to_client","action":"version"}`)
+
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	b.ReportAllocs()
+	for b.Loop() {
+		if err := s.replay(frame); err != nil {
+			b.Fatal(err)
+		}
+	}
+	runtime.ReadMemStats(&after)
+	b.ReportMetric(float64(after.NumGC-before.NumGC)*1000/float64(b.N), "gc/1k-msgs")
+}
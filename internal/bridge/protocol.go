@@ -0,0 +1,70 @@
+package bridge
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"ollama_dev/internal/apperr"
+)
+
+// 协议版本，所有帧的 v 字段携带发送方的版本
+const (
+	ProtocolV1      = 1 // 未携带 v 字段的旧版帧，list_model 响应中的 digest 放在 status 字段
+	ProtocolVersion = 2 // 当前版本
+)
+
+// migrations[v] 将 v 版本的帧升级到 v+1，读取旧版帧时依次应用直到当前版本
+var migrations = map[int]func(*Envelope) error{
+	ProtocolV1: migrateV1,
+}
+
+// Version 返回帧的协议版本，未携带 v 字段时视为 ProtocolV1
+func (e *Envelope) Version() int {
+	if e.V == 0 {
+		return ProtocolV1
+	}
+	return e.V
+}
+
+// Migrate 将旧版帧原地升级为当前版本的布局；更新版本的帧原样保留，
+// 新增字段在非 strict 模式下会被忽略，使云端与桥接客户端可以分别升级
+func Migrate(e *Envelope) error {
+	for v := e.Version(); v < ProtocolVersion; v++ {
+		migrate, ok := migrations[v]
+		if !ok {
+			return apperr.New(apperr.Protocol, apperr.CodeBadFrame, fmt.Sprintf("不支持的协议版本: %d", v))
+		}
+		if err := migrate(e); err != nil {
+			return apperr.Wrap(err, apperr.Protocol, apperr.CodeBadFrame, fmt.Sprintf("升级 v%d 帧失败", v))
+		}
+	}
+	if e.V < ProtocolVersion {
+		e.V = ProtocolVersion
+	}
+	return nil
+}
+
+// migrateV1 v1 的 list_model 响应以 status 字段存放 digest，改为 digest 字段
+func migrateV1(e *Envelope) error {
+	if e.Type != TypeClientToServer || e.Action != "list_model" || e.Status == StatusError || len(e.Data) == 0 {
+		return nil
+	}
+	var items []map[string]any
+	if err := json.Unmarshal(e.Data, &items); err != nil {
+		return err
+	}
+	for _, item := range items {
+		if status, ok := item["status"]; ok {
+			if _, has := item["digest"]; !has {
+				item["digest"] = status
+			}
+			delete(item, "status")
+		}
+	}
+	data, err := json.Marshal(items)
+	if err != nil {
+		return err
+	}
+	e.Data = data
+	return nil
+}
@@ -0,0 +1,88 @@
+package bridge
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"testing"
+
+	"ollama_dev/internal/apperr"
+	"ollama_dev/internal/config"
+)
+
+func TestMigrateV1ListModel(t *testing.T) {
+	// v1 的 list_model 响应以 status 字段存放 digest
+	env := &Envelope{
+		Type:   TypeClientToServer,
+		Action: "list_model",
+		Data:   json.RawMessage(`[{"model_name":"llama3:latest","status":"abc"}]`),
+		Status: "done",
+	}
+	if err := Migrate(env); err != nil {
+		t.Fatal(err)
+	}
+	if env.V != ProtocolVersion {
+		t.Errorf("expected v%d after migration, got v%d", ProtocolVersion, env.V)
+	}
+
+	var models []ModelInfo
+	if err := json.Unmarshal(env.Data, &models); err != nil {
+		t.Fatal(err)
+	}
+	if len(models) != 1 || models[0].Name != "llama3:latest" || models[0].Digest != "abc" {
+		t.Errorf("unexpected models after migration: %+v", models)
+	}
+}
+
+func TestMigrateKeepsCurrentAndNewerFrames(t *testing.T) {
+	data := `[{"model_name":"llama3:latest","digest":"abc","status":"x"}]`
+	for _, v := range []int{ProtocolVersion, ProtocolVersion + 1} {
+		env := &Envelope{V: v, Type: TypeClientToServer, Action: "list_model", Data: json.RawMessage(data)}
+		if err := Migrate(env); err != nil {
+			t.Fatalf("v%d: %v", v, err)
+		}
+		// 当前及更新版本的帧不做改写
+		if env.V != v || string(env.Data) != data {
+			t.Errorf("v%d: frame should be untouched, got v%d %s", v, env.V, env.Data)
+		}
+	}
+}
+
+func TestMigrateRejectsInvalidVersion(t *testing.T) {
+	env := &Envelope{V: -1, Type: TypeServerToClient, Action: "chat"}
+	if err := Migrate(env); apperr.CodeOf(err) != apperr.CodeBadFrame {
+		t.Errorf("expected bad_frame for v-1, got %v", err)
+	}
+}
+
+func TestEmittedFramesCarryVersion(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	var out bytes.Buffer
+	s := NewServer(&replayClient{out: &out}, NewHandlerFactory(&fakeOllama{err: errors.New("unused")}, logger), nil, config.Default().Bridge, logger)
+
+	// 旧版请求 (无 v 字段) 与错误帧的响应都以当前版本发出
+	_ = s.replay([]byte(`{"type":"server_to_client","action":"version","request_id":"1"}`))
+	_ = s.replay([]byte(`not json`))
+	if err := s.sendHeartbeat(); err != nil {
+		t.Fatal(err)
+	}
+
+	dec := json.NewDecoder(&out)
+	n := 0
+	for ; ; n++ {
+		var frame struct {
+			V *int `json:"v"`
+		}
+		if err := dec.Decode(&frame); err != nil {
+			break
+		}
+		if frame.V == nil || *frame.V != ProtocolVersion {
+			t.Errorf("frame %d: expected v=%d", n, ProtocolVersion)
+		}
+	}
+	if n != 3 {
+		t.Errorf("expected 3 frames, got %d", n)
+	}
+}
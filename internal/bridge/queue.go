@@ -0,0 +1,543 @@
+package bridge
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"ollama_dev/internal/apperr"
+	"ollama_dev/internal/config"
+	"ollama_dev/internal/metrics"
+)
+
+// 任务队列的优先级，params.priority 未指定时为 normal
+const (
+	PriorityHigh   = "high"
+	PriorityNormal = "normal"
+	PriorityBatch  = "batch"
+)
+
+// priorities 按出队顺序排列
+var priorities = []string{PriorityHigh, PriorityNormal, PriorityBatch}
+
+// 排队任务的状态
+const (
+	JobQueued    = "queued"
+	JobRunning   = "running"
+	JobCancelled = "cancelled"
+)
+
+// queueActions 任务队列相关的动作
+var queueActions = []string{"job_status", "job_cancel"}
+
+// queueLength 各优先级排队中的任务数
+var queueLength = metrics.NewGauge("ollama_dev_job_queue_length", "任务队列中排队的任务数", "priority")
+
+// QueuedJob 排队任务的状态，作为 queued 帧以及 job_status、job_cancel 响应的 data
+type QueuedJob struct {
+	JobID      string    `json:"job_id"` // 即排队请求的 request_id
+	Action     string    `json:"action"`
+	Priority   string    `json:"priority"`
+	State      string    `json:"state"`              // queued、running 或 cancelled
+	Position   int       `json:"position,omitempty"` // 排队位置，1 表示下一个执行
+	EnqueuedAt time.Time `json:"enqueued_at"`
+}
+
+// queuedJob 队列中的一个请求，持久化时以 JSON 保存；加密的请求只保存密文 (Request.Sealed)，不写入解密后的 params
+type queuedJob struct {
+	Seq        uint64          `json:"seq"`
+	Priority   string          `json:"priority"`
+	EnqueuedAt time.Time       `json:"enqueued_at"`
+	Sealed     bool            `json:"sealed,omitempty"` // 请求经过端到端加密，响应同样加密
+	RawParams  json.RawMessage `json:"raw_params,omitempty"`
+	Request    *CloudRequest   `json:"request"`
+
+	// 请求开始执行后会被归还到池中，查询状态时使用这里的副本
+	id, action, tenant string
+	position           int  // 最近一次通知的排队位置
+	cancelled          bool // 开始执行前已被取消
+	locked             bool // 从文件恢复的加密请求，执行前需解密
+}
+
+func newQueuedJob(req *CloudRequest, priority string) *queuedJob {
+	j := &queuedJob{Priority: priority, EnqueuedAt: time.Now(), Sealed: req.sealed, RawParams: req.RawParams, Request: req}
+	j.init()
+	return j
+}
+
+// persisted 返回写入文件的副本：加密的请求去掉解密后的 params，只保留密文
+func (j *queuedJob) persisted() *queuedJob {
+	if !j.Sealed {
+		return j
+	}
+	req := *j.Request
+	req.Params, req.RawParams = CloudParams{}, nil
+	cp := *j
+	cp.RawParams, cp.Request = nil, &req
+	return &cp
+}
+
+func (j *queuedJob) init() {
+	j.id, j.action, j.tenant = j.Request.RequestID, j.Request.Action, j.Request.TenantID
+}
+
+func (j *queuedJob) info(state string, position int) QueuedJob {
+	return QueuedJob{JobID: j.id, Action: j.action, Priority: j.Priority, State: state, Position: position, EnqueuedAt: j.EnqueuedAt}
+}
+
+// jobPriority 校验 params.priority
+func jobPriority(p string) (string, error) {
+	if p == "" {
+		return PriorityNormal, nil
+	}
+	if !slices.Contains(priorities, p) {
+		return "", apperr.New(apperr.Validation, apperr.CodeInvalidParams, fmt.Sprintf("priority 的取值无效: %q，可选 high、normal、batch", p))
+	}
+	return p, nil
+}
+
+// jobQueue 按优先级排队的请求，同一优先级先到先执行；排队位置变化时通过 changed 通知
+type jobQueue struct {
+	maxSize int
+	actions []string
+	store   *queueStore
+
+	mu      sync.Mutex
+	cond    *sync.Cond
+	seq     uint64          // 未持久化时的序号
+	waiting [3][]*queuedJob // 按 priorities 的顺序
+	running map[string]*queuedJob
+	idle    int // 在 next 中等待的 worker 数
+	stopped bool
+	changed chan struct{}
+}
+
+func newJobQueue(cfg config.JobQueueConfig, store *queueStore) *jobQueue {
+	q := &jobQueue{maxSize: cfg.MaxSize, actions: cfg.Actions, store: store, running: map[string]*queuedJob{}, changed: make(chan struct{}, 1)}
+	q.cond = sync.NewCond(&q.mu)
+	return q
+}
+
+// handles 动作是否进入队列，q 为 nil 表示未启用队列
+func (q *jobQueue) handles(action string) bool {
+	return q != nil && slices.Contains(q.actions, action)
+}
+
+// push 持久化并排队请求，队列已满时返回 busy 错误
+func (q *jobQueue) push(req *CloudRequest) (*queuedJob, error) {
+	priority, err := jobPriority(req.Params.Priority)
+	if err != nil {
+		return nil, err
+	}
+	j := newQueuedJob(req, priority)
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.len() >= q.maxSize {
+		return nil, apperr.New(apperr.Backend, apperr.CodeBusy, "任务队列已满，稍后重试")
+	}
+	if q.store == nil {
+		q.seq++
+		j.Seq = q.seq
+	} else if err := q.store.add(j); err != nil {
+		return nil, apperr.Wrap(err, apperr.Internal, apperr.CodeInternal, "保存排队任务失败")
+	}
+	q.add(j)
+	return j, nil
+}
+
+// restore 重新排队上次未执行的任务
+func (q *jobQueue) restore(jobs []*queuedJob) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for _, j := range jobs {
+		j.init()
+		q.add(j)
+	}
+}
+
+// add 在 q.mu 中调用
+func (q *jobQueue) add(j *queuedJob) {
+	i := slices.Index(priorities, j.Priority)
+	if i < 0 {
+		i = slices.Index(priorities, PriorityNormal)
+	}
+	q.waiting[i] = append(q.waiting[i], j)
+	q.cond.Signal()
+	q.updated()
+}
+
+// len 在 q.mu 中调用，返回排队中的任务数
+func (q *jobQueue) len() int {
+	n := 0
+	for _, jobs := range q.waiting {
+		n += len(jobs)
+	}
+	return n
+}
+
+// updated 在 q.mu 中调用：更新指标并通知排队位置可能已变化
+func (q *jobQueue) updated() {
+	for i, jobs := range q.waiting {
+		queueLength.Set(float64(len(jobs)), priorities[i])
+	}
+	select {
+	case q.changed <- struct{}{}:
+	default:
+	}
+}
+
+// next 等待并取出优先级最高的任务，队列停止后返回 nil
+func (q *jobQueue) next() *queuedJob {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for !q.stopped && q.len() == 0 {
+		q.idle++
+		q.cond.Wait()
+		q.idle--
+	}
+	if q.stopped {
+		return nil
+	}
+	for i, jobs := range q.waiting {
+		if len(jobs) == 0 {
+			continue
+		}
+		j := jobs[0]
+		q.waiting[i] = jobs[1:]
+		q.running[j.id] = j
+		q.updated()
+		return j
+	}
+	return nil
+}
+
+// cancelledEarly 任务开始执行时调用，返回开始前是否已被取消
+func (q *jobQueue) cancelledEarly(j *queuedJob) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return j.cancelled
+}
+
+// finish 任务结束后删除
+func (q *jobQueue) finish(j *queuedJob) error {
+	q.mu.Lock()
+	delete(q.running, j.id)
+	q.mu.Unlock()
+	return q.store.remove(j.Seq)
+}
+
+// find 返回 tenant 的任务及其状态；排队中的任务同时返回位置
+func (q *jobQueue) find(tenant, id string) (QueuedJob, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if j, ok := q.running[id]; ok && j.tenant == tenant {
+		return j.info(JobRunning, 0), true
+	}
+	pos := 0
+	for _, jobs := range q.waiting {
+		for _, j := range jobs {
+			pos++
+			if j.id == id && j.tenant == tenant {
+				return j.info(JobQueued, pos), true
+			}
+		}
+	}
+	return QueuedJob{}, false
+}
+
+// remove 从队列中取出 tenant 排队中的任务并删除持久化记录
+func (q *jobQueue) remove(tenant, id string) (*queuedJob, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for i, jobs := range q.waiting {
+		k := slices.IndexFunc(jobs, func(j *queuedJob) bool { return j.id == id && j.tenant == tenant })
+		if k < 0 {
+			continue
+		}
+		j := jobs[k]
+		q.waiting[i] = slices.Delete(jobs, k, k+1)
+		q.updated()
+		_ = q.store.remove(j.Seq)
+		return j, true
+	}
+	return nil, false
+}
+
+// cancelRunning 标记 tenant 执行中的任务已被取消，尚未登记到 inflight 的任务开始时据此取消
+func (q *jobQueue) cancelRunning(tenant, id string) (*queuedJob, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	j, ok := q.running[id]
+	if !ok || j.tenant != tenant {
+		return nil, false
+	}
+	j.cancelled = true
+	return j, true
+}
+
+// positionUpdate 一个需要发送 queued 帧的任务
+type positionUpdate struct {
+	req  *CloudRequest
+	info QueuedJob
+}
+
+// positions 返回排队位置与上次通知不同的任务；空闲 worker 即将取走的任务不通知
+func (q *jobQueue) positions() []positionUpdate {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	var updates []positionUpdate
+	pos := 0
+	for _, jobs := range q.waiting {
+		for _, j := range jobs {
+			pos++
+			if pos > q.idle && j.position != pos {
+				j.position = pos
+				updates = append(updates, positionUpdate{req: j.Request, info: j.info(JobQueued, pos)})
+			}
+		}
+	}
+	return updates
+}
+
+// stop 停止出队并取出排队中的任务，执行中的任务不受影响
+func (q *jobQueue) stop() []*queuedJob {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.stopped = true
+	q.cond.Broadcast()
+	var jobs []*queuedJob
+	for i := range q.waiting {
+		jobs = append(jobs, q.waiting[i]...)
+		q.waiting[i] = nil
+	}
+	q.updated()
+	return jobs
+}
+
+// StartJobQueue 按配置启动任务队列并启用 job_status、job_cancel 动作，之后 actions 中的请求排队执行，
+// 文件中保存的任务重新入队；返回的函数停止出队并等待执行中的任务结束。cfg.Concurrency 为 0 时不启用
+func (s *Server) StartJobQueue(cfg config.JobQueueConfig) (stop func(), err error) {
+	if cfg.Concurrency <= 0 {
+		return func() {}, nil
+	}
+	var store *queueStore
+	if cfg.File != "" {
+		if store, err = openQueueStore(cfg.File); err != nil {
+			return nil, err
+		}
+	}
+	jobs, err := store.load()
+	if err != nil {
+		store.Close()
+		return nil, fmt.Errorf("读取任务队列失败: %w", err)
+	}
+	q := newJobQueue(cfg, store)
+	for _, j := range jobs {
+		// 重启后云端重发的相同请求不再重复排队
+		s.dedup.begin(j.Request.RequestID)
+	}
+	q.restore(jobs)
+	if len(jobs) > 0 {
+		s.logger.Info("存在上次未执行的排队任务，重新入队", "count", len(jobs), "path", cfg.File)
+	}
+	s.queue = q
+	s.handlerFactory.SetJobQueue(s)
+
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		s.notifyPositions(done)
+	}()
+	for range cfg.Concurrency {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := q.next(); j != nil; j = q.next() {
+				s.runJob(j)
+			}
+		}()
+	}
+	return func() {
+		s.stopQueue()
+		close(done)
+		wg.Wait()
+		store.Close()
+	}, nil
+}
+
+// enqueue 将请求放入任务队列，请求的所有权随之转移；优先级无效或队列已满时回复错误帧
+func (s *Server) enqueue(msg *Message) error {
+	if _, err := s.queue.push(msg.Request); err != nil {
+		s.dedup.forget(msg.Request.RequestID)
+		msg.Response = errorResponse(msg.Request, err)
+		if sendErr := s.sendResponse(msg); sendErr != nil {
+			return sendErr
+		}
+		return err
+	}
+	msg.Request = nil
+	return nil
+}
+
+// runJob 执行出队的任务，流式请求同样逐片段返回
+func (s *Server) runJob(j *queuedJob) {
+	// 等待正在发送的 queued 帧，任务开始后不再发送
+	s.queueNotify.Lock()
+	s.queueNotify.Unlock()
+	defer func() {
+		if err := s.queue.finish(j); err != nil {
+			s.logger.Error("删除已结束的排队任务失败", "request_id", j.id, "error", err)
+		}
+	}()
+	req := j.Request
+	if j.locked {
+		if err := s.e2e.openQueued(req); err != nil {
+			s.logger.Error("解密排队任务失败", "request_id", j.id, "error", err)
+			s.replyJob(j, err)
+			return
+		}
+		j.locked = false
+	}
+	ctx, done := s.inflight.begin(req.RequestID)
+	defer done()
+	if s.queue.cancelledEarly(j) {
+		s.inflight.cancel(req.RequestID)
+	}
+	if req.Params.Stream {
+		if h, ok := s.handlerFactory.CreateHandler(req.Action).(StreamHandler); ok {
+			s.stream(ctx, req, h)
+			return
+		}
+	}
+	msg := &Message{Request: req}
+	defer msg.release()
+	if err := s.process(ctx, msg); err != nil {
+		s.logger.Error("处理排队任务失败", "action", j.action, "request_id", j.id, "error", err)
+	}
+}
+
+// notifyPositions 排队位置变化后向对应请求发送 queued 帧，直到 done 关闭；连接断开时不发送，也不进入待发送队列
+func (s *Server) notifyPositions(done <-chan struct{}) {
+	for {
+		select {
+		case <-done:
+			return
+		case <-s.queue.changed:
+		}
+		s.queueNotify.Lock()
+		for _, u := range s.queue.positions() {
+			resp := newResponse(u.req, u.info)
+			resp.Status = StatusQueued
+			_ = s.writeResponse(resp)
+			releaseResponse(resp)
+		}
+		s.queueNotify.Unlock()
+	}
+}
+
+// stopQueue 停止出队；排队中的任务已持久化时留待重启后执行，否则回复 errShuttingDown
+func (s *Server) stopQueue() {
+	if s.queue == nil {
+		return
+	}
+	s.queueNotify.Lock()
+	jobs := s.queue.stop()
+	s.queueNotify.Unlock()
+	if len(jobs) == 0 {
+		return
+	}
+	if s.queue.store != nil {
+		s.logger.Info("排队中的任务已保存，重启后继续执行", "count", len(jobs))
+		for _, j := range jobs {
+			releaseRequest(j.Request)
+		}
+		return
+	}
+	for _, j := range jobs {
+		s.replyJob(j, errShuttingDown)
+	}
+}
+
+// replyJob 以 err 回复未执行的任务并归还请求
+func (s *Server) replyJob(j *queuedJob, err error) {
+	s.dedup.forget(j.id)
+	msg := &Message{Request: j.Request, Response: errorResponse(j.Request, err)}
+	defer msg.release()
+	if sendErr := s.sendResponse(msg); sendErr != nil {
+		s.logger.Error("回复排队任务失败", "request_id", j.id, "error", sendErr)
+	}
+}
+
+// JobStatus 返回 tenant 的排队或执行中的任务
+func (s *Server) JobStatus(tenant, id string) (QueuedJob, bool) {
+	if s.queue == nil {
+		return QueuedJob{}, false
+	}
+	return s.queue.find(tenant, id)
+}
+
+// CancelJob 取消 tenant 的任务：排队中的任务移出队列，执行中的任务取消对 Ollama 的调用，
+// 原请求均回复 cancelled 错误；任务不存在或已结束时返回 false
+func (s *Server) CancelJob(tenant, id string) (QueuedJob, bool) {
+	if s.queue == nil {
+		return QueuedJob{}, false
+	}
+	s.queueNotify.Lock()
+	j, ok := s.queue.remove(tenant, id)
+	s.queueNotify.Unlock()
+	if ok {
+		info := j.info(JobCancelled, 0)
+		s.replyJob(j, errCancelled)
+		return info, true
+	}
+	if j, ok = s.queue.cancelRunning(tenant, id); !ok {
+		return QueuedJob{}, false
+	}
+	s.inflight.cancel(id)
+	return j.info(JobCancelled, 0), true
+}
+
+// JobQueue 查询与取消任务队列中的任务，由 Server 实现
+type JobQueue interface {
+	JobStatus(tenant, id string) (QueuedJob, bool)
+	CancelJob(tenant, id string) (QueuedJob, bool)
+}
+
+// SetJobQueue 启用 job_status 与 job_cancel 动作，q 为 nil 时不启用
+func (f *HandlerFactory) SetJobQueue(q JobQueue) {
+	f.queue = q
+}
+
+// QueueHandler job_status 查询 params.job_id 对应的排队任务，job_cancel 取消该任务；
+// 只能查询与取消同一租户的任务
+type QueueHandler struct {
+	queue JobQueue
+}
+
+func NewQueueHandler(q JobQueue) *QueueHandler {
+	return &QueueHandler{queue: q}
+}
+
+func (h *QueueHandler) Handle(ctx context.Context, req *CloudRequest) (*CloudResponse, error) {
+	if req.Params.JobID == "" {
+		return nil, apperr.New(apperr.Validation, apperr.CodeInvalidParams, "job_id 不能为空")
+	}
+	var (
+		job QueuedJob
+		ok  bool
+	)
+	if req.Action == "job_cancel" {
+		job, ok = h.queue.CancelJob(req.TenantID, req.Params.JobID)
+	} else {
+		job, ok = h.queue.JobStatus(req.TenantID, req.Params.JobID)
+	}
+	if !ok {
+		return nil, apperr.New(apperr.Validation, apperr.CodeNotFound, "排队任务不存在或已结束: "+req.Params.JobID)
+	}
+	return newResponse(req, job), nil
+}
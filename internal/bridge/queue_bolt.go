@@ -0,0 +1,111 @@
+package bridge
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+var queueBucket = []byte("queue")
+
+// queueStore 将排队中的任务保存到 bbolt 文件，任务结束或取消后删除；为 nil 时只保存在内存
+type queueStore struct {
+	db *bolt.DB
+}
+
+// openQueueStore 打开或创建任务队列文件，已有的任务保留
+func openQueueStore(path string) (*queueStore, error) {
+	if dir := filepath.Dir(path); dir != "." {
+		if err := os.MkdirAll(dir, 0o700); err != nil {
+			return nil, fmt.Errorf("创建任务队列目录失败: %w", err)
+		}
+	}
+	db, err := bolt.Open(path, 0o600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("打开任务队列文件失败: %w", err)
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(queueBucket)
+		return err
+	})
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("初始化任务队列失败: %w", err)
+	}
+	return &queueStore{db: db}, nil
+}
+
+func seqKey(seq uint64) []byte {
+	key := make([]byte, 8)
+	binary.BigEndian.PutUint64(key, seq)
+	return key
+}
+
+// add 以递增序号为键保存任务，并将序号写入 j.Seq
+func (st *queueStore) add(j *queuedJob) error {
+	if st == nil {
+		return nil
+	}
+	return st.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(queueBucket)
+		seq, err := b.NextSequence()
+		if err != nil {
+			return err
+		}
+		j.Seq = seq
+		data, err := json.Marshal(j.persisted())
+		if err != nil {
+			return err
+		}
+		return b.Put(seqKey(seq), data)
+	})
+}
+
+// remove 删除已结束或已取消的任务
+func (st *queueStore) remove(seq uint64) error {
+	if st == nil {
+		return nil
+	}
+	return st.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(queueBucket).Delete(seqKey(seq))
+	})
+}
+
+// load 按入队顺序读取全部任务，无法解析的任务直接删除
+func (st *queueStore) load() ([]*queuedJob, error) {
+	if st == nil {
+		return nil, nil
+	}
+	var jobs []*queuedJob
+	err := st.db.Update(func(tx *bolt.Tx) error {
+		c := tx.Bucket(queueBucket).Cursor()
+		for k, v := c.First(); k != nil; k, v = c.Next() {
+			j := &queuedJob{}
+			if err := json.Unmarshal(v, j); err != nil || j.Request == nil {
+				if err := c.Delete(); err != nil {
+					return err
+				}
+				continue
+			}
+			j.Request.sealed = j.Sealed
+			j.Request.RawParams = j.RawParams
+			j.locked = j.Sealed
+			jobs = append(jobs, j)
+		}
+		return nil
+	})
+	return jobs, err
+}
+
+// Close 关闭任务队列文件
+func (st *queueStore) Close() error {
+	if st == nil {
+		return nil
+	}
+	return st.db.Close()
+}
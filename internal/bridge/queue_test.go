@@ -0,0 +1,284 @@
+package bridge
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+
+	"ollama_dev/internal/apperr"
+	"ollama_dev/internal/config"
+	"ollama_dev/internal/keystore"
+	"ollama_dev/internal/util"
+)
+
+// orderedOllama 的 Chat 按调用顺序记录模型名，阻塞到 release 关闭
+type orderedOllama struct {
+	fakeOllama
+	entered chan string
+	release chan struct{}
+}
+
+func (g *orderedOllama) Chat(ctx context.Context, req ChatRequest) (Reply, error) {
+	g.entered <- req.Model
+	select {
+	case <-g.release:
+		return Reply{Content: "done"}, nil
+	case <-ctx.Done():
+		return Reply{}, BackendError(ctx.Err(), "对话失败")
+	}
+}
+
+// statuses 返回 request_id 对应的全部帧的 status 与 data
+func (s *syncWSClient) statuses(requestID string) (frames []struct {
+	Status string          `json:"status"`
+	Data   json.RawMessage `json:"data"`
+}) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, raw := range s.written {
+		var f struct {
+			RequestID string          `json:"request_id"`
+			Status    string          `json:"status"`
+			Data      json.RawMessage `json:"data"`
+		}
+		if json.Unmarshal(raw, &f) == nil && f.RequestID == requestID {
+			frames = append(frames, struct {
+				Status string          `json:"status"`
+				Data   json.RawMessage `json:"data"`
+			}{f.Status, f.Data})
+		}
+	}
+	return frames
+}
+
+// waitUntil 等待 cond 成立，每写入一帧检查一次
+func waitUntil(t *testing.T, ws *syncWSClient, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.After(2 * time.Second)
+	for !cond() {
+		select {
+		case <-ws.wrote:
+		case <-deadline:
+			t.Fatalf("timed out waiting for %s", what)
+		}
+	}
+}
+
+func newQueueServer(t *testing.T, ollama OllamaClient, cfg config.JobQueueConfig) (*Server, *syncWSClient, func()) {
+	t.Helper()
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	ws := &syncWSClient{wrote: make(chan struct{}, 100)}
+	s := NewServer(ws, NewHandlerFactory(ollama, logger), nil, config.Default().Bridge, logger)
+	stop, err := s.StartJobQueue(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s, ws, stop
+}
+
+func queueChat(t *testing.T, s *Server, id, model, priority string) {
+	t.Helper()
+	req := &CloudRequest{Type: TypeServerToClient, Action: "chat", RequestID: id, Params: CloudParams{ModelName: model, Priority: priority}}
+	if err := s.handleServerRequest(&Message{Request: req}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestJobQueueRunsByPriority(t *testing.T) {
+	ollama := &orderedOllama{entered: make(chan string, 10), release: make(chan struct{})}
+	s, ws, stop := newQueueServer(t, ollama, config.JobQueueConfig{Concurrency: 1, MaxSize: 3, Actions: []string{"chat"}})
+
+	queueChat(t, s, "running", "running", "")
+	if got := <-ollama.entered; got != "running" {
+		t.Fatalf("first job = %q", got)
+	}
+	queueChat(t, s, "batch", "batch", PriorityBatch)
+	queueChat(t, s, "normal", "normal", "")
+	queueChat(t, s, "high", "high", PriorityHigh)
+	if err := s.handleServerRequest(&Message{Request: &CloudRequest{Action: "chat", RequestID: "full", Params: CloudParams{ModelName: "x"}}}); apperr.CodeOf(err) != apperr.CodeBusy {
+		t.Fatalf("expected busy when the queue is full, got %v", err)
+	}
+	if err := s.handleServerRequest(&Message{Request: &CloudRequest{Action: "chat", RequestID: "bad", Params: CloudParams{Priority: "urgent"}}}); apperr.CodeOf(err) != apperr.CodeInvalidParams {
+		t.Fatalf("expected invalid_params for unknown priority, got %v", err)
+	}
+
+	// batch 先入队，排在第 1 位，之后被 normal 与 high 挤到第 3 位
+	lastPosition := func(id string) int {
+		var pos int
+		for _, f := range ws.statuses(id) {
+			if f.Status == StatusQueued {
+				var job QueuedJob
+				_ = json.Unmarshal(f.Data, &job)
+				pos = job.Position
+			}
+		}
+		return pos
+	}
+	waitUntil(t, ws, "queued positions", func() bool {
+		return lastPosition("high") == 1 && lastPosition("normal") == 2 && lastPosition("batch") == 3
+	})
+
+	close(ollama.release)
+	var order []string
+	for range 3 {
+		order = append(order, <-ollama.entered)
+	}
+	if want := []string{"high", "normal", "batch"}; !slices.Equal(order, want) {
+		t.Errorf("jobs ran in order %v, want %v", order, want)
+	}
+	stop()
+	for _, id := range []string{"running", "high", "normal", "batch"} {
+		frames := ws.statuses(id)
+		if len(frames) == 0 || frames[len(frames)-1].Status != StatusDone {
+			t.Errorf("%s: expected a final done frame, got %+v", id, frames)
+		}
+	}
+}
+
+func TestJobStatusAndCancel(t *testing.T) {
+	ollama := &orderedOllama{entered: make(chan string, 10), release: make(chan struct{})}
+	s, ws, stop := newQueueServer(t, ollama, config.JobQueueConfig{Concurrency: 1, MaxSize: 10, Actions: []string{"chat"}})
+	defer stop()
+
+	queueChat(t, s, "running", "running", "")
+	<-ollama.entered
+	queueChat(t, s, "waiting", "waiting", PriorityBatch)
+
+	handle := func(action, tenant, id string) (*CloudResponse, error) {
+		return s.handlerFactory.CreateHandler(action).Handle(context.Background(), &CloudRequest{Action: action, TenantID: tenant, Params: CloudParams{JobID: id}})
+	}
+	resp, err := handle("job_status", "", "waiting")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if job := resp.Data.(QueuedJob); job.State != JobQueued || job.Position != 1 || job.Priority != PriorityBatch || job.Action != "chat" {
+		t.Errorf("unexpected status of the queued job: %+v", job)
+	}
+	resp, err = handle("job_status", "", "running")
+	if err != nil || resp.Data.(QueuedJob).State != JobRunning {
+		t.Errorf("unexpected status of the running job: %+v, %v", resp, err)
+	}
+	if _, err := handle("job_status", "other", "waiting"); apperr.CodeOf(err) != apperr.CodeNotFound {
+		t.Errorf("jobs of other tenants must not be visible, got %v", err)
+	}
+
+	// 取消排队中与执行中的任务，原请求都回复 cancelled
+	for _, id := range []string{"waiting", "running"} {
+		resp, err := handle("job_cancel", "", id)
+		if err != nil || resp.Data.(QueuedJob).State != JobCancelled {
+			t.Fatalf("cancel %s: %+v, %v", id, resp, err)
+		}
+	}
+	for _, id := range []string{"waiting", "running"} {
+		waitUntil(t, ws, id+" cancelled", func() bool {
+			frames := ws.statuses(id)
+			if len(frames) == 0 || frames[len(frames)-1].Status != StatusError {
+				return false
+			}
+			var data apperr.Data
+			_ = json.Unmarshal(frames[len(frames)-1].Data, &data)
+			return data.Code == apperr.CodeCancelled
+		})
+	}
+	if _, err := handle("job_cancel", "", "waiting"); apperr.CodeOf(err) != apperr.CodeNotFound {
+		t.Errorf("expected not_found for a cancelled job, got %v", err)
+	}
+	select {
+	case model := <-ollama.entered:
+		t.Errorf("cancelled job %q reached the backend", model)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestJobQueuePersistsAcrossRestart(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "queue.db")
+	cfg := config.JobQueueConfig{Concurrency: 1, MaxSize: 10, File: file, Actions: []string{"chat"}}
+
+	ollama := &orderedOllama{entered: make(chan string, 10), release: make(chan struct{})}
+	s, _, stop := newQueueServer(t, ollama, cfg)
+	queueChat(t, s, "running", "running", "")
+	<-ollama.entered
+	queueChat(t, s, "saved", "saved", PriorityHigh)
+	s.Shutdown(0)
+	stop()
+
+	// 重启后排队中的任务继续执行
+	ollama = &orderedOllama{entered: make(chan string, 10), release: make(chan struct{})}
+	close(ollama.release)
+	s, ws, stop := newQueueServer(t, ollama, cfg)
+	defer stop()
+	if got := <-ollama.entered; got != "saved" {
+		t.Fatalf("restored job = %q", got)
+	}
+	waitUntil(t, ws, "restored job done", func() bool {
+		frames := ws.statuses("saved")
+		return len(frames) > 0 && frames[len(frames)-1].Status == StatusDone
+	})
+	if jobs, err := s.queue.store.load(); err != nil || len(jobs) != 0 {
+		t.Errorf("finished jobs must be removed from the file: %d, %v", len(jobs), err)
+	}
+}
+
+func TestJobQueueKeepsSealedRequestsEncrypted(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "queue.db")
+	cfg := config.JobQueueConfig{Concurrency: 1, MaxSize: 10, File: file, Actions: []string{"chat"}}
+	keys, _ := keystore.Open(filepath.Join(t.TempDir(), "keys.json"), []byte("test"))
+	_, _ = keys.Rotate("acme", 0)
+	const secret = "top secret prompt"
+	params, _ := json.Marshal(CloudParams{ModelName: "sealed-model", Messages: []ChatMessage{{Role: "user", Content: secret}}})
+	sealed, err := keys.Seal(util.AESGCM, "acme", keystore.AAD("acme", "sealed"), params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	frame, _ := json.Marshal(CloudRequest{V: ProtocolVersion, Type: TypeServerToClient, Action: "chat", RequestID: "sealed", TenantID: "acme", Sealed: sealed})
+
+	ollama := &orderedOllama{entered: make(chan string, 10), release: make(chan struct{})}
+	s, _, stop := newQueueServer(t, ollama, cfg)
+	s.SetKeystore(keys, util.AESGCM)
+	queueChat(t, s, "running", "running", "")
+	<-ollama.entered
+	if err := s.replay(frame); err != nil {
+		t.Fatal(err)
+	}
+	err = s.queue.store.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(queueBucket).ForEach(func(k, v []byte) error {
+			if bytes.Contains(v, []byte(secret)) || bytes.Contains(v, []byte("sealed-model")) {
+				t.Errorf("queue file contains plaintext params: %s", v)
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Shutdown(0)
+	stop()
+
+	// 重启后解密并执行
+	ollama = &orderedOllama{entered: make(chan string, 10), release: make(chan struct{})}
+	close(ollama.release)
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	ws := &syncWSClient{wrote: make(chan struct{}, 100)}
+	s = NewServer(ws, NewHandlerFactory(ollama, logger), nil, config.Default().Bridge, logger)
+	s.SetKeystore(keys, util.AESGCM)
+	stop, err = s.StartJobQueue(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stop()
+	if got := <-ollama.entered; got != "sealed-model" {
+		t.Fatalf("restored job = %q", got)
+	}
+	waitUntil(t, ws, "restored job done", func() bool {
+		frames := ws.statuses("sealed")
+		return len(frames) > 0 && frames[len(frames)-1].Status == StatusDone
+	})
+}
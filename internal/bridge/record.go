@@ -0,0 +1,154 @@
+package bridge
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"ollama_dev/internal/config"
+	"ollama_dev/internal/logging"
+	"ollama_dev/internal/script"
+	"ollama_dev/internal/wasm"
+)
+
+// Record 录制文件中的一行，Frame 保存收到的原始帧，格式错误的帧也原样保留
+type Record struct {
+	Time  time.Time `json:"time"`
+	Frame string    `json:"frame"`
+}
+
+// Recorder 将收到的帧逐行追加到 JSONL 文件，用于复现云端报告的协议问题
+type Recorder struct {
+	mu  sync.Mutex
+	f   *os.File
+	enc *json.Encoder
+}
+
+// NewRecorder 以追加方式打开录制文件
+func NewRecorder(path string) (*Recorder, error) {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("打开录制文件失败: %w", err)
+	}
+	return &Recorder{f: f, enc: json.NewEncoder(f)}, nil
+}
+
+// Record 追加一帧
+func (r *Recorder) Record(frame []byte) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.enc.Encode(Record{Time: time.Now(), Frame: string(frame)})
+}
+
+// Close 关闭录制文件
+func (r *Recorder) Close() error {
+	return r.f.Close()
+}
+
+// ReadRecords 读取录制文件
+func ReadRecords(path string) ([]Record, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("打开录制文件失败: %w", err)
+	}
+	defer f.Close()
+
+	var records []Record
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var rec Record
+		if err := json.Unmarshal(scanner.Bytes(), &rec); err != nil {
+			return nil, fmt.Errorf("解析录制文件第 %d 行失败: %w", line, err)
+		}
+		records = append(records, rec)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("读取录制文件失败: %w", err)
+	}
+	return records, nil
+}
+
+// Replay 将录制的帧依次送入处理流程，响应帧逐行写入 out，使用本地 Ollama
+func Replay(ctx context.Context, logger *slog.Logger, cfg *config.Config, path string, out io.Writer) error {
+	records, err := ReadRecords(path)
+	if err != nil {
+		return err
+	}
+
+	memoryCache := NewMemoryCache(cfg.Cache.TTL, cfg.Cache.CleanupInterval)
+	ollamaClient, err := NewOllamaClient(cfg.Ollama.Host, memoryCache, cfg.Cache.TTL)
+	if err != nil {
+		return fmt.Errorf("创建Ollama客户端失败: %w", err)
+	}
+
+	// 与 Run 一样运行脚本钩子与 WASM 变换，便于复现修改参数后的问题
+	scripts, err := script.Load(cfg.Bridge.Scripts, logging.Component(logger, "script"))
+	if err != nil {
+		return fmt.Errorf("加载脚本失败: %w", err)
+	}
+	defer scripts.Close()
+	factory := NewHandlerFactory(ollamaClient, logger)
+	if err := factory.SetScripts(scripts); err != nil {
+		return err
+	}
+	transforms, err := wasm.Load(ctx, cfg.Bridge.WASM, logging.Component(logger, "wasm"))
+	if err != nil {
+		return fmt.Errorf("加载 WASM 模块失败: %w", err)
+	}
+	defer transforms.Close()
+	factory.SetTransforms(transforms)
+	factory.SetImages(cfg.Bridge.Images)
+
+	server := NewServer(&replayClient{out: out}, factory, nil, cfg.Bridge, logger)
+	for i, rec := range records {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		logger.Info("回放请求", "index", i+1, "total", len(records), "recorded_at", rec.Time)
+		if err := server.replay([]byte(rec.Frame)); err != nil {
+			logger.Error("回放请求失败", "index", i+1, "error", err)
+		}
+	}
+	return nil
+}
+
+// replay 以与 Run 相同的方式解析并分发一帧
+func (s *Server) replay(frame []byte) error {
+	msg, err := parseFrame(frame, s.strict, s.e2e, s.handlerFactory)
+	if err != nil {
+		s.reject(msg, err)
+		return err
+	}
+	defer msg.release()
+	return s.crash.Guard("replay", func() error { return s.dispatch(msg) })
+}
+
+// replayClient 回放时代替 WebSocket 连接，响应帧逐行写入 out
+type replayClient struct {
+	mu  sync.Mutex
+	out io.Writer
+}
+
+func (c *replayClient) Connect(url string) error     { return nil }
+func (c *replayClient) ReadMessage() ([]byte, error) { return nil, io.EOF }
+func (c *replayClient) Close() error                 { return nil }
+func (c *replayClient) Conn() *websocket.Conn        { return nil }
+
+func (c *replayClient) WriteMessage(message []byte) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	_, err := fmt.Fprintf(c.out, "%s\n", message)
+	return err
+}
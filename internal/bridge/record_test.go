@@ -0,0 +1,69 @@
+package bridge
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"path/filepath"
+	"testing"
+
+	"ollama_dev/internal/config"
+)
+
+func TestRecordAndReplay(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "record.jsonl")
+	rec, err := NewRecorder(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	frames := []string{
+		`{"type":"server_to_client","action":"version"}`,
+		`{"type":"server_to_client","action":"nope"}`,
+		`not json`,
+	}
+	for _, f := range frames {
+		if err := rec.Record([]byte(f)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := rec.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	records, err := ReadRecords(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != len(frames) || records[2].Frame != "not json" {
+		t.Fatalf("unexpected records: %+v", records)
+	}
+
+	// 回放：version 正常响应，未知动作回复错误帧，格式错误的帧不导致中断
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	var out bytes.Buffer
+	s := NewServer(&replayClient{out: &out}, NewHandlerFactory(&fakeOllama{err: errors.New("unused")}, logger), nil, config.Default().Bridge, logger)
+	for _, r := range records {
+		_ = s.replay([]byte(r.Frame))
+	}
+
+	dec := json.NewDecoder(&out)
+	var statuses []string
+	for {
+		var resp CloudResponse
+		if err := dec.Decode(&resp); err != nil {
+			break
+		}
+		statuses = append(statuses, resp.Action+":"+resp.Status)
+	}
+	want := []string{"version:done", "nope:error", ":error"}
+	if len(statuses) != len(want) {
+		t.Fatalf("unexpected responses: %v", statuses)
+	}
+	for i := range want {
+		if statuses[i] != want[i] {
+			t.Errorf("response %d: got %s, want %s", i, statuses[i], want[i])
+		}
+	}
+}
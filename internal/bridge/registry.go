@@ -0,0 +1,82 @@
+package bridge
+
+import (
+	"fmt"
+	"slices"
+	"sync"
+)
+
+// builtinActions 返回协议帧的动作名与 actionGroups 中的全部内置动作 (不论组件是否启用)，自定义动作不得与之重名
+func builtinActions() []string {
+	names := []string{ActionCredit, ActionCancel, ActionPart, ActionPing, "capabilities"}
+	for _, g := range actionGroups {
+		names = append(names, g.actions...)
+	}
+	return names
+}
+
+// ActionFactory 创建自定义动作的处理器，ollama 为请求处理使用的 Ollama 客户端（已包含熔断、重试与并发限制）；
+// 处理器同时实现 StreamHandler 时支持 params.stream
+type ActionFactory func(ollama OllamaClient, logger Logger) RequestHandler
+
+// Action 自定义动作
+type Action struct {
+	Name         string
+	New          ActionFactory
+	NeedsBackend bool // 依赖 Ollama 时为 true，后端不可用时直接返回 backend_unavailable
+}
+
+var (
+	actionsMu sync.RWMutex
+	actions   = map[string]Action{}
+)
+
+// RegisterAction 注册自定义动作，通常在动作所在包的 init 中调用，由 main 包以空导入启用；
+// 名称为空、与内置动作重名或重复注册时 panic
+func RegisterAction(a Action) {
+	if a.Name == "" || a.New == nil {
+		panic("bridge: 自定义动作缺少名称或 New")
+	}
+	if slices.Contains(builtinActions(), a.Name) {
+		panic(fmt.Sprintf("bridge: 自定义动作 %s 与内置动作重名", a.Name))
+	}
+	actionsMu.Lock()
+	defer actionsMu.Unlock()
+	if _, dup := actions[a.Name]; dup {
+		panic(fmt.Sprintf("bridge: 自定义动作 %s 重复注册", a.Name))
+	}
+	actions[a.Name] = a
+}
+
+// registeredAction 返回已注册的自定义动作
+func registeredAction(name string) (Action, bool) {
+	actionsMu.RLock()
+	defer actionsMu.RUnlock()
+	a, ok := actions[name]
+	return a, ok
+}
+
+// registeredActions 返回已注册的自定义动作名称，按名称排序
+func registeredActions() []string {
+	actionsMu.RLock()
+	defer actionsMu.RUnlock()
+	names := make([]string, 0, len(actions))
+	for name := range actions {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// customAction 动作是否为注册的或脚本定义的自定义动作，f 可为 nil
+func (f *HandlerFactory) customAction(action string) bool {
+	if _, ok := registeredAction(action); ok {
+		return true
+	}
+	return f != nil && f.scripts.Defines(action)
+}
+
+// NewResponse 构造请求的 done 响应，自定义动作应通过它构造响应，使加密请求的响应同样加密
+func NewResponse(req *CloudRequest, data any) *CloudResponse {
+	return newResponse(req, data)
+}
@@ -0,0 +1,88 @@
+package bridge
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"slices"
+	"testing"
+
+	"ollama_dev/internal/apperr"
+	"ollama_dev/internal/config"
+)
+
+// lookupHandler 测试用的自定义动作，从 params.key 读取自有字段
+type lookupHandler struct{}
+
+func (lookupHandler) Handle(ctx context.Context, req *CloudRequest) (*CloudResponse, error) {
+	var p struct {
+		Key string `json:"key"`
+	}
+	if err := json.Unmarshal(req.RawParams, &p); err != nil || p.Key == "" {
+		return nil, apperr.New(apperr.Validation, apperr.CodeInvalidParams, "key 不能为空")
+	}
+	return newResponse(req, map[string]string{"value": "found " + p.Key}), nil
+}
+
+func init() {
+	RegisterAction(Action{Name: "test_lookup", New: func(OllamaClient, Logger) RequestHandler { return lookupHandler{} }})
+}
+
+func TestRegisteredActionIsDispatched(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	factory := NewHandlerFactory(&fakeOllama{}, logger)
+	if !slices.Contains(factory.Actions(), "test_lookup") {
+		t.Errorf("registered action missing from capabilities: %v", factory.Actions())
+	}
+	if factory.NeedsBackend("test_lookup") {
+		t.Error("test_lookup was registered without NeedsBackend")
+	}
+
+	// 自定义字段在 strict 下同样接受，由处理器从 RawParams 解码
+	msg, err := parseMessage([]byte(`{"v":2,"type":"server_to_client","action":"test_lookup","request_id":"1","params":{"key":"k1"}}`), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ws := &fakeWSClient{}
+	s := NewServer(ws, factory, nil, config.Default().Bridge, logger)
+	if err := s.handleServerRequest(msg); err != nil {
+		t.Fatal(err)
+	}
+	if len(ws.written) != 1 {
+		t.Fatalf("expected one response frame, got %d", len(ws.written))
+	}
+	var resp struct {
+		Status string            `json:"status"`
+		Data   map[string]string `json:"data"`
+	}
+	if err := json.Unmarshal(ws.written[0], &resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.Status != "done" || resp.Data["value"] != "found k1" {
+		t.Errorf("unexpected response %s", ws.written[0])
+	}
+}
+
+func TestRegisterActionRejectsConflicts(t *testing.T) {
+	newHandler := func(OllamaClient, Logger) RequestHandler { return lookupHandler{} }
+	for _, a := range []Action{
+		{Name: "chat", New: newHandler},
+		{Name: "file_download", New: newHandler}, // 组件未启用的内置动作同样保留
+		{Name: ActionCredit, New: newHandler},
+		{Name: ActionPart, New: newHandler},
+		{Name: ActionPing, New: newHandler},
+		{Name: "test_lookup", New: newHandler},
+		{Name: "", New: newHandler},
+		{Name: "no_factory"},
+	} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("RegisterAction(%q) did not panic", a.Name)
+				}
+			}()
+			RegisterAction(a)
+		}()
+	}
+}
@@ -0,0 +1,111 @@
+package bridge
+
+import (
+	"context"
+	"errors"
+
+	"ollama_dev/internal/apperr"
+	"ollama_dev/internal/retry"
+)
+
+// retryClient 按 bridge.ollama_retry 重试失败的 Ollama 调用
+type retryClient struct {
+	OllamaClient
+	policy *retry.Policy
+}
+
+// withRetry 返回会重试的客户端，p 为 nil 时原样返回
+func withRetry(c OllamaClient, p *retry.Policy) OllamaClient {
+	if p == nil {
+		return c
+	}
+	return &retryClient{OllamaClient: c, policy: p}
+}
+
+// isRetryable 只重试连接失败与 5xx；熔断、限流等已分类的错误与 4xx 不重试
+func isRetryable(err error) bool {
+	var appErr *apperr.Error
+	if errors.As(err, &appErr) || !isOllamaFailure(err) {
+		return false
+	}
+	return true
+}
+
+// do 按策略执行 fn，重试次数计入 ollama 指标的 retries
+func (c *retryClient) do(ctx context.Context, fn func(ctx context.Context) error, retryable func(error) bool) error {
+	attempt := 0
+	return c.policy.Do(ctx, func(ctx context.Context) error {
+		if attempt++; attempt > 1 {
+			ollamaStats.Add("retries", 1)
+		}
+		return fn(ctx)
+	}, retryable)
+}
+
+func (c *retryClient) Chat(ctx context.Context, req ChatRequest) (Reply, error) {
+	var reply Reply
+	err := c.do(ctx, func(ctx context.Context) error {
+		var err error
+		reply, err = c.OllamaClient.Chat(ctx, req)
+		return err
+	}, isRetryable)
+	return reply, err
+}
+
+// ChatStream 只在尚未输出任何片段时重试，避免云端收到重复的内容
+func (c *retryClient) ChatStream(ctx context.Context, req ChatRequest, onChunk func(string) error) (Reply, error) {
+	var reply Reply
+	emitted := false
+	err := c.do(ctx, func(ctx context.Context) error {
+		var err error
+		reply, err = c.OllamaClient.ChatStream(ctx, req, func(chunk string) error {
+			emitted = true
+			return onChunk(chunk)
+		})
+		return err
+	}, func(err error) bool {
+		return !emitted && isRetryable(err)
+	})
+	return reply, err
+}
+
+// Generate 与 ChatStream 相同，流式时只在尚未输出任何片段时重试
+func (c *retryClient) Generate(ctx context.Context, req GenerateRequest, onChunk func(string) error) (Reply, error) {
+	var reply Reply
+	emitted := false
+	fn := onChunk
+	if onChunk != nil {
+		fn = func(chunk string) error {
+			emitted = true
+			return onChunk(chunk)
+		}
+	}
+	err := c.do(ctx, func(ctx context.Context) error {
+		var err error
+		reply, err = c.OllamaClient.Generate(ctx, req, fn)
+		return err
+	}, func(err error) bool {
+		return !emitted && isRetryable(err)
+	})
+	return reply, err
+}
+
+func (c *retryClient) Embed(ctx context.Context, req EmbedRequest) (Embeddings, error) {
+	var e Embeddings
+	err := c.do(ctx, func(ctx context.Context) error {
+		var err error
+		e, err = c.OllamaClient.Embed(ctx, req)
+		return err
+	}, isRetryable)
+	return e, err
+}
+
+func (c *retryClient) ListModels(ctx context.Context) ([]ModelInfo, error) {
+	var models []ModelInfo
+	err := c.do(ctx, func(ctx context.Context) error {
+		var err error
+		models, err = c.OllamaClient.ListModels(ctx)
+		return err
+	}, isRetryable)
+	return models, err
+}
@@ -0,0 +1,68 @@
+package bridge
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/ollama/ollama/api"
+
+	"ollama_dev/internal/config"
+	"ollama_dev/internal/retry"
+)
+
+// flakyOllama 前 fails 次调用返回 err
+type flakyOllama struct {
+	fakeOllama
+	fails, calls int
+	chunks       []string
+}
+
+func (f *flakyOllama) ListModels(ctx context.Context) ([]ModelInfo, error) {
+	if f.calls++; f.calls <= f.fails {
+		return nil, f.err
+	}
+	return []ModelInfo{{Name: "llama3"}}, nil
+}
+
+func (f *flakyOllama) ChatStream(ctx context.Context, req ChatRequest, onChunk func(string) error) (Reply, error) {
+	f.calls++
+	for _, c := range f.chunks {
+		if err := onChunk(c); err != nil {
+			return Reply{}, err
+		}
+	}
+	return Reply{}, f.err
+}
+
+func TestRetryClientRetriesTransientFailures(t *testing.T) {
+	policy := retry.New(config.RetryConfig{MaxAttempts: 3, Base: time.Millisecond, Multiplier: 2})
+
+	f := &flakyOllama{fakeOllama: fakeOllama{err: errors.New("connection refused")}, fails: 2}
+	models, err := withRetry(f, policy).ListModels(context.Background())
+	if err != nil || len(models) != 1 || f.calls != 3 {
+		t.Fatalf("models = %v, err = %v, calls = %d", models, err, f.calls)
+	}
+
+	// 4xx 说明请求本身有误，不重试
+	f = &flakyOllama{fakeOllama: fakeOllama{err: api.StatusError{StatusCode: http.StatusNotFound}}, fails: 3}
+	if _, err := withRetry(f, policy).ListModels(context.Background()); err == nil || f.calls != 1 {
+		t.Errorf("err = %v, calls = %d, want a single call", err, f.calls)
+	}
+}
+
+func TestRetryClientDoesNotRetryStreamAfterOutput(t *testing.T) {
+	policy := retry.New(config.RetryConfig{MaxAttempts: 3, Base: time.Millisecond, Multiplier: 2})
+	f := &flakyOllama{fakeOllama: fakeOllama{err: errors.New("connection reset")}, chunks: []string{"hel"}}
+
+	var got []string
+	_, err := withRetry(f, policy).ChatStream(context.Background(), ChatRequest{Model: "llama3"}, func(c string) error {
+		got = append(got, c)
+		return nil
+	})
+	if err == nil || f.calls != 1 || len(got) != 1 {
+		t.Errorf("err = %v, calls = %d, chunks = %v; want no retry after output", err, f.calls, got)
+	}
+}
@@ -0,0 +1,211 @@
+package bridge
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"ollama_dev/internal/apperr"
+)
+
+// errRequestTimeout 本端发出的请求在时限内没有收到回复
+var errRequestTimeout = apperr.New(apperr.Timeout, apperr.CodeTimeout, "等待对端响应超时")
+
+// RPCResponse 对端对本端请求的最终回复 (status 为 done 或 error)，流式响应的中间分片不会返回
+type RPCResponse struct {
+	Action    string          `json:"action"`
+	RequestID string          `json:"request_id"`
+	Status    string          `json:"status"`
+	Data      json.RawMessage `json:"data"`
+}
+
+// Err 回复为错误帧时还原为 *apperr.Error，否则返回 nil
+func (r *RPCResponse) Err() error {
+	if r.Status != StatusError {
+		return nil
+	}
+	var data ErrorData
+	if err := json.Unmarshal(r.Data, &data); err != nil {
+		return apperr.Wrap(err, apperr.Protocol, apperr.CodeBadFrame, "解析错误响应失败")
+	}
+	return apperr.FromData(data)
+}
+
+// Decode 将 data 解码到 v，回复为错误帧时返回对应的错误
+func (r *RPCResponse) Decode(v any) error {
+	if err := r.Err(); err != nil {
+		return err
+	}
+	if err := json.Unmarshal(r.Data, v); err != nil {
+		return apperr.Wrap(err, apperr.Protocol, apperr.CodeBadFrame, fmt.Sprintf("解析 %s 响应失败", r.Action))
+	}
+	return nil
+}
+
+// timeoutResponse 超时时交给等待方的错误帧，与对端回复的错误帧同样处理
+func timeoutResponse(action, id string) *RPCResponse {
+	data, _ := json.Marshal(apperr.ToData(errRequestTimeout))
+	return &RPCResponse{Action: action, RequestID: id, Status: StatusError, Data: data}
+}
+
+type pendingCall struct {
+	action   string
+	deadline time.Time
+	reply    chan *RPCResponse // 容量为 1，只写入一次
+	done     bool              // 已交付回复，等待 AwaitResponse 取走
+}
+
+// pendingCalls 本端发出、尚未取走回复的请求，按 request_id 关联回复
+type pendingCalls struct {
+	mu    sync.Mutex
+	calls map[string]*pendingCall
+}
+
+func newPendingCalls() *pendingCalls {
+	return &pendingCalls{calls: make(map[string]*pendingCall)}
+}
+
+func (p *pendingCalls) add(id, action string, deadline time.Time) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.calls[id] = &pendingCall{action: action, deadline: deadline, reply: make(chan *RPCResponse, 1)}
+}
+
+func (p *pendingCalls) get(id string) *pendingCall {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.calls[id]
+}
+
+// has 请求是否由本端发出且仍在等待回复，用于关联响应与忽略 hub 广播回来的自身请求
+func (p *pendingCalls) has(id string) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	c, ok := p.calls[id]
+	return ok && !c.done
+}
+
+// finish 交付回复，已交付过或请求不存在时返回 false
+func (p *pendingCalls) finish(id string, resp *RPCResponse) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	c, ok := p.calls[id]
+	if !ok || c.done {
+		return false
+	}
+	c.done = true
+	c.reply <- resp
+	return true
+}
+
+// drop 移除请求
+func (p *pendingCalls) drop(id string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	delete(p.calls, id)
+}
+
+// expire 以超时错误帧结束已过期、仍未收到回复的请求并返回其 request_id；
+// 过期后仍无人取走回复的请求直接移除
+func (p *pendingCalls) expire(now time.Time) []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	var expired []string
+	for id, c := range p.calls {
+		if !now.After(c.deadline) {
+			continue
+		}
+		if c.done {
+			delete(p.calls, id)
+			continue
+		}
+		c.done = true
+		c.reply <- timeoutResponse(c.action, id)
+		expired = append(expired, id)
+	}
+	return expired
+}
+
+// sweepCalls 由 janitor 调用，结束超时的请求并通知对端取消
+func (s *Server) sweepCalls(now time.Time) int {
+	ids := s.pending.expire(now)
+	for _, id := range ids {
+		s.cancelRemote(id)
+	}
+	return len(ids)
+}
+
+// cancelRemote 通知对端取消本端已不再等待的请求
+func (s *Server) cancelRemote(id string) {
+	cancel := &CloudRequest{V: ProtocolVersion, Type: TypeServerToClient, Action: ActionCancel, RequestID: id}
+	if err := s.writeRequest(cancel); err != nil {
+		s.logger.Error("发送取消请求失败", "request_id", id, "error", err)
+	}
+}
+
+// resolve 在读取循环中处理响应帧，属于本端请求的最终回复交给等待方
+func (s *Server) resolve(msg *Message) {
+	id := msg.Request.RequestID
+	if !s.pending.has(id) {
+		return
+	}
+	var env Envelope
+	if err := json.Unmarshal(msg.Raw, &env); err != nil {
+		return
+	}
+	if env.Status == StatusStreaming || env.Status == StatusDuplicate || env.Status == StatusQueued {
+		return
+	}
+	s.pending.finish(id, &RPCResponse{Action: env.Action, RequestID: id, Status: env.Status, Data: env.Data})
+}
+
+// SendRequest 向对端发出请求并返回 request_id，之后用 AwaitResponse 等待回复；
+// 超过 timeout 仍未收到回复的请求由 janitor 以超时结束并通知对端取消
+func (s *Server) SendRequest(action string, params CloudParams, timeout time.Duration) (string, error) {
+	id := uuid.New().String()
+	s.pending.add(id, action, time.Now().Add(timeout))
+	req := &CloudRequest{V: ProtocolVersion, Type: TypeServerToClient, Action: action, RequestID: id, Params: params}
+	if err := s.writeRequest(req); err != nil {
+		s.pending.drop(id)
+		return "", fmt.Errorf("写入消息失败: %w", err)
+	}
+	s.logger.Info("已发送请求", "action", action, "request_id", id)
+	return id, nil
+}
+
+// AwaitResponse 等待 SendRequest 发出的请求的最终回复；对端回复错误帧时同时返回对应的错误，
+// timeout 内没有回复时返回超时错误帧与 Timeout 类别的错误，并通知对端取消该请求。
+// 回复由读取循环交付，不能在请求处理器中同步等待
+func (s *Server) AwaitResponse(requestID string, timeout time.Duration) (*RPCResponse, error) {
+	c := s.pending.get(requestID)
+	if c == nil {
+		return nil, apperr.New(apperr.Protocol, apperr.CodeNotFound, fmt.Sprintf("没有等待回复的请求 %s", requestID))
+	}
+	defer s.pending.drop(requestID)
+	timer := time.NewTimer(min(timeout, time.Until(c.deadline)))
+	defer timer.Stop()
+
+	select {
+	case resp := <-c.reply:
+		return resp, resp.Err()
+	case <-timer.C:
+	}
+	if s.pending.finish(requestID, timeoutResponse(c.action, requestID)) {
+		s.cancelRemote(requestID)
+	}
+	// 超时的同时收到回复时以回复为准
+	resp := <-c.reply
+	return resp, resp.Err()
+}
+
+// Call 发出请求并等待回复，回复为错误帧或超时时返回错误
+func (s *Server) Call(action string, params CloudParams, timeout time.Duration) (*RPCResponse, error) {
+	id, err := s.SendRequest(action, params, timeout)
+	if err != nil {
+		return nil, err
+	}
+	return s.AwaitResponse(id, timeout)
+}
@@ -0,0 +1,128 @@
+package bridge
+
+import (
+	"encoding/json"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+
+	"ollama_dev/internal/apperr"
+	"ollama_dev/internal/config"
+)
+
+func newRPCServer(t *testing.T) (*Server, *fakeWSClient) {
+	t.Helper()
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	ws := &fakeWSClient{}
+	return NewServer(ws, NewHandlerFactory(&fakeOllama{}, logger), nil, config.Default().Bridge, logger), ws
+}
+
+// deliverFrame 模拟读取循环收到一帧
+func deliverFrame(t *testing.T, s *Server, frame string) {
+	t.Helper()
+	msg, err := parseMessage([]byte(frame), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.dispatch(msg); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestAwaitResponseCorrelatesReply(t *testing.T) {
+	s, ws := newRPCServer(t)
+
+	id, err := s.SendRequest("list_model", CloudParams{}, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// hub 广播回来的自身请求不处理，也不回复
+	deliverFrame(t, s, string(ws.written[0]))
+	if len(ws.written) != 1 {
+		t.Fatalf("own request was handled, wrote %s", ws.written[1:])
+	}
+	// 其他请求的响应与流式分片不结束等待
+	deliverFrame(t, s, `{"v":2,"type":"client_to_server","action":"list_model","request_id":"other","status":"done","data":[]}`)
+	deliverFrame(t, s, `{"v":2,"type":"client_to_server","action":"list_model","request_id":"`+id+`","status":"streaming","data":"partial"}`)
+	deliverFrame(t, s, `{"v":2,"type":"client_to_server","action":"list_model","request_id":"`+id+`","status":"done","data":[{"model_name":"llama3"}]}`)
+
+	resp, err := s.AwaitResponse(id, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var models []ModelInfo
+	if err := resp.Decode(&models); err != nil || len(models) != 1 || models[0].Name != "llama3" {
+		t.Fatalf("models = %+v, err = %v", models, err)
+	}
+	if s.pending.has(id) {
+		t.Error("finished request still pending")
+	}
+}
+
+func TestAwaitResponseErrorFrame(t *testing.T) {
+	s, _ := newRPCServer(t)
+
+	id, err := s.SendRequest("show_model", CloudParams{}, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	deliverFrame(t, s, `{"v":2,"type":"client_to_server","action":"show_model","request_id":"`+id+`","status":"error","data":{"category":"backend","code":"model_not_found","message":"no such model"}}`)
+
+	resp, err := s.AwaitResponse(id, time.Second)
+	if apperr.CodeOf(err) != apperr.CodeModelNotFound {
+		t.Fatalf("expected model_not_found, got %v", err)
+	}
+	if resp.Status != StatusError {
+		t.Errorf("status = %q", resp.Status)
+	}
+}
+
+func TestAwaitResponseTimeout(t *testing.T) {
+	s, ws := newRPCServer(t)
+
+	id, err := s.SendRequest("list_model", CloudParams{}, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := s.AwaitResponse(id, 20*time.Millisecond)
+	if !apperr.Is(err, apperr.Timeout) {
+		t.Fatalf("expected timeout error, got %v", err)
+	}
+	// 等待方收到与对端错误帧相同形式的超时帧
+	var data ErrorData
+	if jerr := json.Unmarshal(resp.Data, &data); jerr != nil || data.Code != apperr.CodeTimeout || resp.RequestID != id {
+		t.Errorf("timeout frame = %+v (%s)", resp, resp.Data)
+	}
+	// 并通知对端取消
+	var cancel CloudRequest
+	if err := json.Unmarshal(ws.written[len(ws.written)-1], &cancel); err != nil || cancel.Action != ActionCancel || cancel.RequestID != id {
+		t.Errorf("expected cancel frame, got %s", ws.written[len(ws.written)-1])
+	}
+	// 迟到的回复被忽略
+	deliverFrame(t, s, `{"v":2,"type":"client_to_server","action":"list_model","request_id":"`+id+`","status":"done","data":[]}`)
+	if _, err := s.AwaitResponse(id, time.Millisecond); apperr.CodeOf(err) != apperr.CodeNotFound {
+		t.Errorf("expected not_found for finished request, got %v", err)
+	}
+}
+
+func TestSweepCallsExpiresUnawaitedRequests(t *testing.T) {
+	s, ws := newRPCServer(t)
+
+	id, err := s.SendRequest("list_model", CloudParams{}, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := s.sweepCalls(time.Now()); n != 0 {
+		t.Fatalf("swept %d requests before deadline", n)
+	}
+	if n := s.sweepCalls(time.Now().Add(2 * time.Minute)); n != 1 {
+		t.Fatalf("swept %d requests, want 1", n)
+	}
+	if len(ws.written) != 2 {
+		t.Errorf("expected request and cancel frames, got %d", len(ws.written))
+	}
+	if s.pending.has(id) {
+		t.Error("expired request still pending")
+	}
+}
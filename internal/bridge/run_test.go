@@ -0,0 +1,170 @@
+package bridge_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"math"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"ollama_dev/internal/bridge"
+	"ollama_dev/internal/bridge/bridgetest"
+	"ollama_dev/internal/config"
+	"ollama_dev/internal/metrics"
+)
+
+// frame 云端读到的一帧
+type frame struct {
+	Type      string          `json:"type"`
+	Action    string          `json:"action"`
+	RequestID string          `json:"request_id"`
+	Status    string          `json:"status"`
+	Data      json.RawMessage `json:"data"`
+	Params    struct {
+		SentAt int64 `json:"sent_at"`
+	} `json:"params"`
+}
+
+func readFrame(t *testing.T, cloud *bridgetest.Conn) frame {
+	t.Helper()
+	if err := cloud.SetReadDeadline(time.Now().Add(2 * time.Second)); err != nil {
+		t.Fatal(err)
+	}
+	var f frame
+	if err := cloud.ReadJSON(&f); err != nil {
+		t.Fatalf("read frame: %v", err)
+	}
+	return f
+}
+
+func TestRunOverPipe(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	ollama := &bridgetest.Ollama{Reply: bridge.Reply{Content: "你好"}}
+	client, cloud := bridgetest.Pipe()
+	clock := bridgetest.NewClock()
+	cfg := config.Default().Bridge
+	s := bridge.NewServer(client, bridge.NewHandlerFactory(ollama, logger), nil, cfg, logger)
+	s.SetClock(clock)
+	done := make(chan error, 1)
+	go func() { done <- s.Run() }()
+
+	if f := readFrame(t, cloud); f.Action != "capabilities" {
+		t.Fatalf("expected capabilities first, got %+v", f)
+	}
+
+	cases := []struct {
+		name   string
+		req    map[string]any
+		status string
+		data   string
+	}{
+		{"chat", map[string]any{"type": "server_to_client", "action": "chat", "request_id": "c1", "params": map[string]any{
+			"model_name": "llama3", "messages": []map[string]string{{"role": "user", "content": "hi"}},
+		}}, "done", `{"message":{"content":"你好","role":"assistant"}}`},
+		{"unknown action", map[string]any{"type": "server_to_client", "action": "reboot", "request_id": "c2"}, "error", ""},
+		{"bad frame", map[string]any{"type": "mystery", "action": "chat", "request_id": "c3"}, "error", ""},
+	}
+	for _, c := range cases {
+		if err := cloud.WriteJSON(c.req); err != nil {
+			t.Fatal(err)
+		}
+		f := readFrame(t, cloud)
+		if f.RequestID != c.req["request_id"] || f.Status != c.status {
+			t.Errorf("%s: unexpected reply %+v", c.name, f)
+		}
+		if c.data != "" && string(f.Data) != c.data {
+			t.Errorf("%s: data = %s", c.name, f.Data)
+		}
+	}
+	if calls := ollama.Calls(); len(calls) != 1 || calls[0].Method != "Chat" || calls[0].Model != "llama3" {
+		t.Errorf("unexpected Ollama calls %+v", calls)
+	}
+
+	// 推进时钟后，处理完下一帧即发送心跳，sent_at 取自注入的时钟
+	clock.Advance(cfg.HeartbeatInterval)
+	if err := cloud.WriteJSON(map[string]any{"type": "server_to_client", "action": "version", "request_id": "v1"}); err != nil {
+		t.Fatal(err)
+	}
+	if f := readFrame(t, cloud); f.RequestID != "v1" {
+		t.Fatalf("expected the version reply, got %+v", f)
+	}
+	if f := readFrame(t, cloud); f.Type != "heartbeat" || f.Params.SentAt != clock.Now().UnixMilli() {
+		t.Fatalf("expected a heartbeat at %d, got %+v", clock.Now().UnixMilli(), f)
+	}
+
+	// 连接关闭后 Run 返回，由调用方重连
+	cloud.Close()
+	select {
+	case err := <-done:
+		if !errors.Is(err, io.EOF) {
+			t.Errorf("expected io.EOF, got %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Run did not return after the connection closed")
+	}
+}
+
+// chatDurationSum 读取 chat 请求成功耗时直方图的 _sum
+func chatDurationSum(t *testing.T) float64 {
+	t.Helper()
+	var buf bytes.Buffer
+	metrics.Write(&buf)
+	prefix := `ollama_dev_bridge_request_duration_seconds_sum{action="chat",status="done"} `
+	for _, line := range strings.Split(buf.String(), "\n") {
+		if v, ok := strings.CutPrefix(line, prefix); ok {
+			sum, err := strconv.ParseFloat(v, 64)
+			if err != nil {
+				t.Fatal(err)
+			}
+			return sum
+		}
+	}
+	return 0
+}
+
+func TestRequestDurationUsesClock(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	clock := bridgetest.NewClock()
+	// 处理期间时钟前进 90 秒，记录的耗时应取自注入的时钟而不是系统时间
+	ollama := &bridgetest.Ollama{ChatFunc: func(context.Context, bridge.ChatRequest, func(string) error) (bridge.Reply, error) {
+		clock.Advance(90 * time.Second)
+		return bridge.Reply{Content: "ok"}, nil
+	}}
+	client, cloud := bridgetest.Pipe()
+	cfg := config.Default().Bridge
+	cfg.HeartbeatInterval = time.Hour
+	s := bridge.NewServer(client, bridge.NewHandlerFactory(ollama, logger), nil, cfg, logger)
+	s.SetClock(clock)
+	go s.Run()
+	defer cloud.Close()
+
+	if f := readFrame(t, cloud); f.Action != "capabilities" {
+		t.Fatalf("expected capabilities first, got %+v", f)
+	}
+	before := chatDurationSum(t)
+	for _, stream := range []bool{false, true} {
+		if err := cloud.WriteJSON(map[string]any{"type": "server_to_client", "action": "chat", "request_id": "d" + strconv.FormatBool(stream), "params": map[string]any{
+			"model_name": "llama3", "stream": stream, "messages": []map[string]string{{"role": "user", "content": "hi"}},
+		}}); err != nil {
+			t.Fatal(err)
+		}
+		for {
+			if f := readFrame(t, cloud); f.Status == "done" || f.Status == "error" {
+				if f.Status != "done" {
+					t.Fatalf("stream=%v: unexpected reply %+v", stream, f)
+				}
+				break
+			}
+		}
+	}
+	// 直方图由同一进程的其他测试共用，before 带有实际耗时的小数，差值按浮点误差比较
+	if got := chatDurationSum(t) - before; math.Abs(got-180) > 1e-6 {
+		t.Errorf("request duration sum grew by %v, want 180", got)
+	}
+}
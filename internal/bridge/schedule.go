@@ -0,0 +1,62 @@
+package bridge
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"ollama_dev/internal/config"
+	"ollama_dev/internal/keystore"
+	"ollama_dev/internal/scheduler"
+)
+
+// modelMaintainer 定时任务使用的模型维护操作，由 DefaultOllamaClient 实现
+type modelMaintainer interface {
+	RefreshModels(ctx context.Context) error
+	WarmModel(ctx context.Context, model string) error
+}
+
+// addScheduledJobs 按 schedule.jobs 向调度器添加任务，注入的 Ollama 客户端不支持模型维护时
+// refresh_models 与 warm_models 任务返回错误
+func addScheduledJobs(s *scheduler.Scheduler, cfg *config.Config, client OllamaClient) error {
+	for _, j := range cfg.Schedule.Jobs {
+		var fn scheduler.Func
+		ollama, ok := client.(modelMaintainer)
+		if !ok && (j.Task == config.TaskRefreshModels || j.Task == config.TaskWarmModels) {
+			return fmt.Errorf("任务 %s: Ollama 客户端不支持 %s", j.Name, j.Task)
+		}
+		switch j.Task {
+		case config.TaskRefreshModels:
+			fn = ollama.RefreshModels
+		case config.TaskWarmModels:
+			fn = func(ctx context.Context) error {
+				var errs []error
+				for _, m := range j.Models {
+					if err := ollama.WarmModel(ctx, m); err != nil {
+						errs = append(errs, fmt.Errorf("预热 %s 失败: %w", m, err))
+					}
+				}
+				return errors.Join(errs...)
+			}
+		case config.TaskRotateKeys:
+			fn = func(ctx context.Context) error {
+				keys, err := keystore.Open(cfg.E2E.Keystore, []byte(cfg.E2E.Passphrase))
+				if err != nil {
+					return err
+				}
+				for _, tenant := range j.Tenants {
+					if _, err := keys.Rotate(tenant, j.Keep); err != nil {
+						return fmt.Errorf("轮换租户 %s 的密钥失败: %w", tenant, err)
+					}
+				}
+				return nil
+			}
+		default:
+			return fmt.Errorf("任务 %s: 未知的任务类型 %q", j.Name, j.Task)
+		}
+		if err := s.Add(j.Name, j.Task, j.Schedule, fn); err != nil {
+			return err
+		}
+	}
+	return nil
+}
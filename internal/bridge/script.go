@@ -0,0 +1,181 @@
+package bridge
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+
+	"ollama_dev/internal/apperr"
+	"ollama_dev/internal/script"
+	"ollama_dev/internal/wasm"
+)
+
+// SetScripts 启用 Lua 脚本的钩子与自定义动作，rt 为 nil 时不启用；脚本动作不得与内置或已注册的动作重名
+func (f *HandlerFactory) SetScripts(rt *script.Runtime) error {
+	for _, action := range rt.Actions() {
+		if _, ok := registeredAction(action); ok || slices.Contains(builtinActions(), action) {
+			return fmt.Errorf("脚本动作 %s 与已有动作重名", action)
+		}
+	}
+	f.scripts = rt
+	return nil
+}
+
+// SetTransforms 启用 WASM 变换模块，rt 为 nil 时不启用
+func (f *HandlerFactory) SetTransforms(rt *wasm.Runtime) {
+	f.transforms = rt
+}
+
+// hooks 请求前后的钩子，由 Lua 脚本 (script.Runtime) 或 WASM 模块 (wasm.Runtime) 实现
+type hooks interface {
+	HasBefore() bool
+	HasAfter() bool
+	Before(ctx context.Context, req *script.Request) error
+	After(ctx context.Context, req *script.Request, data any) (any, error)
+}
+
+// withHooks 在处理器前后运行钩子，流式处理器的钩子只作用于请求与最终的 done 帧
+func withHooks(h RequestHandler, rt hooks) RequestHandler {
+	if !rt.HasBefore() && !rt.HasAfter() {
+		return h
+	}
+	hooked := &hookedHandler{next: h, scripts: rt}
+	if sh, ok := h.(StreamHandler); ok {
+		return &hookedStreamHandler{hookedHandler: hooked, stream: sh}
+	}
+	return hooked
+}
+
+type hookedHandler struct {
+	next    RequestHandler
+	scripts hooks
+}
+
+func (h *hookedHandler) Handle(ctx context.Context, req *CloudRequest) (*CloudResponse, error) {
+	if err := h.before(ctx, req); err != nil {
+		return nil, err
+	}
+	resp, err := h.next.Handle(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	return h.after(ctx, req, resp)
+}
+
+// before 运行 before 钩子，修改后的 params 写回请求
+func (h *hookedHandler) before(ctx context.Context, req *CloudRequest) error {
+	if !h.scripts.HasBefore() {
+		return nil
+	}
+	sreq, err := scriptRequest(req)
+	if err != nil {
+		return err
+	}
+	if err := h.scripts.Before(ctx, sreq); err != nil {
+		return scriptError(err)
+	}
+	raw, err := json.Marshal(sreq.Params)
+	if err != nil {
+		return apperr.Wrap(err, apperr.Internal, apperr.CodeInternal, "编码脚本修改的参数失败")
+	}
+	var params CloudParams
+	if err := unmarshal(raw, &params, false); err != nil {
+		return apperr.Wrap(err, apperr.Validation, apperr.CodeInvalidParams, "脚本修改的参数无效")
+	}
+	req.Params, req.RawParams = params, raw
+	return nil
+}
+
+// after 运行 after 钩子，返回值替换响应的 data
+func (h *hookedHandler) after(ctx context.Context, req *CloudRequest, resp *CloudResponse) (*CloudResponse, error) {
+	if !h.scripts.HasAfter() {
+		return resp, nil
+	}
+	sreq, err := scriptRequest(req)
+	if err != nil {
+		return nil, err
+	}
+	data, err := jsonValue(resp.Data)
+	if err != nil {
+		return nil, apperr.Wrap(err, apperr.Internal, apperr.CodeInternal, "编码响应失败")
+	}
+	if resp.Data, err = h.scripts.After(ctx, sreq, data); err != nil {
+		return nil, scriptError(err)
+	}
+	return resp, nil
+}
+
+type hookedStreamHandler struct {
+	*hookedHandler
+	stream StreamHandler
+}
+
+func (h *hookedStreamHandler) HandleStream(ctx context.Context, req *CloudRequest, emit func(data any) error) (*CloudResponse, error) {
+	if err := h.before(ctx, req); err != nil {
+		return nil, err
+	}
+	resp, err := h.stream.HandleStream(ctx, req, emit)
+	if err != nil {
+		return nil, err
+	}
+	return h.after(ctx, req, resp)
+}
+
+// ScriptHandler 执行脚本定义的动作，返回值作为 done 帧的 data
+type ScriptHandler struct {
+	scripts *script.Runtime
+}
+
+func (h *ScriptHandler) Handle(ctx context.Context, req *CloudRequest) (*CloudResponse, error) {
+	sreq, err := scriptRequest(req)
+	if err != nil {
+		return nil, err
+	}
+	data, err := h.scripts.Call(ctx, sreq)
+	if err != nil {
+		return nil, scriptError(err)
+	}
+	return newResponse(req, data), nil
+}
+
+// scriptRequest 将请求转换为脚本看到的形式，params 优先取收到的原始 params，保留 CloudParams 之外的字段
+func scriptRequest(req *CloudRequest) (*script.Request, error) {
+	raw := []byte(req.RawParams)
+	if len(raw) == 0 {
+		var err error
+		if raw, err = json.Marshal(req.Params); err != nil {
+			return nil, apperr.Wrap(err, apperr.Internal, apperr.CodeInternal, "编码请求参数失败")
+		}
+	}
+	var params any
+	if err := json.Unmarshal(raw, &params); err != nil {
+		return nil, apperr.Wrap(err, apperr.Protocol, apperr.CodeBadFrame, "解析请求参数失败")
+	}
+	return &script.Request{Action: req.Action, RequestID: req.RequestID, TenantID: req.TenantID, Params: params}, nil
+}
+
+// jsonValue 将任意值经 JSON 编码再解码，得到脚本可处理的 map、slice 与基本类型
+func jsonValue(v any) (any, error) {
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var out any
+	err = json.Unmarshal(raw, &out)
+	return out, err
+}
+
+// scriptError 脚本或 WASM 模块拒绝的请求归类为 invalid_params，超时归类为 timeout，其余为 internal
+func scriptError(err error) error {
+	var rejected *script.Rejected
+	switch {
+	case errors.As(err, &rejected):
+		return apperr.New(apperr.Validation, apperr.CodeInvalidParams, rejected.Reason)
+	case errors.Is(err, context.DeadlineExceeded):
+		return apperr.Wrap(err, apperr.Timeout, apperr.CodeTimeout, "脚本执行超时")
+	default:
+		return apperr.Wrap(err, apperr.Internal, apperr.CodeInternal, "脚本执行失败")
+	}
+}
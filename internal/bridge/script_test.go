@@ -0,0 +1,119 @@
+package bridge
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"ollama_dev/internal/apperr"
+	"ollama_dev/internal/config"
+	"ollama_dev/internal/script"
+)
+
+// modelRecorder 记录对话使用的模型
+type modelRecorder struct {
+	model string
+}
+
+func (m *modelRecorder) Chat(ctx context.Context, req ChatRequest) (Reply, error) {
+	m.model = req.Model
+	return Reply{Content: "hello"}, nil
+}
+func (m *modelRecorder) ChatStream(ctx context.Context, req ChatRequest, onChunk func(string) error) (Reply, error) {
+	return m.Chat(ctx, req)
+}
+func (m *modelRecorder) Generate(ctx context.Context, req GenerateRequest, onChunk func(string) error) (Reply, error) {
+	m.model = req.Model
+	return Reply{Content: "hello"}, nil
+}
+func (m *modelRecorder) Embed(ctx context.Context, req EmbedRequest) (Embeddings, error) {
+	m.model = req.Model
+	return Embeddings{}, nil
+}
+func (m *modelRecorder) ListModels(ctx context.Context) ([]ModelInfo, error) { return nil, nil }
+func (m *modelRecorder) Heartbeat(ctx context.Context) error                 { return nil }
+
+func loadScripts(t *testing.T, src string) *script.Runtime {
+	t.Helper()
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "hooks.lua"), []byte(src), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	rt, err := script.Load(config.ScriptsConfig{Dir: dir, Timeout: time.Second}, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return rt
+}
+
+func TestScriptHooksWrapHandlers(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	ollama := &modelRecorder{}
+	factory := NewHandlerFactory(ollama, logger)
+	err := factory.SetScripts(loadScripts(t, `return {
+		before = function(req)
+			if req.params.model_name == "blocked" then return "模型已停用" end
+			if req.params.model_name == "default" then req.params.model_name = "llama3" end
+		end,
+		after = function(req, data)
+			if req.action == "chat" then data.hooked = true end
+		end,
+		actions = {whoami = function(req) return {tenant = req.tenant_id, extra = req.params.extra} end},
+	}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req := &CloudRequest{Action: "chat", RequestID: "1", Params: CloudParams{ModelName: "default", Messages: []ChatMessage{{Role: "user", Content: "hi"}}}}
+	resp, err := factory.CreateHandler("chat").Handle(context.Background(), req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ollama.model != "llama3" {
+		t.Errorf("before hook did not rewrite the model, chat used %q", ollama.model)
+	}
+	data, _ := json.Marshal(resp.Data)
+	var got struct {
+		Hooked  bool        `json:"hooked"`
+		Message ChatMessage `json:"message"`
+	}
+	_ = json.Unmarshal(data, &got)
+	if !got.Hooked || got.Message.Content != "hello" {
+		t.Errorf("after hook result %s", data)
+	}
+
+	_, err = factory.CreateHandler("chat").Handle(context.Background(), &CloudRequest{Action: "chat", Params: CloudParams{ModelName: "blocked"}})
+	if apperr.CodeOf(err) != apperr.CodeInvalidParams {
+		t.Errorf("expected invalid_params from the rejecting hook, got %v", err)
+	}
+
+	// 脚本动作出现在能力列表中，不依赖 Ollama，可以读取 CloudParams 之外的字段
+	if !slices.Contains(factory.Actions(), "whoami") || factory.NeedsBackend("whoami") {
+		t.Errorf("whoami should be advertised and not need the backend: %v", factory.Actions())
+	}
+	msg, err := parseFrame([]byte(`{"v":2,"type":"server_to_client","action":"whoami","request_id":"2","tenant_id":"acme","params":{"extra":"x"}}`), true, nil, factory)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err = factory.CreateHandler("whoami").Handle(context.Background(), msg.Request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ = json.Marshal(resp.Data)
+	if string(data) != `{"extra":"x","tenant":"acme"}` {
+		t.Errorf("whoami data %s", data)
+	}
+}
+
+func TestScriptActionsCannotShadowBuiltins(t *testing.T) {
+	factory := NewHandlerFactory(&fakeOllama{}, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	if err := factory.SetScripts(loadScripts(t, `return {actions = {chat = function() end}}`)); err == nil {
+		t.Error("script action named chat was accepted")
+	}
+}
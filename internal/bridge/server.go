@@ -0,0 +1,667 @@
+package bridge
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/google/uuid"
+
+	"ollama_dev/internal/apperr"
+	"ollama_dev/internal/breaker"
+	"ollama_dev/internal/config"
+	"ollama_dev/internal/i18n"
+	"ollama_dev/internal/janitor"
+	"ollama_dev/internal/keystore"
+	"ollama_dev/internal/stats"
+	"ollama_dev/internal/tenant"
+	"ollama_dev/internal/tracing"
+	"ollama_dev/internal/util"
+	"ollama_dev/internal/util/wsutils"
+	"ollama_dev/internal/version"
+)
+
+// Server 结构体
+type Server struct {
+	wsClient       WSClient
+	handlerFactory *HandlerFactory
+	health         *HealthChecker // 可为 nil，表示不做后端探测
+	crash          *CrashReporter
+	recorder       *Recorder // 可为 nil，表示不录制
+	dedup          *dedup    // 可为 nil，表示不做去重
+	streams        *streamRegistry
+	inflight       *inflightRegistry
+	outbox         Outbox // 可为 nil，表示写入失败的响应直接丢弃
+	latency        *latencyTracker
+	pending        *pendingCalls // 本端发出、等待回复的请求
+	upstream       string        // 写入发出的帧的 upstream 字段，只连接 bridge.url 时为空
+	clock          Clock
+	logger         Logger
+
+	heartbeatInterval time.Duration
+	readTimeout       time.Duration
+	creditTimeout     time.Duration // 流式响应等待额度的最长时间
+	timeouts          config.TimeoutsConfig
+	strict            bool             // 拒绝含未知字段的帧
+	e2e               *e2e             // 可为 nil，表示未启用端到端加密
+	workers           *workerPool      // 可为 nil，表示在读取循环中逐个处理请求
+	queue             *jobQueue        // 可为 nil，表示不排队
+	queueNotify       sync.Mutex       // 发送 queued 帧期间持有，任务开始执行或被取消前等待发送完毕
+	breaker           *breaker.Breaker // 可为 nil，表示未启用熔断
+	closing           atomic.Bool      // Shutdown 开始后新请求直接回复 errShuttingDown
+	lastHeartbeat     atomic.Int64     // 最近一次成功发送心跳的时间 (UnixNano)
+}
+
+func NewServer(wsClient WSClient, handlerFactory *HandlerFactory, health *HealthChecker, cfg config.BridgeConfig, logger Logger) *Server {
+	s := &Server{
+		wsClient:          wsClient,
+		handlerFactory:    handlerFactory,
+		health:            health,
+		crash:             NewCrashReporter(cfg.Crash, logger),
+		dedup:             newDedup(cfg.DedupTTL),
+		streams:           newStreamRegistry(),
+		inflight:          newInflightRegistry(),
+		latency:           newLatencyTracker(),
+		pending:           newPendingCalls(),
+		clock:             SystemClock,
+		logger:            logger,
+		heartbeatInterval: cfg.HeartbeatInterval,
+		readTimeout:       cfg.ReadTimeout,
+		creditTimeout:     cfg.CreditTimeout,
+		timeouts:          cfg.Timeouts,
+		strict:            cfg.StrictDecoding,
+	}
+	if ob := newMemoryOutbox(cfg.OutboxSize); ob != nil {
+		ob.dropped = func(frame []byte) {
+			s.logger.Error("待发送队列已满，丢弃最早的响应", "size", cfg.OutboxSize)
+		}
+		s.outbox = ob
+	}
+	return s
+}
+
+// RegisterSweepers 向 janitor 注册需要定期清理的数据
+func (s *Server) RegisterSweepers(j *janitor.Janitor) {
+	if s.dedup != nil {
+		j.Register("dedup", s.dedup.sweep)
+	}
+	j.Register("rpc_calls", s.sweepCalls)
+	if s.handlerFactory.files != nil {
+		j.Register("transfers", s.handlerFactory.files.Sweep)
+	}
+}
+
+// SetKeystore 启用端到端加密，之后只接受加密的请求，响应使用同一租户的密钥加密
+func (s *Server) SetKeystore(keys *keystore.Store, c util.Cipher) {
+	s.e2e = &e2e{keys: keys, cipher: c}
+}
+
+// SetUpstream 设置上游名称，之后发出的响应、请求与心跳都带 upstream 字段
+func (s *Server) SetUpstream(name string) {
+	s.upstream = name
+}
+
+// SetOutbox 替换待发送队列，传入 nil 表示不缓存写入失败的响应
+func (s *Server) SetOutbox(o Outbox) {
+	s.outbox = o
+}
+
+// StartWorkers 按配置启动工作池，之后非流式请求在工作池中并发处理；返回的函数停止工作池并等待排队的请求处理完毕
+func (s *Server) StartWorkers(cfg config.WorkersConfig) (stop func()) {
+	s.workers = newWorkerPool(cfg)
+	if s.workers == nil {
+		return func() {}
+	}
+	return s.workers.close
+}
+
+// SetBreaker 在心跳的后端状态中附带 Ollama 调用的熔断状态
+func (s *Server) SetBreaker(b *breaker.Breaker) {
+	s.breaker = b
+}
+
+// SetRecorder 启用录制，收到的每一帧都会写入录制文件
+func (s *Server) SetRecorder(r *Recorder) {
+	s.recorder = r
+}
+
+func (s *Server) Run() error {
+	s.latency.reset()
+	if err := s.sendCapabilities(); err != nil {
+		s.logger.Error("发送能力握手失败", "error", err)
+	}
+	s.flushOutbox()
+
+	heartbeatTicker := s.clock.NewTicker(s.heartbeatInterval)
+	defer heartbeatTicker.Stop()
+	// 退出时终止仍在等待额度的流式响应
+	defer s.streams.closeAll()
+
+	for {
+		// 设置读取超时
+		if err := s.setReadDeadline(s.clock.Now().Add(s.readTimeout)); err != nil {
+			s.logger.Error("设置读取超时失败", "error", err)
+			return err
+		}
+
+		select {
+		case <-heartbeatTicker.C():
+			if err := s.sendHeartbeat(); err != nil {
+				// 写入失败后连接不可再用，随后的读取返回错误，由 upstream.run 重连
+				s.logger.Error("发送心跳失败", "error", err)
+			}
+
+		default:
+			msg, err := s.readAndParseMessage()
+			if err != nil {
+				// 读取失败（含超时）后连接不可再用，返回由调用方重连；分片无效等协议错误不影响连接
+				if msg == nil && !apperr.Is(err, apperr.Protocol) {
+					var netErr net.Error
+					if errors.As(err, &netErr) && netErr.Timeout() {
+						s.logger.Info("读取超时，连接可能已失效")
+					}
+					return err
+				}
+				s.logger.Error("处理消息时发生错误", "error", err)
+				if msg != nil {
+					s.reject(msg, err)
+				}
+				continue // 不退出循环，继续处理后续消息
+			}
+
+			// 单条消息处理中的 panic 写入崩溃报告后继续处理后续消息
+			if err := s.crash.Guard("main_loop", func() error { return s.dispatch(msg) }); err != nil {
+				s.logger.Error("处理消息失败", "error", err)
+			}
+			msg.release()
+		}
+	}
+}
+
+// dispatch 按帧的种类分发处理：请求需要处理并回复，本端请求的回复交给等待方，其他响应（通常是服务端广播回来的自身消息）直接忽略；
+// 与本端心跳 request_id 相同的心跳帧视为确认，用于计算往返时延
+func (s *Server) dispatch(msg *Message) error {
+	if msg.Kind == KindHeartbeat {
+		s.latency.ack(msg.Request.RequestID)
+		return nil
+	}
+	if msg.Kind == KindResponse {
+		s.resolve(msg)
+		return nil
+	}
+	// hub 广播回来的本端请求不处理
+	if msg.Kind != KindRequest || s.pending.has(msg.Request.RequestID) {
+		return nil
+	}
+	if err := s.handleServerRequest(msg); err != nil {
+		return fmt.Errorf("处理服务端请求失败: %w", err)
+	}
+	return nil
+}
+
+// reject 对无法解析的帧回复 bad_frame 错误，使对端无需等待超时
+func (s *Server) reject(msg *Message, err error) {
+	defer msg.release()
+	if sendErr := s.sendResponse(&Message{Response: errorResponse(msg.Request, err)}); sendErr != nil {
+		s.logger.Error("回复错误帧失败", "error", sendErr)
+	}
+}
+
+// requestContext 在 parent 上附加动作时限，并写入请求所属的租户，生成结果缓存据此按租户隔离
+func (s *Server) requestContext(parent context.Context, req *CloudRequest) (context.Context, context.CancelFunc) {
+	parent = tenant.WithTenant(parent, req.TenantID)
+	if d := s.timeouts.For(req.Action); d > 0 {
+		return context.WithTimeout(parent, d)
+	}
+	return context.WithCancel(parent)
+}
+
+// invoke 记录请求并在动作的时限内调用对应的处理器，处理器中的 panic 转换为 Internal 错误；
+// 排队期间已被取消的请求不再调用处理器
+func (s *Server) invoke(parent context.Context, req *CloudRequest) (*CloudResponse, error) {
+	if err := cancelled(parent, parent.Err()); err != nil {
+		return nil, err
+	}
+	s.crash.Record(req)
+	handler := s.handlerFactory.CreateHandler(req.Action)
+	parent, span := traceRequest(parent, "bridge", req)
+	ctx, cancel := s.requestContext(parent, req)
+	defer cancel()
+
+	var resp *CloudResponse
+	start := s.clock.Now()
+	err := s.crash.Guard("handler:"+req.Action, func() error {
+		var err error
+		resp, err = handler.Handle(ctx, req)
+		return err
+	})
+	observeRequest(req.Action, s.clock.Now().Sub(start).Seconds(), err)
+	err = cancelled(ctx, err)
+	tracing.End(span, err)
+	return resp, err
+}
+
+// ActionPing 心跳帧的动作名
+const ActionPing = "ping"
+
+func (s *Server) sendHeartbeat() error {
+	requestID := uuid.New().String()
+	heartbeatReq := acquireRequest()
+	defer releaseRequest(heartbeatReq)
+	heartbeatReq.V = ProtocolVersion
+	heartbeatReq.Type = TypeHeartbeat
+	heartbeatReq.Action = ActionPing
+	heartbeatReq.RequestID = requestID
+	heartbeatReq.Params.Latency = s.latency.stats()
+	if s.health != nil {
+		status := s.health.Status()
+		if s.breaker != nil {
+			b := s.breaker.Status()
+			status.Breaker = &b
+		}
+		heartbeatReq.Params.Backend = &status
+	}
+
+	sentAt := s.clock.Now()
+	heartbeatReq.Params.SentAt = sentAt.UnixMilli()
+	if err := s.writeRequest(heartbeatReq); err != nil {
+		return fmt.Errorf("发送心跳消息失败: %w", err)
+	}
+	s.latency.sent(requestID, sentAt)
+	s.lastHeartbeat.Store(sentAt.UnixNano())
+
+	s.logger.Info("心跳已发送", "request_id", requestID)
+	return nil
+}
+
+// LastHeartbeat 返回最近一次成功发送心跳的时间，尚未发送时为零值
+func (s *Server) LastHeartbeat() time.Time {
+	if at := s.lastHeartbeat.Load(); at != 0 {
+		return time.Unix(0, at)
+	}
+	return time.Time{}
+}
+
+// Capabilities 连接建立后主动上报的能力信息
+type Capabilities struct {
+	Version  version.Info  `json:"version"`
+	Protocol int           `json:"protocol"` // 支持的最高协议版本
+	Actions  []string      `json:"actions"`
+	Latency  *LatencyStats `json:"latency,omitempty"` // 重连前测得的往返时延，首次连接时为空
+}
+
+// sendCapabilities 连接建立后上报版本与支持的动作
+func (s *Server) sendCapabilities() error {
+	msg := &Message{Response: &CloudResponse{
+		V:      ProtocolVersion,
+		Type:   TypeClientToServer,
+		Action: "capabilities",
+		Data: Capabilities{
+			Version:  version.Get(),
+			Protocol: ProtocolVersion,
+			Actions:  s.handlerFactory.Actions(),
+			Latency:  s.latency.stats(),
+		},
+		Status: StatusDone,
+	}}
+	return s.sendResponse(msg)
+}
+
+func (s *Server) handleServerRequest(msg *Message) error {
+	if msg.Request == nil {
+		return apperr.New(apperr.Protocol, apperr.CodeBadFrame, "处理消息时发生错误: 请求为空")
+	}
+	if msg.Request.Action == "" {
+		err := apperr.New(apperr.Protocol, apperr.CodeBadFrame, "处理消息时发生错误: 动作为空")
+		msg.Response = errorResponse(msg.Request, err)
+		if sendErr := s.sendResponse(msg); sendErr != nil {
+			return sendErr
+		}
+		return err
+	}
+	// 追加额度与取消的帧与原请求共用 request_id，需先于去重处理
+	switch msg.Request.Action {
+	case ActionCredit:
+		s.streams.grant(msg.Request.RequestID, msg.Request.Params.Credits)
+		return nil
+	case ActionCancel:
+		// 被取消的请求回复 cancelled 错误帧，取消帧本身不回复；等待额度的流同时停止等待，排队中的请求移出队列
+		if _, ok := s.CancelJob(msg.Request.TenantID, msg.Request.RequestID); !ok && !s.inflight.cancel(msg.Request.RequestID) {
+			s.logger.Info("要取消的请求不存在或已结束", "request_id", msg.Request.RequestID)
+		}
+		s.streams.close(msg.Request.RequestID)
+		return nil
+	}
+	if s.closing.Load() {
+		msg.Response = errorResponse(msg.Request, errShuttingDown)
+		return s.sendResponse(msg)
+	}
+	if frame, seen := s.dedup.begin(msg.Request.RequestID); seen {
+		return s.resend(msg.Request, frame)
+	}
+	if resp := s.backendUnavailable(msg.Request); resp != nil {
+		s.dedup.forget(msg.Request.RequestID)
+		msg.Response = resp
+		return s.sendResponse(msg)
+	}
+	if s.queue.handles(msg.Request.Action) {
+		// 排队的请求开始执行时才登记到 inflight，关闭时不等待排队中的请求
+		return s.enqueue(msg)
+	}
+	ctx, done := s.inflight.begin(msg.Request.RequestID)
+	if msg.Request.Params.Stream {
+		if h, ok := s.handlerFactory.CreateHandler(msg.Request.Action).(StreamHandler); ok {
+			// 流式响应在独立 goroutine 中进行，读取循环继续接收 credit 与 cancel 帧；请求的所有权随之转移
+			req := msg.Request
+			msg.Request = nil
+			go func() {
+				defer done()
+				s.stream(ctx, req, h)
+			}()
+			return nil
+		}
+	}
+	if s.workers != nil {
+		// 在工作池中处理，读取循环继续接收后续请求；请求的所有权随之转移
+		req := msg.Request
+		msg.Request = nil
+		err := s.workers.submit(req.Action, func() {
+			defer done()
+			m := &Message{Request: req}
+			defer m.release()
+			if err := s.process(ctx, m); err != nil {
+				s.logger.Error("处理服务端请求失败", "action", req.Action, "request_id", req.RequestID, "error", err)
+			}
+		})
+		if err != nil {
+			done()
+			msg.Request = req
+			s.dedup.forget(req.RequestID)
+			msg.Response = errorResponse(req, err)
+			if sendErr := s.sendResponse(msg); sendErr != nil {
+				return sendErr
+			}
+			return err
+		}
+		return nil
+	}
+	defer done()
+	return s.process(ctx, msg)
+}
+
+// process 调用处理器并回复响应
+func (s *Server) process(ctx context.Context, msg *Message) error {
+	resp, err := s.invoke(ctx, msg.Request)
+	if err != nil {
+		// 失败时同样回复错误帧，避免云端等待超时；不记录结果，重试时重新执行
+		s.dedup.forget(msg.Request.RequestID)
+		msg.Response = errorResponse(msg.Request, err)
+		if sendErr := s.sendResponse(msg); sendErr != nil {
+			return sendErr
+		}
+		return err
+	}
+	msg.Response = resp
+	return s.sendAndRemember(msg)
+}
+
+// stream 执行流式请求：每个分片消耗一个额度，额度耗尽时暂停直到云端追加，超时后回复 timeout 错误
+func (s *Server) stream(parent context.Context, req *CloudRequest, h StreamHandler) {
+	defer releaseRequest(req)
+	s.crash.Record(req)
+	window := s.streams.open(req.RequestID, req.Params.Credits)
+	defer s.streams.done(req.RequestID)
+	parent, span := traceRequest(parent, "bridge", req)
+	ctx, cancel := s.requestContext(parent, req)
+	defer cancel()
+	// 连接中途断开时不再发送分片，继续生成，完整响应写入待发送队列，重连后送达
+	detached := false
+
+	var resp *CloudResponse
+	start := s.clock.Now()
+	err := s.crash.Guard("stream:"+req.Action, func() error {
+		var err error
+		resp, err = h.HandleStream(ctx, req, func(data any) error {
+			if detached {
+				return nil
+			}
+			if window != nil {
+				if err := window.acquire(s.creditTimeout); err != nil {
+					return err
+				}
+			}
+			frame := newResponse(req, data)
+			defer releaseResponse(frame)
+			frame.Status = StatusStreaming
+			if err := s.writeResponse(frame); err != nil {
+				if s.outbox == nil {
+					return err
+				}
+				s.logger.Error("发送分片失败，继续生成完整响应", "request_id", req.RequestID, "error", err)
+				detached = true
+			}
+			return nil
+		})
+		return err
+	})
+	observeRequest(req.Action, s.clock.Now().Sub(start).Seconds(), err)
+	err = cancelled(ctx, err)
+	tracing.End(span, err)
+
+	msg := &Message{Request: req}
+	defer func() { releaseResponse(msg.Response) }()
+	if err != nil {
+		s.dedup.forget(req.RequestID)
+		s.logger.Error("流式响应失败", "action", req.Action, "request_id", req.RequestID, "error", err)
+		if errors.Is(err, errStreamClosed) {
+			return
+		}
+		msg.Response = errorResponse(req, err)
+		if sendErr := s.sendResponse(msg); sendErr != nil {
+			s.logger.Error("回复错误帧失败", "error", sendErr)
+		}
+		return
+	}
+	msg.Response = resp
+	if err := s.sendAndRemember(msg); err != nil {
+		s.logger.Error("发送流式响应失败", "request_id", req.RequestID, "error", err)
+	}
+}
+
+// sendAndRemember 发送成功响应，并保存响应帧供重复请求重发
+func (s *Server) sendAndRemember(msg *Message) error {
+	buf, err := s.encodeResponse(msg.Response)
+	if err != nil {
+		s.dedup.forget(msg.Request.RequestID)
+		return fmt.Errorf("JSON 序列化失败: %w", err)
+	}
+	defer wsutils.PutBuffer(buf)
+	s.dedup.done(msg.Request.RequestID, bytes.Clone(buf.Bytes()))
+	return s.deliver(buf.Bytes())
+}
+
+// resend 处理重复的 request_id：已完成时重发原响应，仍在处理中时回复 duplicate 状态
+func (s *Server) resend(req *CloudRequest, frame []byte) error {
+	s.logger.Info("收到重复请求", "action", req.Action, "request_id", req.RequestID, "completed", frame != nil)
+	if frame != nil {
+		return s.deliver(frame)
+	}
+	resp := newResponse(req, nil)
+	resp.Status = StatusDuplicate
+	return s.sendResponse(&Message{Response: resp})
+}
+
+// readAndParseMessage 读取并解析一帧，解析失败时同时返回携带已知标识的消息，用于回复错误帧
+func (s *Server) readAndParseMessage() (*Message, error) {
+	rawMsg, err := s.wsClient.ReadMessage()
+	if err != nil {
+		return nil, fmt.Errorf("WebSocket 读取消息错误: %w", err)
+	}
+	if s.recorder != nil {
+		if err := s.recorder.Record(rawMsg); err != nil {
+			s.logger.Error("录制请求失败", "error", err)
+		}
+	}
+	return parseFrame(rawMsg, s.strict, s.e2e, s.handlerFactory)
+}
+
+// parseMessage 解析未加密的帧，请求帧完整解码 params，strict 时拒绝未知字段
+func parseMessage(rawMsg []byte, strict bool) (*Message, error) {
+	return parseFrame(rawMsg, strict, nil, nil)
+}
+
+// parseFrame 解析收到的帧，加密的请求先经 e 解密再解码 params；f 用于识别自定义动作，可为 nil
+func parseFrame(rawMsg []byte, strict bool, e *e2e, f *HandlerFactory) (*Message, error) {
+	msg := &Message{Raw: rawMsg, Request: acquireRequest()}
+
+	env, err := decodeEnvelope(rawMsg, strict)
+	if err != nil {
+		// strict 下因未知字段失败时仍尽量取出标识，便于对端关联错误帧
+		if lenient, lerr := decodeEnvelope(rawMsg, false); lerr == nil {
+			msg.Request.Action, msg.Request.RequestID = lenient.Action, lenient.RequestID
+		}
+		return msg, err
+	}
+	msg.Request.Type, msg.Request.Action, msg.Request.RequestID = env.Type, env.Action, env.RequestID
+	msg.Request.TenantID, msg.Request.User, msg.Request.Role = env.TenantID, env.User, env.Role
+
+	if msg.Kind, err = env.Kind(); err != nil {
+		return msg, err
+	}
+	if err := Migrate(env); err != nil {
+		return msg, err
+	}
+	msg.Request.V = env.V
+	if msg.Kind == KindRequest {
+		if err := e.openRequest(env, msg.Request); err != nil {
+			return msg, err
+		}
+		// 自定义动作的参数含 CloudParams 之外的字段，不按 strict 拒绝
+		if err := env.decodeParams(&msg.Request.Params, strict && !f.customAction(env.Action)); err != nil {
+			return msg, err
+		}
+		msg.Request.RawParams = env.Params
+	}
+	return msg, nil
+}
+
+// backendUnavailable 后端不可用时直接构造错误响应，避免请求挂起等待超时
+func (s *Server) backendUnavailable(req *CloudRequest) *CloudResponse {
+	if s.health == nil || s.health.Healthy() || !s.handlerFactory.NeedsBackend(req.Action) {
+		return nil
+	}
+
+	status := s.health.Status()
+	s.logger.Error(i18n.T(i18n.LogBackendRequestDenied), "action", req.Action, "request_id", req.RequestID)
+	return errorResponse(req, apperr.New(apperr.Backend, apperr.CodeBackendUnavailable, i18n.T(i18n.ErrBackendUnavailable, status.Error)))
+}
+
+// errorResponse 构造 status 为 error 的响应，data 携带错误类别与错误码
+func errorResponse(req *CloudRequest, err error) *CloudResponse {
+	data := apperr.ToData(err)
+	stats.RecordError("bridge "+req.Action, data.Code, data.Message)
+	resp := newResponse(req, data)
+	resp.Status = StatusError
+	return resp
+}
+
+func (s *Server) sendResponse(msg *Message) error {
+	buf, err := s.encodeResponse(msg.Response)
+	if err != nil {
+		return fmt.Errorf("JSON 序列化失败: %w", err)
+	}
+	defer wsutils.PutBuffer(buf)
+	return s.deliver(buf.Bytes())
+}
+
+// encodeResponse 按需加密后序列化响应；加密失败时改为回复明文的错误帧，不会泄露明文响应
+func (s *Server) encodeResponse(resp *CloudResponse) (*bytes.Buffer, error) {
+	if err := s.e2e.sealResponse(resp); err != nil {
+		s.logger.Error("加密响应失败", "request_id", resp.RequestID, "error", err)
+		resp.Data = apperr.ToData(apperr.Wrap(err, apperr.Internal, apperr.CodeInternal, "加密响应失败"))
+		resp.Status, resp.sealed = StatusError, false
+	}
+	resp.Upstream = s.upstream
+	return wsutils.EncodeJSON(resp)
+}
+
+// deliver 写入响应帧，失败时复制一份放入待发送队列，重连后由 flushOutbox 重发
+func (s *Server) deliver(frame []byte) error {
+	err := s.wsClient.WriteMessage(frame)
+	if err == nil {
+		return nil
+	}
+	if s.outbox != nil {
+		if qerr := s.outbox.Push(bytes.Clone(frame)); qerr != nil {
+			s.logger.Error("响应写入待发送队列失败", "error", qerr)
+		} else {
+			s.logger.Info("响应写入失败，已加入待发送队列", "pending", s.outbox.Len())
+		}
+	}
+	return fmt.Errorf("WebSocket 写入消息错误: %w", err)
+}
+
+// flushOutbox 按顺序重发待发送队列中的响应，遇到写入失败时停止，剩余的帧留待下次重连
+func (s *Server) flushOutbox() {
+	if s.outbox == nil {
+		return
+	}
+	sent := 0
+	for {
+		frame, ok := s.outbox.Peek()
+		if !ok {
+			break
+		}
+		if err := s.wsClient.WriteMessage(frame); err != nil {
+			s.logger.Error("重发待发送响应失败", "pending", s.outbox.Len(), "error", err)
+			return
+		}
+		if err := s.outbox.Pop(); err != nil {
+			s.logger.Error("移除已重发响应失败", "error", err)
+			return
+		}
+		sent++
+	}
+	if sent > 0 {
+		s.logger.Info("已重发待发送响应", "count", sent)
+	}
+}
+
+// writeRequest 经池化缓冲区序列化本端发出的请求并写入连接，减少高频收发时的分配
+func (s *Server) writeRequest(req *CloudRequest) error {
+	req.Upstream = s.upstream
+	buf, err := wsutils.EncodeJSON(req)
+	if err != nil {
+		return fmt.Errorf("JSON 序列化失败: %w", err)
+	}
+	defer wsutils.PutBuffer(buf)
+	return s.wsClient.WriteMessage(buf.Bytes())
+}
+
+// writeResponse 序列化（按需加密）并写入响应，失败时不进入待发送队列，用于流式分片
+func (s *Server) writeResponse(resp *CloudResponse) error {
+	buf, err := s.encodeResponse(resp)
+	if err != nil {
+		return fmt.Errorf("JSON 序列化失败: %w", err)
+	}
+	defer wsutils.PutBuffer(buf)
+	return s.wsClient.WriteMessage(buf.Bytes())
+}
+
+// ListModels 向对端请求模型列表并等待回复
+func (s *Server) ListModels(timeout time.Duration) ([]ModelInfo, error) {
+	resp, err := s.Call("list_model", CloudParams{}, timeout)
+	if err != nil {
+		return nil, err
+	}
+	var models []ModelInfo
+	if err := resp.Decode(&models); err != nil {
+		return nil, err
+	}
+	return models, nil
+}
@@ -0,0 +1,439 @@
+package bridge
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/ollama/ollama/api"
+
+	"ollama_dev/internal/apperr"
+	"ollama_dev/internal/auth"
+	"ollama_dev/internal/breaker"
+	"ollama_dev/internal/config"
+	"ollama_dev/internal/jobs"
+)
+
+// fakeWSClient 记录写出的帧
+type fakeWSClient struct {
+	written [][]byte
+}
+
+func (f *fakeWSClient) Connect(url string) error     { return nil }
+func (f *fakeWSClient) ReadMessage() ([]byte, error) { return nil, io.EOF }
+func (f *fakeWSClient) Close() error                 { return nil }
+func (f *fakeWSClient) Conn() *websocket.Conn        { return nil }
+func (f *fakeWSClient) WriteMessage(message []byte) error {
+	// message 来自缓冲池，需要复制后保存
+	f.written = append(f.written, bytes.Clone(message))
+	return nil
+}
+
+// fakeOllama 返回固定错误
+type fakeOllama struct {
+	err error
+}
+
+func (f *fakeOllama) Chat(ctx context.Context, req ChatRequest) (Reply, error) {
+	return Reply{}, f.err
+}
+func (f *fakeOllama) ChatStream(ctx context.Context, req ChatRequest, onChunk func(string) error) (Reply, error) {
+	return Reply{}, f.err
+}
+func (f *fakeOllama) Generate(ctx context.Context, req GenerateRequest, onChunk func(string) error) (Reply, error) {
+	return Reply{}, f.err
+}
+func (f *fakeOllama) Embed(ctx context.Context, req EmbedRequest) (Embeddings, error) {
+	return Embeddings{}, f.err
+}
+func (f *fakeOllama) ListModels(ctx context.Context) ([]ModelInfo, error) { return nil, f.err }
+func (f *fakeOllama) Heartbeat(ctx context.Context) error                 { return f.err }
+
+func TestHandlerErrorsAreCategorized(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	cases := []struct {
+		req      CloudRequest
+		category apperr.Category
+		code     string
+	}{
+		{CloudRequest{Type: "server_to_client", Action: "unknown", RequestID: "1"}, apperr.Protocol, apperr.CodeUnknownAction},
+		{CloudRequest{Type: "server_to_client", Action: "chat", RequestID: "2"}, apperr.Validation, apperr.CodeInvalidParams},
+		{CloudRequest{Type: "server_to_client", Action: "list_model", RequestID: "3"}, apperr.Backend, apperr.CodeBackendError},
+	}
+
+	for _, c := range cases {
+		ws := &fakeWSClient{}
+		factory := NewHandlerFactory(&fakeOllama{err: errors.New("connection refused")}, logger)
+		s := NewServer(ws, factory, nil, config.Default().Bridge, logger)
+
+		req := c.req
+		err := s.handleServerRequest(&Message{Request: &req})
+		if apperr.CategoryOf(err) != c.category {
+			t.Errorf("%s: expected category %s, got %v", req.Action, c.category, err)
+		}
+		if len(ws.written) != 1 {
+			t.Fatalf("%s: expected one error frame, got %d", req.Action, len(ws.written))
+		}
+
+		var resp struct {
+			RequestID string      `json:"request_id"`
+			Status    string      `json:"status"`
+			Data      apperr.Data `json:"data"`
+		}
+		if err := json.Unmarshal(ws.written[0], &resp); err != nil {
+			t.Fatalf("decode frame: %v", err)
+		}
+		if resp.Status != "error" || resp.RequestID != req.RequestID || resp.Data.Category != c.category || resp.Data.Code != c.code {
+			t.Errorf("%s: unexpected error frame %+v", req.Action, resp)
+		}
+	}
+}
+
+// fakeTransfer 立即完成传输
+type fakeTransfer struct{}
+
+func (fakeTransfer) Pull(ctx context.Context, model string, fn func(jobs.Progress)) error { return nil }
+func (fakeTransfer) Push(ctx context.Context, model string, fn func(jobs.Progress)) error { return nil }
+
+func TestJobActions(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	factory := NewHandlerFactory(&fakeOllama{}, logger)
+	if slices.Contains(factory.Actions(), "pull_model") {
+		t.Fatal("job actions should be hidden until SetJobs")
+	}
+	store, _ := jobs.Open("")
+	manager := jobs.NewManager(context.Background(), store, fakeTransfer{}, logger)
+	factory.SetJobs(manager, nil)
+	if !slices.Contains(factory.Actions(), "pull_model") {
+		t.Fatal("expected pull_model after SetJobs")
+	}
+
+	resp, err := factory.CreateHandler("pull_model").Handle(context.Background(), &CloudRequest{Action: "pull_model", Role: auth.RoleAdmin, Params: CloudParams{ModelName: "llama3"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	j := resp.Data.(jobs.Job)
+	manager.Wait()
+	got, err := factory.CreateHandler("get_job").Handle(context.Background(), &CloudRequest{Action: "get_job", Params: CloudParams{JobID: j.ID}})
+	if err != nil || got.Data.(jobs.Job).Status != jobs.StatusDone {
+		t.Fatalf("unexpected get_job result: %+v, %v", got, err)
+	}
+
+	// 不存在的任务返回 not_found
+	_, err = factory.CreateHandler("get_job").Handle(context.Background(), &CloudRequest{Action: "get_job", Params: CloudParams{JobID: "missing"}})
+	if apperr.CodeOf(err) != apperr.CodeNotFound {
+		t.Errorf("expected not_found, got %v", err)
+	}
+}
+
+// steppedTransfer 每上报一次进度后等待 next，模拟逐层下载
+type steppedTransfer struct {
+	next chan struct{}
+}
+
+func (s steppedTransfer) Pull(ctx context.Context, model string, fn func(jobs.Progress)) error {
+	for i := int64(1); i <= 3; i++ {
+		fn(jobs.Progress{Status: "pulling aaa", Digest: "sha256:aaa", Completed: i * 100, Total: 300})
+		if i < 3 {
+			<-s.next
+		}
+	}
+	return nil
+}
+
+func (s steppedTransfer) Push(ctx context.Context, model string, fn func(jobs.Progress)) error {
+	return nil
+}
+
+func TestPullStreamsProgress(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	store, _ := jobs.Open("")
+	transfer := steppedTransfer{next: make(chan struct{}, 10)}
+	h := NewJobHandler(jobs.NewManager(context.Background(), store, transfer, logger), nil)
+
+	var frames []jobs.Job
+	resp, err := h.HandleStream(context.Background(), &CloudRequest{Action: "pull_model", Params: CloudParams{ModelName: "llama3", Stream: true}}, func(data any) error {
+		frames = append(frames, data.(jobs.Job))
+		transfer.next <- struct{}{}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if final := resp.Data.(jobs.Job); final.Status != jobs.StatusDone || final.Completed != 300 {
+		t.Errorf("unexpected done frame: %+v", final)
+	}
+	// 中间帧为未结束的任务，进度单调递增
+	var last int64
+	for _, f := range frames {
+		if f.Finished() || f.Completed < last {
+			t.Errorf("unexpected progress frame: %+v", f)
+		}
+		if f.Total > 0 && f.Percent != int(f.Completed*100/f.Total) {
+			t.Errorf("unexpected percent in frame: %+v", f)
+		}
+		last = f.Completed
+	}
+	if last == 0 {
+		t.Errorf("expected at least one progress frame with bytes transferred, got %+v", frames)
+	}
+}
+
+// fakeManager 记录删除与复制的模型
+type fakeManager struct {
+	deleted, copied []string
+	err             error
+}
+
+func (m *fakeManager) Delete(ctx context.Context, model string) error {
+	m.deleted = append(m.deleted, model)
+	return m.err
+}
+
+func (m *fakeManager) Copy(ctx context.Context, source, destination string) error {
+	m.copied = append(m.copied, source+"->"+destination)
+	return m.err
+}
+
+func TestModelManageActions(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	factory := NewHandlerFactory(&fakeOllama{}, logger)
+	if slices.Contains(factory.Actions(), "delete_model") {
+		t.Fatal("manage actions should be hidden until SetModelManager")
+	}
+	m := &fakeManager{}
+	factory.SetModelManager(m)
+	if !slices.Contains(factory.Actions(), "copy_model") {
+		t.Fatal("expected copy_model after SetModelManager")
+	}
+
+	// 未经 serve 核实为 admin 的请求被拒绝，不调用 Ollama
+	if _, err := factory.CreateHandler("delete_model").Handle(context.Background(), &CloudRequest{Action: "delete_model", Role: auth.RoleUser, Params: CloudParams{ModelName: "llama3"}}); apperr.CodeOf(err) != apperr.CodeForbidden || len(m.deleted) != 0 {
+		t.Fatalf("expected forbidden for a user-role request, got %v (deleted %v)", err, m.deleted)
+	}
+	handle := func(action string, params CloudParams) (*CloudResponse, error) {
+		return factory.CreateHandler(action).Handle(context.Background(), &CloudRequest{Action: action, Role: auth.RoleAdmin, Params: params})
+	}
+	if _, err := handle("copy_model", CloudParams{ModelName: "llama3", Destination: "mine"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := handle("delete_model", CloudParams{ModelName: "mine"}); err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(m.copied, []string{"llama3->mine"}) || !slices.Equal(m.deleted, []string{"mine"}) {
+		t.Errorf("unexpected calls: copied=%v deleted=%v", m.copied, m.deleted)
+	}
+
+	// 缺少参数时不调用后端
+	if _, err := handle("copy_model", CloudParams{ModelName: "llama3"}); apperr.CodeOf(err) != apperr.CodeInvalidParams {
+		t.Errorf("expected invalid_params, got %v", err)
+	}
+	m.err = errors.New("model not found")
+	if _, err := handle("delete_model", CloudParams{ModelName: "missing"}); apperr.CodeOf(err) != apperr.CodeBackendError {
+		t.Errorf("expected backend_error, got %v", err)
+	}
+}
+
+// fakeInspector 返回固定的模型详情与运行状态
+type fakeInspector struct{}
+
+func (fakeInspector) Show(ctx context.Context, model string) (ModelDetail, error) {
+	if model != "llama3" {
+		return ModelDetail{}, api.StatusError{StatusCode: http.StatusNotFound, ErrorMessage: "model not found"}
+	}
+	return ModelDetail{Name: model, Parameters: "num_ctx 4096", License: "MIT"}, nil
+}
+
+func (fakeInspector) Running(ctx context.Context) ([]RunningModel, error) {
+	return []RunningModel{{Name: "llama3", SizeVRAM: 4 << 30}}, nil
+}
+
+func TestModelInspectActions(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	factory := NewHandlerFactory(&fakeOllama{}, logger)
+	if slices.Contains(factory.Actions(), "ps") {
+		t.Fatal("inspect actions should be hidden until SetModelInspector")
+	}
+	factory.SetModelInspector(fakeInspector{})
+	if !slices.Contains(factory.Actions(), "show_model") || !factory.NeedsBackend("ps") {
+		t.Fatal("expected show_model and ps after SetModelInspector")
+	}
+
+	handle := func(action, model string) (*CloudResponse, error) {
+		return factory.CreateHandler(action).Handle(context.Background(), &CloudRequest{Action: action, Params: CloudParams{ModelName: model}})
+	}
+	resp, err := handle("show_model", "llama3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d, ok := resp.Data.(ModelDetail); !ok || d.Parameters != "num_ctx 4096" || d.License != "MIT" {
+		t.Errorf("unexpected show_model data %+v", resp.Data)
+	}
+	resp, err = handle("ps", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r, ok := resp.Data.([]RunningModel); !ok || len(r) != 1 || r[0].SizeVRAM != 4<<30 {
+		t.Errorf("unexpected ps data %+v", resp.Data)
+	}
+
+	if _, err := handle("show_model", ""); apperr.CodeOf(err) != apperr.CodeInvalidParams {
+		t.Errorf("expected invalid_params, got %v", err)
+	}
+	if _, err := handle("show_model", "missing"); apperr.CodeOf(err) != apperr.CodeModelNotFound {
+		t.Errorf("expected model_not_found, got %v", err)
+	}
+}
+
+// embedOllama 为每条输入返回一个向量并记录调用次数
+type embedOllama struct {
+	fakeOllama
+	calls int
+}
+
+func (e *embedOllama) Embed(ctx context.Context, req EmbedRequest) (Embeddings, error) {
+	e.calls++
+	vectors := make([][]float32, len(req.Input))
+	for i := range vectors {
+		vectors[i] = []float32{float32(i), 1}
+	}
+	return Embeddings{Vectors: vectors, Usage: Usage{PromptTokens: 3 * len(req.Input)}}, nil
+}
+
+func TestEmbeddingsAction(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	ollama := &embedOllama{}
+	h := NewHandlerFactory(ollama, logger).CreateHandler("embeddings")
+
+	req := &CloudRequest{Action: "embeddings", Params: CloudParams{ModelName: "nomic-embed-text", Input: []string{"a", "b", "c"}}}
+	resp, err := h.Handle(context.Background(), req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw, _ := json.Marshal(resp)
+	var got struct {
+		Data struct {
+			Embeddings [][]float32 `json:"embeddings"`
+		} `json:"data"`
+		Usage *Usage `json:"usage"`
+	}
+	if err := json.Unmarshal(raw, &got); err != nil {
+		t.Fatal(err)
+	}
+	// 多条输入只调用一次后端，向量顺序与输入一致
+	if ollama.calls != 1 || len(got.Data.Embeddings) != 3 || got.Data.Embeddings[2][0] != 2 {
+		t.Errorf("unexpected embeddings response %s (calls=%d)", raw, ollama.calls)
+	}
+	if got.Usage == nil || got.Usage.PromptTokens != 9 {
+		t.Errorf("expected usage with 9 prompt tokens, got %s", raw)
+	}
+
+	if _, err := h.Handle(context.Background(), &CloudRequest{Action: "embeddings", Params: CloudParams{ModelName: "nomic-embed-text"}}); apperr.CodeOf(err) != apperr.CodeInvalidParams {
+		t.Errorf("expected invalid_params for empty input, got %v", err)
+	}
+}
+
+func TestBreakerFailsFastAndReportsInHeartbeat(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	cfg := config.Default().Bridge
+	cfg.Breaker = config.BreakerConfig{Failures: 2, OpenTimeout: time.Minute, HalfOpenProbes: 1}
+	b := breaker.New("ollama", cfg.Breaker, isOllamaFailure)
+	ws := &fakeWSClient{}
+	ollama := &fakeOllama{err: errors.New("connection refused")}
+	s := NewServer(ws, NewHandlerFactory(withBreaker(ollama, b), logger), NewHealthChecker(ollama.Heartbeat, cfg.Health), cfg, logger)
+	s.SetBreaker(b)
+
+	list := func(id string) error {
+		return s.handleServerRequest(&Message{Request: &CloudRequest{Type: TypeServerToClient, Action: "list_model", RequestID: id}})
+	}
+	for _, id := range []string{"1", "2"} {
+		if err := list(id); apperr.CodeOf(err) != apperr.CodeBackendError {
+			t.Fatalf("expected backend_error before the breaker opens, got %v", err)
+		}
+	}
+	if err := list("3"); apperr.CodeOf(err) != apperr.CodeCircuitOpen {
+		t.Fatalf("expected circuit_open, got %v", err)
+	}
+
+	if err := s.sendHeartbeat(); err != nil {
+		t.Fatal(err)
+	}
+	var hb CloudRequest
+	if err := json.Unmarshal(ws.written[len(ws.written)-1], &hb); err != nil {
+		t.Fatal(err)
+	}
+	if hb.Params.Backend == nil || hb.Params.Backend.Breaker == nil || hb.Params.Backend.Breaker.State != breaker.Open {
+		t.Errorf("heartbeat should carry the open breaker, got %+v", hb.Params.Backend)
+	}
+}
+
+func TestModelNotFoundDoesNotTripBreaker(t *testing.T) {
+	if isOllamaFailure(api.StatusError{StatusCode: http.StatusNotFound}) {
+		t.Error("404 should not count as a backend failure")
+	}
+	if !isOllamaFailure(api.StatusError{StatusCode: http.StatusInternalServerError}) || !isOllamaFailure(errors.New("dial tcp: connection refused")) {
+		t.Error("5xx and connection errors should count as failures")
+	}
+	if isOllamaFailure(context.Canceled) {
+		t.Error("cancellation should not count as a failure")
+	}
+}
+
+func TestBackendErrorClassifiesOllamaStatus(t *testing.T) {
+	cases := []struct {
+		err       error
+		code      string
+		retryable bool
+	}{
+		{api.StatusError{StatusCode: http.StatusNotFound, ErrorMessage: "model 'x' not found"}, apperr.CodeModelNotFound, false},
+		{api.StatusError{StatusCode: http.StatusBadRequest, ErrorMessage: "invalid options"}, apperr.CodeInvalidParams, false},
+		{api.StatusError{StatusCode: http.StatusInternalServerError}, apperr.CodeBackendError, true},
+		{errors.New("dial tcp: connection refused"), apperr.CodeBackendError, true},
+	}
+	for _, c := range cases {
+		data := apperr.ToData(BackendError(c.err, "对话失败"))
+		if data.Code != c.code || data.Retryable != c.retryable {
+			t.Errorf("%v: got %s (retryable=%v), want %s (retryable=%v)", c.err, data.Code, data.Retryable, c.code, c.retryable)
+		}
+	}
+}
+
+// slowOllama 的 Chat 阻塞到 ctx 结束
+type slowOllama struct {
+	fakeOllama
+}
+
+func (s *slowOllama) Chat(ctx context.Context, req ChatRequest) (Reply, error) {
+	<-ctx.Done()
+	return Reply{}, ctx.Err()
+}
+
+func TestActionTimeout(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	cfg := config.Default().Bridge
+	cfg.Timeouts = config.TimeoutsConfig{Default: time.Minute, Actions: map[string]time.Duration{"chat": 20 * time.Millisecond}}
+	b := breaker.New("timeout-test", config.BreakerConfig{Failures: 1, OpenTimeout: time.Minute, HalfOpenProbes: 1}, isOllamaFailure)
+	ws := &fakeWSClient{}
+	s := NewServer(ws, NewHandlerFactory(withBreaker(&slowOllama{}, b), logger), nil, cfg, logger)
+
+	start := time.Now()
+	err := s.handleServerRequest(&Message{Request: &CloudRequest{Type: TypeServerToClient, Action: "chat", RequestID: "1", Params: CloudParams{ModelName: "llama3"}}})
+	if apperr.CodeOf(err) != apperr.CodeTimeout || apperr.CategoryOf(err) != apperr.Timeout {
+		t.Fatalf("expected timeout, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("chat timeout was not enforced, took %s", elapsed)
+	}
+	// 超过动作时限不说明 Ollama 不可用，不触发熔断
+	if b.Status().State != breaker.Closed {
+		t.Errorf("timeout should not trip the breaker, got %s", b.Status().State)
+	}
+}
@@ -0,0 +1,137 @@
+package bridge
+
+import (
+	"context"
+	"unicode/utf8"
+
+	"github.com/ollama/ollama/api"
+	"github.com/patrickmn/go-cache"
+
+	"ollama_dev/internal/apperr"
+	"ollama_dev/internal/config"
+)
+
+// SessionStore 按租户与 session_id 保存 chat 的对话历史，不同租户的同名会话互不可见，空闲超过 idle_ttl 的会话被丢弃；
+// 同一会话的请求依次处理，保证历史按回复顺序追加
+type SessionStore struct {
+	cfg      config.SessionsConfig
+	sessions *cache.Cache
+}
+
+// NewSessionStore max_turns 为 0 时返回 nil，表示不启用会话
+func NewSessionStore(cfg config.SessionsConfig) *SessionStore {
+	if cfg.MaxTurns <= 0 {
+		return nil
+	}
+	return &SessionStore{cfg: cfg, sessions: cache.New(cfg.IdleTTL, cfg.IdleTTL)}
+}
+
+// SetSessions 启用 chat 的 params.session_id，s 为 nil 时携带 session_id 的请求回复 invalid_params
+func (f *HandlerFactory) SetSessions(s *SessionStore) {
+	f.sessions = s
+}
+
+type session struct {
+	lock    chan struct{} // 容量为 1，持有期间其他请求等待
+	history []api.Message
+}
+
+// acquire 取得会话的处理权，等待期间请求被取消时返回 ctx 的错误
+func (s *SessionStore) acquire(ctx context.Context, id string) (*session, error) {
+	sess := &session{lock: make(chan struct{}, 1)}
+	for s.sessions.Add(id, sess, cache.DefaultExpiration) != nil {
+		// 已存在时使用已有会话；恰好过期被清理时重新创建
+		if v, ok := s.sessions.Get(id); ok {
+			sess = v.(*session)
+			break
+		}
+	}
+	select {
+	case sess.lock <- struct{}{}:
+		return sess, nil
+	case <-ctx.Done():
+		return nil, context.Cause(ctx)
+	}
+}
+
+// commit 追加本轮的消息与回复并按 max_turns、max_tokens 裁剪，同时刷新空闲计时
+func (s *SessionStore) commit(id string, sess *session, messages []api.Message, reply Reply) {
+	sess.history = trimHistory(append(messages, api.Message{Role: "assistant", Content: reply.Content, ToolCalls: reply.ToolCalls}), s.cfg)
+	s.sessions.SetDefault(id, sess)
+}
+
+// sessionKey 会话的存储键，租户 ID 与 session_id 以 NUL 分隔，避免拼接后与其他租户的会话重名
+func sessionKey(req *CloudRequest) string {
+	return req.TenantID + "\x00" + req.Params.SessionID
+}
+
+func (sess *session) release() {
+	<-sess.lock
+}
+
+// trimHistory 以 user 消息划分轮次，丢弃最早的轮次直到满足上限；开头的 system 消息始终保留，最近一轮不丢弃
+func trimHistory(history []api.Message, cfg config.SessionsConfig) []api.Message {
+	n := 0
+	for n < len(history) && history[n].Role == "system" {
+		n++
+	}
+	system, rest := history[:n], history[n:]
+
+	var starts []int // 每一轮在 rest 中的起始位置
+	for i, m := range rest {
+		if m.Role == "user" || i == 0 {
+			starts = append(starts, i)
+		}
+	}
+	tokens := estimateTokens(history)
+	drop := 0
+	for drop < len(starts)-1 && (len(starts)-drop > cfg.MaxTurns || (cfg.MaxTokens > 0 && tokens > cfg.MaxTokens)) {
+		tokens -= estimateTokens(rest[starts[drop]:starts[drop+1]])
+		drop++
+	}
+	if drop == 0 {
+		return history
+	}
+	return append(append([]api.Message(nil), system...), rest[starts[drop]:]...)
+}
+
+// estimateTokens 粗略估算 token 数：ASCII 字符按 4 个一个 token，其余字符 (如中文) 各算一个
+func estimateTokens(messages []api.Message) int {
+	total := 0
+	for _, m := range messages {
+		ascii, other := 0, 0
+		for _, r := range m.Content {
+			if r < utf8.RuneSelf {
+				ascii++
+			} else {
+				other++
+			}
+		}
+		total += ascii/4 + other + 1
+	}
+	return total
+}
+
+// withSession 携带 session_id 时在会话历史之后拼接本次的消息调用 chat，成功后保存本轮；未携带时直接调用
+func (s *SessionStore) withSession(ctx context.Context, req *CloudRequest, messages []api.Message, chat func([]api.Message) (Reply, error)) (Reply, error) {
+	if req.Params.SessionID == "" {
+		return chat(messages)
+	}
+	if s == nil {
+		return Reply{}, apperr.New(apperr.Validation, apperr.CodeInvalidParams, "未启用会话记忆 (bridge.sessions.max_turns 为 0)，不能使用 session_id")
+	}
+	id := sessionKey(req)
+	sess, err := s.acquire(ctx, id)
+	if err != nil {
+		return Reply{}, err
+	}
+	defer sess.release()
+
+	messages = append(append([]api.Message(nil), sess.history...), messages...)
+	reply, err := chat(messages)
+	if err != nil {
+		return reply, err
+	}
+	s.commit(id, sess, messages, reply)
+	return reply, nil
+}
@@ -0,0 +1,132 @@
+package bridge
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ollama/ollama/api"
+
+	"ollama_dev/internal/apperr"
+	"ollama_dev/internal/config"
+)
+
+// historyOllama 记录每次调用收到的消息，回复为收到的消息条数
+type historyOllama struct {
+	fakeOllama
+	seen [][]api.Message
+}
+
+func (h *historyOllama) Chat(ctx context.Context, req ChatRequest) (Reply, error) {
+	h.seen = append(h.seen, req.Messages)
+	return Reply{Content: fmt.Sprintf("seen %d", len(req.Messages))}, nil
+}
+
+func TestChatSessionKeepsHistory(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	ollama := &historyOllama{}
+	factory := NewHandlerFactory(ollama, logger)
+	factory.SetSessions(NewSessionStore(config.SessionsConfig{MaxTurns: 2, IdleTTL: time.Minute}))
+
+	chat := func(session, content string) error {
+		req := &CloudRequest{Action: "chat", Params: CloudParams{ModelName: "llama3", SessionID: session, Messages: []ChatMessage{{Role: "user", Content: content}}}}
+		_, err := factory.CreateHandler("chat").Handle(context.Background(), req)
+		return err
+	}
+	for _, q := range []string{"q1", "q2", "q3"} {
+		if err := chat("s1", q); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// 第三次调用带上前两轮 (各一问一答) 与本次的问题
+	if got := ollama.seen[2]; len(got) != 5 || got[0].Content != "q1" || got[1].Role != "assistant" || got[4].Content != "q3" {
+		t.Fatalf("unexpected messages %+v", got)
+	}
+	// max_turns 为 2，第一轮已被丢弃
+	if err := chat("s1", "q4"); err != nil {
+		t.Fatal(err)
+	}
+	if got := ollama.seen[3]; len(got) != 5 || got[0].Content != "q2" {
+		t.Errorf("expected oldest turn trimmed, got %+v", got)
+	}
+
+	// 不同会话、不带 session_id 的请求互不影响
+	if err := chat("s2", "other"); err != nil {
+		t.Fatal(err)
+	}
+	if err := chat("", "stateless"); err != nil {
+		t.Fatal(err)
+	}
+	if len(ollama.seen[4]) != 1 || len(ollama.seen[5]) != 1 {
+		t.Errorf("sessions leaked: %+v", ollama.seen[4:])
+	}
+
+	factory.SetSessions(nil)
+	if err := chat("s1", "q5"); apperr.CodeOf(err) != apperr.CodeInvalidParams {
+		t.Errorf("expected invalid_params when sessions are disabled, got %v", err)
+	}
+}
+
+func TestChatSessionIsolatedByTenant(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	ollama := &historyOllama{}
+	factory := NewHandlerFactory(ollama, logger)
+	factory.SetSessions(NewSessionStore(config.SessionsConfig{MaxTurns: 10, IdleTTL: time.Minute}))
+
+	for _, tenant := range []string{"acme", "globex", "acme"} {
+		req := &CloudRequest{Action: "chat", TenantID: tenant, Params: CloudParams{ModelName: "llama3", SessionID: "s1", Messages: []ChatMessage{{Role: "user", Content: tenant}}}}
+		if _, err := factory.CreateHandler("chat").Handle(context.Background(), req); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// globex 的 s1 看不到 acme 的历史，acme 的第二次请求只带上自己的一轮
+	if got := ollama.seen[1]; len(got) != 1 || got[0].Content != "globex" {
+		t.Errorf("expected globex to start a fresh session, got %+v", got)
+	}
+	if got := ollama.seen[2]; len(got) != 3 || got[0].Content != "acme" || got[2].Content != "acme" {
+		t.Errorf("expected acme to see only its own history, got %+v", got)
+	}
+}
+
+func TestTrimHistory(t *testing.T) {
+	long := strings.Repeat("很长的回答", 20) // 100 个汉字，约 100 token
+	history := []api.Message{
+		{Role: "system", Content: "you are helpful"},
+		{Role: "user", Content: "q1"}, {Role: "assistant", Content: long},
+		{Role: "user", Content: "q2"}, {Role: "assistant", Content: "a2"},
+		{Role: "user", Content: "q3"}, {Role: "assistant", Content: long},
+	}
+
+	// 按 token 上限丢弃早期轮次，system 消息保留
+	got := trimHistory(history, config.SessionsConfig{MaxTurns: 10, MaxTokens: 150})
+	if len(got) != 5 || got[0].Role != "system" || got[1].Content != "q2" {
+		t.Errorf("unexpected trimmed history %+v", got)
+	}
+	// 最近一轮本身超出上限时仍然保留
+	got = trimHistory(history, config.SessionsConfig{MaxTurns: 10, MaxTokens: 10})
+	if len(got) != 3 || got[1].Content != "q3" {
+		t.Errorf("latest turn should be kept, got %+v", got)
+	}
+	if got := trimHistory(history, config.SessionsConfig{MaxTurns: 3}); len(got) != len(history) {
+		t.Errorf("history within limits should be unchanged, got %d messages", len(got))
+	}
+}
+
+func TestSessionAcquireHonorsCancel(t *testing.T) {
+	s := NewSessionStore(config.SessionsConfig{MaxTurns: 1, IdleTTL: time.Minute})
+	sess, err := s.acquire(context.Background(), "s")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sess.release()
+	// 同一会话正在处理时，后到的请求等待，取消后返回
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := s.acquire(ctx, "s"); err != context.DeadlineExceeded {
+		t.Errorf("expected deadline exceeded, got %v", err)
+	}
+}
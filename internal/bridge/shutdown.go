@@ -0,0 +1,44 @@
+package bridge
+
+import (
+	"context"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"ollama_dev/internal/apperr"
+)
+
+// errShuttingDown bridge 正在关闭：新请求直接回复，超时仍未完成的请求以此取消，云端可改投其他节点
+var errShuttingDown = apperr.New(apperr.Backend, apperr.CodeBusy, "bridge 正在关闭，稍后重试")
+
+// shutdownGrace 取消剩余请求后等待其回复错误帧的时间
+const shutdownGrace = time.Second
+
+// Shutdown 停止接受新请求与任务队列的出队，在 timeout 内等待进行中的请求回复，超时后取消剩余请求；
+// 随后重发待发送队列并发送正常关闭帧，读取循环在对端回复关闭帧后退出。调用方负责最后关闭连接
+func (s *Server) Shutdown(timeout time.Duration) {
+	s.closing.Store(true)
+	s.stopQueue()
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	if !s.inflight.wait(ctx) {
+		s.logger.Error("等待进行中的请求超时，取消剩余请求", "count", s.inflight.len(), "timeout", timeout)
+		s.inflight.cancelAll(errShuttingDown)
+		grace, cancel := context.WithTimeout(context.Background(), shutdownGrace)
+		defer cancel()
+		s.inflight.wait(grace)
+	}
+	s.flushOutbox()
+
+	conn := s.wsClient.Conn()
+	if conn == nil {
+		return
+	}
+	frame := websocket.FormatCloseMessage(websocket.CloseNormalClosure, "bridge shutting down")
+	if err := conn.WriteControl(websocket.CloseMessage, frame, time.Now().Add(shutdownGrace)); err != nil {
+		s.logger.Error("发送关闭帧失败", "error", err)
+		return
+	}
+	s.logger.Info("已发送关闭帧，连接即将断开")
+}
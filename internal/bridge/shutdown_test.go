@@ -0,0 +1,105 @@
+package bridge
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+
+	"ollama_dev/internal/apperr"
+	"ollama_dev/internal/config"
+)
+
+// gatedOllama 的 Chat 等到 release 关闭或 ctx 结束
+type gatedOllama struct {
+	fakeOllama
+	started chan struct{}
+	release chan struct{}
+}
+
+func (g *gatedOllama) Chat(ctx context.Context, req ChatRequest) (Reply, error) {
+	g.started <- struct{}{}
+	select {
+	case <-g.release:
+		return Reply{Content: "done"}, nil
+	case <-ctx.Done():
+		return Reply{}, ctx.Err()
+	}
+}
+
+// responseStatus 解析响应帧的 status 与错误码
+func responseStatus(t *testing.T, frame []byte) (string, string) {
+	t.Helper()
+	var resp struct {
+		Status string `json:"status"`
+		Data   struct {
+			Code string `json:"code"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(frame, &resp); err != nil {
+		t.Fatal(err)
+	}
+	return resp.Status, resp.Data.Code
+}
+
+func TestShutdownDrainsInflightRequests(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	ollama := &gatedOllama{started: make(chan struct{}, 2), release: make(chan struct{})}
+	ws := &fakeWSClient{}
+	s := NewServer(ws, NewHandlerFactory(ollama, logger), nil, config.Default().Bridge, logger)
+	defer s.StartWorkers(config.WorkersConfig{Normal: 2, Queue: 4})()
+
+	chat := func(id string) error {
+		return s.handleServerRequest(&Message{Request: &CloudRequest{Type: TypeServerToClient, Action: "chat", RequestID: id, Params: CloudParams{ModelName: "llama3"}}})
+	}
+	if err := chat("1"); err != nil {
+		t.Fatal(err)
+	}
+	<-ollama.started
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		s.Shutdown(time.Second)
+	}()
+	// 关闭期间的新请求直接回复 busy，进行中的请求完成后才返回
+	for !s.closing.Load() {
+		time.Sleep(time.Millisecond)
+	}
+	if err := chat("2"); err != nil {
+		t.Fatal(err)
+	}
+	if status, code := responseStatus(t, ws.written[0]); status != StatusError || code != apperr.CodeBusy {
+		t.Errorf("expected busy for new request during shutdown, got %s %s", status, code)
+	}
+	close(ollama.release)
+	<-done
+	if len(ws.written) != 2 {
+		t.Fatalf("expected in-flight response before shutdown returned, got %d frames", len(ws.written))
+	}
+	if status, _ := responseStatus(t, ws.written[1]); status == StatusError {
+		t.Errorf("in-flight request should complete, got %s", ws.written[1])
+	}
+}
+
+func TestShutdownCancelsAfterTimeout(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	ollama := &gatedOllama{started: make(chan struct{}, 1), release: make(chan struct{})}
+	ws := &fakeWSClient{}
+	s := NewServer(ws, NewHandlerFactory(ollama, logger), nil, config.Default().Bridge, logger)
+	defer s.StartWorkers(config.WorkersConfig{Normal: 1, Queue: 1})()
+
+	if err := s.handleServerRequest(&Message{Request: &CloudRequest{Type: TypeServerToClient, Action: "chat", RequestID: "1", Params: CloudParams{ModelName: "llama3"}}}); err != nil {
+		t.Fatal(err)
+	}
+	<-ollama.started
+	s.Shutdown(20 * time.Millisecond)
+	// 超时后取消的请求同样回复 busy，云端可以改投其他节点
+	if len(ws.written) != 1 {
+		t.Fatalf("expected one response, got %d", len(ws.written))
+	}
+	if status, code := responseStatus(t, ws.written[0]); status != StatusError || code != apperr.CodeBusy {
+		t.Errorf("expected cancelled request answered with busy, got %s %s", status, code)
+	}
+}
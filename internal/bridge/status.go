@@ -0,0 +1,215 @@
+package bridge
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"ollama_dev/internal/config"
+)
+
+// 状态端口的路径
+const (
+	HealthzPath = "/healthz"
+	ReadyzPath  = "/readyz"
+	StatusPath  = "/status"
+)
+
+// Status 状态端口 /readyz 与 /status 的响应体
+type Status struct {
+	Status    string             `json:"status"` // ready、disconnected 或 backend_unavailable
+	Uptime    string             `json:"uptime"`
+	WebSocket ConnectionStatus   `json:"websocket"`           // 第一个上游的连接
+	Upstreams []ConnectionStatus `json:"upstreams,omitempty"` // 配置多个上游时各上游的连接
+	Ollama    BackendStatus      `json:"ollama"`
+	Models    *ModelCount        `json:"models,omitempty"`
+	Cache     CacheStats         `json:"cache"`
+}
+
+// ConnectionStatus 与云端的 WebSocket 连接状态
+type ConnectionStatus struct {
+	Name          string        `json:"name,omitempty"` // 上游名称，只连接 bridge.url 时为空
+	Connected     bool          `json:"connected"`
+	URL           string        `json:"url"`
+	ConnectedAt   time.Time     `json:"connected_at,omitzero"`   // 最近一次建立连接的时间
+	LastHeartbeat time.Time     `json:"last_heartbeat,omitzero"` // 最近一次成功发送心跳的时间
+	Latency       *LatencyStats `json:"latency,omitempty"`       // 心跳往返时延，尚无样本时为空
+	Reconnects    int64         `json:"reconnects"`
+}
+
+// ModelCount Ollama 的模型数，只在 /status 中查询，取自模型列表缓存；Ollama 不可达时不查询
+type ModelCount struct {
+	Available int    `json:"available"`
+	Loaded    int    `json:"loaded"` // 已加载到内存的模型数，可能滞后 cache.ttl
+	Error     string `json:"error,omitempty"`
+}
+
+// CacheStats 模型列表缓存的命中情况
+type CacheStats struct {
+	Hits     int64   `json:"hits"`
+	Misses   int64   `json:"misses"`
+	HitRatio float64 `json:"hit_ratio"`
+}
+
+// statusReporter 汇总连接、心跳、Ollama 与缓存的状态，由 bridge.status_addr 上的 HTTP 端口提供
+type statusReporter struct {
+	conns     []*upstreamStatus
+	startedAt time.Time
+	health    *HealthChecker
+	ollama    OllamaClient
+	timeout   time.Duration // 查询模型列表的超时
+}
+
+// upstreamStatus 一个上游的连接状态
+type upstreamStatus struct {
+	name, url   string
+	server      atomic.Pointer[Server] // 首次连接成功后设置
+	connected   atomic.Bool
+	connectedAt atomic.Int64 // UnixNano
+	reconnects  atomic.Int64
+}
+
+func newStatusReporter(targets []config.UpstreamConfig, health *HealthChecker, ollama OllamaClient, timeout time.Duration) *statusReporter {
+	r := &statusReporter{startedAt: time.Now(), health: health, ollama: ollama, timeout: timeout}
+	for _, t := range targets {
+		r.conns = append(r.conns, &upstreamStatus{name: t.Name, url: t.URL})
+	}
+	return r
+}
+
+// setConnected 记录连接建立或断开，断开后重新建立计为一次重连
+func (u *upstreamStatus) setConnected(connected bool) {
+	was := u.connected.Swap(connected)
+	if connected && !was {
+		if u.connectedAt.Swap(time.Now().UnixNano()) != 0 {
+			u.reconnects.Add(1)
+		}
+	}
+}
+
+func (u *upstreamStatus) status() ConnectionStatus {
+	st := ConnectionStatus{Name: u.name, Connected: u.connected.Load(), URL: u.url, Reconnects: u.reconnects.Load()}
+	if at := u.connectedAt.Load(); at != 0 {
+		st.ConnectedAt = time.Unix(0, at)
+	}
+	if s := u.server.Load(); s != nil {
+		st.LastHeartbeat = s.LastHeartbeat()
+		st.Latency = s.latency.stats()
+	}
+	return st
+}
+
+// Status 返回当前状态，ready 为 false 时 /readyz 返回 503；withModels 为 true 时查询模型数。
+// 配置多个上游时任一上游已连接即可处理请求，视为已连接
+func (r *statusReporter) Status(ctx context.Context, withModels bool) (Status, bool) {
+	st := Status{
+		Uptime: time.Since(r.startedAt).Round(time.Second).String(),
+		Ollama: r.health.Status(),
+		Cache:  cacheStats(),
+	}
+	connected := false
+	for _, u := range r.conns {
+		c := u.status()
+		connected = connected || c.Connected
+		if len(r.conns) > 1 {
+			st.Upstreams = append(st.Upstreams, c)
+		}
+		if s := u.server.Load(); s != nil && s.breaker != nil && st.Ollama.Breaker == nil {
+			b := s.breaker.Status()
+			st.Ollama.Breaker = &b
+		}
+	}
+	if len(r.conns) > 0 {
+		st.WebSocket = r.conns[0].status()
+	}
+	if withModels && st.Ollama.Healthy {
+		count := r.countModels(ctx)
+		st.Models = &count
+	}
+
+	switch {
+	case !connected:
+		st.Status = "disconnected"
+	case !st.Ollama.Healthy:
+		st.Status = "backend_unavailable"
+	default:
+		st.Status = "ready"
+	}
+	return st, st.Status == "ready"
+}
+
+// countModels 统计可用与已加载的模型数
+func (r *statusReporter) countModels(ctx context.Context) ModelCount {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+	list, err := r.ollama.ListModels(ctx)
+	if err != nil {
+		return ModelCount{Error: err.Error()}
+	}
+	count := ModelCount{Available: len(list)}
+	for _, m := range list {
+		if m.Loaded {
+			count.Loaded++
+		}
+	}
+	return count
+}
+
+// cacheStats 读取模型列表缓存的查询计数
+func cacheStats() CacheStats {
+	hit, miss := cacheRequests.Value("hit"), cacheRequests.Value("miss")
+	stats := CacheStats{Hits: int64(hit), Misses: int64(miss)}
+	if hit+miss > 0 {
+		stats.HitRatio = hit / (hit + miss)
+	}
+	return stats
+}
+
+// Handler 返回状态端口的处理器：/healthz 只表示进程存活；/readyz 不查询 Ollama，只读取探测结果，
+// 未就绪时返回 503；/status 另外查询模型数
+func (r *statusReporter) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET "+HealthzPath, func(w http.ResponseWriter, req *http.Request) {
+		writeStatusJSON(w, http.StatusOK, map[string]string{
+			"status": "ok",
+			"uptime": time.Since(r.startedAt).Round(time.Second).String(),
+		})
+	})
+	mux.HandleFunc("GET "+ReadyzPath, func(w http.ResponseWriter, req *http.Request) {
+		st, ready := r.Status(req.Context(), false)
+		code := http.StatusOK
+		if !ready {
+			code = http.StatusServiceUnavailable
+		}
+		writeStatusJSON(w, code, st)
+	})
+	mux.HandleFunc("GET "+StatusPath, func(w http.ResponseWriter, req *http.Request) {
+		st, _ := r.Status(req.Context(), true)
+		writeStatusJSON(w, http.StatusOK, st)
+	})
+	return mux
+}
+
+func writeStatusJSON(w http.ResponseWriter, code int, v any) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+// startStatusServer 在 addr 上提供状态端口，ctx 结束时关闭；addr 为空时不启动
+func startStatusServer(ctx context.Context, addr string, r *statusReporter, logger Logger) {
+	if addr == "" {
+		return
+	}
+	srv := &http.Server{Addr: addr, Handler: r.Handler(), ReadHeaderTimeout: 5 * time.Second}
+	go func() {
+		logger.Info("状态端口已启动", "addr", addr)
+		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			logger.Error("状态端口运行错误", "error", err)
+		}
+	}()
+	context.AfterFunc(ctx, func() { _ = srv.Close() })
+}
@@ -0,0 +1,72 @@
+package bridge
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"ollama_dev/internal/config"
+)
+
+// listOllama 返回固定的模型列表
+type listOllama struct {
+	fakeOllama
+	models []ModelInfo
+}
+
+func (l *listOllama) ListModels(ctx context.Context) ([]ModelInfo, error) { return l.models, l.err }
+
+func TestStatusReporterReadiness(t *testing.T) {
+	health := NewHealthChecker(nil, config.Default().Bridge.Health)
+	ollama := &listOllama{models: []ModelInfo{{Name: "llama3", Loaded: true}, {Name: "qwen2"}}}
+	r := newStatusReporter([]config.UpstreamConfig{{URL: "ws://cloud/ws"}}, health, ollama, config.Default().Bridge.Health.Timeout)
+	h := r.Handler()
+
+	get := func(path string) (int, Status) {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		var st Status
+		if path != HealthzPath {
+			if err := json.Unmarshal(rec.Body.Bytes(), &st); err != nil {
+				t.Fatalf("%s: decode: %v", path, err)
+			}
+		}
+		return rec.Code, st
+	}
+
+	// 存活检查与连接状态无关
+	if code, _ := get(HealthzPath); code != http.StatusOK {
+		t.Errorf("healthz = %d", code)
+	}
+	if code, st := get(ReadyzPath); code != http.StatusServiceUnavailable || st.Status != "disconnected" {
+		t.Errorf("readyz before connect = %d %q", code, st.Status)
+	}
+
+	r.conns[0].setConnected(true)
+	code, st := get(ReadyzPath)
+	if code != http.StatusOK || st.Status != "ready" || !st.WebSocket.Connected {
+		t.Errorf("readyz after connect = %d %+v", code, st)
+	}
+	if st.Models != nil {
+		t.Errorf("readyz should not query models, got %+v", st.Models)
+	}
+	_, st = get(StatusPath)
+	if st.Models == nil || st.Models.Available != 2 || st.Models.Loaded != 1 {
+		t.Errorf("status models = %+v", st.Models)
+	}
+
+	// 断开后重连计为一次重连
+	r.conns[0].setConnected(false)
+	r.conns[0].setConnected(true)
+	if _, st := get(StatusPath); st.WebSocket.Reconnects != 1 {
+		t.Errorf("reconnects = %d, want 1", st.WebSocket.Reconnects)
+	}
+
+	// Ollama 不可达时未就绪，不再查询模型
+	health.status = BackendStatus{Healthy: false, Error: "connection refused"}
+	if code, st := get(ReadyzPath); code != http.StatusServiceUnavailable || st.Status != "backend_unavailable" {
+		t.Errorf("readyz with backend down = %d %q", code, st.Status)
+	}
+}
@@ -0,0 +1,76 @@
+package bridge
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/ollama/ollama/api"
+)
+
+const weatherTool = `[{"type":"function","function":{"name":"get_weather","description":"查询天气",
+"parameters":{"type":"object","required":["city"],"properties":{"city":{"type":"string","description":"城市"}}}}}]`
+
+func TestChatToolCalls(t *testing.T) {
+	var got []api.ChatRequest
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req api.ChatRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		got = append(got, req)
+		msg := api.Message{Role: "assistant"}
+		if last := req.Messages[len(req.Messages)-1]; last.Role == "tool" {
+			msg.Content = "北京晴，" + last.Content
+		} else {
+			msg.ToolCalls = []api.ToolCall{{Function: api.ToolCallFunction{Name: "get_weather", Arguments: api.ToolCallFunctionArguments{"city": "北京"}}}}
+		}
+		json.NewEncoder(w).Encode(api.ChatResponse{Message: msg, Done: true})
+	}))
+	defer srv.Close()
+	c, err := NewOllamaClient(srv.URL, NewMemoryCache(0, 0), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := NewHandlerFactory(c, slog.New(slog.NewTextHandler(io.Discard, nil))).CreateHandler("chat")
+
+	var first CloudRequest
+	frame := `{"action":"chat","request_id":"t1","params":{"model_name":"llama3","tools":` + weatherTool +
+		`,"messages":[{"role":"user","content":"北京天气如何？"}]}}`
+	if err := json.Unmarshal([]byte(frame), &first); err != nil {
+		t.Fatal(err)
+	}
+	resp, err := h.Handle(context.Background(), &first)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got[0].Tools) != 1 || got[0].Tools[0].Function.Name != "get_weather" {
+		t.Fatalf("expected tools to reach Ollama, got %+v", got[0].Tools)
+	}
+	data, _ := json.Marshal(resp.Data)
+	var reply struct {
+		Message ChatMessage `json:"message"`
+	}
+	json.Unmarshal(data, &reply)
+	if len(reply.Message.ToolCalls) != 1 || reply.Message.ToolCalls[0].Function.Arguments["city"] != "北京" {
+		t.Fatalf("expected a tool call in the response, got %s", data)
+	}
+
+	// 下一轮带回模型的 tool_calls 与工具结果
+	next := &CloudRequest{Action: "chat", RequestID: "t2"}
+	next.Params.ModelName = "llama3"
+	next.Params.Messages = []ChatMessage{first.Params.Messages[0], reply.Message, {Role: "tool", Content: "25°C"}}
+	resp, err = h.Handle(context.Background(), next)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got[1].Messages[1].ToolCalls, reply.Message.ToolCalls) {
+		t.Errorf("expected the previous tool calls to be forwarded, got %+v", got[1].Messages[1])
+	}
+	if content := resp.Data.(map[string]any)["message"].(map[string]any)["content"]; content != "北京晴，25°C" {
+		t.Errorf("unexpected final reply %v", content)
+	}
+}
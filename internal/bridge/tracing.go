@@ -0,0 +1,83 @@
+package bridge
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+
+	"ollama_dev/internal/logging"
+	"ollama_dev/internal/tracing"
+)
+
+// span 属性，沿用 OpenTelemetry GenAI 语义约定的名称
+const (
+	attrOperation        = attribute.Key("gen_ai.operation.name")
+	attrModel            = attribute.Key("gen_ai.request.model")
+	attrInputTokens      = attribute.Key("gen_ai.usage.input_tokens")
+	attrOutputTokens     = attribute.Key("gen_ai.usage.output_tokens")
+	attrStream           = attribute.Key("ollama.stream")
+	attrEmbeddingsInputs = attribute.Key("ollama.embeddings.inputs")
+)
+
+// tracingClient 为 Chat、ChatStream、Generate 与 Embed 的每次调用创建 client span，
+// span 带有 ctx 中的 request_id 与模型名，结束时记录 token 用量
+type tracingClient struct {
+	OllamaClient
+}
+
+// WithTracing 返回为每次 Ollama 调用创建 span 的客户端，放在重试之内时每次尝试各有一个 span
+func WithTracing(c OllamaClient) OllamaClient {
+	return &tracingClient{OllamaClient: c}
+}
+
+// startOllamaSpan 开始名为 ollama.<operation> 的 span
+func startOllamaSpan(ctx context.Context, operation, model string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	attrs = append(attrs, attribute.String("gen_ai.system", "ollama"), attrOperation.String(operation), attrModel.String(model))
+	return tracing.Start(ctx, "ollama."+operation, trace.SpanKindClient, attrs...)
+}
+
+// endOllamaSpan 记录用量并结束 span
+func endOllamaSpan(span trace.Span, u Usage, err error) {
+	if err == nil {
+		span.SetAttributes(attrInputTokens.Int(u.PromptTokens), attrOutputTokens.Int(u.CompletionTokens))
+	}
+	tracing.End(span, err)
+}
+
+func (c *tracingClient) Chat(ctx context.Context, req ChatRequest) (Reply, error) {
+	ctx, span := startOllamaSpan(ctx, "chat", req.Model, attrStream.Bool(false))
+	reply, err := c.OllamaClient.Chat(ctx, req)
+	endOllamaSpan(span, reply.Usage, err)
+	return reply, err
+}
+
+func (c *tracingClient) ChatStream(ctx context.Context, req ChatRequest, onChunk func(string) error) (Reply, error) {
+	ctx, span := startOllamaSpan(ctx, "chat", req.Model, attrStream.Bool(true))
+	reply, err := c.OllamaClient.ChatStream(ctx, req, onChunk)
+	endOllamaSpan(span, reply.Usage, err)
+	return reply, err
+}
+
+func (c *tracingClient) Generate(ctx context.Context, req GenerateRequest, onChunk func(string) error) (Reply, error) {
+	ctx, span := startOllamaSpan(ctx, "generate", req.Model, attrStream.Bool(onChunk != nil))
+	reply, err := c.OllamaClient.Generate(ctx, req, onChunk)
+	endOllamaSpan(span, reply.Usage, err)
+	return reply, err
+}
+
+func (c *tracingClient) Embed(ctx context.Context, req EmbedRequest) (Embeddings, error) {
+	ctx, span := startOllamaSpan(ctx, "embeddings", req.Model, attrEmbeddingsInputs.Int(len(req.Input)))
+	e, err := c.OllamaClient.Embed(ctx, req)
+	endOllamaSpan(span, e.Usage, err)
+	return e, err
+}
+
+// traceRequest 为云端请求创建 server span，ctx 带上请求 ID，处理器中的日志与 Ollama 调用的 span 都能按 request_id 关联
+func traceRequest(ctx context.Context, prefix string, req *CloudRequest) (context.Context, trace.Span) {
+	ctx = logging.WithRequestID(ctx, req.RequestID)
+	return tracing.Start(ctx, prefix+"."+req.Action, trace.SpanKindServer,
+		attribute.String("bridge.action", req.Action),
+		attrStream.Bool(req.Params.Stream),
+	)
+}
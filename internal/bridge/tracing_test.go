@@ -0,0 +1,64 @@
+package bridge
+
+import (
+	"io"
+	"log/slog"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+
+	"ollama_dev/internal/config"
+	"ollama_dev/internal/tracing"
+)
+
+// spanAttr 返回 span 上 key 的取值，没有时返回空串
+func spanAttr(span sdktrace.ReadOnlySpan, key attribute.Key) string {
+	for _, attr := range span.Attributes() {
+		if attr.Key == key {
+			return attr.Value.Emit()
+		}
+	}
+	return ""
+}
+
+func TestTracingFollowsRequestToOllama(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	prev := otel.GetTracerProvider()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	t.Cleanup(func() { otel.SetTracerProvider(prev) })
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	ws := &fakeWSClient{}
+	factory := NewHandlerFactory(WithTracing(&fakeOllama{}), logger)
+	s := NewServer(ws, factory, nil, config.Default().Bridge, logger)
+
+	req := CloudRequest{Type: TypeServerToClient, Action: "chat", RequestID: "chat-1"}
+	req.Params.ModelName = "llama3"
+	if err := s.handleServerRequest(&Message{Request: &req}); err != nil {
+		t.Fatalf("handleServerRequest: %v", err)
+	}
+
+	spans := recorder.Ended()
+	if len(spans) != 2 {
+		t.Fatalf("expected 2 spans, got %d", len(spans))
+	}
+	// Ollama 调用先结束，是处理请求的 span 的子 span
+	call, handle := spans[0], spans[1]
+	if handle.Name() != "bridge.chat" || call.Name() != "ollama.chat" {
+		t.Fatalf("unexpected span names %q, %q", handle.Name(), call.Name())
+	}
+	if call.Parent().SpanID() != handle.SpanContext().SpanID() {
+		t.Errorf("ollama span is not a child of the request span")
+	}
+	for _, span := range spans {
+		if got := spanAttr(span, tracing.RequestIDKey); got != "chat-1" {
+			t.Errorf("%s: request_id = %q", span.Name(), got)
+		}
+	}
+	if got := spanAttr(call, attrModel); got != "llama3" {
+		t.Errorf("model attribute = %q", got)
+	}
+}
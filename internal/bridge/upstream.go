@@ -0,0 +1,228 @@
+package bridge
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"ollama_dev/internal/auth"
+	"ollama_dev/internal/breaker"
+	"ollama_dev/internal/capture"
+	"ollama_dev/internal/config"
+	"ollama_dev/internal/janitor"
+	"ollama_dev/internal/keystore"
+	"ollama_dev/internal/retry"
+	"ollama_dev/internal/systemd"
+	"ollama_dev/internal/util"
+	"ollama_dev/internal/util/wsutils"
+)
+
+// upstreamShared 各上游连接共用的组件：同一个 Ollama、处理器与健康探测，录制文件与密钥库也只打开一次
+type upstreamShared struct {
+	factory  *HandlerFactory
+	health   *HealthChecker
+	breaker  *breaker.Breaker
+	recorder *Recorder       // 可为 nil
+	keys     *keystore.Store // 可为 nil，表示未启用端到端加密
+	cipher   util.Cipher
+	ready    func() // 任一上游首次连接成功后调用
+}
+
+// upstream 一个上游的连接：独立的 WebSocket 连接、心跳、重连、待发送队列与任务队列
+type upstream struct {
+	target   config.UpstreamConfig
+	wsClient WSClient
+	chunking *chunkingClient
+	status   *upstreamStatus
+	logger   *slog.Logger
+}
+
+// newUpstream 创建连接 target 的客户端，抓包与分片包装在 ws 之外；ws 为 nil 时按配置创建，
+// 凭据取 target.auth，未配置时取全局的 auth，各上游只持有自己的凭据
+func newUpstream(cfg *config.Config, target config.UpstreamConfig, ws WSClient, capt *capture.Capture, status *upstreamStatus, logger *slog.Logger) (*upstream, error) {
+	if ws == nil {
+		provider, err := auth.FromConfig(target.ClientAuth(cfg.Auth))
+		if err != nil {
+			return nil, err
+		}
+		c := NewWebSocketClient(provider)
+		codec, _ := wsutils.CodecByName(cfg.Bridge.Codec)
+		c.SetTransport(codec, cfg.Compression)
+		ws = c
+	}
+	ws = &meteredClient{WSClient: ws}
+	if capt != nil {
+		conn := "bridge"
+		if target.Name != "" {
+			conn += "/" + target.Name
+		}
+		ws = &capturingClient{WSClient: ws, capture: capt, conn: conn}
+	}
+	if target.Name != "" {
+		logger = logger.With("upstream", target.Name)
+	}
+	// 分片在抓包之外进行，抓包记录的是实际收发的帧
+	chunking := newChunkingClient(ws, cfg.Chunking)
+	return &upstream{target: target, wsClient: chunking, chunking: chunking, status: status, logger: logger}, nil
+}
+
+// upstreamFile 配置多个上游时各上游的持久化文件互相独立，在扩展名前插入上游名称，例如 outbox.db 变为 outbox.global.db
+func upstreamFile(path, name string) string {
+	if path == "" || name == "" {
+		return path
+	}
+	ext := filepath.Ext(path)
+	return strings.TrimSuffix(path, ext) + "." + name + ext
+}
+
+// connect 按 bridge.reconnect 退避重试连接，ctx 结束时返回 ctx.Err()，重试次数或时长用尽时返回最后一次的错误
+func (u *upstream) connect(ctx context.Context, reconnect *retry.Policy) error {
+	b := reconnect.Start()
+	for {
+		err := u.wsClient.Connect(u.target.URL)
+		if err == nil {
+			return nil
+		}
+		d, ok := b.Next()
+		if !ok {
+			return fmt.Errorf("连接失败，已尝试 %d 次: %w", b.Attempts(), err)
+		}
+		u.logger.Error("连接失败，正在重试...", "error", err, "attempt", b.Attempts(), "delay", d.Round(time.Millisecond))
+		if !retry.Sleep(ctx, d) {
+			return ctx.Err()
+		}
+	}
+}
+
+// run 连接上游并处理请求，断开后重连；ctx 结束时等待进行中的请求回复、正常关闭连接后返回 nil
+func (u *upstream) run(ctx context.Context, cfg *config.Config, shared *upstreamShared) error {
+	reconnect := retry.New(cfg.Bridge.Reconnect)
+	name := u.target.Name
+	if err := u.connect(ctx, reconnect); err != nil {
+		if ctx.Err() != nil {
+			return nil
+		}
+		return err
+	}
+	connectedGauge.Set(1, name)
+	defer connectedGauge.Set(0, name)
+	defer u.wsClient.Close()
+	u.status.setConnected(true)
+
+	server := NewServer(u.wsClient, shared.factory, shared.health, cfg.Bridge, u.logger)
+	server.SetUpstream(name)
+	server.SetBreaker(shared.breaker)
+	u.status.server.Store(server)
+	defer server.StartWorkers(cfg.Bridge.Workers)()
+	// 恢复的加密排队任务执行前需要密钥
+	if shared.keys != nil {
+		server.SetKeystore(shared.keys, shared.cipher)
+	}
+	queueCfg := cfg.Bridge.JobQueue
+	queueCfg.File = upstreamFile(queueCfg.File, name)
+	stopQueue, err := server.StartJobQueue(queueCfg)
+	if err != nil {
+		return err
+	}
+	defer stopQueue()
+	if shared.recorder != nil {
+		server.SetRecorder(shared.recorder)
+	}
+	if path := upstreamFile(cfg.Bridge.OutboxFile, name); path != "" && cfg.Bridge.OutboxSize > 0 {
+		outbox, err := OpenBoltOutbox(path, cfg.Bridge.OutboxSize)
+		if err != nil {
+			return err
+		}
+		defer outbox.Close()
+		outbox.dropped = func(frame []byte) {
+			u.logger.Error("待发送队列已满，丢弃最早的响应", "size", cfg.Bridge.OutboxSize)
+		}
+		if n := outbox.Len(); n > 0 {
+			u.logger.Info("存在上次未送达的响应，连接后重发", "count", n, "path", path)
+		}
+		server.SetOutbox(outbox)
+	}
+
+	// 外部取消 (SIGINT/SIGTERM) 时等待进行中的请求回复、正常关闭连接，读取循环随之退出；
+	// 返回前等待关闭完成，之后才停止工作池、关闭待发送队列
+	ctx, cancel := context.WithCancel(ctx)
+	shutdownDone := make(chan struct{})
+	go func() {
+		defer close(shutdownDone)
+		<-ctx.Done()
+		server.Shutdown(cfg.Bridge.ShutdownTimeout)
+		_ = u.wsClient.Close()
+	}()
+	defer func() {
+		cancel()
+		<-shutdownDone
+	}()
+
+	// 定期清理过期的去重记录与未收齐的分片
+	j := janitor.New(cfg.Janitor.Interval)
+	server.RegisterSweepers(j)
+	j.Register("chunks", u.chunking.reassembler.Sweep)
+	go j.Run(ctx, u.logger)
+
+	shared.ready()
+
+	// 连接断开后重连，server 保留去重记录与待发送队列，重连后先重发未送达的响应
+	for {
+		err := server.Run()
+		if ctx.Err() != nil {
+			return nil
+		}
+		u.logger.Error("连接已断开，正在重连", "error", err)
+		connectedGauge.Set(0, name)
+		u.status.setConnected(false)
+		reconnectsTotal.Inc(name)
+		_ = u.wsClient.Close()
+		if !retry.Sleep(ctx, reconnect.Delay(1)) {
+			return nil
+		}
+		if err := u.connect(ctx, reconnect); err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+		connectedGauge.Set(1, name)
+		u.status.setConnected(true)
+		u.logger.Info("已重新连接", "url", u.target.URL)
+	}
+}
+
+// runUpstreams 并行运行各上游的连接，全部返回后返回；某个上游重连失败只结束该上游，其余上游继续运行
+func runUpstreams(ctx context.Context, cfg *config.Config, upstreams []*upstream, shared *upstreamShared) error {
+	// 连接建立后才通知 systemd 就绪
+	var once sync.Once
+	shared.ready = func() {
+		once.Do(func() {
+			if err := systemd.Notify(systemd.StateReady); err != nil {
+				upstreams[0].logger.Error("通知 systemd 就绪失败", "error", err)
+			}
+			systemd.StartWatchdog(ctx, nil)
+		})
+	}
+	defer systemd.Notify(systemd.StateStopping)
+
+	errs := make([]error, len(upstreams))
+	var wg sync.WaitGroup
+	for i, u := range upstreams {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if errs[i] = u.run(ctx, cfg, shared); errs[i] != nil && len(upstreams) > 1 {
+				u.logger.Error("上游连接已停止", "url", u.target.URL, "error", errs[i])
+				errs[i] = fmt.Errorf("上游 %s: %w", u.target.Name, errs[i])
+			}
+		}()
+	}
+	wg.Wait()
+	return errors.Join(errs...)
+}
@@ -0,0 +1,147 @@
+package bridge
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"ollama_dev/internal/config"
+)
+
+// controlPlane 模拟一个云端：连接建立后发出 version 请求，把收到的回复交给 replies
+func controlPlane(t *testing.T, replies chan<- CloudResponse) *httptest.Server {
+	upgrader := websocket.Upgrader{}
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		if err := conn.WriteJSON(CloudRequest{Type: TypeServerToClient, Action: "version", RequestID: "v1"}); err != nil {
+			return
+		}
+		for {
+			_, raw, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			var resp CloudResponse
+			if json.Unmarshal(raw, &resp) == nil && resp.RequestID == "v1" {
+				replies <- resp
+			}
+		}
+	}))
+}
+
+func TestUpstreamsConnectIndependently(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	regionalReplies, globalReplies := make(chan CloudResponse, 1), make(chan CloudResponse, 1)
+	regional, global := controlPlane(t, regionalReplies), controlPlane(t, globalReplies)
+	defer regional.Close()
+	defer global.Close()
+
+	cfg := config.Default()
+	cfg.Bridge.OutboxFile = ""
+	cfg.Bridge.Upstreams = []config.UpstreamConfig{
+		{Name: "regional", URL: "ws" + strings.TrimPrefix(regional.URL, "http")},
+		{Name: "global", URL: "ws" + strings.TrimPrefix(global.URL, "http")},
+	}
+	status := newStatusReporter(cfg.Bridge.Targets(), NewHealthChecker(nil, cfg.Bridge.Health), &fakeOllama{}, time.Second)
+	var upstreams []*upstream
+	for i, target := range cfg.Bridge.Targets() {
+		u, err := newUpstream(cfg, target, nil, nil, status.conns[i], logger)
+		if err != nil {
+			t.Fatal(err)
+		}
+		upstreams = append(upstreams, u)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- runUpstreams(ctx, cfg, upstreams, &upstreamShared{factory: NewHandlerFactory(&fakeOllama{}, logger)})
+	}()
+
+	// 每个上游各自收到回复，回复带该上游的名称
+	for name, replies := range map[string]chan CloudResponse{"regional": regionalReplies, "global": globalReplies} {
+		select {
+		case resp := <-replies:
+			if resp.Upstream != name || resp.Status != StatusDone {
+				t.Errorf("%s: unexpected reply %+v", name, resp)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatalf("%s: no reply", name)
+		}
+	}
+	st, ready := status.Status(context.Background(), false)
+	if !ready || len(st.Upstreams) != 2 || !st.Upstreams[1].Connected || st.Upstreams[1].Name != "global" {
+		t.Errorf("unexpected status %+v", st)
+	}
+
+	cancel()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("expected a clean shutdown, got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("upstreams did not stop")
+	}
+}
+
+func TestUpstreamsUseOwnCredentials(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	// 每个云端记录握手时收到的 Authorization
+	seen := map[string]chan string{"regional": make(chan string, 1), "global": make(chan string, 1), "fallback": make(chan string, 1)}
+	servers := map[string]string{}
+	for name, ch := range seen {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ch <- r.Header.Get("Authorization")
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+		}))
+		defer srv.Close()
+		servers[name] = "ws" + strings.TrimPrefix(srv.URL, "http")
+	}
+
+	cfg := config.Default()
+	cfg.Auth.Token = "global-token"
+	cfg.Bridge.Upstreams = []config.UpstreamConfig{
+		{Name: "regional", URL: servers["regional"], Auth: &config.UpstreamAuthConfig{Token: "regional-token"}},
+		{Name: "global", URL: servers["global"], Auth: &config.UpstreamAuthConfig{Token: "other-token"}},
+		{Name: "fallback", URL: servers["fallback"]},
+	}
+	want := map[string]string{"regional": "Bearer regional-token", "global": "Bearer other-token", "fallback": "Bearer global-token"}
+	status := newStatusReporter(cfg.Bridge.Targets(), NewHealthChecker(nil, cfg.Bridge.Health), &fakeOllama{}, time.Second)
+	for i, target := range cfg.Bridge.Targets() {
+		u, err := newUpstream(cfg, target, nil, nil, status.conns[i], logger)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := u.wsClient.Connect(target.URL); err == nil {
+			t.Fatalf("%s: expected the handshake to be rejected", target.Name)
+		}
+		if got := <-seen[target.Name]; got != want[target.Name] {
+			t.Errorf("%s: expected %q, got %q", target.Name, want[target.Name], got)
+		}
+	}
+}
+
+func TestUpstreamFile(t *testing.T) {
+	for _, c := range []struct{ path, name, want string }{
+		{"outbox.db", "", "outbox.db"},
+		{"outbox.db", "global", "outbox.global.db"},
+		{"/var/lib/ollama_dev/queue", "regional", "/var/lib/ollama_dev/queue.regional"},
+		{"", "global", ""},
+	} {
+		if got := upstreamFile(c.path, c.name); got != c.want {
+			t.Errorf("upstreamFile(%q, %q) = %q, want %q", c.path, c.name, got, c.want)
+		}
+	}
+}
@@ -0,0 +1,94 @@
+package bridge
+
+import (
+	"expvar"
+	"sync"
+
+	"ollama_dev/internal/apperr"
+	"ollama_dev/internal/config"
+)
+
+// workerStats 各通道处理与拒绝的请求数，发布在 /debug/vars 的 workers 字段
+var workerStats = expvar.NewMap("workers")
+
+// 工作池的通道
+const (
+	LaneFast   = "fast"
+	LaneNormal = "normal"
+)
+
+// workerPool 在固定数量的 goroutine 中处理请求，按动作分为快速与普通两条通道，
+// 轻量动作（列出模型、查询任务等）不会排在长时间的生成之后
+type workerPool struct {
+	fast, normal chan func()
+	fastActions  map[string]bool
+	wg           sync.WaitGroup
+}
+
+// newWorkerPool 按配置启动工作池，cfg.Normal 为 0 时返回 nil，请求在读取循环中逐个处理
+func newWorkerPool(cfg config.WorkersConfig) *workerPool {
+	if cfg.Normal <= 0 {
+		return nil
+	}
+	p := &workerPool{
+		fast:        make(chan func(), cfg.Queue),
+		normal:      make(chan func(), cfg.Queue),
+		fastActions: map[string]bool{},
+	}
+	for _, a := range cfg.FastActions {
+		p.fastActions[a] = true
+	}
+	// 未配置快速通道的并发数时，轻量动作同样走普通通道
+	if cfg.Fast <= 0 {
+		p.fast = p.normal
+	}
+	p.start(p.fast, cfg.Fast, LaneFast)
+	p.start(p.normal, cfg.Normal, LaneNormal)
+	return p
+}
+
+func (p *workerPool) start(queue chan func(), n int, lane string) {
+	for range n {
+		p.wg.Add(1)
+		go func() {
+			defer p.wg.Done()
+			for fn := range queue {
+				fn()
+				workerStats.Add(lane+"_handled", 1)
+			}
+		}()
+	}
+}
+
+// lane 返回动作所在的通道
+func (p *workerPool) lane(action string) string {
+	if p.fastActions[action] && p.fast != p.normal {
+		return LaneFast
+	}
+	return LaneNormal
+}
+
+// submit 将请求放入动作所在的通道，通道已满时返回 busy 错误，不阻塞读取循环
+func (p *workerPool) submit(action string, fn func()) error {
+	lane := p.lane(action)
+	queue := p.normal
+	if lane == LaneFast {
+		queue = p.fast
+	}
+	select {
+	case queue <- fn:
+		return nil
+	default:
+		workerStats.Add(lane+"_rejected", 1)
+		return apperr.New(apperr.Backend, apperr.CodeBusy, "请求队列已满，稍后重试")
+	}
+}
+
+// close 停止接收请求，等待已排队的请求处理完毕
+func (p *workerPool) close() {
+	close(p.normal)
+	if p.fast != p.normal {
+		close(p.fast)
+	}
+	p.wg.Wait()
+}
@@ -0,0 +1,179 @@
+package bridge
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"sync"
+	"testing"
+	"time"
+
+	"ollama_dev/internal/apperr"
+	"ollama_dev/internal/config"
+)
+
+// blockingOllama 的 Chat 阻塞到 release 关闭，模拟长时间的生成
+type blockingOllama struct {
+	fakeOllama
+	entered chan struct{}
+	release chan struct{}
+}
+
+func (b *blockingOllama) Chat(ctx context.Context, req ChatRequest) (Reply, error) {
+	b.entered <- struct{}{}
+	<-b.release
+	return Reply{Content: "done"}, nil
+}
+
+// syncWSClient 可并发写入的 fakeWSClient
+type syncWSClient struct {
+	mu sync.Mutex
+	fakeWSClient
+	wrote chan struct{}
+}
+
+func (s *syncWSClient) WriteMessage(message []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	err := s.fakeWSClient.WriteMessage(message)
+	s.wrote <- struct{}{}
+	return err
+}
+
+// frame 返回 request_id 对应的响应帧
+func (s *syncWSClient) frame(requestID string) (resp struct {
+	Status string      `json:"status"`
+	Data   apperr.Data `json:"data"`
+}, ok bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, raw := range s.written {
+		var f struct {
+			RequestID string `json:"request_id"`
+		}
+		if json.Unmarshal(raw, &f) == nil && f.RequestID == requestID {
+			_ = json.Unmarshal(raw, &resp)
+			return resp, true
+		}
+	}
+	return resp, false
+}
+
+func TestFastLaneIsNotBlockedByChat(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	ollama := &blockingOllama{entered: make(chan struct{}, 1), release: make(chan struct{})}
+	ws := &syncWSClient{wrote: make(chan struct{}, 10)}
+	s := NewServer(ws, NewHandlerFactory(ollama, logger), nil, config.Default().Bridge, logger)
+	stop := s.StartWorkers(config.WorkersConfig{Fast: 1, Normal: 1, Queue: 1, FastActions: []string{"list_model"}})
+
+	chat := func(id string) error {
+		return s.handleServerRequest(&Message{Request: &CloudRequest{Type: TypeServerToClient, Action: "chat", RequestID: id, Params: CloudParams{ModelName: "llama3"}}})
+	}
+	if err := chat("chat-1"); err != nil {
+		t.Fatal(err)
+	}
+	<-ollama.entered
+	// 普通通道的 worker 正在生成，第二个对话排队，第三个超出排队上限
+	if err := chat("chat-2"); err != nil {
+		t.Fatal(err)
+	}
+	if err := chat("chat-3"); apperr.CodeOf(err) != apperr.CodeBusy {
+		t.Fatalf("expected busy, got %v", err)
+	}
+
+	// 轻量动作走快速通道，不等待对话结束
+	if err := s.handleServerRequest(&Message{Request: &CloudRequest{Type: TypeServerToClient, Action: "list_model", RequestID: "list-1"}}); err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.After(2 * time.Second)
+	for {
+		if resp, ok := ws.frame("list-1"); ok {
+			if resp.Status != "done" {
+				t.Errorf("unexpected list_model response %+v", resp)
+			}
+			break
+		}
+		select {
+		case <-ws.wrote:
+		case <-deadline:
+			t.Fatal("list_model was blocked behind the running chat")
+		}
+	}
+	if resp, _ := ws.frame("chat-3"); resp.Data.Code != apperr.CodeBusy {
+		t.Errorf("expected busy error frame, got %+v", resp)
+	}
+
+	close(ollama.release)
+	stop()
+	for _, id := range []string{"chat-1", "chat-2"} {
+		if resp, ok := ws.frame(id); !ok || resp.Status != "done" {
+			t.Errorf("%s: expected done after release, got %+v", id, resp)
+		}
+	}
+}
+
+// ctxOllama 的 Chat 阻塞到 ctx 结束，返回包装后的 ctx 错误
+type ctxOllama struct {
+	fakeOllama
+	entered chan string
+}
+
+func (c *ctxOllama) Chat(ctx context.Context, req ChatRequest) (Reply, error) {
+	c.entered <- req.Model
+	<-ctx.Done()
+	return Reply{}, BackendError(ctx.Err(), "对话失败")
+}
+
+func TestCancelRunningAndQueuedRequests(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	ollama := &ctxOllama{entered: make(chan string, 2)}
+	ws := &syncWSClient{wrote: make(chan struct{}, 10)}
+	s := NewServer(ws, NewHandlerFactory(ollama, logger), nil, config.Default().Bridge, logger)
+	stop := s.StartWorkers(config.WorkersConfig{Normal: 1, Queue: 1})
+
+	send := func(action, id, model string) {
+		t.Helper()
+		req := &CloudRequest{Type: TypeServerToClient, Action: action, RequestID: id, Params: CloudParams{ModelName: model}}
+		if err := s.handleServerRequest(&Message{Request: req}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	send("chat", "chat-1", "running")
+	<-ollama.entered
+	send("chat", "chat-2", "queued")
+
+	// 先取消排队的请求，worker 空出后不再调用后端
+	send(ActionCancel, "chat-2", "")
+	send(ActionCancel, "chat-1", "")
+	deadline := time.After(2 * time.Second)
+	for {
+		r1, ok1 := ws.frame("chat-1")
+		r2, ok2 := ws.frame("chat-2")
+		if ok1 && ok2 {
+			for _, r := range []struct {
+				Status string      `json:"status"`
+				Data   apperr.Data `json:"data"`
+			}{r1, r2} {
+				if r.Status != StatusError || r.Data.Code != apperr.CodeCancelled {
+					t.Errorf("expected cancelled error frame, got %+v", r)
+				}
+			}
+			break
+		}
+		select {
+		case <-ws.wrote:
+		case <-deadline:
+			t.Fatal("cancelled requests were not answered")
+		}
+	}
+	stop()
+	select {
+	case model := <-ollama.entered:
+		t.Errorf("queued request %q reached the backend after cancel", model)
+	default:
+	}
+	if len(s.inflight.cancels) != 0 {
+		t.Errorf("in-flight requests not cleared: %v", s.inflight.cancels)
+	}
+}
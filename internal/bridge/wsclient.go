@@ -0,0 +1,97 @@
+package bridge
+
+import (
+	"context"
+	"sync"
+
+	"github.com/gorilla/websocket"
+
+	"ollama_dev/internal/auth"
+	"ollama_dev/internal/capture"
+	"ollama_dev/internal/config"
+	"ollama_dev/internal/util/wsutils"
+)
+
+// WebSocketClient 实现 WSClient
+type WebSocketClient struct {
+	conn        *websocket.Conn
+	codec       wsutils.Codec // 按连接协商的子协议确定，重连时更新
+	auth        auth.Provider
+	wantCodec   wsutils.Codec // 握手时请求的编码
+	compression config.CompressionConfig
+	mu          sync.Mutex   // 流式响应与主循环并发写入
+	connMu      sync.RWMutex // 重连时替换 conn
+}
+
+func (w *WebSocketClient) Conn() *websocket.Conn {
+	conn, _ := w.current()
+	return conn
+}
+
+func (w *WebSocketClient) current() (*websocket.Conn, wsutils.Codec) {
+	w.connMu.RLock()
+	defer w.connMu.RUnlock()
+	return w.conn, w.codec
+}
+
+// NewWebSocketClient 每次连接 (包括重连) 由 p 附加凭据，p 可为 nil
+func NewWebSocketClient(p auth.Provider) *WebSocketClient {
+	return &WebSocketClient{auth: p, codec: wsutils.JSON, wantCodec: wsutils.JSON}
+}
+
+// SetTransport 设置握手时请求的帧编码与压缩 (bridge.codec、compression)，下次连接时生效
+func (w *WebSocketClient) SetTransport(codec wsutils.Codec, compression config.CompressionConfig) {
+	w.wantCodec, w.compression = codec, compression
+}
+
+func (w *WebSocketClient) Connect(url string) error {
+	conn, _, err := auth.Dial(context.Background(), w.auth, url, nil, wsutils.DialCodec(w.wantCodec), wsutils.DialCompression(w.compression))
+	if err != nil {
+		return err
+	}
+	wsutils.SetCompression(conn, w.compression)
+	w.connMu.Lock()
+	w.conn, w.codec = conn, wsutils.CodecFor(conn.Subprotocol())
+	w.connMu.Unlock()
+	return nil
+}
+
+func (w *WebSocketClient) ReadMessage() ([]byte, error) {
+	conn, codec := w.current()
+	return wsutils.ReadFrame(conn, codec)
+}
+
+func (w *WebSocketClient) WriteMessage(message []byte) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	conn, codec := w.current()
+	return wsutils.WriteFrame(conn, codec, message, w.compression.Threshold)
+}
+
+func (w *WebSocketClient) Close() error {
+	conn := w.Conn()
+	if conn == nil {
+		return nil
+	}
+	return conn.Close()
+}
+
+// capturingClient 在收发时抓取帧
+type capturingClient struct {
+	WSClient
+	capture *capture.Capture
+	conn    string
+}
+
+func (c *capturingClient) ReadMessage() ([]byte, error) {
+	message, err := c.WSClient.ReadMessage()
+	if err == nil {
+		c.capture.Record(c.conn, capture.In, message)
+	}
+	return message, err
+}
+
+func (c *capturingClient) WriteMessage(message []byte) error {
+	c.capture.Record(c.conn, capture.Out, message)
+	return c.WSClient.WriteMessage(message)
+}
@@ -0,0 +1,169 @@
+package capture
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"ollama_dev/internal/config"
+	"ollama_dev/internal/logging"
+)
+
+// Direction 帧方向
+type Direction string
+
+const (
+	In  Direction = "in"  // 收到的帧
+	Out Direction = "out" // 发出的帧
+)
+
+// Frame 抓取的一帧，Data 为脱敏后的内容
+type Frame struct {
+	Time      time.Time `json:"time"`
+	Conn      string    `json:"conn"`
+	Direction Direction `json:"direction"`
+	Size      int       `json:"size"` // 原始字节数
+	Data      string    `json:"data,omitempty"`
+	Truncated bool      `json:"truncated,omitempty"`
+}
+
+// Capture 抓取 WebSocket 帧到环形缓冲区并可选写入文件，nil 表示未启用，所有方法均可安全调用
+type Capture struct {
+	cfg    config.CaptureConfig
+	redact map[string]struct{}
+
+	mu   sync.Mutex
+	ring []Frame // 环形缓冲，next 指向最旧的一条
+	next int
+	file *os.File
+	enc  *json.Encoder
+}
+
+// New 按配置创建抓取器，未启用时返回 nil；redact 为需要脱敏的字段名，不区分大小写
+func New(cfg config.CaptureConfig, redact []string) (*Capture, error) {
+	if !cfg.Enabled {
+		return nil, nil
+	}
+	c := &Capture{cfg: cfg, redact: make(map[string]struct{}, len(redact))}
+	for _, f := range redact {
+		c.redact[strings.ToLower(f)] = struct{}{}
+	}
+	if cfg.File != "" {
+		f, err := os.OpenFile(cfg.File, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o600)
+		if err != nil {
+			return nil, fmt.Errorf("打开抓包文件失败: %w", err)
+		}
+		c.file = f
+		c.enc = json.NewEncoder(f)
+	}
+	return c, nil
+}
+
+// Record 记录一帧
+func (c *Capture) Record(conn string, dir Direction, data []byte) {
+	if c == nil {
+		return
+	}
+	frame := Frame{Time: time.Now(), Conn: conn, Direction: dir, Size: len(data), Data: c.redactFrame(data)}
+	if c.cfg.MaxFrameSize > 0 && len(frame.Data) > c.cfg.MaxFrameSize {
+		frame.Data = frame.Data[:c.cfg.MaxFrameSize]
+		frame.Truncated = true
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.cfg.Buffer > 0 {
+		if len(c.ring) < c.cfg.Buffer {
+			c.ring = append(c.ring, frame)
+		} else {
+			c.ring[c.next] = frame
+			c.next = (c.next + 1) % len(c.ring)
+		}
+	}
+	if c.enc != nil {
+		_ = c.enc.Encode(frame)
+	}
+}
+
+// Frames 按时间顺序返回缓冲区中的帧
+func (c *Capture) Frames() []Frame {
+	if c == nil {
+		return nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	out := make([]Frame, 0, len(c.ring))
+	out = append(out, c.ring[c.next:]...)
+	return append(out, c.ring[:c.next]...)
+}
+
+// WriteJSONL 将缓冲区中的帧逐行写入 w
+func (c *Capture) WriteJSONL(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	for _, f := range c.Frames() {
+		if err := enc.Encode(f); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Handler 以 JSONL 附件形式下载缓冲区中的帧
+func (c *Capture) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if c == nil {
+			http.Error(w, "未启用抓包 (capture.enabled)", http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="capture-%s.jsonl"`, time.Now().UTC().Format("20060102T150405")))
+		_ = c.WriteJSONL(w)
+	})
+}
+
+// Close 关闭抓包文件
+func (c *Capture) Close() error {
+	if c == nil || c.file == nil {
+		return nil
+	}
+	return c.file.Close()
+}
+
+// redactFrame 将 JSON 帧中的敏感字段替换为 logging.RedactedValue，非 JSON 帧无法脱敏，只保留长度
+func (c *Capture) redactFrame(data []byte) string {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var v any
+	if err := dec.Decode(&v); err != nil {
+		return fmt.Sprintf("<非 JSON 帧，%d 字节，已省略>", len(data))
+	}
+	out, err := json.Marshal(c.redactValue(v))
+	if err != nil {
+		return fmt.Sprintf("<序列化失败: %v>", err)
+	}
+	return string(out)
+}
+
+func (c *Capture) redactValue(v any) any {
+	switch t := v.(type) {
+	case map[string]any:
+		for k, val := range t {
+			if _, ok := c.redact[strings.ToLower(k)]; ok {
+				t[k] = logging.RedactedValue
+				continue
+			}
+			t[k] = c.redactValue(val)
+		}
+	case []any:
+		for i, val := range t {
+			t[i] = c.redactValue(val)
+		}
+	}
+	return v
+}
@@ -0,0 +1,50 @@
+package capture
+
+import (
+	"strings"
+	"testing"
+
+	"ollama_dev/internal/config"
+	"ollama_dev/internal/logging"
+)
+
+func TestRecordRedactsAndKeepsRecent(t *testing.T) {
+	c, err := New(config.CaptureConfig{Enabled: true, Buffer: 2}, []string{"token", "content"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c.Record("a", In, []byte(`{"action":"chat","params":{"messages":[{"role":"user","content":"secret"}]}}`))
+	c.Record("a", Out, []byte(`{"Token":"abc","status":"done"}`))
+	c.Record("a", In, []byte(`not json secret`))
+
+	frames := c.Frames()
+	if len(frames) != 2 {
+		t.Fatalf("expected 2 frames, got %d", len(frames))
+	}
+	if frames[0].Direction != Out || !strings.Contains(frames[0].Data, logging.RedactedValue) || strings.Contains(frames[0].Data, "abc") {
+		t.Errorf("unexpected first frame: %+v", frames[0])
+	}
+	if strings.Contains(frames[1].Data, "secret") || frames[1].Size != len("not json secret") {
+		t.Errorf("non-JSON frame should be omitted: %+v", frames[1])
+	}
+}
+
+func TestNestedRedaction(t *testing.T) {
+	c, _ := New(config.CaptureConfig{Enabled: true, Buffer: 1}, []string{"content"})
+	c.Record("a", In, []byte(`{"params":{"messages":[{"role":"user","content":"secret"}]}}`))
+	if data := c.Frames()[0].Data; strings.Contains(data, "secret") || !strings.Contains(data, `"role":"user"`) {
+		t.Errorf("unexpected redaction: %s", data)
+	}
+}
+
+func TestDisabledIsNil(t *testing.T) {
+	c, err := New(config.CaptureConfig{}, nil)
+	if err != nil || c != nil {
+		t.Fatalf("expected nil capture, got %v %v", c, err)
+	}
+	c.Record("a", In, []byte("{}")) // nil 安全
+	if c.Frames() != nil {
+		t.Error("nil capture should have no frames")
+	}
+}
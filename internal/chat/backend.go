@@ -0,0 +1,305 @@
+package chat
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
+	"github.com/ollama/ollama/api"
+
+	"ollama_dev/internal/apperr"
+	"ollama_dev/internal/auth"
+	"ollama_dev/internal/bridge"
+	"ollama_dev/internal/config"
+	"ollama_dev/internal/keystore"
+	"ollama_dev/internal/util"
+	"ollama_dev/internal/util/wsutils"
+)
+
+// Backend 对话后端：本地 Ollama 或经由服务器转发
+type Backend interface {
+	ListModels(ctx context.Context) ([]string, error)
+	// Chat 发送完整对话历史，onToken 在每个增量片段到达时调用，返回完整回复
+	Chat(ctx context.Context, model string, messages []bridge.ChatMessage, onToken func(string)) (string, error)
+	Close() error
+}
+
+// LocalBackend 直接调用本地 Ollama
+type LocalBackend struct {
+	client *api.Client
+}
+
+// NewLocalBackend 使用 OLLAMA_HOST 等环境变量创建本地后端
+func NewLocalBackend() (*LocalBackend, error) {
+	client, err := api.ClientFromEnvironment()
+	if err != nil {
+		return nil, err
+	}
+	return &LocalBackend{client: client}, nil
+}
+
+func (b *LocalBackend) ListModels(ctx context.Context) ([]string, error) {
+	resp, err := b.client.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0, len(resp.Models))
+	for _, model := range resp.Models {
+		names = append(names, model.Name)
+	}
+	return names, nil
+}
+
+func (b *LocalBackend) Chat(ctx context.Context, model string, messages []bridge.ChatMessage, onToken func(string)) (string, error) {
+	req := &api.ChatRequest{
+		Model:    model,
+		Messages: make([]api.Message, 0, len(messages)),
+	}
+	for _, msg := range messages {
+		req.Messages = append(req.Messages, api.Message{Role: msg.Role, Content: msg.Content})
+	}
+
+	var content string
+	err := b.client.Chat(ctx, req, func(resp api.ChatResponse) error {
+		content += resp.Message.Content
+		onToken(resp.Message.Content)
+		return nil
+	})
+	return content, err
+}
+
+func (b *LocalBackend) Close() error {
+	return nil
+}
+
+// RemoteBackend 通过服务器的 WebSocket 协议转发请求
+type RemoteBackend struct {
+	conn              *websocket.Conn
+	maxFrameSize      int
+	compressThreshold int // 协商了压缩时，小于该字节数的帧不压缩
+	reassembler       *bridge.Reassembler
+	e2e               *E2E   // 可为 nil，表示不加密
+	user              string // 随请求上报，服务器按用户统计用量
+}
+
+// E2E 端到端加密参数：请求使用 Tenant 当前版本的密钥加密，响应使用同一租户的密钥解密
+type E2E struct {
+	Keys   *keystore.Store
+	Tenant string
+	Cipher util.Cipher
+}
+
+// SetE2E 启用端到端加密
+func (b *RemoteBackend) SetE2E(e *E2E) {
+	b.e2e = e
+}
+
+// SetUser 设置随请求上报的用户名
+func (b *RemoteBackend) SetUser(user string) {
+	b.user = user
+}
+
+// NewRemoteBackend 以 p 附加的凭据连接到服务器的 WebSocket 地址，超过 chunking.max_frame_size 的消息分片收发，
+// 按 compression 协商压缩
+func NewRemoteBackend(url string, p auth.Provider, chunking config.ChunkingConfig, compression config.CompressionConfig) (*RemoteBackend, error) {
+	conn, _, err := auth.Dial(context.Background(), p, url, nil, wsutils.DialCompression(compression))
+	if err != nil {
+		return nil, fmt.Errorf("连接服务器失败: %w", err)
+	}
+	wsutils.SetCompression(conn, compression)
+	return &RemoteBackend{conn: conn, maxFrameSize: chunking.MaxFrameSize, compressThreshold: compression.Threshold, reassembler: bridge.NewReassembler(chunking)}, nil
+}
+
+// writeJSON 序列化 v 并按帧大小限制分片发送
+func (b *RemoteBackend) writeJSON(v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	frames, err := bridge.SplitFrame(data, b.maxFrameSize)
+	if err != nil {
+		return err
+	}
+	for _, f := range frames {
+		if err := wsutils.WriteFrame(b.conn, wsutils.JSON, f, b.compressThreshold); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// readJSON 读取一条完整消息（必要时重组分片）并解码到 v
+func (b *RemoteBackend) readJSON(v any) error {
+	for {
+		_, frame, err := b.conn.ReadMessage()
+		if err != nil {
+			return err
+		}
+		msg, err := b.reassembler.Add(frame)
+		if err != nil {
+			return err
+		}
+		if msg != nil {
+			return json.Unmarshal(msg, v)
+		}
+	}
+}
+
+func (b *RemoteBackend) ListModels(ctx context.Context) ([]string, error) {
+	req := &bridge.CloudRequest{Type: bridge.TypeServerToClient, Action: "list_model"}
+
+	var names []string
+	err := b.roundTrip(ctx, req, func(resp *bridge.Envelope) error {
+		var models []bridge.ModelInfo
+		if err := json.Unmarshal(resp.Data, &models); err != nil {
+			return fmt.Errorf("解析模型列表失败: %w", err)
+		}
+		for _, model := range models {
+			names = append(names, model.Name)
+		}
+		return nil
+	})
+	return names, err
+}
+
+// streamWindow 流式对话授予桥接客户端的额度，消费过半后补充
+const streamWindow = 32
+
+func (b *RemoteBackend) Chat(ctx context.Context, model string, messages []bridge.ChatMessage, onToken func(string)) (string, error) {
+	req := &bridge.CloudRequest{Type: bridge.TypeServerToClient, Action: "chat", User: b.user}
+	req.Params.ModelName = model
+	req.Params.Messages = messages
+	req.Params.Stream = true
+	req.Params.Credits = streamWindow
+
+	var content string
+	consumed := 0
+	err := b.roundTrip(ctx, req, func(resp *bridge.Envelope) error {
+		var data struct {
+			Message bridge.ChatMessage `json:"message"`
+		}
+		if err := json.Unmarshal(resp.Data, &data); err != nil {
+			return fmt.Errorf("解析对话响应失败: %w", err)
+		}
+		if resp.Status != bridge.StatusStreaming {
+			// done 帧携带完整回复；不支持流式的旧版桥接客户端只发送这一帧
+			if content == "" {
+				content = data.Message.Content
+				onToken(content)
+			}
+			return nil
+		}
+
+		content += data.Message.Content
+		onToken(data.Message.Content)
+		if consumed++; consumed >= streamWindow/2 {
+			credit := &bridge.CloudRequest{V: bridge.ProtocolVersion, Type: bridge.TypeServerToClient, Action: bridge.ActionCredit, RequestID: req.RequestID}
+			credit.Params.Credits = consumed
+			if err := b.writeJSON(credit); err != nil {
+				return fmt.Errorf("发送流控额度失败: %w", err)
+			}
+			consumed = 0
+		}
+		return nil
+	})
+	return content, err
+}
+
+// roundTrip 发送请求并处理同一 request_id 的响应帧，直到收到 done 状态
+func (b *RemoteBackend) roundTrip(ctx context.Context, req *bridge.CloudRequest, onFrame func(*bridge.Envelope) error) error {
+	req.V = bridge.ProtocolVersion
+	req.RequestID = uuid.New().String()
+	if err := b.seal(req); err != nil {
+		return err
+	}
+	if err := b.writeJSON(req); err != nil {
+		return fmt.Errorf("发送请求失败: %w", err)
+	}
+
+	for {
+		if err := ctx.Err(); err != nil {
+			// 通知桥接客户端停止生成，尽力而为
+			cancel := &bridge.CloudRequest{V: bridge.ProtocolVersion, Type: bridge.TypeServerToClient, Action: bridge.ActionCancel, RequestID: req.RequestID}
+			_ = b.writeJSON(cancel)
+			return err
+		}
+
+		var resp bridge.Envelope
+		if err := b.readJSON(&resp); err != nil {
+			return fmt.Errorf("读取响应失败: %w", err)
+		}
+		// Hub 会把请求广播回发送方，只处理对应的客户端响应
+		if resp.RequestID != req.RequestID || resp.Type != bridge.TypeClientToServer {
+			continue
+		}
+		if err := b.open(&resp); err != nil {
+			return err
+		}
+		// 旧版桥接客户端的响应先升级为当前布局
+		if err := bridge.Migrate(&resp); err != nil {
+			return err
+		}
+		if resp.Status == "error" {
+			var data bridge.ErrorData
+			if err := json.Unmarshal(resp.Data, &data); err != nil {
+				return apperr.Wrap(err, apperr.Protocol, apperr.CodeBadFrame, "解析错误响应失败")
+			}
+			return apperr.FromData(data)
+		}
+
+		// 桥接客户端启用任务队列时，请求开始执行前先收到排队位置
+		if resp.Status == bridge.StatusQueued {
+			continue
+		}
+		if err := onFrame(&resp); err != nil {
+			return err
+		}
+		if resp.Status == "done" {
+			return nil
+		}
+	}
+}
+
+// seal 加密请求参数，params 移入 sealed
+func (b *RemoteBackend) seal(req *bridge.CloudRequest) error {
+	if b.e2e == nil {
+		return nil
+	}
+	params, err := json.Marshal(req.Params)
+	if err != nil {
+		return err
+	}
+	req.TenantID = b.e2e.Tenant
+	req.Sealed, err = b.e2e.Keys.Seal(b.e2e.Cipher, req.TenantID, keystore.AAD(req.TenantID, req.RequestID), params)
+	if err != nil {
+		return fmt.Errorf("加密请求失败: %w", err)
+	}
+	req.Params = bridge.CloudParams{}
+	return nil
+}
+
+// open 解密响应的 data；启用加密后不接受明文的成功响应，错误帧可能在解密请求之前产生，允许明文
+func (b *RemoteBackend) open(resp *bridge.Envelope) error {
+	if resp.Sealed == nil {
+		if b.e2e != nil && resp.Status != "error" {
+			return apperr.New(apperr.Auth, apperr.CodeEncryptionRequired, "已启用端到端加密，收到明文响应")
+		}
+		return nil
+	}
+	if b.e2e == nil {
+		return apperr.New(apperr.Auth, apperr.CodeDecryptFailed, "收到加密的响应，但未启用端到端加密")
+	}
+	data, err := b.e2e.Keys.Open(b.e2e.Tenant, keystore.AAD(b.e2e.Tenant, resp.RequestID), resp.Sealed)
+	if err != nil {
+		return apperr.Wrap(err, apperr.Auth, apperr.CodeDecryptFailed, "解密响应失败")
+	}
+	resp.Data = data
+	return nil
+}
+
+func (b *RemoteBackend) Close() error {
+	return b.conn.Close()
+}
@@ -0,0 +1,43 @@
+package chat
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"testing"
+
+	"ollama_dev/internal/apperr"
+	"ollama_dev/internal/bridge"
+	"ollama_dev/internal/keystore"
+	"ollama_dev/internal/util"
+)
+
+func TestRemoteBackendSealsRequests(t *testing.T) {
+	keys, _ := keystore.Open(filepath.Join(t.TempDir(), "keys.json"), []byte("test"))
+	_, _ = keys.Rotate("acme", 0)
+	b := &RemoteBackend{e2e: &E2E{Keys: keys, Tenant: "acme", Cipher: util.AESGCM}}
+
+	req := &bridge.CloudRequest{Type: bridge.TypeServerToClient, Action: "chat", RequestID: "r1"}
+	req.Params.ModelName = "llama3"
+	if err := b.seal(req); err != nil {
+		t.Fatal(err)
+	}
+	if req.Params.ModelName != "" || req.Sealed == nil || req.TenantID != "acme" {
+		t.Fatalf("expected params moved into sealed, got %+v", req)
+	}
+	params, err := keys.Open("acme", keystore.AAD("acme", "r1"), req.Sealed)
+	if err != nil || string(params) != `{"model_name":"llama3"}` {
+		t.Errorf("unexpected sealed params %s: %v", params, err)
+	}
+
+	// 响应解密后放回 data
+	sealed, _ := keys.Seal(util.AESGCM, "acme", keystore.AAD("acme", "r1"), []byte(`[]`))
+	resp := &bridge.Envelope{RequestID: "r1", Status: "done", Sealed: sealed}
+	if err := b.open(resp); err != nil || string(resp.Data) != "[]" {
+		t.Errorf("expected decrypted data, got %s %v", resp.Data, err)
+	}
+	// 明文的成功响应被拒绝
+	plain := &bridge.Envelope{RequestID: "r1", Status: "done", Data: json.RawMessage(`[]`)}
+	if err := b.open(plain); apperr.CodeOf(err) != apperr.CodeEncryptionRequired {
+		t.Errorf("expected encryption_required, got %v", err)
+	}
+}
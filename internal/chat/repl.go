@@ -0,0 +1,167 @@
+package chat
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"strings"
+
+	"ollama_dev/internal/bridge"
+)
+
+const helpText = `可用命令:
+  /models          列出可用模型
+  /model <name>    切换模型
+  /history         显示当前对话历史
+  /clear           清空对话历史
+  /export <file>   导出对话历史
+  /import <file>   导入导出的对话，替换当前历史后继续
+  /help            显示帮助
+  /exit            退出
+`
+
+// REPL 交互式对话会话
+type REPL struct {
+	backend Backend
+	model   string
+	history []bridge.ChatMessage
+	in      *bufio.Scanner
+	out     io.Writer
+}
+
+// NewREPL 创建交互式会话
+func NewREPL(backend Backend, model string, in io.Reader, out io.Writer) *REPL {
+	return &REPL{
+		backend: backend,
+		model:   model,
+		in:      bufio.NewScanner(in),
+		out:     out,
+	}
+}
+
+// Run 循环读取输入直到 /exit 或输入结束
+func (r *REPL) Run(ctx context.Context) error {
+	fmt.Fprintf(r.out, "当前模型: %s，输入 /help 查看命令\n", r.displayModel())
+
+	for {
+		fmt.Fprint(r.out, ">>> ")
+		if !r.in.Scan() {
+			fmt.Fprintln(r.out)
+			return r.in.Err()
+		}
+
+		line := strings.TrimSpace(r.in.Text())
+		if line == "" {
+			continue
+		}
+
+		if strings.HasPrefix(line, "/") {
+			quit, err := r.handleCommand(ctx, line)
+			if err != nil {
+				fmt.Fprintln(r.out, "错误:", err)
+			}
+			if quit {
+				return nil
+			}
+			continue
+		}
+
+		if err := r.send(ctx, line); err != nil {
+			fmt.Fprintln(r.out, "错误:", err)
+		}
+	}
+}
+
+// handleCommand 处理斜杠命令，返回是否退出
+func (r *REPL) handleCommand(ctx context.Context, line string) (bool, error) {
+	fields := strings.Fields(line)
+	switch fields[0] {
+	case "/exit", "/quit":
+		return true, nil
+	case "/help":
+		fmt.Fprint(r.out, helpText)
+	case "/models":
+		models, err := r.backend.ListModels(ctx)
+		if err != nil {
+			return false, err
+		}
+		for _, name := range models {
+			marker := "  "
+			if name == r.model {
+				marker = "* "
+			}
+			fmt.Fprintln(r.out, marker+name)
+		}
+	case "/model":
+		if len(fields) < 2 {
+			fmt.Fprintf(r.out, "当前模型: %s\n", r.displayModel())
+			return false, nil
+		}
+		r.model = fields[1]
+		fmt.Fprintf(r.out, "已切换到模型: %s\n", r.model)
+	case "/history":
+		for _, msg := range r.history {
+			fmt.Fprintf(r.out, "[%s] %s\n", msg.Role, msg.Content)
+		}
+	case "/clear":
+		r.history = nil
+		fmt.Fprintln(r.out, "对话历史已清空")
+	case "/export":
+		if len(fields) < 2 {
+			return false, fmt.Errorf("用法: /export <file>")
+		}
+		if err := NewTranscript(r.model, r.history).Save(fields[1]); err != nil {
+			return false, err
+		}
+		fmt.Fprintf(r.out, "已导出 %d 条消息到 %s\n", len(r.history), fields[1])
+	case "/import":
+		if len(fields) < 2 {
+			return false, fmt.Errorf("用法: /import <file>")
+		}
+		t, err := LoadTranscript(fields[1])
+		if err != nil {
+			return false, err
+		}
+		r.Import(t)
+	default:
+		return false, fmt.Errorf("未知命令: %s，输入 /help 查看命令", fields[0])
+	}
+	return false, nil
+}
+
+// send 发送用户消息并流式输出回复
+func (r *REPL) send(ctx context.Context, content string) error {
+	if r.model == "" {
+		return fmt.Errorf("未选择模型，请使用 /model <name>")
+	}
+
+	messages := append(r.history, bridge.ChatMessage{Role: "user", Content: content})
+	reply, err := r.backend.Chat(ctx, r.model, messages, func(token string) {
+		fmt.Fprint(r.out, token)
+	})
+	fmt.Fprintln(r.out)
+	if err != nil {
+		return err
+	}
+
+	// 只有成功的轮次才写入历史
+	r.history = append(messages, bridge.ChatMessage{Role: "assistant", Content: reply})
+	return nil
+}
+
+// Import 以导出的对话替换当前历史，记录中带有模型时一并切换
+func (r *REPL) Import(t *Transcript) {
+	r.history = t.Messages
+	if t.Model != "" {
+		r.model = t.Model
+	}
+	fmt.Fprintf(r.out, "已导入 %d 条消息，当前模型: %s\n", len(t.Messages), r.displayModel())
+}
+
+func (r *REPL) displayModel() string {
+	if r.model == "" {
+		return "(未选择)"
+	}
+	return r.model
+}
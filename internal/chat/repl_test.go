@@ -0,0 +1,85 @@
+package chat
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"ollama_dev/internal/bridge"
+)
+
+// fakeBackend 记录请求并按片段返回固定回复
+type fakeBackend struct {
+	models   []string
+	lastReq  []bridge.ChatMessage
+	lastUsed string
+}
+
+func (f *fakeBackend) ListModels(ctx context.Context) ([]string, error) {
+	return f.models, nil
+}
+
+func (f *fakeBackend) Chat(ctx context.Context, model string, messages []bridge.ChatMessage, onToken func(string)) (string, error) {
+	f.lastUsed = model
+	f.lastReq = append([]bridge.ChatMessage(nil), messages...)
+	for _, token := range []string{"你", "好"} {
+		onToken(token)
+	}
+	return "你好", nil
+}
+
+func (f *fakeBackend) Close() error { return nil }
+
+func TestREPLChatKeepsHistory(t *testing.T) {
+	backend := &fakeBackend{}
+	in := strings.NewReader("hello\nagain\n/exit\n")
+	var out bytes.Buffer
+
+	if err := NewREPL(backend, "llama3", in, &out).Run(context.Background()); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	// 第二轮应携带第一轮的用户消息与回复
+	if len(backend.lastReq) != 3 {
+		t.Fatalf("expected 3 messages in second turn, got %d", len(backend.lastReq))
+	}
+	if backend.lastReq[1].Role != "assistant" || backend.lastReq[1].Content != "你好" {
+		t.Errorf("unexpected assistant history: %+v", backend.lastReq[1])
+	}
+	if !strings.Contains(out.String(), "你好") {
+		t.Errorf("streamed tokens missing from output: %q", out.String())
+	}
+}
+
+func TestREPLCommands(t *testing.T) {
+	backend := &fakeBackend{models: []string{"llama3", "qwen2"}}
+	in := strings.NewReader("/models\n/model qwen2\nhi\n/clear\n/history\n/unknown\n")
+	var out bytes.Buffer
+
+	if err := NewREPL(backend, "llama3", in, &out).Run(context.Background()); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	if backend.lastUsed != "qwen2" {
+		t.Errorf("expected model switch to qwen2, got %s", backend.lastUsed)
+	}
+	output := out.String()
+	for _, want := range []string{"* llama3", "已切换到模型: qwen2", "对话历史已清空", "未知命令"} {
+		if !strings.Contains(output, want) {
+			t.Errorf("output missing %q:\n%s", want, output)
+		}
+	}
+}
+
+func TestREPLRequiresModel(t *testing.T) {
+	backend := &fakeBackend{}
+	var out bytes.Buffer
+
+	if err := NewREPL(backend, "", strings.NewReader("hi\n"), &out).Run(context.Background()); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if backend.lastReq != nil {
+		t.Error("expected no chat request without a model")
+	}
+}
@@ -0,0 +1,69 @@
+package chat
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"ollama_dev/internal/bridge"
+)
+
+// TranscriptVersion 导出文件的格式版本
+const TranscriptVersion = 1
+
+// Transcript 导出的对话记录。对话历史随每个请求完整发送，桥接客户端不保存会话，
+// 因此导入后可以连接任意服务器或桥接客户端继续对话
+type Transcript struct {
+	Version    int                  `json:"version"`
+	Model      string               `json:"model,omitempty"`
+	ExportedAt time.Time            `json:"exported_at,omitzero"`
+	Messages   []bridge.ChatMessage `json:"messages"`
+}
+
+// NewTranscript 以当前时间创建导出记录
+func NewTranscript(model string, messages []bridge.ChatMessage) *Transcript {
+	return &Transcript{Version: TranscriptVersion, Model: model, ExportedAt: time.Now().UTC(), Messages: messages}
+}
+
+// ParseTranscript 解析导出的对话记录，也接受只有消息数组的文件
+func ParseTranscript(data []byte) (*Transcript, error) {
+	var t Transcript
+	if err := json.Unmarshal(data, &t.Messages); err != nil {
+		if err := json.Unmarshal(data, &t); err != nil {
+			return nil, fmt.Errorf("解析对话记录失败: %w", err)
+		}
+	}
+	if t.Version > TranscriptVersion {
+		return nil, fmt.Errorf("不支持的对话记录版本: %d", t.Version)
+	}
+	for i, msg := range t.Messages {
+		switch msg.Role {
+		case "system", "user", "assistant":
+		default:
+			return nil, fmt.Errorf("第 %d 条消息的 role 无效: %q", i+1, msg.Role)
+		}
+	}
+	return &t, nil
+}
+
+// LoadTranscript 读取 path 中的对话记录
+func LoadTranscript(path string) (*Transcript, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("读取对话记录失败: %w", err)
+	}
+	return ParseTranscript(data)
+}
+
+// Save 将对话记录写入 path
+func (t *Transcript) Save(path string) error {
+	data, err := json.MarshalIndent(t, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, append(data, '\n'), 0o600); err != nil {
+		return fmt.Errorf("写入对话记录失败: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,44 @@
+package chat
+
+import (
+	"bytes"
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParseTranscript(t *testing.T) {
+	tr, err := ParseTranscript([]byte(`{"version":1,"model":"llama3","messages":[{"role":"user","content":"hi"},{"role":"assistant","content":"hello"}]}`))
+	if err != nil || tr.Model != "llama3" || len(tr.Messages) != 2 {
+		t.Fatalf("unexpected transcript %+v: %v", tr, err)
+	}
+	// 只有消息数组的文件
+	if tr, err := ParseTranscript([]byte(`[{"role":"system","content":"be brief"}]`)); err != nil || len(tr.Messages) != 1 {
+		t.Errorf("unexpected transcript %+v: %v", tr, err)
+	}
+	for _, bad := range []string{`{"version":2,"messages":[]}`, `[{"role":"tool","content":"x"}]`, `not json`} {
+		if _, err := ParseTranscript([]byte(bad)); err == nil {
+			t.Errorf("expected error for %s", bad)
+		}
+	}
+}
+
+func TestREPLExportImport(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "chat.json")
+	var out bytes.Buffer
+	in := strings.NewReader("hello\n/export " + path + "\n/exit\n")
+	if err := NewREPL(&fakeBackend{}, "llama3", in, &out).Run(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	// 在另一个会话中导入后继续对话，请求携带导入的历史与模型
+	backend := &fakeBackend{}
+	in = strings.NewReader("/import " + path + "\nagain\n/exit\n")
+	if err := NewREPL(backend, "", in, &out).Run(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if backend.lastUsed != "llama3" || len(backend.lastReq) != 3 || backend.lastReq[0].Content != "hello" {
+		t.Errorf("unexpected request after import: %s %+v", backend.lastUsed, backend.lastReq)
+	}
+}
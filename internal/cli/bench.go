@@ -0,0 +1,103 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/signal"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/spf13/cobra"
+
+	"ollama_dev/internal/auth"
+	"ollama_dev/internal/wsbench"
+)
+
+// newBenchCommand 对服务器的 /ws 进行压测
+func newBenchCommand(opts *options) *cobra.Command {
+	var (
+		server, mix, script string
+		asJSON              bool
+		maxErrorRate        float64
+		bench               wsbench.Options
+	)
+
+	cmd := &cobra.Command{
+		Use:   "bench",
+		Short: "建立多条 WebSocket 连接按目标速率发送请求，报告耗时分位数、错误率与吞吐",
+		Long: `建立 --conns 条到服务器 /ws 的连接，按 --mix 或 --script 的权重随机发送请求，经 hub 转发给同一租户下的桥接客户端。
+
+--rate 大于 0 时按固定速率开环发送，所有连接都在等待响应时到期的请求不再发送而计入“未发送”，用于观察服务器变慢时的积压；
+为 0 时每个请求结束后立即发送下一个。每条连接同时等待 --inflight 个响应。
+--script 为 JSON 数组，每项形如 {"action":"chat","weight":3,"params":{...}}，params 与请求帧的 params 相同。
+中断 (Ctrl-C) 时输出已有的结果；错误率超过 --max-error-rate 时以非零状态退出。`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if server == "" {
+				return fmt.Errorf("需要 --server")
+			}
+			var err error
+			if script != "" {
+				bench.Steps, err = wsbench.LoadScript(script)
+			} else {
+				bench.Steps, err = wsbench.ParseMix(mix)
+			}
+			if err != nil {
+				return err
+			}
+			if bench.Duration <= 0 && bench.Requests <= 0 {
+				return fmt.Errorf("需要 --duration 或 --requests")
+			}
+			provider, err := auth.FromConfig(opts.cfg.Auth)
+			if err != nil {
+				return err
+			}
+			bench.Chunking = opts.cfg.Chunking
+
+			ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt)
+			defer stop()
+			dial := func(ctx context.Context) (*websocket.Conn, error) {
+				ws, _, err := auth.Dial(ctx, provider, server, nil)
+				return ws, err
+			}
+			rep, err := wsbench.Run(ctx, dial, bench)
+			if err != nil {
+				return err
+			}
+
+			out := cmd.OutOrStdout()
+			if asJSON {
+				enc := json.NewEncoder(out)
+				enc.SetIndent("", "  ")
+				err = enc.Encode(rep)
+			} else {
+				err = wsbench.Print(out, rep)
+			}
+			if err != nil {
+				return err
+			}
+			if rate := rep.ErrorRate(); rate > maxErrorRate {
+				return fmt.Errorf("错误率 %.2f%% 超过 --max-error-rate (%.2f%%)", rate*100, maxErrorRate*100)
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&server, "server", "", "服务器 WebSocket 地址，例如 ws://localhost:8080/ws/")
+	cmd.Flags().IntVar(&bench.Conns, "conns", 10, "并发连接数")
+	cmd.Flags().IntVar(&bench.Inflight, "inflight", 1, "每条连接同时等待响应的请求数")
+	cmd.Flags().Float64Var(&bench.Rate, "rate", 0, "全部连接合计的目标速率 (请求/秒)，0 表示请求结束后立即发送下一个")
+	cmd.Flags().DurationVarP(&bench.Duration, "duration", "d", 30*time.Second, "发送请求的时长，之后等待进行中的请求结束")
+	cmd.Flags().Int64VarP(&bench.Requests, "requests", "n", 0, "请求总数上限，0 表示只按 --duration")
+	cmd.Flags().DurationVar(&bench.Timeout, "timeout", 2*time.Minute, "单个请求的时限，超时后发送 cancel")
+	cmd.Flags().StringVar(&mix, "mix", wsbench.DefaultMix, "请求组合，动作=权重，逗号分隔")
+	cmd.Flags().StringVar(&script, "script", "", "JSON 格式的请求脚本，指定后忽略 --mix")
+	cmd.Flags().StringVarP(&bench.Model, "model", "m", "", "chat 与 embeddings 使用的模型，默认使用 list_model 返回的第一个")
+	cmd.Flags().StringVar(&bench.Prompt, "prompt", "Reply with the single word: pong", "未在脚本中指定消息或输入时使用的提示词")
+	cmd.Flags().BoolVar(&bench.Stream, "stream", false, "chat 以流式返回，同时统计首个分片的耗时")
+	cmd.Flags().BoolVar(&bench.NoCache, "no-cache", true, "chat 不使用桥接客户端的结果缓存")
+	cmd.Flags().BoolVar(&asJSON, "json", false, "以 JSON 格式输出结果")
+	cmd.Flags().Float64Var(&maxErrorRate, "max-error-rate", 1, "允许的最大错误率 (0-1)")
+	return cmd
+}
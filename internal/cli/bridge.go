@@ -0,0 +1,52 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/charmbracelet/x/term"
+	"github.com/spf13/cobra"
+
+	"ollama_dev/internal/app"
+	"ollama_dev/internal/config"
+	"ollama_dev/internal/logging"
+)
+
+// newBridgeCommand 启动 Ollama 桥接客户端
+func newBridgeCommand(opts *options) *cobra.Command {
+	var url, record string
+
+	cmd := &cobra.Command{
+		Use:   "bridge",
+		Short: "连接云端 WebSocket 并代理本地 Ollama 请求",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			// --url 只连接指定的地址，忽略配置的 bridge.upstreams
+			if cmd.Flags().Changed("url") {
+				opts.cfg.Bridge.URL, opts.cfg.Bridge.Upstreams = url, nil
+			}
+			if cmd.Flags().Changed("record") {
+				opts.cfg.Bridge.RecordFile = record
+			}
+			logger := logging.Component(opts.logger, "bridge")
+
+			// 未配置地址时，只有在终端中运行才回退为交互式输入；systemd、容器等环境下直接报错
+			if opts.cfg.Bridge.URL == "" && len(opts.cfg.Bridge.Upstreams) == 0 {
+				if !term.IsTerminal(os.Stdin.Fd()) {
+					return errors.New("未配置 bridge.url，请使用 --url、配置文件或 " + config.EnvPrefix + "BRIDGE_URL 指定")
+				}
+				logger.Info("请输入 WebSocket 地址 (例如 ws://localhost:8080/ws/ )")
+				_, _ = fmt.Scanln(&opts.cfg.Bridge.URL)
+				if opts.cfg.Bridge.URL == "" {
+					return errors.New("未输入 WebSocket 地址")
+				}
+			}
+
+			return app.NewBridge(opts.cfg, app.WithLogger(logger)).Run(cmd.Context())
+		},
+	}
+
+	cmd.Flags().StringVar(&url, "url", "", "云端 WebSocket 地址")
+	cmd.Flags().StringVar(&record, "record", "", "将收到的帧录制到该 JSONL 文件，用于 replay 回放")
+	return cmd
+}
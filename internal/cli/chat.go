@@ -0,0 +1,93 @@
+package cli
+
+import (
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"ollama_dev/internal/auth"
+	"ollama_dev/internal/chat"
+	"ollama_dev/internal/config"
+	"ollama_dev/internal/keystore"
+	"ollama_dev/internal/util"
+)
+
+// newChatCommand 打开交互式对话终端
+func newChatCommand(opts *options) *cobra.Command {
+	var model, server, resume string
+
+	cmd := &cobra.Command{
+		Use:   "chat",
+		Short: "与模型进行交互式对话（本地 Ollama 或经由服务器）",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if cmd.Flags().Changed("model") {
+				opts.cfg.Chat.Model = model
+			}
+			if cmd.Flags().Changed("server") {
+				opts.cfg.Chat.Server = server
+			}
+
+			if opts.cfg.Chat.Model == "" {
+				opts.cfg.Chat.Model = opts.cfg.Models.Default
+			}
+
+			var backend chat.Backend
+			var err error
+			if opts.cfg.Chat.Server == "" {
+				backend, err = chat.NewLocalBackend()
+			} else {
+				backend, err = newRemoteBackend(opts.cfg)
+			}
+			if err != nil {
+				return err
+			}
+			defer backend.Close()
+
+			repl := chat.NewREPL(backend, opts.cfg.Chat.Model, os.Stdin, os.Stdout)
+			if resume != "" {
+				t, err := chat.LoadTranscript(resume)
+				if err != nil {
+					return err
+				}
+				// 命令行显式指定的模型优先于记录中的模型
+				if cmd.Flags().Changed("model") {
+					t.Model = ""
+				}
+				repl.Import(t)
+			}
+			return repl.Run(cmd.Context())
+		},
+	}
+
+	cmd.Flags().StringVarP(&model, "model", "m", "", "使用的模型名称")
+	cmd.Flags().StringVar(&server, "server", "", "服务器 WebSocket 地址，为空时直接使用本地 Ollama")
+	cmd.Flags().StringVar(&resume, "import", "", "导入 /export 导出的对话记录后继续对话")
+	return cmd
+}
+
+// newRemoteBackend 连接服务器，启用 features.e2e_encryption 时加密请求
+func newRemoteBackend(cfg *config.Config) (chat.Backend, error) {
+	provider, err := auth.FromConfig(cfg.Auth)
+	if err != nil {
+		return nil, err
+	}
+	backend, err := chat.NewRemoteBackend(cfg.Chat.Server, provider, cfg.Chunking, cfg.Compression)
+	if err != nil {
+		return nil, err
+	}
+	backend.SetUser(cfg.Chat.User)
+	if cfg.Features.E2EEncryption {
+		keys, err := keystore.Open(cfg.E2E.Keystore, []byte(cfg.E2E.Passphrase))
+		if err != nil {
+			backend.Close()
+			return nil, err
+		}
+		c, err := util.ParseCipher(cfg.E2E.Cipher)
+		if err != nil {
+			backend.Close()
+			return nil, err
+		}
+		backend.SetE2E(&chat.E2E{Keys: keys, Tenant: cfg.E2E.Tenant, Cipher: c})
+	}
+	return backend, nil
+}
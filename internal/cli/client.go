@@ -0,0 +1,71 @@
+package cli
+
+import (
+	"errors"
+	"os"
+
+	"github.com/charmbracelet/x/term"
+	"github.com/spf13/cobra"
+
+	"ollama_dev/internal/auth"
+	"ollama_dev/internal/chat"
+	"ollama_dev/internal/logging"
+	"ollama_dev/internal/tui"
+	"ollama_dev/internal/wstest"
+)
+
+// newClientCommand 启动终端对话客户端；标准输入不是终端或指定 --raw 时逐行发送标准输入
+func newClientCommand(opts *options) *cobra.Command {
+	var url, origin, model, room, resume string
+	var raw bool
+
+	cmd := &cobra.Command{
+		Use:   "client",
+		Short: "连接 WebSocket 服务器的终端对话客户端 (--raw 时发送标准输入中的消息)",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if cmd.Flags().Changed("url") {
+				opts.cfg.Client.URL = url
+			}
+			if cmd.Flags().Changed("origin") {
+				opts.cfg.Client.Origin = origin
+			}
+			if raw || !term.IsTerminal(os.Stdin.Fd()) {
+				return wstest.RunClient(opts.cfg.Client.URL, opts.cfg.Client.Origin, logging.Component(opts.logger, "client"))
+			}
+			if opts.cfg.Features.E2EEncryption {
+				return errors.New("终端界面不支持端到端加密，请使用 chat --server")
+			}
+
+			if !cmd.Flags().Changed("model") {
+				model = opts.cfg.Chat.Model
+				if model == "" {
+					model = opts.cfg.Models.Default
+				}
+			}
+			tuiOpts := tui.Options{Model: model, Room: room, User: opts.cfg.Chat.User}
+			if resume != "" {
+				t, err := chat.LoadTranscript(resume)
+				if err != nil {
+					return err
+				}
+				if cmd.Flags().Changed("model") {
+					t.Model = ""
+				}
+				tuiOpts.Transcript = t
+			}
+			provider, err := auth.FromConfig(opts.cfg.Auth)
+			if err != nil {
+				return err
+			}
+			return tui.Run(cmd.Context(), opts.cfg.Client.URL, provider, opts.cfg.Client.Origin, opts.cfg.Chunking, tuiOpts)
+		},
+	}
+
+	cmd.Flags().StringVar(&url, "url", "", "服务端 WebSocket 地址 (默认 ws://localhost:8080/ws)")
+	cmd.Flags().StringVar(&origin, "origin", "", "握手时携带的 Origin 请求头")
+	cmd.Flags().StringVarP(&model, "model", "m", "", "初始模型，默认为 chat.model 或 models.default")
+	cmd.Flags().StringVar(&room, "room", "", "启动后加入的房间")
+	cmd.Flags().StringVar(&resume, "import", "", "导入 /export 导出的对话记录后继续对话")
+	cmd.Flags().BoolVar(&raw, "raw", false, "不启动终端界面，逐行发送标准输入")
+	return cmd
+}
@@ -0,0 +1,70 @@
+package cli
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"ollama_dev/internal/config"
+)
+
+// newConfigCommand 配置文件脚手架
+func newConfigCommand(opts *options) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "config",
+		Short: "生成与校验配置文件",
+	}
+
+	cmd.AddCommand(newConfigInitCommand(), newConfigValidateCommand(opts))
+	return cmd
+}
+
+func newConfigInitCommand() *cobra.Command {
+	var force bool
+
+	cmd := &cobra.Command{
+		Use:   "init [path]",
+		Short: "写入带注释的默认配置文件 (默认 ollama_dev.yaml)",
+		Args:  cobra.MaximumNArgs(1),
+		// 生成文件无需加载现有配置
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error { return nil },
+		RunE: func(cmd *cobra.Command, args []string) error {
+			path := "ollama_dev.yaml"
+			if len(args) > 0 {
+				path = args[0]
+			}
+			if err := config.WriteDefault(path, force); err != nil {
+				return err
+			}
+			fmt.Fprintln(cmd.OutOrStdout(), "已生成配置文件:", path)
+			return nil
+		},
+	}
+
+	cmd.Flags().BoolVarP(&force, "force", "f", false, "覆盖已存在的文件")
+	return cmd
+}
+
+func newConfigValidateCommand(opts *options) *cobra.Command {
+	return &cobra.Command{
+		Use:   "validate [path]",
+		Short: "校验配置文件并报告错误 (默认使用 --config 指定的文件)",
+		Args:  cobra.MaximumNArgs(1),
+		// 校验命令自行解析文件，跳过根命令的配置加载
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error { return nil },
+		RunE: func(cmd *cobra.Command, args []string) error {
+			path := opts.configPath
+			if len(args) > 0 {
+				path = args[0]
+			}
+			if path == "" {
+				return fmt.Errorf("请指定配置文件路径，例如: ollama_dev config validate ollama_dev.yaml")
+			}
+			if err := config.ValidateFile(path); err != nil {
+				return err
+			}
+			fmt.Fprintln(cmd.OutOrStdout(), "配置文件有效:", path)
+			return nil
+		},
+	}
+}
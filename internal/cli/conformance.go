@@ -0,0 +1,95 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"ollama_dev/internal/auth"
+	"ollama_dev/internal/conformance"
+)
+
+// newConformanceCommand 扮演云端运行协议一致性用例，检验桥接客户端的实现
+func newConformanceCommand(opts *options) *cobra.Command {
+	var (
+		server, listen string
+		list           bool
+		suite          conformance.Options
+	)
+
+	cmd := &cobra.Command{
+		Use:   "conformance",
+		Short: "扮演云端向桥接客户端发送脚本化的请求，逐个用例报告协议是否一致",
+		Long: `扮演云端向桥接客户端发送脚本化的请求（握手、对话、流式、流控、错误与取消），逐个用例报告结果。
+
+--server 连接到服务器的 WebSocket 地址，请求经 hub 转发给已连接的桥接客户端（同一租户下应只有一个）；
+--listen 在指定地址等待桥接客户端直接连接（将其 bridge.url 指向该地址），此时同时检查连接后的 capabilities 帧。
+有用例失败时以非零状态退出。`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			out := cmd.OutOrStdout()
+			if list {
+				for _, c := range conformance.Cases() {
+					fmt.Fprintf(out, "%-22s %s\n", c.Name, c.Description)
+				}
+				return nil
+			}
+			if (server == "") == (listen == "") {
+				return fmt.Errorf("需要且只能指定 --server 或 --listen 之一")
+			}
+			if suite.CreditWait > 0 && suite.CreditWait >= suite.Timeout {
+				return fmt.Errorf("--credit-wait (%s) 必须小于 --timeout (%s)", suite.CreditWait, suite.Timeout)
+			}
+
+			conn, err := conformanceConn(cmd.Context(), opts, server, listen, out)
+			if err != nil {
+				return err
+			}
+			defer conn.Close()
+
+			failed := 0
+			results := conformance.Run(cmd.Context(), conn, suite, func(r conformance.Result) {
+				fmt.Fprintf(out, "%-4s  %-22s %8s  %s\n", strings.ToUpper(r.Status), r.Case, r.Elapsed.Round(time.Millisecond), r.Detail)
+				if r.Status == conformance.StatusFail {
+					failed++
+				}
+			})
+			fmt.Fprintf(out, "共 %d 个用例，失败 %d 个\n", len(results), failed)
+			if failed > 0 {
+				return fmt.Errorf("%d 个用例失败", failed)
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&server, "server", "", "服务器 WebSocket 地址，例如 ws://localhost:8080/ws/")
+	cmd.Flags().StringVar(&listen, "listen", "", "等待桥接客户端直接连接的监听地址，例如 :9090")
+	cmd.Flags().StringVarP(&suite.Model, "model", "m", "", "对话用例使用的模型，默认使用 list_model 返回的第一个")
+	cmd.Flags().DurationVar(&suite.Timeout, "timeout", 2*time.Minute, "每个用例的时限")
+	cmd.Flags().DurationVar(&suite.PauseWindow, "pause", 2*time.Second, "流式额度耗尽后确认桥接客户端暂停发送的观察时长")
+	cmd.Flags().DurationVar(&suite.CreditWait, "credit-wait", 0, "等待桥接客户端因额度耗尽终止流的时长，需大于其 bridge.credit_timeout；0 表示跳过 cancellation")
+	cmd.Flags().StringSliceVar(&suite.Run, "run", nil, "只运行指定的用例（逗号分隔）")
+	cmd.Flags().BoolVar(&list, "list", false, "列出全部用例后退出")
+	return cmd
+}
+
+// conformanceConn 连接服务器，或等待桥接客户端连接
+func conformanceConn(ctx context.Context, opts *options, server, listen string, out io.Writer) (*conformance.Conn, error) {
+	if server != "" {
+		provider, err := auth.FromConfig(opts.cfg.Auth)
+		if err != nil {
+			return nil, err
+		}
+		return conformance.Dial(server, provider, opts.cfg.Chunking)
+	}
+	ln, err := net.Listen("tcp", listen)
+	if err != nil {
+		return nil, fmt.Errorf("监听 %s 失败: %w", listen, err)
+	}
+	fmt.Fprintf(out, "等待桥接客户端连接 ws://%s/ ...\n", ln.Addr())
+	return conformance.Accept(ctx, ln, opts.cfg.Chunking)
+}
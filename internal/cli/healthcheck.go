@@ -0,0 +1,61 @@
+package cli
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// newHealthcheckCommand 请求本机 /healthz，供 Docker HEALTHCHECK 等无 curl 的环境使用
+func newHealthcheckCommand(opts *options) *cobra.Command {
+	var (
+		url     string
+		timeout time.Duration
+	)
+
+	cmd := &cobra.Command{
+		Use:   "healthcheck",
+		Short: "检查本机 serve 的 /healthz，不健康时以非零状态退出",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if url == "" {
+				url = healthzURL(opts.cfg.Server.Addr)
+			}
+
+			client := &http.Client{Timeout: timeout}
+			resp, err := client.Get(url)
+			if err != nil {
+				return fmt.Errorf("健康检查请求失败: %w", err)
+			}
+			defer resp.Body.Close()
+
+			if resp.StatusCode != http.StatusOK {
+				return fmt.Errorf("健康检查失败: %s", resp.Status)
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&url, "url", "", "健康检查地址，默认根据 server.addr 推导")
+	cmd.Flags().DurationVar(&timeout, "timeout", 3*time.Second, "请求超时")
+	return cmd
+}
+
+// healthzURL 根据监听地址推导本机 /healthz 地址
+func healthzURL(addr string) string {
+	return localURL(addr, "/healthz")
+}
+
+// localURL 根据监听地址推导本机访问地址，未指定或通配 host 时使用 127.0.0.1
+func localURL(addr, path string) string {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return "http://" + addr + path
+	}
+	if host == "" || host == "0.0.0.0" || host == "::" {
+		host = "127.0.0.1"
+	}
+	return "http://" + net.JoinHostPort(host, port) + path
+}
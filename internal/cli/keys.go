@@ -0,0 +1,70 @@
+package cli
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/spf13/cobra"
+
+	"ollama_dev/internal/keystore"
+)
+
+// newKeysCommand 管理端到端加密的租户密钥
+func newKeysCommand(opts *options) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "keys",
+		Short: "管理端到端加密的租户密钥 (e2e.keystore)",
+	}
+
+	cmd.AddCommand(newKeysRotateCommand(opts), newKeysListCommand(opts))
+	return cmd
+}
+
+func newKeysRotateCommand(opts *options) *cobra.Command {
+	var keep int
+
+	cmd := &cobra.Command{
+		Use:   "rotate <tenant>",
+		Short: "为租户生成新的密钥，旧密钥保留用于解密轮换前发出的帧",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			keys, err := keystore.Open(opts.cfg.E2E.Keystore, []byte(opts.cfg.E2E.Passphrase))
+			if err != nil {
+				return err
+			}
+			key, err := keys.Rotate(args[0], keep)
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(cmd.OutOrStdout(), "租户 %s 的当前 key ID: %d (%s)\n", args[0], key.ID, opts.cfg.E2E.Keystore)
+			return nil
+		},
+	}
+
+	cmd.Flags().IntVar(&keep, "keep", 2, "保留最新的几个密钥，0 表示全部保留")
+	return cmd
+}
+
+func newKeysListCommand(opts *options) *cobra.Command {
+	return &cobra.Command{
+		Use:   "list",
+		Short: "列出各租户保存的 key ID（不输出密钥内容）",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			keys, err := keystore.Open(opts.cfg.E2E.Keystore, []byte(opts.cfg.E2E.Passphrase))
+			if err != nil {
+				return err
+			}
+			versions := keys.Versions()
+			tenants := make([]string, 0, len(versions))
+			for name := range versions {
+				tenants = append(tenants, name)
+			}
+			sort.Strings(tenants)
+			for _, name := range tenants {
+				fmt.Fprintf(cmd.OutOrStdout(), "%s\t%v\n", name, versions[name])
+			}
+			return nil
+		},
+	}
+}
@@ -0,0 +1,58 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/gin-gonic/gin"
+	"github.com/spf13/cobra"
+
+	"ollama_dev/internal/openapi"
+	"ollama_dev/internal/router"
+)
+
+// newOpenAPICommand 输出 REST 接口的 OpenAPI 文档或生成 Go 客户端
+func newOpenAPICommand() *cobra.Command {
+	var out, clientOut, pkg string
+
+	cmd := &cobra.Command{
+		Use:   "openapi",
+		Short: "输出 REST 接口的 OpenAPI 3 文档，或用 --client 生成 Go 客户端",
+		Args:  cobra.NoArgs,
+		// 无需加载配置，文档包含全部可选接口
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error { return nil },
+		RunE: func(cmd *cobra.Command, args []string) error {
+			gin.SetMode(gin.ReleaseMode)
+			doc := router.Spec()
+			if clientOut != "" {
+				src, err := openapi.Generate(doc, pkg)
+				if err != nil {
+					return err
+				}
+				if err := os.WriteFile(clientOut, src, 0o644); err != nil {
+					return fmt.Errorf("写入客户端代码失败: %w", err)
+				}
+				return nil
+			}
+			data, err := json.MarshalIndent(doc, "", "  ")
+			if err != nil {
+				return err
+			}
+			data = append(data, '\n')
+			if out == "" {
+				_, err = cmd.OutOrStdout().Write(data)
+				return err
+			}
+			if err := os.WriteFile(out, data, 0o644); err != nil {
+				return fmt.Errorf("写入文档失败: %w", err)
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVarP(&out, "out", "o", "", "文档输出文件，默认输出到标准输出")
+	cmd.Flags().StringVar(&clientOut, "client", "", "生成 Go 客户端代码到该文件，而不输出文档")
+	cmd.Flags().StringVar(&pkg, "package", "client", "生成的客户端代码的包名")
+	return cmd
+}
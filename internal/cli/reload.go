@@ -0,0 +1,48 @@
+package cli
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"ollama_dev/internal/config"
+	"ollama_dev/internal/i18n"
+	"ollama_dev/internal/logging"
+)
+
+// watchReload 收到 SIGHUP 时重新加载配置，直到 ctx 结束
+func watchReload(ctx context.Context, store *config.Store, logger *slog.Logger) {
+	store.OnReload(func(old, cur *config.Config) {
+		if err := logging.SetLevel(cur.Log.Level); err != nil {
+			logger.Error("更新日志级别失败", "error", err)
+		}
+		if err := i18n.SetDefault(cur.Lang); err != nil {
+			logger.Error("更新默认语言失败", "error", err)
+		}
+		if old.Server.Addr != cur.Server.Addr || old.Server.DebugAddr != cur.Server.DebugAddr ||
+			old.Server.Pprof != cur.Server.Pprof || old.Admin != cur.Admin ||
+			old.Log.Format != cur.Log.Format {
+			logger.Warn("监听地址、诊断接口、管理员账号与日志格式需重启后生效")
+		}
+		logger.Info(i18n.T(i18n.LogConfigReloaded), "log_level", cur.Log.Level, "cors_origins", cur.Server.CorsOrigins)
+	})
+
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, syscall.SIGHUP)
+
+	go func() {
+		defer signal.Stop(sig)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-sig:
+				if err := store.Reload(); err != nil {
+					logger.Error(i18n.T(i18n.LogConfigReloadFailed), "error", err)
+				}
+			}
+		}
+	}()
+}
@@ -0,0 +1,20 @@
+package cli
+
+import (
+	"github.com/spf13/cobra"
+
+	"ollama_dev/internal/bridge"
+	"ollama_dev/internal/logging"
+)
+
+// newReplayCommand 回放 bridge --record 录制的请求
+func newReplayCommand(opts *options) *cobra.Command {
+	return &cobra.Command{
+		Use:   "replay <file>",
+		Short: "将 bridge --record 录制的请求送入本地处理流程，响应帧逐行输出到标准输出",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return bridge.Replay(cmd.Context(), logging.Component(opts.logger, "replay"), opts.cfg, args[0], cmd.OutOrStdout())
+		},
+	}
+}
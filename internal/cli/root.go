@@ -0,0 +1,125 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"ollama_dev/internal/config"
+	"ollama_dev/internal/i18n"
+	"ollama_dev/internal/logging"
+	"ollama_dev/internal/tracing"
+	"ollama_dev/internal/version"
+)
+
+// options 所有子命令共享的运行时状态
+type options struct {
+	configPath string
+	logLevel   string
+	logFormat  string
+	cfg        *config.Config
+	logger     *slog.Logger
+	closeLog   func() error
+	closeTrace func(context.Context) error
+}
+
+// traceFlushTimeout 退出时导出剩余 span 的最长等待时间
+const traceFlushTimeout = 5 * time.Second
+
+// NewRootCommand 创建根命令并注册所有子命令
+func NewRootCommand() *cobra.Command {
+	opts := &options{}
+
+	root := &cobra.Command{
+		Use:           "ollama_dev",
+		Short:         "Ollama 开发工具集：Gin 服务器、WebSocket 桥接与测试工具",
+		Version:       version.Get().String(),
+		SilenceUsage:  true,
+		SilenceErrors: true,
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := config.Load(opts.configPath)
+			if err != nil {
+				return err
+			}
+			if cmd.Flags().Changed("log-level") {
+				cfg.Log.Level = opts.logLevel
+			}
+			if cmd.Flags().Changed("log-format") {
+				cfg.Log.Format = opts.logFormat
+			}
+			if err := cfg.Validate(); err != nil {
+				return err
+			}
+			if err := i18n.SetDefault(cfg.Lang); err != nil {
+				return err
+			}
+
+			logger, closeLog, err := logging.New(cfg.Log)
+			if err != nil {
+				return err
+			}
+			opts.cfg = cfg
+			opts.logger = logger
+			opts.closeLog = closeLog
+			slog.SetDefault(logger)
+
+			closeTrace, err := tracing.Setup(cfg.Tracing, cmd.Name())
+			if err != nil {
+				return err
+			}
+			opts.closeTrace = closeTrace
+			return nil
+		},
+		PersistentPostRunE: func(cmd *cobra.Command, args []string) error {
+			if opts.closeTrace != nil {
+				// 导出尚未发送的 span，收集端不可用时不阻塞退出
+				ctx, cancel := context.WithTimeout(context.Background(), traceFlushTimeout)
+				if err := opts.closeTrace(ctx); err != nil {
+					opts.logger.Warn("导出 span 失败", "error", err)
+				}
+				cancel()
+			}
+			if opts.closeLog != nil {
+				return opts.closeLog()
+			}
+			return nil
+		},
+	}
+
+	root.PersistentFlags().StringVarP(&opts.configPath, "config", "c", "", "配置文件路径 (YAML)")
+	root.PersistentFlags().StringVar(&opts.logLevel, "log-level", "", "日志级别 (debug、info、warn、error)")
+	root.PersistentFlags().StringVar(&opts.logFormat, "log-format", "", "日志格式 (text、json)")
+
+	root.AddCommand(
+		newServeCommand(opts),
+		newBridgeCommand(opts),
+		newReplayCommand(opts),
+		newWSTestCommand(opts),
+		newClientCommand(opts),
+		newChatCommand(opts),
+		newConformanceCommand(opts),
+		newBenchCommand(opts),
+		newConfigCommand(opts),
+		newServiceCommand(opts),
+		newHealthcheckCommand(opts),
+		newStatsCommand(opts),
+		newKeysCommand(opts),
+		newOpenAPICommand(),
+		newVersionCommand(),
+	)
+	return root
+}
+
+// Execute 执行根命令
+func Execute() error {
+	root := NewRootCommand()
+	if err := root.Execute(); err != nil {
+		fmt.Fprintln(os.Stderr, i18n.T(i18n.CLIErrorPrefix), err)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,34 @@
+package cli
+
+import (
+	"github.com/spf13/cobra"
+
+	"ollama_dev/internal/app"
+	"ollama_dev/internal/config"
+	"ollama_dev/internal/logging"
+)
+
+// newServeCommand 启动 Gin 服务器
+func newServeCommand(opts *options) *cobra.Command {
+	var addr string
+
+	cmd := &cobra.Command{
+		Use:   "serve",
+		Short: "启动 Gin 服务器（含 /ws 插件）",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if cmd.Flags().Changed("addr") {
+				opts.cfg.Server.Addr = addr
+			}
+			logger := logging.Component(opts.logger, "server")
+
+			// SIGHUP 或 POST /admin/reload 重新加载配置，不影响已建立的 WebSocket 连接
+			store := config.NewStore(opts.configPath, opts.cfg)
+			watchReload(cmd.Context(), store, logger)
+
+			return app.NewServer(store, app.WithLogger(logger)).Run(cmd.Context())
+		},
+	}
+
+	cmd.Flags().StringVar(&addr, "addr", "", "监听地址 (默认 :8080)")
+	return cmd
+}
@@ -0,0 +1,72 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+
+	"github.com/spf13/cobra"
+
+	"ollama_dev/internal/app"
+	"ollama_dev/internal/logging"
+	"ollama_dev/internal/service"
+)
+
+// newServiceCommand 将 bridge 作为 Windows 服务安装、卸载与运行
+func newServiceCommand(opts *options) *cobra.Command {
+	var name string
+
+	cmd := &cobra.Command{
+		Use:   "service",
+		Short: "以 Windows 服务方式运行 bridge",
+	}
+	cmd.PersistentFlags().StringVar(&name, "name", service.DefaultName, "服务名称")
+
+	install := &cobra.Command{
+		Use:   "install",
+		Short: "安装服务（需要管理员权限），服务启动时使用当前 --config 指定的配置文件",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			svcArgs := []string{"service", "run", "--name", name}
+			if opts.configPath != "" {
+				// 服务以系统账户运行，工作目录不确定，必须使用绝对路径
+				path, err := filepath.Abs(opts.configPath)
+				if err != nil {
+					return err
+				}
+				svcArgs = append([]string{"--config", path}, svcArgs...)
+			}
+			if err := service.Install(name, svcArgs...); err != nil {
+				return err
+			}
+			fmt.Fprintln(cmd.OutOrStdout(), "服务已安装:", name)
+			return nil
+		},
+	}
+
+	uninstall := &cobra.Command{
+		Use:   "uninstall",
+		Short: "卸载服务（需要管理员权限）",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := service.Uninstall(name); err != nil {
+				return err
+			}
+			fmt.Fprintln(cmd.OutOrStdout(), "服务已卸载:", name)
+			return nil
+		},
+	}
+
+	run := &cobra.Command{
+		Use:    "run",
+		Short:  "由服务控制管理器调用，运行 bridge",
+		Hidden: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			logger := logging.Component(opts.logger, "bridge")
+			return service.Run(name, func(ctx context.Context) error {
+				return app.NewBridge(opts.cfg, app.WithLogger(logger)).Run(ctx)
+			})
+		},
+	}
+
+	cmd.AddCommand(install, uninstall, run)
+	return cmd
+}
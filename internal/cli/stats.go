@@ -0,0 +1,79 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"ollama_dev/internal/stats"
+)
+
+// newStatsCommand 通过管理接口查询运行中 serve 的连接与模型延迟统计
+func newStatsCommand(opts *options) *cobra.Command {
+	var (
+		url       string
+		fromDebug bool
+		raw       bool
+		timeout   time.Duration
+	)
+
+	cmd := &cobra.Command{
+		Use:   "stats",
+		Short: "查看运行中服务的连接数、队列深度与模型延迟",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if url == "" {
+				if fromDebug {
+					if opts.cfg.Server.DebugAddr == "" {
+						return fmt.Errorf("未配置 server.debug_addr，请通过 --url 指定统计地址")
+					}
+					url = localURL(opts.cfg.Server.DebugAddr, stats.DebugPath)
+				} else {
+					url = localURL(opts.cfg.Server.Addr, stats.AdminPath)
+				}
+			}
+
+			req, err := http.NewRequestWithContext(cmd.Context(), http.MethodGet, url, nil)
+			if err != nil {
+				return fmt.Errorf("构造统计请求失败: %w", err)
+			}
+			if opts.cfg.Admin.Password != "" {
+				req.SetBasicAuth(opts.cfg.Admin.Username, opts.cfg.Admin.Password)
+			}
+
+			client := &http.Client{Timeout: timeout}
+			resp, err := client.Do(req)
+			if err != nil {
+				return fmt.Errorf("统计请求失败: %w", err)
+			}
+			defer resp.Body.Close()
+
+			body, err := io.ReadAll(resp.Body)
+			if err != nil {
+				return fmt.Errorf("读取统计响应失败: %w", err)
+			}
+			if resp.StatusCode != http.StatusOK {
+				return fmt.Errorf("统计请求失败: %s", resp.Status)
+			}
+
+			if raw {
+				_, err := cmd.OutOrStdout().Write(body)
+				return err
+			}
+			var snap stats.Snapshot
+			if err := json.Unmarshal(body, &snap); err != nil {
+				return fmt.Errorf("解析统计响应失败: %w", err)
+			}
+			return stats.Print(cmd.OutOrStdout(), snap)
+		},
+	}
+
+	cmd.Flags().StringVar(&url, "url", "", "统计接口地址，默认根据 server.addr 推导")
+	cmd.Flags().BoolVar(&fromDebug, "debug", false, "从诊断端口 (server.debug_addr) 读取，适用于未开启管理接口的部署")
+	cmd.Flags().BoolVar(&raw, "json", false, "直接输出原始 JSON")
+	cmd.Flags().DurationVar(&timeout, "timeout", 5*time.Second, "请求超时")
+	return cmd
+}
@@ -0,0 +1,35 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"ollama_dev/internal/version"
+)
+
+// newVersionCommand 输出版本与构建信息
+func newVersionCommand() *cobra.Command {
+	var asJSON bool
+
+	cmd := &cobra.Command{
+		Use:   "version",
+		Short: "显示版本与构建信息",
+		// 无需加载配置
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error { return nil },
+		RunE: func(cmd *cobra.Command, args []string) error {
+			info := version.Get()
+			if asJSON {
+				enc := json.NewEncoder(cmd.OutOrStdout())
+				enc.SetIndent("", "  ")
+				return enc.Encode(info)
+			}
+			fmt.Fprintln(cmd.OutOrStdout(), "ollama_dev", info.String())
+			return nil
+		},
+	}
+
+	cmd.Flags().BoolVar(&asJSON, "json", false, "以 JSON 格式输出")
+	return cmd
+}
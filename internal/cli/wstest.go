@@ -0,0 +1,27 @@
+package cli
+
+import (
+	"github.com/spf13/cobra"
+
+	"ollama_dev/internal/logging"
+	"ollama_dev/internal/wstest"
+)
+
+// newWSTestCommand 启动分组测试服务器
+func newWSTestCommand(opts *options) *cobra.Command {
+	var addr string
+
+	cmd := &cobra.Command{
+		Use:   "wstest",
+		Short: "启动支持分组的 WebSocket 测试服务器",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if cmd.Flags().Changed("addr") {
+				opts.cfg.WSTest.Addr = addr
+			}
+			return wstest.ListenAndServe(opts.cfg.WSTest, logging.Component(opts.logger, "wstest"))
+		},
+	}
+
+	cmd.Flags().StringVar(&addr, "addr", "", "监听地址 (默认 :8080)")
+	return cmd
+}
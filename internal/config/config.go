@@ -0,0 +1,817 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Config 所有子命令共享的配置
+type Config struct {
+	Server ServerConfig `yaml:"server"` // serve: Gin 服务器
+	Bridge BridgeConfig `yaml:"bridge"` // bridge: Ollama 桥接客户端
+	WSTest WSTestConfig `yaml:"wstest"` // wstest: 分组测试服务器
+	Client ClientConfig `yaml:"client"` // client: 测试客户端
+	Chat   ChatConfig   `yaml:"chat"`   // chat: 交互式对话
+	Auth   AuthConfig   `yaml:"auth"`   // 鉴权
+	Cache  CacheConfig  `yaml:"cache"`  // 缓存
+	Models ModelsConfig `yaml:"models"` // 模型
+	Ollama OllamaConfig `yaml:"ollama"` // 本地 Ollama
+
+	Features    FeaturesConfig    `yaml:"features"`    // 功能开关
+	Capture     CaptureConfig     `yaml:"capture"`     // WebSocket 抓包
+	Chunking    ChunkingConfig    `yaml:"chunking"`    // 大消息分片
+	Compression CompressionConfig `yaml:"compression"` // WebSocket 消息压缩
+	Janitor     JanitorConfig     `yaml:"janitor"`     // 过期数据清理
+	E2E         E2EConfig         `yaml:"e2e"`         // 端到端加密
+	Alert       AlertConfig       `yaml:"alert"`       // serve 告警
+	Schedule    ScheduleConfig    `yaml:"schedule"`    // bridge 定时任务
+	Admin       AdminConfig       `yaml:"admin"`       // 管理员账号
+	Log         LogConfig         `yaml:"log"`         // 日志
+	Tracing     TracingConfig     `yaml:"tracing"`     // OpenTelemetry 链路追踪
+	Lang        string            `yaml:"lang"`        // 错误与日志消息的默认语言，zh 或 en
+}
+
+// ServerConfig Gin 服务器配置
+type ServerConfig struct {
+	Addr        string   `yaml:"addr"`         // 监听地址
+	CorsOrigins []string `yaml:"cors_origins"` // 允许跨域的 Origin，"*" 表示全部，可用 * 通配一段主机名或端口，支持热加载
+	Pprof       bool     `yaml:"pprof"`        // 是否在监听地址上挂载 /debug/pprof/，需配置管理员账号
+	DebugAddr   string   `yaml:"debug_addr"`   // 内部诊断端口 (pprof、/debug/vars)，为空时不启用
+	Metrics     bool     `yaml:"metrics"`      // 是否在监听地址上提供 Prometheus 的 /metrics
+	UsageFile   string   `yaml:"usage_file"`   // 按天聚合的 token 用量文件，供 /api/usage 与 /api/usage/export 查询；为空时不统计
+
+	// 可信的反向代理 (IP 或 CIDR)，只有来自这些地址的请求才按 X-Forwarded-For 与 X-Real-IP 确定客户端 IP，
+	// 为空时一律使用连接的对端地址；限流按 IP 区分客户端时据此取 IP，修改后需重启生效
+	TrustedProxies []string `yaml:"trusted_proxies"`
+
+	DrainDelay      time.Duration `yaml:"drain_delay"`      // 收到退出信号后 /healthz 返回 503 并等待的时长
+	ShutdownTimeout time.Duration `yaml:"shutdown_timeout"` // 排空后关闭服务器的最长等待时间
+
+	CORS      CORSConfig      `yaml:"cors"`       // 跨域请求的其余设置
+	Readiness ReadinessConfig `yaml:"readiness"`  // /readyz 就绪检查
+	WebSocket WebSocketConfig `yaml:"websocket"`  // /ws 插件
+	OpenAI    OpenAIConfig    `yaml:"openai"`     // /v1 下的 OpenAI 兼容接口
+	RateLimit RateLimitConfig `yaml:"rate_limit"` // 按客户端限流
+	Quota     QuotaConfig     `yaml:"quota"`      // 按调用方每天的用量配额
+	TLS       ServerTLSConfig `yaml:"tls"`        // HTTPS 与客户端证书校验
+
+	Plugins map[string]PluginConfig `yaml:"plugins" env:"-"` // 插件名 -> 是否挂载与挂载路径，修改后需重启生效
+}
+
+// PluginConfig 一个路由插件的配置，未列出的插件不挂载
+type PluginConfig struct {
+	Enabled bool   `yaml:"enabled"`
+	Path    string `yaml:"path"` // 挂载路径，为空时为 /<插件名>
+}
+
+// OpenAIConfig OpenAI 兼容接口，供只支持 OpenAI API 的工具直接调用 Ollama
+type OpenAIConfig struct {
+	Enabled bool              `yaml:"enabled"`        // 是否提供 /v1/chat/completions、/v1/embeddings 与 /v1/models
+	Models  map[string]string `yaml:"models" env:"-"` // OpenAI 模型名 -> Ollama 模型名，未列出的名称原样使用，支持热加载
+}
+
+// CORSConfig 跨域请求的方法、请求头、预检缓存时长与凭据，允许的 Origin 见 ServerConfig.CorsOrigins；支持热加载
+type CORSConfig struct {
+	Methods       []string      `yaml:"methods"`        // 预检请求允许的方法
+	Headers       []string      `yaml:"headers"`        // 预检请求允许的请求头，"*" 表示按请求原样允许
+	ExposeHeaders []string      `yaml:"expose_headers"` // 允许浏览器脚本读取的响应头
+	MaxAge        time.Duration `yaml:"max_age"`        // 浏览器缓存预检结果的时长，为 0 时不设置
+	Credentials   bool          `yaml:"credentials"`    // 是否允许携带 Cookie 与 Authorization，开启时 cors_origins 不能包含 "*"
+}
+
+// RateLimitConfig 按客户端限制请求速率与同时进行的生成数，超出时 HTTP 返回 429 与 Retry-After，
+// WebSocket 回复 code 为 rate_limited 的错误帧；支持热加载
+type RateLimitConfig struct {
+	Key       string        `yaml:"key"`       // 区分客户端的方式：ip，或 token (按 Bearer 凭据，未携带时按 IP)
+	API       RateLimitRule `yaml:"api"`       // /api 下的接口
+	OpenAI    RateLimitRule `yaml:"openai"`    // /v1 下的 OpenAI 兼容接口
+	WebSocket RateLimitRule `yaml:"websocket"` // /ws 连接发出的请求帧
+}
+
+// RateLimitRule 一组路由的限流规则，各项为 0 时不限制
+type RateLimitRule struct {
+	Rate        float64 `yaml:"rate"`        // 每个客户端每秒的请求数
+	Burst       int     `yaml:"burst"`       // 允许的突发请求数，为 0 时取 rate 向上取整
+	Concurrency int     `yaml:"concurrency"` // 每个客户端同时进行的 Ollama 生成数
+}
+
+// QuotaConfig 每个调用方 (租户，JWT 鉴权时为租户内的 sub) 每个 UTC 自然日的对话用量上限，需配置 server.usage_file；
+// 达到上限后 /api/chat、/v1/chat/completions 返回 429，/ws 的 chat 请求帧回复 code 为 quota_exceeded 的错误帧。支持热加载
+type QuotaConfig struct {
+	DailyRequests int64                 `yaml:"daily_requests"`  // 对话次数
+	DailyTokens   int64                 `yaml:"daily_tokens"`    // prompt 与 completion token 之和
+	Tenants       map[string]QuotaLimit `yaml:"tenants" env:"-"` // 租户 -> 该租户调用方的上限，覆盖上述默认值
+}
+
+// QuotaLimit 每天的上限，各项为 0 时不限制
+type QuotaLimit struct {
+	DailyRequests int64 `yaml:"daily_requests"`
+	DailyTokens   int64 `yaml:"daily_tokens"`
+}
+
+// For 返回 tenant 的上限，未单独配置时为默认值
+func (q QuotaConfig) For(tenant string) QuotaLimit {
+	if l, ok := q.Tenants[tenant]; ok {
+		return l
+	}
+	return QuotaLimit{DailyRequests: q.DailyRequests, DailyTokens: q.DailyTokens}
+}
+
+// ServerTLSConfig 服务器 TLS 配置，cert_file 为空时使用明文 HTTP
+type ServerTLSConfig struct {
+	CertFile     string `yaml:"cert_file"`      // 服务器证书
+	KeyFile      string `yaml:"key_file"`       // 服务器私钥
+	ClientCAFile string `yaml:"client_ca_file"` // 签发客户端证书的 CA，配置后 /ws 与 /api 要求有效的客户端证书 (mTLS)
+}
+
+// WebSocketConfig /ws 插件配置
+type WebSocketConfig struct {
+	ReadBufferSize  int `yaml:"read_buffer_size"`  // 连接读缓冲区字节数
+	WriteBufferSize int `yaml:"write_buffer_size"` // 连接写缓冲区字节数
+	SendQueue       int `yaml:"send_queue"`        // 每个连接待发送消息队列长度，写满时断开慢连接
+
+	Origins            []string `yaml:"origins"`             // 允许握手的 Origin，写法同 cors_origins；不带 Origin 的客户端不受限制，支持热加载
+	Subprotocols       []string `yaml:"subprotocols"`        // 可协商的子协议，按优先顺序；为空时不协商，支持热加载
+	RequireSubprotocol bool     `yaml:"require_subprotocol"` // 客户端未提供可协商的子协议时拒绝握手
+
+	Heartbeat HeartbeatConfig `yaml:"heartbeat"` // Ping 心跳与失联连接回收
+
+	History HistoryConfig `yaml:"history"` // 按房间保存最近的消息，连接可在加入房间时或通过 history 帧取回
+	Chat    WSChatConfig  `yaml:"chat"`    // serve 直接调用 Ollama 处理 chat 请求，不经 bridge
+}
+
+// HeartbeatConfig 按间隔向连接发送 Ping，连续 max_missed 个间隔没有收到 Pong 或任何消息的连接被关闭并注销；修改后需重启
+type HeartbeatConfig struct {
+	Interval  time.Duration `yaml:"interval"`   // 发送 Ping 与检查失联连接的间隔，0 表示不启用
+	MaxMissed int           `yaml:"max_missed"` // 允许连续错过的心跳数
+}
+
+// WSChatConfig /ws 上由 serve 直接处理的 chat 请求，回复只发给发出请求的连接
+type WSChatConfig struct {
+	Enabled bool          `yaml:"enabled"` // 启用后 chat 请求不再广播给 bridge
+	Timeout time.Duration `yaml:"timeout"` // 单次对话的处理时限
+}
+
+// HistoryConfig /ws 的消息历史，按租户与房间分别保存，未加入房间时发出的消息归入空房间名
+type HistoryConfig struct {
+	Size     int    `yaml:"size"`      // 每个房间保留的最近消息数，0 表示不保存
+	Backend  string `yaml:"backend"`   // memory、sqlite 或通过 websocket.RegisterHistoryStore 注册的后端
+	Path     string `yaml:"path"`      // backend 为 sqlite 时的数据库文件
+	MaxRooms int    `yaml:"max_rooms"` // backend 为 memory 时最多保存的房间数，超出时丢弃最久没有新消息的房间
+}
+
+// 就绪检查的严格程度
+const (
+	ReadinessOff       = "off"       // 不检查 Ollama，仅排空时返回 503
+	ReadinessReachable = "reachable" // Ollama 可达即就绪
+	ReadinessModels    = "models"    // Ollama 可达且 required_models 均已拉取
+)
+
+// ReadinessConfig /readyz 就绪检查配置
+type ReadinessConfig struct {
+	Mode           string        `yaml:"mode"`            // off、reachable 或 models
+	RequiredModels []string      `yaml:"required_models"` // mode 为 models 时必须存在的模型
+	Interval       time.Duration `yaml:"interval"`        // 探测间隔
+	Timeout        time.Duration `yaml:"timeout"`         // 单次探测超时
+}
+
+// BridgeConfig 桥接客户端配置
+type BridgeConfig struct {
+	URL         string           `yaml:"url"`               // 云端 WebSocket 地址
+	Upstreams   []UpstreamConfig `yaml:"upstreams" env:"-"` // 同时连接多个云端时代替 url，各自独立心跳与重连
+	DebugAddr   string           `yaml:"debug_addr"`        // 本地诊断端口 (pprof、/debug/vars)，为空时不启用
+	MetricsAddr string           `yaml:"metrics_addr"`      // Prometheus 指标端口，提供 /metrics；为空时不启用
+	StatusAddr  string           `yaml:"status_addr"`       // 状态端口，提供 /healthz、/readyz 与 /status，供 Kubernetes 探针使用；为空时不启用
+	Health      HealthConfig     `yaml:"health"`            // Ollama 可达性探测
+
+	HeartbeatInterval time.Duration `yaml:"heartbeat_interval"` // 向云端发送心跳的间隔
+	ReadTimeout       time.Duration `yaml:"read_timeout"`       // 读取超时，应大于心跳间隔
+	Codec             string        `yaml:"codec"`              // 与 serve 之间的帧编码：json，或 msgpack (serve 不支持时回退为 json)
+	ShutdownTimeout   time.Duration `yaml:"shutdown_timeout"`   // 收到退出信号后等待进行中请求回复的最长时间，0 表示立即断开
+
+	Crash          CrashConfig `yaml:"crash"`           // 崩溃报告
+	RecordFile     string      `yaml:"record_file"`     // 调试用：将收到的帧录制到该 JSONL 文件，为空时不录制
+	StrictDecoding bool        `yaml:"strict_decoding"` // 拒绝含未知字段的帧，用于排查协议不一致
+
+	DedupTTL      time.Duration `yaml:"dedup_ttl"`      // 记住已处理 request_id 的时长，重试时重发原响应；0 表示不去重
+	CreditTimeout time.Duration `yaml:"credit_timeout"` // 流式响应额度耗尽后等待云端追加额度的最长时间
+	OutboxSize    int           `yaml:"outbox_size"`    // 连接断开时暂存的未送达响应条数，重连后重发；0 表示不暂存
+	OutboxFile    string        `yaml:"outbox_file"`    // 待发送队列持久化文件，进程重启后继续送达；为空时仅保存在内存
+	JobsFile      string        `yaml:"jobs_file"`      // 模型拉取/推送任务文件，中断的任务重启后继续；为空时仅保存在内存
+	TransferRate  int64         `yaml:"transfer_rate"`  // 模型传输进度流与 blob 传输每秒最多占用的字节数，0 表示不限
+
+	Mirror MirrorConfig `yaml:"mirror"` // 模型层缓存，供局域网内的其他 bridge 拉取
+
+	Workers  WorkersConfig  `yaml:"workers"`   // 并发处理请求的工作池
+	JobQueue JobQueueConfig `yaml:"job_queue"` // chat、generate 等长时间生成的优先级队列
+	Breaker  BreakerConfig  `yaml:"breaker"`   // Ollama 调用的熔断
+
+	ModelConcurrency ModelConcurrencyConfig `yaml:"model_concurrency"` // 每个模型同时进行的生成数
+	Timeouts         TimeoutsConfig         `yaml:"timeouts"`          // 各动作的处理时限
+
+	Reconnect   RetryConfig `yaml:"reconnect"`    // 连接失败或断开后的重连策略
+	OllamaRetry RetryConfig `yaml:"ollama_retry"` // Ollama 调用失败（连接错误、5xx）后的重试策略
+
+	Scripts ScriptsConfig `yaml:"scripts"` // Lua 脚本钩子与自定义动作
+	WASM    WASMConfig    `yaml:"wasm"`    // WASM 模块实现的请求与响应变换
+
+	Sessions SessionsConfig `yaml:"sessions"` // chat 的会话记忆
+	Images   ImagesConfig   `yaml:"images"`   // chat 消息中的图片附件
+	Files    FilesConfig    `yaml:"files"`    // file_upload 与 file_download 的分块文件传输
+
+	PolicyFile string `yaml:"policy_file"` // 模型允许列表与参数范围 (YAML)，修改后自动重新加载；为空时不限制
+}
+
+// UpstreamConfig bridge 连接的一个云端
+type UpstreamConfig struct {
+	Name string              `yaml:"name"` // 上游名称，写入发往该上游的帧的 upstream 字段，用于日志与状态端口
+	URL  string              `yaml:"url"`  // WebSocket 地址
+	Auth *UpstreamAuthConfig `yaml:"auth"` // 连接该上游使用的凭据，未配置时使用 auth 中的 token、hmac 与 tls
+}
+
+// UpstreamAuthConfig 连接单个上游的凭据，配置后整体代替 auth 中对应的项，未填写的项不使用
+type UpstreamAuthConfig struct {
+	Token string          `yaml:"token"` // Bearer Token
+	HMAC  HMACConfig      `yaml:"hmac"`  // 握手签名，只使用 secret
+	TLS   ClientTLSConfig `yaml:"tls"`   // 客户端证书
+}
+
+// ClientAuth 返回连接该上游使用的凭据：配置了 auth 时只使用其中的项，否则为 global
+func (u UpstreamConfig) ClientAuth(global AuthConfig) AuthConfig {
+	if u.Auth == nil {
+		return global
+	}
+	return AuthConfig{Token: u.Auth.Token, HMAC: u.Auth.HMAC, TLS: u.Auth.TLS}
+}
+
+// Targets 返回要连接的上游：配置了 upstreams 时为 upstreams，否则为 url 对应的一个未命名上游
+func (b BridgeConfig) Targets() []UpstreamConfig {
+	if len(b.Upstreams) > 0 {
+		return b.Upstreams
+	}
+	return []UpstreamConfig{{URL: b.URL}}
+}
+
+// WASMConfig WASM 变换模块，在沙箱中变换请求的 params 与响应的 data
+type WASMConfig struct {
+	Dir         string        `yaml:"dir"`          // 模块目录，加载其中的 *.wasm；为空时不启用
+	Timeout     time.Duration `yaml:"timeout"`      // 单次变换的时限
+	MemoryLimit int           `yaml:"memory_limit"` // 每个模块实例的内存上限 (MiB)
+}
+
+// FilesConfig 云端经 file_upload 下发文件 (如 Modelfile)、经 file_download 取回文件 (如日志)，路径不能越出 dir
+type FilesConfig struct {
+	Dir       string `yaml:"dir"`        // 收发文件的目录，为空时不启用文件传输
+	MaxSize   int64  `yaml:"max_size"`   // 单个上传文件的字节数上限
+	ChunkSize int    `yaml:"chunk_size"` // file_download 每个分块的字节数，base64 编码后应小于 chunking.max_frame_size
+}
+
+// ImagesConfig chat 消息 images 中的图片：base64 (可带 data: 前缀) 或由 bridge 下载的 http(s) 地址
+type ImagesConfig struct {
+	MaxCount     int           `yaml:"max_count"`     // 一次请求中全部消息的图片总数上限，0 表示不接受图片
+	MaxBytes     int64         `yaml:"max_bytes"`     // 单张图片解码后的字节数上限
+	AllowedTypes []string      `yaml:"allowed_types"` // 允许的图片类型，按内容识别，例如 image/png
+	FetchURLs    bool          `yaml:"fetch_urls"`    // 是否下载 http(s) 地址的图片；下载发生在 bridge 所在网络，开启前确认云端可信
+	FetchTimeout time.Duration `yaml:"fetch_timeout"` // 下载单张图片的超时
+}
+
+// SessionsConfig 按 params.session_id 保存 chat 的对话历史，云端只需发送新消息
+type SessionsConfig struct {
+	MaxTurns  int           `yaml:"max_turns"`  // 每个会话保留的最近轮数 (一问一答为一轮)，0 表示不启用会话
+	MaxTokens int           `yaml:"max_tokens"` // 历史的估算 token 上限，超出时丢弃最早的轮次；0 表示不限
+	IdleTTL   time.Duration `yaml:"idle_ttl"`   // 会话空闲超过该时长后丢弃
+}
+
+// ScriptsConfig Lua 脚本，在沙箱中运行请求前后的钩子与自定义动作
+type ScriptsConfig struct {
+	Dir     string        `yaml:"dir"`     // 脚本目录，加载其中的 *.lua；为空时不启用
+	Timeout time.Duration `yaml:"timeout"` // 单次钩子或动作调用的时限
+}
+
+// RetryConfig 指数退避的重试策略
+type RetryConfig struct {
+	MaxAttempts int           `yaml:"max_attempts"` // 最多尝试次数（含第一次），0 表示不限
+	Base        time.Duration `yaml:"base"`         // 第一次重试前的等待时间
+	Max         time.Duration `yaml:"max"`          // 单次等待的上限
+	Multiplier  float64       `yaml:"multiplier"`   // 每次重试的等待时间倍数
+	Jitter      float64       `yaml:"jitter"`       // 随机抖动比例，0.2 表示上下浮动 20%，避免大量客户端同时重试
+	Budget      time.Duration `yaml:"budget"`       // 一轮重试的总时长上限，0 表示不限
+}
+
+// TimeoutsConfig 请求的处理时限，超时后取消对 Ollama 的调用并回复 timeout 错误
+type TimeoutsConfig struct {
+	Default time.Duration            `yaml:"default"`         // 未单独配置的动作的时限，0 表示不限
+	Actions map[string]time.Duration `yaml:"actions" env:"-"` // 按动作单独设置，pull_model、push_model 为后台任务每次运行的时限
+}
+
+// For 返回动作的处理时限，0 表示不限
+func (t TimeoutsConfig) For(action string) time.Duration {
+	if d, ok := t.Actions[action]; ok {
+		return d
+	}
+	return t.Default
+}
+
+// ModelConcurrencyConfig 按模型限制同时进行的生成，避免并行加载多个大模型导致显存反复换入换出
+type ModelConcurrencyConfig struct {
+	Default      int            `yaml:"default"`        // 未单独配置的模型的并发上限，0 表示不限
+	Models       map[string]int `yaml:"models" env:"-"` // 按模型名单独设置上限，未写标签时视为 :latest
+	QueueTimeout time.Duration  `yaml:"queue_timeout"`  // 超出上限时排队等待的最长时间，0 表示直接回复 model_busy
+}
+
+// BreakerConfig 熔断配置，后端连续失败时直接拒绝请求，冷却后放行少量探测调用
+type BreakerConfig struct {
+	Failures       int           `yaml:"failures"`         // 连续失败多少次后熔断，0 表示不熔断
+	OpenTimeout    time.Duration `yaml:"open_timeout"`     // 熔断后多久放行探测调用
+	HalfOpenProbes int           `yaml:"half_open_probes"` // 半开状态下同时放行的探测调用数
+}
+
+// WorkersConfig 工作池配置，fast_actions 中的轻量动作使用独立的快速通道，不会排在长时间的生成之后
+type WorkersConfig struct {
+	Fast        int      `yaml:"fast"`         // 快速通道的并发数，0 表示轻量动作同样走普通通道
+	Normal      int      `yaml:"normal"`       // 普通通道的并发数，0 表示不启用工作池，请求逐个处理
+	Queue       int      `yaml:"queue"`        // 每条通道的排队上限，超出时回复 busy 错误
+	FastActions []string `yaml:"fast_actions"` // 走快速通道的动作
+}
+
+// JobQueueConfig 任务队列配置：actions 中的请求按优先级排队，最多 concurrency 个同时调用 Ollama，
+// 排队中的请求可保存到 file，重启后继续执行
+type JobQueueConfig struct {
+	Concurrency int      `yaml:"concurrency"` // 同时执行的任务数，0 表示不启用队列
+	MaxSize     int      `yaml:"max_size"`    // 排队中的任务数上限，超出时回复 busy 错误
+	File        string   `yaml:"file"`        // 排队中的任务持久化文件，为空时仅保存在内存
+	Actions     []string `yaml:"actions"`     // 进入队列的动作，其余动作仍走工作池
+}
+
+// MirrorConfig 模型 registry 回源缓存配置
+type MirrorConfig struct {
+	Addr     string `yaml:"addr"`     // 在该地址上提供缓存，为空时不启用
+	Upstream string `yaml:"upstream"` // 回源的 registry 地址
+	Dir      string `yaml:"dir"`      // 缓存目录
+	MaxSize  int64  `yaml:"max_size"` // 缓存的最大字节数，超出时删除最久未使用的层；0 表示不限
+	PullVia  string `yaml:"pull_via"` // 通过该 mirror 拉取模型，例如 http://10.0.0.5:5001；为空时直接从 registry 拉取
+}
+
+// CrashConfig 崩溃报告配置，处理请求或主循环发生 panic 时写入报告后继续运行
+type CrashConfig struct {
+	Dir            string        `yaml:"dir"`             // 报告目录
+	History        int           `yaml:"history"`         // 报告中附带的最近请求条数（已脱敏）
+	Webhook        string        `yaml:"webhook"`         // 报告同时 POST 到该地址，为空时不发送
+	WebhookTimeout time.Duration `yaml:"webhook_timeout"` // 发送报告的超时
+}
+
+// HealthConfig Ollama 可达性探测配置
+type HealthConfig struct {
+	Interval   time.Duration `yaml:"interval"`    // 健康时的探测间隔
+	Timeout    time.Duration `yaml:"timeout"`     // 单次探测超时
+	MinBackoff time.Duration `yaml:"min_backoff"` // 不健康时的初始重试间隔
+	MaxBackoff time.Duration `yaml:"max_backoff"` // 不健康时的最大重试间隔
+}
+
+// WSTestConfig 分组测试服务器配置
+type WSTestConfig struct {
+	Addr              string        `yaml:"addr"`               // 监听地址
+	HeartbeatInterval time.Duration `yaml:"heartbeat_interval"` // 向客户端发送心跳与检查失联连接的间隔
+	MaxMissed         int           `yaml:"max_missed"`         // 连续错过该数量的心跳 (没有 Pong 或消息) 时断开连接
+}
+
+// ClientConfig 测试客户端配置
+type ClientConfig struct {
+	URL    string `yaml:"url"`    // 服务端 WebSocket 地址
+	Origin string `yaml:"origin"` // 握手时携带的 Origin 请求头
+}
+
+// ChatConfig 交互式对话配置
+type ChatConfig struct {
+	Model  string `yaml:"model"`  // 默认模型
+	Server string `yaml:"server"` // 服务器 WebSocket 地址，为空时使用本地 Ollama
+	User   string `yaml:"user"`   // 随请求上报的用户名，用于按用户统计用量
+}
+
+// AuthConfig 鉴权配置
+type AuthConfig struct {
+	Token   string          `yaml:"token"`           // Bearer Token，桥接客户端与服务器共用，对应 default 租户
+	Tenants []TenantConfig  `yaml:"tenants" env:"-"` // 多租户，按 Token 区分租户；为空时所有连接属于 default 租户
+	HMAC    HMACConfig      `yaml:"hmac"`            // 握手签名
+	JWT     JWTConfig       `yaml:"jwt"`             // serve 接受的 JWT
+	TLS     ClientTLSConfig `yaml:"tls"`             // 客户端证书
+}
+
+// JWTConfig serve 的 /ws、/api、/v1 与管理接口接受的 JWT，algorithm 为空时不启用；
+// 令牌的 tenant 声明为所属租户 (缺省为 default)，roles 声明为角色 (admin、user)
+type JWTConfig struct {
+	Algorithm     string        `yaml:"algorithm"`       // HS256 或 RS256
+	Secret        string        `yaml:"secret"`          // HS256 的密钥
+	PublicKeyFile string        `yaml:"public_key_file"` // RS256 的 PEM 公钥
+	Issuer        string        `yaml:"issuer"`          // 非空时要求 iss 一致
+	Audience      string        `yaml:"audience"`        // 非空时要求 aud 包含该值
+	Leeway        time.Duration `yaml:"leeway"`          // 校验 exp、nbf 时允许的时钟偏差
+}
+
+// HMACConfig 握手签名配置，secret 为空时不签名也不校验
+type HMACConfig struct {
+	Secret  string        `yaml:"secret"`   // 桥接客户端与服务器共享的签名密钥
+	MaxSkew time.Duration `yaml:"max_skew"` // 签名时间与服务器时间允许的最大偏差，同时是防重放的记录时长
+}
+
+// ClientTLSConfig 连接 wss:// 服务器时使用的证书
+type ClientTLSConfig struct {
+	CertFile string `yaml:"cert_file"` // 客户端证书，服务器配置了 server.tls.client_ca_file 时必需
+	KeyFile  string `yaml:"key_file"`  // 客户端私钥
+	CAFile   string `yaml:"ca_file"`   // 校验服务器证书的 CA，为空时使用系统根证书
+}
+
+// TenantConfig 租户及其 Token，同一租户的连接互相可见，不同租户之间隔离
+type TenantConfig struct {
+	ID    string `yaml:"id"`    // 租户标识，出现在协议的 tenant_id 字段与日志中
+	Token string `yaml:"token"` // 该租户的 Bearer Token
+}
+
+// CacheConfig 缓存配置
+type CacheConfig struct {
+	TTL             time.Duration    `yaml:"ttl"`              // 模型列表等缓存的过期时间
+	CleanupInterval time.Duration    `yaml:"cleanup_interval"` // 清理过期条目的间隔
+	Backend         string           `yaml:"backend"`          // memory、redis、bolt 或通过 bridge.RegisterCache 注册的后端
+	Namespace       string           `yaml:"namespace"`        // 键的命名空间，多个 bridge 共享 redis 时区分各自的条目
+	Redis           CacheRedisConfig `yaml:"redis"`            // backend 为 redis 时使用
+	Bolt            CacheBoltConfig  `yaml:"bolt"`             // backend 为 bolt 时使用
+
+	Generations GenerationCacheConfig `yaml:"generations"` // chat 与 generate 的结果缓存，保存在进程内
+}
+
+// GenerationCacheConfig 按模型、消息或提示词与 options 缓存 temperature 为 0 的完整回复，请求的 params.no_cache 可跳过
+type GenerationCacheConfig struct {
+	MaxEntries int           `yaml:"max_entries"` // 最多缓存的回复数，超出时淘汰最久未使用的；0 表示不启用
+	TTL        time.Duration `yaml:"ttl"`         // 回复写入后的有效期
+}
+
+// CacheRedisConfig Redis 缓存后端
+type CacheRedisConfig struct {
+	Addr     string        `yaml:"addr"`     // host:port
+	Password string        `yaml:"password"` // 为空时不认证
+	DB       int           `yaml:"db"`       // 数据库编号
+	Timeout  time.Duration `yaml:"timeout"`  // 连接与单条命令的超时
+
+	MaxEntrySize int64 `yaml:"max_entry_size"` // 单个条目编码后的字节数上限，更大的值不写入，读到更大的回复时断开连接
+}
+
+// CacheBoltConfig bbolt 文件缓存后端
+type CacheBoltConfig struct {
+	Path string `yaml:"path"` // 缓存文件，重启后保留未过期的条目
+}
+
+// ModelsConfig 模型配置
+type ModelsConfig struct {
+	Default string `yaml:"default"` // 未指定模型时使用的默认模型
+}
+
+// OllamaConfig 本地 Ollama 配置
+type OllamaConfig struct {
+	Host    string   `yaml:"host"`    // Ollama 地址，为空时读取 OLLAMA_HOST 环境变量
+	Hosts   []string `yaml:"hosts"`   // 多个 Ollama 地址，非空时代替 host，按模型与负载调度并在故障时切换
+	Balance string   `yaml:"balance"` // 多个后端之间的调度：least_loaded、round_robin 或 first
+}
+
+// FeaturesConfig 功能开关，管理员可在运行期通过 /admin/features 临时修改
+type FeaturesConfig struct {
+	Streaming     bool `yaml:"streaming"`      // 流式输出
+	E2EEncryption bool `yaml:"e2e_encryption"` // 端到端加密
+	SemanticCache bool `yaml:"semantic_cache"` // 语义缓存
+	RAG           bool `yaml:"rag"`            // 检索增强生成
+}
+
+// CaptureConfig WebSocket 收发帧抓取配置，字段按 log.redact 脱敏
+type CaptureConfig struct {
+	Enabled      bool   `yaml:"enabled"`        // 是否启用
+	Buffer       int    `yaml:"buffer"`         // 内存中保留的最近帧数，可通过管理接口下载
+	File         string `yaml:"file"`           // 同时追加写入该 JSONL 文件，为空时不写文件
+	MaxFrameSize int    `yaml:"max_frame_size"` // 单帧保留的最大字节数，0 表示不截断
+}
+
+// JanitorConfig 过期数据清理配置，定期删除过期的去重记录与超时未收齐的分片
+type JanitorConfig struct {
+	Interval time.Duration `yaml:"interval"` // 清理间隔
+}
+
+// AlertConfig serve 的告警规则，触发时记录日志并通过 Webhook 或邮件通知，同一规则在 cooldown 内只通知一次
+type AlertConfig struct {
+	Interval       time.Duration `yaml:"interval"`        // 规则评估间隔，0 表示不启用告警
+	Cooldown       time.Duration `yaml:"cooldown"`        // 同一规则两次通知的最小间隔
+	Webhook        string        `yaml:"webhook"`         // 告警以 JSON POST 到该地址，为空时不发送
+	WebhookTimeout time.Duration `yaml:"webhook_timeout"` // 发送 Webhook 的超时
+	Email          EmailConfig   `yaml:"email"`           // 邮件通知
+
+	ErrorRate      RateRule      `yaml:"error_rate"`      // window 内返回给调用方的错误数达到 threshold
+	ReconnectStorm RateRule      `yaml:"reconnect_storm"` // window 内断开的 WebSocket 连接数达到 threshold
+	BackendDown    time.Duration `yaml:"backend_down"`    // Ollama 持续不可达超过该时长，0 表示不检查，需 server.readiness.mode 不为 off
+}
+
+// RateRule 计数在时间窗口内的增量达到阈值时触发
+type RateRule struct {
+	Threshold int           `yaml:"threshold"` // 0 表示不检查
+	Window    time.Duration `yaml:"window"`
+}
+
+// EmailConfig SMTP 邮件通知配置
+type EmailConfig struct {
+	SMTPAddr string   `yaml:"smtp_addr"` // host:port，为空时不发送邮件
+	Username string   `yaml:"username"`  // 为空时不进行 SMTP 认证
+	Password string   `yaml:"password"`
+	From     string   `yaml:"from"`
+	To       []string `yaml:"to"`
+}
+
+// 定时任务类型
+const (
+	TaskRefreshModels = "refresh_models" // 刷新模型列表缓存
+	TaskWarmModels    = "warm_models"    // 将 models 加载到内存，避免首个请求等待加载
+	TaskRotateKeys    = "rotate_keys"    // 轮换 tenants 的端到端加密密钥
+)
+
+// ScheduleConfig bridge 的定时任务，运行状态见诊断端口的 /debug/schedule
+type ScheduleConfig struct {
+	Jobs []JobConfig `yaml:"jobs" env:"-"`
+}
+
+// JobConfig 单个定时任务
+type JobConfig struct {
+	Name     string   `yaml:"name"`
+	Task     string   `yaml:"task"`              // refresh_models、warm_models 或 rotate_keys
+	Schedule string   `yaml:"schedule"`          // cron 表达式 (分 时 日 月 周)，或 @hourly、@daily、@every 10m
+	Models   []string `yaml:"models,omitempty"`  // warm_models 预热的模型
+	Tenants  []string `yaml:"tenants,omitempty"` // rotate_keys 轮换的租户
+	Keep     int      `yaml:"keep,omitempty"`    // rotate_keys 保留的版本数，0 表示全部保留
+}
+
+// E2EConfig 端到端加密配置，features.e2e_encryption 开启时 chat 加密请求，bridge 只接受加密的请求
+type E2EConfig struct {
+	Keystore   string `yaml:"keystore"`   // 密钥文件，按租户保存多个版本的密钥，由 ollama_dev keys rotate 生成
+	Passphrase string `yaml:"passphrase"` // 密钥文件的口令，文件内容以口令派生的密钥加密，建议通过 OLLAMA_DEV_E2E_PASSPHRASE 设置
+	Tenant     string `yaml:"tenant"`     // chat 加密请求使用的租户
+	Cipher     string `yaml:"cipher"`     // aes-256-gcm 或 chacha20-poly1305，为空时按硬件自动选择
+}
+
+// CompressionConfig WebSocket 的 permessage-deflate 压缩，serve、bridge 与 chat 各按本端配置协商，双方都启用时生效
+type CompressionConfig struct {
+	Enabled   bool `yaml:"enabled"`   // 是否协商压缩
+	Level     int  `yaml:"level"`     // 压缩级别，1 (最快) 到 9 (最小)
+	Threshold int  `yaml:"threshold"` // 小于该字节数的帧不压缩
+}
+
+// ChunkingConfig 大消息分片配置，bridge 与 chat 收发的消息超过 max_frame_size 时拆分为多帧，接收方重组
+type ChunkingConfig struct {
+	MaxFrameSize   int           `yaml:"max_frame_size"`   // 单帧最大字节数，0 表示不拆分
+	MaxMessageSize int           `yaml:"max_message_size"` // 重组后消息的最大字节数，0 表示不限
+	Timeout        time.Duration `yaml:"timeout"`          // 分片未在该时长内收齐时丢弃
+}
+
+// AdminConfig 管理员账号，用于 pprof 等诊断接口的 Basic Auth
+type AdminConfig struct {
+	Username string `yaml:"username"`
+	Password string `yaml:"password"`
+}
+
+// LogConfig 日志配置
+type LogConfig struct {
+	Level   string          `yaml:"level"`   // debug、info、warn、error
+	Format  string          `yaml:"format"`  // text 或 json
+	Outputs []string        `yaml:"outputs"` // stdout、stderr 或文件路径，可同时输出到多个目标
+	Rotate  LogRotateConfig `yaml:"rotate"`  // 日志文件按大小轮转
+
+	Redact   []string          `yaml:"redact"`   // 输出前脱敏的字段名，不区分大小写
+	Sampling LogSamplingConfig `yaml:"sampling"` // 重复消息采样
+	Throttle LogThrottleConfig `yaml:"throttle"` // 重复错误折叠
+}
+
+// TracingConfig 通过 OTLP/HTTP 导出 HTTP 请求、WebSocket 消息与 Ollama 调用的 span，endpoint 为空时不启用
+type TracingConfig struct {
+	Endpoint    string  `yaml:"endpoint"`     // OTLP/HTTP 接收地址，例如 http://localhost:4318
+	ServiceName string  `yaml:"service_name"` // 上报的 service.name
+	SampleRatio float64 `yaml:"sample_ratio"` // 采样比例 (0~1)，请求已带有采样决定时沿用上游的决定
+}
+
+// LogRotateConfig 日志文件按大小轮转，只作用于 outputs 中的文件路径
+type LogRotateConfig struct {
+	MaxSize    int `yaml:"max_size"`    // 单个文件的最大 MiB 数，超出后重命名为 <path>.1 并新建文件，0 表示不轮转
+	MaxBackups int `yaml:"max_backups"` // 保留的历史文件数，更早的被删除
+}
+
+// LogThrottleConfig 重复错误折叠配置，Window 为 0 时不折叠
+type LogThrottleConfig struct {
+	Window time.Duration `yaml:"window"` // 窗口内同一 Warn/Error 日志只输出一条，窗口结束后输出带计数的摘要
+}
+
+// LogSamplingConfig 重复消息采样配置，Initial 为 0 时不采样
+type LogSamplingConfig struct {
+	Initial    int           `yaml:"initial"`    // 每个周期内同一消息先完整输出的条数
+	Thereafter int           `yaml:"thereafter"` // 超出后每 N 条输出一条，0 表示全部丢弃
+	Interval   time.Duration `yaml:"interval"`   // 采样周期
+}
+
+// Default 返回默认配置
+func Default() *Config {
+	return &Config{
+		Server: ServerConfig{
+			Addr:           ":8080",
+			CorsOrigins:    []string{},
+			TrustedProxies: []string{},
+			CORS: CORSConfig{
+				Methods:       []string{"GET", "POST", "PUT", "DELETE"},
+				Headers:       []string{"Content-Type", "Authorization"},
+				ExposeHeaders: []string{"Retry-After", "X-Request-ID"},
+				MaxAge:        10 * time.Minute,
+			},
+			UsageFile:       "usage.db",
+			DrainDelay:      5 * time.Second,
+			ShutdownTimeout: 30 * time.Second,
+			Readiness: ReadinessConfig{
+				Mode:     ReadinessReachable,
+				Interval: 10 * time.Second,
+				Timeout:  3 * time.Second,
+			},
+			WebSocket: WebSocketConfig{
+				ReadBufferSize:  4096,
+				WriteBufferSize: 4096,
+				SendQueue:       256,
+				Origins:         []string{"*"},
+				Subprotocols:    []string{"ollama.v1.json", "ollama.v1.encrypted", "ollama.v1.msgpack"},
+				Heartbeat:       HeartbeatConfig{Interval: 30 * time.Second, MaxMissed: 3},
+				History:         HistoryConfig{Backend: "memory", Path: "history.db", MaxRooms: 1000},
+				Chat:            WSChatConfig{Timeout: 5 * time.Minute},
+			},
+			RateLimit: RateLimitConfig{Key: "token"},
+			Plugins: map[string]PluginConfig{
+				"websocket": {Enabled: true, Path: "/ws"},
+				"ui":        {Enabled: true, Path: "/ui"},
+			},
+		},
+		Bridge: BridgeConfig{
+			Health: HealthConfig{
+				Interval:   15 * time.Second,
+				Timeout:    5 * time.Second,
+				MinBackoff: time.Second,
+				MaxBackoff: time.Minute,
+			},
+			HeartbeatInterval: 30 * time.Second,
+			ReadTimeout:       40 * time.Second,
+			Codec:             "json",
+			ShutdownTimeout:   30 * time.Second,
+			Crash: CrashConfig{
+				Dir:            "crash",
+				History:        20,
+				WebhookTimeout: 5 * time.Second,
+			},
+			DedupTTL:      5 * time.Minute,
+			CreditTimeout: time.Minute,
+			OutboxSize:    100,
+			OutboxFile:    "outbox.db",
+			JobsFile:      "jobs.db",
+			Mirror: MirrorConfig{
+				Upstream: "https://registry.ollama.ai",
+				Dir:      "mirror",
+			},
+			Workers: WorkersConfig{
+				Fast:        2,
+				Normal:      4,
+				Queue:       64,
+				FastActions: []string{"list_model", "version", "pull_model", "push_model", "get_job", "list_jobs", "delete_model", "copy_model", "show_model", "ps", "job_status", "job_cancel"},
+			},
+			JobQueue: JobQueueConfig{
+				MaxSize: 256,
+				File:    "queue.db",
+				Actions: []string{"chat", "generate"},
+			},
+			Timeouts: TimeoutsConfig{
+				Default: 5 * time.Minute,
+				Actions: map[string]time.Duration{
+					"list_model":   30 * time.Second,
+					"version":      10 * time.Second,
+					"pull_model":   6 * time.Hour,
+					"push_model":   6 * time.Hour,
+					"create_model": 30 * time.Minute,
+				},
+			},
+			Reconnect: RetryConfig{
+				Base:       time.Second,
+				Max:        time.Minute,
+				Multiplier: 2,
+				Jitter:     0.2,
+			},
+			OllamaRetry: RetryConfig{
+				MaxAttempts: 3,
+				Base:        200 * time.Millisecond,
+				Max:         2 * time.Second,
+				Multiplier:  2,
+				Jitter:      0.2,
+				Budget:      10 * time.Second,
+			},
+			Breaker: BreakerConfig{
+				Failures:       5,
+				OpenTimeout:    30 * time.Second,
+				HalfOpenProbes: 1,
+			},
+			Scripts:  ScriptsConfig{Timeout: 100 * time.Millisecond},
+			WASM:     WASMConfig{Timeout: 100 * time.Millisecond, MemoryLimit: 64},
+			Sessions: SessionsConfig{MaxTurns: 20, MaxTokens: 4096, IdleTTL: 30 * time.Minute},
+			Images: ImagesConfig{
+				MaxCount:     4,
+				MaxBytes:     10 << 20,
+				AllowedTypes: []string{"image/jpeg", "image/png", "image/webp"},
+				FetchTimeout: 10 * time.Second,
+			},
+			Files: FilesConfig{
+				MaxSize:   256 << 20,
+				ChunkSize: 256 << 10,
+			},
+		},
+		WSTest: WSTestConfig{Addr: ":8080", HeartbeatInterval: 30 * time.Second, MaxMissed: 3},
+		Client: ClientConfig{
+			URL:    "ws://localhost:8080/ws",
+			Origin: "http://allowed-origin.com",
+		},
+		Auth:   AuthConfig{Token: "valid-token", HMAC: HMACConfig{MaxSkew: 5 * time.Minute}, JWT: JWTConfig{Leeway: 30 * time.Second}},
+		Ollama: OllamaConfig{Hosts: []string{}, Balance: "least_loaded"},
+		Cache: CacheConfig{
+			TTL:             120 * time.Second,
+			CleanupInterval: 10 * time.Minute,
+			Backend:         "memory",
+			Namespace:       "ollama_dev",
+			Redis:           CacheRedisConfig{Addr: "localhost:6379", Timeout: 2 * time.Second, MaxEntrySize: 16 << 20},
+			Bolt:            CacheBoltConfig{Path: "cache.db"},
+			Generations:     GenerationCacheConfig{TTL: 10 * time.Minute},
+		},
+		Admin: AdminConfig{Username: "admin"},
+		Log: LogConfig{
+			Level:   "info",
+			Format:  "text",
+			Outputs: []string{"stdout"},
+			Rotate:  LogRotateConfig{MaxSize: 100, MaxBackups: 5},
+			Redact:  []string{"token", "authorization", "password", "prompt", "content", "messages"},
+			Sampling: LogSamplingConfig{
+				Initial:    10,
+				Thereafter: 100,
+				Interval:   time.Minute,
+			},
+			Throttle: LogThrottleConfig{Window: time.Minute},
+		},
+		Tracing: TracingConfig{
+			ServiceName: "ollama_dev",
+			SampleRatio: 1,
+		},
+		Capture: CaptureConfig{
+			Buffer:       1000,
+			MaxFrameSize: 4096,
+		},
+		Chunking: ChunkingConfig{
+			MaxFrameSize:   512 << 10,
+			MaxMessageSize: 64 << 20,
+			Timeout:        time.Minute,
+		},
+		Compression: CompressionConfig{Level: 1, Threshold: 1024},
+		Janitor:     JanitorConfig{Interval: time.Minute},
+		E2E:         E2EConfig{Keystore: "keys.json", Tenant: "default"},
+		Alert: AlertConfig{
+			Interval:       30 * time.Second,
+			Cooldown:       15 * time.Minute,
+			WebhookTimeout: 5 * time.Second,
+			ErrorRate:      RateRule{Threshold: 50, Window: 5 * time.Minute},
+			ReconnectStorm: RateRule{Threshold: 20, Window: time.Minute},
+			BackendDown:    5 * time.Minute,
+		},
+		Lang: "zh",
+	}
+}
+
+// Load 读取配置文件并应用 OLLAMA_DEV_* 环境变量，优先级：环境变量 > 配置文件 > 默认值
+func Load(path string) (*Config, error) {
+	cfg := Default()
+	if path != "" {
+		if err := loadFile(path, cfg); err != nil {
+			return nil, err
+		}
+	}
+	if err := ApplyEnv(cfg, os.LookupEnv); err != nil {
+		return nil, fmt.Errorf("解析环境变量失败: %w", err)
+	}
+	return cfg, nil
+}
+
+// loadFile 将 YAML 配置文件解析到 cfg 上
+func loadFile(path string, cfg *Config) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("配置文件不存在: %s", path)
+		}
+		return fmt.Errorf("读取配置文件失败: %w", err)
+	}
+
+	if err := yaml.Unmarshal(data, cfg); err != nil {
+		return fmt.Errorf("解析配置文件失败: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,46 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadDefault(t *testing.T) {
+	cfg, err := Load("")
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if cfg.Server.Addr != ":8080" {
+		t.Errorf("unexpected default server addr: %s", cfg.Server.Addr)
+	}
+}
+
+func TestLoadFileOverridesDefaults(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	data := []byte("server:\n  addr: \":9090\"\nbridge:\n  url: \"ws://example.com/ws\"\n")
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatalf("write config failed: %v", err)
+	}
+
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if cfg.Server.Addr != ":9090" {
+		t.Errorf("expected :9090, got %s", cfg.Server.Addr)
+	}
+	if cfg.Bridge.URL != "ws://example.com/ws" {
+		t.Errorf("expected bridge url from file, got %s", cfg.Bridge.URL)
+	}
+	// 未出现在文件中的字段保留默认值
+	if cfg.Client.URL != "ws://localhost:8080/ws" {
+		t.Errorf("expected default client url, got %s", cfg.Client.URL)
+	}
+}
+
+func TestLoadMissingFile(t *testing.T) {
+	if _, err := Load(filepath.Join(t.TempDir(), "missing.yaml")); err == nil {
+		t.Error("expected an error for a missing config file, but got none")
+	}
+}
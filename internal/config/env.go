@@ -0,0 +1,115 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// EnvPrefix 环境变量前缀，变量名由 yaml 路径推导，例如 server.addr -> OLLAMA_DEV_SERVER_ADDR
+const EnvPrefix = "OLLAMA_DEV_"
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// ApplyEnv 用环境变量覆盖配置，变量名由字段的 yaml 标签推导，env 标签可指定其他名称，
+// env:"-" 表示不读取环境变量。列表以逗号分隔。解析失败时返回全部出错的变量
+func ApplyEnv(cfg *Config, lookup func(string) (string, bool)) error {
+	var errs ValidationErrors
+	applyEnv(reflect.ValueOf(cfg).Elem(), "", lookup, &errs)
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+func applyEnv(v reflect.Value, path string, lookup func(string) (string, bool), errs *ValidationErrors) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name, _, _ := strings.Cut(field.Tag.Get("yaml"), ",")
+		if name == "" || name == "-" {
+			continue
+		}
+		fieldPath := name
+		if path != "" {
+			fieldPath = path + "." + name
+		}
+
+		fv := v.Field(i)
+		if fv.Kind() == reflect.Struct {
+			applyEnv(fv, fieldPath, lookup, errs)
+			continue
+		}
+
+		key := field.Tag.Get("env")
+		if key == "-" {
+			continue
+		}
+		if key == "" {
+			key = EnvName(fieldPath)
+		}
+		raw, ok := lookup(key)
+		if !ok {
+			continue
+		}
+		if err := setValue(fv, raw); err != nil {
+			*errs = append(*errs, FieldError{Field: key, Message: fmt.Sprintf("无法解析 %q (%s): %v", raw, fieldPath, err)})
+		}
+	}
+}
+
+// EnvName 返回配置项对应的环境变量名
+func EnvName(path string) string {
+	return EnvPrefix + strings.ToUpper(strings.ReplaceAll(path, ".", "_"))
+}
+
+func setValue(v reflect.Value, raw string) error {
+	raw = strings.TrimSpace(raw)
+	if v.Type() == durationType {
+		d, err := time.ParseDuration(raw)
+		if err != nil {
+			return fmt.Errorf("应为时长，例如 30s、2m")
+		}
+		v.SetInt(int64(d))
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(raw)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return fmt.Errorf("应为 true 或 false")
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int64:
+		n, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil {
+			return fmt.Errorf("应为整数")
+		}
+		v.SetInt(n)
+	case reflect.Float64:
+		f, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			return fmt.Errorf("应为数字")
+		}
+		v.SetFloat(f)
+	case reflect.Slice:
+		if v.Type().Elem().Kind() != reflect.String {
+			return fmt.Errorf("不支持的类型 %s", v.Type())
+		}
+		var items []string
+		for _, item := range strings.Split(raw, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+		v.Set(reflect.ValueOf(items))
+	default:
+		return fmt.Errorf("不支持的类型 %s", v.Type())
+	}
+	return nil
+}
@@ -0,0 +1,60 @@
+package config
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func lookupFrom(env map[string]string) func(string) (string, bool) {
+	return func(key string) (string, bool) {
+		v, ok := env[key]
+		return v, ok
+	}
+}
+
+func TestApplyEnv(t *testing.T) {
+	cfg := Default()
+	err := ApplyEnv(cfg, lookupFrom(map[string]string{
+		"OLLAMA_DEV_SERVER_ADDR":                      ":9090",
+		"OLLAMA_DEV_SERVER_PPROF":                     "true",
+		"OLLAMA_DEV_SERVER_CORS_ORIGINS":              "https://a.com, https://b.com",
+		"OLLAMA_DEV_SERVER_WEBSOCKET_SEND_QUEUE":      "64",
+		"OLLAMA_DEV_BRIDGE_HEARTBEAT_INTERVAL":        "10s",
+		"OLLAMA_DEV_SERVER_READINESS_REQUIRED_MODELS": "llama3",
+	}))
+	if err != nil {
+		t.Fatalf("ApplyEnv failed: %v", err)
+	}
+	if cfg.Server.Addr != ":9090" || !cfg.Server.Pprof {
+		t.Errorf("unexpected server config: %+v", cfg.Server)
+	}
+	if want := []string{"https://a.com", "https://b.com"}; !reflect.DeepEqual(cfg.Server.CorsOrigins, want) {
+		t.Errorf("cors origins: got %v, want %v", cfg.Server.CorsOrigins, want)
+	}
+	if cfg.Server.WebSocket.SendQueue != 64 {
+		t.Errorf("send queue: got %d", cfg.Server.WebSocket.SendQueue)
+	}
+	if cfg.Bridge.HeartbeatInterval != 10*time.Second {
+		t.Errorf("heartbeat interval: got %s", cfg.Bridge.HeartbeatInterval)
+	}
+	if !reflect.DeepEqual(cfg.Server.Readiness.RequiredModels, []string{"llama3"}) {
+		t.Errorf("required models: got %v", cfg.Server.Readiness.RequiredModels)
+	}
+}
+
+func TestApplyEnvInvalid(t *testing.T) {
+	err := ApplyEnv(Default(), lookupFrom(map[string]string{
+		"OLLAMA_DEV_CACHE_TTL":    "soon",
+		"OLLAMA_DEV_SERVER_PPROF": "maybe",
+		"OLLAMA_DEV_SERVER_ADDR":  ":9090",
+	}))
+	var verrs ValidationErrors
+	if !errors.As(err, &verrs) {
+		t.Fatalf("expected ValidationErrors, got %v", err)
+	}
+	if len(verrs) != 2 {
+		t.Errorf("expected 2 errors, got %d: %v", len(verrs), verrs)
+	}
+}
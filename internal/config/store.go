@@ -0,0 +1,59 @@
+package config
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+)
+
+// Store 持有当前生效的配置，支持在运行期间重新加载
+type Store struct {
+	path string
+	cur  atomic.Pointer[Config]
+
+	mu        sync.Mutex
+	listeners []func(old, cur *Config)
+}
+
+// NewStore 以已加载的配置创建 Store，path 为空时不支持重新加载
+func NewStore(path string, cfg *Config) *Store {
+	s := &Store{path: path}
+	s.cur.Store(cfg)
+	return s
+}
+
+// Get 返回当前配置，调用方不应修改返回值
+func (s *Store) Get() *Config {
+	return s.cur.Load()
+}
+
+// OnReload 注册重新加载成功后的回调
+func (s *Store) OnReload(fn func(old, cur *Config)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.listeners = append(s.listeners, fn)
+}
+
+// Reload 重新读取并校验配置文件，校验失败时保留原配置
+func (s *Store) Reload() error {
+	if s.path == "" {
+		return fmt.Errorf("未通过 --config 指定配置文件，无法重新加载")
+	}
+
+	cfg, err := Load(s.path)
+	if err != nil {
+		return err
+	}
+	if err := cfg.Validate(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	old := s.cur.Swap(cfg)
+	for _, fn := range s.listeners {
+		fn(old, cfg)
+	}
+	return nil
+}
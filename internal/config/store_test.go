@@ -0,0 +1,51 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestStoreReload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte("log:\n  level: info\n"), 0o600); err != nil {
+		t.Fatalf("write config failed: %v", err)
+	}
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+
+	store := NewStore(path, cfg)
+	var notified string
+	store.OnReload(func(old, cur *Config) {
+		notified = old.Log.Level + "->" + cur.Log.Level
+	})
+
+	if err := os.WriteFile(path, []byte("log:\n  level: debug\n"), 0o600); err != nil {
+		t.Fatalf("write config failed: %v", err)
+	}
+	if err := store.Reload(); err != nil {
+		t.Fatalf("Reload failed: %v", err)
+	}
+	if store.Get().Log.Level != "debug" || notified != "info->debug" {
+		t.Errorf("unexpected reload result: level=%s notified=%s", store.Get().Log.Level, notified)
+	}
+
+	// 校验失败时保留原配置
+	if err := os.WriteFile(path, []byte("log:\n  level: verbose\n"), 0o600); err != nil {
+		t.Fatalf("write config failed: %v", err)
+	}
+	if err := store.Reload(); err == nil {
+		t.Error("expected a validation error, but got none")
+	}
+	if store.Get().Log.Level != "debug" {
+		t.Errorf("invalid reload should keep the previous config, got %s", store.Get().Log.Level)
+	}
+}
+
+func TestStoreReloadWithoutPath(t *testing.T) {
+	if err := NewStore("", Default()).Reload(); err == nil {
+		t.Error("expected an error without a config path, but got none")
+	}
+}
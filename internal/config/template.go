@@ -0,0 +1,567 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+)
+
+// defaultTemplate 带注释的默认配置文件，取值与 Default() 保持一致
+const defaultTemplate = `# ollama_dev 配置文件
+# 优先级：命令行参数 > 环境变量 > 此文件 > 默认值
+# 每个配置项都可用 OLLAMA_DEV_ 加大写路径的环境变量覆盖，例如 server.addr -> OLLAMA_DEV_SERVER_ADDR，
+# 列表以逗号分隔，例如 OLLAMA_DEV_SERVER_CORS_ORIGINS="https://a.com,https://b.com"
+
+# serve: Gin 服务器
+server:
+  # 监听地址，形如 host:port，host 为空表示监听所有网卡
+  addr: ":8080"
+  # 允许跨域的 Origin，默认只允许同源；"*" 表示全部，可用 * 通配一段主机名或端口，
+  # 例如 ["https://app.example.com", "https://*.example.com", "http://localhost:*"]
+  cors_origins: []
+  # 跨域请求的其余设置：预检允许的方法与请求头 ("*" 表示按请求原样允许)、可读取的响应头、预检缓存时长，
+  # 以及是否允许携带凭据 (开启时 cors_origins 不能包含 "*")
+  cors:
+    methods: ["GET", "POST", "PUT", "DELETE"]
+    headers: ["Content-Type", "Authorization"]
+    expose_headers: ["Retry-After", "X-Request-ID"]
+    max_age: 10m0s
+    credentials: false
+  # 是否挂载 /debug/pprof/ 诊断接口，启用时必须配置 admin.password
+  pprof: false
+  # 内部诊断端口 (pprof、/debug/vars)，例如 "127.0.0.1:6060"，为空时不启用，启用时必须配置 admin.password
+  debug_addr: ""
+  # 在监听地址上提供 Prometheus 的 /metrics (连接数、收发帧数、HTTP 请求耗时等)，不需要认证
+  metrics: false
+  # 按天、租户、调用方、用户、模型聚合 token 用量与生成耗时的 SQLite 文件，供 /api/usage 与 /api/usage/export 查询；为空时不统计
+  usage_file: usage.db
+  # 可信的反向代理 (IP 或 CIDR)，例如 ["10.0.0.0/8"]；只有来自这些地址的请求才按 X-Forwarded-For、X-Real-IP 确定客户端 IP，
+  # 默认为空，一律使用连接的对端地址，客户端伪造的请求头不影响按 IP 限流；修改后需重启生效
+  trusted_proxies: []
+  # 收到 SIGTERM 后先让 /healthz 返回 503 并等待 drain_delay，便于负载均衡摘除流量，
+  # 再在 shutdown_timeout 内关闭服务器
+  drain_delay: 5s
+  shutdown_timeout: 30s
+  # /readyz 就绪检查：未就绪时返回 503，Kubernetes 不会向 Pod 转发流量
+  readiness:
+    # off: 不检查 Ollama；reachable: Ollama 可达即就绪；models: 还要求 required_models 均已拉取
+    mode: "reachable"
+    # mode 为 models 时必须存在的模型，未写标签时匹配 latest
+    # required_models: ["llama3", "qwen2:7b"]
+    interval: 10s
+    timeout: 3s
+  # /ws 插件
+  websocket:
+    read_buffer_size: 4096
+    write_buffer_size: 4096
+    # 每个连接待发送消息队列长度，写满时断开慢连接
+    send_queue: 256
+    # 允许握手的 Origin，写法同 cors_origins，其他 Origin 返回 403；不带 Origin 的客户端 (bridge、chat) 不受限制。
+    # 浏览器可直接连接时建议只列出自己的站点
+    origins: ["*"]
+    # 按优先顺序与客户端的 Sec-WebSocket-Protocol 协商子协议：ollama.v1.json 为明文 JSON 帧，
+    # ollama.v1.encrypted 要求请求帧经端到端加密 (sealed)，ollama.v1.msgpack 以 MessagePack 二进制帧收发；为空时不协商
+    subprotocols: ["ollama.v1.json", "ollama.v1.encrypted", "ollama.v1.msgpack"]
+    # 客户端未提供上述子协议时返回 400 拒绝握手；为 false 时照常连接，按 ollama.v1.json 处理
+    require_subprotocol: false
+    # 每隔 interval 向各连接发送 Ping，连续 max_missed 个间隔没有收到 Pong 或任何消息的连接被关闭；interval 为 0 时不启用，修改后需重启
+    heartbeat:
+      interval: 30s
+      max_missed: 3
+    # 消息历史：每个房间保留最近 size 条消息 (0 表示不保存)，重连的连接可在 join 帧或 history 帧中取回；
+    # backend 为 memory (重启后丢失，最多保存 max_rooms 个房间) 或 sqlite (保存在 path，重启后保留)
+    history:
+      size: 0
+      backend: memory
+      path: history.db
+      max_rooms: 1000
+    # 由 serve 直接调用 ollama.host 处理 chat 请求，浏览器无需 bridge 即可与本机模型对话；
+    # 启用后 chat 请求不再广播给 bridge，回复 (含流式分片) 只发给发出请求的连接
+    chat:
+      enabled: false
+      timeout: 5m0s
+  # OpenAI 兼容接口：/v1/chat/completions、/v1/embeddings 与 /v1/models，鉴权与 /api 相同
+  openai:
+    enabled: false
+    # 请求中的模型名到 Ollama 模型名的映射，未列出的名称原样使用，例如 {"gpt-4o": "llama3:70b"}
+    # models: {"gpt-4o": "llama3:70b", "text-embedding-3-small": "nomic-embed-text"}
+  # 按客户端限流，各项为 0 时不限制：rate 为每秒请求数 (令牌桶)，burst 为突发上限 (为 0 时取 rate 向上取整)，
+  # concurrency 为同时进行的生成数；超出时 HTTP 返回 429 与 Retry-After，/ws 回复 code 为 rate_limited 的错误帧
+  rate_limit:
+    # ip，或 token (按 Bearer 凭据区分，未携带时按 IP)
+    key: token
+    api:
+      rate: 0
+      burst: 0
+      concurrency: 0
+    openai:
+      rate: 0
+      burst: 0
+      concurrency: 0
+    # 对 /ws 连接发出的 server_to_client 请求帧计数
+    websocket:
+      rate: 0
+      burst: 0
+      concurrency: 0
+  # 每个调用方 (租户，JWT 鉴权时为租户内的 sub) 每个 UTC 自然日的对话次数与 token 上限，0 表示不限制，需配置 usage_file；
+  # 达到上限后 /api/chat、/v1/chat/completions 返回 429，/ws 的 chat 请求回复 quota_exceeded 错误帧
+  quota:
+    daily_requests: 0
+    daily_tokens: 0
+    # 按租户覆盖上述上限
+    # tenants:
+    #   acme: {daily_requests: 1000, daily_tokens: 2000000}
+  # HTTPS：cert_file 为空时使用明文 HTTP
+  tls:
+    cert_file: ""
+    key_file: ""
+    # 签发客户端证书的 CA，配置后 /ws 与 /api 要求有效的客户端证书 (mTLS)，健康检查不受影响
+    client_ca_file: ""
+  # 路由插件：插件名 -> 是否挂载与挂载路径 (为空时为 /<插件名>)，未列出的插件不挂载，修改后需重启。
+  # 内置 websocket (/ws)；其他插件由 main 包空导入后在这里启用，每项需写明 enabled
+  plugins:
+    websocket: {enabled: true, path: /ws}
+    ui: {enabled: true, path: /ui}          # 内置的网页对话界面，经 websocket 插件收发消息
+
+# bridge: 连接云端 WebSocket 并代理本地 Ollama 请求
+bridge:
+  # 云端 WebSocket 地址 (ws:// 或 wss://)，为空时仅在终端中交互式输入
+  url: ""
+  # 同时连接多个云端 (例如区域与全局控制面) 时代替 url：每个上游独立连接、心跳与重连，共用本地 Ollama；
+  # 发往上游的帧带 upstream 字段 (即 name)，/status 的 upstreams 列出各上游的连接状态；
+  # 上游的 auth (token、hmac.secret、tls) 只用于连接该上游，未配置时使用全局的 auth
+  # upstreams:
+  #   - name: regional
+  #     url: "wss://cn-east.example.com/ws"
+  #     auth:
+  #       token: "regional-token"
+  #   - name: global
+  #     url: "wss://global.example.com/ws"
+  # 本地诊断端口 (pprof、/debug/vars)，例如 "127.0.0.1:6061"，为空时不启用，启用时必须配置 admin.password
+  debug_addr: ""
+  # Prometheus 指标端口，例如 "127.0.0.1:9464"，提供 /metrics (收发帧数、各动作处理耗时、Ollama 调用耗时、缓存命中率、重连次数)；为空时不启用
+  metrics_addr: ""
+  # 状态端口，例如 "0.0.0.0:8081"，为空时不启用，不需要认证：
+  # /healthz 存活检查；/readyz 已连接云端且 Ollama 可达时返回 200，否则 503；
+  # /status 返回连接状态、最近心跳、Ollama 可达性、模型数与缓存命中情况
+  status_addr: ""
+  # Ollama 可达性探测：不可用期间请求直接返回 backend_unavailable
+  health:
+    # 健康时的探测间隔
+    interval: 15s
+    # 单次探测超时
+    timeout: 5s
+    # 不健康时按指数退避重试，从 min_backoff 开始，最长 max_backoff
+    min_backoff: 1s
+    max_backoff: 1m0s
+  # 向云端发送心跳的间隔
+  heartbeat_interval: 30s
+  # 读取超时，应大于 heartbeat_interval
+  read_timeout: 40s
+  # 与 serve 之间的帧编码：json，或 msgpack (协商 ollama.v1.msgpack 子协议，以 MessagePack 二进制帧收发，
+  # 适合嵌入向量等数值较多的响应；serve 未启用该子协议时回退为 json)
+  codec: json
+  # 收到 SIGINT/SIGTERM 后不再接受新请求 (回复 busy)，等待进行中的请求回复、待发送队列写出后再正常关闭连接；
+  # 超过该时长仍未完成的请求被取消，0 表示立即断开
+  shutdown_timeout: 30s
+  # 连接失败或断开后按指数退避重连：第 n 次等待 base * multiplier^(n-1)，不超过 max，并上下浮动 jitter；
+  # max_attempts 为最多尝试次数，budget 为一轮重连的总时长上限，超出后 bridge 退出，0 表示不限
+  reconnect:
+    max_attempts: 0
+    base: 1s
+    max: 1m0s
+    multiplier: 2
+    jitter: 0.2
+    budget: 0s
+  # Ollama 调用遇到连接错误或 5xx 时的重试，策略含义同 reconnect；流式对话只在尚未返回任何片段时重试
+  ollama_retry:
+    max_attempts: 3
+    base: 200ms
+    max: 2s
+    multiplier: 2
+    jitter: 0.2
+    budget: 10s
+  # 崩溃报告：处理请求或主循环发生 panic 时写入报告（调用栈与最近的请求，提示词已脱敏）后继续运行
+  crash:
+    dir: "crash"
+    # 报告中附带的最近请求条数
+    history: 20
+    # 报告同时 POST 到该地址，例如 "https://hooks.example.com/ollama_dev"，为空时不发送
+    webhook: ""
+    webhook_timeout: 5s
+  # 调试用：将收到的帧录制到该 JSONL 文件，可用 replay 子命令回放；文件包含完整提示词，为空时不录制
+  record_file: ""
+  # 拒绝含未知字段的帧，用于排查云端与桥接客户端的协议不一致；默认忽略未知字段
+  strict_decoding: false
+  # 记住已处理的 request_id，云端重试同一请求时重发原响应而不是重新生成；0 表示不去重
+  dedup_ttl: 5m
+  # 流式响应按云端授予的额度发送分片，额度耗尽后最多等待该时长，超时回复 timeout 错误
+  credit_timeout: 1m
+  # 连接断开时写入失败的响应暂存在队列中，重连后按原 request_id 重发；超过该条数时丢弃最早的，0 表示不暂存
+  outbox_size: 100
+  # 待发送队列持久化到该 bbolt 文件，生成完成但未送达时崩溃，重启后仍会送达；为空时仅保存在内存
+  outbox_file: outbox.db
+  # pull_model、push_model 任务及其进度保存到该 bbolt 文件，中断的拉取在重启后从已下载的部分继续；为空时仅保存在内存
+  jobs_file: jobs.db
+  # 模型传输每秒最多占用的字节数，避免拉取/推送挤占对话流量；超出时合并进度帧，只发送最新进度；0 表示不限
+  transfer_rate: 0
+  # 模型层缓存：在 addr 上代理 upstream 的 registry，拉取过的层保存在 dir 中，局域网内的其他 bridge 通过 pull_via 指向这里
+  mirror:
+    # 为空时不启用，例如 ":5001"
+    addr: ""
+    upstream: https://registry.ollama.ai
+    dir: mirror
+    # 缓存的最大字节数，超出时删除最久未使用的层；0 表示不限
+    max_size: 0
+    # 通过该 mirror 拉取模型，例如 http://10.0.0.5:5001；为空时直接从 registry 拉取
+    pull_via: ""
+  # 并发处理请求的工作池：fast_actions 中的轻量动作走独立的快速通道，不会排在长时间的对话之后
+  workers:
+    # 快速通道的并发数，0 表示轻量动作同样走普通通道
+    fast: 2
+    # 其余请求的并发数，0 表示不启用工作池，请求逐个处理
+    normal: 4
+    # 每条通道的排队上限，超出时回复 busy 错误
+    queue: 64
+    fast_actions: [list_model, version, pull_model, push_model, get_job, list_jobs, delete_model, copy_model, show_model, ps, job_status, job_cancel]
+  # 任务队列：actions 中的请求按 params.priority (high、normal、batch) 排队，最多 concurrency 个同时执行，
+  # 排队期间回复 status 为 queued 的帧告知位置，可用 job_status、job_cancel 查询与取消
+  job_queue:
+    # 0 表示不启用队列
+    concurrency: 0
+    # 排队中的任务数上限，超出时回复 busy 错误
+    max_size: 256
+    # 排队中的任务保存到该文件，重启后继续执行；为空时仅保存在内存。文件包含完整提示词，端到端加密的请求只保存密文
+    file: queue.db
+    actions: [chat, generate]
+  # Ollama 调用的熔断：连续失败 failures 次后直接回复 circuit_open 错误，open_timeout 后放行 half_open_probes 个探测调用，成功则恢复
+  breaker:
+    # 0 表示不熔断
+    failures: 5
+    open_timeout: 30s
+    half_open_probes: 1
+  # 每个模型同时进行的对话数，超出时排队最多 queue_timeout，仍未轮到则回复 model_busy 错误
+  model_concurrency:
+    # 0 表示不限
+    default: 0
+    # 按模型单独设置，例如 {"llama3:70b": 1}
+    # models: {"llama3:70b": 1}
+    queue_timeout: 0s
+  # 各动作的处理时限，超时后取消对 Ollama 的调用并回复 timeout 错误；0 表示不限
+  timeouts:
+    default: 5m
+    # pull_model、push_model 为后台任务每次运行的时限；create_model 量化大模型时耗时较长
+    actions: {list_model: 30s, version: 10s, pull_model: 6h, push_model: 6h, create_model: 30m}
+  # Lua 脚本：dir 中的每个 *.lua 返回一个 table，可包含 before/after 钩子与 actions 自定义动作
+  scripts:
+    # 为空时不启用，例如 "scripts"
+    dir: ""
+    # 单次钩子或动作调用的时限，超时回复 timeout 错误
+    timeout: 100ms
+  # WASM 变换模块：dir 中的每个 *.wasm 可导出 transform_request/transform_response，接口见 internal/wasm
+  wasm:
+    # 为空时不启用，例如 "transforms"
+    dir: ""
+    # 单次变换的时限，超时回复 timeout 错误
+    timeout: 100ms
+    # 每个模块实例的内存上限 (MiB)
+    memory_limit: 64
+  # chat 请求携带 params.session_id 时保存对话历史，云端只需发送新消息
+  sessions:
+    # 每个会话保留的最近轮数，0 表示不启用会话
+    max_turns: 20
+    # 历史的估算 token 上限，超出时丢弃最早的轮次，0 表示不限
+    max_tokens: 4096
+    # 会话空闲超过该时长后丢弃
+    idle_ttl: 30m0s
+  # chat 消息 images 中的图片：base64 (可带 data:image/...;base64, 前缀)，或 fetch_urls 开启时的 http(s) 地址
+  images:
+    # 一次请求中的图片总数上限，0 表示不接受图片
+    max_count: 4
+    # 单张图片的字节数上限
+    max_bytes: 10485760
+    # 允许的图片类型，按内容识别而非扩展名
+    allowed_types: [image/jpeg, image/png, image/webp]
+    # 由 bridge 下载图片地址；下载发生在 bridge 所在网络，开启前确认云端可信
+    fetch_urls: false
+    fetch_timeout: 10s
+  # 分块文件传输：云端经 file_upload 下发 Modelfile 等文件，经 file_download 取回日志与产物，支持断点续传
+  files:
+    # 收发文件的目录，路径不能越出该目录；为空时不启用
+    dir: ""
+    # 单个上传文件的字节数上限
+    max_size: 268435456
+    # file_download 每个分块的字节数，base64 编码后应小于 chunking.max_frame_size
+    chunk_size: 262144
+  # 模型允许列表与各模型 options 的范围 (YAML)，修改后自动重新加载；为空时不限制
+  # serve 的 /api、/v1 与 /ws 本地对话同样按该策略检查
+  policy_file: ""
+
+# wstest: 支持分组的 WebSocket 测试服务器
+wstest:
+  addr: ":8080"
+  # 心跳间隔，同时按该间隔检查失联连接
+  heartbeat_interval: 30s
+  # 连续错过该数量的心跳 (没有 Pong 或消息) 时断开连接
+  max_missed: 3
+
+# client: 命令行测试客户端
+client:
+  url: "ws://localhost:8080/ws"
+  # 握手时携带的 Origin 请求头
+  origin: "http://allowed-origin.com"
+
+# chat: 交互式对话
+chat:
+  # 为空时使用 models.default
+  model: ""
+  # 服务器 WebSocket 地址，为空时直接使用本地 Ollama
+  server: ""
+  # 随请求上报的用户名，服务器按用户统计 token 用量
+  user: ""
+
+# 鉴权
+auth:
+  # Bearer Token，桥接客户端与服务器必须一致
+  token: "valid-token"
+  # 多租户：每个租户使用独立的 Token，/ws 与 /api 按 Token 识别租户，不同租户的连接互相隔离
+  # /ws 与 /api 必须携带租户或 auth.token 的 Token；两者均未配置时允许匿名访问，所有连接属于 default 租户
+  # tenants:
+  #   - id: acme
+  #     token: "acme-token"
+  # 握手签名：secret 非空时 bridge、client 与 chat 在连接时用 HMAC-SHA256 签名，服务器拒绝签名无效、
+  # 时间偏差超过 max_skew 或重放的握手；两端需配置相同的 secret
+  hmac:
+    secret: ""
+    max_skew: 5m0s
+  # JWT：algorithm 为 HS256 (secret) 或 RS256 (public_key_file) 时，serve 的 /ws、/api 与 /v1 除上述 Token 外还接受 JWT，
+  # 且必须携带有效凭据；令牌的 tenant 声明为所属租户 (缺省为 default)，roles 声明为角色：user 可调用接口，
+  # admin 还可访问 /admin 与 /debug (与 admin 账号的 Basic Auth 二选一)
+  jwt:
+    algorithm: ""
+    secret: ""
+    public_key_file: ""
+    # 非空时要求令牌的 iss 一致、aud 包含该值
+    issuer: ""
+    audience: ""
+    # 校验 exp、nbf 时允许的时钟偏差
+    leeway: 30s
+  # 连接 wss:// 服务器时使用的客户端证书，服务器配置了 server.tls.client_ca_file 时必需
+  tls:
+    cert_file: ""
+    key_file: ""
+    # 校验服务器证书的 CA，为空时使用系统根证书
+    ca_file: ""
+
+# 缓存
+cache:
+  # 模型列表等缓存的过期时间，例如 30s、2m、1h
+  ttl: 2m0s
+  # 清理过期条目的间隔
+  cleanup_interval: 10m0s
+  # 缓存后端：memory 仅在进程内；redis 可由多个 bridge 共享；bolt 保存到本地文件，重启后保留
+  backend: memory
+  # 键的命名空间，多个 bridge 共享同一 redis 时用于区分
+  namespace: ollama_dev
+  redis:
+    addr: localhost:6379
+    # 为空时不认证
+    password: ""
+    db: 0
+    # 连接与单条命令的超时
+    timeout: 2s
+    # 单个条目编码后的字节数上限，更大的值不写入，读到更大的回复时视为错误并断开连接
+    max_entry_size: 16777216
+  bolt:
+    path: cache.db
+  # chat 与 generate 的结果缓存 (进程内)：只缓存 options.temperature 为 0 的请求，相同的模型、消息或提示词
+  # 与 options 直接返回缓存的回复，命中时不计 token；请求的 params.no_cache 为 true 时跳过；按租户与调用方隔离
+  generations:
+    # 最多缓存的回复数，超出时淘汰最久未使用的；0 表示不启用
+    max_entries: 0
+    ttl: 10m0s
+
+# 模型
+models:
+  # 未指定模型时使用的默认模型，例如 llama3
+  default: ""
+
+# 本地 Ollama
+ollama:
+  # Ollama 地址，例如 "http://127.0.0.1:11434"，为空时读取 OLLAMA_HOST 环境变量
+  host: ""
+  # 多个 Ollama 地址，非空时代替 host：请求优先发往已有该模型的可用后端，连接失败时切换到下一个，
+  # 心跳 (bridge.health) 同时探测各后端并刷新其模型列表
+  hosts: []
+  # 多个后端之间的调度：least_loaded (进行中请求最少)、round_robin (轮换) 或 first (按顺序，前面的不可用时才用后面的)
+  balance: least_loaded
+
+# 功能开关，管理员可通过 PUT /admin/features/<name> 在运行期临时修改，重启或重新加载配置后恢复此处取值
+features:
+  # 流式输出
+  streaming: false
+  # 端到端加密
+  e2e_encryption: false
+  # 语义缓存
+  semantic_cache: false
+  # 检索增强生成
+  rag: false
+
+# WebSocket 抓包：记录 serve 的 /ws 与 bridge 收发的帧，按 log.redact 脱敏，非 JSON 帧只记录长度
+# serve 通过 GET /admin/capture 下载，bridge 通过诊断端口的 /debug/capture 下载
+capture:
+  enabled: false
+  # 内存中保留的最近帧数
+  buffer: 1000
+  # 同时追加写入该 JSONL 文件，为空时不写文件
+  file: ""
+  # 单帧保留的最大字节数，0 表示不截断
+  max_frame_size: 4096
+
+# 大消息分片：bridge 与 chat 发送超过 max_frame_size 的消息时拆分为多帧，接收方重组，避免超出代理的帧大小限制
+chunking:
+  # 单帧最大字节数，0 表示不拆分
+  max_frame_size: 524288
+  # 重组后消息的最大字节数，0 表示不限
+  max_message_size: 67108864
+  # 分片未在该时长内收齐时丢弃
+  timeout: 1m
+
+# WebSocket 消息压缩 (permessage-deflate)：serve、bridge 与 chat 各按本端配置协商，双方都启用时生效，
+# 适合较长的对话历史与嵌入向量；level 为 1 (最快) 到 9 (最小)，小于 threshold 字节的帧不压缩
+compression:
+  enabled: false
+  level: 1
+  threshold: 1024
+
+# 过期数据清理：定期删除过期的去重记录与超时未收齐的分片，回收条数见 /debug/vars 的 janitor
+janitor:
+  # 清理间隔
+  interval: 1m
+
+# 端到端加密：features.e2e_encryption 开启时 chat 加密请求的 params，bridge 解密后加密响应的 data，serve 只转发密文
+# 每个租户使用独立的密钥，密文绑定 tenant_id 与 request_id，其他租户的帧无法解密
+e2e:
+  # 密钥文件，由 ollama_dev keys rotate <tenant> 生成，chat 与 bridge 需使用相同的文件
+  keystore: keys.json
+  # 密钥文件的口令，文件以口令派生的密钥 (Argon2id) 加密保存，建议通过 OLLAMA_DEV_E2E_PASSPHRASE 设置
+  # 未加密的密钥文件不会被读取
+  passphrase: ""
+  # chat 加密请求使用的租户，应与 auth.token 所属的租户一致
+  tenant: default
+  # aes-256-gcm 或 chacha20-poly1305，为空时按硬件自动选择
+  cipher: ""
+
+# serve 告警：按 interval 评估规则，触发时记录日志，并通过 webhook 与邮件通知；同一规则在 cooldown 内只通知一次
+alert:
+  # 规则评估间隔，0 表示不启用告警
+  interval: 30s
+  cooldown: 15m
+  # 告警以 JSON POST 到该地址，为空时不发送
+  webhook: ""
+  webhook_timeout: 5s
+  email:
+    # SMTP 服务器 host:port，为空时不发送邮件
+    smtp_addr: ""
+    # 为空时不进行 SMTP 认证
+    username: ""
+    password: ""
+    from: ""
+    # to: ["ops@example.com"]
+  # window 内返回给调用方的错误数（HTTP 错误响应与 bridge 错误帧）达到 threshold，threshold 为 0 时不检查
+  error_rate:
+    threshold: 50
+    window: 5m
+  # window 内断开的 WebSocket 连接数达到 threshold，通常意味着 bridge 反复重连
+  reconnect_storm:
+    threshold: 20
+    window: 1m
+  # Ollama 持续不可达超过该时长，0 表示不检查；依赖 server.readiness 的探测，mode 为 off 时不生效
+  backend_down: 5m
+
+# bridge 定时任务：schedule 为 cron 表达式 (分 时 日 月 周，按本地时区)，或 @hourly、@daily、@every 10m；
+# 同一任务上一次尚未结束时跳过本次，运行状态见诊断端口的 /debug/schedule
+schedule:
+  # jobs:
+  #   # 刷新模型列表缓存，使 list_model 的加载状态保持最新
+  #   - name: refresh-models
+  #     task: refresh_models
+  #     schedule: "@every 1m"
+  #   # 工作日上班前把常用模型加载到内存
+  #   - name: warm-llama3
+  #     task: warm_models
+  #     schedule: "50 8 * * 1-5"
+  #     models: ["llama3"]
+  #   # 每月轮换端到端加密密钥，保留最新 2 个版本
+  #   - name: rotate-keys
+  #     task: rotate_keys
+  #     schedule: "@monthly"
+  #     tenants: ["default"]
+  #     keep: 2
+
+# 管理员账号，用于 pprof 等诊断接口的 Basic Auth
+admin:
+  username: "admin"
+  password: ""
+
+# 日志
+log:
+  # debug、info、warn、error
+  level: "info"
+  # text 或 json
+  format: "text"
+  # stdout、stderr 或文件路径，可同时输出到多个目标
+  outputs:
+    - "stdout"
+  # 文件输出按大小轮转：超过 max_size MiB 时重命名为 <path>.1 (已有的依次后移) 并新建文件，
+  # 最多保留 max_backups 个历史文件；max_size 为 0 时不轮转
+  rotate:
+    max_size: 100
+    max_backups: 5
+  # 输出前脱敏的字段名（Token、提示词等），不区分大小写
+  redact: ["token", "authorization", "password", "prompt", "content", "messages"]
+  # 重复消息采样：每个周期内同一消息先输出 initial 条，之后每 thereafter 条输出一条
+  # initial 为 0 时不采样，Error 级别始终输出
+  sampling:
+    initial: 10
+    thereafter: 100
+    interval: 1m0s
+  # 重复错误折叠：窗口内同一级别、消息与 error 的 Warn/Error 日志只输出第一条，
+  # 窗口结束后输出一条带 suppressed 计数的摘要，0 表示不折叠
+  throttle:
+    window: 1m0s
+
+# OpenTelemetry 链路追踪：HTTP 请求、WebSocket 消息处理与每次 Ollama 调用各生成一个 span，
+# span 带有 request_id 属性；endpoint 为空时不启用
+tracing:
+  # OTLP/HTTP 接收地址，例如 "http://localhost:4318"
+  endpoint: ""
+  service_name: "ollama_dev"
+  # 采样比例 (0~1)，请求的 traceparent 已带有采样决定时沿用
+  sample_ratio: 1
+
+# 错误与日志消息的默认语言：zh 或 en，HTTP 接口优先按请求的 Accept-Language 选择
+lang: "zh"
+`
+
+// WriteDefault 将带注释的默认配置写入 path，force 为 false 时不覆盖已有文件
+func WriteDefault(path string, force bool) error {
+	flag := os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+	if !force {
+		flag |= os.O_EXCL
+	}
+
+	f, err := os.OpenFile(path, flag, 0o600)
+	if err != nil {
+		if errors.Is(err, os.ErrExist) {
+			return fmt.Errorf("配置文件已存在: %s (使用 --force 覆盖)", path)
+		}
+		return fmt.Errorf("创建配置文件失败: %w", err)
+	}
+	defer f.Close()
+
+	if _, err := f.WriteString(defaultTemplate); err != nil {
+		return fmt.Errorf("写入配置文件失败: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,663 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"net"
+	"net/url"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"ollama_dev/internal/scheduler"
+	"ollama_dev/internal/util"
+)
+
+// tenantIDPattern 租户标识的格式
+var tenantIDPattern = regexp.MustCompile(`^[a-z0-9_-]+$`)
+
+// FieldError 单个配置项的校验错误
+type FieldError struct {
+	Field   string // 配置项路径，例如 server.addr
+	Message string // 可操作的修复建议
+}
+
+func (e FieldError) Error() string {
+	return e.Field + ": " + e.Message
+}
+
+// ValidationErrors 配置校验失败时返回的全部错误
+type ValidationErrors []FieldError
+
+func (e ValidationErrors) Error() string {
+	var b strings.Builder
+	b.WriteString("配置校验失败:")
+	for _, fe := range e {
+		b.WriteString("\n  - ")
+		b.WriteString(fe.Error())
+	}
+	return b.String()
+}
+
+// Validate 检查配置取值是否合法
+func (c *Config) Validate() error {
+	var errs ValidationErrors
+	add := func(field, format string, args ...any) {
+		errs = append(errs, FieldError{Field: field, Message: fmt.Sprintf(format, args...)})
+	}
+
+	checkAddr := func(field, addr string) {
+		if _, _, err := net.SplitHostPort(addr); err != nil {
+			add(field, "无效的监听地址 %q，应为 host:port 形式，例如 \":8080\"", addr)
+		}
+	}
+	checkWSURL := func(field, raw string, required bool) {
+		if raw == "" {
+			if required {
+				add(field, "不能为空，例如 \"ws://localhost:8080/ws\"")
+			}
+			return
+		}
+		u, err := url.Parse(raw)
+		if err != nil || (u.Scheme != "ws" && u.Scheme != "wss") || u.Host == "" {
+			add(field, "无效的 WebSocket 地址 %q，应以 ws:// 或 wss:// 开头并包含主机名", raw)
+		}
+	}
+
+	checkAddr("server.addr", c.Server.Addr)
+	if c.Server.DrainDelay < 0 {
+		add("server.drain_delay", "不能为负数")
+	}
+	if c.Server.ShutdownTimeout <= 0 {
+		add("server.shutdown_timeout", "必须大于 0，例如 \"30s\"")
+	}
+	readiness := c.Server.Readiness
+	switch readiness.Mode {
+	case ReadinessOff, ReadinessReachable:
+	case ReadinessModels:
+		if len(readiness.RequiredModels) == 0 {
+			add("server.readiness.required_models", "mode 为 models 时至少需要一个模型，例如 [\"llama3\"]")
+		}
+	default:
+		add("server.readiness.mode", "未知的检查模式 %q，可选 off、reachable、models", readiness.Mode)
+	}
+	if readiness.Mode != ReadinessOff && (readiness.Interval <= 0 || readiness.Timeout <= 0) {
+		add("server.readiness", "interval 与 timeout 必须大于 0，例如 interval: 10s")
+	}
+	ws := c.Server.WebSocket
+	if ws.ReadBufferSize <= 0 || ws.WriteBufferSize <= 0 || ws.SendQueue <= 0 {
+		add("server.websocket", "read_buffer_size、write_buffer_size 与 send_queue 必须大于 0")
+	}
+	if ws.History.Size < 0 {
+		add("server.websocket.history.size", "不能为负数，0 表示不保存")
+	}
+	for _, origin := range ws.Origins {
+		if !validOriginPattern(origin) {
+			add("server.websocket.origins", "无效的 Origin %q，写法同 server.cors_origins", origin)
+		}
+	}
+	for _, p := range ws.Subprotocols {
+		if p != "ollama.v1.json" && p != "ollama.v1.encrypted" && p != "ollama.v1.msgpack" {
+			add("server.websocket.subprotocols", "不支持的子协议 %q，可选 ollama.v1.json、ollama.v1.encrypted、ollama.v1.msgpack", p)
+		}
+	}
+	if ws.RequireSubprotocol && len(ws.Subprotocols) == 0 {
+		add("server.websocket.require_subprotocol", "需同时配置 subprotocols")
+	}
+	if ws.Heartbeat.Interval < 0 {
+		add("server.websocket.heartbeat.interval", "不能为负数，0 表示不启用")
+	}
+	if ws.Heartbeat.Interval > 0 && ws.Heartbeat.MaxMissed <= 0 {
+		add("server.websocket.heartbeat.max_missed", "必须大于 0，例如 max_missed: 3")
+	}
+	if ws.Chat.Enabled && ws.Chat.Timeout <= 0 {
+		add("server.websocket.chat.timeout", "必须大于 0，例如 \"5m\"")
+	}
+	switch ws.History.Backend {
+	case "memory":
+		if ws.History.MaxRooms <= 0 {
+			add("server.websocket.history.max_rooms", "必须大于 0，例如 max_rooms: 1000")
+		}
+	case "sqlite":
+		if ws.History.Path == "" {
+			add("server.websocket.history.path", "使用 sqlite 保存历史时不能为空")
+		}
+	case "":
+		add("server.websocket.history.backend", "不能为空，可选 memory、sqlite")
+	}
+	for _, name := range slices.Sorted(maps.Keys(c.Server.OpenAI.Models)) {
+		if model := c.Server.OpenAI.Models[name]; name == "" || model == "" {
+			add("server.openai.models", "模型名不能为空：%q -> %q", name, model)
+		}
+	}
+	for _, origin := range c.Server.CorsOrigins {
+		if !validOriginPattern(origin) {
+			add("server.cors_origins", "无效的 Origin %q，应为 \"*\" 或 scheme://host[:port] 形式，可用一个 * 通配主机名的一段或端口", origin)
+		} else if origin == "*" && c.Server.CORS.Credentials {
+			add("server.cors_origins", "server.cors.credentials 开启时不能包含 \"*\"，请列出允许的 Origin")
+		}
+	}
+	for _, proxy := range c.Server.TrustedProxies {
+		if !validProxy(proxy) {
+			add("server.trusted_proxies", "无效的地址 %q，应为 IP 或 CIDR", proxy)
+		}
+	}
+	if c.Server.CORS.MaxAge < 0 {
+		add("server.cors.max_age", "不能为负数")
+	}
+	if c.Bridge.Codec != "json" && c.Bridge.Codec != "msgpack" {
+		add("bridge.codec", "只能是 json 或 msgpack，当前为 %q", c.Bridge.Codec)
+	}
+	if c.Compression.Enabled && (c.Compression.Level < 1 || c.Compression.Level > 9) {
+		add("compression.level", "应为 1 到 9，当前为 %d", c.Compression.Level)
+	}
+	if c.Compression.Threshold < 0 {
+		add("compression.threshold", "不能为负数")
+	}
+	rl := c.Server.RateLimit
+	if rl.Key != "ip" && rl.Key != "token" {
+		add("server.rate_limit.key", "只能是 ip 或 token，当前为 %q", rl.Key)
+	}
+	checkRule := func(field string, rule RateLimitRule) {
+		if rule.Rate < 0 || rule.Burst < 0 || rule.Concurrency < 0 {
+			add(field, "rate、burst 与 concurrency 不能为负数")
+		}
+	}
+	checkRule("server.rate_limit.api", rl.API)
+	checkRule("server.rate_limit.openai", rl.OpenAI)
+	checkRule("server.rate_limit.websocket", rl.WebSocket)
+	checkQuota := func(field string, l QuotaLimit) {
+		if l.DailyRequests < 0 || l.DailyTokens < 0 {
+			add(field, "daily_requests 与 daily_tokens 不能为负数")
+		}
+	}
+	quota := c.Server.Quota
+	checkQuota("server.quota", QuotaLimit{DailyRequests: quota.DailyRequests, DailyTokens: quota.DailyTokens})
+	limited := quota.DailyRequests > 0 || quota.DailyTokens > 0
+	for _, name := range slices.Sorted(maps.Keys(quota.Tenants)) {
+		l := quota.Tenants[name]
+		checkQuota("server.quota.tenants."+name, l)
+		limited = limited || l.DailyRequests > 0 || l.DailyTokens > 0
+	}
+	if limited && c.Server.UsageFile == "" {
+		add("server.quota", "按用量限制需要配置 server.usage_file")
+	}
+	tlsCfg := c.Server.TLS
+	if (tlsCfg.CertFile == "") != (tlsCfg.KeyFile == "") {
+		add("server.tls", "cert_file 与 key_file 需同时配置")
+	}
+	if tlsCfg.ClientCAFile != "" && tlsCfg.CertFile == "" {
+		add("server.tls.client_ca_file", "校验客户端证书需要启用 TLS，请同时配置 cert_file 与 key_file")
+	}
+	for _, name := range slices.Sorted(maps.Keys(c.Server.Plugins)) {
+		if p := c.Server.Plugins[name]; p.Path != "" && !strings.HasPrefix(p.Path, "/") {
+			add("server.plugins."+name+".path", "必须以 / 开头，例如 /%s", name)
+		}
+	}
+	checkWSURL("bridge.url", c.Bridge.URL, false)
+	if len(c.Bridge.Upstreams) > 0 {
+		if c.Bridge.URL != "" {
+			add("bridge.url", "与 bridge.upstreams 只能配置其一")
+		}
+		names := map[string]bool{}
+		for i, u := range c.Bridge.Upstreams {
+			field := fmt.Sprintf("bridge.upstreams[%d]", i)
+			if u.Name == "" {
+				add(field+".name", "不能为空")
+			} else if names[u.Name] {
+				add(field+".name", "重复的上游名称 %q", u.Name)
+			}
+			names[u.Name] = true
+			checkWSURL(field+".url", u.URL, true)
+			if a := u.Auth; a != nil {
+				if a.Token == "" {
+					add(field+".auth.token", "配置 auth 时不能为空")
+				}
+				if (a.TLS.CertFile == "") != (a.TLS.KeyFile == "") {
+					add(field+".auth.tls", "cert_file 与 key_file 需同时配置")
+				}
+			}
+		}
+	}
+	if c.Bridge.HeartbeatInterval <= 0 {
+		add("bridge.heartbeat_interval", "必须大于 0，例如 heartbeat_interval: 30s")
+	}
+	validateRetry("bridge.reconnect", c.Bridge.Reconnect, add)
+	validateRetry("bridge.ollama_retry", c.Bridge.OllamaRetry, add)
+	if c.Bridge.ReadTimeout <= c.Bridge.HeartbeatInterval {
+		add("bridge.read_timeout", "必须大于 heartbeat_interval (%s)，否则心跳间隙会触发读取超时", c.Bridge.HeartbeatInterval)
+	}
+	if c.Bridge.ShutdownTimeout < 0 {
+		add("bridge.shutdown_timeout", "不能为负数，0 表示立即断开")
+	}
+	if c.Bridge.DedupTTL < 0 {
+		add("bridge.dedup_ttl", "不能为负数，0 表示不去重")
+	}
+	if c.Bridge.CreditTimeout <= 0 {
+		add("bridge.credit_timeout", "必须大于 0，例如 credit_timeout: 1m")
+	}
+	if c.Bridge.OutboxSize < 0 {
+		add("bridge.outbox_size", "不能为负数，0 表示不暂存")
+	}
+	if s := c.Bridge.Scripts; s.Dir != "" && s.Timeout <= 0 {
+		add("bridge.scripts.timeout", "启用 dir 时必须大于 0，例如 timeout: 100ms")
+	}
+	if w := c.Bridge.WASM; w.Dir != "" {
+		if w.Timeout <= 0 {
+			add("bridge.wasm.timeout", "启用 dir 时必须大于 0，例如 timeout: 100ms")
+		}
+		if w.MemoryLimit <= 0 || w.MemoryLimit > 4096 {
+			add("bridge.wasm.memory_limit", "必须在 1 到 4096 (MiB) 之间")
+		}
+	}
+	if s := c.Bridge.Sessions; s.MaxTurns < 0 || s.MaxTokens < 0 {
+		add("bridge.sessions", "max_turns 与 max_tokens 不能为负数")
+	} else if s.MaxTurns > 0 && s.IdleTTL <= 0 {
+		add("bridge.sessions.idle_ttl", "启用会话时必须大于 0，例如 idle_ttl: 30m")
+	}
+	if img := c.Bridge.Images; img.MaxCount < 0 {
+		add("bridge.images.max_count", "不能为负数，0 表示不接受图片")
+	} else if img.MaxCount > 0 {
+		if img.MaxBytes <= 0 {
+			add("bridge.images.max_bytes", "必须大于 0，例如 10485760")
+		}
+		if len(img.AllowedTypes) == 0 {
+			add("bridge.images.allowed_types", "不能为空，例如 [image/jpeg, image/png]")
+		}
+		for _, t := range img.AllowedTypes {
+			if !strings.HasPrefix(t, "image/") {
+				add("bridge.images.allowed_types", "%q 不是图片类型", t)
+			}
+		}
+		if img.FetchURLs && img.FetchTimeout <= 0 {
+			add("bridge.images.fetch_timeout", "下载图片时必须大于 0，例如 10s")
+		}
+	}
+	if files := c.Bridge.Files; files.Dir != "" && (files.MaxSize <= 0 || files.ChunkSize <= 0) {
+		add("bridge.files", "启用 dir 时 max_size 与 chunk_size 必须大于 0，例如 chunk_size: 262144")
+	}
+	if c.Bridge.TransferRate < 0 {
+		add("bridge.transfer_rate", "不能为负数，0 表示不限")
+	}
+	if mirror := c.Bridge.Mirror; mirror.Addr != "" && (mirror.Upstream == "" || mirror.Dir == "") {
+		add("bridge.mirror", "启用 addr 时 upstream 与 dir 不能为空")
+	}
+	if w := c.Bridge.Workers; w.Fast < 0 || w.Normal < 0 || w.Queue < 0 {
+		add("bridge.workers", "fast、normal 与 queue 不能为负数")
+	}
+	if q := c.Bridge.JobQueue; q.Concurrency < 0 {
+		add("bridge.job_queue.concurrency", "不能为负数，0 表示不启用队列")
+	} else if q.Concurrency > 0 {
+		if q.MaxSize <= 0 {
+			add("bridge.job_queue.max_size", "启用队列时必须大于 0，例如 max_size: 256")
+		}
+		if len(q.Actions) == 0 {
+			add("bridge.job_queue.actions", "启用队列时不能为空，例如 [chat, generate]")
+		}
+	}
+	if b := c.Bridge.Breaker; b.Failures < 0 || b.Failures > 0 && (b.OpenTimeout <= 0 || b.HalfOpenProbes <= 0) {
+		add("bridge.breaker", "failures 不能为负数，启用时 open_timeout 与 half_open_probes 必须大于 0")
+	}
+	if mc := c.Bridge.ModelConcurrency; mc.Default < 0 || mc.QueueTimeout < 0 {
+		add("bridge.model_concurrency", "default 与 queue_timeout 不能为负数")
+	}
+	for _, model := range slices.Sorted(maps.Keys(c.Bridge.ModelConcurrency.Models)) {
+		if c.Bridge.ModelConcurrency.Models[model] <= 0 {
+			add("bridge.model_concurrency.models", "%s 的上限必须大于 0", model)
+		}
+	}
+	if c.Bridge.Timeouts.Default < 0 {
+		add("bridge.timeouts.default", "不能为负数，0 表示不限")
+	}
+	for _, action := range slices.Sorted(maps.Keys(c.Bridge.Timeouts.Actions)) {
+		if c.Bridge.Timeouts.Actions[action] < 0 {
+			add("bridge.timeouts.actions", "%s 的时限不能为负数，0 表示不限", action)
+		}
+	}
+	if c.Bridge.Mirror.MaxSize < 0 {
+		add("bridge.mirror.max_size", "不能为负数，0 表示不限")
+	}
+	if via := c.Bridge.Mirror.PullVia; via != "" && !strings.HasPrefix(via, "http://") && !strings.HasPrefix(via, "https://") {
+		add("bridge.mirror.pull_via", "应以 http:// 或 https:// 开头，例如 http://10.0.0.5:5001")
+	}
+	health := c.Bridge.Health
+	if health.Interval <= 0 || health.Timeout <= 0 || health.MinBackoff <= 0 {
+		add("bridge.health", "interval、timeout 与 min_backoff 必须大于 0，例如 interval: 15s")
+	}
+	if health.MaxBackoff < health.MinBackoff {
+		add("bridge.health.max_backoff", "不能小于 min_backoff (%s)", health.MinBackoff)
+	}
+	if c.Ollama.Host != "" {
+		if u, err := url.Parse(c.Ollama.Host); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			add("ollama.host", "无效的 Ollama 地址 %q，应以 http:// 或 https:// 开头，例如 \"http://127.0.0.1:11434\"", c.Ollama.Host)
+		}
+	}
+	crash := c.Bridge.Crash
+	if crash.Dir == "" {
+		add("bridge.crash.dir", "不能为空，例如 \"crash\"")
+	}
+	if crash.History < 0 {
+		add("bridge.crash.history", "不能为负数")
+	}
+	if crash.Webhook != "" {
+		if u, err := url.Parse(crash.Webhook); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			add("bridge.crash.webhook", "无效的地址 %q，应以 http:// 或 https:// 开头", crash.Webhook)
+		}
+		if crash.WebhookTimeout <= 0 {
+			add("bridge.crash.webhook_timeout", "必须大于 0，例如 \"5s\"")
+		}
+	}
+	if c.Capture.Buffer < 0 || c.Capture.MaxFrameSize < 0 {
+		add("capture", "buffer 与 max_frame_size 不能为负数")
+	}
+	if c.Capture.Enabled && c.Capture.Buffer == 0 && c.Capture.File == "" {
+		add("capture", "启用抓包时 buffer 与 file 至少配置一项")
+	}
+	if c.Chunking.MaxFrameSize != 0 && c.Chunking.MaxFrameSize < 1024 {
+		add("chunking.max_frame_size", "不能小于 1024，0 表示不拆分")
+	}
+	if c.Chunking.MaxMessageSize < 0 || c.Chunking.Timeout <= 0 {
+		add("chunking", "max_message_size 不能为负数，timeout 必须大于 0")
+	}
+	if _, err := util.ParseCipher(c.E2E.Cipher); err != nil {
+		add("e2e.cipher", "应为 aes-256-gcm 或 chacha20-poly1305，为空时自动选择")
+	}
+	if !tenantIDPattern.MatchString(c.E2E.Tenant) {
+		add("e2e.tenant", "只能包含小写字母、数字、- 与 _，且不能为空")
+	}
+	if c.Features.E2EEncryption && c.E2E.Keystore == "" {
+		add("e2e.keystore", "启用 features.e2e_encryption 时不能为空")
+	}
+	if c.Features.E2EEncryption && c.E2E.Passphrase == "" {
+		add("e2e.passphrase", "启用 features.e2e_encryption 时不能为空，可通过 OLLAMA_DEV_E2E_PASSPHRASE 设置")
+	}
+	if c.Janitor.Interval <= 0 {
+		add("janitor.interval", "必须大于 0，例如 interval: 1m")
+	}
+	validateAlert(c.Alert, add)
+	names := map[string]bool{}
+	for i, j := range c.Schedule.Jobs {
+		field := fmt.Sprintf("schedule.jobs[%d]", i)
+		if j.Name == "" || names[j.Name] {
+			add(field+".name", "不能为空且不能重复")
+		}
+		names[j.Name] = true
+		if _, err := scheduler.Parse(j.Schedule); err != nil {
+			add(field+".schedule", "%v", err)
+		}
+		switch j.Task {
+		case TaskRefreshModels:
+		case TaskWarmModels:
+			if len(j.Models) == 0 {
+				add(field+".models", "warm_models 至少需要一个模型")
+			}
+		case TaskRotateKeys:
+			if len(j.Tenants) == 0 {
+				add(field+".tenants", "rotate_keys 至少需要一个租户")
+			}
+			if j.Keep < 0 {
+				add(field+".keep", "不能为负数，0 表示全部保留")
+			}
+		default:
+			add(field+".task", "未知的任务 %q，可选 %s、%s、%s", j.Task, TaskRefreshModels, TaskWarmModels, TaskRotateKeys)
+		}
+	}
+	checkAddr("wstest.addr", c.WSTest.Addr)
+	if c.WSTest.HeartbeatInterval <= 0 {
+		add("wstest.heartbeat_interval", "必须大于 0，例如 \"30s\"")
+	}
+	if c.WSTest.MaxMissed <= 0 {
+		add("wstest.max_missed", "必须大于 0，例如 max_missed: 3")
+	}
+	checkWSURL("client.url", c.Client.URL, true)
+	checkWSURL("chat.server", c.Chat.Server, false)
+
+	if c.Server.DebugAddr != "" {
+		checkAddr("server.debug_addr", c.Server.DebugAddr)
+	}
+	if c.Bridge.DebugAddr != "" {
+		checkAddr("bridge.debug_addr", c.Bridge.DebugAddr)
+	}
+	if c.Bridge.StatusAddr != "" {
+		checkAddr("bridge.status_addr", c.Bridge.StatusAddr)
+	}
+	if (c.Server.Pprof || c.Server.DebugAddr != "" || c.Bridge.DebugAddr != "") && (c.Admin.Username == "" || c.Admin.Password == "") {
+		add("admin", "启用 server.pprof、server.debug_addr 或 bridge.debug_addr 时必须配置 admin.username 与 admin.password")
+	}
+
+	if c.Auth.Token == "" {
+		add("auth.token", "不能为空，桥接客户端与服务器需配置相同的 Token")
+	}
+	tenantIDs, tenantTokens := map[string]bool{}, map[string]bool{c.Auth.Token: true}
+	for i, t := range c.Auth.Tenants {
+		field := fmt.Sprintf("auth.tenants[%d]", i)
+		if !tenantIDPattern.MatchString(t.ID) {
+			add(field+".id", "只能包含小写字母、数字、- 与 _，且不能为空")
+		} else if tenantIDs[t.ID] || t.ID == "default" {
+			add(field+".id", "租户 %q 重复，default 为 auth.token 保留", t.ID)
+		}
+		if t.Token == "" || tenantTokens[t.Token] {
+			add(field+".token", "不能为空，且不能与 auth.token 或其他租户相同")
+		}
+		tenantIDs[t.ID], tenantTokens[t.Token] = true, true
+	}
+	if c.Auth.HMAC.Secret != "" && c.Auth.HMAC.MaxSkew <= 0 {
+		add("auth.hmac.max_skew", "启用签名时必须大于 0，例如 \"5m\"")
+	}
+	switch jwt := c.Auth.JWT; jwt.Algorithm {
+	case "":
+	case "HS256":
+		if len(jwt.Secret) < 32 {
+			add("auth.jwt.secret", "HS256 的密钥至少 32 字节")
+		}
+	case "RS256":
+		if jwt.PublicKeyFile == "" {
+			add("auth.jwt.public_key_file", "RS256 需要 PEM 公钥文件")
+		}
+	default:
+		add("auth.jwt.algorithm", "未知的算法 %q，可选 HS256、RS256", jwt.Algorithm)
+	}
+	if c.Auth.JWT.Leeway < 0 {
+		add("auth.jwt.leeway", "不能为负数")
+	}
+	if (c.Auth.TLS.CertFile == "") != (c.Auth.TLS.KeyFile == "") {
+		add("auth.tls", "cert_file 与 key_file 需同时配置")
+	}
+	switch c.Ollama.Balance {
+	case "least_loaded", "round_robin", "first":
+	default:
+		add("ollama.balance", "未知的调度策略 %q，可选 least_loaded、round_robin、first", c.Ollama.Balance)
+	}
+	if c.Cache.TTL <= 0 {
+		add("cache.ttl", "必须大于 0，当前为 %s，例如 \"2m\"", c.Cache.TTL)
+	}
+	if c.Cache.CleanupInterval <= 0 {
+		add("cache.cleanup_interval", "必须大于 0，例如 \"10m\"")
+	}
+	switch c.Cache.Backend {
+	case "redis":
+		if c.Cache.Redis.Addr == "" {
+			add("cache.redis.addr", "使用 redis 缓存时不能为空，例如 \"localhost:6379\"")
+		}
+		if c.Cache.Redis.Timeout <= 0 {
+			add("cache.redis.timeout", "必须大于 0，例如 \"2s\"")
+		}
+		if c.Cache.Redis.MaxEntrySize <= 0 {
+			add("cache.redis.max_entry_size", "必须大于 0，例如 16777216")
+		}
+	case "bolt":
+		if c.Cache.Bolt.Path == "" {
+			add("cache.bolt.path", "使用 bolt 缓存时不能为空")
+		}
+	case "":
+		add("cache.backend", "不能为空，可选 memory、redis、bolt")
+	}
+	if c.Cache.Generations.MaxEntries < 0 {
+		add("cache.generations.max_entries", "不能为负数，0 表示不启用")
+	}
+	if c.Cache.Generations.MaxEntries > 0 && c.Cache.Generations.TTL <= 0 {
+		add("cache.generations.ttl", "启用结果缓存时必须大于 0，例如 \"10m\"")
+	}
+
+	switch strings.ToLower(c.Log.Level) {
+	case "debug", "info", "warn", "error":
+	default:
+		add("log.level", "未知的日志级别 %q，可选 debug、info、warn、error", c.Log.Level)
+	}
+	switch strings.ToLower(c.Log.Format) {
+	case "text", "json":
+	default:
+		add("log.format", "未知的日志格式 %q，可选 text、json", c.Log.Format)
+	}
+	if c.Log.Sampling.Initial < 0 || c.Log.Sampling.Thereafter < 0 {
+		add("log.sampling", "initial 与 thereafter 不能为负数")
+	}
+	if c.Log.Sampling.Initial > 0 && c.Log.Sampling.Interval <= 0 {
+		add("log.sampling.interval", "启用采样时必须大于 0，例如 \"1m\"")
+	}
+	if c.Log.Throttle.Window < 0 {
+		add("log.throttle.window", "不能为负数，0 表示不折叠")
+	}
+	if c.Log.Rotate.MaxSize < 0 || c.Log.Rotate.MaxBackups < 0 {
+		add("log.rotate", "max_size 与 max_backups 不能为负数")
+	}
+	if len(c.Log.Outputs) == 0 {
+		add("log.outputs", "至少需要一个输出目标，例如 [\"stdout\"]")
+	}
+	if t := c.Tracing; t.Endpoint != "" {
+		if u, err := url.Parse(t.Endpoint); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			add("tracing.endpoint", "无效的地址 %q，应以 http:// 或 https:// 开头", t.Endpoint)
+		}
+		if t.SampleRatio < 0 || t.SampleRatio > 1 {
+			add("tracing.sample_ratio", "必须在 0 到 1 之间，当前为 %v", t.SampleRatio)
+		}
+	}
+
+	switch strings.ToLower(c.Lang) {
+	case "zh", "en":
+	default:
+		add("lang", "未知的语言 %q，可选 zh、en", c.Lang)
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// validateAlert 校验告警配置，interval 为 0（未启用）时不检查其余字段
+func validateAlert(a AlertConfig, add func(field, format string, args ...any)) {
+	if a.Interval < 0 {
+		add("alert.interval", "不能为负数，0 表示不启用告警")
+	}
+	if a.Interval <= 0 {
+		return
+	}
+	if a.Cooldown <= 0 {
+		add("alert.cooldown", "必须大于 0，例如 \"15m\"")
+	}
+	if a.Webhook != "" {
+		if u, err := url.Parse(a.Webhook); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			add("alert.webhook", "无效的地址 %q，应以 http:// 或 https:// 开头", a.Webhook)
+		}
+		if a.WebhookTimeout <= 0 {
+			add("alert.webhook_timeout", "必须大于 0，例如 \"5s\"")
+		}
+	}
+	if a.Email.SMTPAddr != "" {
+		if _, _, err := net.SplitHostPort(a.Email.SMTPAddr); err != nil {
+			add("alert.email.smtp_addr", "无效的地址 %q，应为 host:port", a.Email.SMTPAddr)
+		}
+		if a.Email.From == "" || len(a.Email.To) == 0 {
+			add("alert.email", "配置 smtp_addr 时必须填写 from 与 to")
+		}
+	}
+	rules := []struct {
+		name string
+		rule RateRule
+	}{{"error_rate", a.ErrorRate}, {"reconnect_storm", a.ReconnectStorm}}
+	for _, r := range rules {
+		if r.rule.Threshold < 0 {
+			add("alert."+r.name+".threshold", "不能为负数，0 表示不检查")
+		}
+		if r.rule.Threshold > 0 && r.rule.Window <= 0 {
+			add("alert."+r.name+".window", "必须大于 0，例如 \"5m\"")
+		}
+	}
+	if a.BackendDown < 0 {
+		add("alert.backend_down", "不能为负数，0 表示不检查")
+	}
+}
+
+// validateRetry 校验重试策略
+func validateRetry(field string, r RetryConfig, add func(field, format string, args ...any)) {
+	if r.MaxAttempts < 0 || r.Budget < 0 {
+		add(field, "max_attempts 与 budget 不能为负数，0 表示不限")
+	}
+	if r.Base <= 0 || r.Max < r.Base {
+		add(field, "base 必须大于 0 且不大于 max，例如 base: 1s, max: 1m")
+	}
+	if r.Multiplier < 1 {
+		add(field+".multiplier", "不能小于 1")
+	}
+	if r.Jitter < 0 || r.Jitter >= 1 {
+		add(field+".jitter", "应在 [0, 1) 之间，例如 0.2")
+	}
+}
+
+// ValidateFile 严格解析配置文件（拒绝未知字段）并校验取值
+func ValidateFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("读取配置文件失败: %w", err)
+	}
+
+	cfg := Default()
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(cfg); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("解析配置文件失败（请检查缩进与字段名拼写）: %w", err)
+	}
+
+	return cfg.Validate()
+}
+
+// validOriginPattern 判断 cors_origins 的一项是否有效："*"，或不带路径的 scheme://host[:port]，
+// 其中可有一个 * 通配主机名的一段 (例如 https://*.example.com) 或端口 (例如 http://localhost:*)
+func validOriginPattern(pattern string) bool {
+	if pattern == "*" {
+		return true
+	}
+	if strings.Count(pattern, "*") > 1 {
+		return false
+	}
+	// 替换通配符后按普通 Origin 解析，通配端口时以 0 代替
+	stub := "x"
+	if strings.HasSuffix(pattern, ":*") {
+		stub = "0"
+	}
+	u, err := url.Parse(strings.Replace(pattern, "*", stub, 1))
+	if err != nil || u.Scheme == "" || u.Host == "" || u.Path != "" || u.RawQuery != "" || u.User != nil {
+		return false
+	}
+	if i := strings.Index(pattern, "*"); i >= 0 {
+		host := strings.TrimPrefix(pattern, u.Scheme+"://")
+		return strings.HasPrefix(host, "*.") || strings.HasSuffix(host, ":*")
+	}
+	return true
+}
+
+// validProxy 判断 trusted_proxies 的一项是否为 IP 或 CIDR，与 gin.Engine.SetTrustedProxies 接受的写法相同
+func validProxy(s string) bool {
+	if strings.Contains(s, "/") {
+		_, _, err := net.ParseCIDR(s)
+		return err == nil
+	}
+	return net.ParseIP(s) != nil
+}
@@ -0,0 +1,230 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDefaultTemplateMatchesDefault(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ollama_dev.yaml")
+	if err := WriteDefault(path, false); err != nil {
+		t.Fatalf("WriteDefault failed: %v", err)
+	}
+
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if !reflect.DeepEqual(cfg, Default()) {
+		t.Errorf("template does not match Default():\n%+v\n%+v", cfg, Default())
+	}
+	if err := ValidateFile(path); err != nil {
+		t.Errorf("default template should be valid: %v", err)
+	}
+
+	// 未指定 force 时不覆盖已有文件
+	if err := WriteDefault(path, false); err == nil {
+		t.Error("expected an error when overwriting without force, but got none")
+	}
+}
+
+func TestValidateReportsFields(t *testing.T) {
+	cfg := Default()
+	cfg.Server.Addr = "8080"
+	cfg.Bridge.URL = "http://example.com"
+	cfg.Auth.Token = ""
+	cfg.Cache.TTL = 0
+	cfg.Server.TrustedProxies = []string{"10.0.0.0/8", "proxy.internal"}
+
+	err := cfg.Validate()
+	var verrs ValidationErrors
+	if !errors.As(err, &verrs) {
+		t.Fatalf("expected ValidationErrors, got %v", err)
+	}
+
+	fields := map[string]bool{}
+	for _, fe := range verrs {
+		fields[fe.Field] = true
+	}
+	for _, want := range []string{"server.addr", "bridge.url", "auth.token", "cache.ttl", "server.trusted_proxies"} {
+		if !fields[want] {
+			t.Errorf("expected error for %s, got %v", want, err)
+		}
+	}
+}
+
+func TestValidateTenants(t *testing.T) {
+	cfg := Default()
+	cfg.Auth.Tenants = []TenantConfig{
+		{ID: "acme", Token: "acme-token"},
+		{ID: "acme", Token: "other-token"},
+		{ID: "Bad ID", Token: cfg.Auth.Token},
+	}
+
+	err := cfg.Validate()
+	var verrs ValidationErrors
+	if !errors.As(err, &verrs) {
+		t.Fatalf("expected ValidationErrors, got %v", err)
+	}
+	fields := map[string]bool{}
+	for _, fe := range verrs {
+		fields[fe.Field] = true
+	}
+	for _, want := range []string{"auth.tenants[1].id", "auth.tenants[2].id", "auth.tenants[2].token"} {
+		if !fields[want] {
+			t.Errorf("expected error for %s, got %v", want, err)
+		}
+	}
+	if fields["auth.tenants[0].id"] || fields["auth.tenants[0].token"] {
+		t.Errorf("first tenant is valid, got %v", err)
+	}
+}
+
+func TestValidateUpstreams(t *testing.T) {
+	cfg := Default()
+	cfg.Bridge.URL = "ws://example.com/ws"
+	cfg.Bridge.Upstreams = []UpstreamConfig{
+		{Name: "regional", URL: "wss://cn-east.example.com/ws"},
+		{Name: "regional", URL: "wss://global.example.com/ws", Auth: &UpstreamAuthConfig{TLS: ClientTLSConfig{CertFile: "bridge.pem"}}},
+		{URL: "http://example.com"},
+	}
+
+	err := cfg.Validate()
+	var verrs ValidationErrors
+	if !errors.As(err, &verrs) {
+		t.Fatalf("expected ValidationErrors, got %v", err)
+	}
+	fields := map[string]bool{}
+	for _, fe := range verrs {
+		fields[fe.Field] = true
+	}
+	for _, want := range []string{"bridge.url", "bridge.upstreams[1].name", "bridge.upstreams[1].auth.token", "bridge.upstreams[1].auth.tls", "bridge.upstreams[2].name", "bridge.upstreams[2].url"} {
+		if !fields[want] {
+			t.Errorf("expected error for %s, got %v", want, err)
+		}
+	}
+	if fields["bridge.upstreams[0].name"] || fields["bridge.upstreams[0].url"] {
+		t.Errorf("first upstream is valid, got %v", err)
+	}
+	if targets := cfg.Bridge.Targets(); len(targets) != 3 {
+		t.Errorf("expected upstreams to replace bridge.url, got %v", targets)
+	}
+}
+
+func TestValidateAlert(t *testing.T) {
+	cfg := Default()
+	cfg.Alert.Webhook = "ftp://example.com"
+	cfg.Alert.Email.SMTPAddr = "smtp.example.com"
+	cfg.Alert.ErrorRate.Window = 0
+
+	err := cfg.Validate()
+	var verrs ValidationErrors
+	if !errors.As(err, &verrs) {
+		t.Fatalf("expected ValidationErrors, got %v", err)
+	}
+	fields := map[string]bool{}
+	for _, fe := range verrs {
+		fields[fe.Field] = true
+	}
+	for _, want := range []string{"alert.webhook", "alert.email.smtp_addr", "alert.email", "alert.error_rate.window"} {
+		if !fields[want] {
+			t.Errorf("expected error for %s, got %v", want, err)
+		}
+	}
+
+	// 未启用告警时不检查其余字段
+	cfg.Alert.Interval = 0
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("disabled alerting should not be validated, got %v", err)
+	}
+}
+
+func TestValidateCorsOrigins(t *testing.T) {
+	cfg := Default()
+	cfg.Server.CorsOrigins = []string{"*", "https://app.example.com", "https://*.example.com", "http://localhost:*"}
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("valid origins rejected: %v", err)
+	}
+	for _, origin := range []string{"example.com", "https://example.com/path", "https://a*.example.com", "https://*.*.example.com"} {
+		cfg.Server.CorsOrigins = []string{origin}
+		if err := cfg.Validate(); err == nil {
+			t.Errorf("expected error for %q", origin)
+		}
+	}
+	// 允许携带凭据时不能允许全部 Origin
+	cfg.Server.CorsOrigins = []string{"*"}
+	cfg.Server.CORS.Credentials = true
+	if err := cfg.Validate(); err == nil {
+		t.Error("expected error for credentials with wildcard origin")
+	}
+}
+
+func TestValidateScheduleJobs(t *testing.T) {
+	cfg := Default()
+	cfg.Schedule.Jobs = []JobConfig{
+		{Name: "refresh", Task: TaskRefreshModels, Schedule: "@every 1m"},
+		{Name: "refresh", Task: TaskWarmModels, Schedule: "0 25 * * *"},
+		{Name: "rotate", Task: "purge", Schedule: "@daily"},
+	}
+
+	err := cfg.Validate()
+	var verrs ValidationErrors
+	if !errors.As(err, &verrs) {
+		t.Fatalf("expected ValidationErrors, got %v", err)
+	}
+	fields := map[string]bool{}
+	for _, fe := range verrs {
+		fields[fe.Field] = true
+	}
+	for _, want := range []string{"schedule.jobs[1].name", "schedule.jobs[1].schedule", "schedule.jobs[1].models", "schedule.jobs[2].task"} {
+		if !fields[want] {
+			t.Errorf("expected error for %s, got %v", want, err)
+		}
+	}
+	if fields["schedule.jobs[0].name"] || fields["schedule.jobs[0].schedule"] {
+		t.Errorf("first job is valid, got %v", err)
+	}
+}
+
+func TestValidateTracing(t *testing.T) {
+	cfg := Default()
+	cfg.Tracing.Endpoint = "localhost:4318"
+	cfg.Tracing.SampleRatio = 2
+
+	err := cfg.Validate()
+	var verrs ValidationErrors
+	if !errors.As(err, &verrs) {
+		t.Fatalf("expected ValidationErrors, got %v", err)
+	}
+	fields := map[string]bool{}
+	for _, fe := range verrs {
+		fields[fe.Field] = true
+	}
+	for _, want := range []string{"tracing.endpoint", "tracing.sample_ratio"} {
+		if !fields[want] {
+			t.Errorf("expected error for %s, got %v", want, err)
+		}
+	}
+
+	cfg = Default()
+	cfg.Tracing.Endpoint = "http://localhost:4318"
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("valid tracing config rejected: %v", err)
+	}
+}
+
+func TestValidateFileUnknownField(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "bad.yaml")
+	if err := os.WriteFile(path, []byte("server:\n  adr: \":8080\"\n"), 0o600); err != nil {
+		t.Fatalf("write config failed: %v", err)
+	}
+
+	err := ValidateFile(path)
+	if err == nil || !strings.Contains(err.Error(), "adr") {
+		t.Errorf("expected unknown field error mentioning adr, got %v", err)
+	}
+}
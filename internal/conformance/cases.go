@@ -0,0 +1,344 @@
+package conformance
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"ollama_dev/internal/apperr"
+	"ollama_dev/internal/bridge"
+)
+
+// Cases 返回全部用例，按运行顺序；chat 等用例依赖 list_model 选出的模型
+func Cases() []Case {
+	return []Case{
+		{Name: "handshake", Description: "直接连接时先上报 capabilities；version 请求返回当前协议版本的 done 帧", run: handshake},
+		{Name: "list_model", Description: "list_model 返回模型列表，并选出后续用例使用的模型", run: listModel},
+		{Name: "chat", Description: "非流式对话返回一个携带回复的 done 帧", run: chat},
+		{Name: "streaming", Description: "流式对话逐片段返回 streaming 帧，done 帧携带完整回复", run: streaming},
+		{Name: "flow_control", Description: "额度耗尽后暂停发送分片，追加额度后继续", run: flowControl},
+		{Name: "cancellation", Description: "不再追加额度时，桥接客户端在 credit_timeout 后以 timeout 错误终止流", run: cancellation},
+		{Name: "cancel", Description: "cancel 帧以相同 request_id 取消进行中的流，被取消的请求返回 cancelled 错误帧", run: cancelStream},
+		{Name: "duplicate", Description: "以相同 request_id 重发的请求得到与原响应相同的 done 帧", run: duplicate},
+		{Name: "error_unknown_action", Description: "未知动作返回 unknown_action 错误帧", run: errorUnknownAction},
+		{Name: "error_bad_frame", Description: "无法解析的参数返回带原 request_id 的 bad_frame 错误帧", run: errorBadFrame},
+		{Name: "error_invalid_params", Description: "缺少 model_name 的对话返回 invalid_params 错误帧", run: errorInvalidParams},
+	}
+}
+
+// countPrompt 回复较长的提示，用于观察流控
+const countPrompt = "Count from 1 to 50, one number per line, with no other text."
+
+func handshake(ctx context.Context, s *session) error {
+	if s.conn.accepted {
+		caps, err := s.conn.Capabilities(ctx)
+		if err != nil {
+			return fmt.Errorf("连接后未收到 capabilities 帧")
+		}
+		if caps.Protocol < 1 {
+			return fmt.Errorf("capabilities.protocol 应为正数，实际为 %d", caps.Protocol)
+		}
+		for _, action := range []string{"list_model", "chat"} {
+			if !slices.Contains(caps.Actions, action) {
+				return fmt.Errorf("capabilities.actions 缺少 %s: %v", action, caps.Actions)
+			}
+		}
+	}
+	env, err := s.exchange(ctx, s.request("version"), nil)
+	if err != nil {
+		return err
+	}
+	if err := expectDone(env); err != nil {
+		return err
+	}
+	if len(env.Data) == 0 || bytes.Equal(env.Data, []byte("null")) {
+		return fmt.Errorf("version 响应缺少 data")
+	}
+	return nil
+}
+
+func listModel(ctx context.Context, s *session) error {
+	env, err := s.exchange(ctx, s.request("list_model"), nil)
+	if err != nil {
+		return err
+	}
+	if err := expectDone(env); err != nil {
+		return err
+	}
+	var models []bridge.ModelInfo
+	if err := json.Unmarshal(env.Data, &models); err != nil {
+		return fmt.Errorf("data 应为模型数组: %w", err)
+	}
+	if s.model != "" {
+		for _, m := range models {
+			if m.Name == s.model || m.Name == s.model+":latest" {
+				return nil
+			}
+		}
+		return fmt.Errorf("模型列表中没有 %s", s.model)
+	}
+	if len(models) == 0 {
+		return fmt.Errorf("模型列表为空，对话用例将跳过")
+	}
+	s.model = models[0].Name
+	return nil
+}
+
+// chatRequest 构造对话请求
+func (s *session) chatRequest(prompt string, stream bool, credits int) *bridge.CloudRequest {
+	req := s.request("chat")
+	req.Params.ModelName = s.model
+	req.Params.Messages = []bridge.ChatMessage{{Role: "user", Content: prompt}}
+	req.Params.Stream = stream
+	req.Params.Credits = credits
+	return req
+}
+
+// credit 为流式请求追加额度
+func (s *session) credit(id string, n int) error {
+	req := s.request(bridge.ActionCredit)
+	req.RequestID = id
+	req.Params.Credits = n
+	return s.conn.Send(req)
+}
+
+func (s *session) needModel() error {
+	if s.model == "" {
+		return skip("没有可用的模型，使用 --model 指定")
+	}
+	return nil
+}
+
+func chat(ctx context.Context, s *session) error {
+	if err := s.needModel(); err != nil {
+		return err
+	}
+	env, err := s.exchange(ctx, s.chatRequest("Reply with the single word: pong", false, 0), nil)
+	if err != nil {
+		return err
+	}
+	if err := expectDone(env); err != nil {
+		return err
+	}
+	content, err := chatContent(env)
+	if err != nil {
+		return err
+	}
+	if content == "" {
+		return fmt.Errorf("done 帧的 message.content 为空")
+	}
+	return nil
+}
+
+func streaming(ctx context.Context, s *session) error {
+	if err := s.needModel(); err != nil {
+		return err
+	}
+	// 每收到两个分片追加两个额度，同时检验 credit 帧被接受
+	const window = 4
+	req := s.chatRequest(countPrompt, true, window)
+	var streamed strings.Builder
+	chunks := 0
+	env, err := s.exchange(ctx, req, func(env *bridge.Envelope) error {
+		content, err := chatContent(env)
+		if err != nil {
+			return err
+		}
+		streamed.WriteString(content)
+		if chunks++; chunks%2 == 0 {
+			return s.credit(req.RequestID, 2)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if err := expectDone(env); err != nil {
+		return err
+	}
+	if chunks == 0 {
+		return fmt.Errorf("done 之前没有收到 streaming 帧")
+	}
+	content, err := chatContent(env)
+	if err != nil {
+		return err
+	}
+	if content != streamed.String() {
+		return fmt.Errorf("done 帧的回复（%d 字节）与分片拼接的结果（%d 字节）不一致", len(content), streamed.Len())
+	}
+	return nil
+}
+
+// firstChunk 发送只有一个额度的流式请求，等待第一个 streaming 帧；回复在一个分片内结束时跳过用例
+func (s *session) firstChunk(ctx context.Context, req *bridge.CloudRequest) error {
+	if err := s.conn.Send(req); err != nil {
+		return fmt.Errorf("发送请求失败: %w", err)
+	}
+	env, err := s.await(ctx, req.RequestID)
+	if err != nil {
+		return err
+	}
+	switch env.Status {
+	case bridge.StatusStreaming:
+		return nil
+	case "done":
+		return skip("回复没有分片，无法观察流控")
+	default:
+		return fmt.Errorf("应先收到 streaming 帧，实际为 %q: %s", env.Status, errorString(env))
+	}
+}
+
+func flowControl(ctx context.Context, s *session) error {
+	if err := s.needModel(); err != nil {
+		return err
+	}
+	req := s.chatRequest(countPrompt, true, 1)
+	if err := s.firstChunk(ctx, req); err != nil {
+		return err
+	}
+
+	pause, cancel := context.WithTimeout(ctx, s.opts.PauseWindow)
+	env, err := s.await(pause, req.RequestID)
+	cancel()
+	switch {
+	case err == nil && env.Status == bridge.StatusStreaming:
+		return fmt.Errorf("额度耗尽后仍发送了 streaming 帧")
+	case err == nil && env.Status == "done":
+		return skip("回复在额度耗尽前结束，无法观察暂停")
+	case err == nil:
+		return fmt.Errorf("暂停期间收到 %q 帧: %s", env.Status, errorString(env))
+	case ctx.Err() != nil:
+		return err
+	}
+
+	if err := s.credit(req.RequestID, 1<<20); err != nil {
+		return fmt.Errorf("发送额度失败: %w", err)
+	}
+	for {
+		env, err := s.await(ctx, req.RequestID)
+		if err != nil {
+			return fmt.Errorf("追加额度后未继续: %w", err)
+		}
+		if env.Status != bridge.StatusStreaming {
+			return expectDone(env)
+		}
+	}
+}
+
+func cancellation(ctx context.Context, s *session) error {
+	if s.opts.CreditWait <= 0 {
+		return skip("使用 --credit-wait 启用，需大于桥接客户端的 bridge.credit_timeout")
+	}
+	if err := s.needModel(); err != nil {
+		return err
+	}
+	req := s.chatRequest(countPrompt, true, 1)
+	if err := s.firstChunk(ctx, req); err != nil {
+		return err
+	}
+
+	wait, cancel := context.WithTimeout(ctx, s.opts.CreditWait)
+	defer cancel()
+	env, err := s.await(wait, req.RequestID)
+	switch {
+	case err != nil:
+		return fmt.Errorf("%s 内未终止流: %w", s.opts.CreditWait, err)
+	case env.Status == bridge.StatusStreaming:
+		return fmt.Errorf("额度耗尽后仍发送了 streaming 帧")
+	case env.Status == "done":
+		return skip("回复在额度耗尽前结束，无法观察终止")
+	}
+	return expectError(env, apperr.CodeTimeout)
+}
+
+func cancelStream(ctx context.Context, s *session) error {
+	if err := s.needModel(); err != nil {
+		return err
+	}
+	// 只授予 1 个额度，流在第一个分片后暂停，取消时一定仍在进行
+	req := s.chatRequest(countPrompt, true, 1)
+	if err := s.firstChunk(ctx, req); err != nil {
+		return err
+	}
+	cancel := s.request(bridge.ActionCancel)
+	cancel.RequestID = req.RequestID
+	if err := s.conn.Send(cancel); err != nil {
+		return err
+	}
+	env, err := s.await(ctx, req.RequestID)
+	switch {
+	case err != nil:
+		return err
+	case env.Status == "done":
+		return skip("回复在取消前结束，无法观察取消")
+	}
+	return expectError(env, apperr.CodeCancelled)
+}
+
+func duplicate(ctx context.Context, s *session) error {
+	if err := s.needModel(); err != nil {
+		return err
+	}
+	// 对话的回复不确定，两次相同说明第二次重发了原响应而没有重新生成
+	req := s.chatRequest("Write one random word.", false, 0)
+	first, err := s.exchange(ctx, req, nil)
+	if err != nil {
+		return err
+	}
+	if err := expectDone(first); err != nil {
+		return err
+	}
+	second, err := s.exchange(ctx, req, nil)
+	if err != nil {
+		return err
+	}
+	if err := expectDone(second); err != nil {
+		return err
+	}
+	if !bytes.Equal(first.Data, second.Data) {
+		return fmt.Errorf("重发的响应与原响应不同，请求可能被重新执行")
+	}
+	return nil
+}
+
+func errorUnknownAction(ctx context.Context, s *session) error {
+	env, err := s.exchange(ctx, s.request("conformance_unknown_action"), nil)
+	if err != nil {
+		return err
+	}
+	return expectError(env, apperr.CodeUnknownAction)
+}
+
+func errorBadFrame(ctx context.Context, s *session) error {
+	req := s.request("chat")
+	raw := fmt.Sprintf(`{"v":%d,"type":%q,"action":"chat","request_id":%q,"params":"not an object"}`, bridge.ProtocolVersion, bridge.TypeServerToClient, req.RequestID)
+	if err := s.conn.SendRaw([]byte(raw)); err != nil {
+		return fmt.Errorf("发送请求失败: %w", err)
+	}
+	env, err := s.await(ctx, req.RequestID)
+	if err != nil {
+		if errors.Is(err, errClosed) {
+			return fmt.Errorf("收到无效的帧后断开了连接")
+		}
+		return err
+	}
+	return expectError(env, apperr.CodeBadFrame)
+}
+
+func errorInvalidParams(ctx context.Context, s *session) error {
+	req := s.request("chat")
+	req.Params.Messages = []bridge.ChatMessage{{Role: "user", Content: "hi"}}
+	env, err := s.exchange(ctx, req, nil)
+	if err != nil {
+		return err
+	}
+	// 后端不可用时桥接客户端在校验参数之前拒绝请求
+	if expectError(env, apperr.CodeBackendUnavailable) == nil {
+		return skip("Ollama 后端不可用，无法检查参数校验")
+	}
+	return expectError(env, apperr.CodeInvalidParams)
+}
@@ -0,0 +1,192 @@
+package conformance
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+
+	"ollama_dev/internal/bridge"
+)
+
+// 用例结果
+const (
+	StatusPass = "pass"
+	StatusFail = "fail"
+	StatusSkip = "skip"
+)
+
+// Options 运行参数
+type Options struct {
+	Model       string        // 对话用例使用的模型，为空时使用 list_model 返回的第一个
+	Timeout     time.Duration // 每个用例的时限
+	PauseWindow time.Duration // 流式额度耗尽后确认桥接客户端暂停发送的观察时长
+	CreditWait  time.Duration // 等待桥接客户端因额度耗尽终止流的时长，应大于其 bridge.credit_timeout；0 表示跳过 cancellation
+	Run         []string      // 只运行指定的用例，为空时运行全部
+}
+
+// Result 单个用例的结果
+type Result struct {
+	Case    string        `json:"case"`
+	Status  string        `json:"status"`
+	Detail  string        `json:"detail,omitempty"`
+	Elapsed time.Duration `json:"elapsed"`
+}
+
+// Case 一组脚本化的协议交互
+type Case struct {
+	Name        string
+	Description string
+	run         func(ctx context.Context, s *session) error
+}
+
+// skipError 用例无法在当前环境下判定
+type skipError struct{ reason string }
+
+func (e *skipError) Error() string { return e.reason }
+
+func skip(format string, args ...any) error {
+	return &skipError{reason: fmt.Sprintf(format, args...)}
+}
+
+// Run 在 conn 上按顺序运行用例，report 在每个用例结束时调用，可为 nil
+func Run(ctx context.Context, conn *Conn, opts Options, report func(Result)) []Result {
+	s := &session{conn: conn, opts: opts, model: opts.Model}
+	var results []Result
+	for _, c := range Cases() {
+		if len(opts.Run) > 0 && !slices.Contains(opts.Run, c.Name) {
+			continue
+		}
+		r := s.runCase(ctx, c)
+		results = append(results, r)
+		if report != nil {
+			report(r)
+		}
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	return results
+}
+
+func (s *session) runCase(ctx context.Context, c Case) Result {
+	ctx, cancel := context.WithTimeout(ctx, s.opts.Timeout)
+	defer cancel()
+	start := time.Now()
+	err := c.run(ctx, s)
+	r := Result{Case: c.Name, Status: StatusPass, Elapsed: time.Since(start)}
+	var se *skipError
+	switch {
+	case errors.As(err, &se):
+		r.Status, r.Detail = StatusSkip, se.reason
+	case err != nil:
+		r.Status, r.Detail = StatusFail, err.Error()
+	}
+	return r
+}
+
+// session 用例之间共享的状态
+type session struct {
+	conn  *Conn
+	opts  Options
+	model string // list_model 用例确定的对话模型
+}
+
+// request 构造带新 request_id 的请求
+func (s *session) request(action string) *bridge.CloudRequest {
+	return &bridge.CloudRequest{V: bridge.ProtocolVersion, Type: bridge.TypeServerToClient, Action: action, RequestID: uuid.NewString()}
+}
+
+// await 返回 request_id 为 id 的下一帧，其他请求遗留的帧直接丢弃
+func (s *session) await(ctx context.Context, id string) (*bridge.Envelope, error) {
+	for {
+		env, err := s.conn.Next(ctx)
+		if err != nil {
+			if errors.Is(err, context.DeadlineExceeded) {
+				return nil, fmt.Errorf("等待响应超时")
+			}
+			return nil, err
+		}
+		if env.RequestID == id {
+			return env, nil
+		}
+	}
+}
+
+// exchange 发送请求并读取响应帧直到 done 或 error，streaming 帧交给 onStream（可为 nil）；返回最后一帧
+func (s *session) exchange(ctx context.Context, req *bridge.CloudRequest, onStream func(*bridge.Envelope) error) (*bridge.Envelope, error) {
+	if err := s.conn.Send(req); err != nil {
+		return nil, fmt.Errorf("发送请求失败: %w", err)
+	}
+	for {
+		env, err := s.await(ctx, req.RequestID)
+		if err != nil {
+			return nil, err
+		}
+		switch env.Status {
+		case bridge.StatusStreaming:
+			if onStream == nil {
+				return nil, fmt.Errorf("非流式请求收到了 streaming 帧")
+			}
+			if err := onStream(env); err != nil {
+				return nil, err
+			}
+		case bridge.StatusDuplicate, bridge.StatusQueued:
+			// 上一次相同 request_id 的请求仍在处理中，或请求在任务队列中等待，继续等待
+		default:
+			return env, nil
+		}
+	}
+}
+
+// expectDone 要求响应为 done 帧且携带当前协议版本
+func expectDone(env *bridge.Envelope) error {
+	if env.Status == "error" {
+		return fmt.Errorf("收到错误响应: %s", errorString(env))
+	}
+	if env.Status != "done" {
+		return fmt.Errorf("status 应为 done，实际为 %q", env.Status)
+	}
+	if env.V != bridge.ProtocolVersion {
+		return fmt.Errorf("v 应为 %d，实际为 %d", bridge.ProtocolVersion, env.V)
+	}
+	return nil
+}
+
+// expectError 要求响应为错误码为 code 的错误帧
+func expectError(env *bridge.Envelope, code string) error {
+	if env.Status != "error" {
+		return fmt.Errorf("应返回 %s 错误，实际 status 为 %q", code, env.Status)
+	}
+	var data bridge.ErrorData
+	if err := json.Unmarshal(env.Data, &data); err != nil {
+		return fmt.Errorf("解析错误帧的 data 失败: %w", err)
+	}
+	if data.Category == "" || data.Code != code {
+		return fmt.Errorf("错误码应为 %s，实际为 %s", code, errorString(env))
+	}
+	return nil
+}
+
+func errorString(env *bridge.Envelope) string {
+	var data bridge.ErrorData
+	if err := json.Unmarshal(env.Data, &data); err != nil {
+		return string(env.Data)
+	}
+	return fmt.Sprintf("%s/%s %s", data.Category, data.Code, data.Message)
+}
+
+// chatContent 解析对话帧的 data.message.content
+func chatContent(env *bridge.Envelope) (string, error) {
+	var data struct {
+		Message bridge.ChatMessage `json:"message"`
+	}
+	if err := json.Unmarshal(env.Data, &data); err != nil {
+		return "", fmt.Errorf("解析对话响应失败: %w", err)
+	}
+	return data.Message.Content, nil
+}
@@ -0,0 +1,103 @@
+package conformance
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"ollama_dev/internal/bridge"
+	"ollama_dev/internal/config"
+)
+
+// fakeOllama 每次对话返回不同的回复，流式对话逐行返回
+type fakeOllama struct {
+	calls atomic.Int64
+}
+
+func (f *fakeOllama) Chat(ctx context.Context, req bridge.ChatRequest) (bridge.Reply, error) {
+	return bridge.Reply{Content: fmt.Sprintf("reply %d", f.calls.Add(1))}, nil
+}
+
+func (f *fakeOllama) Embed(ctx context.Context, req bridge.EmbedRequest) (bridge.Embeddings, error) {
+	return bridge.Embeddings{Vectors: make([][]float32, len(req.Input))}, nil
+}
+
+func (f *fakeOllama) ChatStream(ctx context.Context, req bridge.ChatRequest, onChunk func(string) error) (bridge.Reply, error) {
+	var b strings.Builder
+	for i := 1; i <= 10; i++ {
+		chunk := fmt.Sprintf("%d\n", i)
+		if err := onChunk(chunk); err != nil {
+			return bridge.Reply{}, err
+		}
+		b.WriteString(chunk)
+	}
+	return bridge.Reply{Content: b.String()}, nil
+}
+
+func (f *fakeOllama) Generate(ctx context.Context, req bridge.GenerateRequest, onChunk func(string) error) (bridge.Reply, error) {
+	return bridge.Reply{Content: fmt.Sprintf("reply %d", f.calls.Add(1))}, nil
+}
+
+func (f *fakeOllama) ListModels(ctx context.Context) ([]bridge.ModelInfo, error) {
+	return []bridge.ModelInfo{{Name: "llama3:latest"}}, nil
+}
+
+func (f *fakeOllama) Heartbeat(ctx context.Context) error { return nil }
+
+func TestSuitePassesAgainstBridge(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	cfg := config.Default()
+	cfg.Bridge.CreditTimeout = 300 * time.Millisecond
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ws := bridge.NewWebSocketClient(nil)
+	server := bridge.NewServer(ws, bridge.NewHandlerFactory(&fakeOllama{}, logger), nil, cfg.Bridge, logger)
+	go func() {
+		if err := ws.Connect("ws://" + ln.Addr().String() + "/"); err != nil {
+			t.Error(err)
+			return
+		}
+		server.Run()
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	conn, err := Accept(ctx, ln, cfg.Chunking)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	opts := Options{Timeout: 5 * time.Second, PauseWindow: 200 * time.Millisecond, CreditWait: 2 * time.Second}
+	results := Run(ctx, conn, opts, nil)
+	if len(results) != len(Cases()) {
+		t.Fatalf("ran %d cases, want %d", len(results), len(Cases()))
+	}
+	for _, r := range results {
+		if r.Status != StatusPass {
+			t.Errorf("%s: %s %s", r.Case, r.Status, r.Detail)
+		}
+	}
+}
+
+func TestCasesSkipWithoutPrerequisites(t *testing.T) {
+	// 缺少模型或未设置 credit_wait 时在收发之前跳过
+	s := &session{opts: Options{Timeout: time.Second}}
+	r := s.runCase(context.Background(), Case{Name: "chat", run: chat})
+	if r.Status != StatusSkip {
+		t.Errorf("chat without a model should be skipped, got %+v", r)
+	}
+	r = s.runCase(context.Background(), Case{Name: "cancellation", run: cancellation})
+	if r.Status != StatusSkip {
+		t.Errorf("cancellation without --credit-wait should be skipped, got %+v", r)
+	}
+}
@@ -0,0 +1,166 @@
+package conformance
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"sync"
+
+	"github.com/gorilla/websocket"
+
+	"ollama_dev/internal/auth"
+	"ollama_dev/internal/bridge"
+	"ollama_dev/internal/config"
+)
+
+// errClosed 连接已断开
+var errClosed = errors.New("连接已断开")
+
+// Conn 扮演云端与桥接客户端通信的连接：经由服务器的 hub 转发，或直接接受桥接客户端的连接
+type Conn struct {
+	ws           *websocket.Conn
+	maxFrameSize int
+	reassembler  *bridge.Reassembler
+	accepted     bool // 桥接客户端直接连接到本端，连接建立后应先收到 capabilities
+	writeMu      sync.Mutex
+
+	frames chan *bridge.Envelope // 桥接客户端发出的响应帧，连接断开时关闭
+	err    error                 // 读取结束的原因，frames 关闭后有效
+
+	capsOnce sync.Once
+	caps     chan *bridge.Capabilities // 收到第一个 capabilities 帧时写入
+}
+
+// Dial 以 p 附加的凭据连接到服务器的 WebSocket 地址，请求经 hub 广播给同一租户下的桥接客户端
+func Dial(url string, p auth.Provider, chunking config.ChunkingConfig) (*Conn, error) {
+	ws, _, err := auth.Dial(context.Background(), p, url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("连接服务器失败: %w", err)
+	}
+	return newConn(ws, chunking, false), nil
+}
+
+// Accept 在 ln 上等待一个桥接客户端连接（bridge.url 指向本端的任意路径），返回后不再接受新的连接
+func Accept(ctx context.Context, ln net.Listener, chunking config.ChunkingConfig) (*Conn, error) {
+	accepted := make(chan *websocket.Conn, 1)
+	upgrader := websocket.Upgrader{CheckOrigin: func(*http.Request) bool { return true }}
+	srv := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ws, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		select {
+		case accepted <- ws:
+		default:
+			ws.Close()
+		}
+	})}
+	go srv.Serve(ln)
+	// Close 只关闭监听与空闲连接，已升级的 WebSocket 连接不受影响
+	defer srv.Close()
+
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case ws := <-accepted:
+		return newConn(ws, chunking, true), nil
+	}
+}
+
+func newConn(ws *websocket.Conn, chunking config.ChunkingConfig, accepted bool) *Conn {
+	c := &Conn{
+		ws:           ws,
+		maxFrameSize: chunking.MaxFrameSize,
+		reassembler:  bridge.NewReassembler(chunking),
+		accepted:     accepted,
+		frames:       make(chan *bridge.Envelope, 256),
+		caps:         make(chan *bridge.Capabilities, 1),
+	}
+	go c.readLoop()
+	return c
+}
+
+// readLoop 读取并重组帧，只保留桥接客户端的响应帧；hub 广播回来的请求与心跳直接丢弃
+func (c *Conn) readLoop() {
+	defer close(c.frames)
+	for {
+		_, frame, err := c.ws.ReadMessage()
+		if err != nil {
+			c.err = err
+			return
+		}
+		msg, err := c.reassembler.Add(frame)
+		if err != nil || msg == nil {
+			continue
+		}
+		var env bridge.Envelope
+		if json.Unmarshal(msg, &env) != nil || env.Type != bridge.TypeClientToServer {
+			continue
+		}
+		if env.Action == "capabilities" {
+			var caps bridge.Capabilities
+			if json.Unmarshal(env.Data, &caps) == nil {
+				c.capsOnce.Do(func() { c.caps <- &caps })
+			}
+			continue
+		}
+		c.frames <- &env
+	}
+}
+
+// Send 序列化 v 并按帧大小限制分片发送
+func (c *Conn) Send(v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return c.SendRaw(data)
+}
+
+// SendRaw 原样发送一条消息，用于构造格式错误的帧
+func (c *Conn) SendRaw(data []byte) error {
+	frames, err := bridge.SplitFrame(data, c.maxFrameSize)
+	if err != nil {
+		return err
+	}
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	for _, f := range frames {
+		if err := c.ws.WriteMessage(websocket.TextMessage, f); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Next 返回下一个响应帧
+func (c *Conn) Next(ctx context.Context) (*bridge.Envelope, error) {
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case env, ok := <-c.frames:
+		if !ok {
+			return nil, fmt.Errorf("%w: %v", errClosed, c.err)
+		}
+		return env, nil
+	}
+}
+
+// Capabilities 等待桥接客户端连接后上报的能力信息
+func (c *Conn) Capabilities(ctx context.Context) (*bridge.Capabilities, error) {
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case caps := <-c.caps:
+		c.caps <- caps
+		return caps, nil
+	}
+}
+
+// Close 关闭连接
+func (c *Conn) Close() error {
+	return c.ws.Close()
+}
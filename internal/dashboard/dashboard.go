@@ -0,0 +1,76 @@
+package dashboard
+
+import (
+	_ "embed"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"ollama_dev/internal/stats"
+)
+
+// 仪表盘在管理接口与诊断端口上的路径，页面通过 <路径>/ws 接收统计快照
+const (
+	AdminPath = "/admin/dashboard"
+	DebugPath = "/debug/dashboard"
+)
+
+// Interval 推送统计快照的间隔
+const Interval = 2 * time.Second
+
+const writeTimeout = 5 * time.Second
+
+//go:embed index.html
+var page []byte
+
+// Handler 返回仪表盘页面，路径以 /ws 结尾时升级为 WebSocket 并定时推送 stats.Get()。
+// 只接受同源的 WebSocket 连接，鉴权由外层的管理员账号中间件负责
+func Handler(logger *slog.Logger) http.Handler {
+	upgrader := &websocket.Upgrader{}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasSuffix(r.URL.Path, "/ws") {
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			_, _ = w.Write(page)
+			return
+		}
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			logger.Warn("仪表盘 WebSocket 升级失败", "error", err)
+			return
+		}
+		stream(conn)
+	})
+}
+
+// stream 立即推送一次快照，之后每隔 Interval 推送，页面关闭后返回
+func stream(conn *websocket.Conn) {
+	defer conn.Close()
+
+	// 页面不发送消息，读取只用于发现连接关闭
+	closed := make(chan struct{})
+	go func() {
+		defer close(closed)
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+
+	ticker := time.NewTicker(Interval)
+	defer ticker.Stop()
+	for {
+		_ = conn.SetWriteDeadline(time.Now().Add(writeTimeout))
+		if err := conn.WriteJSON(stats.Get()); err != nil {
+			return
+		}
+		select {
+		case <-ticker.C:
+		case <-closed:
+			return
+		}
+	}
+}
@@ -0,0 +1,46 @@
+package dashboard
+
+import (
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/websocket"
+
+	"ollama_dev/internal/stats"
+)
+
+func TestDashboardServesPageAndStreamsStats(t *testing.T) {
+	stats.RegisterConnections(func() stats.Connections {
+		return stats.Connections{Total: 3, Rooms: map[string]int{"lobby": 3}}
+	})
+	srv := httptest.NewServer(Handler(slog.New(slog.NewTextHandler(io.Discard, nil))))
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL + AdminPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if !strings.Contains(resp.Header.Get("Content-Type"), "text/html") || !strings.Contains(string(body), "/ws`") {
+		t.Fatalf("unexpected page: %s", resp.Header.Get("Content-Type"))
+	}
+
+	// 连接后立即收到一次快照
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http")+AdminPath+"/ws", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	var snap stats.Snapshot
+	if err := conn.ReadJSON(&snap); err != nil {
+		t.Fatal(err)
+	}
+	if snap.Connections == nil || snap.Connections.Rooms["lobby"] != 3 {
+		t.Errorf("unexpected snapshot: %+v", snap)
+	}
+}
@@ -0,0 +1,37 @@
+package debug
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"net/http/pprof"
+)
+
+// PprofPrefix pprof 处理器的挂载路径前缀
+const PprofPrefix = "/debug/pprof/"
+
+// PprofHandler 返回挂载了 net/http/pprof 全部处理器的 Handler
+func PprofHandler() http.Handler {
+	mux := http.NewServeMux()
+	// Index 会根据路径后缀分发 goroutine、heap 等命名 profile
+	mux.HandleFunc(PprofPrefix, pprof.Index)
+	mux.HandleFunc(PprofPrefix+"cmdline", pprof.Cmdline)
+	mux.HandleFunc(PprofPrefix+"profile", pprof.Profile)
+	mux.HandleFunc(PprofPrefix+"symbol", pprof.Symbol)
+	mux.HandleFunc(PprofPrefix+"trace", pprof.Trace)
+	return mux
+}
+
+// BasicAuth 使用管理员账号保护 Handler
+func BasicAuth(next http.Handler, username, password string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, pass, ok := r.BasicAuth()
+		if !ok ||
+			subtle.ConstantTimeCompare([]byte(user), []byte(username)) != 1 ||
+			subtle.ConstantTimeCompare([]byte(pass), []byte(password)) != 1 {
+			w.Header().Set("WWW-Authenticate", `Basic realm="admin"`)
+			http.Error(w, "未授权", http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,54 @@
+package debug
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestBasicAuthProtectsPprof(t *testing.T) {
+	handler := BasicAuth(PprofHandler(), "admin", "secret")
+
+	tests := []struct {
+		name     string
+		user     string
+		pass     string
+		withAuth bool
+		want     int
+	}{
+		{name: "no credentials", want: http.StatusUnauthorized},
+		{name: "wrong password", user: "admin", pass: "wrong", withAuth: true, want: http.StatusUnauthorized},
+		{name: "valid credentials", user: "admin", pass: "secret", withAuth: true, want: http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, PprofPrefix+"goroutine?debug=1", nil)
+			if tt.withAuth {
+				req.SetBasicAuth(tt.user, tt.pass)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != tt.want {
+				t.Errorf("expected status %d, got %d", tt.want, rec.Code)
+			}
+		})
+	}
+}
+
+func TestHandlerServesVars(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, VarsPath, nil)
+	rec := httptest.NewRecorder()
+	Handler().ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", rec.Code)
+	}
+	for _, key := range []string{`"goroutines"`, `"memstats"`} {
+		if !strings.Contains(rec.Body.String(), key) {
+			t.Errorf("expected %s in /debug/vars output", key)
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package debug
+
+import (
+	"expvar"
+	"log/slog"
+	"net/http"
+	"runtime"
+	"sync"
+
+	"ollama_dev/internal/config"
+)
+
+// VarsPath expvar 统计信息路径
+const VarsPath = "/debug/vars"
+
+func init() {
+	// memstats (内存与 GC) 与 cmdline 由 expvar 包自动发布
+	expvar.Publish("goroutines", expvar.Func(func() any {
+		return runtime.NumGoroutine()
+	}))
+	expvar.Publish("num_gc", expvar.Func(func() any {
+		var m runtime.MemStats
+		runtime.ReadMemStats(&m)
+		return m.NumGC
+	}))
+}
+
+// CapturePath WebSocket 抓包下载路径
+const CapturePath = "/debug/capture"
+
+var (
+	extraMu sync.Mutex
+	extra   = map[string]http.Handler{}
+)
+
+// Register 在诊断端口上额外挂载 Handler，需在 StartServer 之前调用
+func Register(path string, h http.Handler) {
+	extraMu.Lock()
+	defer extraMu.Unlock()
+	extra[path] = h
+}
+
+// Handler 返回同时挂载 pprof、expvar 与已注册 Handler 的诊断 Handler
+func Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.Handle(PprofPrefix, PprofHandler())
+	mux.Handle(VarsPath, expvar.Handler())
+
+	extraMu.Lock()
+	defer extraMu.Unlock()
+	for path, h := range extra {
+		mux.Handle(path, h)
+	}
+	return mux
+}
+
+// StartServer 在内部端口上启动受管理员账号保护的诊断服务
+func StartServer(logger *slog.Logger, addr string, admin config.AdminConfig) {
+	if addr == "" {
+		return
+	}
+	if admin.Password == "" {
+		logger.Error("未配置管理员密码，诊断端口未启动", "addr", addr)
+		return
+	}
+
+	handler := BasicAuth(Handler(), admin.Username, admin.Password)
+	go func() {
+		logger.Info("诊断端口已启动", "addr", addr, "pprof", PprofPrefix, "vars", VarsPath)
+		if err := http.ListenAndServe(addr, handler); err != nil {
+			logger.Error("诊断端口运行错误", "error", err)
+		}
+	}()
+}
@@ -0,0 +1,97 @@
+package feature
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+
+	"ollama_dev/internal/config"
+)
+
+// 已知的功能开关
+const (
+	Streaming     = "streaming"      // 流式输出
+	E2EEncryption = "e2e_encryption" // 端到端加密
+	SemanticCache = "semantic_cache" // 语义缓存
+	RAG           = "rag"            // 检索增强生成
+)
+
+// Names 返回全部已知开关名，按字母排序
+func Names() []string {
+	return []string{E2EEncryption, RAG, SemanticCache, Streaming}
+}
+
+// FromConfig 将配置转换为开关表
+func FromConfig(cfg config.FeaturesConfig) map[string]bool {
+	return map[string]bool{
+		Streaming:     cfg.Streaming,
+		E2EEncryption: cfg.E2EEncryption,
+		SemanticCache: cfg.SemanticCache,
+		RAG:           cfg.RAG,
+	}
+}
+
+// ErrUnknown 开关名不存在
+var ErrUnknown = errors.New("未知的功能开关")
+
+// Flags 运行期可修改的功能开关，并发安全
+type Flags struct {
+	mu    sync.RWMutex
+	flags map[string]bool
+}
+
+// New 以配置中的取值创建开关
+func New(cfg config.FeaturesConfig) *Flags {
+	return &Flags{flags: FromConfig(cfg)}
+}
+
+// Enabled 返回开关是否启用，未知开关视为关闭
+func (f *Flags) Enabled(name string) bool {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	return f.flags[name]
+}
+
+// Set 运行期修改开关，重启或重新加载配置后恢复配置中的取值
+func (f *Flags) Set(name string, enabled bool) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if _, ok := f.flags[name]; !ok {
+		return fmt.Errorf("%w %q，可选 %v", ErrUnknown, name, Names())
+	}
+	f.flags[name] = enabled
+	return nil
+}
+
+// Reset 用配置中的取值覆盖全部开关
+func (f *Flags) Reset(cfg config.FeaturesConfig) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.flags = FromConfig(cfg)
+}
+
+// Snapshot 返回当前全部开关的副本
+func (f *Flags) Snapshot() map[string]bool {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	out := make(map[string]bool, len(f.flags))
+	for name, enabled := range f.flags {
+		out[name] = enabled
+	}
+	return out
+}
+
+// EnabledNames 返回已启用的开关名，按字母排序
+func (f *Flags) EnabledNames() []string {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	var names []string
+	for name, enabled := range f.flags {
+		if enabled {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,36 @@
+package feature
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"ollama_dev/internal/config"
+)
+
+func TestFlags(t *testing.T) {
+	f := New(config.FeaturesConfig{Streaming: true})
+	if !f.Enabled(Streaming) || f.Enabled(RAG) {
+		t.Fatalf("unexpected initial flags: %v", f.Snapshot())
+	}
+
+	if err := f.Set(RAG, true); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+	if got, want := f.EnabledNames(), []string{RAG, Streaming}; !reflect.DeepEqual(got, want) {
+		t.Errorf("EnabledNames: got %v, want %v", got, want)
+	}
+
+	if err := f.Set("unknown", true); !errors.Is(err, ErrUnknown) {
+		t.Errorf("expected ErrUnknown for an unknown flag, got %v", err)
+	}
+	if f.Enabled("unknown") {
+		t.Error("unknown flag should be disabled")
+	}
+
+	// 重新加载配置后恢复配置中的取值
+	f.Reset(config.FeaturesConfig{})
+	if len(f.EnabledNames()) != 0 {
+		t.Errorf("expected all flags disabled after reset, got %v", f.EnabledNames())
+	}
+}
@@ -0,0 +1,165 @@
+// Package handlers 提供与 WebSocket 动作对应的 REST 接口 (/api/chat、/api/embeddings)，
+// 供无法保持 WebSocket 连接的客户端使用；参数与响应字段沿用桥接协议的命名
+package handlers
+
+import (
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ollama/ollama/api"
+
+	"ollama_dev/internal/apperr"
+	"ollama_dev/internal/bridge"
+	"ollama_dev/internal/middleware"
+	"ollama_dev/internal/usage"
+)
+
+// SSE 事件名：chunk 为增量片段，done 携带完整回复，error 为生成中途的错误
+const (
+	EventChunk = "chunk"
+	EventDone  = "done"
+	EventError = "error"
+)
+
+// ChatRequest POST /api/chat 请求体，对应 chat 动作的 params
+type ChatRequest struct {
+	ModelName string               `json:"model_name"`
+	Messages  []bridge.ChatMessage `json:"messages"`
+	Stream    bool                 `json:"stream,omitempty"` // 以 text/event-stream 逐片段返回
+	User      string               `json:"user,omitempty"`   // 用量记在该用户名下
+}
+
+// ChatResponse POST /api/chat 响应体，流式请求时为 done 事件的 data
+type ChatResponse struct {
+	Message bridge.ChatMessage `json:"message"`
+	Usage   *bridge.Usage      `json:"usage,omitempty"`
+}
+
+// ChatChunk 流式请求 chunk 事件的 data
+type ChatChunk struct {
+	Content string `json:"content"`
+}
+
+// EmbeddingsRequest POST /api/embeddings 请求体，对应 embed 动作的 params
+type EmbeddingsRequest struct {
+	ModelName string         `json:"model_name"`
+	Input     []string       `json:"input"`
+	Options   map[string]any `json:"options,omitempty"`
+	User      string         `json:"user,omitempty"`
+}
+
+// EmbeddingsResponse POST /api/embeddings 响应体，embeddings 与 input 一一对应
+type EmbeddingsResponse struct {
+	Embeddings [][]float32   `json:"embeddings"`
+	Usage      *bridge.Usage `json:"usage,omitempty"`
+}
+
+// API 直接调用 Ollama 的 REST 处理器
+type API struct {
+	ollama bridge.OllamaClient
+	usage  *usage.Store
+	logger *slog.Logger
+}
+
+// New usage 可为 nil，表示不统计用量
+func New(ollama bridge.OllamaClient, usage *usage.Store, logger *slog.Logger) *API {
+	return &API{ollama: ollama, usage: usage, logger: logger}
+}
+
+// Chat 处理 POST /api/chat，stream 为 true 时以 SSE 逐片段返回，客户端断开时中止生成
+func (a *API) Chat(c *gin.Context) {
+	var req ChatRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		middleware.AbortWithError(c, apperr.Wrap(err, apperr.Validation, apperr.CodeInvalidParams, "请求体无效"))
+		return
+	}
+	if req.ModelName == "" {
+		middleware.AbortWithError(c, apperr.New(apperr.Validation, apperr.CodeInvalidParams, "model_name 不能为空"))
+		return
+	}
+	messages := make([]api.Message, 0, len(req.Messages))
+	for _, m := range req.Messages {
+		messages = append(messages, api.Message{Role: m.Role, Content: m.Content})
+	}
+
+	ctx := c.Request.Context()
+	if !req.Stream {
+		reply, err := a.ollama.Chat(ctx, bridge.ChatRequest{Model: req.ModelName, Messages: messages})
+		if err != nil {
+			middleware.AbortWithError(c, bridge.BackendError(err, "Ollama 对话失败"))
+			return
+		}
+		c.JSON(http.StatusOK, ChatResponse{Message: assistant(reply.Content), Usage: a.record(c, req.ModelName, req.User, reply.Usage)})
+		return
+	}
+
+	// 首个片段之前出错时仍以 JSON 错误响应，之后只能发送 error 事件
+	started := false
+	reply, err := a.ollama.ChatStream(ctx, bridge.ChatRequest{Model: req.ModelName, Messages: messages}, func(chunk string) error {
+		if !started {
+			started = true
+			c.Header("Cache-Control", "no-cache")
+			c.Header("X-Accel-Buffering", "no")
+		}
+		c.SSEvent(EventChunk, ChatChunk{Content: chunk})
+		c.Writer.Flush()
+		return ctx.Err()
+	})
+	if err != nil {
+		err = bridge.BackendError(err, "Ollama 对话失败")
+		if !started {
+			middleware.AbortWithError(c, err)
+			return
+		}
+		if ctx.Err() == nil {
+			a.logger.Error("流式对话失败", "model", req.ModelName, "error", err)
+			c.SSEvent(EventError, middleware.NewErrorResponse(c, err))
+		}
+		return
+	}
+	c.SSEvent(EventDone, ChatResponse{Message: assistant(reply.Content), Usage: a.record(c, req.ModelName, req.User, reply.Usage)})
+	c.Writer.Flush()
+}
+
+// Embeddings 处理 POST /api/embeddings，多条输入在一次 Ollama 调用中完成
+func (a *API) Embeddings(c *gin.Context) {
+	var req EmbeddingsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		middleware.AbortWithError(c, apperr.Wrap(err, apperr.Validation, apperr.CodeInvalidParams, "请求体无效"))
+		return
+	}
+	if req.ModelName == "" {
+		middleware.AbortWithError(c, apperr.New(apperr.Validation, apperr.CodeInvalidParams, "model_name 不能为空"))
+		return
+	}
+	if len(req.Input) == 0 {
+		middleware.AbortWithError(c, apperr.New(apperr.Validation, apperr.CodeInvalidParams, "input 不能为空"))
+		return
+	}
+	e, err := a.ollama.Embed(c.Request.Context(), bridge.EmbedRequest{Model: req.ModelName, Input: req.Input, Options: req.Options})
+	if err != nil {
+		middleware.AbortWithError(c, bridge.BackendError(err, "Ollama 计算向量失败"))
+		return
+	}
+	c.JSON(http.StatusOK, EmbeddingsResponse{Embeddings: e.Vectors, Usage: a.record(c, req.ModelName, req.User, e.Usage)})
+}
+
+// record 按调用方记录 token 用量，返回随响应附带的用量
+func (a *API) record(c *gin.Context, model, user string, u bridge.Usage) *bridge.Usage {
+	u.User = user
+	if u.Model == "" {
+		u.Model = model
+	}
+	if a.usage != nil {
+		if err := a.usage.Record(usage.CallerOf(c.Request.Context()), u, time.Now()); err != nil {
+			a.logger.Warn("记录用量失败", "error", err)
+		}
+	}
+	return &u
+}
+
+func assistant(content string) bridge.ChatMessage {
+	return bridge.ChatMessage{Role: "assistant", Content: content}
+}
@@ -0,0 +1,149 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ollama/ollama/api"
+
+	"ollama_dev/internal/apperr"
+	"ollama_dev/internal/bridge"
+	"ollama_dev/internal/middleware"
+)
+
+// chunkOllama 把最后一条消息按空格拆成片段作为回复，err 不为 nil 时在第一个片段之后返回
+type chunkOllama struct {
+	bridge.OllamaClient
+	err error
+}
+
+func (o *chunkOllama) Chat(ctx context.Context, req bridge.ChatRequest) (bridge.Reply, error) {
+	return bridge.Reply{Content: req.Messages[len(req.Messages)-1].Content, Usage: bridge.Usage{PromptTokens: 3, CompletionTokens: 2}}, nil
+}
+
+func (o *chunkOllama) ChatStream(ctx context.Context, req bridge.ChatRequest, onChunk func(string) error) (bridge.Reply, error) {
+	content := req.Messages[len(req.Messages)-1].Content
+	for _, word := range strings.SplitAfter(content, " ") {
+		if err := onChunk(word); err != nil {
+			return bridge.Reply{}, err
+		}
+		if o.err != nil {
+			return bridge.Reply{}, o.err
+		}
+	}
+	return bridge.Reply{Content: content}, nil
+}
+
+func (o *chunkOllama) Embed(ctx context.Context, req bridge.EmbedRequest) (bridge.Embeddings, error) {
+	if o.err != nil {
+		return bridge.Embeddings{}, o.err
+	}
+	vectors := make([][]float32, len(req.Input))
+	for i := range vectors {
+		vectors[i] = []float32{float32(i)}
+	}
+	return bridge.Embeddings{Vectors: vectors}, nil
+}
+
+func serve(t *testing.T, ollama bridge.OllamaClient, path, body string) *httptest.ResponseRecorder {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	a := New(ollama, nil, slog.New(slog.DiscardHandler))
+	r := gin.New()
+	r.POST("/api/chat", a.Chat)
+	r.POST("/api/embeddings", a.Embeddings)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, path, strings.NewReader(body)))
+	return w
+}
+
+func TestChat(t *testing.T) {
+	w := serve(t, &chunkOllama{}, "/api/chat", `{"model_name":"llama3","messages":[{"role":"user","content":"hi there"}]}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, body %s", w.Code, w.Body)
+	}
+	var resp ChatResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.Message.Role != "assistant" || resp.Message.Content != "hi there" {
+		t.Errorf("message = %+v", resp.Message)
+	}
+	if resp.Usage == nil || resp.Usage.Model != "llama3" || resp.Usage.PromptTokens != 3 {
+		t.Errorf("usage = %+v", resp.Usage)
+	}
+}
+
+func TestChatStream(t *testing.T) {
+	w := serve(t, &chunkOllama{}, "/api/chat", `{"model_name":"llama3","stream":true,"messages":[{"role":"user","content":"hi there"}]}`)
+	if ct := w.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/event-stream") {
+		t.Fatalf("Content-Type = %q", ct)
+	}
+	want := "event:chunk\ndata:{\"content\":\"hi \"}\n\n" +
+		"event:chunk\ndata:{\"content\":\"there\"}\n\n" +
+		"event:done\ndata:{\"message\":{\"role\":\"assistant\",\"content\":\"hi there\"},\"usage\":{\"model\":\"llama3\",\"prompt_tokens\":0,\"completion_tokens\":0}}\n\n"
+	if got := w.Body.String(); got != want {
+		t.Errorf("body = %q\nwant %q", got, want)
+	}
+}
+
+func TestChatStreamError(t *testing.T) {
+	// 已发出片段后出错时以 error 事件结束
+	w := serve(t, &chunkOllama{err: errors.New("boom")}, "/api/chat", `{"model_name":"llama3","stream":true,"messages":[{"role":"user","content":"hi there"}]}`)
+	events := strings.Split(strings.TrimSpace(w.Body.String()), "\n\n")
+	if len(events) != 2 || !strings.HasPrefix(events[1], "event:error\n") {
+		t.Fatalf("events = %q", events)
+	}
+	var e middleware.ErrorResponse
+	if err := json.Unmarshal([]byte(strings.TrimPrefix(events[1], "event:error\ndata:")), &e); err != nil {
+		t.Fatal(err)
+	}
+	if e.Code != apperr.CodeBackendError {
+		t.Errorf("code = %s", e.Code)
+	}
+}
+
+func TestEmbeddings(t *testing.T) {
+	w := serve(t, &chunkOllama{}, "/api/embeddings", `{"model_name":"nomic-embed-text","input":["a","b"]}`)
+	var resp EmbeddingsResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if w.Code != http.StatusOK || len(resp.Embeddings) != 2 || resp.Embeddings[1][0] != 1 {
+		t.Errorf("status = %d, body %s", w.Code, w.Body)
+	}
+}
+
+func TestValidationAndBackendErrors(t *testing.T) {
+	tests := []struct {
+		name, path, body string
+		err              error
+		status           int
+		code             string
+	}{
+		{"no model", "/api/chat", `{"messages":[]}`, nil, http.StatusBadRequest, apperr.CodeInvalidParams},
+		{"bad json", "/api/chat", `{`, nil, http.StatusBadRequest, apperr.CodeInvalidParams},
+		{"no input", "/api/embeddings", `{"model_name":"m"}`, nil, http.StatusBadRequest, apperr.CodeInvalidParams},
+		{"model not found", "/api/embeddings", `{"model_name":"m","input":["a"]}`, api.StatusError{StatusCode: http.StatusNotFound}, http.StatusBadRequest, apperr.CodeModelNotFound},
+		{"backend down", "/api/embeddings", `{"model_name":"m","input":["a"]}`, errors.New("connection refused"), http.StatusServiceUnavailable, apperr.CodeBackendError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := serve(t, &chunkOllama{err: tt.err}, tt.path, tt.body)
+			var e middleware.ErrorResponse
+			if err := json.Unmarshal(w.Body.Bytes(), &e); err != nil {
+				t.Fatal(err)
+			}
+			if w.Code != tt.status || e.Code != tt.code {
+				t.Errorf("got %d %s, want %d %s", w.Code, e.Code, tt.status, tt.code)
+			}
+		})
+	}
+}
@@ -0,0 +1,49 @@
+package health
+
+import (
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+// Lifecycle 进程生命周期状态，供 /healthz 与关闭流程共享
+type Lifecycle struct {
+	startedAt time.Time
+	draining  atomic.Bool
+}
+
+// NewLifecycle 创建生命周期状态
+func NewLifecycle() *Lifecycle {
+	return &Lifecycle{startedAt: time.Now()}
+}
+
+// StartDrain 进入排空阶段，/healthz 随即返回 503，负载均衡器停止转发新流量
+func (l *Lifecycle) StartDrain() {
+	l.draining.Store(true)
+}
+
+// Draining 是否处于排空阶段
+func (l *Lifecycle) Draining() bool {
+	return l.draining.Load()
+}
+
+// Uptime 进程运行时长
+func (l *Lifecycle) Uptime() time.Duration {
+	return time.Since(l.startedAt)
+}
+
+// Status /healthz 响应体
+type Status struct {
+	Status string `json:"status"` // ok 或 draining
+	Uptime string `json:"uptime"`
+}
+
+// Liveness 返回存活状态及对应的 HTTP 状态码
+func (l *Lifecycle) Liveness() (int, Status) {
+	status := Status{Status: "ok", Uptime: l.Uptime().Round(time.Second).String()}
+	if l.Draining() {
+		status.Status = "draining"
+		return http.StatusServiceUnavailable, status
+	}
+	return http.StatusOK, status
+}
@@ -0,0 +1,21 @@
+package health
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestLivenessDraining(t *testing.T) {
+	lc := NewLifecycle()
+
+	code, status := lc.Liveness()
+	if code != http.StatusOK || status.Status != "ok" {
+		t.Fatalf("before drain: got %d %q", code, status.Status)
+	}
+
+	lc.StartDrain()
+	code, status = lc.Liveness()
+	if code != http.StatusServiceUnavailable || status.Status != "draining" {
+		t.Fatalf("after drain: got %d %q", code, status.Status)
+	}
+}
@@ -0,0 +1,187 @@
+package health
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"ollama_dev/internal/config"
+	"ollama_dev/internal/i18n"
+	"ollama_dev/internal/models"
+)
+
+// ModelLister 列出 Ollama 已拉取的模型，调用成功即表示 Ollama 可达
+type ModelLister func(ctx context.Context) ([]string, error)
+
+// OllamaModelLister 基于 Ollama API 的 ModelLister，host 为空时读取 OLLAMA_HOST 环境变量
+func OllamaModelLister(host string) (ModelLister, error) {
+	client, err := models.NewClient(host)
+	if err != nil {
+		return nil, err
+	}
+	return func(ctx context.Context) ([]string, error) {
+		resp, err := client.List(ctx)
+		if err != nil {
+			return nil, err
+		}
+		names := make([]string, 0, len(resp.Models))
+		for _, m := range resp.Models {
+			names = append(names, m.Name)
+		}
+		return names, nil
+	}, nil
+}
+
+// ReadyStatus /readyz 响应体
+type ReadyStatus struct {
+	Status        string    `json:"status"` // ready、not_ready 或 draining
+	Mode          string    `json:"mode"`
+	Error         string    `json:"error,omitempty"`
+	MissingModels []string  `json:"missing_models,omitempty"`
+	CheckedAt     time.Time `json:"checked_at,omitzero"`
+}
+
+// Readiness 后台探测 Ollama 可用性，决定 /readyz 是否放行流量
+type Readiness struct {
+	lifecycle *Lifecycle
+	list      ModelLister
+	cfg       config.ReadinessConfig
+
+	mu      sync.RWMutex
+	checked bool
+	listErr error     // 最近一次探测的错误，为 nil 表示 Ollama 可达
+	downAt  time.Time // 连续探测失败中第一次失败的时间
+	missing []string  // mode 为 models 时缺少的模型
+	at      time.Time
+}
+
+// NewReadiness 创建就绪检查，首次探测成功前视为未就绪
+func NewReadiness(lifecycle *Lifecycle, list ModelLister, cfg config.ReadinessConfig) *Readiness {
+	return &Readiness{lifecycle: lifecycle, list: list, cfg: cfg}
+}
+
+// Run 按 interval 循环探测直到 ctx 结束，mode 为 off 时直接返回
+func (r *Readiness) Run(ctx context.Context, logger *slog.Logger) {
+	if r.cfg.Mode == config.ReadinessOff {
+		return
+	}
+	ticker := time.NewTicker(r.cfg.Interval)
+	defer ticker.Stop()
+	for {
+		r.check(ctx, logger)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// check 执行一次探测并更新状态，就绪状态变化时记录日志
+func (r *Readiness) check(ctx context.Context, logger *slog.Logger) {
+	probeCtx, cancel := context.WithTimeout(ctx, r.cfg.Timeout)
+	names, err := r.list(probeCtx)
+	cancel()
+
+	var missing []string
+	if err == nil && r.cfg.Mode == config.ReadinessModels {
+		missing = MissingModels(r.cfg.RequiredModels, names)
+	}
+
+	r.mu.Lock()
+	wasReady := r.ready()
+	r.checked = true
+	if err != nil && r.listErr == nil {
+		r.downAt = time.Now()
+	}
+	r.listErr = err
+	r.missing = missing
+	r.at = time.Now()
+	ready := r.ready()
+	reason := r.reason(i18n.Default())
+	r.mu.Unlock()
+
+	switch {
+	case ready && !wasReady:
+		logger.Info("服务已就绪")
+	case !ready && wasReady:
+		logger.Warn("服务未就绪", "reason", reason)
+	}
+}
+
+// ready 调用方需持有锁
+func (r *Readiness) ready() bool {
+	return r.checked && r.listErr == nil && len(r.missing) == 0
+}
+
+// reason 以指定语言描述未就绪原因，调用方需持有锁
+func (r *Readiness) reason(lang i18n.Lang) string {
+	switch {
+	case !r.checked:
+		return i18n.Tr(lang, i18n.ErrReadyNotChecked)
+	case r.listErr != nil:
+		return i18n.Tr(lang, i18n.ErrOllamaUnreachable, r.listErr.Error())
+	case len(r.missing) > 0:
+		return i18n.Tr(lang, i18n.ErrMissingModels, strings.Join(r.missing, ", "))
+	}
+	return ""
+}
+
+// Ready 返回就绪状态及对应的 HTTP 状态码，未就绪原因以 lang 描述
+func (r *Readiness) Ready(lang i18n.Lang) (int, ReadyStatus) {
+	status := ReadyStatus{Status: "ready", Mode: r.cfg.Mode}
+	if r.lifecycle.Draining() {
+		status.Status = "draining"
+		return http.StatusServiceUnavailable, status
+	}
+	if r.cfg.Mode == config.ReadinessOff {
+		return http.StatusOK, status
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	status.CheckedAt = r.at
+	status.MissingModels = r.missing
+	if r.ready() {
+		return http.StatusOK, status
+	}
+	status.Status = "not_ready"
+	status.Error = r.reason(lang)
+	return http.StatusServiceUnavailable, status
+}
+
+// Unreachable 返回 Ollama 已持续不可达的时长，可达或尚未探测时返回 0
+func (r *Readiness) Unreachable() time.Duration {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if r.listErr == nil {
+		return 0
+	}
+	return time.Since(r.downAt)
+}
+
+// MissingModels 返回 required 中未出现在 available 里的模型，未写标签时按 latest 匹配
+func MissingModels(required, available []string) []string {
+	have := make(map[string]bool, len(available))
+	for _, name := range available {
+		have[normalizeModel(name)] = true
+	}
+	var missing []string
+	for _, name := range required {
+		if !have[normalizeModel(name)] {
+			missing = append(missing, name)
+		}
+	}
+	return missing
+}
+
+func normalizeModel(name string) string {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if !strings.Contains(name, ":") {
+		name += ":latest"
+	}
+	return name
+}
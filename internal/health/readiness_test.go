@@ -0,0 +1,86 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+
+	"ollama_dev/internal/config"
+	"ollama_dev/internal/i18n"
+)
+
+func TestMissingModels(t *testing.T) {
+	available := []string{"llama3:latest", "qwen2:7b"}
+	got := MissingModels([]string{"llama3", "qwen2:7b", "qwen2", "mistral"}, available)
+	want := []string{"qwen2", "mistral"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+}
+
+func TestReadiness(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	cfg := config.ReadinessConfig{
+		Mode:           config.ReadinessModels,
+		RequiredModels: []string{"llama3"},
+		Interval:       time.Second,
+		Timeout:        time.Second,
+	}
+
+	var models []string
+	var listErr error
+	lc := NewLifecycle()
+	r := NewReadiness(lc, func(ctx context.Context) ([]string, error) { return models, listErr }, cfg)
+
+	// 首次探测前未就绪
+	if code, _ := r.Ready(i18n.Zh); code != http.StatusServiceUnavailable {
+		t.Fatalf("before first check: got %d", code)
+	}
+
+	listErr = errors.New("connection refused")
+	r.check(context.Background(), logger)
+	if code, st := r.Ready(i18n.Zh); code != http.StatusServiceUnavailable || st.Error == "" {
+		t.Fatalf("unreachable: got %d %+v", code, st)
+	}
+	// 连续失败时从第一次失败开始计时
+	first := r.Unreachable()
+	r.check(context.Background(), logger)
+	if d := r.Unreachable(); d <= 0 || d < first {
+		t.Fatalf("expected unreachable duration to keep growing, got %s then %s", first, d)
+	}
+
+	listErr = nil
+	r.check(context.Background(), logger)
+	if d := r.Unreachable(); d != 0 {
+		t.Fatalf("expected 0 once reachable, got %s", d)
+	}
+	if code, st := r.Ready(i18n.Zh); code != http.StatusServiceUnavailable || len(st.MissingModels) != 1 {
+		t.Fatalf("missing model: got %d %+v", code, st)
+	}
+	if _, st := r.Ready(i18n.En); st.Error != "missing models: llama3" {
+		t.Fatalf("english reason: got %q", st.Error)
+	}
+
+	models = []string{"llama3:latest"}
+	r.check(context.Background(), logger)
+	if code, st := r.Ready(i18n.Zh); code != http.StatusOK {
+		t.Fatalf("ready: got %d %+v", code, st)
+	}
+
+	lc.StartDrain()
+	if code, st := r.Ready(i18n.Zh); code != http.StatusServiceUnavailable || st.Status != "draining" {
+		t.Fatalf("draining: got %d %+v", code, st)
+	}
+}
+
+func TestReadinessOff(t *testing.T) {
+	r := NewReadiness(NewLifecycle(), nil, config.ReadinessConfig{Mode: config.ReadinessOff})
+	if code, _ := r.Ready(i18n.Zh); code != http.StatusOK {
+		t.Fatalf("got %d", code)
+	}
+}
@@ -0,0 +1,121 @@
+package i18n
+
+// Key 消息目录中的消息标识
+type Key string
+
+// 面向用户的错误
+const (
+	ErrUnauthorized       Key = "err.unauthorized"
+	ErrInvalidFeatureBody Key = "err.invalid_feature_body"
+	ErrUnknownFeature     Key = "err.unknown_feature"
+	ErrReloadFailed       Key = "err.reload_failed"
+	ErrBackendUnavailable Key = "err.backend_unavailable"
+	ErrReadyNotChecked    Key = "err.ready_not_checked"
+	ErrOllamaUnreachable  Key = "err.ollama_unreachable"
+	ErrMissingModels      Key = "err.missing_models"
+	ErrInvalidDateRange   Key = "err.invalid_date_range"
+	ErrConnectionNotFound Key = "err.connection_not_found"
+	ErrInvalidAnnounce    Key = "err.invalid_announce"
+)
+
+// 命令行输出与日志
+const (
+	CLIErrorPrefix          Key = "cli.error_prefix"
+	LogServerStarted        Key = "log.server_started"
+	LogDrainStarted         Key = "log.drain_started"
+	LogServerStopped        Key = "log.server_stopped"
+	LogConfigReloaded       Key = "log.config_reloaded"
+	LogConfigReloadFailed   Key = "log.config_reload_failed"
+	LogFeatureChanged       Key = "log.feature_changed"
+	LogBackendUnavailable   Key = "log.backend_unavailable"
+	LogBackendRecovered     Key = "log.backend_recovered"
+	LogBackendRequestDenied Key = "log.backend_request_denied"
+)
+
+// catalog 消息目录，每条消息需同时提供中文与英文
+var catalog = map[Key]map[Lang]string{
+	ErrUnauthorized: {
+		Zh: "未授权",
+		En: "unauthorized",
+	},
+	ErrInvalidFeatureBody: {
+		Zh: `请求体应为 {"enabled": true|false}`,
+		En: `request body must be {"enabled": true|false}`,
+	},
+	ErrUnknownFeature: {
+		Zh: "未知的功能开关 %q，可选 %v",
+		En: "unknown feature flag %q, available: %v",
+	},
+	ErrReloadFailed: {
+		Zh: "重新加载配置失败: %v",
+		En: "failed to reload config: %v",
+	},
+	ErrBackendUnavailable: {
+		Zh: "Ollama 后端不可用: %s",
+		En: "Ollama backend unavailable: %s",
+	},
+	ErrReadyNotChecked: {
+		Zh: "尚未完成首次探测",
+		En: "first probe has not completed yet",
+	},
+	ErrOllamaUnreachable: {
+		Zh: "Ollama 不可达: %s",
+		En: "Ollama unreachable: %s",
+	},
+	ErrMissingModels: {
+		Zh: "缺少模型: %s",
+		En: "missing models: %s",
+	},
+	ErrInvalidDateRange: {
+		Zh: "日期范围无效，from 与 to 应为 YYYY-MM-DD 且 from 不晚于 to",
+		En: "invalid date range, from and to must be YYYY-MM-DD and from must not be after to",
+	},
+	ErrConnectionNotFound: {
+		Zh: "连接 %q 不存在或已断开",
+		En: "connection %q not found or already closed",
+	},
+	ErrInvalidAnnounce: {
+		Zh: `请求体应为 {"message": "...", "room": "可选", "tenant": "可选"}，message 不能为空`,
+		En: `request body must be {"message": "...", "room": "optional", "tenant": "optional"} with a non-empty message`,
+	},
+	CLIErrorPrefix: {
+		Zh: "错误:",
+		En: "Error:",
+	},
+	LogServerStarted: {
+		Zh: "Gin 服务器启动",
+		En: "gin server started",
+	},
+	LogDrainStarted: {
+		Zh: "收到退出信号，开始排空",
+		En: "received shutdown signal, draining",
+	},
+	LogServerStopped: {
+		Zh: "服务器已关闭",
+		En: "server stopped",
+	},
+	LogConfigReloaded: {
+		Zh: "配置已重新加载",
+		En: "config reloaded",
+	},
+	LogConfigReloadFailed: {
+		Zh: "重新加载配置失败，继续使用原配置",
+		En: "config reload failed, keeping previous config",
+	},
+	LogFeatureChanged: {
+		Zh: "功能开关已修改",
+		En: "feature flag changed",
+	},
+	LogBackendUnavailable: {
+		Zh: "Ollama 后端不可用",
+		En: "Ollama backend unavailable",
+	},
+	LogBackendRecovered: {
+		Zh: "Ollama 后端已恢复",
+		En: "Ollama backend recovered",
+	},
+	LogBackendRequestDenied: {
+		Zh: "Ollama 后端不可用，拒绝请求",
+		En: "Ollama backend unavailable, rejecting request",
+	},
+}
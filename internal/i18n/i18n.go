@@ -0,0 +1,101 @@
+package i18n
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync/atomic"
+)
+
+// Lang 消息语言
+type Lang string
+
+// 支持的语言
+const (
+	Zh Lang = "zh"
+	En Lang = "en"
+)
+
+// defaultLang 未指定语言时使用，由 lang 配置项设置
+var defaultLang atomic.Value
+
+func init() {
+	defaultLang.Store(Zh)
+}
+
+// Parse 解析语言名称，接受 zh、en 及 zh-CN、en-US 等带地区的写法
+func Parse(s string) (Lang, error) {
+	base, _, _ := strings.Cut(strings.ToLower(strings.TrimSpace(s)), "-")
+	base, _, _ = strings.Cut(base, "_")
+	switch Lang(base) {
+	case Zh:
+		return Zh, nil
+	case En:
+		return En, nil
+	}
+	return "", fmt.Errorf("未知的语言 %q，可选 zh、en", s)
+}
+
+// SetDefault 设置默认语言
+func SetDefault(lang string) error {
+	l, err := Parse(lang)
+	if err != nil {
+		return err
+	}
+	defaultLang.Store(l)
+	return nil
+}
+
+// Default 返回默认语言
+func Default() Lang {
+	return defaultLang.Load().(Lang)
+}
+
+// T 以默认语言格式化消息，用于日志与命令行输出
+func T(key Key, args ...any) string {
+	return Tr(Default(), key, args...)
+}
+
+// Tr 以指定语言格式化消息，目录中缺少该语言时回退到中文，缺少该消息时返回 key
+func Tr(lang Lang, key Key, args ...any) string {
+	msgs, ok := catalog[key]
+	if !ok {
+		return string(key)
+	}
+	format, ok := msgs[lang]
+	if !ok {
+		format = msgs[Zh]
+	}
+	if len(args) == 0 {
+		return format
+	}
+	return fmt.Sprintf(format, args...)
+}
+
+// FromAcceptLanguage 按 q 值选出 Accept-Language 中优先级最高的已支持语言，均不支持时返回 fallback
+func FromAcceptLanguage(header string, fallback Lang) Lang {
+	best, bestQ := fallback, 0.0
+	for _, part := range strings.Split(header, ",") {
+		tag, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		lang, err := Parse(tag)
+		if err != nil {
+			continue
+		}
+		q := 1.0
+		if v, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			if parsed, err := strconv.ParseFloat(v, 64); err == nil {
+				q = parsed
+			}
+		}
+		if q > bestQ {
+			best, bestQ = lang, q
+		}
+	}
+	return best
+}
+
+// FromRequest 返回请求的 Accept-Language 对应的语言，未指定时使用默认语言
+func FromRequest(r *http.Request) Lang {
+	return FromAcceptLanguage(r.Header.Get("Accept-Language"), Default())
+}
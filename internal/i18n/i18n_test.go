@@ -0,0 +1,40 @@
+package i18n
+
+import "testing"
+
+func TestCatalogComplete(t *testing.T) {
+	for key, msgs := range catalog {
+		for _, lang := range []Lang{Zh, En} {
+			if msgs[lang] == "" {
+				t.Errorf("%s: missing %s translation", key, lang)
+			}
+		}
+	}
+}
+
+func TestFromAcceptLanguage(t *testing.T) {
+	cases := []struct {
+		header string
+		want   Lang
+	}{
+		{"", Zh},
+		{"en-US,en;q=0.9", En},
+		{"zh-CN,zh;q=0.9,en;q=0.8", Zh},
+		{"fr-FR;q=1.0, en;q=0.5, zh;q=0.7", Zh},
+		{"fr-FR", Zh},
+	}
+	for _, c := range cases {
+		if got := FromAcceptLanguage(c.header, Zh); got != c.want {
+			t.Errorf("FromAcceptLanguage(%q) = %s, want %s", c.header, got, c.want)
+		}
+	}
+}
+
+func TestTr(t *testing.T) {
+	if got := Tr(En, ErrMissingModels, "llama3"); got != "missing models: llama3" {
+		t.Errorf("unexpected message: %q", got)
+	}
+	if got := Tr(Zh, Key("no.such.key")); got != "no.such.key" {
+		t.Errorf("unknown key should fall back to the key, got %q", got)
+	}
+}
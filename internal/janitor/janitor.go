@@ -0,0 +1,73 @@
+package janitor
+
+import (
+	"context"
+	"expvar"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// metrics 每类数据累计回收的条数与清理轮次，通过 /debug/vars 的 janitor 查看
+var metrics = expvar.NewMap("janitor")
+
+// Sweeper 清理一类过期数据，返回本次回收的条数
+type Sweeper func(now time.Time) int
+
+type task struct {
+	name  string
+	sweep Sweeper
+}
+
+// Janitor 按固定间隔依次执行已注册的清理任务
+type Janitor struct {
+	interval time.Duration
+	mu       sync.Mutex
+	tasks    []task
+}
+
+// New 创建清理器，interval 为两轮清理的间隔
+func New(interval time.Duration) *Janitor {
+	return &Janitor{interval: interval}
+}
+
+// Register 注册清理任务，name 作为指标名
+func (j *Janitor) Register(name string, sweep Sweeper) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.tasks = append(j.tasks, task{name: name, sweep: sweep})
+}
+
+// Sweep 执行一轮清理，返回各任务回收的条数
+func (j *Janitor) Sweep(now time.Time) map[string]int {
+	j.mu.Lock()
+	tasks := append([]task(nil), j.tasks...)
+	j.mu.Unlock()
+
+	reclaimed := make(map[string]int, len(tasks))
+	for _, t := range tasks {
+		n := t.sweep(now)
+		reclaimed[t.name] = n
+		metrics.Add(t.name, int64(n))
+	}
+	metrics.Add("runs", 1)
+	return reclaimed
+}
+
+// Run 周期性清理直到 ctx 结束，有回收时记录日志
+func (j *Janitor) Run(ctx context.Context, logger *slog.Logger) {
+	ticker := time.NewTicker(j.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			for name, n := range j.Sweep(now) {
+				if n > 0 {
+					logger.Info("已清理过期数据", "task", name, "count", n)
+				}
+			}
+		}
+	}
+}
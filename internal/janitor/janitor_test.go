@@ -0,0 +1,51 @@
+package janitor
+
+import (
+	"context"
+	"expvar"
+	"io"
+	"log/slog"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestSweepRecordsMetrics(t *testing.T) {
+	j := New(time.Minute)
+	j.Register("test_sessions", func(now time.Time) int { return 3 })
+	j.Register("test_empty", func(now time.Time) int { return 0 })
+
+	got := j.Sweep(time.Now())
+	if got["test_sessions"] != 3 || got["test_empty"] != 0 {
+		t.Fatalf("unexpected reclaimed counts: %v", got)
+	}
+	j.Sweep(time.Now())
+	if v := metrics.Get("test_sessions").(*expvar.Int).Value(); v != 6 {
+		t.Errorf("expected cumulative metric 6, got %d", v)
+	}
+}
+
+func TestRunStopsWithContext(t *testing.T) {
+	var calls atomic.Int32
+	j := New(time.Millisecond)
+	j.Register("test_run", func(now time.Time) int {
+		calls.Add(1)
+		return 1
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		j.Run(ctx, slog.New(slog.NewTextHandler(io.Discard, nil)))
+		close(done)
+	}()
+	for calls.Load() < 2 {
+		time.Sleep(time.Millisecond)
+	}
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("janitor did not stop after cancel")
+	}
+}
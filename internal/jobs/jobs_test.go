@@ -0,0 +1,135 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeTransfer 按 layers 上报进度，block 不为 nil 时在第一层之后等待 ctx 结束，模拟进程被中断
+type fakeTransfer struct {
+	block chan struct{}
+	err   error
+	pulls int
+}
+
+func (f *fakeTransfer) Pull(ctx context.Context, model string, fn func(Progress)) error {
+	f.pulls++
+	fn(Progress{Status: "pulling manifest"})
+	fn(Progress{Status: "pulling aaa", Digest: "sha256:aaa", Completed: 512, Total: 1024})
+	if f.block != nil {
+		close(f.block)
+		<-ctx.Done()
+		return ctx.Err()
+	}
+	fn(Progress{Status: "pulling aaa", Digest: "sha256:aaa", Completed: 1024, Total: 1024})
+	return f.err
+}
+
+func (f *fakeTransfer) Push(ctx context.Context, model string, fn func(Progress)) error {
+	fn(Progress{Status: "pushing bbb", Digest: "sha256:bbb", Completed: 10, Total: 10})
+	return f.err
+}
+
+func discard() *slog.Logger {
+	return slog.New(slog.NewTextHandler(io.Discard, nil))
+}
+
+func TestInterruptedPullResumesAfterRestart(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "jobs.db")
+	store, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 第一次运行在第一层传输到一半时被中断
+	ctx, cancel := context.WithCancel(context.Background())
+	first := &fakeTransfer{block: make(chan struct{})}
+	m := NewManager(ctx, store, first, discard())
+	j, err := m.Start(KindPull, "llama3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	<-first.block
+	cancel()
+	m.Wait()
+	store.Close()
+
+	// 重启后进度仍可查询，任务保持 running 并被恢复
+	store, err = Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	saved, ok := store.Get(j.ID)
+	if !ok || saved.Status != StatusRunning || saved.Digest != "sha256:aaa" || saved.Completed != 512 {
+		t.Fatalf("unexpected job after restart: %+v", saved)
+	}
+
+	second := &fakeTransfer{}
+	m = NewManager(context.Background(), store, second, discard())
+	if n := m.Resume(); n != 1 {
+		t.Fatalf("expected 1 resumed job, got %d", n)
+	}
+	m.Wait()
+	done, _ := m.Get(j.ID)
+	if done.Status != StatusDone || done.Attempts != 2 || done.Completed != 1024 || second.pulls != 1 {
+		t.Errorf("unexpected job after resume: %+v", done)
+	}
+}
+
+func TestStartReusesUnfinishedJob(t *testing.T) {
+	store, _ := Open("")
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	transfer := &fakeTransfer{block: make(chan struct{})}
+	m := NewManager(ctx, store, transfer, discard())
+
+	a, _ := m.Start(KindPull, "llama3")
+	<-transfer.block
+	b, _ := m.Start(KindPull, "llama3")
+	if a.ID != b.ID {
+		t.Errorf("expected the running pull to be reused, got %s and %s", a.ID, b.ID)
+	}
+	// 不同类型的任务单独创建
+	c, _ := m.Start(KindPush, "llama3")
+	if c.ID == a.ID {
+		t.Error("push should not reuse the pull job")
+	}
+	cancel()
+	m.Wait()
+	if len(m.List()) != 2 {
+		t.Errorf("expected 2 jobs, got %d", len(m.List()))
+	}
+}
+
+func TestFailedJobIsNotResumed(t *testing.T) {
+	store, _ := Open("")
+	m := NewManager(context.Background(), store, &fakeTransfer{err: errors.New("model not found")}, discard())
+	j, _ := m.Start(KindPull, "missing")
+	m.Wait()
+	if got, _ := m.Get(j.ID); got.Status != StatusFailed || got.Error != "model not found" {
+		t.Fatalf("unexpected job: %+v", got)
+	}
+	if n := m.Resume(); n != 0 {
+		t.Errorf("failed jobs should not be resumed, got %d", n)
+	}
+}
+
+func TestJobTimeout(t *testing.T) {
+	store, _ := Open("")
+	m := NewManager(context.Background(), store, &fakeTransfer{block: make(chan struct{})}, discard())
+	m.SetTimeout(KindPull, 20*time.Millisecond)
+	j, _ := m.Start(KindPull, "llama3")
+	m.Wait()
+	// 超时的任务记为失败，不会在重启后恢复
+	got, _ := m.Get(j.ID)
+	if got.Status != StatusFailed || !strings.Contains(got.Error, "超过时限") {
+		t.Errorf("unexpected job after timeout: %+v", got)
+	}
+}
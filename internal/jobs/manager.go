@@ -0,0 +1,184 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// DebugPath 任务列表在诊断端口上的路径
+const DebugPath = "/debug/jobs"
+
+// persistInterval 进度写入任务文件的最小间隔，层切换与状态变化时立即写入
+const persistInterval = time.Second
+
+// Progress 传输进度
+type Progress struct {
+	Status    string
+	Digest    string
+	Completed int64
+	Total     int64
+}
+
+// Transfer 执行模型的拉取与推送，中断后再次调用时应从已下载的部分继续（Ollama 按层保留未完成的文件）
+type Transfer interface {
+	Pull(ctx context.Context, model string, fn func(Progress)) error
+	Push(ctx context.Context, model string, fn func(Progress)) error
+}
+
+// Manager 在后台运行拉取与推送任务，进度保存在 Store 中
+type Manager struct {
+	ctx      context.Context
+	store    *Store
+	transfer Transfer
+	logger   *slog.Logger
+
+	mu       sync.Mutex
+	running  map[string]bool          // 运行中的 kind/model，同一模型同时只运行一个同类任务
+	timeouts map[string]time.Duration // 各类任务每次运行的时限
+	wg       sync.WaitGroup
+}
+
+// NewManager 创建任务管理器，ctx 结束时中断运行中的任务，任务保持 running 状态以便下次启动时恢复
+func NewManager(ctx context.Context, store *Store, transfer Transfer, logger *slog.Logger) *Manager {
+	return &Manager{ctx: ctx, store: store, transfer: transfer, logger: logger, running: map[string]bool{}, timeouts: map[string]time.Duration{}}
+}
+
+// SetTimeout 设置一类任务每次运行的时限，超时的任务记为失败；0 表示不限，需在 Start 与 Resume 之前调用
+func (m *Manager) SetTimeout(kind string, d time.Duration) {
+	m.timeouts[kind] = d
+}
+
+// Start 创建并启动任务，同一模型已有未结束的同类任务时返回该任务
+func (m *Manager) Start(kind, model string) (Job, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, j := range m.store.List() {
+		if j.Kind == kind && j.Model == model && !j.Finished() {
+			return j, nil
+		}
+	}
+	now := time.Now()
+	j := Job{ID: uuid.NewString(), Kind: kind, Model: model, Status: StatusQueued, CreatedAt: now, UpdatedAt: now}
+	if err := m.store.Put(j); err != nil {
+		return Job{}, err
+	}
+	m.launch(j)
+	return j, nil
+}
+
+// Resume 重新运行上次未结束的任务，返回恢复的任务数
+func (m *Manager) Resume() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	n := 0
+	for _, j := range m.store.List() {
+		if !j.Finished() && !m.running[j.Kind+"/"+j.Model] {
+			m.logger.Info("恢复未完成的任务", "job", j.ID, "kind", j.Kind, "model", j.Model, "completed", j.Completed, "total", j.Total)
+			m.launch(j)
+			n++
+		}
+	}
+	return n
+}
+
+// Get 返回指定任务
+func (m *Manager) Get(id string) (Job, bool) {
+	return m.store.Get(id)
+}
+
+// List 返回全部任务
+func (m *Manager) List() []Job {
+	return m.store.List()
+}
+
+// Changed 返回在下一次任务更新时关闭的 channel，用于跟踪进度
+func (m *Manager) Changed() <-chan struct{} {
+	return m.store.Changed()
+}
+
+// Done 返回在管理器停止（运行中的任务被中断）时关闭的 channel
+func (m *Manager) Done() <-chan struct{} {
+	return m.ctx.Done()
+}
+
+// Wait 等待运行中的任务结束或被中断
+func (m *Manager) Wait() {
+	m.wg.Wait()
+}
+
+// launch 调用方需持有锁
+func (m *Manager) launch(j Job) {
+	m.running[j.Kind+"/"+j.Model] = true
+	m.wg.Add(1)
+	go func() {
+		defer m.wg.Done()
+		m.run(j)
+		m.mu.Lock()
+		delete(m.running, j.Kind+"/"+j.Model)
+		m.mu.Unlock()
+	}()
+}
+
+func (m *Manager) run(j Job) {
+	j.Status, j.Error = StatusRunning, ""
+	j.Attempts++
+	m.save(&j)
+
+	lastSaved := time.Now()
+	progress := func(p Progress) {
+		layerChanged := p.Digest != j.Digest
+		j.Detail, j.Digest, j.Completed, j.Total = p.Status, p.Digest, p.Completed, p.Total
+		j.Percent = 0
+		if p.Total > 0 {
+			j.Percent = int(p.Completed * 100 / p.Total)
+		}
+		if layerChanged || time.Since(lastSaved) >= persistInterval {
+			m.save(&j)
+			lastSaved = time.Now()
+		}
+	}
+
+	ctx := m.ctx
+	if d := m.timeouts[j.Kind]; d > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, d)
+		defer cancel()
+	}
+	var err error
+	switch j.Kind {
+	case KindPush:
+		err = m.transfer.Push(ctx, j.Model, progress)
+	default:
+		err = m.transfer.Pull(ctx, j.Model, progress)
+	}
+	if err != nil && m.ctx.Err() == nil && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		err = fmt.Errorf("超过时限 %s: %w", m.timeouts[j.Kind], err)
+	}
+
+	switch {
+	case m.ctx.Err() != nil:
+		// 进程退出导致的中断保持 running 状态，下次启动时恢复
+		m.save(&j)
+		return
+	case err != nil:
+		j.Status, j.Error = StatusFailed, err.Error()
+		m.logger.Error("模型传输失败", "job", j.ID, "kind", j.Kind, "model", j.Model, "error", err)
+	default:
+		j.Status = StatusDone
+		m.logger.Info("模型传输完成", "job", j.ID, "kind", j.Kind, "model", j.Model)
+	}
+	m.save(&j)
+}
+
+func (m *Manager) save(j *Job) {
+	j.UpdatedAt = time.Now()
+	if err := m.store.Put(*j); err != nil {
+		m.logger.Error("保存任务失败", "job", j.ID, "error", err)
+	}
+}
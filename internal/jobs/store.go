@@ -0,0 +1,159 @@
+package jobs
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// 任务类型
+const (
+	KindPull = "pull"
+	KindPush = "push"
+)
+
+// 任务状态，queued 与 running 的任务在重启后恢复
+const (
+	StatusQueued  = "queued"
+	StatusRunning = "running"
+	StatusDone    = "done"
+	StatusFailed  = "failed"
+)
+
+// Job 一次模型拉取或推送
+type Job struct {
+	ID        string    `json:"id"`
+	Kind      string    `json:"kind"`
+	Model     string    `json:"model"`
+	Status    string    `json:"status"`
+	Detail    string    `json:"detail,omitempty"` // Ollama 上报的进度描述，例如 "pulling 6a0746a1ec1a"
+	Digest    string    `json:"digest,omitempty"` // 正在传输的层
+	Completed int64     `json:"completed"`        // 当前层已传输的字节数
+	Total     int64     `json:"total"`            // 当前层的总字节数
+	Percent   int       `json:"percent"`          // 当前层的完成百分比
+	Attempts  int       `json:"attempts"`         // 运行次数，中断后恢复时递增
+	Error     string    `json:"error,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// Finished 任务是否已结束
+func (j Job) Finished() bool {
+	return j.Status == StatusDone || j.Status == StatusFailed
+}
+
+var bucket = []byte("jobs")
+
+// Store 任务记录，内存中保存全部任务，配置文件路径时同时写入 bbolt 文件
+type Store struct {
+	mu      sync.Mutex
+	db      *bolt.DB // 为 nil 时只保存在内存
+	jobs    map[string]Job
+	changed chan struct{} // 下一次 Put 时关闭
+}
+
+// Open 打开任务文件并读取已有任务，path 为空时只保存在内存，重启后不恢复
+func Open(path string) (*Store, error) {
+	s := &Store{jobs: map[string]Job{}, changed: make(chan struct{})}
+	if path == "" {
+		return s, nil
+	}
+	if dir := filepath.Dir(path); dir != "." {
+		if err := os.MkdirAll(dir, 0o700); err != nil {
+			return nil, fmt.Errorf("创建任务目录失败: %w", err)
+		}
+	}
+	db, err := bolt.Open(path, 0o600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("打开任务文件失败: %w", err)
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		b, err := tx.CreateBucketIfNotExists(bucket)
+		if err != nil {
+			return err
+		}
+		return b.ForEach(func(k, v []byte) error {
+			var j Job
+			if err := json.Unmarshal(v, &j); err != nil {
+				return err
+			}
+			s.jobs[j.ID] = j
+			return nil
+		})
+	})
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("读取任务文件失败: %w", err)
+	}
+	s.db = db
+	return s, nil
+}
+
+// Put 保存任务
+func (s *Store) Put(j Job) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.jobs[j.ID] = j
+	close(s.changed)
+	s.changed = make(chan struct{})
+	if s.db == nil {
+		return nil
+	}
+	data, err := json.Marshal(j)
+	if err != nil {
+		return err
+	}
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(bucket).Put([]byte(j.ID), data)
+	})
+}
+
+// Get 返回指定任务
+func (s *Store) Get(id string) (Job, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	j, ok := s.jobs[id]
+	return j, ok
+}
+
+// Changed 返回在下一次任务更新时关闭的 channel
+func (s *Store) Changed() <-chan struct{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.changed
+}
+
+// List 返回全部任务，按创建时间排序
+func (s *Store) List() []Job {
+	s.mu.Lock()
+	out := make([]Job, 0, len(s.jobs))
+	for _, j := range s.jobs {
+		out = append(out, j)
+	}
+	s.mu.Unlock()
+	slices.SortFunc(out, func(a, b Job) int { return a.CreatedAt.Compare(b.CreatedAt) })
+	return out
+}
+
+// Close 关闭任务文件
+func (s *Store) Close() error {
+	if s == nil || s.db == nil {
+		return nil
+	}
+	return s.db.Close()
+}
+
+// Handler 以 JSON 返回全部任务
+func (s *Store) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]any{"jobs": s.List()})
+	})
+}
@@ -0,0 +1,168 @@
+package keystore
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"ollama_dev/internal/util"
+)
+
+// ErrNoKey 租户没有可用的密钥
+var ErrNoKey = errors.New("没有可用的密钥")
+
+// fileAAD 密钥文件的附加数据，防止其他用途的加密文件（例如 util.Keyring 单独保存的密钥环）被当作密钥文件解密
+var fileAAD = []byte("ollama_dev/keystore")
+
+type file struct {
+	Tenants map[string]*util.Keyring `json:"tenants"`
+}
+
+// Store 为每个租户保存一个 util.Keyring，文件以口令加密，在其他进程轮换后自动重新读取
+type Store struct {
+	mu         sync.Mutex
+	path       string
+	passphrase []byte
+	modTime    time.Time
+	tenants    map[string]*util.Keyring
+}
+
+// Open 以口令读取密钥文件，文件不存在时返回空的 Store，首次轮换时创建；文件未以口令加密时返回错误
+func Open(path string, passphrase []byte) (*Store, error) {
+	if len(passphrase) == 0 {
+		return nil, errors.New("密钥文件口令不能为空，请设置 e2e.passphrase 或 OLLAMA_DEV_E2E_PASSPHRASE")
+	}
+	s := &Store{path: path, passphrase: passphrase, tenants: map[string]*util.Keyring{}}
+	if err := s.load(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// load 读取文件内容，调用方需持有锁或尚未共享 Store
+func (s *Store) load() error {
+	info, err := os.Stat(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("读取密钥文件失败: %w", err)
+	}
+	data, err := os.ReadFile(s.path)
+	if err != nil {
+		return fmt.Errorf("读取密钥文件失败: %w", err)
+	}
+	if !util.IsSealedFile(data) {
+		return fmt.Errorf("密钥文件 %s 未加密，请用 ollama_dev keys rotate 重新生成", s.path)
+	}
+	plaintext, err := util.OpenSealedFile(data, s.passphrase, fileAAD)
+	if err != nil {
+		return fmt.Errorf("解密密钥文件失败: %w", err)
+	}
+	var f file
+	if err := json.Unmarshal(plaintext, &f); err != nil {
+		return fmt.Errorf("解析密钥文件失败: %w", err)
+	}
+	tenants := make(map[string]*util.Keyring, len(f.Tenants))
+	for name, ring := range f.Tenants {
+		if ring == nil {
+			return fmt.Errorf("解析租户 %q 的密钥失败: 密钥环为空", name)
+		}
+		tenants[name] = ring
+	}
+	s.tenants, s.modTime = tenants, info.ModTime()
+	return nil
+}
+
+// refresh 文件被修改（例如另一个进程完成轮换）时重新读取
+func (s *Store) refresh() error {
+	info, err := os.Stat(s.path)
+	if err != nil || info.ModTime().Equal(s.modTime) {
+		return nil
+	}
+	return s.load()
+}
+
+// Current 返回租户当前用于加密的密钥
+func (s *Store) Current(tenant string) (util.KeyInfo, error) {
+	ring, err := s.keyring(tenant)
+	if err != nil {
+		return util.KeyInfo{}, err
+	}
+	return ring.Current(), nil
+}
+
+// keyring 返回租户的密钥环，文件被其他进程修改过时先重新读取
+func (s *Store) keyring(tenant string) (*util.Keyring, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.refresh(); err != nil {
+		return nil, err
+	}
+	ring, ok := s.tenants[tenant]
+	if !ok {
+		return nil, fmt.Errorf("%w: 租户 %q，可运行 ollama_dev keys rotate %s 生成", ErrNoKey, tenant, tenant)
+	}
+	return ring, nil
+}
+
+// Rotate 为租户生成新的密钥并设为当前密钥，只保留最新的 keep 个，keep 不大于 0 时全部保留
+func (s *Store) Rotate(tenant string, keep int) (util.KeyInfo, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.refresh(); err != nil {
+		return util.KeyInfo{}, err
+	}
+	var (
+		info util.KeyInfo
+		err  error
+	)
+	ring, ok := s.tenants[tenant]
+	if ok {
+		info, err = ring.Rotate("", keep)
+	} else if ring, err = util.NewKeyring(""); err == nil {
+		s.tenants[tenant], info = ring, ring.Current()
+	}
+	if err != nil {
+		return util.KeyInfo{}, fmt.Errorf("生成密钥失败: %w", err)
+	}
+	if err := s.save(); err != nil {
+		return util.KeyInfo{}, err
+	}
+	return info, nil
+}
+
+// Versions 返回各租户保存的 key ID，按升序
+func (s *Store) Versions() map[string][]uint32 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_ = s.refresh()
+	out := make(map[string][]uint32, len(s.tenants))
+	for name, ring := range s.tenants {
+		keys := ring.Keys()
+		ids := make([]uint32, len(keys))
+		for i, k := range keys {
+			ids[i] = k.ID
+		}
+		out[name] = ids
+	}
+	return out
+}
+
+// save 以口令加密后写入，util.SaveSealedFile 先写临时文件再重命名，读取方不会看到写了一半的文件
+func (s *Store) save() error {
+	data, err := json.Marshal(file{Tenants: s.tenants})
+	if err != nil {
+		return err
+	}
+	if err := util.SaveSealedFile(s.path, s.passphrase, data, fileAAD); err != nil {
+		return fmt.Errorf("写入密钥文件失败: %w", err)
+	}
+	if info, err := os.Stat(s.path); err == nil {
+		s.modTime = info.ModTime()
+	}
+	return nil
+}
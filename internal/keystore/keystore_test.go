@@ -0,0 +1,139 @@
+package keystore
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"ollama_dev/internal/util"
+)
+
+var passphrase = []byte("test passphrase")
+
+func TestRotateKeepsVersions(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "keys.json")
+	s, err := Open(path, passphrase)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Current("acme"); !errors.Is(err, ErrNoKey) {
+		t.Fatalf("expected ErrNoKey before rotation, got %v", err)
+	}
+	for range 3 {
+		if _, err := s.Rotate("acme", 2); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if v := s.Versions()["acme"]; len(v) != 2 || v[0] != 2 || v[1] != 3 {
+		t.Errorf("expected versions [2 3], got %v", v)
+	}
+	if info, err := os.Stat(path); err != nil || info.Mode().Perm() != 0o600 {
+		t.Errorf("expected keystore written with 0600, got %v %v", info.Mode(), err)
+	}
+
+	// 重新打开后读到相同的当前版本
+	reopened, err := Open(path, passphrase)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if key, err := reopened.Current("acme"); err != nil || key.ID != 3 {
+		t.Errorf("expected current key ID 3, got %d %v", key.ID, err)
+	}
+}
+
+func TestFileEncrypted(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "keys.json")
+	s, _ := Open(path, passphrase)
+	if _, err := s.Rotate("acme", 0); err != nil {
+		t.Fatal(err)
+	}
+	raw, _ := os.ReadFile(path)
+	if !util.IsSealedFile(raw) || bytes.Contains(raw, []byte("acme")) {
+		t.Errorf("expected an encrypted keystore, got %s", raw)
+	}
+	if _, err := Open(path, []byte("wrong")); !errors.Is(err, util.ErrWrongPassphrase) {
+		t.Errorf("expected ErrWrongPassphrase, got %v", err)
+	}
+	if _, err := Open(path, nil); err == nil {
+		t.Error("expected an error without passphrase")
+	}
+
+	// 未加密的文件不读取，也不改写
+	plain := filepath.Join(t.TempDir(), "plain.json")
+	if err := os.WriteFile(plain, []byte(`{"tenants":{}}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Open(plain, passphrase); err == nil {
+		t.Error("expected an error for an unencrypted keystore")
+	}
+	if raw, _ := os.ReadFile(plain); string(raw) != `{"tenants":{}}` {
+		t.Errorf("expected the unencrypted keystore to be left untouched, got %s", raw)
+	}
+}
+
+func TestRefreshAfterExternalRotation(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "keys.json")
+	reader, _ := Open(path, passphrase)
+	writer, _ := Open(path, passphrase)
+	if _, err := writer.Rotate("acme", 0); err != nil {
+		t.Fatal(err)
+	}
+	// 确保 mtime 变化可被观察到
+	time.Sleep(10 * time.Millisecond)
+	if _, err := writer.Rotate("acme", 0); err != nil {
+		t.Fatal(err)
+	}
+	sealed, err := writer.Seal(util.AESGCM, "acme", AAD("acme", "r1"), []byte("hi"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if plaintext, err := reader.Open("acme", AAD("acme", "r1"), sealed); err != nil || string(plaintext) != "hi" {
+		t.Errorf("expected reader to pick up rotated key, got %q %v", plaintext, err)
+	}
+}
+
+func TestSealOpen(t *testing.T) {
+	s, _ := Open(filepath.Join(t.TempDir(), "keys.json"), passphrase)
+	_, _ = s.Rotate("acme", 0)
+	_, _ = s.Rotate("other", 0)
+
+	for _, c := range []util.Cipher{util.AESGCM, util.ChaCha20Poly1305} {
+		sealed, err := s.Seal(c, "acme", AAD("acme", "r1"), []byte(`{"model_name":"llama3"}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+		plaintext, err := s.Open("acme", AAD("acme", "r1"), sealed)
+		if err != nil || string(plaintext) != `{"model_name":"llama3"}` {
+			t.Fatalf("%s: round trip failed: %q %v", c, plaintext, err)
+		}
+
+		// 其他租户或改写 request_id 后无法解密
+		if _, err := s.Open("other", AAD("other", "r1"), sealed); !errors.Is(err, ErrTenantMismatch) {
+			t.Errorf("%s: expected cross-tenant open to fail, got %v", c, err)
+		}
+		if _, err := s.Open("acme", AAD("acme", "r2"), sealed); !errors.Is(err, ErrTenantMismatch) {
+			t.Errorf("%s: expected relabeled request to fail, got %v", c, err)
+		}
+	}
+}
+
+func TestOpenOldVersionAfterRotation(t *testing.T) {
+	s, _ := Open(filepath.Join(t.TempDir(), "keys.json"), passphrase)
+	_, _ = s.Rotate("acme", 0)
+	sealed, err := s.Seal(util.AESGCM, "acme", AAD("acme", "r1"), []byte("hi"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _ = s.Rotate("acme", 0)
+
+	// 轮换前发出的帧仍可解密
+	if plaintext, err := s.Open("acme", AAD("acme", "r1"), sealed); err != nil || string(plaintext) != "hi" {
+		t.Errorf("expected old version to decrypt, got %q %v", plaintext, err)
+	}
+	if id, err := sealed.KeyID(); err != nil || id != 1 {
+		t.Errorf("expected key ID 1 in the ciphertext header, got %d %v", id, err)
+	}
+}
@@ -0,0 +1,63 @@
+package keystore
+
+import (
+	"errors"
+	"fmt"
+
+	"ollama_dev/internal/util"
+)
+
+// ErrTenantMismatch 密文不属于解密方声明的租户，或帧在传输中被篡改
+var ErrTenantMismatch = errors.New("密文与租户或请求不匹配")
+
+// Sealed 端到端加密后的载荷，替代帧中的 params 或 data；算法与 key ID 记录在密文头部
+type Sealed struct {
+	Payload string `json:"payload"` // util.Keyring 的输出：base64(格式版本 || 算法 || key ID || nonce || ciphertext)
+}
+
+// KeyID 返回加密所用密钥的 ID，不需要密钥
+func (s *Sealed) KeyID() (uint32, error) {
+	return util.KeyID(s.Payload)
+}
+
+// AAD 密文绑定的附加数据：租户与 request_id，改写帧的 tenant_id 或 request_id 后无法解密
+func AAD(tenant, requestID string) []byte {
+	return []byte("ollama_dev/e2e\x00" + tenant + "\x00" + requestID)
+}
+
+// Seal 使用租户当前的密钥与算法 c 加密
+func (s *Store) Seal(c util.Cipher, tenant string, aad, plaintext []byte) (*Sealed, error) {
+	if tenant == "" {
+		return nil, fmt.Errorf("%w: 未指定租户", ErrNoKey)
+	}
+	ring, err := s.keyring(tenant)
+	if err != nil {
+		return nil, err
+	}
+	payload, err := ring.SealWith(c, plaintext, aad)
+	if err != nil {
+		return nil, fmt.Errorf("加密失败: %w", err)
+	}
+	return &Sealed{Payload: payload}, nil
+}
+
+// Open 按密文头部的 key ID 使用租户的密钥解密，只会查找该租户自己的密钥环，其他租户的帧必然失败
+func (s *Store) Open(tenant string, aad []byte, sealed *Sealed) ([]byte, error) {
+	if tenant == "" {
+		return nil, fmt.Errorf("%w: 未指定租户", ErrNoKey)
+	}
+	ring, err := s.keyring(tenant)
+	if err != nil {
+		return nil, err
+	}
+	plaintext, err := ring.Open(sealed.Payload, aad)
+	switch {
+	case errors.Is(err, util.ErrUnknownKey):
+		return nil, fmt.Errorf("%w: 租户 %q: %v", ErrNoKey, tenant, err)
+	case errors.Is(err, util.ErrMalformedCiphertext):
+		return nil, err
+	case err != nil:
+		return nil, fmt.Errorf("%w: %v", ErrTenantMismatch, err)
+	}
+	return plaintext, nil
+}
@@ -0,0 +1,92 @@
+package logging
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"log/slog"
+	"strings"
+)
+
+// 请求标识的属性名
+const (
+	RequestIDKey = "request_id"
+	TraceIDKey   = "trace_id"
+)
+
+type requestIDKey struct{}
+
+type traceIDKey struct{}
+
+// WithRequestID 在 ctx 中附带请求 ID，经 *Context 方法输出的日志自动带上 request_id
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// RequestID 返回 ctx 中的请求 ID，没有时返回空串
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// WithTraceID 在 ctx 中附带分布式追踪的 trace ID
+func WithTraceID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, traceIDKey{}, id)
+}
+
+// TraceID 返回 ctx 中的 trace ID，没有时返回空串
+func TraceID(ctx context.Context) string {
+	id, _ := ctx.Value(traceIDKey{}).(string)
+	return id
+}
+
+// NewRequestID 生成 16 位十六进制的随机请求 ID
+func NewRequestID() string {
+	var b [8]byte
+	_, _ = rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
+
+// ParseTraceparent 从 W3C traceparent 请求头 (version-traceid-parentid-flags) 中取出 trace ID
+func ParseTraceparent(header string) (string, bool) {
+	parts := strings.Split(strings.TrimSpace(header), "-")
+	if len(parts) != 4 || len(parts[0]) != 2 || len(parts[1]) != 32 || len(parts[2]) != 16 || len(parts[3]) != 2 {
+		return "", false
+	}
+	trace := strings.ToLower(parts[1])
+	if _, err := hex.DecodeString(trace); err != nil || trace == strings.Repeat("0", 32) {
+		return "", false
+	}
+	return trace, true
+}
+
+// contextHandler 输出前从 ctx 中取出请求 ID 与 trace ID 追加到记录上
+type contextHandler struct {
+	slog.Handler
+}
+
+// NewContextHandler 包装 next，使 InfoContext 等方法输出的日志带上 ctx 中的 request_id 与 trace_id
+func NewContextHandler(next slog.Handler) slog.Handler {
+	return contextHandler{next}
+}
+
+func (h contextHandler) Handle(ctx context.Context, r slog.Record) error {
+	if ctx == nil {
+		return h.Handler.Handle(ctx, r)
+	}
+	if id := RequestID(ctx); id != "" {
+		r.AddAttrs(slog.String(RequestIDKey, id))
+	}
+	if id := TraceID(ctx); id != "" {
+		r.AddAttrs(slog.String(TraceIDKey, id))
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return contextHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h contextHandler) WithGroup(name string) slog.Handler {
+	return contextHandler{h.Handler.WithGroup(name)}
+}
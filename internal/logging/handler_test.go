@@ -0,0 +1,146 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+
+	"ollama_dev/internal/config"
+)
+
+func TestRedactHandler(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(NewRedactHandler(slog.NewJSONHandler(&buf, nil), []string{"token", "Prompt"}))
+
+	logger.With("token", "secret-token").Info("request",
+		"prompt", "tell me a secret",
+		slog.Group("params", "prompt", "nested secret", "model", "llama3"),
+		"model", "llama3",
+	)
+
+	out := buf.String()
+	for _, secret := range []string{"secret-token", "tell me a secret", "nested secret"} {
+		if strings.Contains(out, secret) {
+			t.Errorf("secret %q leaked: %s", secret, out)
+		}
+	}
+	if !strings.Contains(out, RedactedValue) || !strings.Contains(out, "llama3") {
+		t.Errorf("unexpected output: %s", out)
+	}
+}
+
+func TestSamplingHandler(t *testing.T) {
+	var buf bytes.Buffer
+	handler := NewSamplingHandler(slog.NewTextHandler(&buf, nil), config.LogSamplingConfig{
+		Initial:    2,
+		Thereafter: 3,
+		Interval:   time.Minute,
+	}).(*SamplingHandler)
+
+	now := time.Now()
+	handler.state.now = func() time.Time { return now }
+	logger := slog.New(handler)
+
+	// 前 2 条输出，之后第 5、8 条输出
+	for i := 0; i < 8; i++ {
+		logger.Info("heartbeat")
+	}
+	// Error 不采样
+	for i := 0; i < 5; i++ {
+		logger.Error("boom")
+	}
+	if got := strings.Count(buf.String(), "heartbeat"); got != 4 {
+		t.Errorf("expected 4 sampled heartbeat lines, got %d", got)
+	}
+	if got := strings.Count(buf.String(), "boom"); got != 5 {
+		t.Errorf("expected all 5 error lines, got %d", got)
+	}
+
+	// 进入新周期后重新计数
+	buf.Reset()
+	now = now.Add(2 * time.Minute)
+	logger.Info("heartbeat")
+	if got := strings.Count(buf.String(), "heartbeat"); got != 1 {
+		t.Errorf("expected counter reset after interval, got %d lines", got)
+	}
+}
+
+func TestThrottleHandler(t *testing.T) {
+	var buf bytes.Buffer
+	h, stop := NewThrottleHandler(slog.NewTextHandler(&buf, nil), config.LogThrottleConfig{Window: time.Hour})
+	defer stop()
+	handler := h.(*ThrottleHandler)
+	now := time.Now()
+	handler.state.now = func() time.Time { return now }
+
+	logger := slog.New(handler).With(ComponentKey, "bridge")
+	for i := 0; i < 5; i++ {
+		logger.Error("读取超时", "error", "i/o timeout")
+	}
+	logger.Error("读取超时", "error", "connection reset") // 不同的 error 单独计数
+	logger.Info("info 不折叠")
+	logger.Info("info 不折叠")
+
+	if got := strings.Count(buf.String(), "读取超时"); got != 2 {
+		t.Fatalf("expected 2 error lines inside the window, got %d:\n%s", got, buf.String())
+	}
+	if got := strings.Count(buf.String(), "info 不折叠"); got != 2 {
+		t.Errorf("info records should not be throttled, got %d", got)
+	}
+
+	// 窗口结束后输出摘要，保留 With 添加的属性
+	now = now.Add(2 * time.Hour)
+	handler.state.flush(false)
+	out := buf.String()
+	if !strings.Contains(out, SuppressedKey+"=4") || !strings.Contains(out, "component=bridge") {
+		t.Errorf("expected a summary with suppressed=4, got:\n%s", out)
+	}
+}
+
+func TestContextHandlerAddsIDs(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(NewContextHandler(slog.NewJSONHandler(&buf, nil))).With("component", "api")
+
+	ctx := WithTraceID(WithRequestID(context.Background(), "req-1"), "4bf92f3577b34da6a3ce929d0e0e4736")
+	logger.InfoContext(ctx, "handled")
+	logger.Info("no context")
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected 2 lines, got %q", buf.String())
+	}
+	var first, second map[string]any
+	if err := json.Unmarshal([]byte(lines[0]), &first); err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal([]byte(lines[1]), &second); err != nil {
+		t.Fatal(err)
+	}
+	if first[RequestIDKey] != "req-1" || first[TraceIDKey] != "4bf92f3577b34da6a3ce929d0e0e4736" || first["component"] != "api" {
+		t.Errorf("unexpected record: %v", first)
+	}
+	if _, ok := second[RequestIDKey]; ok {
+		t.Errorf("record without context should not carry request_id: %v", second)
+	}
+}
+
+func TestParseTraceparent(t *testing.T) {
+	cases := map[string]string{
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01": "4bf92f3577b34da6a3ce929d0e0e4736",
+		"00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-00": "4bf92f3577b34da6a3ce929d0e0e4736",
+		"00-00000000000000000000000000000000-00f067aa0ba902b7-01": "",
+		"00-4bf92f3577b34da6a3ce929d0e0e473z-00f067aa0ba902b7-01": "",
+		"garbage": "",
+		"":        "",
+	}
+	for header, want := range cases {
+		got, ok := ParseTraceparent(header)
+		if got != want || ok != (want != "") {
+			t.Errorf("ParseTraceparent(%q) = %q, %v; want %q", header, got, ok, want)
+		}
+	}
+}
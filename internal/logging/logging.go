@@ -0,0 +1,120 @@
+package logging
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+
+	"ollama_dev/internal/config"
+)
+
+// ComponentKey 组件日志的属性名
+const ComponentKey = "component"
+
+// level 当前进程的日志级别，可在运行期间通过 SetLevel 调整
+var level = new(slog.LevelVar)
+
+// New 根据配置构建 slog.Logger，返回的 close 函数用于关闭打开的日志文件
+func New(cfg config.LogConfig) (*slog.Logger, func() error, error) {
+	if err := SetLevel(cfg.Level); err != nil {
+		return nil, nil, err
+	}
+
+	out, closers, err := openOutputs(cfg.Outputs, cfg.Rotate)
+	if err != nil {
+		return nil, nil, err
+	}
+	closeFn := func() error {
+		var errs []error
+		for _, c := range closers {
+			errs = append(errs, c.Close())
+		}
+		return errors.Join(errs...)
+	}
+
+	opts := &slog.HandlerOptions{Level: level}
+	var handler slog.Handler
+	switch strings.ToLower(cfg.Format) {
+	case "", "text":
+		handler = slog.NewTextHandler(out, opts)
+	case "json":
+		handler = slog.NewJSONHandler(out, opts)
+	default:
+		_ = closeFn()
+		return nil, nil, fmt.Errorf("未知的日志格式: %s (可选 text、json)", cfg.Format)
+	}
+
+	handler = NewRedactHandler(handler, cfg.Redact)
+	handler, stopThrottle := NewThrottleHandler(handler, cfg.Throttle)
+	handler = NewSamplingHandler(handler, cfg.Sampling)
+	handler = NewContextHandler(handler)
+
+	// 先输出剩余的折叠摘要再关闭日志文件
+	return slog.New(handler), func() error {
+		stopThrottle()
+		return closeFn()
+	}, nil
+}
+
+// SetLevel 调整所有由 New 创建的 Logger 的日志级别
+func SetLevel(s string) error {
+	l, err := ParseLevel(s)
+	if err != nil {
+		return err
+	}
+	level.Set(l)
+	return nil
+}
+
+// Component 返回带组件名的子 Logger
+func Component(logger *slog.Logger, name string) *slog.Logger {
+	return logger.With(ComponentKey, name)
+}
+
+// ParseLevel 解析 debug/info/warn/error 日志级别
+func ParseLevel(s string) (slog.Level, error) {
+	var level slog.Level
+	if s == "" {
+		return slog.LevelInfo, nil
+	}
+	if err := level.UnmarshalText([]byte(s)); err != nil {
+		return level, fmt.Errorf("未知的日志级别: %s (可选 debug、info、warn、error)", s)
+	}
+	return level, nil
+}
+
+// openOutputs 打开所有输出目标：stdout、stderr 或文件路径，文件按 rotate 轮转
+func openOutputs(targets []string, rotate config.LogRotateConfig) (io.Writer, []io.Closer, error) {
+	if len(targets) == 0 {
+		return os.Stdout, nil, nil
+	}
+
+	var writers []io.Writer
+	var closers []io.Closer
+	for _, target := range targets {
+		switch target {
+		case "stdout":
+			writers = append(writers, os.Stdout)
+		case "stderr":
+			writers = append(writers, os.Stderr)
+		default:
+			f, err := openRotating(target, rotate)
+			if err != nil {
+				for _, c := range closers {
+					_ = c.Close()
+				}
+				return nil, nil, fmt.Errorf("打开日志文件失败: %w", err)
+			}
+			writers = append(writers, f)
+			closers = append(closers, f)
+		}
+	}
+
+	if len(writers) == 1 {
+		return writers[0], closers, nil
+	}
+	return io.MultiWriter(writers...), closers, nil
+}
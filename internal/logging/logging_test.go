@@ -0,0 +1,115 @@
+package logging
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"ollama_dev/internal/config"
+)
+
+func TestNewJSONFileOutput(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+	logger, closeLog, err := New(config.LogConfig{Level: "warn", Format: "json", Outputs: []string{path}})
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+
+	Component(logger, "bridge").Info("filtered by level")
+	Component(logger, "bridge").Warn("kept", "key", "value")
+	if err := closeLog(); err != nil {
+		t.Fatalf("close failed: %v", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read log failed: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) != 1 {
+		t.Fatalf("expected 1 log line, got %d: %s", len(lines), data)
+	}
+
+	var record map[string]any
+	if err := json.Unmarshal([]byte(lines[0]), &record); err != nil {
+		t.Fatalf("log line is not JSON: %v", err)
+	}
+	if record[ComponentKey] != "bridge" || record["msg"] != "kept" {
+		t.Errorf("unexpected record: %v", record)
+	}
+}
+
+func TestNewRejectsInvalidConfig(t *testing.T) {
+	if _, _, err := New(config.LogConfig{Level: "verbose"}); err == nil {
+		t.Error("expected an error for an unknown level, but got none")
+	}
+	if _, _, err := New(config.LogConfig{Format: "xml"}); err == nil {
+		t.Error("expected an error for an unknown format, but got none")
+	}
+}
+
+func TestRotatingFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+	r, err := openRotating(path, config.LogRotateConfig{MaxSize: 1, MaxBackups: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	// 每条 400KiB，写入 5 条：每个文件最多容纳 2 条，共轮转两次
+	line := []byte(strings.Repeat("x", 400<<10-1) + "\n")
+	for i := 0; i < 5; i++ {
+		if _, err := r.Write(line); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for name, want := range map[string]int64{
+		path:        1 * int64(len(line)),
+		path + ".1": 2 * int64(len(line)),
+		path + ".2": 2 * int64(len(line)),
+	} {
+		info, err := os.Stat(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info.Size() != want {
+			t.Errorf("%s size = %d, want %d", filepath.Base(name), info.Size(), want)
+		}
+	}
+
+	// 再轮转一次后最早的历史文件被删除，只保留 max_backups 个
+	for i := 0; i < 2; i++ {
+		if _, err := r.Write(line); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := os.Stat(path + ".3"); !os.IsNotExist(err) {
+		t.Errorf("expected at most 2 backups, stat .3: %v", err)
+	}
+}
+
+func TestRotatingFileCountsExistingContent(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+	if err := os.WriteFile(path, []byte(strings.Repeat("x", 1<<20)), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	r, err := openRotating(path, config.LogRotateConfig{MaxSize: 1, MaxBackups: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	// 已有内容达到上限，第一次写入即轮转
+	if _, err := r.Write([]byte("new\n")); err != nil {
+		t.Fatal(err)
+	}
+	if data, err := os.ReadFile(path); err != nil || string(data) != "new\n" {
+		t.Errorf("current file = %q, %v", data, err)
+	}
+	if info, err := os.Stat(path + ".1"); err != nil || info.Size() != 1<<20 {
+		t.Errorf("backup = %v, %v", info, err)
+	}
+}
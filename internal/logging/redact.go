@@ -0,0 +1,72 @@
+package logging
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+)
+
+// RedactedValue 敏感字段被替换后的值
+const RedactedValue = "***REDACTED***"
+
+// RedactHandler 在输出前将配置的敏感字段替换为 RedactedValue
+type RedactHandler struct {
+	next   slog.Handler
+	fields map[string]struct{}
+}
+
+// NewRedactHandler 包装 next，字段名匹配不区分大小写，包括分组内的字段
+func NewRedactHandler(next slog.Handler, fields []string) slog.Handler {
+	if len(fields) == 0 {
+		return next
+	}
+	set := make(map[string]struct{}, len(fields))
+	for _, f := range fields {
+		set[strings.ToLower(f)] = struct{}{}
+	}
+	return &RedactHandler{next: next, fields: set}
+}
+
+func (h *RedactHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.next.Enabled(ctx, level)
+}
+
+func (h *RedactHandler) Handle(ctx context.Context, r slog.Record) error {
+	redacted := slog.NewRecord(r.Time, r.Level, r.Message, r.PC)
+	r.Attrs(func(a slog.Attr) bool {
+		redacted.AddAttrs(h.redact(a))
+		return true
+	})
+	return h.next.Handle(ctx, redacted)
+}
+
+func (h *RedactHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	redacted := make([]slog.Attr, 0, len(attrs))
+	for _, a := range attrs {
+		redacted = append(redacted, h.redact(a))
+	}
+	return &RedactHandler{next: h.next.WithAttrs(redacted), fields: h.fields}
+}
+
+func (h *RedactHandler) WithGroup(name string) slog.Handler {
+	return &RedactHandler{next: h.next.WithGroup(name), fields: h.fields}
+}
+
+// redact 替换敏感字段，递归处理分组
+func (h *RedactHandler) redact(a slog.Attr) slog.Attr {
+	if _, ok := h.fields[strings.ToLower(a.Key)]; ok {
+		return slog.String(a.Key, RedactedValue)
+	}
+
+	v := a.Value.Resolve()
+	if v.Kind() != slog.KindGroup {
+		return slog.Attr{Key: a.Key, Value: v}
+	}
+
+	group := v.Group()
+	redacted := make([]slog.Attr, 0, len(group))
+	for _, ga := range group {
+		redacted = append(redacted, h.redact(ga))
+	}
+	return slog.Attr{Key: a.Key, Value: slog.GroupValue(redacted...)}
+}
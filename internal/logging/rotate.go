@@ -0,0 +1,91 @@
+package logging
+
+import (
+	"fmt"
+	"os"
+	"sync"
+
+	"ollama_dev/internal/config"
+)
+
+// rotatingFile 按大小轮转的日志文件：写入将超过 maxSize 时把当前文件重命名为 path.1，
+// 已有的 path.N 依次后移，超出 maxBackups 的被删除，然后新建 path 继续写入
+type rotatingFile struct {
+	mu         sync.Mutex
+	path       string
+	maxSize    int64 // 0 表示不轮转
+	maxBackups int
+	f          *os.File
+	size       int64
+}
+
+// openRotating 以追加方式打开 path，已有内容计入当前大小
+func openRotating(path string, cfg config.LogRotateConfig) (*rotatingFile, error) {
+	r := &rotatingFile{path: path, maxSize: int64(cfg.MaxSize) << 20, maxBackups: cfg.MaxBackups}
+	if err := r.open(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *rotatingFile) open() error {
+	f, err := os.OpenFile(r.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		_ = f.Close()
+		return err
+	}
+	r.f, r.size = f, info.Size()
+	return nil
+}
+
+// Write 写入一条日志，单条超过 maxSize 时仍完整写入新文件
+func (r *rotatingFile) Write(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.maxSize > 0 && r.size > 0 && r.size+int64(len(p)) > r.maxSize {
+		if err := r.rotate(); err != nil {
+			return 0, fmt.Errorf("轮转日志文件失败: %w", err)
+		}
+	}
+	n, err := r.f.Write(p)
+	r.size += int64(n)
+	return n, err
+}
+
+// rotate 关闭当前文件，依次后移历史文件并新建 path
+func (r *rotatingFile) rotate() error {
+	if err := r.f.Close(); err != nil {
+		return err
+	}
+	if r.maxBackups == 0 {
+		if err := os.Remove(r.path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return r.open()
+	}
+	_ = os.Remove(backupName(r.path, r.maxBackups))
+	for i := r.maxBackups - 1; i >= 1; i-- {
+		if err := os.Rename(backupName(r.path, i), backupName(r.path, i+1)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	if err := os.Rename(r.path, backupName(r.path, 1)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return r.open()
+}
+
+func (r *rotatingFile) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.f.Close()
+}
+
+func backupName(path string, n int) string {
+	return fmt.Sprintf("%s.%d", path, n)
+}
@@ -0,0 +1,88 @@
+package logging
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+
+	"ollama_dev/internal/config"
+)
+
+// SamplingHandler 对重复消息进行采样：每个周期内同一级别同一消息
+// 先输出前 Initial 条，之后每 Thereafter 条输出一条。Error 及以上级别不采样。
+type SamplingHandler struct {
+	next  slog.Handler
+	state *samplingState
+}
+
+type samplingState struct {
+	mu         sync.Mutex
+	initial    int
+	thereafter int
+	interval   time.Duration
+	resetAt    time.Time
+	counts     map[samplingKey]int
+	now        func() time.Time
+}
+
+type samplingKey struct {
+	level slog.Level
+	msg   string
+}
+
+// NewSamplingHandler 包装 next，Initial 为 0 时不启用采样
+func NewSamplingHandler(next slog.Handler, cfg config.LogSamplingConfig) slog.Handler {
+	if cfg.Initial <= 0 || cfg.Interval <= 0 {
+		return next
+	}
+	return &SamplingHandler{
+		next: next,
+		state: &samplingState{
+			initial:    cfg.Initial,
+			thereafter: cfg.Thereafter,
+			interval:   cfg.Interval,
+			counts:     make(map[samplingKey]int),
+			now:        time.Now,
+		},
+	}
+}
+
+func (h *SamplingHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.next.Enabled(ctx, level)
+}
+
+func (h *SamplingHandler) Handle(ctx context.Context, r slog.Record) error {
+	if r.Level >= slog.LevelError || h.state.allow(samplingKey{level: r.Level, msg: r.Message}) {
+		return h.next.Handle(ctx, r)
+	}
+	return nil
+}
+
+func (h *SamplingHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &SamplingHandler{next: h.next.WithAttrs(attrs), state: h.state}
+}
+
+func (h *SamplingHandler) WithGroup(name string) slog.Handler {
+	return &SamplingHandler{next: h.next.WithGroup(name), state: h.state}
+}
+
+// allow 记录一次消息并判断是否输出
+func (s *samplingState) allow(key samplingKey) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	if now.After(s.resetAt) {
+		// 每个周期重置计数，同时释放不再出现的消息
+		s.counts = make(map[samplingKey]int)
+		s.resetAt = now.Add(s.interval)
+	}
+
+	s.counts[key]++
+	n := s.counts[key]
+	if n <= s.initial {
+		return true
+	}
+	return s.thereafter > 0 && (n-s.initial)%s.thereafter == 0
+}
@@ -0,0 +1,155 @@
+package logging
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+
+	"ollama_dev/internal/config"
+)
+
+// SuppressedKey 折叠摘要中被省略条数的属性名
+const SuppressedKey = "suppressed"
+
+// ThrottleHandler 折叠重复的 Warn 及以上级别日志：同一级别、消息与 error 属性的日志
+// 每个窗口只输出第一条，窗口结束后输出一条带 suppressed 计数的摘要
+type ThrottleHandler struct {
+	next  slog.Handler
+	state *throttleState
+}
+
+type throttleState struct {
+	mu      sync.Mutex
+	window  time.Duration
+	entries map[throttleKey]*throttleEntry
+	now     func() time.Time
+}
+
+type throttleKey struct {
+	level slog.Level
+	msg   string
+	err   string
+}
+
+type throttleEntry struct {
+	next       slog.Handler // 被折叠日志所属的 Handler，保留 With 添加的属性
+	last       slog.Record  // 最后一条被折叠的日志
+	suppressed int
+	windowEnd  time.Time
+}
+
+// NewThrottleHandler 包装 next，window 为 0 时不折叠；返回的 stop 函数停止后台汇总并输出剩余摘要
+func NewThrottleHandler(next slog.Handler, cfg config.LogThrottleConfig) (slog.Handler, func()) {
+	if cfg.Window <= 0 {
+		return next, func() {}
+	}
+	state := &throttleState{
+		window:  cfg.Window,
+		entries: make(map[throttleKey]*throttleEntry),
+		now:     time.Now,
+	}
+
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		ticker := time.NewTicker(cfg.Window)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				state.flush(true)
+				return
+			case <-ticker.C:
+				state.flush(false)
+			}
+		}
+	}()
+
+	var once sync.Once
+	stop := func() {
+		once.Do(func() {
+			close(done)
+			wg.Wait()
+		})
+	}
+	return &ThrottleHandler{next: next, state: state}, stop
+}
+
+func (h *ThrottleHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.next.Enabled(ctx, level)
+}
+
+func (h *ThrottleHandler) Handle(ctx context.Context, r slog.Record) error {
+	if r.Level < slog.LevelWarn {
+		return h.next.Handle(ctx, r)
+	}
+	if !h.state.allow(h.next, r) {
+		return nil
+	}
+	return h.next.Handle(ctx, r)
+}
+
+func (h *ThrottleHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &ThrottleHandler{next: h.next.WithAttrs(attrs), state: h.state}
+}
+
+func (h *ThrottleHandler) WithGroup(name string) slog.Handler {
+	return &ThrottleHandler{next: h.next.WithGroup(name), state: h.state}
+}
+
+// allow 判断日志是否输出，窗口内的重复日志只计数
+func (s *throttleState) allow(next slog.Handler, r slog.Record) bool {
+	key := throttleKey{level: r.Level, msg: r.Message}
+	r.Attrs(func(a slog.Attr) bool {
+		if a.Key == "error" {
+			key.err = a.Value.String()
+			return false
+		}
+		return true
+	})
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	entry, ok := s.entries[key]
+	if ok && now.Before(entry.windowEnd) {
+		entry.suppressed++
+		entry.next = next
+		entry.last = r.Clone()
+		return false
+	}
+	if ok && entry.suppressed > 0 {
+		emitSummary(entry)
+	}
+	s.entries[key] = &throttleEntry{windowEnd: now.Add(s.window)}
+	return true
+}
+
+// flush 输出已结束窗口的摘要并清理条目，all 为 true 时输出全部摘要
+func (s *throttleState) flush(all bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	for key, entry := range s.entries {
+		if !all && now.Before(entry.windowEnd) {
+			continue
+		}
+		if entry.suppressed > 0 {
+			emitSummary(entry)
+		}
+		delete(s.entries, key)
+	}
+}
+
+// emitSummary 以最后一条被折叠的日志为模板输出摘要
+func emitSummary(entry *throttleEntry) {
+	summary := entry.last.Clone()
+	summary.Time = time.Now()
+	summary.AddAttrs(slog.Int(SuppressedKey, entry.suppressed))
+	_ = entry.next.Handle(context.Background(), summary)
+}
@@ -0,0 +1,334 @@
+// Package metrics 以 Prometheus 文本格式导出计数器、仪表与直方图，
+// 各包在包级变量中创建指标，Handler 输出全部已创建的指标
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"math"
+	"net/http"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// Path serve 与 bridge 指标端口上的路径
+const Path = "/metrics"
+
+// DefBuckets 请求耗时直方图的默认分桶，单位秒
+var DefBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60, 120}
+
+// metric 可以输出为文本格式的指标
+type metric interface {
+	write(w io.Writer)
+}
+
+var (
+	mu      sync.RWMutex
+	metrics = map[string]metric{}
+)
+
+// register 名称重复时 panic，与 expvar.Publish 一致
+func register(name string, m metric) {
+	mu.Lock()
+	defer mu.Unlock()
+	if _, dup := metrics[name]; dup {
+		panic("metrics: 指标 " + name + " 重复创建")
+	}
+	metrics[name] = m
+}
+
+// series 一组标签取值对应的时间序列，按标签取值的组合查找
+type series[T any] struct {
+	labels []string
+	mu     sync.RWMutex
+	values map[string]*T
+	keys   map[string][]string // 组合键 -> 标签取值
+	newT   func() *T
+}
+
+func newSeries[T any](labels []string, newT func() *T) *series[T] {
+	return &series[T]{labels: labels, values: map[string]*T{}, keys: map[string][]string{}, newT: newT}
+}
+
+// with 返回标签取值对应的序列，取值个数与标签不一致时 panic
+func (s *series[T]) with(values []string) *T {
+	if len(values) != len(s.labels) {
+		panic(fmt.Sprintf("metrics: 需要 %d 个标签取值，实际为 %d", len(s.labels), len(values)))
+	}
+	key := strings.Join(values, "\xff")
+	s.mu.RLock()
+	v, ok := s.values[key]
+	s.mu.RUnlock()
+	if ok {
+		return v
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if v, ok := s.values[key]; ok {
+		return v
+	}
+	v = s.newT()
+	s.values[key] = v
+	s.keys[key] = slices.Clone(values)
+	return v
+}
+
+// each 按标签取值排序遍历，保证输出稳定
+func (s *series[T]) each(fn func(labels string, v *T)) {
+	s.mu.RLock()
+	keys := make([]string, 0, len(s.values))
+	for k := range s.values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	type item struct {
+		labels string
+		v      *T
+	}
+	items := make([]item, 0, len(keys))
+	for _, k := range keys {
+		items = append(items, item{formatLabels(s.labels, s.keys[k]), s.values[k]})
+	}
+	s.mu.RUnlock()
+	for _, it := range items {
+		fn(it.labels, it.v)
+	}
+}
+
+// Counter 只增不减的计数器
+type Counter struct {
+	name, help string
+	series     *series[atomicFloat]
+}
+
+// NewCounter 创建计数器，labels 为标签名
+func NewCounter(name, help string, labels ...string) *Counter {
+	c := &Counter{name: name, help: help, series: newSeries(labels, func() *atomicFloat { return new(atomicFloat) })}
+	if len(labels) == 0 {
+		// 没有标签时从 0 开始输出
+		c.series.with(nil)
+	}
+	register(name, c)
+	return c
+}
+
+// Add 按标签取值增加 v，v 不能为负数
+func (c *Counter) Add(v float64, labelValues ...string) {
+	c.series.with(labelValues).add(v)
+}
+
+func (c *Counter) Inc(labelValues ...string) {
+	c.Add(1, labelValues...)
+}
+
+// Value 返回标签取值对应的当前值
+func (c *Counter) Value(labelValues ...string) float64 {
+	return c.series.with(labelValues).load()
+}
+
+func (c *Counter) write(w io.Writer) {
+	header(w, c.name, c.help, "counter")
+	c.series.each(func(labels string, v *atomicFloat) {
+		sample(w, c.name, labels, v.load())
+	})
+}
+
+// Gauge 可增可减的仪表
+type Gauge struct {
+	name, help string
+	series     *series[atomicFloat]
+}
+
+// NewGauge 创建仪表，labels 为标签名
+func NewGauge(name, help string, labels ...string) *Gauge {
+	g := &Gauge{name: name, help: help, series: newSeries(labels, func() *atomicFloat { return new(atomicFloat) })}
+	if len(labels) == 0 {
+		g.series.with(nil)
+	}
+	register(name, g)
+	return g
+}
+
+func (g *Gauge) Set(v float64, labelValues ...string) {
+	g.series.with(labelValues).store(v)
+}
+
+func (g *Gauge) Add(v float64, labelValues ...string) {
+	g.series.with(labelValues).add(v)
+}
+
+func (g *Gauge) write(w io.Writer) {
+	header(w, g.name, g.help, "gauge")
+	g.series.each(func(labels string, v *atomicFloat) {
+		sample(w, g.name, labels, v.load())
+	})
+}
+
+// gaugeFunc 输出时调用函数取值的仪表
+type gaugeFunc struct {
+	name, help string
+	fn         func() float64
+}
+
+// NewGaugeFunc 创建输出时调用 fn 取值的仪表，用于比值等由其他指标计算的值
+func NewGaugeFunc(name, help string, fn func() float64) {
+	register(name, &gaugeFunc{name: name, help: help, fn: fn})
+}
+
+func (g *gaugeFunc) write(w io.Writer) {
+	header(w, g.name, g.help, "gauge")
+	sample(w, g.name, "", g.fn())
+}
+
+// Histogram 按分桶统计观测值的分布
+type Histogram struct {
+	name, help string
+	buckets    []float64
+	series     *series[histogramState]
+}
+
+type histogramState struct {
+	mu     sync.Mutex
+	counts []uint64 // 每个分桶的累计个数，最后一个为 +Inf
+	sum    float64
+}
+
+// NewHistogram 创建直方图，buckets 为递增的上界
+func NewHistogram(name, help string, buckets []float64, labels ...string) *Histogram {
+	h := &Histogram{name: name, help: help, buckets: buckets}
+	h.series = newSeries(labels, func() *histogramState {
+		return &histogramState{counts: make([]uint64, len(buckets)+1)}
+	})
+	register(name, h)
+	return h
+}
+
+func (h *Histogram) Observe(v float64, labelValues ...string) {
+	s := h.series.with(labelValues)
+	i := sort.SearchFloat64s(h.buckets, v)
+	s.mu.Lock()
+	s.counts[i]++
+	s.sum += v
+	s.mu.Unlock()
+}
+
+func (h *Histogram) write(w io.Writer) {
+	header(w, h.name, h.help, "histogram")
+	h.series.each(func(labels string, s *histogramState) {
+		s.mu.Lock()
+		counts, sum := slices.Clone(s.counts), s.sum
+		s.mu.Unlock()
+		var total uint64
+		for i, n := range counts {
+			total += n
+			le := "+Inf"
+			if i < len(h.buckets) {
+				le = strconv.FormatFloat(h.buckets[i], 'g', -1, 64)
+			}
+			sample(w, h.name+"_bucket", joinLabels(labels, `le="`+le+`"`), float64(total))
+		}
+		sample(w, h.name+"_sum", labels, sum)
+		sample(w, h.name+"_count", labels, float64(total))
+	})
+}
+
+// atomicFloat 以 uint64 位模式保存的 float64
+type atomicFloat struct {
+	bits atomic.Uint64
+}
+
+func (f *atomicFloat) load() float64 {
+	return math.Float64frombits(f.bits.Load())
+}
+
+func (f *atomicFloat) store(v float64) {
+	f.bits.Store(math.Float64bits(v))
+}
+
+func (f *atomicFloat) add(v float64) {
+	for {
+		old := f.bits.Load()
+		if f.bits.CompareAndSwap(old, math.Float64bits(math.Float64frombits(old)+v)) {
+			return
+		}
+	}
+}
+
+func header(w io.Writer, name, help, typ string) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, strings.ReplaceAll(help, "\n", " "), name, typ)
+}
+
+func sample(w io.Writer, name, labels string, v float64) {
+	if labels != "" {
+		labels = "{" + labels + "}"
+	}
+	fmt.Fprintf(w, "%s%s %s\n", name, labels, strconv.FormatFloat(v, 'g', -1, 64))
+}
+
+// formatLabels 输出 name="value" 列表，不含花括号
+func formatLabels(names, values []string) string {
+	parts := make([]string, len(names))
+	for i, name := range names {
+		parts[i] = name + `="` + escape(values[i]) + `"`
+	}
+	return strings.Join(parts, ",")
+}
+
+func joinLabels(a, b string) string {
+	if a == "" {
+		return b
+	}
+	return a + "," + b
+}
+
+var escaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func escape(s string) string {
+	return escaper.Replace(s)
+}
+
+// Write 按名称顺序输出全部指标
+func Write(w io.Writer) {
+	mu.RLock()
+	names := make([]string, 0, len(metrics))
+	for name := range metrics {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	list := make([]metric, 0, len(names))
+	for _, name := range names {
+		list = append(list, metrics[name])
+	}
+	mu.RUnlock()
+	for _, m := range list {
+		m.write(w)
+	}
+}
+
+// Handler 以 Prometheus 文本格式 (0.0.4) 输出全部指标
+func Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		Write(w)
+	})
+}
+
+// StartServer 在独立端口上提供 Path，addr 为空时不启动；指标不含请求内容，不需要认证
+func StartServer(logger *slog.Logger, addr string) {
+	if addr == "" {
+		return
+	}
+	mux := http.NewServeMux()
+	mux.Handle(Path, Handler())
+	go func() {
+		logger.Info("指标端口已启动", "addr", addr, "path", Path)
+		if err := http.ListenAndServe(addr, mux); err != nil {
+			logger.Error("指标端口运行错误", "error", err)
+		}
+	}()
+}
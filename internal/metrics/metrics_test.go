@@ -0,0 +1,52 @@
+package metrics
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestWriteTextFormat(t *testing.T) {
+	c := NewCounter("test_requests_total", "请求数", "action")
+	c.Inc("chat")
+	c.Add(2, `a"b`)
+	g := NewGauge("test_connected", "是否已连接")
+	h := NewHistogram("test_duration_seconds", "耗时", []float64{0.1, 1}, "action")
+	h.Observe(0.05, "chat")
+	h.Observe(0.5, "chat")
+	h.Observe(3, "chat")
+
+	var buf bytes.Buffer
+	Write(&buf)
+	out := buf.String()
+	for _, want := range []string{
+		"# TYPE test_requests_total counter\n",
+		`test_requests_total{action="chat"} 1` + "\n",
+		`test_requests_total{action="a\"b"} 2` + "\n",
+		// 无标签的指标从 0 开始输出
+		"test_connected 0\n",
+		`test_duration_seconds_bucket{action="chat",le="0.1"} 1` + "\n",
+		`test_duration_seconds_bucket{action="chat",le="1"} 2` + "\n",
+		`test_duration_seconds_bucket{action="chat",le="+Inf"} 3` + "\n",
+		`test_duration_seconds_sum{action="chat"} 3.55` + "\n",
+		`test_duration_seconds_count{action="chat"} 3` + "\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("missing %q in output:\n%s", want, out)
+		}
+	}
+	g.Set(1)
+	if c.Value("chat") != 1 {
+		t.Errorf("unexpected counter value %v", c.Value("chat"))
+	}
+}
+
+func TestRegisterDuplicatePanics(t *testing.T) {
+	NewCounter("test_dup_total", "")
+	defer func() {
+		if recover() == nil {
+			t.Error("expected panic on duplicate metric name")
+		}
+	}()
+	NewGauge("test_dup_total", "")
+}
@@ -0,0 +1,104 @@
+package middleware
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"ollama_dev/internal/config"
+)
+
+// CorsMiddleware 跨域中间件，按 server.cors_origins 与 server.cors 设置响应头，随配置热加载：
+// Origin 不在允许列表中时不写入跨域响应头，由浏览器拦截；预检请求 (带 Access-Control-Request-Method 的 OPTIONS) 直接返回 204
+func CorsMiddleware(store *config.Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		server := store.Get().Server
+		cors := server.CORS
+		origin := c.GetHeader("Origin")
+		preflight := c.Request.Method == http.MethodOptions && c.GetHeader("Access-Control-Request-Method") != ""
+
+		h := c.Writer.Header()
+		if origin != "" {
+			h.Add("Vary", "Origin")
+		}
+		allowed := ""
+		if origin != "" {
+			allowed = allowedOrigin(server.CorsOrigins, origin)
+		}
+		if allowed != "" {
+			h.Set("Access-Control-Allow-Origin", allowed)
+			if cors.Credentials {
+				h.Set("Access-Control-Allow-Credentials", "true")
+			}
+			if !preflight && len(cors.ExposeHeaders) > 0 {
+				h.Set("Access-Control-Expose-Headers", strings.Join(cors.ExposeHeaders, ", "))
+			}
+		}
+		if !preflight {
+			c.Next()
+			return
+		}
+
+		h.Add("Vary", "Access-Control-Request-Method")
+		h.Add("Vary", "Access-Control-Request-Headers")
+		if allowed != "" {
+			h.Set("Access-Control-Allow-Methods", strings.Join(cors.Methods, ", "))
+			if headers := allowHeaders(cors.Headers, c.GetHeader("Access-Control-Request-Headers")); headers != "" {
+				h.Set("Access-Control-Allow-Headers", headers)
+			}
+			if cors.MaxAge > 0 {
+				h.Set("Access-Control-Max-Age", strconv.Itoa(int(cors.MaxAge.Seconds())))
+			}
+		}
+		c.AbortWithStatus(http.StatusNoContent)
+	}
+}
+
+// allowedOrigin 返回应写入 Access-Control-Allow-Origin 的值，不允许时返回空
+func allowedOrigin(allowed []string, origin string) string {
+	for _, pattern := range allowed {
+		if pattern == "*" {
+			return "*"
+		}
+		if matchOrigin(pattern, origin) {
+			return origin
+		}
+	}
+	return ""
+}
+
+// OriginAllowed 判断 origin 是否在 patterns (写法同 server.cors_origins) 中，用于 /ws 握手的 Origin 校验
+func OriginAllowed(patterns []string, origin string) bool {
+	return allowedOrigin(patterns, origin) != ""
+}
+
+// matchOrigin 判断 origin 是否匹配 cors_origins 中的一项：完全相同 (不区分大小写)，
+// 或 * 处匹配非空的主机名片段或端口，例如 https://*.example.com 匹配 https://a.b.example.com 但不匹配 https://example.com
+func matchOrigin(pattern, origin string) bool {
+	pattern, origin = strings.ToLower(pattern), strings.ToLower(origin)
+	prefix, suffix, wildcard := strings.Cut(pattern, "*")
+	if !wildcard {
+		return pattern == origin
+	}
+	if len(origin) <= len(prefix)+len(suffix) || !strings.HasPrefix(origin, prefix) || !strings.HasSuffix(origin, suffix) {
+		return false
+	}
+	for _, r := range origin[len(prefix) : len(origin)-len(suffix)] {
+		if !(r >= 'a' && r <= 'z' || r >= '0' && r <= '9' || r == '-' || r == '.') {
+			return false
+		}
+	}
+	return true
+}
+
+// allowHeaders 返回预检响应的 Access-Control-Allow-Headers，配置为 "*" 时原样允许请求的头
+func allowHeaders(headers []string, requested string) string {
+	for _, h := range headers {
+		if h == "*" {
+			return requested
+		}
+	}
+	return strings.Join(headers, ", ")
+}
@@ -0,0 +1,81 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"ollama_dev/internal/config"
+)
+
+func corsRouter(origins []string, credentials bool) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	cfg := config.Default()
+	cfg.Server.CorsOrigins = origins
+	cfg.Server.CORS.Credentials = credentials
+	r := gin.New()
+	r.Use(CorsMiddleware(config.NewStore("", cfg)))
+	r.GET("/api/version", func(c *gin.Context) { c.Status(http.StatusOK) })
+	return r
+}
+
+func corsRequest(r *gin.Engine, method, origin string, headers map[string]string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, "/api/version", nil)
+	req.Header.Set("Origin", origin)
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestCorsAllowlist(t *testing.T) {
+	r := corsRouter([]string{"https://app.example.com", "https://*.example.org"}, true)
+	for origin, want := range map[string]string{
+		"https://app.example.com":    "https://app.example.com",
+		"https://a.b.example.org":    "https://a.b.example.org",
+		"https://example.org":        "",
+		"https://evil.com":           "",
+		"https://app.example.com.cn": "",
+	} {
+		w := corsRequest(r, http.MethodGet, origin, nil)
+		if got := w.Header().Get("Access-Control-Allow-Origin"); got != want {
+			t.Errorf("%s: Allow-Origin = %q, want %q", origin, got, want)
+		}
+		if w.Code != http.StatusOK {
+			t.Errorf("%s: status = %d", origin, w.Code)
+		}
+		// 允许的 Origin 才附带凭据与可读取的响应头
+		if cred := w.Header().Get("Access-Control-Allow-Credentials"); (cred == "true") != (want != "") {
+			t.Errorf("%s: Allow-Credentials = %q", origin, cred)
+		}
+		if expose := w.Header().Get("Access-Control-Expose-Headers"); (expose == "Retry-After, X-Request-ID") != (want != "") {
+			t.Errorf("%s: Expose-Headers = %q", origin, expose)
+		}
+	}
+}
+
+func TestCorsPreflight(t *testing.T) {
+	r := corsRouter([]string{"*"}, false)
+	w := corsRequest(r, http.MethodOptions, "https://app.example.com", map[string]string{
+		"Access-Control-Request-Method":  "POST",
+		"Access-Control-Request-Headers": "content-type",
+	})
+	h := w.Header()
+	if w.Code != http.StatusNoContent || h.Get("Access-Control-Allow-Origin") != "*" ||
+		h.Get("Access-Control-Allow-Methods") != "GET, POST, PUT, DELETE" ||
+		h.Get("Access-Control-Allow-Headers") != "Content-Type, Authorization" ||
+		h.Get("Access-Control-Max-Age") != "600" || h.Get("Access-Control-Allow-Credentials") != "" {
+		t.Errorf("status = %d, headers = %v", w.Code, h)
+	}
+
+	// 不允许的 Origin 的预检不返回跨域响应头
+	r = corsRouter(nil, false)
+	w = corsRequest(r, http.MethodOptions, "https://app.example.com", map[string]string{"Access-Control-Request-Method": "POST"})
+	if w.Code != http.StatusNoContent || w.Header().Get("Access-Control-Allow-Origin") != "" || w.Header().Get("Access-Control-Allow-Methods") != "" {
+		t.Errorf("status = %d, headers = %v", w.Code, w.Header())
+	}
+}
@@ -1,49 +1,217 @@
 package middleware
 
 import (
+	"crypto/subtle"
+	"fmt"
 	"log/slog"
+	"math"
 	"net/http"
+	"strconv"
+	"time"
 
 	"github.com/gin-gonic/gin"
+
+	"ollama_dev/internal/apperr"
+	"ollama_dev/internal/auth"
+	"ollama_dev/internal/config"
+	"ollama_dev/internal/i18n"
+	"ollama_dev/internal/metrics"
+	"ollama_dev/internal/ratelimit"
+	"ollama_dev/internal/stats"
+	"ollama_dev/internal/tenant"
+	"ollama_dev/internal/usage"
 )
 
-// CorsMiddleware 跨域中间件
-func CorsMiddleware() gin.HandlerFunc {
+// TrafficLoggingMiddleware 流量日志监控中间件，请求处理完成后记录，经过租户鉴权的请求附带 tenant 字段，
+// 位于 RequestIDMiddleware 之后时附带 request_id 与 trace_id
+func TrafficLoggingMiddleware(logger *slog.Logger) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		c.Writer.Header().Set("Access-Control-Allow-Origin", "*")
-		c.Writer.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
+		c.Next()
+		args := []any{
+			"method", c.Request.Method,
+			"path", c.Request.URL.Path,
+			"status", c.Writer.Status(),
+			"remote_addr", c.ClientIP(),
+		}
+		if id := c.GetString(TenantKey); id != "" {
+			args = append(args, "tenant", id)
+		}
+		logger.InfoContext(c.Request.Context(), "请求日志", args...)
+	}
+}
+
+// HTTP 请求的 Prometheus 指标，route 为注册的路由模板，未匹配的请求为 unmatched
+var (
+	httpRequests = metrics.NewCounter("ollama_dev_http_requests_total", "serve 处理的 HTTP 请求数", "method", "route", "status")
+	httpDuration = metrics.NewHistogram("ollama_dev_http_request_duration_seconds", "serve 处理 HTTP 请求的耗时",
+		metrics.DefBuckets, "method", "route")
+)
 
-		if c.Request.Method == "OPTIONS" {
-			c.AbortWithStatus(http.StatusOK)
+// MetricsMiddleware 记录请求数与耗时，WebSocket 连接的耗时为连接时长
+func MetricsMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+		httpRequests.Inc(c.Request.Method, route, strconv.Itoa(c.Writer.Status()))
+		httpDuration.Observe(time.Since(start).Seconds(), c.Request.Method, route)
+	}
+}
+
+// gin.Context 中保存租户与调用方 (auth.Identity) 的键
+const (
+	TenantKey   = "tenant"
+	IdentityKey = "identity"
+)
+
+// AuthMiddleware 识别调用方并写入 gin.Context 与请求 ctx：配置 auth.jwt 时接受 JWT 或 Token，
+// 否则按 Token 识别租户，auth.token 与多租户均未配置时允许匿名访问 (default 租户)；同时按配置校验客户端证书与请求签名，随配置热加载。
+// 接口要求的角色由其后的 RequireRole 检查
+func AuthMiddleware(store *config.Store) gin.HandlerFunc {
+	verifier := auth.NewVerifier()
+	return func(c *gin.Context) {
+		id, err := verifier.Authenticate(store.Get(), c.Request)
+		if err != nil {
+			AbortWithError(c, err)
 			return
 		}
+		SetIdentity(c, id)
+		c.Next()
+	}
+}
+
+// SetIdentity 写入通过鉴权的调用方，handler 可用 GetIdentity、auth.FromContext 或 tenant.FromContext 读取
+func SetIdentity(c *gin.Context, id auth.Identity) {
+	c.Set(TenantKey, id.Tenant)
+	c.Set(IdentityKey, id)
+	c.Request = c.Request.WithContext(tenant.WithTenant(auth.WithIdentity(c.Request.Context(), id), id.Tenant))
+}
+
+// GetIdentity 读取 SetIdentity 写入的调用方，未经过鉴权时返回 false
+func GetIdentity(c *gin.Context) (auth.Identity, bool) {
+	v, ok := c.Get(IdentityKey)
+	if !ok {
+		return auth.Identity{}, false
+	}
+	id, ok := v.(auth.Identity)
+	return id, ok
+}
 
+// RequireRole 要求调用方具有 role (admin 满足所有角色)，否则返回 403；须在 AuthMiddleware 或 AdminAuthMiddleware 之后使用
+func RequireRole(role string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if id, ok := GetIdentity(c); !ok || !id.HasRole(role) {
+			AbortWithError(c, apperr.New(apperr.Auth, apperr.CodeForbidden, "需要 "+role+" 角色"))
+			return
+		}
 		c.Next()
 	}
 }
 
-// TrafficLoggingMiddleware 流量日志监控中间件
-func TrafficLoggingMiddleware(logger *slog.Logger) gin.HandlerFunc {
+// AdminAuthMiddleware 管理接口鉴权：admin 账号的 Basic Auth，或配置 auth.jwt 时带 admin 角色的 JWT；随配置热加载
+func AdminAuthMiddleware(store *config.Store) gin.HandlerFunc {
+	verifier := auth.NewVerifier()
 	return func(c *gin.Context) {
-		logger.Info("请求日志",
-			"method", c.Request.Method,
-			"path", c.Request.URL.Path,
-			"remote_addr", c.ClientIP(),
-		)
+		cur := store.Get()
+		if user, pass, ok := c.Request.BasicAuth(); ok {
+			if cur.Admin.Password != "" && equal(user, cur.Admin.Username) && equal(pass, cur.Admin.Password) {
+				SetIdentity(c, auth.Identity{Subject: user, Tenant: tenant.Default, Roles: []string{auth.RoleAdmin}, Method: auth.MethodBasic})
+				c.Next()
+				return
+			}
+		} else if cur.Auth.JWT.Algorithm != "" && tenant.Token(c.Request) != "" {
+			id, err := verifier.Authenticate(cur, c.Request)
+			if err != nil {
+				AbortWithError(c, err)
+				return
+			}
+			SetIdentity(c, id)
+			RequireRole(auth.RoleAdmin)(c)
+			return
+		}
+		c.Header("WWW-Authenticate", `Basic realm="admin"`)
+		AbortWithError(c, apperr.New(apperr.Auth, apperr.CodeUnauthorized, i18n.Tr(i18n.FromRequest(c.Request), i18n.ErrUnauthorized)))
+	}
+}
+
+// RateLimitMiddleware 按 pick 从 server.rate_limit 选出的规则限流，随配置热加载：每个请求消耗一个令牌，
+// POST 请求 (对话、嵌入等生成) 在处理期间另占用一个并发名额；超出时返回 429 与 Retry-After (秒)
+func RateLimitMiddleware(store *config.Store, limiter *ratelimit.Limiter, pick func(config.RateLimitConfig) config.RateLimitRule) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		cfg := store.Get().Server.RateLimit
+		rule := pick(cfg)
+		key := ratelimit.ClientKey(cfg.Key, tenant.Token(c.Request), c.ClientIP())
+		if wait, ok := limiter.Allow(rule, key); !ok {
+			abortRateLimited(c, wait, fmt.Sprintf("请求过于频繁，每秒最多 %g 个", rule.Rate))
+			return
+		}
+		if c.Request.Method == http.MethodPost {
+			release, ok := limiter.Acquire(rule, key)
+			if !ok {
+				abortRateLimited(c, time.Second, fmt.Sprintf("同时进行的生成已达上限 (%d)", rule.Concurrency))
+				return
+			}
+			defer release()
+		}
 		c.Next()
 	}
 }
 
-// AuthMiddleware 请求鉴权访问中间件
-func AuthMiddleware() gin.HandlerFunc {
+// QuotaMiddleware 调用方当天的用量已达 server.quota 的上限时返回 429，Retry-After 为距额度重置 (UTC 0 点) 的秒数，
+// 随配置热加载；usg 为 nil 时不检查。需位于 AuthMiddleware 之后
+func QuotaMiddleware(store *config.Store, usg *usage.Store) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		authHeader := c.GetHeader("Authorization")
-		if authHeader != "Bearer valid-token" {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "未授权"})
-			c.Abort()
+		caller := usage.CallerOf(c.Request.Context())
+		wait, err := usg.Enforce(caller, store.Get().Server.Quota.For(caller.Tenant), time.Now())
+		if err != nil {
+			if wait > 0 {
+				c.Header("Retry-After", strconv.Itoa(RetryAfterSeconds(wait)))
+			}
+			AbortWithError(c, err)
 			return
 		}
 		c.Next()
 	}
 }
+
+func abortRateLimited(c *gin.Context, wait time.Duration, msg string) {
+	c.Header("Retry-After", strconv.Itoa(RetryAfterSeconds(wait)))
+	AbortWithError(c, apperr.New(apperr.Backend, apperr.CodeRateLimited, msg+"，稍后重试"))
+}
+
+// RetryAfterSeconds 将等待时长向上取整为 Retry-After 的秒数，至少为 1
+func RetryAfterSeconds(wait time.Duration) int {
+	return max(1, int(math.Ceil(wait.Seconds())))
+}
+
+func equal(a, b string) bool {
+	return subtle.ConstantTimeCompare([]byte(a), []byte(b)) == 1
+}
+
+// ErrorResponse REST 接口的错误响应体
+type ErrorResponse struct {
+	Error     string          `json:"error"`
+	Category  apperr.Category `json:"category"`
+	Code      string          `json:"code"`
+	RequestID string          `json:"request_id,omitempty"` // 与响应头 X-Request-ID 相同，便于对照服务端日志
+}
+
+// NewErrorResponse 将错误转换为 ErrorResponse，同时计入错误统计；用于已开始写入响应、无法再改状态码的场景 (例如 SSE)
+func NewErrorResponse(c *gin.Context, err error) ErrorResponse {
+	data := apperr.ToData(err)
+	stats.RecordError(c.Request.Method+" "+c.Request.URL.Path, data.Code, data.Message)
+	return ErrorResponse{
+		Error:     data.Message,
+		Category:  data.Category,
+		Code:      data.Code,
+		RequestID: c.GetString(RequestIDKey),
+	}
+}
+
+// AbortWithError 按错误类别返回对应的状态码，响应体为 ErrorResponse
+func AbortWithError(c *gin.Context, err error) {
+	c.AbortWithStatusJSON(apperr.HTTPStatus(err), NewErrorResponse(c, err))
+}
@@ -0,0 +1,48 @@
+package middleware
+
+import (
+	"github.com/gin-gonic/gin"
+
+	"ollama_dev/internal/logging"
+)
+
+// RequestIDHeader 请求与响应中携带请求 ID 的头
+const RequestIDHeader = "X-Request-ID"
+
+// RequestIDKey gin.Context 中保存请求 ID 的键
+const RequestIDKey = logging.RequestIDKey
+
+// maxRequestIDLen 沿用客户端提供的请求 ID 的最大长度，超出时重新生成
+const maxRequestIDLen = 128
+
+// RequestIDMiddleware 为每个请求确定请求 ID：沿用合法的 X-Request-ID 请求头，否则随机生成，并写回响应头；
+// 请求带有 W3C traceparent 时同时取出 trace ID。两者写入请求 ctx，处理器经 logger.InfoContext 等输出的日志自动带上
+func RequestIDMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.GetHeader(RequestIDHeader)
+		if !validRequestID(id) {
+			id = logging.NewRequestID()
+		}
+		ctx := logging.WithRequestID(c.Request.Context(), id)
+		if trace, ok := logging.ParseTraceparent(c.GetHeader("traceparent")); ok {
+			ctx = logging.WithTraceID(ctx, trace)
+		}
+		c.Request = c.Request.WithContext(ctx)
+		c.Set(RequestIDKey, id)
+		c.Header(RequestIDHeader, id)
+		c.Next()
+	}
+}
+
+// validRequestID 请求 ID 只能包含可打印的 ASCII 字符，避免日志注入
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLen {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] <= ' ' || id[i] > '~' {
+			return false
+		}
+	}
+	return true
+}
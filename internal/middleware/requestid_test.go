@@ -0,0 +1,83 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"ollama_dev/internal/apperr"
+	"ollama_dev/internal/logging"
+)
+
+func requestIDRouter(logs *bytes.Buffer) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	logger := slog.New(logging.NewContextHandler(slog.NewJSONHandler(logs, nil)))
+	r := gin.New()
+	r.Use(RequestIDMiddleware(), TrafficLoggingMiddleware(logger))
+	r.GET("/ok", func(c *gin.Context) { c.Status(http.StatusOK) })
+	r.GET("/fail", func(c *gin.Context) {
+		AbortWithError(c, apperr.Wrap(errors.New("boom"), apperr.Protocol, apperr.CodeInvalidParams, "参数无效"))
+	})
+	return r
+}
+
+func TestRequestIDMiddlewareGeneratesID(t *testing.T) {
+	var logs bytes.Buffer
+	r := requestIDRouter(&logs)
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/fail", nil))
+
+	id := w.Header().Get(RequestIDHeader)
+	if len(id) != 16 {
+		t.Fatalf("expected a generated request id, got %q", id)
+	}
+	var body ErrorResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil || body.RequestID != id {
+		t.Errorf("error body should carry request id %q, got %s", id, w.Body.String())
+	}
+	var record map[string]any
+	if err := json.Unmarshal(logs.Bytes(), &record); err != nil || record[logging.RequestIDKey] != id {
+		t.Errorf("traffic log should carry request id %q, got %s", id, logs.String())
+	}
+}
+
+func TestRequestIDMiddlewareKeepsClientIDAndTrace(t *testing.T) {
+	var logs bytes.Buffer
+	r := requestIDRouter(&logs)
+
+	req := httptest.NewRequest(http.MethodGet, "/ok", nil)
+	req.Header.Set(RequestIDHeader, "client-abc")
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	if got := w.Header().Get(RequestIDHeader); got != "client-abc" {
+		t.Errorf("X-Request-ID = %q, want client-abc", got)
+	}
+	var record map[string]any
+	if err := json.Unmarshal(logs.Bytes(), &record); err != nil {
+		t.Fatal(err)
+	}
+	if record[logging.RequestIDKey] != "client-abc" || record[logging.TraceIDKey] != "4bf92f3577b34da6a3ce929d0e0e4736" {
+		t.Errorf("unexpected traffic log: %v", record)
+	}
+
+	// 含控制字符或过长的请求 ID 不沿用
+	for _, bad := range []string{"a\nb", strings.Repeat("x", maxRequestIDLen+1)} {
+		req := httptest.NewRequest(http.MethodGet, "/ok", nil)
+		req.Header.Set(RequestIDHeader, bad)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		if got := w.Header().Get(RequestIDHeader); got == bad || got == "" {
+			t.Errorf("invalid request id %q should be replaced, got %q", bad, got)
+		}
+	}
+}
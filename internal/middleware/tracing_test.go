@@ -0,0 +1,62 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+
+	"ollama_dev/internal/tracing"
+)
+
+func TestTracingMiddlewareContinuesTrace(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	prevProvider, prevPropagator := otel.GetTracerProvider(), otel.GetTextMapPropagator()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	t.Cleanup(func() {
+		otel.SetTracerProvider(prevProvider)
+		otel.SetTextMapPropagator(prevPropagator)
+	})
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(RequestIDMiddleware(), TracingMiddleware())
+	r.GET("/items/:id", func(c *gin.Context) { c.Status(http.StatusBadGateway) })
+
+	req := httptest.NewRequest(http.MethodGet, "/items/1", nil)
+	req.Header.Set(RequestIDHeader, "abc")
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	r.ServeHTTP(httptest.NewRecorder(), req)
+
+	spans := recorder.Ended()
+	if len(spans) != 1 {
+		t.Fatalf("expected 1 span, got %d", len(spans))
+	}
+	span := spans[0]
+	if span.Name() != "GET /items/:id" {
+		t.Errorf("span name = %q", span.Name())
+	}
+	if got := span.SpanContext().TraceID().String(); got != "4bf92f3577b34da6a3ce929d0e0e4736" {
+		t.Errorf("trace id = %s, want upstream trace", got)
+	}
+	attrs := map[string]string{}
+	for _, attr := range span.Attributes() {
+		attrs[string(attr.Key)] = attr.Value.Emit()
+	}
+	if attrs[string(tracing.RequestIDKey)] != "abc" {
+		t.Errorf("request_id = %q", attrs[string(tracing.RequestIDKey)])
+	}
+	if attrs[string(semconv.HTTPResponseStatusCodeKey)] != "502" {
+		t.Errorf("status code = %q", attrs[string(semconv.HTTPResponseStatusCodeKey)])
+	}
+	if span.Status().Code.String() != "Error" {
+		t.Errorf("status = %v, want Error", span.Status())
+	}
+}
@@ -0,0 +1,348 @@
+package mirror
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"expvar"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"ollama_dev/internal/config"
+	"ollama_dev/internal/throttle"
+)
+
+// metrics 缓存命中、回源与淘汰计数，发布在 /debug/vars 的 mirror 字段
+var metrics = expvar.NewMap("mirror")
+
+// maxManifestSize manifest 的最大字节数
+const maxManifestSize = 4 << 20
+
+// manifestType 从缓存返回 manifest 时使用的 Content-Type，与 Ollama registry 一致
+const manifestType = "application/vnd.docker.distribution.manifest.v2+json"
+
+var (
+	routeRe  = regexp.MustCompile(`^/v2/([a-z0-9][a-z0-9._-]*(?:/[a-z0-9][a-z0-9._-]*)*)/(manifests|blobs)/([^/]+)$`)
+	refRe    = regexp.MustCompile(`^[A-Za-z0-9_][A-Za-z0-9._-]{0,127}$`)
+	digestRe = regexp.MustCompile(`^sha256:([a-f0-9]{64})$`)
+)
+
+// Mirror 模型 registry 的回源缓存：manifest 每次回源，失败时返回缓存；
+// blob 按 digest 缓存在磁盘上，未命中时转发当前请求并在后台完整下载、校验后写入缓存
+type Mirror struct {
+	ctx      context.Context
+	upstream string
+	dir      string
+	maxSize  int64
+	client   *http.Client
+	limiter  *throttle.Limiter
+	logger   *slog.Logger
+
+	mu      sync.Mutex
+	filling map[string]bool // 正在后台下载的 digest
+	wg      sync.WaitGroup
+}
+
+// New 创建缓存目录并清理上次未完成的下载，ctx 结束时中断后台下载；limiter 限制回源与返回给下游的带宽
+func New(ctx context.Context, cfg config.MirrorConfig, limiter *throttle.Limiter, logger *slog.Logger) (*Mirror, error) {
+	m := &Mirror{
+		ctx:      ctx,
+		upstream: strings.TrimSuffix(cfg.Upstream, "/"),
+		dir:      cfg.Dir,
+		maxSize:  cfg.MaxSize,
+		client:   &http.Client{},
+		limiter:  limiter,
+		logger:   logger,
+		filling:  map[string]bool{},
+	}
+	for _, sub := range []string{"blobs", "manifests"} {
+		if err := os.MkdirAll(filepath.Join(m.dir, sub), 0o755); err != nil {
+			return nil, fmt.Errorf("创建缓存目录失败: %w", err)
+		}
+	}
+	partials, _ := filepath.Glob(filepath.Join(m.dir, "blobs", "*.partial"))
+	for _, p := range partials {
+		_ = os.Remove(p)
+	}
+	return m, nil
+}
+
+// Wait 等待后台下载结束
+func (m *Mirror) Wait() {
+	m.wg.Wait()
+}
+
+func (m *Mirror) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.Error(w, "只支持 GET 与 HEAD", http.StatusMethodNotAllowed)
+		return
+	}
+	if r.URL.Path == "/v2/" || r.URL.Path == "/v2" {
+		// registry API 版本探测
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = io.WriteString(w, "{}")
+		return
+	}
+	match := routeRe.FindStringSubmatch(r.URL.Path)
+	if match == nil {
+		http.NotFound(w, r)
+		return
+	}
+	name, kind, ref := match[1], match[2], match[3]
+	if kind == "manifests" {
+		if !refRe.MatchString(ref) && !digestRe.MatchString(ref) {
+			http.Error(w, "无效的 reference", http.StatusBadRequest)
+			return
+		}
+		m.serveManifest(w, r, name, ref)
+		return
+	}
+	sum := digestRe.FindStringSubmatch(ref)
+	if sum == nil {
+		http.Error(w, "无效的 digest", http.StatusBadRequest)
+		return
+	}
+	m.serveBlob(w, r, name, ref, sum[1])
+}
+
+func (m *Mirror) serveManifest(w http.ResponseWriter, r *http.Request, name, ref string) {
+	path := filepath.Join(m.dir, "manifests", filepath.FromSlash(name), ref)
+	req, err := http.NewRequestWithContext(r.Context(), http.MethodGet, m.upstream+r.URL.Path, nil)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if accept := r.Header.Get("Accept"); accept != "" {
+		req.Header.Set("Accept", accept)
+	}
+
+	resp, err := m.client.Do(req)
+	if err == nil {
+		defer resp.Body.Close()
+	}
+	if err != nil || resp.StatusCode >= 500 {
+		// registry 不可达时返回缓存的 manifest，局域网内仍可拉取已缓存的模型
+		data, readErr := os.ReadFile(path)
+		if readErr != nil {
+			m.logger.Error("获取 manifest 失败且没有缓存", "name", name, "ref", ref, "error", upstreamError(resp, err))
+			http.Error(w, "registry 不可达", http.StatusBadGateway)
+			return
+		}
+		metrics.Add("manifest_stale", 1)
+		m.logger.Warn("registry 不可达，返回缓存的 manifest", "name", name, "ref", ref, "error", upstreamError(resp, err))
+		writeManifest(w, r, manifestType, data)
+		return
+	}
+	if resp.StatusCode != http.StatusOK {
+		w.WriteHeader(resp.StatusCode)
+		return
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxManifestSize))
+	if err != nil {
+		http.Error(w, "读取 manifest 失败", http.StatusBadGateway)
+		return
+	}
+	if err := writeFileAtomic(path, data); err != nil {
+		m.logger.Error("缓存 manifest 失败", "name", name, "ref", ref, "error", err)
+	}
+	writeManifest(w, r, resp.Header.Get("Content-Type"), data)
+}
+
+func upstreamError(resp *http.Response, err error) error {
+	if err != nil {
+		return err
+	}
+	return fmt.Errorf("registry 返回 %s", resp.Status)
+}
+
+func writeManifest(w http.ResponseWriter, r *http.Request, contentType string, data []byte) {
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Length", fmt.Sprint(len(data)))
+	if r.Method == http.MethodHead {
+		return
+	}
+	_, _ = w.Write(data)
+}
+
+func (m *Mirror) blobPath(sum string) string {
+	return filepath.Join(m.dir, "blobs", "sha256-"+sum)
+}
+
+func (m *Mirror) serveBlob(w http.ResponseWriter, r *http.Request, name, digest, sum string) {
+	path := m.blobPath(sum)
+	f, err := os.Open(path)
+	if err == nil {
+		defer f.Close()
+		metrics.Add("hits", 1)
+		now := time.Now()
+		// 修改时间作为最近使用时间，超出 max_size 时先淘汰最久未使用的层
+		_ = os.Chtimes(path, now, now)
+		w.Header().Set("Docker-Content-Digest", digest)
+		http.ServeContent(m.throttled(r.Context(), w), r, "", now, f)
+		return
+	}
+
+	metrics.Add("misses", 1)
+	m.fill(name, digest, sum)
+	m.proxy(w, r)
+}
+
+// proxy 将未命中的请求转发到 registry，保留 Range 以支持 Ollama 的分段并发下载
+func (m *Mirror) proxy(w http.ResponseWriter, r *http.Request) {
+	req, err := http.NewRequestWithContext(r.Context(), r.Method, m.upstream+r.URL.Path, nil)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if rng := r.Header.Get("Range"); rng != "" {
+		req.Header.Set("Range", rng)
+	}
+	resp, err := m.client.Do(req)
+	if err != nil {
+		http.Error(w, "registry 不可达", http.StatusBadGateway)
+		return
+	}
+	defer resp.Body.Close()
+	for _, h := range []string{"Content-Type", "Content-Length", "Content-Range", "Accept-Ranges", "Docker-Content-Digest", "ETag"} {
+		if v := resp.Header.Get(h); v != "" {
+			w.Header().Set(h, v)
+		}
+	}
+	w.WriteHeader(resp.StatusCode)
+	_, _ = io.Copy(m.throttled(r.Context(), w), resp.Body)
+}
+
+// fill 在后台下载完整的 blob，同一 digest 只下载一次
+func (m *Mirror) fill(name, digest, sum string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.filling[sum] {
+		return
+	}
+	m.filling[sum] = true
+	m.wg.Add(1)
+	go func() {
+		defer m.wg.Done()
+		start := time.Now()
+		size, err := m.download(name, digest, sum)
+		m.mu.Lock()
+		delete(m.filling, sum)
+		m.mu.Unlock()
+		if err != nil {
+			metrics.Add("fill_errors", 1)
+			m.logger.Error("缓存模型层失败", "digest", digest, "error", err)
+			return
+		}
+		metrics.Add("fills", 1)
+		m.logger.Info("已缓存模型层", "digest", digest, "size", size, "elapsed", time.Since(start).Round(time.Millisecond))
+		m.prune(m.blobPath(sum))
+	}()
+}
+
+// download 下载 blob 到临时文件，校验 digest 后移入缓存
+func (m *Mirror) download(name, digest, sum string) (int64, error) {
+	req, err := http.NewRequestWithContext(m.ctx, http.MethodGet, m.upstream+"/v2/"+name+"/blobs/"+digest, nil)
+	if err != nil {
+		return 0, err
+	}
+	resp, err := m.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return 0, upstreamError(resp, nil)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Join(m.dir, "blobs"), "sha256-*.partial")
+	if err != nil {
+		return 0, err
+	}
+	defer os.Remove(tmp.Name())
+	h := sha256.New()
+	size, err := io.Copy(io.MultiWriter(tmp, h), throttle.Reader(m.ctx, resp.Body, m.limiter))
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return 0, err
+	}
+	if got := hex.EncodeToString(h.Sum(nil)); got != sum {
+		return 0, fmt.Errorf("digest 不匹配: sha256:%s", got)
+	}
+	return size, os.Rename(tmp.Name(), m.blobPath(sum))
+}
+
+// prune 缓存超出 max_size 时按最近使用时间删除最旧的层，keep 为刚写入的层，不删除
+func (m *Mirror) prune(keep string) {
+	if m.maxSize <= 0 {
+		return
+	}
+	paths, _ := filepath.Glob(filepath.Join(m.dir, "blobs", "sha256-*"))
+	type blob struct {
+		path string
+		size int64
+		used time.Time
+	}
+	var blobs []blob
+	var total int64
+	for _, p := range paths {
+		info, err := os.Stat(p)
+		if err != nil || strings.HasSuffix(p, ".partial") {
+			continue
+		}
+		blobs = append(blobs, blob{p, info.Size(), info.ModTime()})
+		total += info.Size()
+	}
+	slices.SortFunc(blobs, func(a, b blob) int { return a.used.Compare(b.used) })
+	for _, b := range blobs {
+		if total <= m.maxSize {
+			return
+		}
+		if b.path == keep {
+			continue
+		}
+		if err := os.Remove(b.path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			m.logger.Error("删除缓存失败", "path", b.path, "error", err)
+			continue
+		}
+		total -= b.size
+		metrics.Add("evictions", 1)
+	}
+}
+
+// throttled 返回按 limiter 限速写入并统计字节数的 ResponseWriter
+func (m *Mirror) throttled(ctx context.Context, w http.ResponseWriter) http.ResponseWriter {
+	return throttledWriter{ResponseWriter: w, w: throttle.Writer(ctx, w, m.limiter)}
+}
+
+type throttledWriter struct {
+	http.ResponseWriter
+	w io.Writer
+}
+
+func (t throttledWriter) Write(p []byte) (int, error) {
+	metrics.Add("bytes_served", int64(len(p)))
+	return t.w.Write(p)
+}
+
+func writeFileAtomic(path string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
@@ -0,0 +1,187 @@
+package mirror
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"ollama_dev/internal/config"
+)
+
+// fakeRegistry 提供一个 manifest 与一个 blob，统计 blob 请求次数
+type fakeRegistry struct {
+	blob     []byte
+	digest   string
+	requests atomic.Int64
+	down     atomic.Bool
+}
+
+func newFakeRegistry(blob []byte) *fakeRegistry {
+	sum := sha256.Sum256(blob)
+	return &fakeRegistry{blob: blob, digest: "sha256:" + hex.EncodeToString(sum[:])}
+}
+
+func (f *fakeRegistry) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if f.down.Load() {
+		http.Error(w, "down", http.StatusServiceUnavailable)
+		return
+	}
+	switch {
+	case strings.HasSuffix(r.URL.Path, "/manifests/latest"):
+		w.Header().Set("Content-Type", manifestType)
+		io.WriteString(w, `{"layers":[{"digest":"`+f.digest+`"}]}`)
+	case strings.HasSuffix(r.URL.Path, "/blobs/"+f.digest):
+		f.requests.Add(1)
+		http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(f.blob))
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func newTestMirror(t *testing.T, upstream string, maxSize int64) *Mirror {
+	t.Helper()
+	cfg := config.MirrorConfig{Upstream: upstream, Dir: t.TempDir(), MaxSize: maxSize}
+	m, err := New(context.Background(), cfg, nil, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+func get(t *testing.T, h http.Handler, path, rng string) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	if rng != "" {
+		req.Header.Set("Range", rng)
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestBlobIsCachedAfterMiss(t *testing.T) {
+	reg := newFakeRegistry(bytes.Repeat([]byte("layer"), 1000))
+	upstream := httptest.NewServer(reg)
+	defer upstream.Close()
+	m := newTestMirror(t, upstream.URL, 0)
+	path := "/v2/library/llama3/blobs/" + reg.digest
+
+	// 未命中时转发分段请求，并在后台下载完整的层
+	rec := get(t, m, path, "bytes=0-9")
+	if rec.Code != http.StatusPartialContent || rec.Body.String() != "layerlayer" {
+		t.Fatalf("unexpected proxied response: %d %q", rec.Code, rec.Body.String())
+	}
+	m.Wait()
+	before := reg.requests.Load()
+
+	// 命中后从磁盘返回，同样支持 Range，不再回源
+	rec = get(t, m, path, "bytes=5-9")
+	if rec.Code != http.StatusPartialContent || rec.Body.String() != "layer" {
+		t.Fatalf("unexpected cached response: %d %q", rec.Code, rec.Body.String())
+	}
+	rec = get(t, m, path, "")
+	if !bytes.Equal(rec.Body.Bytes(), reg.blob) || rec.Header().Get("Docker-Content-Digest") != reg.digest {
+		t.Error("cached blob does not match upstream")
+	}
+	if reg.requests.Load() != before {
+		t.Error("cache hit should not contact the registry")
+	}
+}
+
+func TestCorruptBlobIsNotCached(t *testing.T) {
+	reg := newFakeRegistry([]byte("good"))
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "tampered")
+	}))
+	defer upstream.Close()
+	m := newTestMirror(t, upstream.URL, 0)
+
+	get(t, m, "/v2/library/llama3/blobs/"+reg.digest, "")
+	m.Wait()
+	if _, err := os.Stat(m.blobPath(strings.TrimPrefix(reg.digest, "sha256:"))); err == nil {
+		t.Error("blob with mismatched digest should not be cached")
+	}
+	if files, _ := filepath.Glob(filepath.Join(m.dir, "blobs", "*")); len(files) != 0 {
+		t.Errorf("temporary files left behind: %v", files)
+	}
+}
+
+func TestManifestFallsBackToCache(t *testing.T) {
+	reg := newFakeRegistry([]byte("x"))
+	upstream := httptest.NewServer(reg)
+	defer upstream.Close()
+	m := newTestMirror(t, upstream.URL, 0)
+	path := "/v2/library/llama3/manifests/latest"
+
+	fresh := get(t, m, path, "")
+	if fresh.Code != http.StatusOK {
+		t.Fatalf("unexpected status %d", fresh.Code)
+	}
+	// registry 不可达时返回缓存的 manifest
+	reg.down.Store(true)
+	stale := get(t, m, path, "")
+	if stale.Code != http.StatusOK || stale.Body.String() != fresh.Body.String() {
+		t.Errorf("expected cached manifest, got %d %q", stale.Code, stale.Body.String())
+	}
+	if rec := get(t, m, "/v2/library/other/manifests/latest", ""); rec.Code != http.StatusBadGateway {
+		t.Errorf("uncached manifest with registry down should be 502, got %d", rec.Code)
+	}
+}
+
+func TestPruneEvictsLeastRecentlyUsed(t *testing.T) {
+	m := newTestMirror(t, "http://unused", 10)
+	now := time.Now()
+	for i, name := range []string{"old", "mid", "new"} {
+		p := m.blobPath(name)
+		os.WriteFile(p, []byte("123456"), 0o644)
+		used := now.Add(time.Duration(i) * time.Minute)
+		os.Chtimes(p, used, used)
+	}
+	m.prune(m.blobPath("new"))
+	for name, want := range map[string]bool{"old": false, "mid": false, "new": true} {
+		if _, err := os.Stat(m.blobPath(name)); (err == nil) != want {
+			t.Errorf("%s: expected exists=%v", name, want)
+		}
+	}
+}
+
+func TestRejectsBadPaths(t *testing.T) {
+	m := newTestMirror(t, "http://unused", 0)
+	for _, path := range []string{"/v2/../etc/manifests/latest", "/v2/library/x/blobs/sha256:zz", "/v2/library/x/manifests/..", "/other"} {
+		if rec := get(t, m, path, ""); rec.Code != http.StatusNotFound && rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected rejection, got %d", path, rec.Code)
+		}
+	}
+}
+
+func TestRewrite(t *testing.T) {
+	cases := []struct {
+		model, pull, local string
+		ok                 bool
+	}{
+		{"llama3", "http://10.0.0.5:5001/library/llama3:latest", "10.0.0.5:5001/library/llama3:latest", true},
+		{"user/model:7b", "http://10.0.0.5:5001/user/model:7b", "10.0.0.5:5001/user/model:7b", true},
+		{"example.com/user/model", "", "", false},
+		{"localhost:5000/model", "", "", false},
+	}
+	for _, c := range cases {
+		pull, local, ok := Rewrite("http://10.0.0.5:5001", c.model)
+		if pull != c.pull || local != c.local || ok != c.ok {
+			t.Errorf("%s: got %q %q %v", c.model, pull, local, ok)
+		}
+	}
+	if _, _, ok := Rewrite("", "llama3"); ok {
+		t.Error("empty pull_via should not rewrite")
+	}
+}
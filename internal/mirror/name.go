@@ -0,0 +1,28 @@
+package mirror
+
+import (
+	"net/url"
+	"strings"
+)
+
+// Rewrite 将模型名改写为经 via 拉取的名称：pull 为传给 Ollama 的名称（带协议），
+// local 为拉取后 Ollama 中的名称（不带协议），拉取后需复制回原名称。
+// 模型名已包含 registry 主机时 ok 为 false，不经 mirror 拉取
+func Rewrite(via, model string) (pull, local string, ok bool) {
+	u, err := url.Parse(via)
+	if err != nil || u.Host == "" {
+		return "", "", false
+	}
+	parts := strings.Split(model, "/")
+	if len(parts) > 2 || len(parts) == 2 && strings.ContainsAny(parts[0], ".:") {
+		return "", "", false
+	}
+	if len(parts) == 1 {
+		parts = []string{"library", parts[0]}
+	}
+	if !strings.Contains(parts[1], ":") {
+		parts[1] += ":latest"
+	}
+	path := strings.Join(parts, "/")
+	return u.Scheme + "://" + u.Host + "/" + path, u.Host + "/" + path, true
+}
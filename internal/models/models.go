@@ -0,0 +1,141 @@
+package models
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/ollama/ollama/api"
+)
+
+// Info 模型信息，桥接协议的 list_model 响应与 REST 接口 /api/models 共用
+type Info struct {
+	Name          string    `json:"model_name"`
+	Digest        string    `json:"digest"`
+	Size          int64     `json:"size"`                         // 字节
+	Family        string    `json:"family,omitempty"`             // 模型家族，例如 llama
+	ParameterSize string    `json:"parameter_size,omitempty"`     // 参数规模，例如 8.0B
+	Quantization  string    `json:"quantization_level,omitempty"` // 量化等级，例如 Q4_K_M
+	ModifiedAt    time.Time `json:"modified_at"`
+	Loaded        bool      `json:"loaded"` // 是否已加载到内存
+}
+
+// NewClient 创建 Ollama API 客户端，host 为空时读取 OLLAMA_HOST 环境变量
+func NewClient(host string) (*api.Client, error) {
+	if host == "" {
+		return api.ClientFromEnvironment()
+	}
+	u, err := url.Parse(host)
+	if err != nil {
+		return nil, fmt.Errorf("解析 Ollama 地址失败: %w", err)
+	}
+	return api.NewClient(u, http.DefaultClient), nil
+}
+
+// Lister 列出模型，供 REST 接口使用
+type Lister func(ctx context.Context) ([]Info, error)
+
+// OllamaLister 基于 Ollama API 的 Lister，host 为空时读取 OLLAMA_HOST 环境变量
+func OllamaLister(host string) (Lister, error) {
+	client, err := NewClient(host)
+	if err != nil {
+		return nil, err
+	}
+	return func(ctx context.Context) ([]Info, error) {
+		return List(ctx, client)
+	}, nil
+}
+
+// List 列出已拉取的模型并标记已加载的模型，查询加载状态失败时视为均未加载
+func List(ctx context.Context, client *api.Client) ([]Info, error) {
+	list, err := client.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	running, err := client.ListRunning(ctx)
+	if err != nil {
+		running = nil
+	}
+	return FromOllama(list, running), nil
+}
+
+// FromOllama 将 Ollama 的 /api/tags 与 /api/ps 响应转换为 Info，running 可为 nil
+func FromOllama(list *api.ListResponse, running *api.ProcessResponse) []Info {
+	loaded := make(map[string]bool)
+	if running != nil {
+		for _, m := range running.Models {
+			loaded[m.Name] = true
+		}
+	}
+
+	infos := make([]Info, 0, len(list.Models))
+	for _, m := range list.Models {
+		infos = append(infos, Info{
+			Name:          m.Name,
+			Digest:        m.Digest,
+			Size:          m.Size,
+			Family:        m.Details.Family,
+			ParameterSize: m.Details.ParameterSize,
+			Quantization:  m.Details.QuantizationLevel,
+			ModifiedAt:    m.ModifiedAt,
+			Loaded:        loaded[m.Name],
+		})
+	}
+	return infos
+}
+
+// Detail show_model 响应的 data，来自 Ollama 的 /api/show
+type Detail struct {
+	Name          string    `json:"model_name"`
+	Family        string    `json:"family,omitempty"`
+	ParameterSize string    `json:"parameter_size,omitempty"`
+	Quantization  string    `json:"quantization_level,omitempty"`
+	Parameters    string    `json:"parameters,omitempty"` // Modelfile 中的 PARAMETER，每行一项
+	Template      string    `json:"template,omitempty"`
+	System        string    `json:"system,omitempty"`
+	License       string    `json:"license,omitempty"`
+	Modelfile     string    `json:"modelfile,omitempty"`
+	ModifiedAt    time.Time `json:"modified_at"`
+}
+
+// Running ps 响应 data 数组的元素，来自 Ollama 的 /api/ps
+type Running struct {
+	Name      string    `json:"model_name"`
+	Digest    string    `json:"digest"`
+	Size      int64     `json:"size"`       // 占用的内存，字节
+	SizeVRAM  int64     `json:"size_vram"`  // 其中位于显存的部分，字节
+	ExpiresAt time.Time `json:"expires_at"` // 空闲后自动卸载的时间
+}
+
+// DetailFromShow 将 /api/show 的响应转换为 Detail
+func DetailFromShow(name string, resp *api.ShowResponse) Detail {
+	return Detail{
+		Name:          name,
+		Family:        resp.Details.Family,
+		ParameterSize: resp.Details.ParameterSize,
+		Quantization:  resp.Details.QuantizationLevel,
+		Parameters:    resp.Parameters,
+		Template:      resp.Template,
+		System:        resp.System,
+		License:       resp.License,
+		Modelfile:     resp.Modelfile,
+		ModifiedAt:    resp.ModifiedAt,
+	}
+}
+
+// FromProcess 将 /api/ps 的响应转换为 Running
+func FromProcess(resp *api.ProcessResponse) []Running {
+	running := make([]Running, 0, len(resp.Models))
+	for _, m := range resp.Models {
+		running = append(running, Running{
+			Name:      m.Name,
+			Digest:    m.Digest,
+			Size:      m.Size,
+			SizeVRAM:  m.SizeVRAM,
+			ExpiresAt: m.ExpiresAt,
+		})
+	}
+	return running
+}
@@ -0,0 +1,76 @@
+package models
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/ollama/ollama/api"
+)
+
+func TestFromOllama(t *testing.T) {
+	modified := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	list := &api.ListResponse{Models: []api.ListModelResponse{
+		{
+			Name: "llama3:latest", Digest: "abc", Size: 4 << 30, ModifiedAt: modified,
+			Details: api.ModelDetails{Family: "llama", ParameterSize: "8.0B", QuantizationLevel: "Q4_0"},
+		},
+		{Name: "qwen2:7b", Digest: "def"},
+	}}
+	running := &api.ProcessResponse{Models: []api.ProcessModelResponse{{Name: "llama3:latest"}}}
+
+	got := FromOllama(list, running)
+	want := []Info{
+		{Name: "llama3:latest", Digest: "abc", Size: 4 << 30, Family: "llama", ParameterSize: "8.0B", Quantization: "Q4_0", ModifiedAt: modified, Loaded: true},
+		{Name: "qwen2:7b", Digest: "def"},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("expected %d models, got %d", len(want), len(got))
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("model %d: got %+v, want %+v", i, got[i], want[i])
+		}
+	}
+
+	// 未获取到加载状态时全部视为未加载
+	for _, m := range FromOllama(list, nil) {
+		if m.Loaded {
+			t.Errorf("%s should not be loaded without ps response", m.Name)
+		}
+	}
+}
+
+func TestInfoJSON(t *testing.T) {
+	data, err := json.Marshal(Info{Name: "llama3:latest", Digest: "abc"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var fields map[string]any
+	if err := json.Unmarshal(data, &fields); err != nil {
+		t.Fatal(err)
+	}
+	// 协议字段名保持 model_name，不再使用含义错误的 status 字段
+	if fields["model_name"] != "llama3:latest" || fields["digest"] != "abc" {
+		t.Errorf("unexpected JSON: %s", data)
+	}
+	if _, ok := fields["status"]; ok {
+		t.Errorf("status field should be gone: %s", data)
+	}
+}
+
+func TestFromProcess(t *testing.T) {
+	expires := time.Date(2025, 3, 1, 12, 5, 0, 0, time.UTC)
+	resp := &api.ProcessResponse{Models: []api.ProcessModelResponse{
+		{Name: "llama3:latest", Digest: "abc", Size: 6 << 30, SizeVRAM: 4 << 30, ExpiresAt: expires},
+	}}
+	got := FromProcess(resp)
+	want := Running{Name: "llama3:latest", Digest: "abc", Size: 6 << 30, SizeVRAM: 4 << 30, ExpiresAt: expires}
+	if len(got) != 1 || got[0] != want {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+	// 没有运行中的模型时为空数组而不是 null
+	if data, _ := json.Marshal(FromProcess(&api.ProcessResponse{})); string(data) != "[]" {
+		t.Errorf("expected empty array, got %s", data)
+	}
+}
@@ -0,0 +1,248 @@
+// Package openai 提供 OpenAI 兼容接口 (/v1/chat/completions、/v1/embeddings、/v1/models)，
+// 将请求转换为 Ollama 调用，供只支持 OpenAI API 的工具直接使用；模型名按 server.openai.models 映射
+package openai
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"maps"
+	"math"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/ollama/ollama/api"
+
+	"ollama_dev/internal/apperr"
+	"ollama_dev/internal/bridge"
+	"ollama_dev/internal/config"
+	"ollama_dev/internal/middleware"
+	"ollama_dev/internal/usage"
+)
+
+// API OpenAI 兼容接口的处理器，模型名映射每次请求时从配置读取，随配置热加载
+type API struct {
+	ollama bridge.OllamaClient
+	store  *config.Store
+	usage  *usage.Store
+	logger *slog.Logger
+}
+
+// New usage 可为 nil，表示不统计用量
+func New(ollama bridge.OllamaClient, store *config.Store, usage *usage.Store, logger *slog.Logger) *API {
+	return &API{ollama: ollama, store: store, usage: usage, logger: logger}
+}
+
+// Register 在 g 下注册 /chat/completions、/embeddings 与 /models，chat 中的中间件 (例如用量配额) 只作用于 /chat/completions
+func (a *API) Register(g gin.IRoutes, chat ...gin.HandlerFunc) {
+	g.POST("/chat/completions", append(chat, a.ChatCompletions)...)
+	g.POST("/embeddings", a.Embeddings)
+	g.GET("/models", a.Models)
+}
+
+// ChatCompletions 处理 POST /v1/chat/completions，stream 为 true 时以 SSE 逐片段返回并以 data: [DONE] 结束
+func (a *API) ChatCompletions(c *gin.Context) {
+	var req ChatCompletionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		abort(c, apperr.Wrap(err, apperr.Validation, apperr.CodeInvalidParams, "请求体无效"))
+		return
+	}
+	if req.Model == "" || len(req.Messages) == 0 {
+		abort(c, apperr.New(apperr.Validation, apperr.CodeInvalidParams, "model 与 messages 不能为空"))
+		return
+	}
+	messages := make([]api.Message, 0, len(req.Messages))
+	for _, m := range req.Messages {
+		messages = append(messages, api.Message{Role: m.Role, Content: string(m.Content)})
+	}
+	model := a.model(req.Model)
+	chatReq := bridge.ChatRequest{Model: model, Messages: messages, Options: req.Options()}
+	id, created := "chatcmpl-"+uuid.NewString(), time.Now().Unix()
+
+	ctx := c.Request.Context()
+	if !req.Stream {
+		reply, err := a.ollama.Chat(ctx, chatReq)
+		if err != nil {
+			abort(c, bridge.BackendError(err, "Ollama 对话失败"))
+			return
+		}
+		c.JSON(http.StatusOK, ChatCompletion{
+			ID: id, Object: "chat.completion", Created: created, Model: req.Model,
+			Choices: []Choice{{Message: Message{Role: "assistant", Content: Content(reply.Content)}, FinishReason: "stop"}},
+			Usage:   a.record(c, model, req.User, reply.Usage),
+		})
+		return
+	}
+
+	chunk := func(delta Delta, finish *string) ChatCompletionChunk {
+		return ChatCompletionChunk{
+			ID: id, Object: "chat.completion.chunk", Created: created, Model: req.Model,
+			Choices: []ChunkChoice{{Delta: delta, FinishReason: finish}},
+		}
+	}
+	// 首个片段之前出错时仍以 JSON 错误响应，之后只能以 data 行发送错误
+	started := false
+	start := func() {
+		if started {
+			return
+		}
+		started = true
+		c.Header("Content-Type", "text/event-stream")
+		c.Header("Cache-Control", "no-cache")
+		c.Header("X-Accel-Buffering", "no")
+		c.Status(http.StatusOK)
+		send(c, chunk(Delta{Role: "assistant"}, nil))
+	}
+	reply, err := a.ollama.ChatStream(ctx, chatReq, func(s string) error {
+		start()
+		send(c, chunk(Delta{Content: s}, nil))
+		return ctx.Err()
+	})
+	if err != nil {
+		err = bridge.BackendError(err, "Ollama 对话失败")
+		if !started {
+			abort(c, err)
+			return
+		}
+		if ctx.Err() == nil {
+			a.logger.Error("流式对话失败", "model", model, "error", err)
+			send(c, errorResponse(c, err))
+		}
+		return
+	}
+	start()
+	stop := "stop"
+	send(c, chunk(Delta{}, &stop))
+	u := a.record(c, model, req.User, reply.Usage)
+	if req.StreamOptions != nil && req.StreamOptions.IncludeUsage {
+		last := chunk(Delta{}, nil)
+		last.Choices, last.Usage = []ChunkChoice{}, &u
+		send(c, last)
+	}
+	fmt.Fprint(c.Writer, "data: [DONE]\n\n")
+	c.Writer.Flush()
+}
+
+// Embeddings 处理 POST /v1/embeddings
+func (a *API) Embeddings(c *gin.Context) {
+	var req EmbeddingRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		abort(c, apperr.Wrap(err, apperr.Validation, apperr.CodeInvalidParams, "请求体无效"))
+		return
+	}
+	if req.Model == "" || len(req.Input) == 0 {
+		abort(c, apperr.New(apperr.Validation, apperr.CodeInvalidParams, "model 与 input 不能为空"))
+		return
+	}
+	if req.EncodingFormat != "" && req.EncodingFormat != "float" && req.EncodingFormat != "base64" {
+		abort(c, apperr.New(apperr.Validation, apperr.CodeInvalidParams, "encoding_format 只支持 float 与 base64"))
+		return
+	}
+	model := a.model(req.Model)
+	e, err := a.ollama.Embed(c.Request.Context(), bridge.EmbedRequest{Model: model, Input: req.Input})
+	if err != nil {
+		abort(c, bridge.BackendError(err, "Ollama 计算向量失败"))
+		return
+	}
+	data := make([]Embedding, len(e.Vectors))
+	for i, v := range e.Vectors {
+		data[i] = Embedding{Object: "embedding", Index: i, Embedding: v}
+		if req.EncodingFormat == "base64" {
+			data[i].Embedding = encodeBase64(v)
+		}
+	}
+	u := a.record(c, model, req.User, e.Usage)
+	c.JSON(http.StatusOK, EmbeddingList{Object: "list", Data: data, Model: req.Model, Usage: u})
+}
+
+// Models 处理 GET /v1/models，列出 Ollama 上的模型，以及目标模型已存在的映射名
+func (a *API) Models(c *gin.Context) {
+	infos, err := a.ollama.ListModels(c.Request.Context())
+	if err != nil {
+		abort(c, bridge.BackendError(err, "查询模型列表失败"))
+		return
+	}
+	list := ModelList{Object: "list", Data: make([]Model, 0, len(infos))}
+	created := make(map[string]int64, len(infos))
+	for _, info := range infos {
+		created[info.Name] = info.ModifiedAt.Unix()
+		list.Data = append(list.Data, Model{ID: info.Name, Object: "model", Created: info.ModifiedAt.Unix(), OwnedBy: "ollama"})
+	}
+	aliases := a.store.Get().Server.OpenAI.Models
+	for _, name := range slices.Sorted(maps.Keys(aliases)) {
+		if t, ok := created[withTag(aliases[name])]; ok {
+			list.Data = append(list.Data, Model{ID: name, Object: "model", Created: t, OwnedBy: "ollama"})
+		}
+	}
+	c.JSON(http.StatusOK, list)
+}
+
+// model 返回请求中的模型名对应的 Ollama 模型名
+func (a *API) model(name string) string {
+	if m, ok := a.store.Get().Server.OpenAI.Models[name]; ok {
+		return m
+	}
+	return name
+}
+
+// record 按调用方记录 token 用量，返回 OpenAI 格式的用量
+func (a *API) record(c *gin.Context, model, user string, u bridge.Usage) Usage {
+	u.User, u.Model = user, model
+	if a.usage != nil {
+		if err := a.usage.Record(usage.CallerOf(c.Request.Context()), u, time.Now()); err != nil {
+			a.logger.Warn("记录用量失败", "error", err)
+		}
+	}
+	return Usage{PromptTokens: u.PromptTokens, CompletionTokens: u.CompletionTokens, TotalTokens: u.PromptTokens + u.CompletionTokens}
+}
+
+// send 写入一行 SSE data 并立即发送
+func send(c *gin.Context, v any) {
+	data, _ := json.Marshal(v)
+	fmt.Fprintf(c.Writer, "data: %s\n\n", data)
+	c.Writer.Flush()
+}
+
+// abort 以 OpenAI 格式返回错误，模型不存在时与 OpenAI 一样返回 404
+func abort(c *gin.Context, err error) {
+	status := apperr.HTTPStatus(err)
+	if apperr.CodeOf(err) == apperr.CodeModelNotFound {
+		status = http.StatusNotFound
+	}
+	c.AbortWithStatusJSON(status, errorResponse(c, err))
+}
+
+func errorResponse(c *gin.Context, err error) ErrorResponse {
+	e := middleware.NewErrorResponse(c, err)
+	typ := "server_error"
+	switch e.Category {
+	case apperr.Validation, apperr.Protocol:
+		typ = "invalid_request_error"
+	case apperr.Auth:
+		typ = "authentication_error"
+	}
+	return ErrorResponse{Error: ErrorBody{Message: e.Error, Type: typ, Code: e.Code}}
+}
+
+// encodeBase64 按 OpenAI 的 base64 格式编码向量：小端 float32 依次排列
+func encodeBase64(v []float32) string {
+	buf := make([]byte, 4*len(v))
+	for i, f := range v {
+		binary.LittleEndian.PutUint32(buf[4*i:], math.Float32bits(f))
+	}
+	return base64.StdEncoding.EncodeToString(buf)
+}
+
+// withTag 未写标签的模型名视为 :latest
+func withTag(name string) string {
+	if strings.Contains(name, ":") {
+		return name
+	}
+	return name + ":latest"
+}
@@ -0,0 +1,183 @@
+package openai
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"ollama_dev/internal/bridge"
+	"ollama_dev/internal/config"
+)
+
+// echoOllama 记录调用的模型名与 options，回复最后一条消息，流式时按空格拆成片段
+type echoOllama struct {
+	bridge.OllamaClient
+	model   string
+	options map[string]any
+}
+
+func (o *echoOllama) Chat(ctx context.Context, req bridge.ChatRequest) (bridge.Reply, error) {
+	o.model, o.options = req.Model, req.Options
+	return bridge.Reply{Content: req.Messages[len(req.Messages)-1].Content, Usage: bridge.Usage{PromptTokens: 5, CompletionTokens: 2}}, nil
+}
+
+func (o *echoOllama) ChatStream(ctx context.Context, req bridge.ChatRequest, onChunk func(string) error) (bridge.Reply, error) {
+	o.model, o.options = req.Model, req.Options
+	content := req.Messages[len(req.Messages)-1].Content
+	for _, word := range strings.SplitAfter(content, " ") {
+		if err := onChunk(word); err != nil {
+			return bridge.Reply{}, err
+		}
+	}
+	return bridge.Reply{Content: content, Usage: bridge.Usage{PromptTokens: 5, CompletionTokens: 2}}, nil
+}
+
+func (o *echoOllama) Embed(ctx context.Context, req bridge.EmbedRequest) (bridge.Embeddings, error) {
+	o.model = req.Model
+	vectors := make([][]float32, len(req.Input))
+	for i := range vectors {
+		vectors[i] = []float32{1, 0.5}
+	}
+	return bridge.Embeddings{Vectors: vectors, Usage: bridge.Usage{PromptTokens: 4}}, nil
+}
+
+func (o *echoOllama) ListModels(ctx context.Context) ([]bridge.ModelInfo, error) {
+	return []bridge.ModelInfo{{Name: "llama3:latest", ModifiedAt: time.Unix(1700000000, 0)}}, nil
+}
+
+func serve(t *testing.T, ollama bridge.OllamaClient, method, path, body string) *httptest.ResponseRecorder {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	cfg := config.Default()
+	cfg.Server.OpenAI.Models = map[string]string{"gpt-4o": "llama3", "gpt-missing": "qwen2"}
+	r := gin.New()
+	New(ollama, config.NewStore("", cfg), nil, slog.New(slog.DiscardHandler)).Register(r.Group("/v1"))
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(method, path, strings.NewReader(body)))
+	return w
+}
+
+func TestChatCompletions(t *testing.T) {
+	ollama := &echoOllama{}
+	// content 为片段数组时只取文本片段
+	w := serve(t, ollama, http.MethodPost, "/v1/chat/completions",
+		`{"model":"gpt-4o","temperature":0.2,"max_tokens":64,"top_p":0.9,"stop":"\n","seed":7,"messages":[{"role":"user","content":[{"type":"text","text":"hi "},{"type":"image_url"},{"type":"text","text":"there"}]}]}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, body %s", w.Code, w.Body)
+	}
+	var resp ChatCompletion
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if ollama.model != "llama3" {
+		t.Errorf("ollama model = %q, want mapped llama3", ollama.model)
+	}
+	// 采样参数转换为 Ollama 的 options
+	if want := map[string]any{"temperature": 0.2, "num_predict": 64, "top_p": 0.9, "stop": []string{"\n"}, "seed": 7}; !reflect.DeepEqual(ollama.options, want) {
+		t.Errorf("options = %v, want %v", ollama.options, want)
+	}
+	if resp.Object != "chat.completion" || resp.Model != "gpt-4o" || len(resp.Choices) != 1 ||
+		resp.Choices[0].Message.Content != "hi there" || resp.Choices[0].FinishReason != "stop" {
+		t.Errorf("resp = %+v", resp)
+	}
+	if resp.Usage != (Usage{PromptTokens: 5, CompletionTokens: 2, TotalTokens: 7}) {
+		t.Errorf("usage = %+v", resp.Usage)
+	}
+}
+
+func TestChatCompletionsStream(t *testing.T) {
+	ollama := &echoOllama{}
+	w := serve(t, ollama, http.MethodPost, "/v1/chat/completions",
+		`{"model":"llama3","stream":true,"stream_options":{"include_usage":true},"stop":["END","STOP"],"messages":[{"role":"user","content":"hi there"}]}`)
+	if ct := w.Header().Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("Content-Type = %q", ct)
+	}
+	lines := strings.Split(strings.TrimSpace(w.Body.String()), "\n\n")
+	if len(lines) != 6 || lines[5] != "data: [DONE]" {
+		t.Fatalf("lines = %q", lines)
+	}
+	var content strings.Builder
+	var chunks []ChatCompletionChunk
+	for _, line := range lines[:5] {
+		var chunk ChatCompletionChunk
+		if err := json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &chunk); err != nil {
+			t.Fatal(err)
+		}
+		chunks = append(chunks, chunk)
+		if len(chunk.Choices) > 0 {
+			content.WriteString(chunk.Choices[0].Delta.Content)
+		}
+	}
+	// 第一个分片只有 role，最后是 finish_reason 与 usage
+	if chunks[0].Choices[0].Delta.Role != "assistant" || content.String() != "hi there" {
+		t.Errorf("chunks = %+v", chunks)
+	}
+	if f := chunks[3].Choices[0].FinishReason; f == nil || *f != "stop" {
+		t.Errorf("finish chunk = %+v", chunks[3])
+	}
+	if len(chunks[4].Choices) != 0 || chunks[4].Usage == nil || chunks[4].Usage.TotalTokens != 7 {
+		t.Errorf("usage chunk = %+v", chunks[4])
+	}
+	if want := map[string]any{"stop": []string{"END", "STOP"}}; !reflect.DeepEqual(ollama.options, want) {
+		t.Errorf("options = %v, want %v", ollama.options, want)
+	}
+}
+
+func TestEmbeddings(t *testing.T) {
+	ollama := &echoOllama{}
+	w := serve(t, ollama, http.MethodPost, "/v1/embeddings", `{"model":"nomic-embed-text","input":"hello","encoding_format":"base64"}`)
+	var resp struct {
+		Data []struct {
+			Index     int    `json:"index"`
+			Embedding string `json:"embedding"`
+		} `json:"data"`
+		Usage Usage `json:"usage"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("%v: %s", err, w.Body)
+	}
+	if len(resp.Data) != 1 || resp.Usage.PromptTokens != 4 || resp.Usage.TotalTokens != 4 {
+		t.Fatalf("resp = %+v", resp)
+	}
+	raw, err := base64.StdEncoding.DecodeString(resp.Data[0].Embedding)
+	// 1 与 0.5 的小端 float32
+	if err != nil || string(raw) != "\x00\x00\x80\x3f\x00\x00\x00\x3f" {
+		t.Errorf("embedding = %x, %v", raw, err)
+	}
+}
+
+func TestModels(t *testing.T) {
+	w := serve(t, &echoOllama{}, http.MethodGet, "/v1/models", "")
+	var list ModelList
+	if err := json.Unmarshal(w.Body.Bytes(), &list); err != nil {
+		t.Fatal(err)
+	}
+	// 目标模型不存在的映射名不列出
+	var ids []string
+	for _, m := range list.Data {
+		ids = append(ids, m.ID)
+	}
+	if strings.Join(ids, ",") != "llama3:latest,gpt-4o" || list.Data[1].Created != 1700000000 {
+		t.Errorf("models = %+v", list.Data)
+	}
+}
+
+func TestErrorFormat(t *testing.T) {
+	w := serve(t, &echoOllama{}, http.MethodPost, "/v1/chat/completions", `{"model":"llama3"}`)
+	var resp ErrorResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if w.Code != http.StatusBadRequest || resp.Error.Type != "invalid_request_error" || resp.Error.Message == "" {
+		t.Errorf("status = %d, resp = %+v", w.Code, resp)
+	}
+}
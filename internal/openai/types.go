@@ -0,0 +1,221 @@
+package openai
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+)
+
+// ChatCompletionRequest POST /v1/chat/completions 请求体；采样参数经 Options 转换为 Ollama 的 options
+type ChatCompletionRequest struct {
+	Model         string         `json:"model"`
+	Messages      []Message      `json:"messages"`
+	Stream        bool           `json:"stream,omitempty"`
+	StreamOptions *StreamOptions `json:"stream_options,omitempty"`
+	User          string         `json:"user,omitempty"`
+
+	Temperature *float64 `json:"temperature,omitempty"`
+	MaxTokens   *int     `json:"max_tokens,omitempty"`
+	TopP        *float64 `json:"top_p,omitempty"`
+	Stop        Stop     `json:"stop,omitempty"`
+	Seed        *int     `json:"seed,omitempty"`
+}
+
+// Options 返回请求中的采样参数对应的 Ollama options，未设置任何参数时为 nil
+func (r *ChatCompletionRequest) Options() map[string]any {
+	options := map[string]any{}
+	if r.Temperature != nil {
+		options["temperature"] = *r.Temperature
+	}
+	if r.MaxTokens != nil {
+		options["num_predict"] = *r.MaxTokens
+	}
+	if r.TopP != nil {
+		options["top_p"] = *r.TopP
+	}
+	if len(r.Stop) > 0 {
+		options["stop"] = []string(r.Stop)
+	}
+	if r.Seed != nil {
+		options["seed"] = *r.Seed
+	}
+	if len(options) == 0 {
+		return nil
+	}
+	return options
+}
+
+// StreamOptions include_usage 为 true 时在 [DONE] 之前多发一个只含 usage 的分片
+type StreamOptions struct {
+	IncludeUsage bool `json:"include_usage"`
+}
+
+// Message 对话消息，请求中的 content 可以是字符串或内容片段数组，只取其中的文本片段
+type Message struct {
+	Role    string  `json:"role"`
+	Content Content `json:"content"`
+}
+
+// Content 消息的文本内容
+type Content string
+
+// UnmarshalJSON 接受字符串、null 与 [{"type":"text","text":"..."}] 形式的片段数组
+func (c *Content) UnmarshalJSON(data []byte) error {
+	var s *string
+	if err := json.Unmarshal(data, &s); err == nil {
+		if s != nil {
+			*c = Content(*s)
+		}
+		return nil
+	}
+	var parts []struct {
+		Type string `json:"type"`
+		Text string `json:"text"`
+	}
+	if err := json.Unmarshal(data, &parts); err != nil {
+		return errors.New("content 必须是字符串或内容片段数组")
+	}
+	var b strings.Builder
+	for _, p := range parts {
+		if p.Type == "text" {
+			b.WriteString(p.Text)
+		}
+	}
+	*c = Content(b.String())
+	return nil
+}
+
+// Stop 停止序列
+type Stop []string
+
+// UnmarshalJSON 接受字符串、null 与字符串数组
+func (s *Stop) UnmarshalJSON(data []byte) error {
+	var one *string
+	if err := json.Unmarshal(data, &one); err == nil {
+		if one != nil {
+			*s = Stop{*one}
+		}
+		return nil
+	}
+	var many []string
+	if err := json.Unmarshal(data, &many); err != nil {
+		return errors.New("stop 必须是字符串或字符串数组")
+	}
+	*s = many
+	return nil
+}
+
+// ChatCompletion 非流式响应
+type ChatCompletion struct {
+	ID      string   `json:"id"`
+	Object  string   `json:"object"` // chat.completion
+	Created int64    `json:"created"`
+	Model   string   `json:"model"`
+	Choices []Choice `json:"choices"`
+	Usage   Usage    `json:"usage"`
+}
+
+// Choice 非流式响应的候选回复，Ollama 只生成一个
+type Choice struct {
+	Index        int     `json:"index"`
+	Message      Message `json:"message"`
+	FinishReason string  `json:"finish_reason"`
+}
+
+// ChatCompletionChunk 流式响应的分片
+type ChatCompletionChunk struct {
+	ID      string        `json:"id"`
+	Object  string        `json:"object"` // chat.completion.chunk
+	Created int64         `json:"created"`
+	Model   string        `json:"model"`
+	Choices []ChunkChoice `json:"choices"`
+	Usage   *Usage        `json:"usage,omitempty"`
+}
+
+// ChunkChoice 分片中的增量，最后一个分片的 finish_reason 为 stop
+type ChunkChoice struct {
+	Index        int     `json:"index"`
+	Delta        Delta   `json:"delta"`
+	FinishReason *string `json:"finish_reason"`
+}
+
+// Delta 增量内容，role 只出现在第一个分片
+type Delta struct {
+	Role    string `json:"role,omitempty"`
+	Content string `json:"content,omitempty"`
+}
+
+// Usage token 用量
+type Usage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+	TotalTokens      int `json:"total_tokens"`
+}
+
+// EmbeddingRequest POST /v1/embeddings 请求体，input 可以是字符串或字符串数组
+type EmbeddingRequest struct {
+	Model          string `json:"model"`
+	Input          Input  `json:"input"`
+	EncodingFormat string `json:"encoding_format,omitempty"` // float (默认) 或 base64
+	User           string `json:"user,omitempty"`
+}
+
+// Input 向量的输入文本
+type Input []string
+
+// UnmarshalJSON 接受字符串或字符串数组
+func (in *Input) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err == nil {
+		*in = Input{s}
+		return nil
+	}
+	var list []string
+	if err := json.Unmarshal(data, &list); err != nil {
+		return errors.New("input 必须是字符串或字符串数组")
+	}
+	*in = list
+	return nil
+}
+
+// EmbeddingList POST /v1/embeddings 响应
+type EmbeddingList struct {
+	Object string      `json:"object"` // list
+	Data   []Embedding `json:"data"`
+	Model  string      `json:"model"`
+	Usage  Usage       `json:"usage"`
+}
+
+// Embedding 一条输入的向量，encoding_format 为 base64 时为小端 float32 的 base64 字符串
+type Embedding struct {
+	Object    string `json:"object"` // embedding
+	Index     int    `json:"index"`
+	Embedding any    `json:"embedding"`
+}
+
+// ModelList GET /v1/models 响应
+type ModelList struct {
+	Object string  `json:"object"` // list
+	Data   []Model `json:"data"`
+}
+
+// Model 模型，created 为 Ollama 中的修改时间
+type Model struct {
+	ID      string `json:"id"`
+	Object  string `json:"object"` // model
+	Created int64  `json:"created"`
+	OwnedBy string `json:"owned_by"`
+}
+
+// ErrorResponse OpenAI 格式的错误响应
+type ErrorResponse struct {
+	Error ErrorBody `json:"error"`
+}
+
+// ErrorBody type 按错误类别取 OpenAI 的取值，code 为本服务的错误码
+type ErrorBody struct {
+	Message string  `json:"message"`
+	Type    string  `json:"type"`
+	Param   *string `json:"param"`
+	Code    string  `json:"code"`
+}
@@ -0,0 +1,89 @@
+package openapi
+
+// Version 生成的文档遵循的 OpenAPI 版本
+const Version = "3.0.3"
+
+// Document OpenAPI 文档，只包含本项目用到的字段
+type Document struct {
+	OpenAPI    string                                 `json:"openapi"`
+	Info       Info                                   `json:"info"`
+	Paths      map[string]map[string]*OperationObject `json:"paths"` // 路径 -> 小写的 HTTP 方法 -> 接口
+	Components Components                             `json:"components"`
+}
+
+// Info 文档标题与版本
+type Info struct {
+	Title   string `json:"title"`
+	Version string `json:"version"`
+}
+
+// Components 可复用的 schema 与鉴权方式
+type Components struct {
+	Schemas         map[string]*Schema         `json:"schemas,omitempty"`
+	SecuritySchemes map[string]*SecurityScheme `json:"securitySchemes,omitempty"`
+}
+
+// SecurityScheme 鉴权方式
+type SecurityScheme struct {
+	Type   string `json:"type"`
+	Scheme string `json:"scheme,omitempty"`
+}
+
+// OperationObject 一个接口
+type OperationObject struct {
+	OperationID string                     `json:"operationId,omitempty"`
+	Summary     string                     `json:"summary,omitempty"`
+	Description string                     `json:"description,omitempty"`
+	Tags        []string                   `json:"tags,omitempty"`
+	Security    []map[string][]string      `json:"security,omitempty"`
+	Parameters  []*ParameterObject         `json:"parameters,omitempty"`
+	RequestBody *RequestBodyObject         `json:"requestBody,omitempty"`
+	Responses   map[string]*ResponseObject `json:"responses"`
+}
+
+// ParameterObject 路径或查询参数
+type ParameterObject struct {
+	Name        string  `json:"name"`
+	In          string  `json:"in"`
+	Description string  `json:"description,omitempty"`
+	Required    bool    `json:"required,omitempty"`
+	Schema      *Schema `json:"schema"`
+}
+
+// RequestBodyObject 请求体
+type RequestBodyObject struct {
+	Required bool                  `json:"required,omitempty"`
+	Content  map[string]*MediaType `json:"content"`
+}
+
+// ResponseObject 一种状态码的响应
+type ResponseObject struct {
+	Description string                `json:"description"`
+	Content     map[string]*MediaType `json:"content,omitempty"`
+}
+
+// MediaType 某种内容类型的响应体
+type MediaType struct {
+	Schema *Schema `json:"schema,omitempty"`
+}
+
+// Schema JSON Schema 的子集
+type Schema struct {
+	Ref                  string             `json:"$ref,omitempty"`
+	Type                 string             `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+	Enum                 []string           `json:"enum,omitempty"`
+}
+
+// RefName 返回 $ref 指向的 schema 名称，不是引用时返回空
+func (s *Schema) RefName() string {
+	const prefix = "#/components/schemas/"
+	if s == nil || len(s.Ref) <= len(prefix) {
+		return ""
+	}
+	return s.Ref[len(prefix):]
+}
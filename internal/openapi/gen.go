@@ -0,0 +1,294 @@
+package openapi
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Generate 根据文档生成 Go 客户端代码：components 中的 schema 生成为结构体，
+// 成功时返回 JSON 的接口生成为 Client 的方法。生成的代码依赖同一包中手写的
+// Client.do，见 pkg/client
+func Generate(doc *Document, pkg string) ([]byte, error) {
+	g := &generator{imports: map[string]bool{}}
+	g.types(doc.Components.Schemas)
+	g.operations(doc.Paths)
+
+	var out bytes.Buffer
+	fmt.Fprintf(&out, "// Code generated by ollama_dev openapi; DO NOT EDIT.\n\npackage %s\n\n", pkg)
+	if len(g.imports) > 0 {
+		out.WriteString("import (\n")
+		for _, imp := range sortedKeys(g.imports) {
+			fmt.Fprintf(&out, "\t%q\n", imp)
+		}
+		out.WriteString(")\n\n")
+	}
+	out.Write(g.body.Bytes())
+	src, err := format.Source(out.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("格式化生成的代码失败: %w", err)
+	}
+	return src, nil
+}
+
+type generator struct {
+	body    bytes.Buffer
+	imports map[string]bool
+}
+
+func (g *generator) printf(format string, args ...any) {
+	fmt.Fprintf(&g.body, format, args...)
+}
+
+func (g *generator) types(schemas map[string]*Schema) {
+	for _, name := range sortedKeys(schemas) {
+		s := schemas[name]
+		g.printf("// %s 对应 OpenAPI 文档中的 schema %s\n", exportedName(name), name)
+		g.printf("type %s struct {\n", exportedName(name))
+		for _, prop := range sortedKeys(s.Properties) {
+			required := slices.Contains(s.Required, prop)
+			typ := g.goType(s.Properties[prop], required)
+			tag := prop
+			if !required {
+				if typ == "time.Time" {
+					tag += ",omitzero"
+				} else {
+					tag += ",omitempty"
+				}
+			}
+			g.printf("\t%s %s `json:%q`\n", exportedName(prop), typ, tag)
+		}
+		g.printf("}\n\n")
+	}
+}
+
+// goType 返回 schema 对应的 Go 类型，可选的结构体字段使用指针
+func (g *generator) goType(s *Schema, required bool) string {
+	if name := exportedName(s.RefName()); name != "" {
+		if required {
+			return name
+		}
+		return "*" + name
+	}
+	switch s.Type {
+	case "string":
+		switch s.Format {
+		case "date-time":
+			g.imports["time"] = true
+			return "time.Time"
+		case "byte":
+			return "[]byte"
+		}
+		return "string"
+	case "integer":
+		return "int64"
+	case "number":
+		return "float64"
+	case "boolean":
+		return "bool"
+	case "array":
+		return "[]" + g.goType(s.Items, true)
+	case "object":
+		if s.AdditionalProperties != nil {
+			return "map[string]" + g.goType(s.AdditionalProperties, true)
+		}
+		return "map[string]any"
+	default:
+		return "any"
+	}
+}
+
+var methodOrder = []string{"get", "post", "put", "patch", "delete"}
+
+func (g *generator) operations(paths map[string]map[string]*OperationObject) {
+	for _, p := range sortedKeys(paths) {
+		for _, method := range methodOrder {
+			if op := paths[p][method]; op != nil && op.OperationID != "" {
+				g.operation(p, method, op)
+			}
+		}
+	}
+}
+
+// operation 生成一个接口的方法，成功时不返回 JSON 的接口 (WebSocket、页面、文件下载) 不生成
+func (g *generator) operation(path, method string, op *OperationObject) {
+	result, accepted := successSchema(op.Responses)
+	if result == nil {
+		return
+	}
+	name := exportedName(op.OperationID)
+
+	var args []string
+	var query []*ParameterObject
+	for _, p := range op.Parameters {
+		switch p.In {
+		case "path":
+			args = append(args, lowerName(p.Name)+" string")
+		case "query":
+			query = append(query, p)
+		}
+	}
+	if len(query) > 0 {
+		g.printf("// %sParams %s 的查询参数，空字符串表示不传\n", name, name)
+		g.printf("type %sParams struct {\n", name)
+		for _, p := range query {
+			g.printf("\t%s string", exportedName(p.Name))
+			if p.Description != "" {
+				g.printf(" // %s", p.Description)
+			}
+			g.printf("\n")
+		}
+		g.printf("}\n\n")
+		args = append(args, "params *"+name+"Params")
+	}
+	body := "nil"
+	if op.RequestBody != nil {
+		if mt := op.RequestBody.Content["application/json"]; mt != nil {
+			args = append(args, "body "+g.goType(mt.Schema, true))
+			body = "body"
+		}
+	}
+
+	typ := g.goType(result, true)
+	ret, zero, out := typ, "nil", "out"
+	switch {
+	case result.RefName() != "":
+		ret, out = "*"+typ, "&out"
+	case !strings.HasPrefix(typ, "map[") && !strings.HasPrefix(typ, "[]") && typ != "any":
+		zero = "*new(" + typ + ")"
+	}
+
+	g.printf("// %s %s\n", name, op.Summary)
+	g.printf("func (c *Client) %s(%s) (%s, error) {\n", name, strings.Join(append([]string{"ctx context.Context"}, args...), ", "), ret)
+	queryArg := "nil"
+	if len(query) > 0 {
+		g.imports["net/url"] = true
+		queryArg = "q"
+		g.printf("q := url.Values{}\nif params != nil {\n")
+		for _, p := range query {
+			field := exportedName(p.Name)
+			g.printf("if params.%s != \"\" {\nq.Set(%q, params.%s)\n}\n", field, p.Name, field)
+		}
+		g.printf("}\n")
+	}
+	security := ""
+	if len(op.Security) > 0 {
+		for k := range op.Security[0] {
+			security = k
+		}
+	}
+	statuses := make([]string, len(accepted))
+	for i, s := range accepted {
+		statuses[i] = strconv.Itoa(s)
+	}
+	g.imports["context"] = true
+	g.imports["net/http"] = true
+	g.printf("var out %s\n", typ)
+	g.printf("if err := c.do(ctx, http.Method%s, %s, %s, %s, %q, []int{%s}, &out); err != nil {\nreturn %s, err\n}\n",
+		methodName(method), strings.Join(g.pathExpr(path), " + "), queryArg, body, security, strings.Join(statuses, ", "), zero)
+	g.printf("return %s, nil\n}\n\n", out)
+}
+
+// pathExpr 将 /admin/features/{name} 拆成字符串常量与转义后的参数
+func (g *generator) pathExpr(path string) []string {
+	var parts []string
+	rest := path
+	for {
+		i := strings.Index(rest, "{")
+		j := strings.Index(rest, "}")
+		if i < 0 || j < i {
+			break
+		}
+		g.imports["net/url"] = true
+		parts = append(parts, strconv.Quote(rest[:i]), "url.PathEscape("+lowerName(rest[i+1:j])+")")
+		rest = rest[j+1:]
+	}
+	if rest != "" || len(parts) == 0 {
+		parts = append(parts, strconv.Quote(rest))
+	}
+	return parts
+}
+
+// successSchema 返回 2xx 响应中的 JSON schema，以及响应体为同一 schema 的全部状态码
+// (例如 /healthz 在排空阶段以 503 返回同样的结构)
+func successSchema(responses map[string]*ResponseObject) (*Schema, []int) {
+	var result *Schema
+	for _, key := range sortedKeys(responses) {
+		status, err := strconv.Atoi(key)
+		if err != nil || status < 200 || status >= 300 {
+			continue
+		}
+		if mt := responses[key].Content["application/json"]; mt != nil && mt.Schema != nil {
+			result = mt.Schema
+			break
+		}
+	}
+	if result == nil {
+		return nil, nil
+	}
+	var accepted []int
+	for _, key := range sortedKeys(responses) {
+		status, err := strconv.Atoi(key)
+		if err != nil {
+			continue
+		}
+		mt := responses[key].Content["application/json"]
+		if mt == nil || mt.Schema == nil {
+			continue
+		}
+		if status >= 200 && status < 300 || result.RefName() != "" && mt.Schema.RefName() == result.RefName() {
+			accepted = append(accepted, status)
+		}
+	}
+	return result, accepted
+}
+
+var initialisms = map[string]string{"id": "ID", "url": "URL", "api": "API", "http": "HTTP", "json": "JSON", "ws": "WS"}
+
+// exportedName 将 model_name、getVersion 转换为 ModelName、GetVersion
+func exportedName(s string) string {
+	var b strings.Builder
+	for _, part := range strings.FieldsFunc(s, func(r rune) bool { return r == '_' || r == '-' || r == '.' }) {
+		if v, ok := initialisms[strings.ToLower(part)]; ok {
+			b.WriteString(v)
+			continue
+		}
+		b.WriteString(strings.ToUpper(part[:1]) + part[1:])
+	}
+	return b.String()
+}
+
+func lowerName(s string) string {
+	name := exportedName(s)
+	if v, ok := initialisms[strings.ToLower(name)]; ok && v == name {
+		return strings.ToLower(name)
+	}
+	return strings.ToLower(name[:1]) + name[1:]
+}
+
+func methodName(method string) string {
+	switch method {
+	case "get":
+		return "Get"
+	case "post":
+		return "Post"
+	case "put":
+		return "Put"
+	case "patch":
+		return "Patch"
+	default:
+		return "Delete"
+	}
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	return keys
+}
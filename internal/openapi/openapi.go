@@ -0,0 +1,189 @@
+// Package openapi 随路由注册收集接口说明，生成 OpenAPI 3 文档与 Go 客户端
+package openapi
+
+import (
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 鉴权方式
+const (
+	SecurityTenant = "tenant" // Bearer Token，配置多租户时按 Token 识别租户
+	SecurityAdmin  = "admin"  // 管理员 Basic Auth
+)
+
+// Param 路径或查询参数，取值均为字符串
+type Param struct {
+	Name        string
+	In          string // path 或 query
+	Description string
+	Required    bool
+	Enum        []string
+}
+
+// Response 一种响应
+type Response struct {
+	Status      int
+	Description string
+	Body        any    // 响应体的示例值（零值即可），用于推导 schema；为 nil 时没有 JSON 响应体
+	ContentType string // 非 JSON 响应的类型，例如 text/csv；与 Body 同时设置时表示另一种可选格式
+}
+
+// Operation 一个接口的说明
+type Operation struct {
+	ID          string // operationId，同时作为客户端的方法名
+	Summary     string
+	Description string
+	Tag         string
+	Security    string // SecurityTenant、SecurityAdmin，为空表示无需鉴权
+	Params      []Param
+	Body        any // 请求体的示例值，为 nil 时没有请求体
+	Responses   []Response
+}
+
+// Registry 收集接口说明，在路由注册完成后生成文档
+type Registry struct {
+	title, version string
+	routes         []route
+	schemas        *schemas
+
+	once sync.Once
+	doc  *Document
+}
+
+type route struct {
+	method, path string
+	op           Operation
+}
+
+// New 创建接口说明的登记表
+func New(title, version string) *Registry {
+	return &Registry{title: title, version: version, schemas: newSchemas()}
+}
+
+// Handle 在 g 上注册路由并记录其说明，gin 的 :name 参数在文档中写作 {name}
+func (r *Registry) Handle(g *gin.RouterGroup, method, relativePath string, op Operation, handlers ...gin.HandlerFunc) {
+	g.Handle(method, relativePath, handlers...)
+	r.Add(method, joinPath(g.BasePath(), relativePath), op)
+}
+
+// Add 记录由其他方式注册的路由的说明
+func (r *Registry) Add(method, fullPath string, op Operation) {
+	r.routes = append(r.routes, route{method: method, path: openAPIPath(fullPath), op: op})
+}
+
+// Document 返回 OpenAPI 文档，首次调用后不再记录新的路由
+func (r *Registry) Document() *Document {
+	r.once.Do(func() { r.doc = r.build() })
+	return r.doc
+}
+
+// Handler 以 JSON 返回 OpenAPI 文档
+func (r *Registry) Handler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.JSON(http.StatusOK, r.Document())
+	}
+}
+
+func (r *Registry) build() *Document {
+	doc := &Document{
+		OpenAPI: Version,
+		Info:    Info{Title: r.title, Version: r.version},
+		Paths:   map[string]map[string]*OperationObject{},
+		Components: Components{
+			Schemas: map[string]*Schema{},
+			SecuritySchemes: map[string]*SecurityScheme{
+				SecurityTenant: {Type: "http", Scheme: "bearer"},
+				SecurityAdmin:  {Type: "http", Scheme: "basic"},
+			},
+		},
+	}
+	for _, rt := range r.routes {
+		item := doc.Paths[rt.path]
+		if item == nil {
+			item = map[string]*OperationObject{}
+			doc.Paths[rt.path] = item
+		}
+		item[strings.ToLower(rt.method)] = r.operation(rt.op)
+	}
+	doc.Components.Schemas = r.schemas.resolve()
+	return doc
+}
+
+func (r *Registry) operation(op Operation) *OperationObject {
+	o := &OperationObject{
+		OperationID: op.ID,
+		Summary:     op.Summary,
+		Description: op.Description,
+		Responses:   map[string]*ResponseObject{},
+	}
+	if op.Tag != "" {
+		o.Tags = []string{op.Tag}
+	}
+	if op.Security != "" {
+		o.Security = []map[string][]string{{op.Security: {}}}
+	}
+	for _, p := range op.Params {
+		o.Parameters = append(o.Parameters, &ParameterObject{
+			Name:        p.Name,
+			In:          p.In,
+			Description: p.Description,
+			Required:    p.Required || p.In == "path",
+			Schema:      &Schema{Type: "string", Enum: p.Enum},
+		})
+	}
+	if op.Body != nil {
+		o.RequestBody = &RequestBodyObject{
+			Required: true,
+			Content:  map[string]*MediaType{"application/json": {Schema: r.schemas.of(op.Body)}},
+		}
+	}
+	for _, resp := range op.Responses {
+		ro := &ResponseObject{Description: resp.Description}
+		if resp.Body != nil || resp.ContentType != "" {
+			ro.Content = map[string]*MediaType{}
+		}
+		if resp.Body != nil {
+			ro.Content["application/json"] = &MediaType{Schema: r.schemas.of(resp.Body)}
+		}
+		if resp.ContentType != "" {
+			ro.Content[resp.ContentType] = &MediaType{}
+		}
+		o.Responses[statusKey(resp.Status)] = ro
+	}
+	return o
+}
+
+func joinPath(base, rel string) string {
+	if rel == "" {
+		return base
+	}
+	p := path.Join(base, rel)
+	if strings.HasSuffix(rel, "/") && !strings.HasSuffix(p, "/") {
+		p += "/"
+	}
+	return p
+}
+
+// openAPIPath 将 gin 的 :name 与 *name 参数改写为 {name}
+func openAPIPath(p string) string {
+	parts := strings.Split(p, "/")
+	for i, part := range parts {
+		if strings.HasPrefix(part, ":") || strings.HasPrefix(part, "*") {
+			parts[i] = "{" + part[1:] + "}"
+		}
+	}
+	return strings.Join(parts, "/")
+}
+
+func statusKey(status int) string {
+	if status == 0 {
+		return "default"
+	}
+	return strconv.Itoa(status)
+}
@@ -0,0 +1,92 @@
+package openapi
+
+import (
+	"net/http"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type inner struct {
+	Name string `json:"name"`
+}
+
+type sample struct {
+	ID      string            `json:"id"`
+	Tags    []string          `json:"tags,omitempty"`
+	At      time.Time         `json:"at,omitzero"`
+	Counts  map[string]int    `json:"counts"`
+	Inner   inner             `json:"inner"`
+	Others  []*inner          `json:"others"`
+	Skipped string            `json:"-"`
+	Extra   map[string]*inner `json:"extra,omitempty"`
+}
+
+func TestDocument(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	spec := New("test", "1.0")
+	g := gin.New().Group("/api")
+	spec.Handle(g, http.MethodPut, "/items/:id", Operation{
+		ID:        "putItem",
+		Security:  SecurityAdmin,
+		Params:    []Param{{Name: "id", In: "path"}},
+		Body:      inner{},
+		Responses: []Response{{Status: http.StatusOK, Body: sample{}}},
+	}, func(c *gin.Context) {})
+
+	doc := spec.Document()
+	op := doc.Paths["/api/items/{id}"]["put"]
+	if op == nil || op.OperationID != "putItem" || !op.Parameters[0].Required {
+		t.Fatalf("paths = %+v", doc.Paths)
+	}
+	s := doc.Components.Schemas["sample"]
+	if s == nil {
+		t.Fatalf("schemas = %v", doc.Components.Schemas)
+	}
+	// 带 omitempty/omitzero 的字段不是必需字段
+	if want := []string{"id", "counts", "inner", "others"}; !slices.Equal(s.Required, want) {
+		t.Fatalf("required = %v, want %v", s.Required, want)
+	}
+	if _, ok := s.Properties["Skipped"]; ok {
+		t.Fatal("json:\"-\" 字段不应出现")
+	}
+	if s.Properties["at"].Format != "date-time" || s.Properties["inner"].Ref != "#/components/schemas/inner" ||
+		s.Properties["others"].Items.Ref != "#/components/schemas/inner" || s.Properties["counts"].AdditionalProperties.Type != "integer" {
+		t.Fatalf("properties = %+v", s.Properties)
+	}
+}
+
+func TestGenerate(t *testing.T) {
+	spec := New("test", "1.0")
+	spec.Add(http.MethodGet, "/items/:id", Operation{
+		ID:        "getItem",
+		Summary:   "读取条目",
+		Params:    []Param{{Name: "id", In: "path"}, {Name: "view", In: "query"}},
+		Responses: []Response{{Status: http.StatusOK, Body: sample{}}, {Status: http.StatusNotFound}},
+	})
+	// 成功时不返回 JSON 的接口不生成方法
+	spec.Add(http.MethodGet, "/ws", Operation{ID: "connect", Responses: []Response{{Status: http.StatusSwitchingProtocols}}})
+
+	src, err := Generate(spec.Document(), "client")
+	if err != nil {
+		t.Fatal(err)
+	}
+	code := string(src)
+	for _, want := range []string{
+		"type Sample struct",
+		"`json:\"at,omitzero\"`",
+		"Others []Inner",
+		"func (c *Client) GetItem(ctx context.Context, id string, params *GetItemParams) (*Sample, error)",
+		`"/items/"+url.PathEscape(id)`,
+	} {
+		if !strings.Contains(code, want) {
+			t.Errorf("生成的代码缺少 %q:\n%s", want, code)
+		}
+	}
+	if strings.Contains(code, "Connect(") {
+		t.Error("不应为 WebSocket 接口生成方法")
+	}
+}
@@ -0,0 +1,122 @@
+package openapi
+
+import (
+	"path"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// schemas 从 Go 类型推导 schema，具名结构体放入 components，名称在生成文档时确定：
+// 类型名不重复时直接使用，不同包的同名类型加上包名前缀，例如 VersionInfo 与 ModelsInfo
+type schemas struct {
+	types []reflect.Type
+	defs  map[reflect.Type]*Schema   // 具名结构体 -> 定义
+	refs  map[reflect.Type][]*Schema // 具名结构体 -> 指向它的引用，名称确定后填写 $ref
+}
+
+func newSchemas() *schemas {
+	return &schemas{defs: map[reflect.Type]*Schema{}, refs: map[reflect.Type][]*Schema{}}
+}
+
+// of 返回 v 的类型对应的 schema
+func (s *schemas) of(v any) *Schema {
+	return s.schema(reflect.TypeOf(v))
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+func (s *schemas) schema(t reflect.Type) *Schema {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t == timeType {
+		return &Schema{Type: "string", Format: "date-time"}
+	}
+	switch t.Kind() {
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return &Schema{Type: "integer", Format: "int64"}
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: "number", Format: "double"}
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return &Schema{Type: "string", Format: "byte"}
+		}
+		return &Schema{Type: "array", Items: s.schema(t.Elem())}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: s.schema(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return s.object(t)
+		}
+		ref := &Schema{}
+		s.refs[t] = append(s.refs[t], ref)
+		if _, ok := s.defs[t]; !ok {
+			s.types = append(s.types, t)
+			s.defs[t] = nil // 占位，避免自引用的类型无限递归
+			s.defs[t] = s.object(t)
+		}
+		return ref
+	default:
+		// interface 等任意值
+		return &Schema{}
+	}
+}
+
+// object 按 encoding/json 的规则展开结构体字段，没有 omitempty/omitzero 的字段为必需字段
+func (s *schemas) object(t reflect.Type) *Schema {
+	obj := &Schema{Type: "object", Properties: map[string]*Schema{}}
+	for i := range t.NumField() {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		if tag == "-" || !f.IsExported() && !f.Anonymous {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+		if f.Anonymous && name == "" && f.Type.Kind() == reflect.Struct {
+			embedded := s.object(f.Type)
+			for k, v := range embedded.Properties {
+				obj.Properties[k] = v
+			}
+			obj.Required = append(obj.Required, embedded.Required...)
+			continue
+		}
+		if !f.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		obj.Properties[name] = s.schema(f.Type)
+		if !strings.Contains(opts, "omitempty") && !strings.Contains(opts, "omitzero") {
+			obj.Required = append(obj.Required, name)
+		}
+	}
+	return obj
+}
+
+// resolve 确定各具名结构体的名称，填写引用并返回 components.schemas
+func (s *schemas) resolve() map[string]*Schema {
+	count := map[string]int{}
+	for _, t := range s.types {
+		count[t.Name()]++
+	}
+	out := make(map[string]*Schema, len(s.types))
+	for _, t := range s.types {
+		name := t.Name()
+		if count[name] > 1 {
+			pkg := path.Base(t.PkgPath())
+			name = strings.ToUpper(pkg[:1]) + pkg[1:] + name
+		}
+		out[name] = s.defs[t]
+		for _, ref := range s.refs[t] {
+			ref.Ref = "#/components/schemas/" + name
+		}
+	}
+	return out
+}
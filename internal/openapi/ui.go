@@ -0,0 +1,37 @@
+package openapi
+
+import (
+	"html/template"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// swaggerUIVersion 页面从 CDN 加载的 swagger-ui-dist 版本
+const swaggerUIVersion = "5.17.14"
+
+var uiPage = template.Must(template.New("ui").Parse(`<!DOCTYPE html>
+<html lang="zh-CN">
+<head>
+<meta charset="utf-8">
+<title>ollama_dev API</title>
+<link rel="stylesheet" href="https://unpkg.com/swagger-ui-dist@{{.Version}}/swagger-ui.css">
+</head>
+<body>
+<div id="swagger-ui"></div>
+<script src="https://unpkg.com/swagger-ui-dist@{{.Version}}/swagger-ui-bundle.js"></script>
+<script>
+window.ui = SwaggerUIBundle({url: {{.SpecURL}}, dom_id: "#swagger-ui"});
+</script>
+</body>
+</html>
+`))
+
+// UIHandler 返回 Swagger UI 页面，页面从 specURL 读取文档；脚本与样式从 unpkg 加载，离线环境下不可用
+func UIHandler(specURL string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Header("Content-Type", "text/html; charset=utf-8")
+		c.Status(http.StatusOK)
+		_ = uiPage.Execute(c.Writer, struct{ Version, SpecURL string }{swaggerUIVersion, specURL})
+	}
+}
@@ -0,0 +1,139 @@
+// Package plugins 挂载在 serve 的 gin 路由组上的插件。内置插件由路由注册 (如子包 websocket 提供的 /ws)，
+// 其他插件在所在包的 init 中调用 Register，由 main 包以空导入启用；是否挂载及挂载路径由 server.plugins 决定
+package plugins
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"maps"
+	"slices"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+
+	"ollama_dev/internal/config"
+	"ollama_dev/internal/logging"
+	"ollama_dev/internal/openapi"
+)
+
+// Plugin 挂载在一个路由组上的插件
+type Plugin interface {
+	// Name 插件名，即 server.plugins 中的键
+	Name() string
+	// Init 在挂载路径对应的路由组上注册路由并启动后台任务；返回错误时插件不挂载，其余路由照常提供
+	Init(r *gin.RouterGroup, logger *slog.Logger, store *config.Store) error
+	// Shutdown serve 退出时按挂载的逆序调用，关闭插件持有的连接与后台任务
+	Shutdown(ctx context.Context) error
+}
+
+// Describer 插件可选实现，在 OpenAPI 文档中登记路由，path 为挂载路径
+type Describer interface {
+	Describe(spec *openapi.Registry, path string)
+}
+
+var (
+	registeredMu sync.RWMutex
+	registered   []Plugin
+)
+
+// Register 注册插件，通常在插件所在包的 init 中调用；名称为空或重复注册时 panic
+func Register(p Plugin) {
+	if p == nil || p.Name() == "" {
+		panic("plugins: 插件缺少名称")
+	}
+	registeredMu.Lock()
+	defer registeredMu.Unlock()
+	if slices.ContainsFunc(registered, func(r Plugin) bool { return r.Name() == p.Name() }) {
+		panic(fmt.Sprintf("plugins: 插件 %s 重复注册", p.Name()))
+	}
+	registered = append(registered, p)
+}
+
+// Registered 返回 Register 注册的插件，按注册顺序
+func Registered() []Plugin {
+	registeredMu.RLock()
+	defer registeredMu.RUnlock()
+	return slices.Clone(registered)
+}
+
+// Registry 一次 serve 运行中的插件：按配置挂载内置插件与 Register 注册的插件，退出时关闭已挂载的插件
+type Registry struct {
+	mu      sync.Mutex
+	builtin []Plugin
+	mounted []Plugin
+}
+
+func NewRegistry() *Registry {
+	return &Registry{}
+}
+
+// Add 添加内置插件，内置插件先于 Register 注册的插件挂载
+func (r *Registry) Add(p Plugin) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.builtin = append(r.builtin, p)
+}
+
+// Mount 在 root 下挂载 server.plugins 中启用的插件，路径未配置时为 /<name>；
+// 重名或 Init 失败的插件不挂载，记录错误后继续挂载其余插件
+func (r *Registry) Mount(root *gin.RouterGroup, spec *openapi.Registry, store *config.Store, logger *slog.Logger) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	cfg := store.Get().Server.Plugins
+	seen := map[string]bool{}
+	for _, p := range append(slices.Clone(r.builtin), Registered()...) {
+		name := p.Name()
+		if seen[name] {
+			logger.Error("插件重名，忽略", "plugin", name)
+			continue
+		}
+		seen[name] = true
+		c, ok := cfg[name]
+		if !ok || !c.Enabled {
+			logger.Info("插件未启用", "plugin", name)
+			continue
+		}
+		path := c.Path
+		if path == "" {
+			path = "/" + name
+		}
+		if err := p.Init(root.Group(path), logging.Component(logger, name), store); err != nil {
+			logger.Error("初始化插件失败，不挂载", "plugin", name, "error", err)
+			continue
+		}
+		r.mounted = append(r.mounted, p)
+		if d, ok := p.(Describer); ok && spec != nil {
+			d.Describe(spec, path)
+		}
+		logger.Info("插件已加载", "plugin", name, "path", path)
+	}
+	for _, name := range slices.Sorted(maps.Keys(cfg)) {
+		if !seen[name] {
+			logger.Warn("server.plugins 中的插件未注册，忽略", "plugin", name)
+		}
+	}
+}
+
+// Mounted 插件是否已挂载
+func (r *Registry) Mounted(name string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return slices.ContainsFunc(r.mounted, func(p Plugin) bool { return p.Name() == name })
+}
+
+// Shutdown 按挂载的逆序关闭插件，返回全部失败
+func (r *Registry) Shutdown(ctx context.Context) error {
+	r.mu.Lock()
+	mounted := slices.Clone(r.mounted)
+	r.mounted = nil
+	r.mu.Unlock()
+	var errs []error
+	for _, p := range slices.Backward(mounted) {
+		if err := p.Shutdown(ctx); err != nil {
+			errs = append(errs, fmt.Errorf("关闭插件 %s 失败: %w", p.Name(), err))
+		}
+	}
+	return errors.Join(errs...)
+}
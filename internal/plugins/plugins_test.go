@@ -0,0 +1,108 @@
+package plugins
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"maps"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"ollama_dev/internal/config"
+	"ollama_dev/internal/openapi"
+)
+
+// fakePlugin 在挂载路径下提供 GET /ping，Shutdown 时记录名称
+type fakePlugin struct {
+	name    string
+	initErr error
+	closed  *[]string
+}
+
+func (p *fakePlugin) Name() string { return p.name }
+
+func (p *fakePlugin) Init(r *gin.RouterGroup, logger *slog.Logger, store *config.Store) error {
+	if p.initErr != nil {
+		return p.initErr
+	}
+	r.GET("/ping", func(c *gin.Context) { c.String(http.StatusOK, p.name) })
+	return nil
+}
+
+func (p *fakePlugin) Shutdown(ctx context.Context) error {
+	*p.closed = append(*p.closed, p.name)
+	return nil
+}
+
+func (p *fakePlugin) Describe(spec *openapi.Registry, path string) {
+	spec.Add(http.MethodGet, path+"/ping", openapi.Operation{ID: p.name + "Ping"})
+}
+
+func TestRegistryMount(t *testing.T) {
+	var closed []string
+	cfg := config.Default()
+	cfg.Server.Plugins = map[string]config.PluginConfig{
+		"alpha":  {Enabled: true},
+		"beta":   {Enabled: true, Path: "/custom"},
+		"off":    {Enabled: false},
+		"broken": {Enabled: true},
+	}
+	reg := NewRegistry()
+	for _, p := range []*fakePlugin{
+		{name: "alpha"}, {name: "beta"}, {name: "off"}, {name: "unset"},
+		{name: "broken", initErr: errors.New("boom")}, {name: "alpha"},
+	} {
+		p.closed = &closed
+		reg.Add(p)
+	}
+	r := gin.New()
+	spec := openapi.New("test", "dev")
+	reg.Mount(&r.RouterGroup, spec, config.NewStore("", cfg), slog.New(slog.DiscardHandler))
+
+	for path, want := range map[string]int{
+		"/alpha/ping":  http.StatusOK,
+		"/custom/ping": http.StatusOK,
+		"/beta/ping":   http.StatusNotFound,
+		"/off/ping":    http.StatusNotFound,
+		"/unset/ping":  http.StatusNotFound,
+		"/broken/ping": http.StatusNotFound,
+	} {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		if w.Code != want {
+			t.Errorf("%s: expected %d, got %d", path, want, w.Code)
+		}
+	}
+	for name, want := range map[string]bool{"alpha": true, "beta": true, "off": false, "unset": false, "broken": false} {
+		if reg.Mounted(name) != want {
+			t.Errorf("Mounted(%q) = %v, want %v", name, !want, want)
+		}
+	}
+	if paths := spec.Document().Paths; paths["/custom/ping"] == nil || paths["/off/ping"] != nil {
+		t.Errorf("only mounted plugins are documented, got %v", slices.Collect(maps.Keys(paths)))
+	}
+
+	// 按挂载的逆序关闭，重复调用不会再次关闭
+	if err := reg.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	_ = reg.Shutdown(context.Background())
+	if want := []string{"beta", "alpha"}; !slices.Equal(closed, want) {
+		t.Errorf("closed %v, want %v", closed, want)
+	}
+}
+
+func TestRegisterRejectsDuplicates(t *testing.T) {
+	var closed []string
+	Register(&fakePlugin{name: "registered-once", closed: &closed})
+	defer func() {
+		if recover() == nil {
+			t.Error("expected a panic for a duplicate name")
+		}
+	}()
+	Register(&fakePlugin{name: "registered-once", closed: &closed})
+}
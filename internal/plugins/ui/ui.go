@@ -0,0 +1,68 @@
+// Package ui 内置的网页对话界面，作为路由插件挂载在 server.plugins.ui 的路径 (默认 /ui) 下；
+// 页面经 /ws 发送请求，与 client 命令使用相同的帧协议
+package ui
+
+import (
+	"context"
+	_ "embed"
+	"log/slog"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"ollama_dev/internal/config"
+	"ollama_dev/internal/plugins"
+	"ollama_dev/internal/plugins/websocket"
+)
+
+// Name 插件名，即 server.plugins 中的键
+const Name = "ui"
+
+// modelsPath 页面获取模型列表的 REST 接口，失败时改为经 /ws 发送 list_model
+const modelsPath = "/api/models"
+
+//go:embed index.html
+var page []byte
+
+// Settings 页面启动时从 <路径>/settings.json 读取的连接参数
+type Settings struct {
+	WSPath     string `json:"ws_path"`     // WebSocket 插件的挂载路径，未启用时为空
+	ModelsPath string `json:"models_path"` // 模型列表接口
+}
+
+func init() {
+	plugins.Register(Plugin{})
+}
+
+// Plugin 提供页面与连接参数，不持有后台任务
+type Plugin struct{}
+
+func (Plugin) Name() string {
+	return Name
+}
+
+func (Plugin) Init(r *gin.RouterGroup, logger *slog.Logger, store *config.Store) error {
+	r.GET("/", func(c *gin.Context) {
+		c.Data(http.StatusOK, "text/html; charset=utf-8", page)
+	})
+	// 按当前配置返回，websocket 插件的路径随配置文件变化
+	r.GET("/settings.json", func(c *gin.Context) {
+		c.JSON(http.StatusOK, settings(store.Get()))
+	})
+	return nil
+}
+
+func (Plugin) Shutdown(context.Context) error {
+	return nil
+}
+
+func settings(cfg *config.Config) Settings {
+	s := Settings{ModelsPath: modelsPath}
+	if ws, ok := cfg.Server.Plugins[websocket.Name]; ok && ws.Enabled {
+		s.WSPath = ws.Path
+		if s.WSPath == "" {
+			s.WSPath = "/" + websocket.Name
+		}
+	}
+	return s
+}
@@ -0,0 +1,72 @@
+package ui
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"ollama_dev/internal/config"
+	"ollama_dev/internal/plugins"
+)
+
+func mount(t *testing.T, cfg *config.Config) *gin.Engine {
+	t.Helper()
+	r := gin.New()
+	reg := plugins.NewRegistry()
+	reg.Mount(&r.RouterGroup, nil, config.NewStore("", cfg), slog.New(slog.DiscardHandler))
+	return r
+}
+
+func get(r *gin.Engine, path string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+	return w
+}
+
+func TestServesPageAndSettings(t *testing.T) {
+	cfg := config.Default()
+	cfg.Server.Plugins = map[string]config.PluginConfig{
+		"ui":        {Enabled: true, Path: "/chat"},
+		"websocket": {Enabled: true, Path: "/socket"},
+	}
+	r := mount(t, cfg)
+
+	w := get(r, "/chat/")
+	if w.Code != http.StatusOK || !strings.Contains(w.Header().Get("Content-Type"), "text/html") || !strings.Contains(w.Body.String(), `fetch("settings.json")`) {
+		t.Fatalf("unexpected page: %d %s", w.Code, w.Header().Get("Content-Type"))
+	}
+	if w := get(r, "/chat"); w.Code != http.StatusMovedPermanently {
+		t.Errorf("expected a redirect to /chat/, got %d", w.Code)
+	}
+
+	var s Settings
+	w = get(r, "/chat/settings.json")
+	if err := json.Unmarshal(w.Body.Bytes(), &s); err != nil {
+		t.Fatal(err)
+	}
+	if s.WSPath != "/socket" || s.ModelsPath != "/api/models" {
+		t.Errorf("unexpected settings: %+v", s)
+	}
+}
+
+func TestSettingsWithoutWebSocket(t *testing.T) {
+	cfg := config.Default()
+	cfg.Server.Plugins = map[string]config.PluginConfig{"ui": {Enabled: true}}
+	r := mount(t, cfg)
+
+	var s Settings
+	if err := json.Unmarshal(get(r, "/ui/settings.json").Body.Bytes(), &s); err != nil {
+		t.Fatal(err)
+	}
+	if s.WSPath != "" {
+		t.Errorf("ws_path must be empty when the websocket plugin is disabled, got %q", s.WSPath)
+	}
+	if w := get(r, "/ui/"); w.Code != http.StatusOK {
+		t.Errorf("the page is still served, got %d", w.Code)
+	}
+}
@@ -0,0 +1,157 @@
+package websocket
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"slices"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// TypeSystem serve 主动推送的系统通知帧的 type
+const TypeSystem = "system"
+
+// SystemAnnouncement 管理员推送的公告，data 为 Announcement
+const SystemAnnouncement = "announcement"
+
+// SystemFrame 系统通知帧
+type SystemFrame struct {
+	V      int           `json:"v,omitempty"`
+	Type   string        `json:"type"`
+	Action string        `json:"action"`
+	Status string        `json:"status"`
+	Data   *Announcement `json:"data,omitempty"`
+}
+
+// Announcement 公告内容，room 为空时推送给全部连接
+type Announcement struct {
+	Message string    `json:"message"`
+	Room    string    `json:"room,omitempty"`
+	SentAt  time.Time `json:"sent_at"`
+}
+
+// ConnectionInfo /admin/connections 中的一个连接
+type ConnectionInfo struct {
+	ID          string    `json:"id"` // 即房间成员的 id
+	RemoteAddr  string    `json:"remote_addr"`
+	Tenant      string    `json:"tenant"`
+	Subject     string    `json:"subject,omitempty"` // 鉴权得到的调用方
+	Room        string    `json:"room,omitempty"`
+	Name        string    `json:"name,omitempty"` // 在房间中显示的名称
+	ConnectedAt time.Time `json:"connected_at"`
+	Received    int64     `json:"messages_received"`
+	Sent        int64     `json:"messages_sent"`
+	QueueLen    int       `json:"queue_len"` // 发送队列中尚未写出的帧数
+}
+
+// kick 断开 id 对应的连接，回复是否找到
+type kick struct {
+	id    string
+	reply chan bool
+}
+
+// announcement 向 tenant (为空时为全部租户) 中 room (为空时为全部连接) 的连接推送 data，回复送达的连接数
+type announcement struct {
+	tenant, room string
+	data         []byte
+	reply        chan int
+}
+
+// Connections 返回当前连接，按建立时间排序
+func (h *Hub) Connections() []ConnectionInfo {
+	h.mu.RLock()
+	list := make([]ConnectionInfo, 0, len(h.Clients))
+	for client := range h.Clients {
+		list = append(list, ConnectionInfo{
+			ID:          client.member.ID,
+			RemoteAddr:  client.Conn.RemoteAddr().String(),
+			Tenant:      client.Tenant,
+			Subject:     client.Identity.Subject,
+			Room:        h.members[client],
+			Name:        client.member.Name,
+			ConnectedAt: client.connectedAt,
+			Received:    client.received.Load(),
+			Sent:        client.sent.Load(),
+			QueueLen:    len(client.Send),
+		})
+	}
+	h.mu.RUnlock()
+	slices.SortFunc(list, func(a, b ConnectionInfo) int {
+		return cmp.Or(a.ConnectedAt.Compare(b.ConnectedAt), cmp.Compare(a.ID, b.ID))
+	})
+	return list
+}
+
+// Kick 断开 id 对应的连接：写完发送队列后发送 1008 (policy violation) 关闭帧，所在房间的成员收到 leave 通知；
+// 连接不存在时返回 false。Hub 需已在运行
+func (h *Hub) Kick(ctx context.Context, id string) (bool, error) {
+	k := kick{id: id, reply: make(chan bool, 1)}
+	select {
+	case h.kicks <- k:
+	case <-ctx.Done():
+		return false, ctx.Err()
+	}
+	return <-k.reply, nil
+}
+
+// Announce 推送公告，tenant 为空时推送给全部租户，返回送达的连接数；发送队列已满的连接跳过。Hub 需已在运行
+func (h *Hub) Announce(ctx context.Context, tenant string, a Announcement) (int, error) {
+	if a.SentAt.IsZero() {
+		a.SentAt = time.Now()
+	}
+	data, err := json.Marshal(SystemFrame{Type: TypeSystem, Action: SystemAnnouncement, Status: "done", Data: &a})
+	if err != nil {
+		return 0, err
+	}
+	req := announcement{tenant: tenant, room: a.Room, data: data, reply: make(chan int, 1)}
+	select {
+	case h.announces <- req:
+	case <-ctx.Done():
+		return 0, ctx.Err()
+	}
+	return <-req.reply, nil
+}
+
+// kick 在 Run 中调用
+func (h *Hub) kick(id string) bool {
+	h.mu.RLock()
+	var target *Client
+	for client := range h.Clients {
+		if client.member.ID == id {
+			target = client
+			break
+		}
+	}
+	h.mu.RUnlock()
+	if target == nil {
+		return false
+	}
+	target.closeCode = websocket.ClosePolicyViolation
+	h.drop(target, "kicked")
+	target.Logger.Info("连接已被管理员断开", "id", id)
+	return true
+}
+
+// announce 在 Run 中调用
+func (h *Hub) announce(a announcement) int {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	delivered := 0
+	for client := range h.Clients {
+		if a.tenant != "" && client.Tenant != a.tenant {
+			continue
+		}
+		if a.room != "" && h.members[client] != a.room {
+			continue
+		}
+		select {
+		case client.Send <- a.data:
+			delivered++
+		default:
+		}
+	}
+	hubStats.Add("announcements", 1)
+	return delivered
+}
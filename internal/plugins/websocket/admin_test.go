@@ -0,0 +1,93 @@
+package websocket
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"ollama_dev/internal/auth"
+)
+
+func TestAdminListKickAndAnnounce(t *testing.T) {
+	h := NewHub()
+	go h.Run()
+	upgrader := &websocket.Upgrader{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tenant := r.URL.Query().Get("tenant")
+		serveWs(h, upgrader, 4, nil, nil, handshake{identity: auth.Identity{Tenant: tenant}}, w, r, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	}))
+	defer srv.Close()
+
+	dial := func(tenant string) *websocket.Conn {
+		t.Helper()
+		conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http")+"?tenant="+tenant, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { conn.Close() })
+		return conn
+	}
+	acme, other := dial("acme"), dial("other")
+	for h.Stats().Total != 2 {
+		time.Sleep(time.Millisecond)
+	}
+
+	conns := h.Connections()
+	if len(conns) != 2 || conns[0].ID == "" || conns[0].RemoteAddr == "" || conns[0].ConnectedAt.IsZero() {
+		t.Fatalf("unexpected connections %+v", conns)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	// 只推送给 acme 租户
+	n, err := h.Announce(ctx, "acme", Announcement{Message: "maintenance at 22:00"})
+	if err != nil || n != 1 {
+		t.Fatalf("Announce = %d, %v", n, err)
+	}
+	_ = acme.SetReadDeadline(time.Now().Add(time.Second))
+	_, msg, err := acme.ReadMessage()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var f SystemFrame
+	if err := json.Unmarshal(msg, &f); err != nil || f.Type != TypeSystem || f.Action != SystemAnnouncement || f.Data.Message != "maintenance at 22:00" {
+		t.Fatalf("unexpected announcement %s (%v)", msg, err)
+	}
+
+	// 断开 other，收到 1008 关闭帧；不存在的 id 返回 false
+	var id string
+	for _, c := range conns {
+		if c.Tenant == "other" {
+			id = c.ID
+		}
+	}
+	if ok, err := h.Kick(ctx, id); !ok || err != nil {
+		t.Fatalf("Kick = %v, %v", ok, err)
+	}
+	_ = other.SetReadDeadline(time.Now().Add(time.Second))
+	if _, _, err := other.ReadMessage(); !websocket.IsCloseError(err, websocket.ClosePolicyViolation) {
+		t.Errorf("expected policy violation close frame, got %v", err)
+	}
+	if ok, _ := h.Kick(ctx, id); ok {
+		t.Error("kicking a closed connection should report not found")
+	}
+	got := h.Connections()
+	if len(got) != 1 || got[0].Tenant != "acme" {
+		t.Fatalf("unexpected connections after kick %+v", got)
+	}
+	// 公告写出后计入 messages_sent
+	for deadline := time.Now().Add(time.Second); got[0].Sent != 1 && time.Now().Before(deadline); got = h.Connections() {
+		time.Sleep(time.Millisecond)
+	}
+	if got[0].Sent != 1 {
+		t.Errorf("messages_sent = %d, want 1", got[0].Sent)
+	}
+}
@@ -0,0 +1,50 @@
+package websocket
+
+import (
+	"encoding/json"
+	"strings"
+
+	"ollama_dev/internal/apperr"
+	"ollama_dev/internal/auth"
+)
+
+// authorize 按 auth.ActionRole 校验连接能否发出请求帧的动作，不满足时回复 code 为 forbidden 的 ErrorFrame 并返回 false，帧应丢弃；
+// 需要 admin 角色的请求改写 role 字段为连接握手时核实的角色后返回，bridge 据此再次校验，帧中原有的 role 一律覆盖
+func (c *Client) authorize(message []byte) ([]byte, bool) {
+	var head frameHead
+	if json.Unmarshal(message, &head) != nil || !head.isRequest() {
+		return message, true
+	}
+	// user 角色已在握手时校验
+	role := auth.ActionRole(head.Action)
+	if role == auth.RoleUser {
+		return message, true
+	}
+	if !c.Identity.HasRole(role) {
+		hubStats.Add("forbidden_frames", 1)
+		c.Logger.Warn("拒绝请求：角色不满足动作要求", "action", head.Action, "role", role)
+		c.replyError(head, apperr.New(apperr.Auth, apperr.CodeForbidden, head.Action+" 需要 "+role+" 角色"), 0)
+		return nil, false
+	}
+	stamped, err := stampRole(message, role)
+	if err != nil {
+		c.Logger.Warn("丢弃无法改写的请求帧", "action", head.Action, "error", err)
+		return nil, false
+	}
+	return stamped, true
+}
+
+// stampRole 将帧的 role 字段设为 role；encoding/json 匹配字段名不区分大小写，大小写不同的同名字段一并删除
+func stampRole(message []byte, role string) ([]byte, error) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(message, &fields); err != nil {
+		return nil, err
+	}
+	for k := range fields {
+		if strings.EqualFold(k, "role") {
+			delete(fields, k)
+		}
+	}
+	fields["role"], _ = json.Marshal(role)
+	return json.Marshal(fields)
+}
@@ -0,0 +1,80 @@
+package websocket
+
+import (
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"ollama_dev/internal/apperr"
+	"ollama_dev/internal/auth"
+)
+
+func TestAdminActionsRequireRole(t *testing.T) {
+	h := NewHub()
+	go h.Run()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := auth.Identity{Tenant: "acme", Roles: []string{r.URL.Query().Get("role")}}
+		serveWs(h, &websocket.Upgrader{}, 16, nil, nil, handshake{identity: id}, w, r, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	}))
+	defer srv.Close()
+	dial := func(role string) *websocket.Conn {
+		t.Helper()
+		conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http")+"?role="+role, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { conn.Close() })
+		return conn
+	}
+	read := func(conn *websocket.Conn) map[string]json.RawMessage {
+		t.Helper()
+		_ = conn.SetReadDeadline(time.Now().Add(time.Second))
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var f map[string]json.RawMessage
+		if err := json.Unmarshal(data, &f); err != nil {
+			t.Fatal(err)
+		}
+		return f
+	}
+	user, admin, bridge := dial(auth.RoleUser), dial(auth.RoleAdmin), dial(auth.RoleUser)
+	for h.Stats().Total != 3 {
+		time.Sleep(time.Millisecond)
+	}
+
+	// user 角色的管理、传输与文件请求回复 forbidden，不转发；自报的 role 不起作用
+	for _, action := range []string{"delete_model", "copy_model", "create_model", "pull_model", "push_model", "load_model", "unload_model", "file_upload", "file_download"} {
+		_ = user.WriteMessage(websocket.TextMessage, []byte(`{"type":"server_to_client","action":"`+action+`","request_id":"`+action+`","role":"admin"}`))
+		var f ErrorFrame
+		raw, _ := json.Marshal(read(user))
+		if err := json.Unmarshal(raw, &f); err != nil {
+			t.Fatal(err)
+		}
+		if f.RequestID != action || f.Status != "error" || f.Data.Code != apperr.CodeForbidden {
+			t.Fatalf("%s: expected forbidden frame, got %+v", action, f)
+		}
+	}
+	// user 角色的对话照常转发，不写入 role
+	_ = user.WriteMessage(websocket.TextMessage, []byte(`{"type":"server_to_client","action":"chat","request_id":"c1"}`))
+	if f := read(bridge); string(f["request_id"]) != `"c1"` || f["role"] != nil {
+		t.Fatalf("expected the forwarded chat request without role, got %s", f)
+	}
+	read(user)
+	read(admin)
+
+	// admin 的请求转发时 role 改写为核实的角色，大小写不同的同名字段被删除
+	_ = admin.WriteMessage(websocket.TextMessage, []byte(`{"type":"server_to_client","action":"delete_model","request_id":"d1","ROLE":"root","params":{"model_name":"llama3"}}`))
+	f := read(bridge)
+	if string(f["request_id"]) != `"d1"` || string(f["role"]) != `"admin"` || f["ROLE"] != nil || string(f["params"]) != `{"model_name":"llama3"}` {
+		t.Fatalf("expected the forwarded request with role admin, got %s", f)
+	}
+}
@@ -1,31 +1,178 @@
 package websocket
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"sync/atomic"
+	"time"
+
 	"github.com/gorilla/websocket"
+
+	"ollama_dev/internal/auth"
+	"ollama_dev/internal/capture"
+	"ollama_dev/internal/tenant"
+	"ollama_dev/internal/usage"
+	"ollama_dev/internal/util/wsutils"
 )
 
 type Client struct {
-	Hub  *Hub
-	Conn *websocket.Conn
-	Send chan []byte
+	Hub         *Hub
+	Conn        *websocket.Conn
+	Send        chan []byte
+	ID          string           // 连接标识，用于抓包
+	Tenant      string           // 所属租户，只与同一租户的连接互通
+	Identity    auth.Identity    // 握手时通过鉴权的调用方
+	Subprotocol string           // 握手时协商的子协议，未协商时为空，按 SubprotocolJSON 处理
+	Capture     *capture.Capture // 可为 nil
+	Logger      *slog.Logger     // 携带 tenant 字段
+	Usage       *usage.Store     // 可为 nil，表示不统计用量
+
+	rateKey           string        // 限流时区分客户端的键，握手时按 server.rate_limit.key 确定
+	codec             wsutils.Codec // 按协商的子协议确定的帧编码
+	compressThreshold int           // 协商了压缩时，小于该字节数的帧不压缩
+	member            RoomMember    // 在房间中的身份，由 Hub 在 Run 中设置
+	closeCode         int           // 非 0 时 WritePump 写完队列后发送该关闭帧，由 Hub 在关闭 Send 前设置
+	flushed           chan struct{} // 可为 nil，WritePump 退出时关闭，Hub.Shutdown 据此等待
+	lastSeen          atomic.Int64  // 最近一次收到 Pong 或消息的时间 (UnixNano)，心跳检查据此回收失联连接
+	connectedAt       time.Time     // 握手完成的时间
+	received, sent    atomic.Int64  // 收到与成功写出的帧数，供 /admin/connections 查看
 }
 
+// closeTimeout 发送关闭帧与等待对端回复关闭帧的时限
+const closeTimeout = 2 * time.Second
+
 func (c *Client) ReadPump() {
+	// 连接断开时中止本地处理中的请求；ctx 携带连接的租户与调用方，与 HTTP 接口的请求相同
+	ctx, cancel := context.WithCancel(tenant.WithTenant(auth.WithIdentity(context.Background(), c.Identity), c.Tenant))
 	defer func() {
+		cancel()
 		c.Hub.Unregister <- c
 		_ = c.Conn.Close()
 	}()
+	c.Conn.SetPongHandler(func(string) error {
+		c.touch(time.Now())
+		return nil
+	})
 	for {
-		_, message, err := c.Conn.ReadMessage()
+		messageType, message, err := c.Conn.ReadMessage()
 		if err != nil {
 			break
 		}
-		c.Hub.Broadcast <- message
+		c.touch(time.Now())
+		c.received.Add(1)
+		wsMessages.Inc("in")
+		// 二进制帧按协商的编码还原为 JSON，之后与文本帧相同处理
+		if messageType == websocket.BinaryMessage {
+			if message, err = c.codec.Decode(message); err != nil {
+				c.Logger.Warn("丢弃无法解析的二进制帧", "subprotocol", c.Subprotocol, "error", err)
+				continue
+			}
+		}
+		c.Capture.Record(c.ID, capture.In, message)
+		// 房间控制帧由 Hub 处理，不转发
+		if f := parseRoomFrame(message); f != nil {
+			var params RoomInfo
+			if f.Params != nil {
+				params = *f.Params
+			}
+			switch f.Action {
+			case RoomHistory, RoomMembers:
+				c.Hub.queries <- roomQuery{client: c, action: f.Action, room: params.Room, limit: params.Limit}
+				continue
+			case RoomPresence:
+				if !validPresence(params.Presence) {
+					c.Logger.Warn("丢弃在线状态无效的帧", "presence", params.Presence)
+					continue
+				}
+				c.Hub.presence <- presenceEvent{client: c, action: RoomPresence, presence: params.Presence}
+				continue
+			case RoomTyping:
+				c.Hub.presence <- presenceEvent{client: c, action: RoomTyping, typing: params.Typing == nil || *params.Typing}
+				continue
+			case RoomJoin:
+				if params.Room == "" {
+					c.Logger.Warn("加入房间的帧缺少 params.room")
+					continue
+				}
+			default:
+				params = RoomInfo{}
+			}
+			c.Hub.rooms <- membership{client: c, room: params.Room, name: params.Name, limit: params.Limit}
+			continue
+		}
+		// 连接所属租户以 Token 为准，帧内声明其他租户时丢弃
+		if id, ok := frameTenant(message); ok && id != c.Tenant {
+			hubStats.Add("cross_tenant_frames", 1)
+			c.Logger.Warn("丢弃声明了其他租户的帧", "tenant_id", id)
+			continue
+		}
+		// 动作的角色要求在握手之后逐帧校验，本地处理与转发的请求相同
+		var allowed bool
+		if message, allowed = c.authorize(message); !allowed {
+			continue
+		}
+		if !c.requireSealed(message) || !c.checkQuota(message) {
+			continue
+		}
+		// 加密的请求由 bridge 解密，不在本地处理
+		local := c.Subprotocol != SubprotocolEncrypted && c.Hub.handler != nil && c.Hub.handler.Accept(message)
+		// 转发的请求先登记 request_id，重复时在限流之前拒绝
+		if !local && !c.forward(message) {
+			continue
+		}
+		release, ok := c.limit(message, local)
+		if !ok {
+			continue
+		}
+		if local {
+			go func() {
+				defer release()
+				c.serveLocal(ctx, message)
+			}()
+			continue
+		}
+		c.Hub.Broadcast <- Frame{Tenant: c.Tenant, Data: message, From: c}
 	}
 }
 
+// frameTenant 读取帧中的 tenant_id，未声明时返回 false
+func frameTenant(message []byte) (string, bool) {
+	if !bytes.Contains(message, []byte(`"tenant_id"`)) {
+		return "", false
+	}
+	var f struct {
+		TenantID string `json:"tenant_id"`
+	}
+	if err := json.Unmarshal(message, &f); err != nil || f.TenantID == "" {
+		return "", false
+	}
+	return f.TenantID, true
+}
+
 func (c *Client) WritePump() {
+	if c.flushed != nil {
+		defer close(c.flushed)
+	}
 	for msg := range c.Send {
-		_ = c.Conn.WriteMessage(websocket.TextMessage, msg)
+		c.Capture.Record(c.ID, capture.Out, msg)
+		if wsutils.WriteFrame(c.Conn, c.codec, msg, c.compressThreshold) == nil {
+			c.sent.Add(1)
+			wsMessages.Inc("out")
+		}
+	}
+	if c.closeCode != 0 {
+		// 对端回复关闭帧后 ReadPump 退出并关闭连接，不回复时读取超时退出
+		_ = c.Conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(c.closeCode, closeText(c.closeCode)), time.Now().Add(closeTimeout))
+		_ = c.Conn.SetReadDeadline(time.Now().Add(closeTimeout))
+	}
+}
+
+// closeText 关闭帧的说明
+func closeText(code int) string {
+	if code == websocket.ClosePolicyViolation {
+		return "closed by administrator"
 	}
+	return "server shutting down"
 }
@@ -0,0 +1,60 @@
+package websocket
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"ollama_dev/internal/config"
+	"ollama_dev/internal/janitor"
+)
+
+// touch 记录收到对端 Pong 或消息的时间
+func (c *Client) touch(now time.Time) {
+	c.lastSeen.Store(now.UnixNano())
+}
+
+// StartJanitor 每隔 cfg.Interval 向全部连接发送 Ping，并关闭连续 cfg.MaxMissed 个间隔没有回应的连接；
+// Interval 为 0 时不启用，Shutdown 后停止
+func (h *Hub) StartJanitor(cfg config.HeartbeatConfig, logger *slog.Logger) {
+	if cfg.Interval <= 0 {
+		return
+	}
+	j := janitor.New(cfg.Interval)
+	j.Register("ws_stale_connections", func(now time.Time) int {
+		return h.reapStale(now, cfg.Interval*time.Duration(cfg.MaxMissed), cfg.Interval)
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		<-h.done
+		cancel()
+	}()
+	go j.Run(ctx, logger)
+}
+
+// reapStale 关闭超过 timeout 没有回应的连接并返回数量，其余连接发送 Ping；
+// 被关闭的连接由其 ReadPump 照常注销
+func (h *Hub) reapStale(now time.Time, timeout, writeWait time.Duration) int {
+	h.mu.RLock()
+	clients := make([]*Client, 0, len(h.Clients))
+	for client := range h.Clients {
+		clients = append(clients, client)
+	}
+	h.mu.RUnlock()
+
+	reaped := 0
+	for _, client := range clients {
+		if idle := now.Sub(time.Unix(0, client.lastSeen.Load())); idle > timeout {
+			client.Logger.Info("关闭失联连接", "id", client.ID, "idle", idle.Round(time.Second))
+			_ = client.Conn.Close()
+			reaped++
+			continue
+		}
+		// WriteControl 可与 WritePump 并发调用
+		_ = client.Conn.WriteControl(websocket.PingMessage, nil, now.Add(writeWait))
+	}
+	hubStats.Add("stale_reaped", int64(reaped))
+	return reaped
+}
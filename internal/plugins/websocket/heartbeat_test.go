@@ -0,0 +1,72 @@
+package websocket
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"ollama_dev/internal/auth"
+	"ollama_dev/internal/config"
+)
+
+func TestJanitorReapsSilentConnections(t *testing.T) {
+	h := NewHub()
+	go h.Run()
+	h.StartJanitor(config.HeartbeatConfig{Interval: 50 * time.Millisecond, MaxMissed: 2}, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		_ = h.Shutdown(ctx)
+	}()
+	upgrader := &websocket.Upgrader{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		serveWs(h, upgrader, 4, nil, nil, handshake{identity: auth.Identity{Tenant: "acme"}}, w, r, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	}))
+	defer srv.Close()
+	url := "ws" + strings.TrimPrefix(srv.URL, "http")
+
+	// 持续读取的连接会自动回复 Pong，从不读取的连接不会
+	alive, _, err := websocket.DefaultDialer.Dial(url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer alive.Close()
+	go func() {
+		for {
+			if _, _, err := alive.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+	silent, _, err := websocket.DefaultDialer.Dial(url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer silent.Close()
+	for h.Stats().Total != 2 {
+		time.Sleep(time.Millisecond)
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for h.Stats().Total != 1 {
+		if time.Now().After(deadline) {
+			t.Fatalf("失联连接未被回收，剩余 %d 个连接", h.Stats().Total)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	// 回复 Pong 的连接在多个心跳间隔后仍然保留
+	time.Sleep(300 * time.Millisecond)
+	if got := h.Stats().Total; got != 1 {
+		t.Errorf("expected responsive client to stay, got %d clients", got)
+	}
+	if got := h.Stats().Disconnects; got != 1 {
+		t.Errorf("disconnects = %d, want 1", got)
+	}
+}
@@ -0,0 +1,245 @@
+package websocket
+
+import (
+	"bytes"
+	"container/list"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"slices"
+	"sync"
+
+	"ollama_dev/internal/config"
+)
+
+// RoomHistory 房间控制帧的 action：取回 params.room (为空时为当前房间) 最近 params.limit 条消息，
+// Hub 只向发送方回复同名帧，data.messages 按时间先后排列；join 帧带 params.limit 时加入后同样回复
+const RoomHistory = "history"
+
+// HistoryStore 按租户与房间保存最近的消息，Hub 在单独的写入 goroutine 中依次调用，实现不必考虑并发
+type HistoryStore interface {
+	// Append 追加一条消息，超出 size 时丢弃最早的
+	Append(tenant, room string, data []byte) error
+	// Recent 按时间先后返回最近 limit 条消息
+	Recent(tenant, room string, limit int) ([][]byte, error)
+	Close() error
+}
+
+// HistoryFactory 按配置创建历史后端
+type HistoryFactory func(cfg config.HistoryConfig) (HistoryStore, error)
+
+var (
+	historiesMu sync.RWMutex
+	histories   = map[string]HistoryFactory{
+		"memory": func(cfg config.HistoryConfig) (HistoryStore, error) {
+			return NewMemoryHistory(cfg.Size, cfg.MaxRooms), nil
+		},
+		"sqlite": func(cfg config.HistoryConfig) (HistoryStore, error) {
+			return OpenSQLiteHistory(cfg.Path, cfg.Size)
+		},
+	}
+)
+
+// RegisterHistoryStore 注册历史后端，之后可通过 server.websocket.history.backend 选择；重复注册时 panic
+func RegisterHistoryStore(name string, f HistoryFactory) {
+	historiesMu.Lock()
+	defer historiesMu.Unlock()
+	if _, dup := histories[name]; dup {
+		panic(fmt.Sprintf("websocket: 历史后端 %s 重复注册", name))
+	}
+	histories[name] = f
+}
+
+// NewHistoryStore 创建 history.backend 指定的后端，size 为 0 时返回 nil，表示不保存历史
+func NewHistoryStore(cfg config.HistoryConfig) (HistoryStore, error) {
+	if cfg.Size <= 0 {
+		return nil, nil
+	}
+	historiesMu.RLock()
+	f, ok := histories[cfg.Backend]
+	names := make([]string, 0, len(histories))
+	for name := range histories {
+		names = append(names, name)
+	}
+	historiesMu.RUnlock()
+	if !ok {
+		slices.Sort(names)
+		return nil, fmt.Errorf("未知的历史后端 %q，可选 %v", cfg.Backend, names)
+	}
+	return f(cfg)
+}
+
+// historyQueue 等待写入 goroutine 处理的消息与查询数，队列满时丢弃并计入 hub.history_dropped
+const historyQueue = 1024
+
+// historyOp 写入 goroutine 的一项工作：query 为 nil 时追加 data，否则回复查询
+type historyOp struct {
+	tenant, room string
+	data         []byte
+	logger       *slog.Logger // 发送方的日志，可为 nil
+	query        *roomQuery
+}
+
+// SetHistory 启用消息历史，须在 Run 之前调用；s 为 nil 时 history 帧回复空列表。
+// 读写在 Run 启动的单独 goroutine 中进行，磁盘 I/O 不阻塞广播与连接登记
+func (h *Hub) SetHistory(s HistoryStore) {
+	h.history = s
+}
+
+// startHistory 在 Run 开始时调用，启动读写历史的 goroutine
+func (h *Hub) startHistory() {
+	if h.history == nil {
+		return
+	}
+	h.historyOps = make(chan historyOp, historyQueue)
+	h.historyDone = make(chan struct{})
+	go h.runHistory(h.history, h.historyOps)
+}
+
+// stopHistory 在 Run 中调用，写完队列中的消息后关闭历史
+func (h *Hub) stopHistory() {
+	if h.history == nil {
+		return
+	}
+	close(h.historyOps)
+	<-h.historyDone
+	_ = h.history.Close()
+	h.history = nil
+}
+
+// runHistory 依次处理追加与查询，查询结果经 direct 交给 Run 投递，Hub 关闭后不再回复
+func (h *Hub) runHistory(store HistoryStore, ops <-chan historyOp) {
+	defer close(h.historyDone)
+	for op := range ops {
+		if op.query == nil {
+			if err := store.Append(op.tenant, op.room, op.data); err != nil {
+				hubStats.Add("history_errors", 1)
+				if op.logger != nil {
+					op.logger.Warn("保存消息历史失败", "room", op.room, "error", err)
+				}
+			}
+			continue
+		}
+		r := op.query
+		messages, err := store.Recent(op.tenant, op.room, r.limit)
+		if err != nil {
+			hubStats.Add("history_errors", 1)
+			r.client.Logger.Warn("读取消息历史失败", "room", r.room, "error", err)
+		}
+		select {
+		case h.direct <- Frame{Tenant: op.tenant, Data: historyFrame(r.room, messages), From: r.client}:
+		case <-h.done:
+		}
+	}
+}
+
+// enqueueHistory 在 Run 中调用，不阻塞；队列满时返回 false
+func (h *Hub) enqueueHistory(op historyOp) bool {
+	select {
+	case h.historyOps <- op:
+		return true
+	default:
+		hubStats.Add("history_dropped", 1)
+		return false
+	}
+}
+
+// record 在 Run 中调用，将广播的帧交给写入 goroutine 保存；心跳不保存
+func (h *Hub) record(f Frame, room string) {
+	if h.history == nil || bytes.Contains(f.Data, []byte(`"type":"heartbeat"`)) {
+		return
+	}
+	op := historyOp{tenant: f.Tenant, room: room, data: f.Data}
+	if f.From != nil {
+		op.logger = f.From.Logger
+	}
+	h.enqueueHistory(op)
+}
+
+// replay 在 Run 中调用，向连接回复房间最近的消息；未启用历史或队列已满时回复空列表
+func (h *Hub) replay(r roomQuery) {
+	if h.history != nil && h.enqueueHistory(historyOp{tenant: r.client.Tenant, room: r.room, query: &r}) {
+		return
+	}
+	select {
+	case r.client.Send <- historyFrame(r.room, nil):
+	default:
+	}
+}
+
+// historyFrame 返回 history 查询的回复帧
+func historyFrame(room string, messages [][]byte) []byte {
+	info := &RoomInfo{Room: room, Messages: make([]json.RawMessage, 0, len(messages))}
+	for _, m := range messages {
+		info.Messages = append(info.Messages, m)
+	}
+	data, _ := json.Marshal(RoomFrame{Type: TypeRoom, Action: RoomHistory, Status: "done", Data: info})
+	return data
+}
+
+// MemoryHistory 保存在内存中的历史，每个房间一个环形缓冲区，重启后丢失；
+// 房间名由客户端指定，最多保存 maxRooms 个房间，超出时丢弃最久没有新消息的房间
+type MemoryHistory struct {
+	size     int
+	maxRooms int
+	rooms    map[string]*list.Element // 值为 *ring
+	lru      *list.List               // 最近写入的房间在前
+}
+
+type ring struct {
+	key  string
+	buf  [][]byte
+	next int // 下一条写入的位置
+	full bool
+}
+
+// NewMemoryHistory 每个房间保留最近 size 条消息，最多保存 maxRooms 个房间，maxRooms 不大于 0 时不限制
+func NewMemoryHistory(size, maxRooms int) *MemoryHistory {
+	return &MemoryHistory{size: size, maxRooms: maxRooms, rooms: make(map[string]*list.Element), lru: list.New()}
+}
+
+func historyKey(tenant, room string) string {
+	return tenant + "\x00" + room
+}
+
+func (m *MemoryHistory) Append(tenant, room string, data []byte) error {
+	key := historyKey(tenant, room)
+	e, ok := m.rooms[key]
+	if ok {
+		m.lru.MoveToFront(e)
+	} else {
+		e = m.lru.PushFront(&ring{key: key, buf: make([][]byte, m.size)})
+		m.rooms[key] = e
+		if m.maxRooms > 0 && m.lru.Len() > m.maxRooms {
+			oldest := m.lru.Back()
+			m.lru.Remove(oldest)
+			delete(m.rooms, oldest.Value.(*ring).key)
+			hubStats.Add("history_evicted_rooms", 1)
+		}
+	}
+	r := e.Value.(*ring)
+	r.buf[r.next] = bytes.Clone(data)
+	r.next = (r.next + 1) % m.size
+	r.full = r.full || r.next == 0
+	return nil
+}
+
+func (m *MemoryHistory) Recent(tenant, room string, limit int) ([][]byte, error) {
+	e, ok := m.rooms[historyKey(tenant, room)]
+	if !ok {
+		return nil, nil
+	}
+	r := e.Value.(*ring)
+	ordered := r.buf[:r.next]
+	if r.full {
+		ordered = append(slices.Clone(r.buf[r.next:]), r.buf[:r.next]...)
+	}
+	if limit > 0 && len(ordered) > limit {
+		ordered = ordered[len(ordered)-limit:]
+	}
+	return slices.Clone(ordered), nil
+}
+
+func (m *MemoryHistory) Close() error {
+	return nil
+}
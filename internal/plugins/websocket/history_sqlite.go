@@ -0,0 +1,92 @@
+package websocket
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	_ "modernc.org/sqlite"
+)
+
+// SQLiteHistory 保存到 SQLite 文件的历史，进程重启后保留；使用纯 Go 的驱动，CGO_ENABLED=0 构建时同样可用
+type SQLiteHistory struct {
+	db   *sql.DB
+	size int
+}
+
+const historySchema = `
+CREATE TABLE IF NOT EXISTS messages (
+	id         INTEGER PRIMARY KEY AUTOINCREMENT,
+	tenant     TEXT    NOT NULL,
+	room       TEXT    NOT NULL,
+	data       BLOB    NOT NULL,
+	created_at INTEGER NOT NULL
+);
+CREATE INDEX IF NOT EXISTS messages_room ON messages (tenant, room, id);
+`
+
+// OpenSQLiteHistory 打开或创建 path，每个房间保留最近 size 条消息
+func OpenSQLiteHistory(path string, size int) (*SQLiteHistory, error) {
+	if dir := filepath.Dir(path); dir != "." {
+		if err := os.MkdirAll(dir, 0o700); err != nil {
+			return nil, fmt.Errorf("创建历史目录失败: %w", err)
+		}
+	}
+	// WAL 模式下写入不阻塞读取，synchronous=NORMAL 减少每条消息的 fsync
+	db, err := sql.Open("sqlite", "file:"+path+"?_pragma=journal_mode(WAL)&_pragma=synchronous(NORMAL)&_pragma=busy_timeout(5000)")
+	if err != nil {
+		return nil, fmt.Errorf("打开历史数据库失败: %w", err)
+	}
+	if _, err := db.Exec(historySchema); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("初始化历史数据库失败: %w", err)
+	}
+	return &SQLiteHistory{db: db, size: size}, nil
+}
+
+func (s *SQLiteHistory) Append(tenant, room string, data []byte) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if _, err := tx.Exec(`INSERT INTO messages (tenant, room, data, created_at) VALUES (?, ?, ?, ?)`,
+		tenant, room, data, time.Now().UnixMilli()); err != nil {
+		return err
+	}
+	// 删除第 size 条之前的消息
+	if _, err := tx.Exec(`DELETE FROM messages WHERE tenant = ? AND room = ? AND id <= (
+		SELECT id FROM messages WHERE tenant = ? AND room = ? ORDER BY id DESC LIMIT 1 OFFSET ?)`,
+		tenant, room, tenant, room, s.size); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (s *SQLiteHistory) Recent(tenant, room string, limit int) ([][]byte, error) {
+	if limit <= 0 || limit > s.size {
+		limit = s.size
+	}
+	rows, err := s.db.Query(`SELECT data FROM (
+		SELECT id, data FROM messages WHERE tenant = ? AND room = ? ORDER BY id DESC LIMIT ?) ORDER BY id`,
+		tenant, room, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var messages [][]byte
+	for rows.Next() {
+		var data []byte
+		if err := rows.Scan(&data); err != nil {
+			return nil, err
+		}
+		messages = append(messages, data)
+	}
+	return messages, rows.Err()
+}
+
+func (s *SQLiteHistory) Close() error {
+	return s.db.Close()
+}
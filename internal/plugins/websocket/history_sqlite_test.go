@@ -0,0 +1,41 @@
+package websocket
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestSQLiteHistoryKeepsRecentMessagesAcrossRestarts(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history", "history.db")
+	h, err := OpenSQLiteHistory(path, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, m := range []string{"a", "b", "c"} {
+		if err := h.Append("t1", "dev", []byte(m)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := h.Append("t2", "dev", []byte("other")); err != nil {
+		t.Fatal(err)
+	}
+	if err := h.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	h, err = OpenSQLiteHistory(path, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer h.Close()
+	got, err := h.Recent("t1", "dev", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 || string(got[0]) != "b" || string(got[1]) != "c" {
+		t.Errorf("Recent = %q, want [b c]", got)
+	}
+	if got, _ := h.Recent("t2", "dev", 1); len(got) != 1 || string(got[0]) != "other" {
+		t.Errorf("other tenant = %q, want [other]", got)
+	}
+}
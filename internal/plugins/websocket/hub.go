@@ -1,41 +1,264 @@
 package websocket
 
+import (
+	"context"
+	"expvar"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
+	"github.com/patrickmn/go-cache"
+
+	"ollama_dev/internal/config"
+	"ollama_dev/internal/metrics"
+	"ollama_dev/internal/ratelimit"
+	"ollama_dev/internal/stats"
+)
+
+// hubStats Hub 计数，发布在 /debug/vars 的 hub 字段
+var (
+	hubStats   = expvar.NewMap("hub")
+	hubClients = new(expvar.Int)
+)
+
+// wsMessages 收发的帧数，由 server.metrics 启用的 /metrics 导出
+var wsMessages = metrics.NewCounter("ollama_dev_ws_messages_total", "serve 的 WebSocket 收发帧数，direction 为 in 或 out", "direction")
+
+func init() {
+	hubStats.Set("clients", hubClients)
+}
+
+// Frame 待广播的消息，只投递给同一租户的连接，发送方加入房间时只在房间内可见
+type Frame struct {
+	Tenant string
+	Data   []byte
+	From   *Client // 发送方，可为 nil
+}
+
 // WebSocket 服务器端管理连接的 Hub
 type Hub struct {
-	Clients    map[*Client]bool
-	Broadcast  chan []byte
-	Register   chan *Client
-	Unregister chan *Client
+	mu          sync.RWMutex // 保护 Clients、members 与 disconnects，Run 之外读取时使用
+	Clients     map[*Client]bool
+	disconnects int64 // 累计断开（含被踢出的慢连接）的连接数
+	Broadcast   chan Frame
+	Register    chan *Client
+	Unregister  chan *Client
+
+	rooms    chan membership
+	members  map[*Client]string // 连接 -> 所在房间，未加入房间的连接不在其中
+	requests *cache.Cache       // 房间成员发出的 request_id -> 房间
+	pending  *cache.Cache       // 转发的请求 租户/request_id -> *pendingRequest
+
+	history     HistoryStore   // 可为 nil，表示不保存消息历史
+	historyOps  chan historyOp // 交给写入 goroutine 的消息与查询，见 startHistory
+	historyDone chan struct{}  // 写入 goroutine 退出时关闭
+	queries     chan roomQuery
+
+	presence      chan presenceEvent
+	typers        map[*Client]*typingState // 正在输入的成员，只在 Run 中读写
+	typingSeq     uint64
+	typingTimeout time.Duration
+	handler       FrameHandler // 可为 nil，表示全部帧照常广播
+	direct        chan Frame   // handler 的响应，只投递给 From
+
+	limiter *ratelimit.Limiter            // 可为 nil，表示不限流
+	limits  func() config.RateLimitConfig // 当前的 server.rate_limit
+	quotas  func() config.QuotaConfig     // 当前的 server.quota，可为 nil 表示不检查配额
+
+	kicks     chan kick         // 管理接口断开指定连接的请求
+	announces chan announcement // 管理接口推送的公告
+
+	stop    chan chan []*Client // Shutdown 的请求，回复被关闭的连接
+	closing bool                // 已关闭，新登记的连接立即关闭；只在 Run 中读写
+	done    chan struct{}       // Shutdown 时关闭，心跳检查随之停止
 }
 
 func NewHub() *Hub {
 	return &Hub{
 		Clients:    make(map[*Client]bool),
-		Broadcast:  make(chan []byte),
+		Broadcast:  make(chan Frame),
 		Register:   make(chan *Client),
 		Unregister: make(chan *Client),
+		rooms:      make(chan membership),
+		members:    make(map[*Client]string),
+		requests:   cache.New(roomRequestTTL, roomRequestTTL),
+		pending:    cache.New(pendingTTL, pendingTTL),
+		queries:    make(chan roomQuery),
+		presence:   make(chan presenceEvent),
+		typers:     make(map[*Client]*typingState),
+		direct:     make(chan Frame),
+		kicks:      make(chan kick),
+		announces:  make(chan announcement),
+		stop:       make(chan chan []*Client),
+		done:       make(chan struct{}),
+
+		typingTimeout: typingTimeout,
+	}
+}
+
+// Shutdown 关闭全部连接：各连接先写完待发送队列，再发送 1001 (going away) 关闭帧；
+// 之后登记的连接立即关闭。等待各连接写完或 ctx 结束，Hub 需已在运行
+func (h *Hub) Shutdown(ctx context.Context) error {
+	reply := make(chan []*Client, 1)
+	select {
+	case h.stop <- reply:
+	case <-ctx.Done():
+		return ctx.Err()
 	}
+	for _, client := range <-reply {
+		if client.flushed == nil {
+			continue
+		}
+		select {
+		case <-client.flushed:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return nil
 }
 
 func (h *Hub) Run() {
+	h.startHistory()
 	for {
 		select {
 		case client := <-h.Register:
+			if h.closing {
+				client.closeCode = websocket.CloseGoingAway
+				close(client.Send)
+				continue
+			}
+			if client.lastSeen.Load() == 0 {
+				client.touch(time.Now())
+			}
+			if client.member.ID == "" {
+				client.member.ID = uuid.NewString()[:8]
+			}
+			client.member.Presence = PresenceOnline
+			h.mu.Lock()
 			h.Clients[client] = true
+			h.mu.Unlock()
+			hubStats.Add("registered", 1)
 		case client := <-h.Unregister:
-			if _, ok := h.Clients[client]; ok {
-				delete(h.Clients, client)
+			h.drop(client, "unregistered")
+		case reply := <-h.stop:
+			if !h.closing {
+				close(h.done)
+			}
+			h.closing = true
+			h.mu.Lock()
+			closed := make([]*Client, 0, len(h.Clients))
+			for client := range h.Clients {
+				client.closeCode = websocket.CloseGoingAway
 				close(client.Send)
+				delete(h.Clients, client)
+				delete(h.members, client)
+				h.disconnects++
+				closed = append(closed, client)
 			}
-		case message := <-h.Broadcast:
+			h.mu.Unlock()
+			hubStats.Add("shutdown_clients", int64(len(closed)))
+			h.stopHistory()
+			reply <- closed
+		case m := <-h.rooms:
+			if _, ok := h.Clients[m.client]; ok {
+				h.setRoom(m.client, m.room, m.name)
+				if m.limit > 0 {
+					h.replay(roomQuery{client: m.client, action: RoomHistory, room: m.room, limit: m.limit})
+				}
+			}
+		case f := <-h.direct:
+			h.deliverDirect(f)
+		case k := <-h.kicks:
+			k.reply <- h.kick(k.id)
+		case a := <-h.announces:
+			a.reply <- h.announce(a)
+		case q := <-h.queries:
+			if _, ok := h.Clients[q.client]; ok {
+				h.query(q)
+			}
+		case e := <-h.presence:
+			if _, ok := h.Clients[e.client]; ok {
+				h.onPresence(e)
+			}
+		case frame := <-h.Broadcast:
+			hubStats.Add("broadcasts", 1)
+			// 成员发出消息即结束输入
+			if h.typers[frame.From] != nil {
+				h.setTyping(frame.From, false, 0)
+			}
+			h.mu.Lock()
+			room, all := h.route(frame)
+			var slow []*Client
 			for client := range h.Clients {
+				if client.Tenant != frame.Tenant {
+					continue
+				}
+				if r := h.members[client]; !all && r != "" && r != room {
+					continue
+				}
 				select {
-				case client.Send <- message:
+				case client.Send <- frame.Data:
+					hubStats.Add("messages_sent", 1)
 				default:
-					close(client.Send)
-					delete(h.Clients, client)
+					slow = append(slow, client)
 				}
 			}
+			h.mu.Unlock()
+			// 历史在锁外交给写入 goroutine，磁盘 I/O 不阻塞 Stats 等读取方
+			h.record(frame, room)
+			for _, client := range slow {
+				h.drop(client, "dropped_clients")
+			}
+		}
+		h.mu.RLock()
+		hubClients.Set(int64(len(h.Clients)))
+		h.mu.RUnlock()
+	}
+}
+
+// drop 在 Run 中调用，断开已登记的连接：清除输入状态、关闭发送队列，所在房间的成员收到它以 offline 离开；
+// reason 为计入 /debug/vars 的 hub 计数名。连接未登记 (已断开) 时返回 false
+func (h *Hub) drop(client *Client, reason string) bool {
+	h.clearTyping(client)
+	h.mu.Lock()
+	if _, ok := h.Clients[client]; !ok {
+		h.mu.Unlock()
+		return false
+	}
+	room := h.members[client]
+	client.member.Presence = PresenceOffline
+	close(client.Send)
+	delete(h.Clients, client)
+	delete(h.members, client)
+	h.disconnects++
+	h.mu.Unlock()
+	hubStats.Add(reason, 1)
+	if room != "" {
+		h.notifyRoom(room, RoomLeave, client, false)
+	}
+	return true
+}
+
+// Stats 返回连接数与发送队列深度
+func (h *Hub) Stats() stats.Connections {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	c := stats.Connections{Total: len(h.Clients), Disconnects: h.disconnects, Tenants: map[string]int{}}
+	for client := range h.Clients {
+		c.Tenants[client.Tenant]++
+		if room := h.members[client]; room != "" {
+			if c.Rooms == nil {
+				c.Rooms = map[string]int{}
+			}
+			c.Rooms[room]++
 		}
+		depth := len(client.Send)
+		c.QueueDepth += depth
+		c.MaxQueueDepth = max(c.MaxQueueDepth, depth)
+		c.QueueCapacity = cap(client.Send)
 	}
+	return c
 }
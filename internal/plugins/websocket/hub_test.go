@@ -0,0 +1,351 @@
+package websocket
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"ollama_dev/internal/auth"
+	"ollama_dev/internal/config"
+)
+
+func TestBroadcastScopedByTenant(t *testing.T) {
+	h := NewHub()
+	go h.Run()
+
+	acme := &Client{Send: make(chan []byte, 1), Tenant: "acme"}
+	other := &Client{Send: make(chan []byte, 1), Tenant: "other"}
+	h.Register <- acme
+	h.Register <- other
+	h.Broadcast <- Frame{Tenant: "acme", Data: []byte("hello")}
+
+	select {
+	case msg := <-acme.Send:
+		if string(msg) != "hello" {
+			t.Errorf("unexpected message %q", msg)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("same-tenant client did not receive the broadcast")
+	}
+	// 其他租户的连接收不到
+	select {
+	case msg := <-other.Send:
+		t.Errorf("cross-tenant delivery: %q", msg)
+	default:
+	}
+
+	if c := h.Stats(); c.Tenants["acme"] != 1 || c.Tenants["other"] != 1 {
+		t.Errorf("unexpected tenant counts: %v", c.Tenants)
+	}
+}
+
+func TestFrameTenant(t *testing.T) {
+	if id, ok := frameTenant([]byte(`{"type":"heartbeat","tenant_id":"acme"}`)); !ok || id != "acme" {
+		t.Errorf("expected acme, got %q %v", id, ok)
+	}
+	for _, f := range []string{`{"type":"heartbeat"}`, `{"tenant_id":""}`, `not json "tenant_id"`} {
+		if _, ok := frameTenant([]byte(f)); ok {
+			t.Errorf("expected no tenant for %s", f)
+		}
+	}
+}
+
+func TestRoomRouting(t *testing.T) {
+	h := NewHub()
+	go h.Run()
+
+	bridge := &Client{Send: make(chan []byte, 8)}
+	alice := &Client{Send: make(chan []byte, 8), member: RoomMember{ID: "a"}}
+	bob := &Client{Send: make(chan []byte, 8), member: RoomMember{ID: "b"}}
+	carol := &Client{Send: make(chan []byte, 8), member: RoomMember{ID: "c"}}
+	for _, c := range []*Client{bridge, alice, bob, carol} {
+		h.Register <- c
+	}
+	h.rooms <- membership{client: alice, room: "dev", name: "Alice"}
+	h.rooms <- membership{client: bob, room: "dev"}
+	h.rooms <- membership{client: carol, room: "ops"}
+
+	// 加入房间后成员收到房间人数
+	expect := func(c *Client, want string) {
+		t.Helper()
+		select {
+		case msg := <-c.Send:
+			if string(msg) != want {
+				t.Errorf("expected %s, got %s", want, msg)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("expected %s, got nothing", want)
+		}
+	}
+	expectNone := func(c *Client) {
+		t.Helper()
+		select {
+		case msg := <-c.Send:
+			t.Errorf("unexpected message %s", msg)
+		case <-time.After(50 * time.Millisecond):
+		}
+	}
+	expect(alice, `{"type":"room","action":"join","data":{"room":"dev","members":1,"member":{"id":"a","name":"Alice","presence":"online"}},"status":"done"}`)
+	expect(alice, `{"type":"room","action":"join","data":{"room":"dev","members":2,"member":{"id":"b","presence":"online"}},"status":"done"}`)
+	expect(bob, `{"type":"room","action":"join","data":{"room":"dev","members":2,"member":{"id":"b","presence":"online"}},"status":"done"}`)
+	expect(carol, `{"type":"room","action":"join","data":{"room":"ops","members":1,"member":{"id":"c","presence":"online"}},"status":"done"}`)
+
+	// 请求只在房间内与桥接客户端可见，响应按 request_id 回到房间
+	req := `{"type":"client_to_server","action":"chat","request_id":"r1"}`
+	h.Broadcast <- Frame{Data: []byte(req), From: alice}
+	expect(alice, req)
+	expect(bob, req)
+	expect(bridge, req)
+	expectNone(carol)
+
+	resp := `{"type":"server_to_client","action":"chat","request_id":"r1","status":"done"}`
+	h.Broadcast <- Frame{Data: []byte(resp), From: bridge}
+	expect(alice, resp)
+	expect(bob, resp)
+	expect(bridge, resp)
+	expectNone(carol)
+
+	// 心跳投递给全部连接
+	hb := `{"type":"heartbeat","action":"ping","request_id":"hb"}`
+	h.Broadcast <- Frame{Data: []byte(hb), From: bridge}
+	for _, c := range []*Client{bridge, alice, bob, carol} {
+		expect(c, hb)
+	}
+
+	if c := h.Stats(); c.Rooms["dev"] != 2 || c.Rooms["ops"] != 1 {
+		t.Errorf("unexpected room counts: %v", c.Rooms)
+	}
+
+	// 成员列表只回复查询方
+	h.queries <- roomQuery{client: bob, action: RoomMembers}
+	expect(bob, `{"type":"room","action":"members","data":{"room":"dev","members":2,"member_list":[{"id":"b","presence":"online"},{"id":"a","name":"Alice","presence":"online"}]},"status":"done"}`)
+	expectNone(alice)
+
+	// 离开后剩余成员收到新的人数与离开的成员，离开者也收到一条
+	h.rooms <- membership{client: bob}
+	expect(alice, `{"type":"room","action":"leave","data":{"room":"dev","members":1,"member":{"id":"b","presence":"online"}},"status":"done"}`)
+	expect(bob, `{"type":"room","action":"leave","data":{"room":"dev","members":1,"member":{"id":"b","presence":"online"}},"status":"done"}`)
+
+	// 断开连接同样通知房间成员
+	h.Unregister <- carol
+	h.rooms <- membership{client: bob, room: "ops"}
+	expect(bob, `{"type":"room","action":"join","data":{"room":"ops","members":1,"member":{"id":"b","presence":"online"}},"status":"done"}`)
+}
+
+func TestSlowMemberLeavesRoom(t *testing.T) {
+	h := NewHub()
+	h.typingTimeout = 50 * time.Millisecond
+	go h.Run()
+
+	alice := &Client{Send: make(chan []byte, 8), member: RoomMember{ID: "a"}}
+	slow := &Client{Send: make(chan []byte, 2), member: RoomMember{ID: "s"}}
+	h.Register <- alice
+	h.Register <- slow
+	h.rooms <- membership{client: alice, room: "dev"}
+	h.rooms <- membership{client: slow, room: "dev"}
+	h.presence <- presenceEvent{client: slow, action: RoomTyping, typing: true}
+	for _, c := range []*Client{alice, alice, alice, slow} { // 加入通知与输入提示
+		<-c.Send
+	}
+
+	// 发送队列已满的成员被断开，房间成员收到它以 offline 离开，之后不再有它的输入提示
+	slow.Send <- []byte("x")
+	slow.Send <- []byte("x")
+	msg := `{"type":"client_to_server","action":"chat","request_id":"r1"}`
+	h.Broadcast <- Frame{Data: []byte(msg), From: alice}
+	for _, want := range []string{msg, `{"type":"room","action":"leave","data":{"room":"dev","members":1,"member":{"id":"s","presence":"offline"}},"status":"done"}`} {
+		select {
+		case got := <-alice.Send:
+			if string(got) != want {
+				t.Errorf("expected %s, got %s", want, got)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("expected %s, got nothing", want)
+		}
+	}
+	select {
+	case got := <-alice.Send:
+		t.Errorf("unexpected message %s", got)
+	case <-time.After(3 * h.typingTimeout):
+	}
+	if total := h.Stats().Total; total != 1 {
+		t.Errorf("expected the slow client to be dropped, %d clients left", total)
+	}
+}
+
+func TestParseRoomFrame(t *testing.T) {
+	f := parseRoomFrame([]byte(`{"type":"room","action":"join","params":{"room":"dev"}}`))
+	if f == nil || f.Action != RoomJoin || f.Params.Room != "dev" {
+		t.Fatalf("unexpected frame %+v", f)
+	}
+	if parseRoomFrame([]byte(`{"type":"client_to_server","params":{"room":"dev"}}`)) != nil {
+		t.Error("non-room frame parsed as room frame")
+	}
+}
+
+func TestHubShutdownSendsCloseFrame(t *testing.T) {
+	h := NewHub()
+	go h.Run()
+	upgrader := &websocket.Upgrader{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		serveWs(h, upgrader, 4, nil, nil, handshake{identity: auth.Identity{Tenant: "acme"}}, w, r, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	}))
+	defer srv.Close()
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	for h.Stats().Total != 1 {
+		time.Sleep(time.Millisecond)
+	}
+	h.Broadcast <- Frame{Tenant: "acme", Data: []byte("pending")}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := h.Shutdown(ctx); err != nil {
+		t.Fatal(err)
+	}
+	// 关闭前先写完队列中的消息，随后收到 1001 关闭帧
+	if _, msg, err := conn.ReadMessage(); err != nil || string(msg) != "pending" {
+		t.Fatalf("expected queued message before close, got %q %v", msg, err)
+	}
+	if _, _, err := conn.ReadMessage(); !websocket.IsCloseError(err, websocket.CloseGoingAway) {
+		t.Errorf("expected going away close frame, got %v", err)
+	}
+	if h.Stats().Total != 0 {
+		t.Errorf("expected no clients after shutdown, got %d", h.Stats().Total)
+	}
+}
+
+func TestRoomHistory(t *testing.T) {
+	for _, backend := range []string{"memory", "sqlite"} {
+		t.Run(backend, func(t *testing.T) {
+			store, err := NewHistoryStore(config.HistoryConfig{Size: 2, Backend: backend, Path: filepath.Join(t.TempDir(), "history.db")})
+			if err != nil {
+				t.Skipf("history backend unavailable: %v", err)
+			}
+			h := NewHub()
+			h.SetHistory(store)
+			go h.Run()
+			logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+			alice := &Client{Hub: h, Send: make(chan []byte, 8), Tenant: "acme", Logger: logger}
+			h.Register <- alice
+			h.rooms <- membership{client: alice, room: "r1"}
+			for _, msg := range []string{`{"n":1}`, `{"n":2}`, `{"n":3}`} {
+				h.Broadcast <- Frame{Tenant: "acme", Data: []byte(msg), From: alice}
+			}
+			h.Broadcast <- Frame{Tenant: "acme", Data: []byte(`{"type":"heartbeat"}`)}
+
+			// 重连后加入房间时取回最近的消息，只保留 size 条，心跳不保存
+			bob := &Client{Hub: h, Send: make(chan []byte, 8), Tenant: "acme", Logger: logger}
+			h.Register <- bob
+			h.rooms <- membership{client: bob, room: "r1", limit: 10}
+			var got RoomFrame
+			for got.Action != RoomHistory {
+				select {
+				case msg := <-bob.Send:
+					if err := json.Unmarshal(msg, &got); err != nil {
+						t.Fatal(err)
+					}
+				case <-time.After(time.Second):
+					t.Fatal("no history frame")
+				}
+			}
+			if len(got.Data.Messages) != 2 || string(got.Data.Messages[0]) != `{"n":2}` || string(got.Data.Messages[1]) != `{"n":3}` {
+				t.Errorf("unexpected history %s", got.Data.Messages)
+			}
+
+			// 其他租户取不到
+			eve := &Client{Hub: h, Send: make(chan []byte, 8), Tenant: "other", Logger: logger}
+			h.Register <- eve
+			h.queries <- roomQuery{client: eve, action: RoomHistory, room: "r1", limit: 10}
+			var leaked RoomFrame
+			_ = json.Unmarshal(<-eve.Send, &leaked)
+			if leaked.Data == nil || len(leaked.Data.Messages) != 0 {
+				t.Errorf("cross-tenant history: %+v", leaked.Data)
+			}
+		})
+	}
+}
+
+func TestMemoryHistoryEvictsOldestRoom(t *testing.T) {
+	m := NewMemoryHistory(2, 2)
+	for _, room := range []string{"r1", "r2", "r1", "r3"} {
+		_ = m.Append("acme", room, []byte(room))
+	}
+	// r2 最久没有新消息，超出房间上限时被丢弃
+	for room, want := range map[string]int{"r1": 2, "r2": 0, "r3": 1} {
+		if got, _ := m.Recent("acme", room, 0); len(got) != want {
+			t.Errorf("%s: expected %d messages, got %q", room, want, got)
+		}
+	}
+	if len(m.rooms) != 2 || m.lru.Len() != 2 {
+		t.Errorf("expected 2 rooms kept, got %d", len(m.rooms))
+	}
+}
+
+// blockingHistory Append 阻塞到 release 关闭，模拟缓慢的磁盘
+type blockingHistory struct {
+	MemoryHistory
+	release chan struct{}
+}
+
+func (b *blockingHistory) Append(tenant, room string, data []byte) error {
+	<-b.release
+	return b.MemoryHistory.Append(tenant, room, data)
+}
+
+func TestSlowHistoryDoesNotBlockBroadcast(t *testing.T) {
+	store := &blockingHistory{MemoryHistory: *NewMemoryHistory(10, 10), release: make(chan struct{})}
+	h := NewHub()
+	h.SetHistory(store)
+	go h.Run()
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	alice := &Client{Hub: h, Send: make(chan []byte, 8), Tenant: "acme", Logger: logger}
+	h.Register <- alice
+
+	// 历史写入阻塞时广播与 Stats 照常进行
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := range 3 {
+			h.Broadcast <- Frame{Tenant: "acme", Data: []byte(fmt.Sprintf(`{"n":%d}`, i)), From: alice}
+		}
+		_ = h.Stats()
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("broadcast blocked on history I/O")
+	}
+	for range 3 {
+		<-alice.Send
+	}
+
+	// 写入完成后可以取回，history 查询排在写入之后
+	close(store.release)
+	h.queries <- roomQuery{client: alice, action: RoomHistory, limit: 10}
+	var got RoomFrame
+	select {
+	case msg := <-alice.Send:
+		_ = json.Unmarshal(msg, &got)
+	case <-time.After(time.Second):
+		t.Fatal("no history frame")
+	}
+	if got.Data == nil || len(got.Data.Messages) != 3 {
+		t.Errorf("expected 3 messages after the writes finish, got %+v", got.Data)
+	}
+}
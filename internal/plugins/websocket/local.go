@@ -0,0 +1,56 @@
+package websocket
+
+import (
+	"context"
+	"time"
+
+	"ollama_dev/internal/usage"
+)
+
+// FrameHandler 在 serve 中直接处理连接发出的请求帧 (例如 server.websocket.chat 启用的对话)，
+// 处理的帧不再广播，响应只发给发出请求的连接
+type FrameHandler interface {
+	// Accept 判断是否处理该帧，在读取循环中调用，应尽快返回
+	Accept(frame []byte) bool
+	// Serve 在独立 goroutine 中处理帧，ctx 在连接断开时结束；reply 发送一帧响应，连接已断开时返回错误
+	Serve(ctx context.Context, tenant string, frame []byte, reply func([]byte) error)
+}
+
+// SetFrameHandler 启用本地处理，须在 Run 之前调用
+func (h *Hub) SetFrameHandler(fh FrameHandler) {
+	h.handler = fh
+}
+
+// serveLocal 交给 FrameHandler 处理，响应经 Hub 投递给本连接，完成的响应按租户记录用量
+func (c *Client) serveLocal(ctx context.Context, frame []byte) {
+	c.Hub.handler.Serve(ctx, c.Tenant, frame, func(data []byte) error {
+		select {
+		case c.Hub.direct <- Frame{Tenant: c.Tenant, Data: data, From: c}:
+		case <-ctx.Done():
+			return context.Cause(ctx)
+		}
+		if t, ok := usage.ParseFrame(data); ok {
+			if err := c.Usage.Record(c.caller(), t, time.Now()); err != nil {
+				c.Logger.Warn("记录用量失败", "error", err)
+			}
+		}
+		return nil
+	})
+}
+
+// deliverDirect 在 Run 中调用，将响应放入发出请求的连接的队列，队列已满时与广播一样断开慢连接
+func (h *Hub) deliverDirect(f Frame) {
+	client := f.From
+	h.mu.RLock()
+	_, ok := h.Clients[client]
+	h.mu.RUnlock()
+	if !ok {
+		return
+	}
+	select {
+	case client.Send <- f.Data:
+		hubStats.Add("local_replies", 1)
+	default:
+		h.drop(client, "dropped_clients")
+	}
+}
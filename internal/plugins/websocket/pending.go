@@ -0,0 +1,86 @@
+package websocket
+
+import (
+	"bytes"
+	"encoding/json"
+	"time"
+
+	"github.com/patrickmn/go-cache"
+
+	"ollama_dev/internal/apperr"
+	"ollama_dev/internal/usage"
+)
+
+// pendingTTL 转发的请求迟迟没有 done 或 error 响应时，丢弃其登记的时长
+const pendingTTL = 10 * time.Minute
+
+// pendingRequest 经 Hub 转发、等待其他连接 (bridge) 回复的请求
+type pendingRequest struct {
+	from   *Client      // 发出请求的连接
+	caller usage.Caller // 回复中的用量记在该调用方名下
+}
+
+func pendingKey(tenant, requestID string) string {
+	return tenant + "/" + requestID
+}
+
+// forward 登记连接发出的请求，并把其他连接回复的用量记在发出请求的调用方名下：
+// done、error 帧或携带用量的分片 (拆分的 done 帧) 结束登记并归还 limit 占用的并发名额；
+// 未登记的请求与请求方自己发出的回复按发送方记录用量，也不归还名额。
+// request_id 与本租户进行中的请求重复时回复 code 为 invalid_params 的 ErrorFrame 并返回 false，帧应丢弃，
+// 否则同一租户的其他连接可以借重复的 request_id 接管回复与用量
+func (c *Client) forward(message []byte) bool {
+	if !bytes.Contains(message, []byte(`"type"`)) {
+		return true
+	}
+	var head frameHead
+	if json.Unmarshal(message, &head) != nil {
+		return true
+	}
+	if head.isRequest() {
+		if head.RequestID == "" {
+			return true
+		}
+		// Add 在登记已存在时失败，并发发出的相同 request_id 只有一个成功
+		if c.Hub.pending.Add(pendingKey(c.Tenant, head.RequestID), &pendingRequest{from: c, caller: c.caller()}, cache.DefaultExpiration) != nil {
+			c.replyError(head, apperr.New(apperr.Validation, apperr.CodeInvalidParams, "request_id 与进行中的请求重复: "+head.RequestID), 0)
+			return false
+		}
+		return true
+	}
+	if head.Type != "client_to_server" {
+		return true
+	}
+	t, hasUsage := usage.ParseFrame(message)
+	caller := c.caller()
+	if p, ok := c.reply(head, hasUsage); ok {
+		caller = p.caller
+	}
+	if hasUsage {
+		if err := c.Usage.Record(caller, t, time.Now()); err != nil {
+			c.Logger.Warn("记录用量失败", "error", err)
+		}
+	}
+	return true
+}
+
+// reply 返回回复帧对应的登记，帧结束请求时删除登记；请求方自己发出的帧不是回复，返回 false
+func (c *Client) reply(head frameHead, hasUsage bool) (*pendingRequest, bool) {
+	if head.RequestID == "" {
+		return nil, false
+	}
+	key := pendingKey(c.Tenant, head.RequestID)
+	v, ok := c.Hub.pending.Get(key)
+	if !ok {
+		return nil, false
+	}
+	p := v.(*pendingRequest)
+	if p.from == c {
+		return nil, false
+	}
+	if head.Status == "done" || head.Status == "error" || hasUsage {
+		c.Hub.pending.Delete(key)
+		c.Hub.limiter.Done(key)
+	}
+	return p, true
+}
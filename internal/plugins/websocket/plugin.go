@@ -0,0 +1,62 @@
+package websocket
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"ollama_dev/internal/capture"
+	"ollama_dev/internal/config"
+	"ollama_dev/internal/middleware"
+	"ollama_dev/internal/openapi"
+	"ollama_dev/internal/usage"
+)
+
+// Name 插件名，即 server.plugins 中的键
+const Name = "websocket"
+
+// Plugin 以 plugins.Plugin 的形式挂载 InitWebSocketPlugin，Shutdown 时关闭 Hub 上的连接
+type Plugin struct {
+	hub   *Hub
+	capt  *capture.Capture
+	usage *usage.Store
+}
+
+// NewPlugin h 为 nil 时创建新的 Hub；capt 与 usg 可为 nil，含义同 InitWebSocketPlugin
+func NewPlugin(h *Hub, capt *capture.Capture, usg *usage.Store) *Plugin {
+	if h == nil {
+		h = NewHub()
+	}
+	return &Plugin{hub: h, capt: capt, usage: usg}
+}
+
+// Hub 插件使用的 Hub，管理接口据此列出、断开连接
+func (p *Plugin) Hub() *Hub {
+	return p.hub
+}
+
+func (p *Plugin) Name() string {
+	return Name
+}
+
+func (p *Plugin) Init(r *gin.RouterGroup, logger *slog.Logger, store *config.Store) error {
+	InitWebSocketPlugin(r, store, p.hub, p.capt, p.usage, logger)
+	return nil
+}
+
+// Shutdown 写完各连接的发送队列后发送关闭帧
+func (p *Plugin) Shutdown(ctx context.Context) error {
+	return p.hub.Shutdown(ctx)
+}
+
+func (p *Plugin) Describe(spec *openapi.Registry, path string) {
+	spec.Add(http.MethodGet, path, openapi.Operation{
+		ID: "connectWebSocket", Summary: "建立 WebSocket 连接", Tag: "websocket", Security: openapi.SecurityTenant,
+		Responses: []openapi.Response{
+			{Status: http.StatusSwitchingProtocols, Description: "切换到 WebSocket 协议"},
+			{Status: http.StatusUnauthorized, Description: "Token 无效", Body: middleware.ErrorResponse{}},
+		},
+	})
+}
@@ -0,0 +1,122 @@
+package websocket
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// 房间控制帧的 action：在线状态与输入提示
+const (
+	RoomPresence = "presence" // 设置本连接的在线状态 params.presence，Hub 向所在房间的成员与发送方回复
+	RoomTyping   = "typing"   // 输入提示，params.typing 省略时为 true；Hub 只向同一房间的其他成员转发状态变化，不保存到历史
+)
+
+// 成员的在线状态，连接登记后为 online
+const (
+	PresenceOnline  = "online"
+	PresenceAway    = "away"
+	PresenceOffline = "offline" // 只由 Hub 在连接断开时设置，随 leave 帧通知
+)
+
+// typingTimeout 成员在该时长内没有再次发送 typing 时，Hub 代为通知输入结束
+const typingTimeout = 5 * time.Second
+
+// presenceEvent 连接设置在线状态 (presence) 或输入提示 (typing)；seq 非 0 时为输入提示超时，由计时器发出
+type presenceEvent struct {
+	client   *Client
+	action   string
+	presence string
+	typing   bool
+	seq      uint64
+}
+
+// typingState 正在输入的成员：room 为开始输入时所在的房间，seq 区分每次续期的计时器
+type typingState struct {
+	room  string
+	seq   uint64
+	timer *time.Timer
+}
+
+// validPresence 连接可以设置的在线状态，offline 只由 Hub 设置
+func validPresence(p string) bool {
+	return p == PresenceOnline || p == PresenceAway
+}
+
+// onPresence 在 Run 中调用
+func (h *Hub) onPresence(e presenceEvent) {
+	if e.action == RoomTyping {
+		h.setTyping(e.client, e.typing, e.seq)
+		return
+	}
+	h.mu.Lock()
+	e.client.member.Presence = e.presence
+	room := h.members[e.client]
+	h.mu.Unlock()
+	// 未加入房间时只回复发送方
+	h.notifyRoom(room, RoomPresence, e.client, room == "")
+}
+
+// setTyping 在 Run 中调用，只转发输入状态的变化：仍在输入时再次收到 typing 只延长超时，
+// 超时、发送消息、离开房间或断开后视为输入结束
+func (h *Hub) setTyping(c *Client, typing bool, seq uint64) {
+	state := h.typers[c]
+	if seq != 0 && (state == nil || state.seq != seq) {
+		// 计时器触发前成员已续期或已结束输入
+		return
+	}
+	if !typing {
+		if state != nil {
+			h.clearTyping(c)
+			h.notifyTyping(state.room, c, false)
+		}
+		return
+	}
+	room := h.members[c]
+	if room == "" {
+		return
+	}
+	if state == nil {
+		state = &typingState{room: room}
+		h.typers[c] = state
+		h.notifyTyping(room, c, true)
+	} else {
+		state.timer.Stop()
+	}
+	h.typingSeq++
+	state.seq = h.typingSeq
+	expire := presenceEvent{client: c, action: RoomTyping, seq: state.seq}
+	state.timer = time.AfterFunc(h.typingTimeout, func() {
+		select {
+		case h.presence <- expire:
+		case <-h.done:
+		}
+	})
+}
+
+// clearTyping 在 Run 中调用，丢弃连接的输入状态而不通知
+func (h *Hub) clearTyping(c *Client) {
+	if state := h.typers[c]; state != nil {
+		state.timer.Stop()
+		delete(h.typers, c)
+	}
+}
+
+// notifyTyping 向房间中除 who 以外的成员发送输入提示
+func (h *Hub) notifyTyping(room string, who *Client, typing bool) {
+	h.mu.RLock()
+	targets := make([]*Client, 0, 4)
+	for client, r := range h.members {
+		if r == room && client != who && client.Tenant == who.Tenant {
+			targets = append(targets, client)
+		}
+	}
+	member := who.member
+	h.mu.RUnlock()
+	data, _ := json.Marshal(RoomFrame{Type: TypeRoom, Action: RoomTyping, Status: "done", Data: &RoomInfo{Room: room, Member: &member, Typing: &typing}})
+	for _, client := range targets {
+		select {
+		case client.Send <- data:
+		default:
+		}
+	}
+}
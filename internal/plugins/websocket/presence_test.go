@@ -0,0 +1,96 @@
+package websocket
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPresenceAndTyping(t *testing.T) {
+	h := NewHub()
+	h.typingTimeout = 200 * time.Millisecond
+	go h.Run()
+
+	alice := &Client{Send: make(chan []byte, 8), member: RoomMember{ID: "a"}}
+	bob := &Client{Send: make(chan []byte, 8), member: RoomMember{ID: "b"}}
+	carol := &Client{Send: make(chan []byte, 8), member: RoomMember{ID: "c"}}
+	for _, c := range []*Client{alice, bob, carol} {
+		h.Register <- c
+	}
+	h.rooms <- membership{client: alice, room: "dev", name: "Alice"}
+	h.rooms <- membership{client: bob, room: "dev"}
+	h.rooms <- membership{client: carol, room: "ops"}
+
+	expect := func(c *Client, want string) {
+		t.Helper()
+		select {
+		case msg := <-c.Send:
+			if string(msg) != want {
+				t.Errorf("expected %s, got %s", want, msg)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("expected %s, got nothing", want)
+		}
+	}
+	expectNone := func(c *Client) {
+		t.Helper()
+		select {
+		case msg := <-c.Send:
+			t.Errorf("unexpected message %s", msg)
+		case <-time.After(50 * time.Millisecond):
+		}
+	}
+	// 丢弃加入房间的通知
+	for _, c := range []*Client{alice, alice, bob, carol} {
+		<-c.Send
+	}
+
+	// 在线状态通知房间成员与发送方，成员列表随之更新
+	away := `{"type":"room","action":"presence","data":{"room":"dev","members":2,"member":{"id":"a","name":"Alice","presence":"away"}},"status":"done"}`
+	h.presence <- presenceEvent{client: alice, action: RoomPresence, presence: PresenceAway}
+	expect(alice, away)
+	expect(bob, away)
+	expectNone(carol)
+	h.queries <- roomQuery{client: bob, action: RoomMembers}
+	expect(bob, `{"type":"room","action":"members","data":{"room":"dev","members":2,"member_list":[{"id":"b","presence":"online"},{"id":"a","name":"Alice","presence":"away"}]},"status":"done"}`)
+
+	// 输入提示只转发给其他成员，仍在输入时不重复转发
+	typing := `{"type":"room","action":"typing","data":{"room":"dev","member":{"id":"a","name":"Alice","presence":"away"},"typing":true},"status":"done"}`
+	stopped := `{"type":"room","action":"typing","data":{"room":"dev","member":{"id":"a","name":"Alice","presence":"away"},"typing":false},"status":"done"}`
+	h.presence <- presenceEvent{client: alice, action: RoomTyping, typing: true}
+	expect(bob, typing)
+	h.presence <- presenceEvent{client: alice, action: RoomTyping, typing: true}
+	expectNone(bob)
+	expectNone(alice)
+	expectNone(carol)
+
+	// 发出消息即结束输入
+	msg := `{"type":"client_to_server","action":"chat","request_id":"r1"}`
+	h.Broadcast <- Frame{Data: []byte(msg), From: alice}
+	expect(bob, stopped)
+	expect(bob, msg)
+	expect(alice, msg)
+
+	// 超时未续期时由 Hub 通知输入结束
+	h.presence <- presenceEvent{client: alice, action: RoomTyping, typing: true}
+	expect(bob, typing)
+	expect(bob, stopped)
+
+	// 未加入房间时输入提示被忽略，在线状态只回复发送方
+	outsider := &Client{Send: make(chan []byte, 8), member: RoomMember{ID: "o"}}
+	h.Register <- outsider
+	h.presence <- presenceEvent{client: outsider, action: RoomTyping, typing: true}
+	h.presence <- presenceEvent{client: outsider, action: RoomPresence, presence: PresenceAway}
+	expect(outsider, `{"type":"room","action":"presence","data":{"room":"","member":{"id":"o","presence":"away"}},"status":"done"}`)
+
+	// 断开连接时成员以 offline 离开
+	h.Unregister <- bob
+	expect(alice, `{"type":"room","action":"leave","data":{"room":"dev","members":1,"member":{"id":"b","presence":"offline"}},"status":"done"}`)
+}
+
+func TestValidPresence(t *testing.T) {
+	for p, want := range map[string]bool{PresenceOnline: true, PresenceAway: true, PresenceOffline: false, "": false, "busy": false} {
+		if got := validPresence(p); got != want {
+			t.Errorf("validPresence(%q) = %v, want %v", p, got, want)
+		}
+	}
+}
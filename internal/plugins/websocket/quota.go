@@ -0,0 +1,38 @@
+package websocket
+
+import (
+	"bytes"
+	"encoding/json"
+	"time"
+
+	"ollama_dev/internal/apperr"
+	"ollama_dev/internal/usage"
+)
+
+// caller 连接的用量归属
+func (c *Client) caller() usage.Caller {
+	return usage.Caller{Tenant: c.Tenant, Subject: c.Identity.Subject}
+}
+
+// checkQuota 调用方当天的用量已达 server.quota 的上限时，对 chat 请求帧回复 code 为 quota_exceeded 的 ErrorFrame 并返回 false，帧应丢弃；
+// 未统计用量或未配置配额时总是返回 true
+func (c *Client) checkQuota(message []byte) bool {
+	if c.Usage == nil || c.Hub.quotas == nil || !bytes.Contains(message, []byte(`"chat"`)) {
+		return true
+	}
+	var head frameHead
+	if json.Unmarshal(message, &head) != nil || !head.isRequest() || head.Action != "chat" {
+		return true
+	}
+	wait, err := c.Usage.Enforce(c.caller(), c.Hub.quotas().For(c.Tenant), time.Now())
+	if err == nil {
+		return true
+	}
+	if apperr.CodeOf(err) == apperr.CodeQuotaExceeded {
+		hubStats.Add("quota_exceeded", 1)
+	} else {
+		c.Logger.Warn("检查用量配额失败", "error", err)
+	}
+	c.replyError(head, err, wait)
+	return false
+}
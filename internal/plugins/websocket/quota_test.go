@@ -0,0 +1,151 @@
+package websocket
+
+import (
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"ollama_dev/internal/apperr"
+	"ollama_dev/internal/auth"
+	"ollama_dev/internal/config"
+	"ollama_dev/internal/usage"
+)
+
+func TestQuotaRejectsChat(t *testing.T) {
+	store, err := usage.Open(filepath.Join(t.TempDir(), "usage.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	h := NewHub()
+	h.quotas = func() config.QuotaConfig {
+		return config.QuotaConfig{DailyRequests: 100, Tenants: map[string]config.QuotaLimit{"acme": {DailyRequests: 1}}}
+	}
+	go h.Run()
+	id := auth.Identity{Tenant: "acme", Subject: "alice"}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		serveWs(h, &websocket.Upgrader{}, 16, nil, store, handshake{identity: id}, w, r, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	}))
+	defer srv.Close()
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	send := func(frame string) {
+		t.Helper()
+		if err := conn.WriteMessage(websocket.TextMessage, []byte(frame)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	read := func() ErrorFrame {
+		t.Helper()
+		_ = conn.SetReadDeadline(time.Now().Add(time.Second))
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var f ErrorFrame
+		if err := json.Unmarshal(data, &f); err != nil {
+			t.Fatal(err)
+		}
+		return f
+	}
+
+	send(`{"type":"server_to_client","action":"chat","request_id":"r1"}`)
+	if f := read(); f.RequestID != "r1" || f.Status != "" {
+		t.Fatalf("expected echoed request, got %+v", f)
+	}
+	// done 帧的用量记在连接的调用方名下
+	send(`{"type":"client_to_server","action":"chat","request_id":"r1","status":"done","usage":{"model":"llama3","prompt_tokens":3,"completion_tokens":4,"duration_ms":50}}`)
+	read()
+	if sum, err := store.Daily(usage.Caller{Tenant: "acme", Subject: "alice"}, time.Now()); err != nil || sum.Requests != 1 || sum.DurationMs != 50 {
+		t.Fatalf("unexpected usage %+v %v", sum, err)
+	}
+
+	// 租户的上限覆盖默认值，用完后 chat 请求被拒绝，其他动作不受影响
+	send(`{"type":"server_to_client","action":"chat","request_id":"r2"}`)
+	f := read()
+	if f.RequestID != "r2" || f.Status != "error" || f.Data.Code != apperr.CodeQuotaExceeded || f.RetryAfter < 1 {
+		t.Fatalf("expected quota_exceeded frame, got %+v", f)
+	}
+	send(`{"type":"server_to_client","action":"list_model","request_id":"r3"}`)
+	if f := read(); f.RequestID != "r3" || f.Status != "" {
+		t.Fatalf("expected echoed request, got %+v", f)
+	}
+}
+
+func TestQuotaCountsBridgedUsageForRequester(t *testing.T) {
+	store, err := usage.Open(filepath.Join(t.TempDir(), "usage.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	h := NewHub()
+	h.quotas = func() config.QuotaConfig { return config.QuotaConfig{DailyTokens: 5} }
+	go h.Run()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := auth.Identity{Tenant: "acme", Subject: r.URL.Query().Get("sub")}
+		serveWs(h, &websocket.Upgrader{}, 16, nil, store, handshake{identity: id}, w, r, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	}))
+	defer srv.Close()
+	dial := func(sub string) *websocket.Conn {
+		t.Helper()
+		conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http")+"?sub="+sub, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { conn.Close() })
+		return conn
+	}
+	read := func(conn *websocket.Conn) ErrorFrame {
+		t.Helper()
+		_ = conn.SetReadDeadline(time.Now().Add(time.Second))
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var f ErrorFrame
+		if err := json.Unmarshal(data, &f); err != nil {
+			t.Fatal(err)
+		}
+		return f
+	}
+	alice, bridge := dial("alice"), dial("bridge")
+	for h.Stats().Total != 2 {
+		time.Sleep(time.Millisecond)
+	}
+
+	_ = alice.WriteMessage(websocket.TextMessage, []byte(`{"type":"server_to_client","action":"chat","request_id":"r1"}`))
+	for _, conn := range []*websocket.Conn{alice, bridge} {
+		if f := read(conn); f.RequestID != "r1" || f.Status != "" {
+			t.Fatalf("expected forwarded request, got %+v", f)
+		}
+	}
+	_ = bridge.WriteMessage(websocket.TextMessage, []byte(`{"type":"client_to_server","action":"chat","request_id":"r1","status":"done","usage":{"model":"llama3","prompt_tokens":3,"completion_tokens":4}}`))
+	for _, conn := range []*websocket.Conn{alice, bridge} {
+		if f := read(conn); f.RequestID != "r1" || f.Status != "done" {
+			t.Fatalf("expected bridged reply, got %+v", f)
+		}
+	}
+	if sum, err := store.Daily(usage.Caller{Tenant: "acme", Subject: "alice"}, time.Now()); err != nil || sum.PromptTokens+sum.CompletionTokens != 7 {
+		t.Fatalf("usage must be recorded for the requester: %+v %v", sum, err)
+	}
+	if sum, _ := store.Daily(usage.Caller{Tenant: "acme", Subject: "bridge"}, time.Now()); sum.Requests != 0 {
+		t.Fatalf("usage must not be recorded for the bridge: %+v", sum)
+	}
+
+	_ = alice.WriteMessage(websocket.TextMessage, []byte(`{"type":"server_to_client","action":"chat","request_id":"r2"}`))
+	if f := read(alice); f.RequestID != "r2" || f.Data.Code != apperr.CodeQuotaExceeded {
+		t.Fatalf("expected quota_exceeded frame, got %+v", f)
+	}
+}
@@ -0,0 +1,64 @@
+package websocket
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"ollama_dev/internal/apperr"
+	"ollama_dev/internal/config"
+	"ollama_dev/internal/ratelimit"
+)
+
+// rateHoldTTL 经 bridge 处理的请求迟迟没有 done 或 error 响应时，归还其并发名额的时长
+const rateHoldTTL = 10 * time.Minute
+
+// limit 对连接发出的请求帧 (server_to_client，cancel 除外) 限流，超出时回复 code 为 rate_limited 的 ErrorFrame 并返回 false，帧应丢弃；
+// local 为 true 时帧由 FrameHandler 处理，处理完成后须调用 release 归还并发名额，
+// 否则名额在其他连接 (bridge) 回复 done 或 error 响应 (client_to_server) 时由 forward 归还，
+// 被拒绝的请求同时撤销 forward 的登记
+func (c *Client) limit(message []byte, local bool) (release func(), ok bool) {
+	release = func() {}
+	h := c.Hub
+	if h.limiter == nil || !bytes.Contains(message, []byte(`"type"`)) {
+		return release, true
+	}
+	var head frameHead
+	if json.Unmarshal(message, &head) != nil || !head.isRequest() {
+		return release, true
+	}
+	hold := pendingKey(c.Tenant, head.RequestID)
+	forwarded := !local && head.RequestID != ""
+
+	rule := h.limits().WebSocket
+	if wait, ok := h.limiter.Allow(rule, c.rateKey); !ok {
+		if forwarded {
+			h.pending.Delete(hold)
+		}
+		c.rejectRate(head, wait, fmt.Sprintf("请求过于频繁，每秒最多 %g 个", rule.Rate))
+		return nil, false
+	}
+	if !forwarded {
+		if release, ok = h.limiter.Acquire(rule, c.rateKey); ok {
+			return release, true
+		}
+	} else if h.limiter.Hold(rule, c.rateKey, hold) {
+		return release, true
+	} else {
+		h.pending.Delete(hold)
+	}
+	c.rejectRate(head, time.Second, fmt.Sprintf("同时进行的生成已达上限 (%d)", rule.Concurrency))
+	return nil, false
+}
+
+func (c *Client) rejectRate(head frameHead, wait time.Duration, msg string) {
+	hubStats.Add("rate_limited", 1)
+	c.replyError(head, apperr.New(apperr.Backend, apperr.CodeRateLimited, msg+"，稍后重试"), wait)
+}
+
+// enableRateLimit 按 limits 返回的 server.rate_limit 对请求帧限流，须在 Run 之前调用
+func (h *Hub) enableRateLimit(limits func() config.RateLimitConfig) {
+	h.limiter = ratelimit.New(rateHoldTTL)
+	h.limits = limits
+}
@@ -0,0 +1,171 @@
+package websocket
+
+import (
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"ollama_dev/internal/apperr"
+	"ollama_dev/internal/auth"
+	"ollama_dev/internal/config"
+	"ollama_dev/internal/usage"
+)
+
+func TestErrorFrame(t *testing.T) {
+	h := NewHub()
+	rule := config.RateLimitRule{Rate: 1, Burst: 4, Concurrency: 1}
+	h.enableRateLimit(func() config.RateLimitConfig { return config.RateLimitConfig{Key: "ip", WebSocket: rule} })
+	go h.Run()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		serveWs(h, &websocket.Upgrader{}, 16, nil, nil, handshake{identity: auth.Identity{Tenant: "acme"}, rateKey: "ip:" + r.URL.Query().Get("ip")}, w, r, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	}))
+	defer srv.Close()
+	dial := func(ip string) *websocket.Conn {
+		t.Helper()
+		conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http")+"?ip="+ip, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { conn.Close() })
+		return conn
+	}
+	conn, bridge := dial("127.0.0.1"), dial("127.0.0.2")
+	for h.Stats().Total != 2 {
+		time.Sleep(time.Millisecond)
+	}
+
+	send := func(conn *websocket.Conn, frame string) {
+		t.Helper()
+		if err := conn.WriteMessage(websocket.TextMessage, []byte(frame)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	read := func(conn *websocket.Conn) ErrorFrame {
+		t.Helper()
+		_ = conn.SetReadDeadline(time.Now().Add(time.Second))
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var f ErrorFrame
+		if err := json.Unmarshal(data, &f); err != nil {
+			t.Fatal(err)
+		}
+		return f
+	}
+	// 未加入房间时帧广播给同租户的全部连接，包括发送方
+	broadcast := func(from *websocket.Conn, frame string) {
+		t.Helper()
+		send(from, frame)
+		read(conn)
+		read(bridge)
+	}
+
+	broadcast(conn, `{"type":"server_to_client","action":"chat","request_id":"r1"}`)
+	// r1 未完成时超出并发上限
+	send(conn, `{"type":"server_to_client","action":"chat","request_id":"r2"}`)
+	f := read(conn)
+	if f.RequestID != "r2" || f.Status != "error" || f.Data.Code != apperr.CodeRateLimited || f.RetryAfter != 1 {
+		t.Fatalf("expected rate_limited frame, got %+v", f)
+	}
+	// 请求方自己发出的 done 帧不归还名额
+	broadcast(conn, `{"type":"client_to_server","action":"chat","request_id":"r1","status":"done"}`)
+	send(conn, `{"type":"server_to_client","action":"chat","request_id":"r3"}`)
+	if f := read(conn); f.RequestID != "r3" || f.Data.Code != apperr.CodeRateLimited {
+		t.Fatalf("expected rate_limited frame after a self-sent done, got %+v", f)
+	}
+	// 重复的 request_id 被拒绝，不能绕过并发上限
+	send(conn, `{"type":"server_to_client","action":"chat","request_id":"r1"}`)
+	if f := read(conn); f.RequestID != "r1" || f.Status != "error" || f.Data.Code != apperr.CodeInvalidParams {
+		t.Fatalf("expected invalid_params frame for a duplicate id, got %+v", f)
+	}
+	// bridge 回复的 done 响应归还名额
+	broadcast(bridge, `{"type":"client_to_server","action":"chat","request_id":"r1","status":"done"}`)
+	broadcast(conn, `{"type":"server_to_client","action":"chat","request_id":"r4"}`)
+	broadcast(bridge, `{"type":"client_to_server","action":"chat","request_id":"r4","status":"done"}`)
+	// 超出并发上限的请求同样消耗令牌，4 个令牌已用完
+	send(conn, `{"type":"server_to_client","action":"chat","request_id":"r5"}`)
+	if f := read(conn); f.RequestID != "r5" || f.Data.Code != apperr.CodeRateLimited || f.RetryAfter != 1 {
+		t.Fatalf("expected rate_limited frame, got %+v", f)
+	}
+}
+
+func TestDuplicateRequestIDWithoutRateLimit(t *testing.T) {
+	store, err := usage.Open(filepath.Join(t.TempDir(), "usage.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	h := NewHub()
+	go h.Run()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := auth.Identity{Tenant: "acme", Subject: r.URL.Query().Get("sub")}
+		serveWs(h, &websocket.Upgrader{}, 16, nil, store, handshake{identity: id}, w, r, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	}))
+	defer srv.Close()
+	dial := func(sub string) *websocket.Conn {
+		t.Helper()
+		conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http")+"?sub="+sub, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { conn.Close() })
+		return conn
+	}
+	read := func(conn *websocket.Conn) ErrorFrame {
+		t.Helper()
+		_ = conn.SetReadDeadline(time.Now().Add(time.Second))
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var f ErrorFrame
+		if err := json.Unmarshal(data, &f); err != nil {
+			t.Fatal(err)
+		}
+		return f
+	}
+	alice, mallory, bridge := dial("alice"), dial("mallory"), dial("bridge")
+	for h.Stats().Total != 3 {
+		time.Sleep(time.Millisecond)
+	}
+
+	_ = alice.WriteMessage(websocket.TextMessage, []byte(`{"type":"server_to_client","action":"chat","request_id":"r1"}`))
+	for _, conn := range []*websocket.Conn{alice, mallory, bridge} {
+		if f := read(conn); f.RequestID != "r1" || f.Status != "" {
+			t.Fatalf("expected forwarded request, got %+v", f)
+		}
+	}
+	// 未启用限流时同样拒绝同租户其他连接重复的 request_id，帧不转发
+	_ = mallory.WriteMessage(websocket.TextMessage, []byte(`{"type":"server_to_client","action":"chat","request_id":"r1"}`))
+	if f := read(mallory); f.RequestID != "r1" || f.Status != "error" || f.Data.Code != apperr.CodeInvalidParams {
+		t.Fatalf("expected invalid_params frame for a duplicate id, got %+v", f)
+	}
+	_ = bridge.WriteMessage(websocket.TextMessage, []byte(`{"type":"client_to_server","action":"chat","request_id":"r1","status":"done","usage":{"model":"llama3","prompt_tokens":3,"completion_tokens":4}}`))
+	for _, conn := range []*websocket.Conn{alice, mallory, bridge} {
+		if f := read(conn); f.RequestID != "r1" || f.Status != "done" {
+			t.Fatalf("expected bridged reply, got %+v", f)
+		}
+	}
+	// 用量仍记在最初发出请求的连接名下
+	if sum, err := store.Daily(usage.Caller{Tenant: "acme", Subject: "alice"}, time.Now()); err != nil || sum.Requests != 1 {
+		t.Fatalf("usage must be recorded for the original requester: %+v %v", sum, err)
+	}
+	if sum, _ := store.Daily(usage.Caller{Tenant: "acme", Subject: "mallory"}, time.Now()); sum.Requests != 0 {
+		t.Fatalf("usage must not be recorded for the duplicate sender: %+v", sum)
+	}
+
+	// 请求结束后 request_id 可以再次使用
+	_ = mallory.WriteMessage(websocket.TextMessage, []byte(`{"type":"server_to_client","action":"chat","request_id":"r1"}`))
+	if f := read(mallory); f.RequestID != "r1" || f.Status != "" {
+		t.Fatalf("expected the finished id to be reusable, got %+v", f)
+	}
+}
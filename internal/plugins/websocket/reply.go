@@ -0,0 +1,51 @@
+package websocket
+
+import (
+	"encoding/json"
+	"time"
+
+	"ollama_dev/internal/apperr"
+	"ollama_dev/internal/middleware"
+)
+
+// protocolVersion 回复帧的协议版本，与 bridge.ProtocolVersion 相同 (bridge 依赖本包，不能反向引用)
+const protocolVersion = 2
+
+// ErrorFrame Hub 拒绝请求帧时只回复发送方的错误帧，与 bridge 的错误响应格式相同；
+// 超出限流 (data.code 为 rate_limited) 时 retry_after 为建议等待的秒数
+type ErrorFrame struct {
+	V          int         `json:"v"`
+	Type       string      `json:"type"`
+	Action     string      `json:"action"`
+	RequestID  string      `json:"request_id,omitempty"`
+	Data       apperr.Data `json:"data"`
+	Status     string      `json:"status"`
+	RetryAfter int         `json:"retry_after,omitempty"`
+}
+
+// frameHead Hub 检查请求帧时关心的帧头
+type frameHead struct {
+	Type      string          `json:"type"`
+	Action    string          `json:"action"`
+	RequestID string          `json:"request_id"`
+	Status    string          `json:"status"`
+	Sealed    json.RawMessage `json:"sealed"`
+}
+
+// isRequest 是否为发往 bridge 的请求帧，取消请求的 cancel 帧除外
+func (h frameHead) isRequest() bool {
+	return h.Type == "server_to_client" && h.Action != "cancel"
+}
+
+// replyError 经 Hub 向本连接回复 ErrorFrame，wait 大于 0 时附带 retry_after
+func (c *Client) replyError(head frameHead, err error, wait time.Duration) {
+	f := ErrorFrame{
+		V: protocolVersion, Type: "client_to_server", Action: head.Action, RequestID: head.RequestID,
+		Data: apperr.ToData(err), Status: "error",
+	}
+	if wait > 0 {
+		f.RetryAfter = middleware.RetryAfterSeconds(wait)
+	}
+	data, _ := json.Marshal(f)
+	c.Hub.direct <- Frame{Tenant: c.Tenant, Data: data, From: c}
+}
@@ -0,0 +1,195 @@
+package websocket
+
+import (
+	"bytes"
+	"cmp"
+	"encoding/json"
+	"slices"
+	"time"
+
+	"github.com/patrickmn/go-cache"
+)
+
+// TypeRoom 房间控制帧的 type，由 Hub 处理，不转发给其他连接
+const TypeRoom = "room"
+
+// 房间控制帧的 action
+const (
+	RoomJoin    = "join"    // 加入 params.room，已在其他房间时先离开；params.name 为在房间中显示的名称
+	RoomLeave   = "leave"   // 离开当前房间
+	RoomMembers = "members" // 查询 params.room (为空时为当前房间) 的成员列表，只向发送方回复
+)
+
+// roomRequestTTL 房间成员发出的请求与房间的对应关系的保留时长，超时后响应只投递给未加入房间的连接
+const roomRequestTTL = 10 * time.Minute
+
+// RoomFrame 房间控制帧：连接发送 join 或 leave，Hub 向该房间的成员与发送方回复 status 为 done、data 为房间当前人数的同名帧，
+// data.member 为加入或离开的成员 (断开连接同样视为离开)
+type RoomFrame struct {
+	V      int       `json:"v,omitempty"`
+	Type   string    `json:"type"`
+	Action string    `json:"action"`
+	Params *RoomInfo `json:"params,omitempty"`
+	Data   *RoomInfo `json:"data,omitempty"`
+	Status string    `json:"status,omitempty"`
+}
+
+// RoomInfo 房间名与成员数；history 帧的 params.limit 为取回条数，data.messages 为取回的消息；
+// presence 帧的 params.presence 为要设置的在线状态，typing 帧的 typing 为是否正在输入
+type RoomInfo struct {
+	Room       string            `json:"room"`
+	Members    int               `json:"members,omitempty"`
+	Name       string            `json:"name,omitempty"`
+	Member     *RoomMember       `json:"member,omitempty"`
+	MemberList []RoomMember      `json:"member_list,omitempty"`
+	Limit      int               `json:"limit,omitempty"`
+	Messages   []json.RawMessage `json:"messages,omitempty"`
+	Presence   string            `json:"presence,omitempty"`
+	Typing     *bool             `json:"typing,omitempty"`
+}
+
+// RoomMember 房间成员：id 由 Hub 为每个连接分配，name 为 join 帧中的 params.name，presence 为在线状态
+type RoomMember struct {
+	ID       string `json:"id"`
+	Name     string `json:"name,omitempty"`
+	Presence string `json:"presence,omitempty"`
+}
+
+// membership 连接加入 (room 非空) 或离开房间，limit 大于 0 时加入后回复房间最近的消息
+type membership struct {
+	client *Client
+	room   string
+	name   string
+	limit  int
+}
+
+// roomQuery 连接查询 room 的最近消息 (history) 或成员列表 (members)，只回复发送方
+type roomQuery struct {
+	client *Client
+	action string
+	room   string
+	limit  int
+}
+
+// parseRoomFrame 识别房间控制帧，不是时返回 nil
+func parseRoomFrame(message []byte) *RoomFrame {
+	if !bytes.Contains(message, []byte(`"room"`)) {
+		return nil
+	}
+	var f RoomFrame
+	if err := json.Unmarshal(message, &f); err != nil || f.Type != TypeRoom {
+		return nil
+	}
+	return &f
+}
+
+// setRoom 在 Run 中调用，更新连接所在的房间并通知受影响房间的成员
+func (h *Hub) setRoom(c *Client, room, name string) {
+	if room != "" {
+		c.member.Name = name
+	}
+	h.mu.Lock()
+	old := h.members[c]
+	if room == "" {
+		delete(h.members, c)
+	} else {
+		h.members[c] = room
+	}
+	h.mu.Unlock()
+
+	if old != room {
+		h.clearTyping(c)
+	}
+	if old != "" && old != room {
+		h.notifyRoom(old, RoomLeave, c, true)
+	}
+	if room != "" {
+		h.notifyRoom(room, RoomJoin, c, false)
+	}
+}
+
+// notifyRoom 向房间成员发送当前人数与加入或离开的成员 who，notifyWho 为 true 时 who 不在房间中也会收到
+func (h *Hub) notifyRoom(room, action string, who *Client, notifyWho bool) {
+	h.mu.RLock()
+	targets := make([]*Client, 0, 4)
+	members := 0
+	for client, r := range h.members {
+		if r == room && client.Tenant == who.Tenant {
+			targets = append(targets, client)
+			members++
+		}
+	}
+	h.mu.RUnlock()
+	if notifyWho {
+		targets = append(targets, who)
+	}
+	member := who.member
+	data, _ := json.Marshal(RoomFrame{Type: TypeRoom, Action: action, Status: "done", Data: &RoomInfo{Room: room, Members: members, Member: &member}})
+	for _, client := range targets {
+		select {
+		case client.Send <- data:
+		default:
+		}
+	}
+}
+
+// query 在 Run 中调用，回复 history 或 members 查询；未指定房间时为连接当前所在的房间
+func (h *Hub) query(q roomQuery) {
+	if q.room == "" {
+		q.room = h.members[q.client]
+	}
+	if q.action == RoomHistory {
+		h.replay(q)
+		return
+	}
+	info := &RoomInfo{Room: q.room, MemberList: []RoomMember{}}
+	h.mu.RLock()
+	for client, r := range h.members {
+		if r == q.room && client.Tenant == q.client.Tenant {
+			info.MemberList = append(info.MemberList, client.member)
+		}
+	}
+	h.mu.RUnlock()
+	slices.SortFunc(info.MemberList, func(a, b RoomMember) int {
+		return cmp.Or(cmp.Compare(a.Name, b.Name), cmp.Compare(a.ID, b.ID))
+	})
+	info.Members = len(info.MemberList)
+	data, _ := json.Marshal(RoomFrame{Type: TypeRoom, Action: RoomMembers, Status: "done", Data: info})
+	select {
+	case q.client.Send <- data:
+	default:
+	}
+}
+
+// route 决定帧的投递范围：all 为 true 时投递给租户的全部连接，否则投递给 room 的成员与未加入房间的连接。
+// 没有连接加入房间时与不分房间时相同；房间成员发出的帧只在本房间内可见 (未加入房间的桥接客户端仍可收到)，
+// 桥接客户端的响应按 request_id 投递回发出请求的房间，心跳等不属于任何请求的帧投递给全部连接
+func (h *Hub) route(f Frame) (room string, all bool) {
+	if len(h.members) == 0 {
+		return "", true
+	}
+	var head struct {
+		Type      string `json:"type"`
+		RequestID string `json:"request_id"`
+		Status    string `json:"status"`
+	}
+	_ = json.Unmarshal(f.Data, &head)
+
+	if room := h.members[f.From]; room != "" {
+		if head.RequestID != "" {
+			h.requests.Set(head.RequestID, room, cache.DefaultExpiration)
+		}
+		return room, false
+	}
+	if head.RequestID == "" || head.Type == "heartbeat" {
+		return "", true
+	}
+	v, ok := h.requests.Get(head.RequestID)
+	if !ok {
+		return "", false
+	}
+	if head.Status == "done" || head.Status == "error" {
+		h.requests.Delete(head.RequestID)
+	}
+	return v.(string), false
+}
@@ -0,0 +1,63 @@
+package websocket
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/websocket"
+
+	"ollama_dev/internal/apperr"
+	"ollama_dev/internal/config"
+	"ollama_dev/internal/middleware"
+	"ollama_dev/internal/util/wsutils"
+)
+
+// 可协商的子协议 (server.websocket.subprotocols)
+const (
+	SubprotocolJSON      = "ollama.v1.json"           // 明文 JSON 帧，未协商子协议的连接同样按此处理
+	SubprotocolEncrypted = "ollama.v1.encrypted"      // 请求帧须经端到端加密 (sealed)，明文请求回复 encryption_required
+	SubprotocolMsgpack   = wsutils.SubprotocolMsgpack // 以 MessagePack 二进制帧收发，Hub 内部仍按 JSON 处理
+)
+
+// checkOrigin 校验握手的 Origin，不在 server.websocket.origins 中时返回 403 错误；不带 Origin 的客户端 (bridge、chat) 不受限制
+func checkOrigin(cfg config.WebSocketConfig, r *http.Request) error {
+	origin := r.Header.Get("Origin")
+	if origin == "" || middleware.OriginAllowed(cfg.Origins, origin) {
+		return nil
+	}
+	return apperr.New(apperr.Auth, apperr.CodeForbidden, "不允许来自 "+origin+" 的 WebSocket 连接")
+}
+
+// negotiateSubprotocol 按 server.websocket.subprotocols 的优先顺序选出客户端提供的子协议；
+// 没有可协商的子协议时返回空，配置了 require_subprotocol 时返回 400 错误
+func negotiateSubprotocol(cfg config.WebSocketConfig, r *http.Request) (string, error) {
+	offered := websocket.Subprotocols(r)
+	for _, p := range cfg.Subprotocols {
+		for _, o := range offered {
+			if o == p {
+				return p, nil
+			}
+		}
+	}
+	if cfg.RequireSubprotocol {
+		return "", apperr.New(apperr.Protocol, apperr.CodeBadSubprotocol,
+			"需要以下子协议之一："+strings.Join(cfg.Subprotocols, ", "))
+	}
+	return "", nil
+}
+
+// requireSealed 协商了 ollama.v1.encrypted 的连接只接受加密的请求帧，明文请求回复 encryption_required 并返回 false
+func (c *Client) requireSealed(message []byte) bool {
+	if c.Subprotocol != SubprotocolEncrypted || !bytes.Contains(message, []byte(`"server_to_client"`)) {
+		return true
+	}
+	var head frameHead
+	if json.Unmarshal(message, &head) != nil || !head.isRequest() || (len(head.Sealed) > 0 && string(head.Sealed) != "null") {
+		return true
+	}
+	hubStats.Add("plaintext_rejected", 1)
+	c.replyError(head, apperr.New(apperr.Auth, apperr.CodeEncryptionRequired, "连接协商了 "+SubprotocolEncrypted+"，拒绝明文请求"), 0)
+	return false
+}
@@ -0,0 +1,135 @@
+package websocket
+
+import (
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+
+	"ollama_dev/internal/apperr"
+	"ollama_dev/internal/config"
+	"ollama_dev/internal/util/wsutils"
+)
+
+func handshakeServer(t *testing.T, configure func(*config.WebSocketConfig)) string {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	cfg := config.Default()
+	cfg.Auth.Token = ""
+	cfg.Server.WebSocket.Origins = []string{"https://app.example.com"}
+	if configure != nil {
+		configure(&cfg.Server.WebSocket)
+	}
+	r := gin.New()
+	InitWebSocketPlugin(r.Group("/ws"), config.NewStore("", cfg), nil, nil, nil, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	srv := httptest.NewServer(r)
+	t.Cleanup(srv.Close)
+	return "ws" + strings.TrimPrefix(srv.URL, "http") + "/ws/"
+}
+
+func TestHandshakeOrigin(t *testing.T) {
+	url := handshakeServer(t, nil)
+	for origin, want := range map[string]int{
+		"https://app.example.com": http.StatusSwitchingProtocols,
+		"https://evil.example":    http.StatusForbidden,
+		"":                        http.StatusSwitchingProtocols, // 不带 Origin 的客户端 (bridge) 不受限制
+	} {
+		header := http.Header{}
+		if origin != "" {
+			header.Set("Origin", origin)
+		}
+		conn, resp, err := websocket.DefaultDialer.Dial(url, header)
+		if conn != nil {
+			conn.Close()
+		}
+		if resp == nil || resp.StatusCode != want {
+			t.Errorf("%q: expected %d, got %v (%v)", origin, want, resp, err)
+		}
+	}
+}
+
+func TestNegotiateSubprotocol(t *testing.T) {
+	url := handshakeServer(t, nil)
+	dialer := websocket.Dialer{Subprotocols: []string{"chat.v2", SubprotocolEncrypted, SubprotocolJSON}}
+	conn, _, err := dialer.Dial(url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	// 按服务端配置的优先顺序选择
+	if got := conn.Subprotocol(); got != SubprotocolJSON {
+		t.Errorf("subprotocol = %q, want %q", got, SubprotocolJSON)
+	}
+
+	url = handshakeServer(t, func(ws *config.WebSocketConfig) { ws.RequireSubprotocol = true })
+	_, resp, err := (&websocket.Dialer{Subprotocols: []string{"chat.v2"}}).Dial(url, nil)
+	if err == nil || resp == nil || resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("expected 400 without a supported subprotocol, got %v (%v)", resp, err)
+	}
+	var body struct{ Code string }
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil || body.Code != apperr.CodeBadSubprotocol {
+		t.Errorf("body code = %q, %v", body.Code, err)
+	}
+}
+
+func TestEncryptedSubprotocolRejectsPlaintext(t *testing.T) {
+	url := handshakeServer(t, func(ws *config.WebSocketConfig) { ws.Subprotocols = []string{SubprotocolEncrypted} })
+	conn, _, err := (&websocket.Dialer{Subprotocols: []string{SubprotocolEncrypted}}).Dial(url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	read := func() ErrorFrame {
+		t.Helper()
+		_ = conn.SetReadDeadline(time.Now().Add(time.Second))
+		var f ErrorFrame
+		if err := conn.ReadJSON(&f); err != nil {
+			t.Fatal(err)
+		}
+		return f
+	}
+
+	_ = conn.WriteMessage(websocket.TextMessage, []byte(`{"type":"server_to_client","action":"chat","request_id":"r1","params":{"model_name":"llama3"}}`))
+	if f := read(); f.RequestID != "r1" || f.Status != "error" || f.Data.Code != apperr.CodeEncryptionRequired {
+		t.Fatalf("expected encryption_required, got %+v", f)
+	}
+	// 加密的请求照常广播 (包括发送方)
+	_ = conn.WriteMessage(websocket.TextMessage, []byte(`{"type":"server_to_client","action":"chat","request_id":"r2","sealed":{"nonce":"x","data":"y"}}`))
+	if f := read(); f.RequestID != "r2" || f.Status != "" {
+		t.Fatalf("expected echoed sealed request, got %+v", f)
+	}
+}
+
+func TestMsgpackSubprotocol(t *testing.T) {
+	url := handshakeServer(t, nil)
+	conn, _, err := (&websocket.Dialer{Subprotocols: []string{SubprotocolMsgpack}}).Dial(url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	if conn.Subprotocol() != SubprotocolMsgpack {
+		t.Fatalf("subprotocol = %q", conn.Subprotocol())
+	}
+	frame, err := wsutils.Msgpack.Encode([]byte(`{"type":"server_to_client","action":"chat","request_id":"r1"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = conn.WriteMessage(websocket.BinaryMessage, frame)
+	// 广播回发送方的帧同样以 MessagePack 编码
+	_ = conn.SetReadDeadline(time.Now().Add(time.Second))
+	messageType, data, err := conn.ReadMessage()
+	if err != nil || messageType != websocket.BinaryMessage {
+		t.Fatalf("message type %d, %v", messageType, err)
+	}
+	decoded, err := wsutils.Msgpack.Decode(data)
+	if err != nil || !strings.Contains(string(decoded), `"request_id":"r1"`) {
+		t.Errorf("decoded = %s, %v", decoded, err)
+	}
+}
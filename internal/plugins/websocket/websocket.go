@@ -3,35 +3,138 @@ package websocket
 import (
 	"log/slog"
 	"net/http"
+	"time"
 
 	"github.com/gin-gonic/gin"
-
 	"github.com/gorilla/websocket"
+
+	"ollama_dev/internal/auth"
+	"ollama_dev/internal/capture"
+	"ollama_dev/internal/config"
+	"ollama_dev/internal/logging"
+	"ollama_dev/internal/middleware"
+	"ollama_dev/internal/ratelimit"
+	"ollama_dev/internal/stats"
+	"ollama_dev/internal/tenant"
+	"ollama_dev/internal/usage"
+	"ollama_dev/internal/util/wsutils"
 )
 
-var upgrader = websocket.Upgrader{
-	CheckOrigin: func(r *http.Request) bool { return true },
+// handshake 握手时确定的连接属性
+type handshake struct {
+	identity    auth.Identity // 通过鉴权的调用方
+	rateKey     string        // 限流时区分客户端的键，按 server.rate_limit.key 确定
+	subprotocol string        // 协商的子协议，未协商时为空
+	compression config.CompressionConfig
 }
 
-func serveWs(hub *Hub, w http.ResponseWriter, r *http.Request, logger *slog.Logger) {
-	conn, err := upgrader.Upgrade(w, r, nil)
+func serveWs(hub *Hub, upgrader *websocket.Upgrader, sendQueue int, capt *capture.Capture, usg *usage.Store, hs handshake, w http.ResponseWriter, r *http.Request, logger *slog.Logger) {
+	var header http.Header
+	if hs.subprotocol != "" {
+		header = http.Header{"Sec-Websocket-Protocol": {hs.subprotocol}}
+	}
+	conn, err := upgrader.Upgrade(w, r, header)
 	if err != nil {
 		logger.Error("WebSocket 升级失败", "error", err)
 		return
 	}
-	client := &Client{Hub: hub, Conn: conn, Send: make(chan []byte, 256)}
+	wsutils.SetCompression(conn, hs.compression)
+	client := &Client{
+		Hub:               hub,
+		Conn:              conn,
+		Send:              make(chan []byte, sendQueue),
+		ID:                conn.RemoteAddr().String(),
+		Tenant:            hs.identity.Tenant,
+		Identity:          hs.identity,
+		Subprotocol:       hs.subprotocol,
+		Capture:           capt,
+		Logger:            logger,
+		Usage:             usg,
+		rateKey:           hs.rateKey,
+		codec:             wsutils.CodecFor(hs.subprotocol),
+		compressThreshold: hs.compression.Threshold,
+		flushed:           make(chan struct{}),
+		connectedAt:       time.Now(),
+	}
 	client.Hub.Register <- client
 	go client.WritePump()
 	go client.ReadPump()
 }
 
-func InitWebSocketPlugin(r *gin.RouterGroup, logger *slog.Logger) {
-	h := NewHub()
+// InitWebSocketPlugin 在 r 上挂载 WebSocket 端点 (默认 /ws)，依次校验 Origin (server.websocket.origins，否则 403)、按 auth.Verifier.Authenticate 识别调用方 (要求 user 角色，请求帧的动作另按 auth.ActionRole 逐帧校验)
+// 并校验客户端证书与握手签名，再协商子协议 (server.websocket.subprotocols)，均随配置热加载；
+// usg 不为 nil 时按发出请求的连接的调用方记录 bridge 上报的 token 用量，并按 server.quota 拒绝超出配额的 chat 请求；
+// h 为 nil 时创建新的 Hub，传入的 Hub 由插件启动，调用方不得再调用其 Run；
+// 配置了 server.websocket.history.size 且 Hub 未设置历史时按配置打开；按 server.websocket.heartbeat 回收失联连接
+func InitWebSocketPlugin(r *gin.RouterGroup, store *config.Store, h *Hub, capt *capture.Capture, usg *usage.Store, logger *slog.Logger) {
+	cfg := store.Get().Server.WebSocket
+	if h == nil {
+		h = NewHub()
+	}
+	// 消息历史在 Hub.Shutdown 时关闭；打开失败时不保存历史，/ws 照常提供
+	if h.history == nil {
+		history, err := NewHistoryStore(cfg.History)
+		if err != nil {
+			logger.Error("打开消息历史失败，不保存历史", "backend", cfg.History.Backend, "error", err)
+		} else if history != nil {
+			h.SetHistory(history)
+			logger.Info("已启用消息历史", "backend", cfg.History.Backend, "size", cfg.History.Size)
+		}
+	}
+	if h.limiter == nil {
+		h.enableRateLimit(func() config.RateLimitConfig { return store.Get().Server.RateLimit })
+	}
+	if h.quotas == nil {
+		h.quotas = func() config.QuotaConfig { return store.Get().Server.Quota }
+	}
 	go h.Run()
+	h.StartJanitor(cfg.Heartbeat, logger)
+	stats.RegisterConnections(h.Stats)
 
+	upgrader := &websocket.Upgrader{
+		ReadBufferSize:    cfg.ReadBufferSize,
+		WriteBufferSize:   cfg.WriteBufferSize,
+		EnableCompression: store.Get().Compression.Enabled,            // 修改后需重启生效
+		CheckOrigin:       func(r *http.Request) bool { return true }, // 已由 checkOrigin 按配置校验
+	}
+	verifier := auth.NewVerifier()
 	r.GET("/", func(c *gin.Context) {
-		serveWs(h, c.Writer, c.Request, logger)
+		cur := store.Get()
+		if err := checkOrigin(cur.Server.WebSocket, c.Request); err != nil {
+			logger.Warn("拒绝握手：Origin 不被允许", "remote_addr", c.ClientIP(), "origin", c.GetHeader("Origin"))
+			middleware.AbortWithError(c, err)
+			return
+		}
+		id, err := verifier.Authenticate(cur, c.Request)
+		if err != nil {
+			logger.Warn("握手校验失败", "remote_addr", c.ClientIP(), "error", err)
+			middleware.AbortWithError(c, err)
+			return
+		}
+		middleware.SetIdentity(c, id)
+		if middleware.RequireRole(auth.RoleUser)(c); c.IsAborted() {
+			return
+		}
+		// 握手请求的 ID 标识该连接，之后的日志都带上它与握手时的 trace ID
+		l := logger.With("tenant", id.Tenant, "conn_id", logging.RequestID(c.Request.Context()))
+		if trace := logging.TraceID(c.Request.Context()); trace != "" {
+			l = l.With(logging.TraceIDKey, trace)
+		}
+		if id.Subject != "" {
+			l = l.With("subject", id.Subject)
+		}
+		subprotocol, err := negotiateSubprotocol(cur.Server.WebSocket, c.Request)
+		if err != nil {
+			l.Warn("拒绝握手：没有可协商的子协议", "remote_addr", c.ClientIP(), "offered", websocket.Subprotocols(c.Request))
+			middleware.AbortWithError(c, err)
+			return
+		}
+		hs := handshake{
+			identity:    id,
+			rateKey:     ratelimit.ClientKey(cur.Server.RateLimit.Key, tenant.Token(c.Request), c.ClientIP()),
+			subprotocol: subprotocol,
+			compression: cur.Compression,
+		}
+		serveWs(h, upgrader, cfg.SendQueue, capt, usg, hs, c.Writer, c.Request, l)
 	})
-
-	logger.Info("WebSocket 插件已加载，路径：/ws")
 }
@@ -0,0 +1,287 @@
+// Package policy 限制远端调用方可以使用的模型与模型参数。策略文件由 bridge.policy_file 指定，
+// 修改后自动重新加载，无需重启 bridge
+package policy
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"ollama_dev/internal/apperr"
+)
+
+// pollInterval 检查策略文件是否被修改的间隔
+const pollInterval = 2 * time.Second
+
+// Policy 策略文件的内容：只允许 models 中列出的模型，按文件中的顺序取第一条匹配的规则
+type Policy struct {
+	Models []Rule `yaml:"models"`
+}
+
+// Rule 一个或一组模型的规则，未设置的限制不生效
+type Rule struct {
+	Name           string   `yaml:"name"`            // 模型名，可使用 path.Match 通配符，例如 llama3:*；不带标签时按 :latest 匹配
+	MaxNumPredict  int      `yaml:"max_num_predict"` // options.num_predict 的上限，请求未指定时按上限生成
+	MaxNumCtx      int      `yaml:"max_num_ctx"`     // options.num_ctx 的上限
+	MinTemperature *float64 `yaml:"min_temperature"`
+	MaxTemperature *float64 `yaml:"max_temperature"`
+}
+
+// Parse 解析并校验策略文件的内容
+func Parse(data []byte) (*Policy, error) {
+	var p Policy
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(&p); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("解析策略文件失败: %w", err)
+	}
+	for i, r := range p.Models {
+		if r.Name == "" {
+			return nil, fmt.Errorf("models[%d].name 不能为空", i)
+		}
+		if _, err := path.Match(r.Name, ""); err != nil {
+			return nil, fmt.Errorf("models[%d].name %q 不是合法的通配符: %w", i, r.Name, err)
+		}
+		if r.MaxNumPredict < 0 || r.MaxNumCtx < 0 {
+			return nil, fmt.Errorf("models[%d] 的 max_num_predict 与 max_num_ctx 不能为负数", i)
+		}
+		if r.MinTemperature != nil && r.MaxTemperature != nil && *r.MinTemperature > *r.MaxTemperature {
+			return nil, fmt.Errorf("models[%d] 的 min_temperature 大于 max_temperature", i)
+		}
+	}
+	return &p, nil
+}
+
+// Rule 返回 model 匹配的规则，没有匹配时返回 false
+func (p *Policy) Rule(model string) (Rule, bool) {
+	model = withTag(model)
+	for _, r := range p.Models {
+		if ok, _ := path.Match(r.Name, model); ok {
+			return r, true
+		}
+		if ok, _ := path.Match(withTag(r.Name), model); ok {
+			return r, true
+		}
+	}
+	return Rule{}, false
+}
+
+// withTag 为不带标签的模型名补上 Ollama 默认的 :latest
+func withTag(name string) string {
+	if strings.Contains(name, ":") {
+		return name
+	}
+	return name + ":latest"
+}
+
+// Apply 检查 model 与 options 是否符合策略，返回补上默认上限后的 options (不修改传入的 map)；
+// 不在允许列表中的模型返回 model_not_allowed，超出限制的参数返回 policy_violation
+func (p *Policy) Apply(model string, options map[string]any) (map[string]any, error) {
+	r, ok := p.Rule(model)
+	if !ok {
+		return nil, notAllowed(model)
+	}
+	if err := r.checkMax(options, "num_predict", r.MaxNumPredict); err != nil {
+		return nil, err
+	}
+	if err := r.checkMax(options, "num_ctx", r.MaxNumCtx); err != nil {
+		return nil, err
+	}
+	if err := r.checkTemperature(options); err != nil {
+		return nil, err
+	}
+	if _, set := options["num_predict"]; set || r.MaxNumPredict == 0 {
+		return options, nil
+	}
+	out := make(map[string]any, len(options)+1)
+	for k, v := range options {
+		out[k] = v
+	}
+	out["num_predict"] = r.MaxNumPredict
+	return out, nil
+}
+
+// Allow 只检查 model 是否在允许列表中，供复制、创建与加载模型等不带 options 的请求使用；不在列表中时返回 model_not_allowed
+func (p *Policy) Allow(model string) error {
+	if _, ok := p.Rule(model); !ok {
+		return notAllowed(model)
+	}
+	return nil
+}
+
+func notAllowed(model string) error {
+	return apperr.New(apperr.Validation, apperr.CodeModelNotAllowed, fmt.Sprintf("模型 %q 不在允许列表中", model))
+}
+
+// checkMax options[key] 不得超过 limit，limit 为 0 时不限；num_predict 为负数表示不限长度，同样视为超出
+func (r Rule) checkMax(options map[string]any, key string, limit int) error {
+	v, set := options[key]
+	if !set || limit == 0 {
+		return nil
+	}
+	n, ok := number(v)
+	if !ok {
+		return violation(key, "应为数字")
+	}
+	if n < 0 || n > float64(limit) {
+		return violation(key, fmt.Sprintf("不能超过 %d", limit))
+	}
+	return nil
+}
+
+// checkTemperature options.temperature 须在 [min_temperature, max_temperature] 范围内
+func (r Rule) checkTemperature(options map[string]any) error {
+	v, set := options["temperature"]
+	if !set || (r.MinTemperature == nil && r.MaxTemperature == nil) {
+		return nil
+	}
+	t, ok := number(v)
+	if !ok {
+		return violation("temperature", "应为数字")
+	}
+	if r.MinTemperature != nil && t < *r.MinTemperature {
+		return violation("temperature", fmt.Sprintf("不能小于 %g", *r.MinTemperature))
+	}
+	if r.MaxTemperature != nil && t > *r.MaxTemperature {
+		return violation("temperature", fmt.Sprintf("不能大于 %g", *r.MaxTemperature))
+	}
+	return nil
+}
+
+func violation(key, reason string) error {
+	return apperr.New(apperr.Validation, apperr.CodePolicyViolation, fmt.Sprintf("options.%s %s", key, reason))
+}
+
+// number 读取 JSON 或 msgpack 解码得到的数值
+func number(v any) (float64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case float32:
+		return float64(n), true
+	case int:
+		return float64(n), true
+	case int8:
+		return float64(n), true
+	case int16:
+		return float64(n), true
+	case int32:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case uint8:
+		return float64(n), true
+	case uint16:
+		return float64(n), true
+	case uint32:
+		return float64(n), true
+	case uint64:
+		return float64(n), true
+	case json.Number:
+		f, err := n.Float64()
+		return f, err == nil
+	default:
+		return 0, false
+	}
+}
+
+// Watcher 持有策略文件的当前内容，文件修改后重新加载；新内容不合法时保留原策略并记录错误
+type Watcher struct {
+	path string
+
+	mu      sync.RWMutex
+	policy  *Policy
+	modTime time.Time
+}
+
+// Open 读取策略文件，文件不存在或内容不合法时返回错误
+func Open(path string) (*Watcher, error) {
+	w := &Watcher{path: path}
+	if _, err := w.reload(); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+// reload 文件修改时间变化时重新读取，返回是否已重新加载
+func (w *Watcher) reload() (bool, error) {
+	info, err := os.Stat(w.path)
+	if err != nil {
+		return false, fmt.Errorf("读取策略文件失败: %w", err)
+	}
+	w.mu.RLock()
+	unchanged := w.policy != nil && info.ModTime().Equal(w.modTime)
+	w.mu.RUnlock()
+	if unchanged {
+		return false, nil
+	}
+	data, err := os.ReadFile(w.path)
+	if err != nil {
+		return false, fmt.Errorf("读取策略文件失败: %w", err)
+	}
+	p, err := Parse(data)
+	if err != nil {
+		// 同一版本不再重复解析，文件再次修改后重试
+		w.mu.Lock()
+		w.modTime = info.ModTime()
+		w.mu.Unlock()
+		return false, err
+	}
+	w.mu.Lock()
+	w.policy, w.modTime = p, info.ModTime()
+	w.mu.Unlock()
+	return true, nil
+}
+
+// Run 定期检查策略文件，直到 ctx 结束
+func (w *Watcher) Run(ctx context.Context, logger *slog.Logger) {
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			reloaded, err := w.reload()
+			if err != nil {
+				logger.Error("重新加载策略文件失败，继续使用原策略", "path", w.path, "error", err)
+			} else if reloaded {
+				logger.Info("已重新加载策略文件", "path", w.path, "models", len(w.Policy().Models))
+			}
+		}
+	}
+}
+
+// Policy 返回当前策略
+func (w *Watcher) Policy() *Policy {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	return w.policy
+}
+
+// Apply 按当前策略检查请求，见 Policy.Apply；w 为 nil 表示未启用策略，options 原样返回
+func (w *Watcher) Apply(model string, options map[string]any) (map[string]any, error) {
+	if w == nil {
+		return options, nil
+	}
+	return w.Policy().Apply(model, options)
+}
+
+// Allow 按当前策略检查模型，见 Policy.Allow；w 为 nil 表示未启用策略，总是返回 nil
+func (w *Watcher) Allow(model string) error {
+	if w == nil {
+		return nil
+	}
+	return w.Policy().Allow(model)
+}
@@ -0,0 +1,129 @@
+package policy
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"ollama_dev/internal/apperr"
+)
+
+const testPolicy = `
+models:
+  - name: "llama3:*"
+    max_num_predict: 256
+    max_num_ctx: 4096
+    min_temperature: 0
+    max_temperature: 1
+  - name: qwen2.5
+`
+
+func TestApply(t *testing.T) {
+	p, err := Parse([]byte(testPolicy))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cases := []struct {
+		model   string
+		options map[string]any
+		code    string
+	}{
+		{"llama3:8b", map[string]any{"num_predict": 128.0, "temperature": 0.5}, ""},
+		{"llama3", nil, ""}, // 不带标签时按 :latest 匹配
+		{"qwen2.5:latest", map[string]any{"num_predict": -1.0}, ""},
+		{"mistral", nil, apperr.CodeModelNotAllowed},
+		{"llama3:8b", map[string]any{"num_predict": 512.0}, apperr.CodePolicyViolation},
+		{"llama3:8b", map[string]any{"num_predict": -1.0}, apperr.CodePolicyViolation},
+		{"llama3:8b", map[string]any{"num_ctx": int64(8192)}, apperr.CodePolicyViolation},
+		{"llama3:8b", map[string]any{"temperature": 1.5}, apperr.CodePolicyViolation},
+		{"llama3:8b", map[string]any{"temperature": "hot"}, apperr.CodePolicyViolation},
+	}
+	for _, c := range cases {
+		_, err := p.Apply(c.model, c.options)
+		if got := apperr.CodeOf(err); got != c.code {
+			t.Errorf("%s %v: expected %q, got %v", c.model, c.options, c.code, err)
+		}
+	}
+	// Allow 只检查模型是否在允许列表中
+	if err := p.Allow("llama3"); err != nil {
+		t.Errorf("Allow(llama3): %v", err)
+	}
+	if err := p.Allow("mistral"); apperr.CodeOf(err) != apperr.CodeModelNotAllowed {
+		t.Errorf("Allow(mistral): expected model_not_allowed, got %v", err)
+	}
+
+	options := map[string]any{"temperature": 0.2}
+	got, err := p.Apply("llama3:8b", options)
+	if err != nil || got["num_predict"] != 256 || got["temperature"] != 0.2 {
+		t.Errorf("expected num_predict to default to the limit, got %v, %v", got, err)
+	}
+	if _, ok := options["num_predict"]; ok {
+		t.Error("Apply modified the request options")
+	}
+}
+
+func TestParseRejectsInvalidRules(t *testing.T) {
+	for _, data := range []string{
+		"models:\n  - max_num_predict: 10\n",
+		"models:\n  - name: \"[\"\n",
+		"models:\n  - name: a\n    min_temperature: 1\n    max_temperature: 0.5\n",
+		"models:\n  - name: a\n    max_tokens: 10\n",
+	} {
+		if _, err := Parse([]byte(data)); err == nil {
+			t.Errorf("expected %q to be rejected", data)
+		}
+	}
+	p, err := Parse(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := p.Apply("llama3", nil); apperr.CodeOf(err) != apperr.CodeModelNotAllowed {
+		t.Errorf("an empty policy should allow no models, got %v", err)
+	}
+}
+
+func TestWatcherReload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "policy.yaml")
+	if err := os.WriteFile(path, []byte(testPolicy), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	w, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.Apply("mistral", nil); apperr.CodeOf(err) != apperr.CodeModelNotAllowed {
+		t.Fatalf("expected mistral to be rejected, got %v", err)
+	}
+
+	touch := func(data string, at time.Time) {
+		t.Helper()
+		if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(path, at, at); err != nil {
+			t.Fatal(err)
+		}
+	}
+	touch("models:\n  - name: mistral\n", time.Now().Add(time.Minute))
+	if reloaded, err := w.reload(); !reloaded || err != nil {
+		t.Fatalf("expected reload, got %v, %v", reloaded, err)
+	}
+	if _, err := w.Apply("mistral", nil); err != nil {
+		t.Errorf("expected mistral to be allowed after reload, got %v", err)
+	}
+
+	// 不合法的新内容不替换原策略
+	touch("models: [", time.Now().Add(2*time.Minute))
+	if _, err := w.reload(); err == nil {
+		t.Fatal("expected a parse error")
+	}
+	if _, err := w.Apply("mistral", nil); err != nil {
+		t.Errorf("expected the previous policy to stay in effect, got %v", err)
+	}
+
+	var nilWatcher *Watcher
+	if opts, err := nilWatcher.Apply("anything", map[string]any{"num_predict": 1e6}); err != nil || opts["num_predict"] != 1e6 {
+		t.Errorf("a nil watcher should allow everything, got %v, %v", opts, err)
+	}
+}
@@ -0,0 +1,154 @@
+// Package ratelimit 按客户端限制请求速率 (令牌桶) 与同时进行的生成数，规则见 config.RateLimitRule
+package ratelimit
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/patrickmn/go-cache"
+
+	"ollama_dev/internal/config"
+)
+
+// idleTTL 客户端空闲超过该时长且没有进行中的生成时丢弃其状态
+const idleTTL = 10 * time.Minute
+
+// Limiter 按键 (客户端) 维护令牌桶与进行中的生成数；规则在每次调用时传入，以便随配置热加载。为 nil 时不限制
+type Limiter struct {
+	mu        sync.Mutex
+	clients   map[string]*client
+	holds     *cache.Cache // Hold 登记的 id -> 键，Done 或超时后归还名额
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+type client struct {
+	tokens float64
+	last   time.Time
+	active int // 进行中的生成数
+}
+
+// New 创建限流器，holdTTL 为 Hold 登记的生成未收到 Done 时自动归还名额的时长
+func New(holdTTL time.Duration) *Limiter {
+	l := &Limiter{clients: make(map[string]*client), lastSweep: time.Now(), now: time.Now}
+	l.holds = cache.New(holdTTL, holdTTL)
+	l.holds.OnEvicted(func(_ string, v any) { l.release(v.(string)) })
+	return l
+}
+
+// ClientKey 按 server.rate_limit.key 区分客户端：token 时为凭据的摘要 (不保存凭据本身)，未携带凭据或为 ip 时为 IP
+func ClientKey(mode, token, ip string) string {
+	if mode == "token" && token != "" {
+		sum := sha256.Sum256([]byte(token))
+		return "token:" + hex.EncodeToString(sum[:8])
+	}
+	return "ip:" + ip
+}
+
+// burst 令牌桶容量，未配置时取 rate 向上取整，至少为 1
+func burst(rule config.RateLimitRule) float64 {
+	if rule.Burst > 0 {
+		return float64(rule.Burst)
+	}
+	return max(1, math.Ceil(rule.Rate))
+}
+
+// get 返回 key 的状态，不存在时创建满的令牌桶；顺带丢弃空闲的客户端。调用方需持有锁
+func (l *Limiter) get(rule config.RateLimitRule, key string, now time.Time) *client {
+	if now.Sub(l.lastSweep) > idleTTL {
+		for k, c := range l.clients {
+			if c.active == 0 && now.Sub(c.last) > idleTTL {
+				delete(l.clients, k)
+			}
+		}
+		l.lastSweep = now
+	}
+	c, ok := l.clients[key]
+	if !ok {
+		c = &client{tokens: burst(rule), last: now}
+		l.clients[key] = c
+	}
+	return c
+}
+
+// Allow 消耗 key 的一个令牌；桶为空时返回 false 与补足一个令牌所需的时间。rule.Rate 为 0 时不限制
+func (l *Limiter) Allow(rule config.RateLimitRule, key string) (time.Duration, bool) {
+	if l == nil || rule.Rate <= 0 {
+		return 0, true
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := l.now()
+	c := l.get(rule, key, now)
+	c.tokens = min(burst(rule), c.tokens+now.Sub(c.last).Seconds()*rule.Rate)
+	c.last = now
+	if c.tokens < 1 {
+		return time.Duration((1 - c.tokens) / rule.Rate * float64(time.Second)), false
+	}
+	c.tokens--
+	return 0, true
+}
+
+// Acquire 占用 key 的一个生成名额，已达 rule.Concurrency 时返回 false；成功时须调用 release 归还 (可重复调用)
+func (l *Limiter) Acquire(rule config.RateLimitRule, key string) (release func(), ok bool) {
+	if l == nil || rule.Concurrency <= 0 {
+		return func() {}, true
+	}
+	if !l.acquire(rule, key) {
+		return nil, false
+	}
+	var once sync.Once
+	return func() { once.Do(func() { l.release(key) }) }, true
+}
+
+// Hold 与 Acquire 相同，用于响应异步返回的请求 (例如经 bridge 处理的 WebSocket 请求)：
+// 名额在以同一 id 调用 Done 或超过 holdTTL 后归还；id 已登记时返回 false，不能借重复的 id 绕过并发上限
+func (l *Limiter) Hold(rule config.RateLimitRule, key, id string) bool {
+	if l == nil || rule.Concurrency <= 0 {
+		return true
+	}
+	if _, ok := l.holds.Get(id); ok {
+		return false
+	}
+	if !l.acquire(rule, key) {
+		return false
+	}
+	// 并发登记同一 id 时只有一个成功
+	if l.holds.Add(id, key, cache.DefaultExpiration) != nil {
+		l.release(key)
+		return false
+	}
+	return true
+}
+
+// Done 归还 Hold 以 id 登记的名额，未登记时忽略
+func (l *Limiter) Done(id string) {
+	if l != nil {
+		l.holds.Delete(id)
+	}
+}
+
+func (l *Limiter) acquire(rule config.RateLimitRule, key string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := l.now()
+	c := l.get(rule, key, now)
+	if c.active >= rule.Concurrency {
+		return false
+	}
+	c.active++
+	c.last = now
+	return true
+}
+
+func (l *Limiter) release(key string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if c, ok := l.clients[key]; ok && c.active > 0 {
+		c.active--
+		c.last = l.now()
+	}
+}
@@ -0,0 +1,97 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+
+	"ollama_dev/internal/config"
+)
+
+func newTestLimiter() (*Limiter, *time.Time) {
+	now := time.Unix(0, 0)
+	l := New(time.Minute)
+	l.now = func() time.Time { return now }
+	l.lastSweep = now
+	return l, &now
+}
+
+func TestAllowTokenBucket(t *testing.T) {
+	l, now := newTestLimiter()
+	rule := config.RateLimitRule{Rate: 2, Burst: 3}
+	// 初始可突发 burst 个请求
+	for i := range 3 {
+		if _, ok := l.Allow(rule, "a"); !ok {
+			t.Fatalf("request %d rejected", i)
+		}
+	}
+	wait, ok := l.Allow(rule, "a")
+	if ok || wait != 500*time.Millisecond {
+		t.Fatalf("expected rejection with 500ms wait, got %v %s", ok, wait)
+	}
+	// 其他客户端不受影响
+	if _, ok := l.Allow(rule, "b"); !ok {
+		t.Error("other key rejected")
+	}
+	*now = now.Add(500 * time.Millisecond)
+	if _, ok := l.Allow(rule, "a"); !ok {
+		t.Error("expected a token after refill")
+	}
+	// rate 为 0 时不限制
+	for range 10 {
+		if _, ok := l.Allow(config.RateLimitRule{}, "a"); !ok {
+			t.Fatal("unlimited rule rejected")
+		}
+	}
+}
+
+func TestAcquireConcurrency(t *testing.T) {
+	l, _ := newTestLimiter()
+	rule := config.RateLimitRule{Concurrency: 1}
+	release, ok := l.Acquire(rule, "a")
+	if !ok {
+		t.Fatal("first acquire rejected")
+	}
+	if _, ok := l.Acquire(rule, "a"); ok {
+		t.Fatal("second acquire allowed")
+	}
+	release()
+	release() // 重复调用不多归还
+	if _, ok := l.Acquire(rule, "a"); !ok {
+		t.Fatal("acquire after release rejected")
+	}
+	if _, ok := l.Acquire(rule, "a"); ok {
+		t.Fatal("double release returned two slots")
+	}
+}
+
+func TestHoldDone(t *testing.T) {
+	l, _ := newTestLimiter()
+	rule := config.RateLimitRule{Concurrency: 1}
+	if !l.Hold(rule, "a", "acme/r1") {
+		t.Fatal("hold rejected")
+	}
+	// 重复的 id 不能绕过并发上限
+	if l.Hold(rule, "a", "acme/r1") || l.Hold(config.RateLimitRule{Concurrency: 2}, "a", "acme/r1") {
+		t.Fatal("duplicate id held")
+	}
+	if l.Hold(rule, "a", "acme/r2") {
+		t.Fatal("second request held beyond limit")
+	}
+	l.Done("acme/r1")
+	if !l.Hold(rule, "a", "acme/r2") {
+		t.Fatal("hold after done rejected")
+	}
+}
+
+func TestClientKey(t *testing.T) {
+	if got := ClientKey("ip", "secret", "1.2.3.4"); got != "ip:1.2.3.4" {
+		t.Errorf("ip mode: %s", got)
+	}
+	if got := ClientKey("token", "", "1.2.3.4"); got != "ip:1.2.3.4" {
+		t.Errorf("token mode without token: %s", got)
+	}
+	a, b := ClientKey("token", "secret", "1.2.3.4"), ClientKey("token", "secret", "5.6.7.8")
+	if a != b || a == "token:secret" {
+		t.Errorf("token keys: %s %s", a, b)
+	}
+}
@@ -0,0 +1,95 @@
+package retry
+
+import (
+	"context"
+	"math/rand/v2"
+	"time"
+
+	"ollama_dev/internal/config"
+)
+
+// Policy 指数退避的重试策略，断线重连与 Ollama 调用共用
+type Policy struct {
+	cfg    config.RetryConfig
+	jitter func() float64 // 返回 [0, 1) 的随机数
+	now    func() time.Time
+}
+
+// New 按配置创建重试策略
+func New(cfg config.RetryConfig) *Policy {
+	return &Policy{cfg: cfg, jitter: rand.Float64, now: time.Now}
+}
+
+// Delay 返回第 n 次重试（从 1 开始）前的等待时间：base * multiplier^(n-1)，不超过 max，再加上 ±jitter 的随机抖动
+func (p *Policy) Delay(n int) time.Duration {
+	d := float64(p.cfg.Base)
+	for i := 1; i < n && d < float64(p.cfg.Max); i++ {
+		d *= p.cfg.Multiplier
+	}
+	if p.cfg.Max > 0 {
+		d = min(d, float64(p.cfg.Max))
+	}
+	if p.cfg.Jitter > 0 {
+		d *= 1 + p.cfg.Jitter*(2*p.jitter()-1)
+	}
+	return time.Duration(d)
+}
+
+// Backoff 一轮重试的进度
+type Backoff struct {
+	p        *Policy
+	attempts int
+	start    time.Time
+}
+
+// Start 开始一轮重试，第一次尝试之前调用
+func (p *Policy) Start() *Backoff {
+	return &Backoff{p: p, attempts: 1, start: p.now()}
+}
+
+// Next 在一次尝试失败后调用，返回下一次尝试前的等待时间；
+// 已达到 max_attempts，或等待后会超出 budget 时返回 false
+func (b *Backoff) Next() (time.Duration, bool) {
+	cfg := b.p.cfg
+	if cfg.MaxAttempts > 0 && b.attempts >= cfg.MaxAttempts {
+		return 0, false
+	}
+	d := b.p.Delay(b.attempts)
+	if cfg.Budget > 0 && b.p.now().Sub(b.start)+d > cfg.Budget {
+		return 0, false
+	}
+	b.attempts++
+	return d, true
+}
+
+// Attempts 已进行的尝试次数
+func (b *Backoff) Attempts() int {
+	return b.attempts
+}
+
+// Sleep 等待 d，ctx 先结束时返回 false
+func Sleep(ctx context.Context, d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
+
+// Do 执行 fn，失败且 retryable 返回 true 时按策略重试，直到成功、次数或预算用尽，或 ctx 结束；返回最后一次的错误
+func (p *Policy) Do(ctx context.Context, fn func(ctx context.Context) error, retryable func(error) bool) error {
+	b := p.Start()
+	for {
+		err := fn(ctx)
+		if err == nil || !retryable(err) || ctx.Err() != nil {
+			return err
+		}
+		d, ok := b.Next()
+		if !ok || !Sleep(ctx, d) {
+			return err
+		}
+	}
+}
@@ -0,0 +1,102 @@
+package retry
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"ollama_dev/internal/config"
+)
+
+func TestDelayGrowsAndCaps(t *testing.T) {
+	p := New(config.RetryConfig{Base: 100 * time.Millisecond, Max: time.Second, Multiplier: 2})
+	want := []time.Duration{100, 200, 400, 800, 1000, 1000}
+	for i, w := range want {
+		if got := p.Delay(i + 1); got != w*time.Millisecond {
+			t.Errorf("Delay(%d) = %s, want %s", i+1, got, w*time.Millisecond)
+		}
+	}
+}
+
+func TestDelayJitterBounds(t *testing.T) {
+	p := New(config.RetryConfig{Base: time.Second, Max: time.Second, Multiplier: 2, Jitter: 0.2})
+	// 随机数取两端时分别为 -20% 与接近 +20%
+	p.jitter = func() float64 { return 0 }
+	if got := p.Delay(1); got != 800*time.Millisecond {
+		t.Errorf("low jitter = %s", got)
+	}
+	p.jitter = func() float64 { return 0.999 }
+	if got := p.Delay(1); got <= time.Second || got > 1200*time.Millisecond {
+		t.Errorf("high jitter = %s", got)
+	}
+}
+
+func TestBackoffStopsAtMaxAttempts(t *testing.T) {
+	b := New(config.RetryConfig{MaxAttempts: 3, Base: time.Millisecond, Multiplier: 2}).Start()
+	for i := 0; i < 2; i++ {
+		if _, ok := b.Next(); !ok {
+			t.Fatalf("retry %d should be allowed", i+1)
+		}
+	}
+	if _, ok := b.Next(); ok {
+		t.Error("expected no retry after 3 attempts")
+	}
+	if b.Attempts() != 3 {
+		t.Errorf("attempts = %d", b.Attempts())
+	}
+}
+
+func TestBackoffStopsWhenBudgetExhausted(t *testing.T) {
+	p := New(config.RetryConfig{Base: time.Second, Max: time.Minute, Multiplier: 2, Budget: 5 * time.Second})
+	now := time.Unix(0, 0)
+	p.now = func() time.Time { return now }
+	b := p.Start()
+
+	// 1s、2s 之后累计 3s，下一次等待 4s 会超出 5s 的预算
+	for _, want := range []time.Duration{time.Second, 2 * time.Second} {
+		d, ok := b.Next()
+		if !ok || d != want {
+			t.Fatalf("Next() = %s %v, want %s", d, ok, want)
+		}
+		now = now.Add(d)
+	}
+	if d, ok := b.Next(); ok {
+		t.Errorf("expected budget to be exhausted, got %s", d)
+	}
+}
+
+func TestDoRetriesOnlyRetryableErrors(t *testing.T) {
+	p := New(config.RetryConfig{MaxAttempts: 5, Base: time.Millisecond, Multiplier: 1})
+	errTemp, errFatal := errors.New("temporary"), errors.New("fatal")
+	retryable := func(err error) bool { return errors.Is(err, errTemp) }
+
+	calls := 0
+	err := p.Do(context.Background(), func(context.Context) error {
+		if calls++; calls < 3 {
+			return errTemp
+		}
+		return nil
+	}, retryable)
+	if err != nil || calls != 3 {
+		t.Errorf("err = %v, calls = %d, want success after 3 calls", err, calls)
+	}
+
+	calls = 0
+	err = p.Do(context.Background(), func(context.Context) error {
+		calls++
+		return errFatal
+	}, retryable)
+	if !errors.Is(err, errFatal) || calls != 1 {
+		t.Errorf("err = %v, calls = %d, want a single call", err, calls)
+	}
+
+	calls = 0
+	err = p.Do(context.Background(), func(context.Context) error {
+		calls++
+		return errTemp
+	}, retryable)
+	if !errors.Is(err, errTemp) || calls != 5 {
+		t.Errorf("err = %v, calls = %d, want 5 calls", err, calls)
+	}
+}
@@ -1,26 +1,426 @@
 package router
 
 import (
+	"context"
 	"log/slog"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 
+	"ollama_dev/internal/apperr"
+	"ollama_dev/internal/auth"
+	"ollama_dev/internal/capture"
+	"ollama_dev/internal/config"
+	"ollama_dev/internal/dashboard"
+	"ollama_dev/internal/debug"
+	"ollama_dev/internal/feature"
+	"ollama_dev/internal/handlers"
+	"ollama_dev/internal/health"
+	"ollama_dev/internal/i18n"
+	"ollama_dev/internal/logging"
+	"ollama_dev/internal/metrics"
 	"ollama_dev/internal/middleware"
+	"ollama_dev/internal/models"
+	"ollama_dev/internal/openai"
+	"ollama_dev/internal/openapi"
+	"ollama_dev/internal/plugins"
 	"ollama_dev/internal/plugins/websocket"
+	"ollama_dev/internal/ratelimit"
+	"ollama_dev/internal/stats"
+	"ollama_dev/internal/tenant"
+	"ollama_dev/internal/usage"
+	"ollama_dev/internal/version"
 )
 
-// SetupRoutes 注册路由
-func SetupRoutes(logger *slog.Logger, r *gin.Engine) {
+// Deps 路由依赖的运行期组件
+type Deps struct {
+	Lifecycle *health.Lifecycle
+	Readiness *health.Readiness
+	Flags     *feature.Flags
+	Capture   *capture.Capture  // 可为 nil，表示未启用抓包
+	Models    models.Lister     // 可为 nil，表示不提供 /api/models
+	API       *handlers.API     // 可为 nil，表示不提供 /api/chat 与 /api/embeddings
+	OpenAI    *openai.API       // 可为 nil，表示不提供 /v1 下的 OpenAI 兼容接口
+	Usage     *usage.Store      // 可为 nil，表示不统计用量、不提供 /api/usage/export
+	Hub       *websocket.Hub    // 可为 nil，表示由 /ws 插件创建
+	Plugins   *plugins.Registry // 可为 nil，表示新建；挂载的插件由调用方在退出时 Shutdown
+}
+
+// 文档与 Swagger UI 的路径
+const (
+	SpecPath = "/api/openapi.json"
+	DocsPath = "/api/docs"
+)
+
+// SetupRoutes 注册路由，返回收集到的接口说明，其文档由 SpecPath 提供
+func SetupRoutes(logger *slog.Logger, r *gin.Engine, store *config.Store, deps Deps) *openapi.Registry {
+	cfg := store.Get()
+	lifecycle, readiness, flags := deps.Lifecycle, deps.Readiness, deps.Flags
+	spec := openapi.New("ollama_dev", version.Get().Version)
+	root := &r.RouterGroup
+
+	// 存活检查，排空阶段返回 503，适用于 Docker HEALTHCHECK
+	spec.Handle(root, http.MethodGet, "/healthz", openapi.Operation{
+		ID: "getHealth", Summary: "存活检查", Tag: "health",
+		Responses: []openapi.Response{
+			{Status: http.StatusOK, Description: "运行中", Body: health.Status{}},
+			{Status: http.StatusServiceUnavailable, Description: "正在排空", Body: health.Status{}},
+		},
+	}, func(c *gin.Context) {
+		c.JSON(lifecycle.Liveness())
+	})
+	// 就绪检查，Ollama 不可用或缺少必需模型时返回 503，适用于 Kubernetes readinessProbe
+	spec.Handle(root, http.MethodGet, "/readyz", openapi.Operation{
+		ID: "getReadiness", Summary: "就绪检查", Tag: "health",
+		Responses: []openapi.Response{
+			{Status: http.StatusOK, Description: "可以接收流量", Body: health.ReadyStatus{}},
+			{Status: http.StatusServiceUnavailable, Description: "Ollama 不可用、缺少必需模型或正在排空", Body: health.ReadyStatus{}},
+		},
+	}, func(c *gin.Context) {
+		c.JSON(readiness.Ready(i18n.FromRequest(c.Request)))
+	})
+	r.GET(SpecPath, spec.Handler())
+	r.GET(DocsPath, openapi.UIHandler(SpecPath))
+
 	// 全局中间件
-	r.Use(middleware.CorsMiddleware())
+	if cfg.Server.Metrics {
+		r.Use(middleware.MetricsMiddleware())
+		spec.Handle(root, http.MethodGet, metrics.Path, openapi.Operation{
+			ID: "getMetrics", Summary: "Prometheus 指标", Tag: "health",
+			Responses: []openapi.Response{{Status: http.StatusOK, Description: "Prometheus 文本格式", ContentType: "text/plain"}},
+		}, gin.WrapH(metrics.Handler()))
+	}
+	r.Use(middleware.RequestIDMiddleware())
+	if cfg.Tracing.Endpoint != "" {
+		r.Use(middleware.TracingMiddleware())
+	}
+	r.Use(middleware.CorsMiddleware(store))
 	r.Use(middleware.TrafficLoggingMiddleware(logger))
-	// r.Use(middleware.AuthMiddleware())
+	// r.Use(middleware.AuthMiddleware(store))
 
 	logger.Info("中间件已加载")
 
-	// WebSocket 插件路由组
-	wsGroup := r.Group("/ws")
+	// 按 server.plugins 挂载插件，内置的 websocket 插件提供 /ws
+	// 管理接口与 /ws 共用同一个 Hub
+	reg := deps.Plugins
+	if reg == nil {
+		reg = plugins.NewRegistry()
+	}
+	wsPlugin := websocket.NewPlugin(deps.Hub, deps.Capture, deps.Usage)
+	hub := wsPlugin.Hub()
+	reg.Add(wsPlugin)
+	reg.Mount(root, spec, store, logger)
+
+	// 公共 API，各路由组要求的角色在此登记
+	apiGroup := r.Group("/api",
+		middleware.RateLimitMiddleware(store, ratelimit.New(time.Minute), func(c config.RateLimitConfig) config.RateLimitRule { return c.API }),
+		middleware.AuthMiddleware(store), middleware.RequireRole(auth.RoleUser))
 	{
-		websocket.InitWebSocketPlugin(wsGroup, logger)
+		spec.Handle(apiGroup, http.MethodGet, "/version", openapi.Operation{
+			ID: "getVersion", Summary: "版本与构建信息", Tag: "api", Security: openapi.SecurityTenant,
+			Responses: []openapi.Response{
+				{Status: http.StatusOK, Description: "构建信息", Body: version.Info{}},
+				errorResponse(http.StatusUnauthorized, "Token 无效"),
+			},
+		}, func(c *gin.Context) {
+			c.JSON(http.StatusOK, version.Get())
+		})
+		if deps.Models != nil {
+			spec.Handle(apiGroup, http.MethodGet, "/models", openapi.Operation{
+				ID: "listModels", Summary: "列出 Ollama 上的模型", Tag: "api", Security: openapi.SecurityTenant,
+				Responses: []openapi.Response{
+					{Status: http.StatusOK, Description: "模型列表", Body: ModelsResponse{}},
+					errorResponse(http.StatusUnauthorized, "Token 无效"),
+					errorResponse(http.StatusServiceUnavailable, "Ollama 不可用"),
+				},
+			}, func(c *gin.Context) {
+				infos, err := deps.Models(c.Request.Context())
+				if err != nil {
+					middleware.AbortWithError(c, apperr.New(apperr.Backend, apperr.CodeBackendUnavailable, i18n.Tr(i18n.FromRequest(c.Request), i18n.ErrBackendUnavailable, err)))
+					return
+				}
+				c.JSON(http.StatusOK, ModelsResponse{Models: infos})
+			})
+		}
+		if deps.API != nil {
+			spec.Handle(apiGroup, http.MethodPost, "/chat", openapi.Operation{
+				ID: "chat", Summary: "对话，与 WebSocket 的 chat 动作相同", Tag: "api", Security: openapi.SecurityTenant,
+				Description: "stream 为 true 时以 text/event-stream 返回：chunk 事件的 data 为 ChatChunk，" +
+					"最后的 done 事件的 data 为 ChatResponse，生成中途出错时为 error 事件，data 为 ErrorResponse",
+				Body: handlers.ChatRequest{},
+				Responses: []openapi.Response{
+					{Status: http.StatusOK, Description: "完整回复", Body: handlers.ChatResponse{}, ContentType: "text/event-stream"},
+					errorResponse(http.StatusBadRequest, "参数无效或模型不存在"),
+					errorResponse(http.StatusUnauthorized, "Token 无效"),
+					errorResponse(http.StatusForbidden, "模型不在 bridge.policy_file 的允许列表中"),
+					errorResponse(http.StatusTooManyRequests, "超出 server.rate_limit 的限制，或当天用量已达 server.quota 的上限 (code 为 quota_exceeded)，Retry-After 为建议等待的秒数"),
+					errorResponse(http.StatusServiceUnavailable, "Ollama 调用失败"),
+					errorResponse(http.StatusGatewayTimeout, "Ollama 处理超时"),
+				},
+			}, middleware.QuotaMiddleware(store, deps.Usage), deps.API.Chat)
+			spec.Handle(apiGroup, http.MethodPost, "/embeddings", openapi.Operation{
+				ID: "embeddings", Summary: "计算文本向量，与 WebSocket 的 embed 动作相同", Tag: "api", Security: openapi.SecurityTenant,
+				Body: handlers.EmbeddingsRequest{},
+				Responses: []openapi.Response{
+					{Status: http.StatusOK, Description: "与 input 一一对应的向量", Body: handlers.EmbeddingsResponse{}},
+					errorResponse(http.StatusBadRequest, "参数无效或模型不存在"),
+					errorResponse(http.StatusUnauthorized, "Token 无效"),
+					errorResponse(http.StatusForbidden, "模型不在 bridge.policy_file 的允许列表中"),
+					errorResponse(http.StatusTooManyRequests, "超出 server.rate_limit 的限制，Retry-After 为建议等待的秒数"),
+					errorResponse(http.StatusServiceUnavailable, "Ollama 调用失败"),
+					errorResponse(http.StatusGatewayTimeout, "Ollama 处理超时"),
+				},
+			}, deps.API.Embeddings)
+		}
+		if deps.Usage != nil {
+			spec.Handle(apiGroup, http.MethodGet, "/usage", openapi.Operation{
+				ID: "getUsage", Summary: "调用方当天 (UTC) 的用量与配额", Tag: "api", Security: openapi.SecurityTenant,
+				Responses: []openapi.Response{
+					{Status: http.StatusOK, Description: "当天的对话次数、token 数、生成耗时与剩余额度", Body: UsageResponse{}},
+					errorResponse(http.StatusUnauthorized, "Token 无效"),
+				},
+			}, func(c *gin.Context) {
+				now := time.Now()
+				caller := usage.CallerOf(c.Request.Context())
+				sum, err := deps.Usage.Daily(caller, now)
+				if err != nil {
+					middleware.AbortWithError(c, apperr.Wrap(err, apperr.Internal, apperr.CodeInternal, "查询用量失败"))
+					return
+				}
+				c.JSON(http.StatusOK, UsageResponse{Usage: sum, Quota: usage.NewQuota(sum, store.Get().Server.Quota.For(caller.Tenant), now)})
+			})
+			// 只导出调用方所属租户的用量
+			spec.Handle(apiGroup, http.MethodGet, "/usage/export", openapi.Operation{
+				ID: "exportUsage", Summary: "导出调用方租户的 token 用量", Description: usageFormat, Tag: "api", Security: openapi.SecurityTenant,
+				Params:    usageParams,
+				Responses: usageResponses,
+			}, func(c *gin.Context) {
+				exportUsage(c, deps.Usage, tenant.FromContext(c.Request.Context()))
+			})
+		}
+	}
+
+	// OpenAI 兼容接口，鉴权与 /api 相同；接口以 OpenAI 的文档为准，不写入 OpenAPI 文档
+	if deps.OpenAI != nil {
+		deps.OpenAI.Register(r.Group("/v1",
+			middleware.RateLimitMiddleware(store, ratelimit.New(time.Minute), func(c config.RateLimitConfig) config.RateLimitRule { return c.OpenAI }),
+			middleware.AuthMiddleware(store), middleware.RequireRole(auth.RoleUser)),
+			middleware.QuotaMiddleware(store, deps.Usage))
+		logger.Info("OpenAI 兼容接口已启用，路径：/v1/")
+	}
+
+	// 管理接口，需管理员账号或带 admin 角色的 JWT
+	if cfg.Admin.Password != "" || cfg.Auth.JWT.Algorithm != "" {
+		adminGroup := r.Group("/admin", middleware.AdminAuthMiddleware(store))
+		adminOp := func(op openapi.Operation) openapi.Operation {
+			op.Tag, op.Security = "admin", openapi.SecurityAdmin
+			op.Responses = append(slices.Clone(op.Responses),
+				openapi.Response{Status: http.StatusUnauthorized, Description: "管理员账号或 JWT 无效"},
+				openapi.Response{Status: http.StatusForbidden, Description: "JWT 没有 admin 角色"})
+			return op
+		}
+		{
+			spec.Handle(adminGroup, http.MethodPost, "/reload", adminOp(openapi.Operation{
+				ID: "reloadConfig", Summary: "重新加载配置文件",
+				Responses: []openapi.Response{
+					{Status: http.StatusOK, Description: "已重新加载", Body: ReloadResponse{}},
+					errorResponse(http.StatusBadRequest, "配置无效，继续使用原配置"),
+				},
+			}), func(c *gin.Context) {
+				if err := store.Reload(); err != nil {
+					middleware.AbortWithError(c, apperr.New(apperr.Validation, apperr.CodeInvalidParams, i18n.Tr(i18n.FromRequest(c.Request), i18n.ErrReloadFailed, err)))
+					return
+				}
+				c.JSON(http.StatusOK, ReloadResponse{Status: "reloaded"})
+			})
+			spec.Handle(adminGroup, http.MethodGet, "/stats", adminOp(openapi.Operation{
+				ID: "getStats", Summary: "运行统计快照",
+				Responses: []openapi.Response{{Status: http.StatusOK, Description: "统计快照", Body: stats.Snapshot{}}},
+			}), gin.WrapH(stats.Handler()))
+			// 运行状态仪表盘，页面经 /admin/dashboard/ws 实时接收统计快照
+			dash := gin.WrapH(dashboard.Handler(logging.Component(logger, "dashboard")))
+			spec.Handle(adminGroup, http.MethodGet, "/dashboard", adminOp(openapi.Operation{
+				ID: "getDashboard", Summary: "运行状态仪表盘页面",
+				Responses: []openapi.Response{{Status: http.StatusOK, Description: "HTML 页面", ContentType: "text/html"}},
+			}), dash)
+			spec.Handle(adminGroup, http.MethodGet, "/dashboard/ws", adminOp(openapi.Operation{
+				ID: "connectDashboard", Summary: "实时接收统计快照的 WebSocket",
+				Responses: []openapi.Response{{Status: http.StatusSwitchingProtocols, Description: "切换到 WebSocket 协议"}},
+			}), dash)
+			spec.Handle(adminGroup, http.MethodGet, "/capture", adminOp(openapi.Operation{
+				ID: "downloadCapture", Summary: "下载抓包缓冲区中的帧",
+				Responses: []openapi.Response{
+					{Status: http.StatusOK, Description: "JSONL 附件", ContentType: "application/x-ndjson"},
+					{Status: http.StatusNotFound, Description: "未启用抓包"},
+				},
+			}), gin.WrapH(deps.Capture.Handler()))
+			if deps.Usage != nil {
+				// 导出全部租户的用量，?tenant= 可筛选单个租户
+				spec.Handle(adminGroup, http.MethodGet, "/usage/export", adminOp(openapi.Operation{
+					ID: "exportAllUsage", Summary: "导出全部租户的 token 用量", Description: usageFormat,
+					Params:    append([]openapi.Param{{Name: "tenant", In: "query", Description: "只导出该租户"}}, usageParams...),
+					Responses: usageResponses,
+				}), func(c *gin.Context) {
+					exportUsage(c, deps.Usage, c.Query("tenant"))
+				})
+			}
+			// 连接管理与公告依赖 /ws 的 Hub，websocket 插件未启用时不提供
+			if reg.Mounted(websocket.Name) {
+				spec.Handle(adminGroup, http.MethodGet, "/connections", adminOp(openapi.Operation{
+					ID: "listConnections", Summary: "/ws 的当前连接",
+					Responses: []openapi.Response{{Status: http.StatusOK, Description: "按建立时间排序的连接", Body: ConnectionsResponse{}}},
+				}), func(c *gin.Context) {
+					c.JSON(http.StatusOK, ConnectionsResponse{Connections: hub.Connections()})
+				})
+				spec.Handle(adminGroup, http.MethodDelete, "/connections/:id", adminOp(openapi.Operation{
+					ID: "kickConnection", Summary: "断开指定连接，连接收到 1008 关闭帧",
+					Params: []openapi.Param{{Name: "id", In: "path", Description: "连接 id，见 GET /admin/connections"}},
+					Responses: []openapi.Response{
+						{Status: http.StatusNoContent, Description: "已断开"},
+						errorResponse(http.StatusNotFound, "连接不存在或已断开"),
+					},
+				}), func(c *gin.Context) {
+					id := c.Param("id")
+					found, err := hub.Kick(c.Request.Context(), id)
+					if err != nil {
+						middleware.AbortWithError(c, apperr.Wrap(err, apperr.Timeout, apperr.CodeCancelled, "断开连接被取消"))
+						return
+					}
+					if !found {
+						middleware.AbortWithError(c, apperr.New(apperr.Validation, apperr.CodeNotFound, i18n.Tr(i18n.FromRequest(c.Request), i18n.ErrConnectionNotFound, id)))
+						return
+					}
+					logger.Info("管理员断开了连接", "id", id)
+					c.Status(http.StatusNoContent)
+				})
+				spec.Handle(adminGroup, http.MethodPost, "/broadcast", adminOp(openapi.Operation{
+					ID: "broadcastAnnouncement", Summary: "向全部连接或一个房间推送系统公告",
+					Description: "连接收到 type 为 system、action 为 announcement 的帧，data 为 {message, room, sent_at}",
+					Body:        BroadcastRequest{},
+					Responses: []openapi.Response{
+						{Status: http.StatusOK, Description: "已推送", Body: BroadcastResponse{}},
+						errorResponse(http.StatusBadRequest, "请求体无效"),
+					},
+				}), func(c *gin.Context) {
+					var body BroadcastRequest
+					if err := c.ShouldBindJSON(&body); err != nil || strings.TrimSpace(body.Message) == "" {
+						middleware.AbortWithError(c, apperr.New(apperr.Validation, apperr.CodeInvalidParams, i18n.Tr(i18n.FromRequest(c.Request), i18n.ErrInvalidAnnounce)))
+						return
+					}
+					n, err := hub.Announce(c.Request.Context(), body.Tenant, websocket.Announcement{Message: body.Message, Room: body.Room})
+					if err != nil {
+						middleware.AbortWithError(c, apperr.Wrap(err, apperr.Timeout, apperr.CodeCancelled, "推送公告被取消"))
+						return
+					}
+					logger.Info("管理员推送了公告", "room", body.Room, "tenant", body.Tenant, "delivered", n)
+					c.JSON(http.StatusOK, BroadcastResponse{Delivered: n})
+				})
+			}
+			spec.Handle(adminGroup, http.MethodGet, "/features", adminOp(openapi.Operation{
+				ID: "listFeatures", Summary: "功能开关状态",
+				Responses: []openapi.Response{{Status: http.StatusOK, Description: "开关名 -> 是否启用", Body: map[string]bool{}}},
+			}), func(c *gin.Context) {
+				c.JSON(http.StatusOK, flags.Snapshot())
+			})
+			spec.Handle(adminGroup, http.MethodPut, "/features/:name", adminOp(openapi.Operation{
+				ID: "setFeature", Summary: "运行时开启或关闭功能开关，重新加载配置后恢复为配置值",
+				Params: []openapi.Param{{Name: "name", In: "path", Description: "开关名", Enum: feature.Names()}},
+				Body:   FeatureUpdate{},
+				Responses: []openapi.Response{
+					{Status: http.StatusOK, Description: "修改后的全部开关", Body: map[string]bool{}},
+					errorResponse(http.StatusBadRequest, "请求体无效或开关不存在"),
+				},
+			}), func(c *gin.Context) {
+				var body FeatureUpdate
+				if err := c.ShouldBindJSON(&body); err != nil || body.Enabled == nil {
+					middleware.AbortWithError(c, apperr.New(apperr.Validation, apperr.CodeInvalidParams, i18n.Tr(i18n.FromRequest(c.Request), i18n.ErrInvalidFeatureBody)))
+					return
+				}
+				name := c.Param("name")
+				if err := flags.Set(name, *body.Enabled); err != nil {
+					middleware.AbortWithError(c, apperr.New(apperr.Validation, apperr.CodeUnknownFeature, i18n.Tr(i18n.FromRequest(c.Request), i18n.ErrUnknownFeature, name, feature.Names())))
+					return
+				}
+				logger.Info(i18n.T(i18n.LogFeatureChanged), "feature", name, "enabled", *body.Enabled)
+				c.JSON(http.StatusOK, flags.Snapshot())
+			})
+		}
+	}
+
+	// pprof 诊断路由，需管理员账号，不写入文档
+	if cfg.Server.Pprof {
+		debugGroup := r.Group("/debug", middleware.AdminAuthMiddleware(store))
+		{
+			debugGroup.Any("/pprof/*profile", gin.WrapH(debug.PprofHandler()))
+		}
+		logger.Info("pprof 已启用，路径：/debug/pprof/")
+	}
+	return spec
+}
+
+// Spec 返回全部 REST 接口的 OpenAPI 文档，包含需要可选组件 (用量、模型列表、对话) 与管理员账号的接口，
+// 供 openapi 命令生成文档与客户端；注册的处理器不会被调用
+func Spec() *openapi.Document {
+	cfg := config.Default()
+	cfg.Admin.Password = "spec"
+	cfg.Server.Pprof = false
+	r := gin.New()
+	_ = r.SetTrustedProxies(cfg.Server.TrustedProxies)
+	spec := SetupRoutes(slog.New(slog.DiscardHandler), r, config.NewStore("", cfg), Deps{
+		Lifecycle: health.NewLifecycle(),
+		Flags:     feature.New(cfg.Features),
+		Models:    func(context.Context) ([]models.Info, error) { return nil, nil },
+		API:       handlers.New(nil, nil, nil),
+		Usage:     new(usage.Store),
+	})
+	return spec.Document()
+}
+
+func errorResponse(status int, description string) openapi.Response {
+	return openapi.Response{Status: status, Description: description, Body: middleware.ErrorResponse{}}
+}
+
+var usageParams = []openapi.Param{
+	{Name: "from", In: "query", Description: "起始日期 YYYY-MM-DD，默认 30 天前"},
+	{Name: "to", In: "query", Description: "结束日期 YYYY-MM-DD，默认今天"},
+}
+
+// usageFormat 生成的客户端只解码 JSON，CSV 格式在说明中描述而不作为参数
+const usageFormat = "加上 ?format=csv 时以 CSV 附件返回"
+
+var usageResponses = []openapi.Response{
+	{Status: http.StatusOK, Description: "按天、租户、用户、模型聚合的用量", Body: UsageExport{}, ContentType: "text/csv"},
+	errorResponse(http.StatusBadRequest, "日期范围无效"),
+	errorResponse(http.StatusUnauthorized, "Token 无效"),
+}
+
+// exportUsage 按 ?from=&to= (YYYY-MM-DD，默认最近 30 天) 导出用量，?format=csv 时输出 CSV，否则输出 JSON
+func exportUsage(c *gin.Context, store *usage.Store, tenantID string) {
+	from, to, err := usage.ParseRange(c.Query("from"), c.Query("to"), time.Now())
+	if err != nil {
+		middleware.AbortWithError(c, apperr.New(apperr.Validation, apperr.CodeInvalidParams, i18n.Tr(i18n.FromRequest(c.Request), i18n.ErrInvalidDateRange)))
+		return
+	}
+	rows, err := store.Query(from, to, tenantID)
+	if err != nil {
+		middleware.AbortWithError(c, apperr.Wrap(err, apperr.Internal, apperr.CodeInternal, "查询用量失败"))
+		return
+	}
+	if c.Query("format") == "csv" {
+		c.Header("Content-Type", "text/csv; charset=utf-8")
+		c.Header("Content-Disposition", "attachment; filename=usage-"+from.Format(usage.DateLayout)+"-"+to.Format(usage.DateLayout)+".csv")
+		_ = usage.WriteCSV(c.Writer, rows)
+		return
+	}
+	if rows == nil {
+		rows = []usage.Row{}
 	}
+	c.JSON(http.StatusOK, UsageExport{
+		From: from.Format(usage.DateLayout),
+		To:   to.Format(usage.DateLayout),
+		Rows: rows,
+	})
 }
@@ -0,0 +1,52 @@
+package router
+
+import (
+	"ollama_dev/internal/models"
+	"ollama_dev/internal/plugins/websocket"
+	"ollama_dev/internal/usage"
+)
+
+// ModelsResponse /api/models 响应体
+type ModelsResponse struct {
+	Models []models.Info `json:"models"`
+}
+
+// UsageExport 用量导出的 JSON 响应体
+type UsageExport struct {
+	From string      `json:"from"` // YYYY-MM-DD
+	To   string      `json:"to"`
+	Rows []usage.Row `json:"rows"`
+}
+
+// UsageResponse GET /api/usage 响应体
+type UsageResponse struct {
+	Usage usage.Summary `json:"usage"`
+	Quota *usage.Quota  `json:"quota,omitempty"` // 未配置 server.quota 时省略
+}
+
+// ReloadResponse /admin/reload 响应体
+type ReloadResponse struct {
+	Status string `json:"status"` // reloaded
+}
+
+// FeatureUpdate PUT /admin/features/{name} 请求体
+type FeatureUpdate struct {
+	Enabled *bool `json:"enabled"`
+}
+
+// ConnectionsResponse GET /admin/connections 响应体
+type ConnectionsResponse struct {
+	Connections []websocket.ConnectionInfo `json:"connections"`
+}
+
+// BroadcastRequest POST /admin/broadcast 请求体
+type BroadcastRequest struct {
+	Message string `json:"message"`
+	Room    string `json:"room,omitempty"`   // 只推送给该房间的成员，为空时推送给全部连接
+	Tenant  string `json:"tenant,omitempty"` // 只推送给该租户，为空时推送给全部租户
+}
+
+// BroadcastResponse POST /admin/broadcast 响应体
+type BroadcastResponse struct {
+	Delivered int `json:"delivered"` // 送达的连接数，发送队列已满的连接不计入
+}
@@ -0,0 +1,163 @@
+package scheduler
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Schedule 计算任务的下一次运行时间
+type Schedule interface {
+	Next(after time.Time) time.Time
+}
+
+// every 固定间隔，对应 @every <duration>
+type every time.Duration
+
+func (e every) Next(after time.Time) time.Time {
+	return after.Add(time.Duration(e))
+}
+
+// cron 五段式 cron 表达式，各字段为允许取值的位图
+type cron struct {
+	minute, hour, dom, month, dow uint64
+	domStar, dowStar              bool // 日与周均受限（不以 * 开头）时按任一满足匹配，与标准 cron 一致
+}
+
+var descriptors = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+// Parse 解析 "分 时 日 月 周" 格式的 cron 表达式（支持 *、a-b、a,b、*/n），
+// 以及 @hourly、@daily 等别名和 @every <duration>，按本地时区计算
+func Parse(spec string) (Schedule, error) {
+	spec = strings.TrimSpace(spec)
+	if d, ok := strings.CutPrefix(spec, "@every "); ok {
+		dur, err := time.ParseDuration(strings.TrimSpace(d))
+		if err != nil || dur < time.Second {
+			return nil, fmt.Errorf("无效的间隔 %q，至少为 1s", d)
+		}
+		return every(dur), nil
+	}
+	if expanded, ok := descriptors[spec]; ok {
+		spec = expanded
+	}
+
+	fields := strings.Fields(spec)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("cron 表达式 %q 应包含 5 个字段 (分 时 日 月 周)", spec)
+	}
+	var c cron
+	var err error
+	if c.minute, err = parseField(fields[0], 0, 59); err != nil {
+		return nil, fmt.Errorf("分钟字段: %w", err)
+	}
+	if c.hour, err = parseField(fields[1], 0, 23); err != nil {
+		return nil, fmt.Errorf("小时字段: %w", err)
+	}
+	if c.dom, err = parseField(fields[2], 1, 31); err != nil {
+		return nil, fmt.Errorf("日期字段: %w", err)
+	}
+	if c.month, err = parseField(fields[3], 1, 12); err != nil {
+		return nil, fmt.Errorf("月份字段: %w", err)
+	}
+	if c.dow, err = parseField(fields[4], 0, 7); err != nil {
+		return nil, fmt.Errorf("星期字段: %w", err)
+	}
+	// 7 与 0 均表示周日
+	if c.dow&(1<<7) != 0 {
+		c.dow |= 1
+	}
+	c.domStar, c.dowStar = strings.HasPrefix(fields[2], "*"), strings.HasPrefix(fields[4], "*")
+	return &c, nil
+}
+
+// parseField 解析逗号分隔的取值列表，返回位图
+func parseField(field string, lo, hi int) (uint64, error) {
+	var set uint64
+	for part := range strings.SplitSeq(field, ",") {
+		rng, stepStr, hasStep := strings.Cut(part, "/")
+		step := 1
+		if hasStep {
+			n, err := strconv.Atoi(stepStr)
+			if err != nil || n <= 0 {
+				return 0, fmt.Errorf("无效的步长 %q", stepStr)
+			}
+			step = n
+		}
+		start, end := lo, hi
+		switch {
+		case rng == "*":
+		case strings.Contains(rng, "-"):
+			a, b, _ := strings.Cut(rng, "-")
+			var err error
+			if start, err = parseValue(a, lo, hi); err != nil {
+				return 0, err
+			}
+			if end, err = parseValue(b, lo, hi); err != nil {
+				return 0, err
+			}
+			if start > end {
+				return 0, fmt.Errorf("无效的范围 %q", rng)
+			}
+		default:
+			v, err := parseValue(rng, lo, hi)
+			if err != nil {
+				return 0, err
+			}
+			start, end = v, v
+			if hasStep {
+				end = hi
+			}
+		}
+		for v := start; v <= end; v += step {
+			set |= 1 << v
+		}
+	}
+	return set, nil
+}
+
+func parseValue(s string, lo, hi int) (int, error) {
+	v, err := strconv.Atoi(s)
+	if err != nil || v < lo || v > hi {
+		return 0, fmt.Errorf("取值 %q 应在 %d-%d 之间", s, lo, hi)
+	}
+	return v, nil
+}
+
+// Next 返回晚于 after 的第一个匹配时间（精确到分钟），五年内没有匹配时返回零值
+func (c *cron) Next(after time.Time) time.Time {
+	t := time.Date(after.Year(), after.Month(), after.Day(), after.Hour(), after.Minute()+1, 0, 0, after.Location())
+	limit := t.AddDate(5, 0, 0)
+	for t.Before(limit) {
+		switch {
+		case c.month&(1<<uint(t.Month())) == 0:
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, t.Location())
+		case !c.dayMatches(t):
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+		case c.hour&(1<<uint(t.Hour())) == 0:
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, t.Location())
+		case c.minute&(1<<uint(t.Minute())) == 0:
+			t = t.Add(time.Minute)
+		default:
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+func (c *cron) dayMatches(t time.Time) bool {
+	dom := c.dom&(1<<uint(t.Day())) != 0
+	dow := c.dow&(1<<uint(t.Weekday())) != 0
+	if c.domStar || c.dowStar {
+		return dom && dow
+	}
+	return dom || dow
+}
@@ -0,0 +1,46 @@
+package scheduler
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCronNext(t *testing.T) {
+	// 2024-05-01 为周三
+	base := time.Date(2024, 5, 1, 10, 7, 30, 0, time.UTC)
+	tests := []struct {
+		spec string
+		want time.Time
+	}{
+		{"* * * * *", time.Date(2024, 5, 1, 10, 8, 0, 0, time.UTC)},
+		{"*/15 * * * *", time.Date(2024, 5, 1, 10, 15, 0, 0, time.UTC)},
+		{"0 9-17/4 * * *", time.Date(2024, 5, 1, 13, 0, 0, 0, time.UTC)},
+		{"30 2 * * *", time.Date(2024, 5, 2, 2, 30, 0, 0, time.UTC)},
+		{"50 8 * * 1-5", time.Date(2024, 5, 2, 8, 50, 0, 0, time.UTC)},
+		{"0 0 * * 7", time.Date(2024, 5, 5, 0, 0, 0, 0, time.UTC)},
+		{"0 0 1,15 * *", time.Date(2024, 5, 15, 0, 0, 0, 0, time.UTC)},
+		// 日与周均受限时任一满足即可
+		{"0 0 31 * 5", time.Date(2024, 5, 3, 0, 0, 0, 0, time.UTC)},
+		{"0 0 29 2 *", time.Date(2028, 2, 29, 0, 0, 0, 0, time.UTC)},
+		{"@monthly", time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)},
+		{"@hourly", time.Date(2024, 5, 1, 11, 0, 0, 0, time.UTC)},
+		{"@every 90s", base.Add(90 * time.Second)},
+	}
+	for _, tt := range tests {
+		s, err := Parse(tt.spec)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.spec, err)
+		}
+		if got := s.Next(base); !got.Equal(tt.want) {
+			t.Errorf("%s: expected %s, got %s", tt.spec, tt.want, got)
+		}
+	}
+}
+
+func TestCronParseErrors(t *testing.T) {
+	for _, spec := range []string{"", "* * * *", "60 * * * *", "* 24 * * *", "* * 0 * *", "*/0 * * * *", "5-1 * * * *", "@every 10ms", "@every soon", "@sometimes"} {
+		if _, err := Parse(spec); err == nil {
+			t.Errorf("%q: expected an error", spec)
+		}
+	}
+}
@@ -0,0 +1,167 @@
+package scheduler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// DebugPath 任务状态在诊断端口上的路径
+const DebugPath = "/debug/schedule"
+
+// Func 任务函数，ctx 在调度器停止时取消
+type Func func(ctx context.Context) error
+
+// Status 任务的运行状态
+type Status struct {
+	Name       string    `json:"name"`
+	Task       string    `json:"task"`
+	Schedule   string    `json:"schedule"`
+	Next       time.Time `json:"next,omitzero"`
+	Running    bool      `json:"running"`
+	Runs       int64     `json:"runs"`
+	Failures   int64     `json:"failures"`
+	LastRun    time.Time `json:"last_run,omitzero"`
+	LastMs     float64   `json:"last_ms,omitempty"`
+	LastError  string    `json:"last_error,omitempty"`
+	LastFailed time.Time `json:"last_failed,omitzero"`
+}
+
+type job struct {
+	schedule Schedule
+	fn       Func
+	status   Status
+}
+
+// Scheduler 按 cron 表达式运行定时任务，同一任务上一次尚未结束时跳过本次
+type Scheduler struct {
+	mu   sync.Mutex
+	jobs []*job
+}
+
+// New 创建调度器
+func New() *Scheduler {
+	return &Scheduler{}
+}
+
+// Add 添加任务，spec 格式见 Parse，需在 Run 之前调用
+func (s *Scheduler) Add(name, task, spec string, fn Func) error {
+	schedule, err := Parse(spec)
+	if err != nil {
+		return fmt.Errorf("任务 %s: %w", name, err)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.jobs = append(s.jobs, &job{
+		schedule: schedule,
+		fn:       fn,
+		status:   Status{Name: name, Task: task, Schedule: spec},
+	})
+	return nil
+}
+
+// Run 运行调度循环，直到 ctx 结束
+func (s *Scheduler) Run(ctx context.Context, logger *slog.Logger) {
+	s.mu.Lock()
+	if len(s.jobs) == 0 {
+		s.mu.Unlock()
+		return
+	}
+	now := time.Now()
+	for _, j := range s.jobs {
+		j.status.Next = j.schedule.Next(now)
+	}
+	s.mu.Unlock()
+	logger.Info("定时任务已启动", "jobs", len(s.jobs))
+
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+	for {
+		timer.Reset(time.Until(s.nextWake()))
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-timer.C:
+			s.runDue(ctx, now, logger)
+		}
+	}
+}
+
+// nextWake 最早的下一次运行时间，没有待运行任务时一小时后再检查
+func (s *Scheduler) nextWake() time.Time {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	wake := time.Now().Add(time.Hour)
+	for _, j := range s.jobs {
+		if !j.status.Next.IsZero() && j.status.Next.Before(wake) {
+			wake = j.status.Next
+		}
+	}
+	return wake
+}
+
+// runDue 启动到期的任务并计算下一次运行时间
+func (s *Scheduler) runDue(ctx context.Context, now time.Time, logger *slog.Logger) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, j := range s.jobs {
+		if j.status.Next.IsZero() || j.status.Next.After(now) {
+			continue
+		}
+		j.status.Next = j.schedule.Next(now)
+		if j.status.Running {
+			logger.Warn("上一次运行尚未结束，跳过", "job", j.status.Name)
+			continue
+		}
+		j.status.Running = true
+		go s.run(ctx, j, logger)
+	}
+}
+
+func (s *Scheduler) run(ctx context.Context, j *job, logger *slog.Logger) {
+	start := time.Now()
+	err := j.fn(ctx)
+	elapsed := time.Since(start)
+
+	s.mu.Lock()
+	j.status.Running = false
+	j.status.Runs++
+	j.status.LastRun = start
+	j.status.LastMs = float64(elapsed.Microseconds()) / 1000
+	j.status.LastError = ""
+	if err != nil {
+		j.status.Failures++
+		j.status.LastError = err.Error()
+		j.status.LastFailed = start
+	}
+	s.mu.Unlock()
+
+	if err != nil {
+		logger.Error("定时任务失败", "job", j.status.Name, "error", err)
+		return
+	}
+	logger.Info("定时任务完成", "job", j.status.Name, "elapsed", elapsed.Round(time.Millisecond))
+}
+
+// Statuses 返回各任务的状态，按添加顺序
+func (s *Scheduler) Statuses() []Status {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := make([]Status, 0, len(s.jobs))
+	for _, j := range s.jobs {
+		out = append(out, j.status)
+	}
+	return out
+}
+
+// Handler 以 JSON 返回各任务的状态
+func (s *Scheduler) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]any{"jobs": s.Statuses()})
+	})
+}
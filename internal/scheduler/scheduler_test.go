@@ -0,0 +1,83 @@
+package scheduler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestSchedulerRunsJobsAndReportsStatus(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	s := New()
+	var ok, failed atomic.Int64
+	if err := s.Add("ok", "refresh_models", "@every 1s", func(ctx context.Context) error {
+		ok.Add(1)
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Add("broken", "warm_models", "@every 1s", func(ctx context.Context) error {
+		failed.Add(1)
+		return errors.New("model not found")
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Add("bad", "rotate_keys", "61 * * * *", nil); err == nil {
+		t.Fatal("expected an error for an invalid schedule")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go s.Run(ctx, logger)
+
+	deadline := time.Now().Add(5 * time.Second)
+	for ok.Load() < 2 || failed.Load() < 1 {
+		if time.Now().After(deadline) {
+			t.Fatalf("jobs did not run: ok=%d failed=%d", ok.Load(), failed.Load())
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+
+	rec := httptest.NewRecorder()
+	s.Handler().ServeHTTP(rec, httptest.NewRequest("GET", DebugPath, nil))
+	var body struct {
+		Jobs []Status `json:"jobs"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil || len(body.Jobs) != 2 {
+		t.Fatalf("unexpected status: %s (%v)", rec.Body, err)
+	}
+	if j := body.Jobs[0]; j.Name != "ok" || j.Runs < 2 || j.Failures != 0 || j.Next.IsZero() {
+		t.Errorf("unexpected status for ok: %+v", j)
+	}
+	if j := body.Jobs[1]; j.Failures < 1 || j.LastError != "model not found" {
+		t.Errorf("unexpected status for broken: %+v", j)
+	}
+}
+
+func TestSchedulerSkipsOverlappingRuns(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	s := New()
+	release := make(chan struct{})
+	var runs atomic.Int64
+	_ = s.Add("slow", "warm_models", "@every 1s", func(ctx context.Context) error {
+		runs.Add(1)
+		<-release
+		return nil
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go s.Run(ctx, logger)
+
+	// 第一次运行未结束前，后续到期的运行被跳过
+	time.Sleep(2500 * time.Millisecond)
+	if n := runs.Load(); n != 1 {
+		t.Errorf("expected a single run while the first is in progress, got %d", n)
+	}
+	close(release)
+}
@@ -0,0 +1,101 @@
+package script
+
+import (
+	"fmt"
+
+	lua "github.com/yuin/gopher-lua"
+)
+
+// maxDepth 转换的最大嵌套层数，避免自引用的 table 无限递归
+const maxDepth = 64
+
+// toLua 将 JSON 解码得到的值转换为 Lua 值，数组与对象均转换为 table
+func toLua(L *lua.LState, v any) (lua.LValue, error) {
+	switch v := v.(type) {
+	case nil:
+		return lua.LNil, nil
+	case bool:
+		return lua.LBool(v), nil
+	case float64:
+		return lua.LNumber(v), nil
+	case string:
+		return lua.LString(v), nil
+	case []any:
+		tbl := L.CreateTable(len(v), 0)
+		for _, item := range v {
+			lv, err := toLua(L, item)
+			if err != nil {
+				return nil, err
+			}
+			tbl.Append(lv)
+		}
+		return tbl, nil
+	case map[string]any:
+		tbl := L.CreateTable(0, len(v))
+		for k, item := range v {
+			lv, err := toLua(L, item)
+			if err != nil {
+				return nil, err
+			}
+			tbl.RawSetString(k, lv)
+		}
+		return tbl, nil
+	default:
+		return nil, fmt.Errorf("无法转换为 Lua 值: %T", v)
+	}
+}
+
+// fromLua 将 Lua 值转换为可 JSON 编码的值：键为 1..n 的 table 转换为数组，其余转换为对象，
+// 空 table 转换为 nil（JSON 的 null），函数等无法编码的值返回错误
+func fromLua(v lua.LValue, depth int) (any, error) {
+	if depth > maxDepth {
+		return nil, fmt.Errorf("table 嵌套超过 %d 层", maxDepth)
+	}
+	switch v := v.(type) {
+	case *lua.LNilType:
+		return nil, nil
+	case lua.LBool:
+		return bool(v), nil
+	case lua.LNumber:
+		return float64(v), nil
+	case lua.LString:
+		return string(v), nil
+	case *lua.LTable:
+		n := v.MaxN()
+		count := 0
+		v.ForEach(func(lua.LValue, lua.LValue) { count++ })
+		if count == 0 {
+			return nil, nil
+		}
+		if n == count {
+			out := make([]any, 0, n)
+			for i := 1; i <= n; i++ {
+				item, err := fromLua(v.RawGetInt(i), depth+1)
+				if err != nil {
+					return nil, err
+				}
+				out = append(out, item)
+			}
+			return out, nil
+		}
+		out := make(map[string]any, count)
+		var err error
+		v.ForEach(func(k, item lua.LValue) {
+			if err != nil {
+				return
+			}
+			key, ok := k.(lua.LString)
+			if !ok {
+				err = fmt.Errorf("对象的键应为字符串，实际为 %s", k.Type())
+				return
+			}
+			out[string(key)], err = fromLua(item, depth+1)
+		})
+		if err != nil {
+			return nil, err
+		}
+		return out, nil
+	default:
+		return nil, fmt.Errorf("无法转换 %s 类型的 Lua 值", v.Type())
+	}
+}
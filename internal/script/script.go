@@ -0,0 +1,389 @@
+// Package script 加载 Lua 脚本，在沙箱中运行请求钩子与自定义动作
+package script
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+
+	lua "github.com/yuin/gopher-lua"
+	"github.com/yuin/gopher-lua/parse"
+
+	"ollama_dev/internal/config"
+)
+
+// Request 传给脚本的请求，Params 为 JSON 解码后的值，before 钩子可以修改
+type Request struct {
+	Action    string
+	RequestID string
+	TenantID  string
+	Params    any
+}
+
+// Rejected before 钩子拒绝了请求
+type Rejected struct {
+	Script string
+	Reason string
+}
+
+func (e *Rejected) Error() string {
+	return fmt.Sprintf("脚本 %s 拒绝了请求: %s", e.Script, e.Reason)
+}
+
+// compiled 已编译的脚本
+type compiled struct {
+	name  string
+	proto *lua.FunctionProto
+}
+
+// state 一个 Lua 虚拟机及其中各脚本返回的 table，按脚本顺序
+type state struct {
+	L       *lua.LState
+	modules []*lua.LTable
+}
+
+// Runtime 已加载的脚本，虚拟机不能并发使用，每次调用从池中取出一个
+type Runtime struct {
+	scripts []compiled
+	timeout time.Duration
+	logger  *slog.Logger
+
+	before, after bool
+	actions       map[string]int // 动作 -> 定义它的脚本下标
+
+	mu   sync.Mutex
+	idle []*state
+}
+
+// Load 编译 cfg.Dir 中的 *.lua（按文件名排序），dir 为空时返回 nil；
+// 每个脚本应返回一个 table，可包含 before(req)、after(req, data) 与 actions = {name = function(req) ... end}
+func Load(cfg config.ScriptsConfig, logger *slog.Logger) (*Runtime, error) {
+	if cfg.Dir == "" {
+		return nil, nil
+	}
+	paths, err := filepath.Glob(filepath.Join(cfg.Dir, "*.lua"))
+	if err != nil {
+		return nil, err
+	}
+	if len(paths) == 0 {
+		return nil, fmt.Errorf("目录 %s 中没有 .lua 脚本", cfg.Dir)
+	}
+	slices.Sort(paths)
+
+	r := &Runtime{timeout: cfg.Timeout, logger: logger, actions: map[string]int{}}
+	for _, path := range paths {
+		proto, err := compile(path)
+		if err != nil {
+			return nil, err
+		}
+		r.scripts = append(r.scripts, compiled{name: filepath.Base(path), proto: proto})
+	}
+
+	// 用第一个虚拟机检查脚本的返回值，收集钩子与动作
+	st, err := r.newState()
+	if err != nil {
+		return nil, err
+	}
+	for i, m := range st.modules {
+		name := r.scripts[i].name
+		r.before = r.before || m.RawGetString("before").Type() == lua.LTFunction
+		r.after = r.after || m.RawGetString("after").Type() == lua.LTFunction
+		actions, ok := m.RawGetString("actions").(*lua.LTable)
+		if !ok {
+			continue
+		}
+		var errs []error
+		actions.ForEach(func(k, v lua.LValue) {
+			action, isString := k.(lua.LString)
+			switch {
+			case !isString || v.Type() != lua.LTFunction:
+				errs = append(errs, fmt.Errorf("脚本 %s: actions 的键应为动作名、值应为函数", name))
+			case r.hasAction(string(action)):
+				errs = append(errs, fmt.Errorf("脚本 %s: 动作 %s 已由 %s 定义", name, action, r.scripts[r.actions[string(action)]].name))
+			default:
+				r.actions[string(action)] = i
+			}
+		})
+		if err := errors.Join(errs...); err != nil {
+			st.L.Close()
+			return nil, err
+		}
+	}
+	r.put(st)
+	return r, nil
+}
+
+func compile(path string) (*lua.FunctionProto, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("读取脚本失败: %w", err)
+	}
+	defer f.Close()
+	chunk, err := parse.Parse(f, filepath.Base(path))
+	if err != nil {
+		return nil, fmt.Errorf("解析脚本失败: %w", err)
+	}
+	proto, err := lua.Compile(chunk, filepath.Base(path))
+	if err != nil {
+		return nil, fmt.Errorf("编译脚本失败: %w", err)
+	}
+	return proto, nil
+}
+
+// newState 创建沙箱虚拟机并执行全部脚本：只开放 base、table、string、math，
+// 移除加载代码与访问文件的函数，print 写入日志
+func (r *Runtime) newState() (*state, error) {
+	L := lua.NewState(lua.Options{SkipOpenLibs: true, CallStackSize: 256, RegistryMaxSize: 1 << 20})
+	for _, lib := range []struct {
+		name string
+		open lua.LGFunction
+	}{{lua.BaseLibName, lua.OpenBase}, {lua.TabLibName, lua.OpenTable}, {lua.StringLibName, lua.OpenString}, {lua.MathLibName, lua.OpenMath}} {
+		L.Push(L.NewFunction(lib.open))
+		L.Push(lua.LString(lib.name))
+		L.Call(1, 0)
+	}
+	for _, name := range []string{"dofile", "loadfile", "load", "loadstring", "require", "module", "getfenv", "setfenv", "collectgarbage"} {
+		L.SetGlobal(name, lua.LNil)
+	}
+	L.SetGlobal("print", L.NewFunction(func(L *lua.LState) int {
+		args := make([]any, 0, L.GetTop())
+		for i := 1; i <= L.GetTop(); i++ {
+			args = append(args, L.Get(i).String())
+		}
+		r.logger.Info("脚本输出", "message", fmt.Sprint(args...))
+		return 0
+	}))
+
+	st := &state{L: L}
+	for _, s := range r.scripts {
+		L.Push(L.NewFunctionFromProto(s.proto))
+		if err := r.callContext(context.Background(), L, s.name, 0, 1); err != nil {
+			L.Close()
+			return nil, err
+		}
+		m, ok := L.Get(-1).(*lua.LTable)
+		L.Pop(1)
+		if !ok {
+			L.Close()
+			return nil, fmt.Errorf("脚本 %s 应返回 table", s.name)
+		}
+		st.modules = append(st.modules, m)
+	}
+	return st, nil
+}
+
+// callContext 在 ctx 与 timeout 内调用栈上的函数
+func (r *Runtime) callContext(ctx context.Context, L *lua.LState, name string, nargs, nret int) error {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+	L.SetContext(ctx)
+	defer L.RemoveContext()
+	if err := L.PCall(nargs, nret, nil); err != nil {
+		if ctx.Err() != nil {
+			return fmt.Errorf("脚本 %s 超过时限 %s: %w", name, r.timeout, context.DeadlineExceeded)
+		}
+		return fmt.Errorf("脚本 %s 执行失败: %w", name, err)
+	}
+	return nil
+}
+
+// get 从池中取出虚拟机，没有空闲的时创建新的
+func (r *Runtime) get() (*state, error) {
+	r.mu.Lock()
+	if n := len(r.idle); n > 0 {
+		st := r.idle[n-1]
+		r.idle = r.idle[:n-1]
+		r.mu.Unlock()
+		return st, nil
+	}
+	r.mu.Unlock()
+	return r.newState()
+}
+
+func (r *Runtime) put(st *state) {
+	st.L.SetTop(0)
+	r.mu.Lock()
+	r.idle = append(r.idle, st)
+	r.mu.Unlock()
+}
+
+// use 取出虚拟机执行 fn，执行失败（拒绝请求除外）的虚拟机可能处于中断状态，直接丢弃
+func (r *Runtime) use(fn func(st *state) error) error {
+	st, err := r.get()
+	if err != nil {
+		return err
+	}
+	err = fn(st)
+	if rejected := (*Rejected)(nil); err != nil && !errors.As(err, &rejected) {
+		st.L.Close()
+		return err
+	}
+	r.put(st)
+	return err
+}
+
+// HasBefore 是否有脚本定义了 before 钩子
+func (r *Runtime) HasBefore() bool {
+	return r != nil && r.before
+}
+
+// HasAfter 是否有脚本定义了 after 钩子
+func (r *Runtime) HasAfter() bool {
+	return r != nil && r.after
+}
+
+// Actions 返回脚本定义的动作，按名称排序
+func (r *Runtime) Actions() []string {
+	if r == nil {
+		return nil
+	}
+	names := make([]string, 0, len(r.actions))
+	for name := range r.actions {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+func (r *Runtime) hasAction(action string) bool {
+	_, ok := r.actions[action]
+	return ok
+}
+
+// Defines 动作是否由脚本定义
+func (r *Runtime) Defines(action string) bool {
+	return r != nil && r.hasAction(action)
+}
+
+// Before 按脚本顺序调用 before(req)，钩子可以修改 req.params；返回字符串时拒绝请求，返回 *Rejected
+func (r *Runtime) Before(ctx context.Context, req *Request) error {
+	if !r.HasBefore() {
+		return nil
+	}
+	return r.use(func(st *state) error {
+		for i, m := range st.modules {
+			fn, ok := m.RawGetString("before").(*lua.LFunction)
+			if !ok {
+				continue
+			}
+			tbl, err := requestTable(st.L, req)
+			if err != nil {
+				return err
+			}
+			st.L.Push(fn)
+			st.L.Push(tbl)
+			if err := r.callContext(ctx, st.L, r.scripts[i].name, 1, 1); err != nil {
+				return err
+			}
+			ret := st.L.Get(-1)
+			st.L.Pop(1)
+			if reason, ok := ret.(lua.LString); ok {
+				return &Rejected{Script: r.scripts[i].name, Reason: string(reason)}
+			}
+			if req.Params, err = fromLua(tbl.RawGetString("params"), 0); err != nil {
+				return fmt.Errorf("脚本 %s: %w", r.scripts[i].name, err)
+			}
+		}
+		return nil
+	})
+}
+
+// After 按脚本顺序调用 after(req, data)，返回值替换 data，返回 nil 时保留（可能已被修改的）data
+func (r *Runtime) After(ctx context.Context, req *Request, data any) (any, error) {
+	if !r.HasAfter() {
+		return data, nil
+	}
+	err := r.use(func(st *state) error {
+		for i, m := range st.modules {
+			fn, ok := m.RawGetString("after").(*lua.LFunction)
+			if !ok {
+				continue
+			}
+			tbl, err := requestTable(st.L, req)
+			if err != nil {
+				return err
+			}
+			value, err := toLua(st.L, data)
+			if err != nil {
+				return err
+			}
+			st.L.Push(fn)
+			st.L.Push(tbl)
+			st.L.Push(value)
+			if err := r.callContext(ctx, st.L, r.scripts[i].name, 2, 1); err != nil {
+				return err
+			}
+			ret := st.L.Get(-1)
+			st.L.Pop(1)
+			if ret == lua.LNil {
+				ret = value
+			}
+			if data, err = fromLua(ret, 0); err != nil {
+				return fmt.Errorf("脚本 %s: %w", r.scripts[i].name, err)
+			}
+		}
+		return nil
+	})
+	return data, err
+}
+
+// Call 调用脚本定义的动作，返回值作为响应的 data
+func (r *Runtime) Call(ctx context.Context, req *Request) (any, error) {
+	i, ok := r.actions[req.Action]
+	if !ok {
+		return nil, fmt.Errorf("脚本未定义动作 %s", req.Action)
+	}
+	var data any
+	err := r.use(func(st *state) error {
+		fn := st.L.GetField(st.modules[i].RawGetString("actions"), req.Action)
+		tbl, err := requestTable(st.L, req)
+		if err != nil {
+			return err
+		}
+		st.L.Push(fn)
+		st.L.Push(tbl)
+		if err := r.callContext(ctx, st.L, r.scripts[i].name, 1, 1); err != nil {
+			return err
+		}
+		ret := st.L.Get(-1)
+		st.L.Pop(1)
+		if data, err = fromLua(ret, 0); err != nil {
+			return fmt.Errorf("脚本 %s: %w", r.scripts[i].name, err)
+		}
+		return nil
+	})
+	return data, err
+}
+
+// Close 关闭空闲的虚拟机
+func (r *Runtime) Close() {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, st := range r.idle {
+		st.L.Close()
+	}
+	r.idle = nil
+}
+
+// requestTable 将请求转换为 {action, request_id, tenant_id, params}
+func requestTable(L *lua.LState, req *Request) (*lua.LTable, error) {
+	params, err := toLua(L, req.Params)
+	if err != nil {
+		return nil, err
+	}
+	tbl := L.NewTable()
+	tbl.RawSetString("action", lua.LString(req.Action))
+	tbl.RawSetString("request_id", lua.LString(req.RequestID))
+	tbl.RawSetString("tenant_id", lua.LString(req.TenantID))
+	tbl.RawSetString("params", params)
+	return tbl, nil
+}
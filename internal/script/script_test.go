@@ -0,0 +1,189 @@
+package script
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"ollama_dev/internal/config"
+)
+
+// load 将 files 写入临时目录后加载
+func load(t *testing.T, files map[string]string) (*Runtime, error) {
+	t.Helper()
+	dir := t.TempDir()
+	for name, src := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(src), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return Load(config.ScriptsConfig{Dir: dir, Timeout: 200 * time.Millisecond}, slog.New(slog.NewTextHandler(io.Discard, nil)))
+}
+
+func TestLoadWithoutDirIsDisabled(t *testing.T) {
+	rt, err := Load(config.ScriptsConfig{}, nil)
+	if err != nil || rt != nil {
+		t.Fatalf("Load with empty dir = %v, %v", rt, err)
+	}
+	// 未启用时各方法可直接调用
+	if rt.HasBefore() || rt.HasAfter() || rt.Defines("x") || len(rt.Actions()) != 0 {
+		t.Error("nil runtime should report no hooks or actions")
+	}
+	if err := rt.Before(context.Background(), &Request{}); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestHooksRunInFileOrder(t *testing.T) {
+	rt, err := load(t, map[string]string{
+		"10-model.lua": `return {
+			before = function(req)
+				if req.params.model_name == nil then return "model_name 不能为空" end
+				req.params.model_name = string.lower(req.params.model_name)
+			end,
+		}`,
+		"20-tag.lua": `return {
+			before = function(req) req.params.model_name = req.params.model_name .. ":latest" end,
+			after = function(req, data) data.via = "script"; return nil end,
+		}`,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	req := &Request{Action: "chat", Params: map[string]any{"model_name": "Llama3", "stream": true}}
+	if err := rt.Before(context.Background(), req); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]any{"model_name": "llama3:latest", "stream": true}
+	if !reflect.DeepEqual(req.Params, want) {
+		t.Errorf("params = %v, want %v", req.Params, want)
+	}
+
+	data, err := rt.After(context.Background(), req, map[string]any{"content": "hi"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(data, map[string]any{"content": "hi", "via": "script"}) {
+		t.Errorf("data = %v", data)
+	}
+
+	var rejected *Rejected
+	err = rt.Before(context.Background(), &Request{Action: "chat", Params: map[string]any{}})
+	if !errors.As(err, &rejected) || rejected.Reason != "model_name 不能为空" || rejected.Script != "10-model.lua" {
+		t.Errorf("expected rejection from 10-model.lua, got %v", err)
+	}
+}
+
+func TestScriptAction(t *testing.T) {
+	rt, err := load(t, map[string]string{
+		"lookup.lua": `local users = {alice = "admin"}
+		return {actions = {lookup = function(req)
+			return {role = users[req.params.user], tenant = req.tenant_id, tags = {"a", "b"}}
+		end}}`,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !rt.Defines("lookup") || !reflect.DeepEqual(rt.Actions(), []string{"lookup"}) {
+		t.Fatalf("actions = %v", rt.Actions())
+	}
+	data, err := rt.Call(context.Background(), &Request{Action: "lookup", TenantID: "acme", Params: map[string]any{"user": "alice"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]any{"role": "admin", "tenant": "acme", "tags": []any{"a", "b"}}
+	if !reflect.DeepEqual(data, want) {
+		t.Errorf("data = %v, want %v", data, want)
+	}
+}
+
+func TestSandbox(t *testing.T) {
+	rt, err := load(t, map[string]string{
+		"probe.lua": `return {actions = {probe = function()
+			return {os = os == nil, io = io == nil, require = require == nil, load = load == nil, dofile = dofile == nil}
+		end}}`,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := rt.Call(context.Background(), &Request{Action: "probe"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, removed := range data.(map[string]any) {
+		if removed != true {
+			t.Errorf("%s is reachable from scripts", name)
+		}
+	}
+}
+
+func TestTimeoutStopsRunawayScript(t *testing.T) {
+	rt, err := load(t, map[string]string{
+		"loop.lua": `return {actions = {
+			spin = function() while true do end end,
+			ok = function() return "ok" end,
+		}}`,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := time.Now()
+	_, err = rt.Call(context.Background(), &Request{Action: "spin"})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected deadline exceeded, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("timeout was not enforced, took %s", elapsed)
+	}
+	// 中断的虚拟机被丢弃，后续调用不受影响
+	if data, err := rt.Call(context.Background(), &Request{Action: "ok"}); err != nil || data != "ok" {
+		t.Errorf("call after timeout = %v, %v", data, err)
+	}
+}
+
+func TestConcurrentCalls(t *testing.T) {
+	rt, err := load(t, map[string]string{
+		"double.lua": `return {actions = {double = function(req) return req.params.n * 2 end}}`,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var wg sync.WaitGroup
+	for i := range 20 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			data, err := rt.Call(context.Background(), &Request{Action: "double", Params: map[string]any{"n": float64(i)}})
+			if err != nil || data != float64(2*i) {
+				t.Errorf("double(%d) = %v, %v", i, data, err)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func TestLoadErrors(t *testing.T) {
+	cases := map[string]map[string]string{
+		"应返回 table": {"a.lua": `return 1`},
+		"已由 a.lua 定义": {
+			"a.lua": `return {actions = {x = function() end}}`,
+			"b.lua": `return {actions = {x = function() end}}`,
+		},
+		"解析脚本失败":     {"a.lua": `return {`},
+		"没有 .lua 脚本": {},
+	}
+	for want, files := range cases {
+		_, err := load(t, files)
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("expected error containing %q, got %v", want, err)
+		}
+	}
+}
@@ -0,0 +1,9 @@
+package service
+
+import "context"
+
+// DefaultName 默认的服务名称
+const DefaultName = "ollama_dev_bridge"
+
+// RunFunc 服务主体，ctx 在服务停止时取消
+type RunFunc func(ctx context.Context) error
@@ -0,0 +1,23 @@
+//go:build !windows
+
+package service
+
+import "errors"
+
+// ErrUnsupported 非 Windows 平台不支持服务管理，请使用 systemd 单元文件
+var ErrUnsupported = errors.New("仅支持 Windows，其它平台请使用 deploy/systemd 中的 systemd 单元文件")
+
+// Install 非 Windows 平台不支持
+func Install(name string, args ...string) error {
+	return ErrUnsupported
+}
+
+// Uninstall 非 Windows 平台不支持
+func Uninstall(name string) error {
+	return ErrUnsupported
+}
+
+// Run 非 Windows 平台不支持
+func Run(name string, fn RunFunc) error {
+	return ErrUnsupported
+}
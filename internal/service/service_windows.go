@@ -0,0 +1,133 @@
+//go:build windows
+
+package service
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"golang.org/x/sys/windows/svc"
+	"golang.org/x/sys/windows/svc/mgr"
+)
+
+// Install 将当前可执行文件注册为自动启动的 Windows 服务，args 为服务启动时的命令行参数
+func Install(name string, args ...string) error {
+	exe, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("获取可执行文件路径失败: %w", err)
+	}
+	exe, err = filepath.Abs(exe)
+	if err != nil {
+		return fmt.Errorf("获取可执行文件路径失败: %w", err)
+	}
+
+	m, err := mgr.Connect()
+	if err != nil {
+		return fmt.Errorf("连接服务管理器失败（需要管理员权限）: %w", err)
+	}
+	defer m.Disconnect()
+
+	if s, err := m.OpenService(name); err == nil {
+		s.Close()
+		return fmt.Errorf("服务 %s 已存在", name)
+	}
+
+	s, err := m.CreateService(name, exe, mgr.Config{
+		DisplayName: "ollama_dev bridge",
+		Description: "连接云端 WebSocket 并代理本地 Ollama 请求",
+		StartType:   mgr.StartAutomatic,
+	}, args...)
+	if err != nil {
+		return fmt.Errorf("创建服务失败: %w", err)
+	}
+	defer s.Close()
+
+	// 异常退出后自动重启
+	recovery := []mgr.RecoveryAction{
+		{Type: mgr.ServiceRestart, Delay: 5 * time.Second},
+		{Type: mgr.ServiceRestart, Delay: 30 * time.Second},
+	}
+	if err := s.SetRecoveryActions(recovery, uint32((24 * time.Hour).Seconds())); err != nil {
+		return fmt.Errorf("设置服务恢复策略失败: %w", err)
+	}
+	return nil
+}
+
+// Uninstall 删除 Windows 服务
+func Uninstall(name string) error {
+	m, err := mgr.Connect()
+	if err != nil {
+		return fmt.Errorf("连接服务管理器失败（需要管理员权限）: %w", err)
+	}
+	defer m.Disconnect()
+
+	s, err := m.OpenService(name)
+	if err != nil {
+		return fmt.Errorf("服务 %s 不存在: %w", name, err)
+	}
+	defer s.Close()
+
+	if err := s.Delete(); err != nil {
+		return fmt.Errorf("删除服务失败: %w", err)
+	}
+	return nil
+}
+
+// Run 在 Windows 服务控制管理器下运行 fn，收到停止或关机请求时取消 ctx
+func Run(name string, fn RunFunc) error {
+	isService, err := svc.IsWindowsService()
+	if err != nil {
+		return fmt.Errorf("检测服务环境失败: %w", err)
+	}
+	if !isService {
+		return fmt.Errorf("当前进程不是由服务控制管理器启动的，请使用 service install 安装后启动")
+	}
+	return svc.Run(name, &handler{run: fn})
+}
+
+// handler 实现 svc.Handler
+type handler struct {
+	run RunFunc
+}
+
+func (h *handler) Execute(args []string, requests <-chan svc.ChangeRequest, status chan<- svc.Status) (bool, uint32) {
+	const accepted = svc.AcceptStop | svc.AcceptShutdown
+
+	status <- svc.Status{State: svc.StartPending}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	done := make(chan error, 1)
+	go func() { done <- h.run(ctx) }()
+
+	status <- svc.Status{State: svc.Running, Accepts: accepted}
+
+	for {
+		select {
+		case err := <-done:
+			status <- svc.Status{State: svc.StopPending}
+			if err != nil {
+				// 非零退出码触发服务恢复策略
+				return false, 1
+			}
+			return false, 0
+		case req := <-requests:
+			switch req.Cmd {
+			case svc.Interrogate:
+				status <- req.CurrentStatus
+			case svc.Stop, svc.Shutdown:
+				status <- svc.Status{State: svc.StopPending}
+				cancel()
+				select {
+				case <-done:
+				case <-time.After(10 * time.Second):
+				}
+				return false, 0
+			}
+		}
+	}
+}
@@ -0,0 +1,60 @@
+package stats
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"text/tabwriter"
+	"time"
+)
+
+// Print 以表格形式输出统计快照
+func Print(w io.Writer, s Snapshot) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+
+	fmt.Fprintf(tw, "运行时长\t%s\n", s.Uptime)
+	fmt.Fprintf(tw, "Goroutine\t%d\n", s.Goroutines)
+
+	if c := s.Connections; c != nil {
+		fmt.Fprintln(tw)
+		fmt.Fprintf(tw, "连接数\t%d\n", c.Total)
+		fmt.Fprintf(tw, "待发送消息\t%d (单连接最多 %d / 容量 %d)\n", c.QueueDepth, c.MaxQueueDepth, c.QueueCapacity)
+		printCounts(tw, "房间", c.Rooms)
+		printCounts(tw, "租户", c.Tenants)
+	}
+
+	if len(s.Models) > 0 {
+		fmt.Fprintln(tw)
+		fmt.Fprintln(tw, "模型\t调用\t失败\t平均(ms)\t最大(ms)\t最近(ms)")
+		for _, name := range s.ModelNames() {
+			m := s.Models[name]
+			fmt.Fprintf(tw, "%s\t%d\t%d\t%.1f\t%.1f\t%.1f\n", name, m.Calls, m.Errors, m.AvgMs, m.MaxMs, m.LastMs)
+		}
+	}
+
+	if len(s.Errors) > 0 {
+		fmt.Fprintln(tw)
+		fmt.Fprintln(tw, "最近错误\t来源\t错误码\t信息")
+		for _, e := range s.Errors {
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", e.Time.Format(time.TimeOnly), e.Source, e.Code, e.Message)
+		}
+	}
+	return tw.Flush()
+}
+
+// printCounts 按名称排序输出各分组的连接数
+func printCounts(w io.Writer, title string, counts map[string]int) {
+	if len(counts) == 0 {
+		return
+	}
+	names := make([]string, 0, len(counts))
+	for name := range counts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	fmt.Fprintln(w)
+	fmt.Fprintf(w, "%s\t连接数\n", title)
+	for _, name := range names {
+		fmt.Fprintf(w, "%s\t%d\n", name, counts[name])
+	}
+}
@@ -0,0 +1,190 @@
+package stats
+
+import (
+	"encoding/json"
+	"net/http"
+	"runtime"
+	"sort"
+	"sync"
+	"time"
+
+	"ollama_dev/internal/metrics"
+)
+
+// 统计信息在管理接口与诊断端口上的路径
+const (
+	AdminPath = "/admin/stats"
+	DebugPath = "/debug/stats"
+)
+
+var startedAt = time.Now()
+
+// Connections WebSocket 连接统计
+type Connections struct {
+	Total         int            `json:"total"`
+	Rooms         map[string]int `json:"rooms,omitempty"`   // 房间/分组 -> 连接数
+	Tenants       map[string]int `json:"tenants,omitempty"` // 租户 -> 连接数
+	QueueDepth    int            `json:"queue_depth"`       // 所有连接待发送消息总数
+	MaxQueueDepth int            `json:"max_queue_depth"`   // 单个连接的最大待发送消息数
+	QueueCapacity int            `json:"queue_capacity"`    // 单个连接的队列容量
+	Disconnects   int64          `json:"disconnects"`       // 启动以来断开的连接数
+}
+
+// ModelLatency 单个模型的调用耗时统计
+type ModelLatency struct {
+	Calls  int64   `json:"calls"`
+	Errors int64   `json:"errors"`
+	AvgMs  float64 `json:"avg_ms"`
+	MaxMs  float64 `json:"max_ms"`
+	LastMs float64 `json:"last_ms"`
+}
+
+// ErrorEvent 最近发生的一次错误
+type ErrorEvent struct {
+	Time    time.Time `json:"time"`
+	Source  string    `json:"source"` // 例如 "GET /api/models" 或 "bridge chat"
+	Code    string    `json:"code"`
+	Message string    `json:"message"`
+}
+
+// maxErrors 保留的最近错误条数
+const maxErrors = 20
+
+// Snapshot 进程运行统计快照
+type Snapshot struct {
+	Uptime      string                  `json:"uptime"`
+	Goroutines  int                     `json:"goroutines"`
+	Connections *Connections            `json:"connections,omitempty"` // 未注册连接来源时为空
+	Models      map[string]ModelLatency `json:"models,omitempty"`
+	Errors      []ErrorEvent            `json:"errors,omitempty"` // 最近的错误，最新的在前
+	ErrorsTotal int64                   `json:"errors_total"`     // 启动以来的错误总数
+}
+
+var (
+	mu           sync.Mutex
+	connections  func() Connections
+	models       = map[string]*modelState{}
+	recentErrors []ErrorEvent
+	errorsTotal  int64
+)
+
+// Prometheus 指标：模型调用耗时与 WebSocket 连接数
+var modelDuration = metrics.NewHistogram("ollama_dev_ollama_request_duration_seconds",
+	"Ollama 调用耗时，result 为 ok 或 error", metrics.DefBuckets, "model", "result")
+
+func init() {
+	metrics.NewGaugeFunc("ollama_dev_ws_connections", "serve 当前的 WebSocket 连接数", func() float64 {
+		mu.Lock()
+		src := connections
+		mu.Unlock()
+		if src == nil {
+			return 0
+		}
+		return float64(src().Total)
+	})
+}
+
+type modelState struct {
+	calls, errors int64
+	total, max    time.Duration
+	last          time.Duration
+}
+
+// RegisterConnections 注册连接统计来源，后注册的覆盖先注册的
+func RegisterConnections(src func() Connections) {
+	mu.Lock()
+	defer mu.Unlock()
+	connections = src
+}
+
+// ObserveModel 记录一次模型调用的耗时
+func ObserveModel(model string, d time.Duration, err error) {
+	result := "ok"
+	if err != nil {
+		result = "error"
+	}
+	modelDuration.Observe(d.Seconds(), model, result)
+	mu.Lock()
+	defer mu.Unlock()
+	m, ok := models[model]
+	if !ok {
+		m = &modelState{}
+		models[model] = m
+	}
+	m.calls++
+	if err != nil {
+		m.errors++
+	}
+	m.total += d
+	m.last = d
+	if d > m.max {
+		m.max = d
+	}
+}
+
+// RecordError 记录一次返回给调用方的错误，只保留最近 maxErrors 条
+func RecordError(source, code, message string) {
+	mu.Lock()
+	defer mu.Unlock()
+	errorsTotal++
+	if len(recentErrors) == maxErrors {
+		recentErrors = recentErrors[1:]
+	}
+	recentErrors = append(recentErrors, ErrorEvent{Time: time.Now(), Source: source, Code: code, Message: message})
+}
+
+// Get 返回当前统计快照
+func Get() Snapshot {
+	mu.Lock()
+	src := connections
+	snap := Snapshot{
+		Uptime:      time.Since(startedAt).Round(time.Second).String(),
+		Goroutines:  runtime.NumGoroutine(),
+		ErrorsTotal: errorsTotal,
+	}
+	if len(models) > 0 {
+		snap.Models = make(map[string]ModelLatency, len(models))
+		for name, m := range models {
+			snap.Models[name] = ModelLatency{
+				Calls:  m.calls,
+				Errors: m.errors,
+				AvgMs:  ms(m.total) / float64(m.calls),
+				MaxMs:  ms(m.max),
+				LastMs: ms(m.last),
+			}
+		}
+	}
+	for i := len(recentErrors) - 1; i >= 0; i-- {
+		snap.Errors = append(snap.Errors, recentErrors[i])
+	}
+	mu.Unlock()
+
+	// 在锁外调用来源，避免与来源内部的锁形成依赖
+	if src != nil {
+		c := src()
+		snap.Connections = &c
+	}
+	return snap
+}
+
+// ModelNames 返回按名称排序的模型列表
+func (s Snapshot) ModelNames() []string {
+	names := make([]string, 0, len(s.Models))
+	for name := range s.Models {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Handler 以 JSON 返回统计快照
+func Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(Get())
+	})
+}
+
+func ms(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000
+}
@@ -0,0 +1,56 @@
+package stats
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSnapshotAndPrint(t *testing.T) {
+	ObserveModel("llama3", 100*time.Millisecond, nil)
+	ObserveModel("llama3", 300*time.Millisecond, errors.New("boom"))
+	RegisterConnections(func() Connections {
+		return Connections{Total: 2, QueueDepth: 3, MaxQueueDepth: 2, QueueCapacity: 256}
+	})
+
+	s := Get()
+	m := s.Models["llama3"]
+	if m.Calls != 2 || m.Errors != 1 || m.AvgMs != 200 || m.MaxMs != 300 || m.LastMs != 300 {
+		t.Errorf("unexpected model latency: %+v", m)
+	}
+	if s.Connections == nil || s.Connections.Total != 2 {
+		t.Fatalf("unexpected connections: %+v", s.Connections)
+	}
+
+	var buf bytes.Buffer
+	if err := Print(&buf, s); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"连接数", "llama3", "200.0"} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("output missing %q:\n%s", want, buf.String())
+		}
+	}
+}
+
+func TestRecentErrors(t *testing.T) {
+	for i := range maxErrors + 5 {
+		RecordError("bridge chat", "timeout", fmt.Sprintf("第 %d 次", i))
+	}
+	// 只保留最近的 maxErrors 条，最新的在前
+	errs := Get().Errors
+	if len(errs) != maxErrors || errs[0].Message != fmt.Sprintf("第 %d 次", maxErrors+4) || errs[maxErrors-1].Message != "第 5 次" {
+		t.Fatalf("unexpected errors: %+v", errs)
+	}
+
+	var buf bytes.Buffer
+	if err := Print(&buf, Get()); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), "最近错误") || !strings.Contains(buf.String(), "timeout") {
+		t.Errorf("output missing recent errors:\n%s", buf.String())
+	}
+}
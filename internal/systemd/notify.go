@@ -0,0 +1,74 @@
+package systemd
+
+import (
+	"context"
+	"net"
+	"os"
+	"strconv"
+	"time"
+)
+
+// sd_notify 状态
+const (
+	StateReady    = "READY=1"
+	StateStopping = "STOPPING=1"
+	StateWatchdog = "WATCHDOG=1"
+)
+
+// Notify 向 $NOTIFY_SOCKET 发送状态，未在 systemd (Type=notify) 下运行时为空操作
+func Notify(state string) error {
+	socket := os.Getenv("NOTIFY_SOCKET")
+	if socket == "" {
+		return nil
+	}
+	// '@' 开头表示 Linux 抽象命名空间
+	if socket[0] == '@' {
+		socket = "\x00" + socket[1:]
+	}
+
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: socket, Net: "unixgram"})
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	_, err = conn.Write([]byte(state))
+	return err
+}
+
+// WatchdogInterval 返回 systemd 配置的看门狗超时，未启用时返回 0
+func WatchdogInterval() time.Duration {
+	usec, err := strconv.ParseInt(os.Getenv("WATCHDOG_USEC"), 10, 64)
+	if err != nil || usec <= 0 {
+		return 0
+	}
+	// WATCHDOG_PID 存在时只有对应进程需要发送看门狗信号
+	if pid := os.Getenv("WATCHDOG_PID"); pid != "" && pid != strconv.Itoa(os.Getpid()) {
+		return 0
+	}
+	return time.Duration(usec) * time.Microsecond
+}
+
+// StartWatchdog 以超时时间的一半为间隔发送 WATCHDOG=1，直到 ctx 结束。
+// healthy 不为 nil 且返回 false 时跳过本次信号，让 systemd 在超时后重启服务
+func StartWatchdog(ctx context.Context, healthy func() bool) {
+	interval := WatchdogInterval() / 2
+	if interval <= 0 {
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if healthy == nil || healthy() {
+					_ = Notify(StateWatchdog)
+				}
+			}
+		}
+	}()
+}
@@ -0,0 +1,69 @@
+package tenant
+
+import (
+	"context"
+	"crypto/subtle"
+	"net/http"
+	"strings"
+
+	"ollama_dev/internal/config"
+)
+
+// Default 未配置多租户或使用 auth.token 时所属的租户
+const Default = "default"
+
+// Resolve 按 Token 识别租户，Token 无效时返回 false
+func Resolve(auth config.AuthConfig, token string) (string, bool) {
+	if token == "" {
+		return "", false
+	}
+	for _, t := range auth.Tenants {
+		if equal(t.Token, token) {
+			return t.ID, true
+		}
+	}
+	if equal(auth.Token, token) {
+		return Default, true
+	}
+	return "", false
+}
+
+// FromRequest 识别请求所属的租户：auth.token 与多租户均未配置时均为 Default，否则要求携带有效 Token
+func FromRequest(auth config.AuthConfig, r *http.Request) (string, bool) {
+	if Anonymous(auth) {
+		return Default, true
+	}
+	return Resolve(auth, Token(r))
+}
+
+// Anonymous 是否允许不带 Token 的请求：仅在 auth.token 与多租户均未配置时允许
+func Anonymous(auth config.AuthConfig) bool {
+	return auth.Token == "" && len(auth.Tenants) == 0
+}
+
+// Token 从 Authorization: Bearer 头读取 Token，浏览器 WebSocket 无法设置请求头时可使用 ?token= 参数
+func Token(r *http.Request) string {
+	if token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok {
+		return token
+	}
+	return r.URL.Query().Get("token")
+}
+
+func equal(a, b string) bool {
+	return a != "" && subtle.ConstantTimeCompare([]byte(a), []byte(b)) == 1
+}
+
+type contextKey struct{}
+
+// WithTenant 将租户写入 ctx
+func WithTenant(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, contextKey{}, id)
+}
+
+// FromContext 读取 ctx 中的租户，未设置时返回 Default
+func FromContext(ctx context.Context) string {
+	if id, ok := ctx.Value(contextKey{}).(string); ok {
+		return id
+	}
+	return Default
+}
@@ -0,0 +1,66 @@
+package tenant
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+
+	"ollama_dev/internal/config"
+)
+
+func TestResolve(t *testing.T) {
+	auth := config.AuthConfig{
+		Token:   "shared",
+		Tenants: []config.TenantConfig{{ID: "acme", Token: "acme-token"}},
+	}
+	cases := []struct {
+		token string
+		want  string
+		ok    bool
+	}{
+		{"acme-token", "acme", true},
+		{"shared", Default, true},
+		{"wrong", "", false},
+		{"", "", false},
+	}
+	for _, c := range cases {
+		got, ok := Resolve(auth, c.token)
+		if got != c.want || ok != c.ok {
+			t.Errorf("Resolve(%q) = %q, %v; want %q, %v", c.token, got, ok, c.want, c.ok)
+		}
+	}
+}
+
+func TestFromRequest(t *testing.T) {
+	// auth.token 与多租户均未配置时不要求 Token
+	r := httptest.NewRequest("GET", "/ws", nil)
+	if id, ok := FromRequest(config.AuthConfig{}, r); !ok || id != Default {
+		t.Errorf("expected anonymous default tenant without credentials configured, got %q %v", id, ok)
+	}
+	// 配置了 auth.token 时即使没有多租户也要求 Token
+	if _, ok := FromRequest(config.AuthConfig{Token: "shared"}, r); ok {
+		t.Error("expected request without token to be rejected when auth.token is set")
+	}
+
+	auth := config.AuthConfig{Token: "shared", Tenants: []config.TenantConfig{{ID: "acme", Token: "acme-token"}}}
+	if _, ok := FromRequest(auth, r); ok {
+		t.Error("expected request without token to be rejected")
+	}
+	r.Header.Set("Authorization", "Bearer acme-token")
+	if id, ok := FromRequest(auth, r); !ok || id != "acme" {
+		t.Errorf("expected acme from header, got %q %v", id, ok)
+	}
+	r = httptest.NewRequest("GET", "/ws?token=acme-token", nil)
+	if id, ok := FromRequest(auth, r); !ok || id != "acme" {
+		t.Errorf("expected acme from query, got %q %v", id, ok)
+	}
+}
+
+func TestContext(t *testing.T) {
+	if id := FromContext(context.Background()); id != Default {
+		t.Errorf("expected default tenant, got %q", id)
+	}
+	if id := FromContext(WithTenant(context.Background(), "acme")); id != "acme" {
+		t.Errorf("expected acme, got %q", id)
+	}
+}
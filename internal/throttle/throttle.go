@@ -0,0 +1,140 @@
+package throttle
+
+import (
+	"context"
+	"io"
+	"sync"
+	"time"
+)
+
+// Limiter 按字节计的令牌桶，每秒补充 rate 个令牌，最多积累 1 秒；
+// 令牌可透支，透支后 Wait 阻塞到余额恢复为正。为 nil 时不限速
+type Limiter struct {
+	mu     sync.Mutex
+	rate   float64
+	tokens float64
+	last   time.Time
+	now    func() time.Time
+	sleep  func(ctx context.Context, d time.Duration) error
+}
+
+// New 创建每秒 rate 字节的限速器，rate <= 0 时返回 nil（不限速）
+func New(rate int64) *Limiter {
+	if rate <= 0 {
+		return nil
+	}
+	return &Limiter{rate: float64(rate), tokens: float64(rate), last: time.Now(), now: time.Now, sleep: sleep}
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// refill 按经过的时间补充令牌，调用方需持有锁
+func (l *Limiter) refill() {
+	now := l.now()
+	l.tokens += now.Sub(l.last).Seconds() * l.rate
+	l.tokens = min(l.tokens, l.rate)
+	l.last = now
+}
+
+// Take 消耗 n 个令牌，不等待
+func (l *Limiter) Take(n int) {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	l.refill()
+	l.tokens -= float64(n)
+	l.mu.Unlock()
+}
+
+// Wait 等待余额恢复为正
+func (l *Limiter) Wait(ctx context.Context) error {
+	if l == nil {
+		return nil
+	}
+	l.mu.Lock()
+	l.refill()
+	var d time.Duration
+	if l.tokens <= 0 {
+		d = time.Duration((1 - l.tokens) / l.rate * float64(time.Second))
+	}
+	l.mu.Unlock()
+	if d == 0 {
+		return nil
+	}
+	return l.sleep(ctx, d)
+}
+
+// WaitN 消耗 n 个令牌，透支时等待到余额恢复
+func (l *Limiter) WaitN(ctx context.Context, n int) error {
+	l.Take(n)
+	return l.Wait(ctx)
+}
+
+// Reader 按 l 限速读取 r
+func Reader(ctx context.Context, r io.Reader, l *Limiter) io.Reader {
+	if l == nil {
+		return r
+	}
+	return &reader{ctx: ctx, r: r, l: l}
+}
+
+type reader struct {
+	ctx context.Context
+	r   io.Reader
+	l   *Limiter
+}
+
+func (r *reader) Read(p []byte) (int, error) {
+	// 单次读取不超过 1 秒的配额，避免一次透支过多
+	if len(p) > int(r.l.rate) {
+		p = p[:int(r.l.rate)]
+	}
+	n, err := r.r.Read(p)
+	if n > 0 {
+		if werr := r.l.WaitN(r.ctx, n); werr != nil {
+			return n, werr
+		}
+	}
+	return n, err
+}
+
+// Writer 按 l 限速写入 w
+func Writer(ctx context.Context, w io.Writer, l *Limiter) io.Writer {
+	if l == nil {
+		return w
+	}
+	return &writer{ctx: ctx, w: w, l: l}
+}
+
+type writer struct {
+	ctx context.Context
+	w   io.Writer
+	l   *Limiter
+}
+
+func (w *writer) Write(p []byte) (int, error) {
+	written := 0
+	for len(p) > 0 {
+		chunk := p[:min(len(p), int(w.l.rate))]
+		if err := w.l.WaitN(w.ctx, len(chunk)); err != nil {
+			return written, err
+		}
+		n, err := w.w.Write(chunk)
+		written += n
+		if err != nil {
+			return written, err
+		}
+		p = p[len(chunk):]
+	}
+	return written, nil
+}
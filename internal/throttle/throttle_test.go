@@ -0,0 +1,90 @@
+package throttle
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeClock sleep 直接推进时间并记录总等待时长
+type fakeClock struct {
+	now   time.Time
+	slept time.Duration
+}
+
+func newTestLimiter(rate int64) (*Limiter, *fakeClock) {
+	clock := &fakeClock{now: time.Unix(0, 0)}
+	l := New(rate)
+	l.last = clock.now
+	l.now = func() time.Time { return clock.now }
+	l.sleep = func(ctx context.Context, d time.Duration) error {
+		clock.now = clock.now.Add(d)
+		clock.slept += d
+		return nil
+	}
+	return l, clock
+}
+
+func TestLimiterCapsThroughput(t *testing.T) {
+	l, clock := newTestLimiter(1000)
+	// 初始积累 1 秒的令牌，之后每 1000 字节需要 1 秒
+	for range 5 {
+		if err := l.WaitN(context.Background(), 1000); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if clock.slept < 4*time.Second || clock.slept > 4*time.Second+10*time.Millisecond {
+		t.Errorf("expected about 4s of waiting, got %s", clock.slept)
+	}
+}
+
+func TestLimiterRefillIsCapped(t *testing.T) {
+	l, clock := newTestLimiter(1000)
+	// 空闲一分钟后只积累 1 秒的令牌
+	clock.now = clock.now.Add(time.Minute)
+	l.WaitN(context.Background(), 3000)
+	if clock.slept < 2*time.Second {
+		t.Errorf("idle time should not build up more than one second of burst, slept %s", clock.slept)
+	}
+}
+
+func TestNilLimiterIsUnlimited(t *testing.T) {
+	l := New(0)
+	if l != nil {
+		t.Fatal("rate 0 should disable limiting")
+	}
+	if err := l.WaitN(context.Background(), 1<<30); err != nil {
+		t.Fatal(err)
+	}
+	r := strings.NewReader("hello")
+	if Reader(context.Background(), r, l) != io.Reader(r) {
+		t.Error("nil limiter should return the reader unchanged")
+	}
+}
+
+func TestReaderAndWriter(t *testing.T) {
+	l, clock := newTestLimiter(100)
+	data := bytes.Repeat([]byte("x"), 450)
+	var out bytes.Buffer
+	n, err := io.Copy(Writer(context.Background(), &out, l), Reader(context.Background(), bytes.NewReader(data), l))
+	if err != nil || n != 450 || !bytes.Equal(out.Bytes(), data) {
+		t.Fatalf("copy: n=%d err=%v", n, err)
+	}
+	// 读与写共用一个限速器，共 900 字节，扣除初始的 100 字节约需 8 秒
+	if clock.slept < 7*time.Second {
+		t.Errorf("expected throttled copy, slept %s", clock.slept)
+	}
+}
+
+func TestWaitHonorsContext(t *testing.T) {
+	l := New(1)
+	l.Take(100)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := l.Wait(ctx); err != context.Canceled {
+		t.Errorf("expected context.Canceled, got %v", err)
+	}
+}
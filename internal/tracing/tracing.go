@@ -0,0 +1,86 @@
+// Package tracing 基于 OpenTelemetry 的链路追踪，按 tracing 配置经 OTLP/HTTP 导出 span；
+// 未启用时全局 TracerProvider 为 no-op，Start 与 End 几乎没有开销
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+
+	"ollama_dev/internal/config"
+	"ollama_dev/internal/logging"
+	"ollama_dev/internal/version"
+)
+
+// tracerName 本项目创建 span 使用的 instrumentation scope
+const tracerName = "ollama_dev"
+
+// tracesPath endpoint 未指定路径时使用的 OTLP/HTTP 路径
+const tracesPath = "/v1/traces"
+
+// RequestIDKey span 上记录请求 ID 的属性，与日志中的 request_id 同名，可按同一个值查找日志与 span
+const RequestIDKey = attribute.Key(logging.RequestIDKey)
+
+// Setup 按配置创建 TracerProvider 并设为全局，同时按 W3C traceparent 传播上下文；
+// endpoint 为空时不做任何事。返回的函数在退出时调用，导出尚未发送的 span
+func Setup(cfg config.TracingConfig, command string) (func(context.Context) error, error) {
+	if cfg.Endpoint == "" {
+		return func(context.Context) error { return nil }, nil
+	}
+	u, err := url.Parse(cfg.Endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("解析 tracing.endpoint 失败: %w", err)
+	}
+	if u.Path == "" || u.Path == "/" {
+		u.Path = tracesPath
+	}
+	exporter, err := otlptracehttp.New(context.Background(), otlptracehttp.WithEndpointURL(u.String()))
+	if err != nil {
+		return nil, fmt.Errorf("创建 OTLP 导出器失败: %w", err)
+	}
+	res := resource.NewSchemaless(
+		semconv.ServiceName(cfg.ServiceName),
+		semconv.ServiceVersion(version.Get().Version),
+		attribute.String("ollama_dev.command", command),
+	)
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(cfg.SampleRatio))),
+	)
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	return provider.Shutdown, nil
+}
+
+// Start 开始一个 span，ctx 中的请求 ID (logging.RequestID) 记为 request_id 属性；
+// 返回的 ctx 带有该 span 的 trace ID，之后经 *Context 方法输出的日志与 span 对应
+func Start(ctx context.Context, name string, kind trace.SpanKind, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	if id := logging.RequestID(ctx); id != "" {
+		attrs = append(attrs, RequestIDKey.String(id))
+	}
+	ctx, span := otel.Tracer(tracerName).Start(ctx, name, trace.WithSpanKind(kind), trace.WithAttributes(attrs...))
+	if sc := span.SpanContext(); sc.IsValid() {
+		ctx = logging.WithTraceID(ctx, sc.TraceID().String())
+	}
+	return ctx, span
+}
+
+// End 结束 span，err 不为 nil 时记录错误并将状态设为 Error
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
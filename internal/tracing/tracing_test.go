@@ -0,0 +1,67 @@
+package tracing
+
+import (
+	"context"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
+
+	"ollama_dev/internal/config"
+	"ollama_dev/internal/logging"
+)
+
+func TestStartRecordsRequestID(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	prev := otel.GetTracerProvider()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	t.Cleanup(func() { otel.SetTracerProvider(prev) })
+
+	ctx, span := Start(logging.WithRequestID(context.Background(), "req-1"), "test", trace.SpanKindInternal)
+	End(span, nil)
+
+	spans := recorder.Ended()
+	if len(spans) != 1 {
+		t.Fatalf("expected 1 span, got %d", len(spans))
+	}
+	var found bool
+	for _, attr := range spans[0].Attributes() {
+		if attr.Key == RequestIDKey && attr.Value.AsString() == "req-1" {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("request_id attribute missing: %v", spans[0].Attributes())
+	}
+	// 日志中的 trace_id 与 span 一致
+	if got, want := logging.TraceID(ctx), spans[0].SpanContext().TraceID().String(); got != want {
+		t.Errorf("trace id in ctx = %q, want %q", got, want)
+	}
+}
+
+func TestSetupDisabled(t *testing.T) {
+	prev := otel.GetTracerProvider()
+	provider := noop.NewTracerProvider()
+	otel.SetTracerProvider(provider)
+	t.Cleanup(func() { otel.SetTracerProvider(prev) })
+
+	shutdown, err := Setup(config.TracingConfig{}, "serve")
+	if err != nil {
+		t.Fatalf("Setup: %v", err)
+	}
+	if err := shutdown(context.Background()); err != nil {
+		t.Errorf("shutdown: %v", err)
+	}
+	// 未启用时不替换全局 TracerProvider，span 不记录，ctx 中不附加 trace ID
+	if otel.GetTracerProvider() != provider {
+		t.Errorf("Setup replaced the global provider")
+	}
+	ctx, span := Start(context.Background(), "noop", trace.SpanKindInternal)
+	End(span, nil)
+	if span.IsRecording() || logging.TraceID(ctx) != "" {
+		t.Errorf("expected no-op span, got recording=%v trace=%q", span.IsRecording(), logging.TraceID(ctx))
+	}
+}
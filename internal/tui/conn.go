@@ -0,0 +1,145 @@
+// Package tui 终端对话客户端：连接 serve 的 /ws，选择模型、流式显示回复、加入房间，
+// 同时作为 WebSocket 协议 (api/asyncapi.yaml) 的参考实现
+package tui
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/gorilla/websocket"
+
+	"ollama_dev/internal/auth"
+	"ollama_dev/internal/bridge"
+	"ollama_dev/internal/config"
+)
+
+// 重连的退避区间
+const (
+	minBackoff = time.Second
+	maxBackoff = 30 * time.Second
+)
+
+// connectedMsg 连接 (或重连) 成功
+type connectedMsg struct{}
+
+// disconnectedMsg 连接失败或断开，retry 后重连
+type disconnectedMsg struct {
+	err   error
+	retry time.Duration
+}
+
+// frameMsg 收到的一条完整消息 (已重组分片)
+type frameMsg struct {
+	data []byte
+}
+
+// Conn 断线自动重连的 WebSocket 连接，连接状态与收到的消息经 Events 送给界面
+type Conn struct {
+	url          string
+	auth         auth.Provider
+	header       http.Header
+	chunking     config.ChunkingConfig
+	maxFrameSize int
+	events       chan tea.Msg
+
+	mu sync.Mutex
+	ws *websocket.Conn
+}
+
+// NewConn 创建连接，p 在每次连接时附加凭据，调用 Run 后开始连接
+func NewConn(url string, p auth.Provider, origin string, chunking config.ChunkingConfig) *Conn {
+	header := make(http.Header)
+	if origin != "" {
+		header.Set("Origin", origin)
+	}
+	return &Conn{url: url, auth: p, header: header, chunking: chunking, maxFrameSize: chunking.MaxFrameSize, events: make(chan tea.Msg, 64)}
+}
+
+// Events 返回连接事件
+func (c *Conn) Events() <-chan tea.Msg {
+	return c.events
+}
+
+// Run 连接并读取消息，断开后以指数退避重连，直到 ctx 结束
+func (c *Conn) Run(ctx context.Context) {
+	backoff := minBackoff
+	for ctx.Err() == nil {
+		ws, _, err := auth.Dial(ctx, c.auth, c.url, c.header)
+		if err == nil {
+			backoff = minBackoff
+			c.setWS(ws)
+			c.emit(ctx, connectedMsg{})
+			err = c.read(ctx, ws)
+			c.setWS(nil)
+			ws.Close()
+		}
+		if ctx.Err() != nil {
+			return
+		}
+		c.emit(ctx, disconnectedMsg{err: err, retry: backoff})
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+		backoff = min(backoff*2, maxBackoff)
+	}
+}
+
+func (c *Conn) read(ctx context.Context, ws *websocket.Conn) error {
+	stop := context.AfterFunc(ctx, func() { ws.Close() })
+	defer stop()
+	reassembler := bridge.NewReassembler(c.chunking)
+	for {
+		_, frame, err := ws.ReadMessage()
+		if err != nil {
+			return err
+		}
+		msg, err := reassembler.Add(frame)
+		if err != nil || msg == nil {
+			continue
+		}
+		c.emit(ctx, frameMsg{data: msg})
+	}
+}
+
+func (c *Conn) emit(ctx context.Context, msg tea.Msg) {
+	select {
+	case c.events <- msg:
+	case <-ctx.Done():
+	}
+}
+
+func (c *Conn) setWS(ws *websocket.Conn) {
+	c.mu.Lock()
+	c.ws = ws
+	c.mu.Unlock()
+}
+
+// Send 序列化 v 并按帧大小限制分片发送，未连接时返回错误
+func (c *Conn) Send(v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	frames, err := bridge.SplitFrame(data, c.maxFrameSize)
+	if err != nil {
+		return err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.ws == nil {
+		return fmt.Errorf("未连接到服务器")
+	}
+	for _, f := range frames {
+		if err := c.ws.WriteMessage(websocket.TextMessage, f); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,704 @@
+package tui
+
+import (
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/bubbles/textinput"
+	"github.com/charmbracelet/bubbles/viewport"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/google/uuid"
+
+	"ollama_dev/internal/apperr"
+	"ollama_dev/internal/bridge"
+	"ollama_dev/internal/chat"
+	"ollama_dev/internal/plugins/websocket"
+)
+
+// streamWindow 流式对话授予桥接客户端的额度，消费过半后补充，与 chat 命令相同
+const streamWindow = 32
+
+// Sender 发送一条消息，由 Conn 实现
+type Sender interface {
+	Send(v any) error
+}
+
+// Options 界面的初始设置
+type Options struct {
+	Model string // 初始模型，为空时使用模型列表中的第一个
+	Room  string // 启动后加入的房间
+	User  string // 随请求上报的用户名，也用于在房间中显示
+
+	Transcript *chat.Transcript // 启动时导入的对话记录，可为 nil
+}
+
+// line 对话记录中的一行
+type line struct {
+	who  string // 用户名、模型名或空 (系统消息)
+	text string
+	err  bool
+}
+
+// request 本连接发出、尚未结束的请求
+type request struct {
+	action   string
+	line     int // 回复所在的行，-1 表示不显示
+	received bool
+	consumed int
+}
+
+var (
+	statusStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("252")).Background(lipgloss.Color("237")).Padding(0, 1)
+	okStyle     = lipgloss.NewStyle().Foreground(lipgloss.Color("42"))
+	badStyle    = lipgloss.NewStyle().Foreground(lipgloss.Color("203"))
+	whoStyle    = lipgloss.NewStyle().Bold(true).Foreground(lipgloss.Color("39"))
+	systemStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("244")).Italic(true)
+	cursorStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("212")).Bold(true)
+)
+
+// Model bubbletea 界面状态
+type Model struct {
+	conn   Sender
+	events <-chan tea.Msg
+	user   string
+
+	viewport viewport.Model
+	input    textinput.Model
+	width    int
+	ready    bool
+
+	connected bool
+	connErr   error
+	retry     time.Duration
+
+	model   string
+	models  []string
+	picking bool
+	cursor  int
+
+	room    string
+	members int
+
+	backend *bridge.BackendStatus
+	latency *bridge.LatencyStats
+
+	lines    []line
+	history  []bridge.ChatMessage
+	pending  map[string]*request
+	watching map[string]int // 房间其他成员的请求 -> 回复所在的行
+}
+
+// New 创建界面，events 为 Conn.Events()，可为 nil
+func New(conn Sender, events <-chan tea.Msg, opts Options) *Model {
+	input := textinput.New()
+	input.Placeholder = "输入消息，/help 查看命令"
+	input.Prompt = "> "
+	input.Focus()
+	m := &Model{
+		conn:     conn,
+		events:   events,
+		user:     opts.User,
+		model:    opts.Model,
+		room:     opts.Room,
+		viewport: viewport.New(80, 20),
+		input:    input,
+		width:    80,
+		pending:  map[string]*request{},
+		watching: map[string]int{},
+	}
+	if opts.Transcript != nil {
+		m.importTranscript(opts.Transcript)
+	}
+	return m
+}
+
+// Init 开始接收连接事件
+func (m *Model) Init() tea.Cmd {
+	return tea.Batch(textinput.Blink, m.waitEvent())
+}
+
+func (m *Model) waitEvent() tea.Cmd {
+	if m.events == nil {
+		return nil
+	}
+	return func() tea.Msg {
+		return <-m.events
+	}
+}
+
+// Update 处理按键、窗口大小与连接事件
+func (m *Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		m.resize(msg.Width, msg.Height)
+		return m, nil
+	case tea.KeyMsg:
+		return m.key(msg)
+	case connectedMsg:
+		m.onConnected()
+		return m, m.waitEvent()
+	case disconnectedMsg:
+		m.onDisconnected(msg)
+		return m, m.waitEvent()
+	case frameMsg:
+		m.onFrame(msg.data)
+		return m, m.waitEvent()
+	}
+	var cmd tea.Cmd
+	m.input, cmd = m.input.Update(msg)
+	return m, cmd
+}
+
+func (m *Model) resize(width, height int) {
+	m.width = width
+	m.viewport.Width = width
+	// 状态栏与输入框各占一行
+	m.viewport.Height = max(height-2, 1)
+	m.input.Width = max(width-len(m.input.Prompt)-1, 1)
+	m.ready = true
+	m.render()
+}
+
+func (m *Model) key(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	if msg.Type == tea.KeyCtrlC {
+		return m, tea.Quit
+	}
+	if m.picking {
+		m.pick(msg)
+		return m, nil
+	}
+	switch msg.Type {
+	case tea.KeyTab:
+		m.openPicker()
+		return m, nil
+	case tea.KeyPgUp, tea.KeyPgDown:
+		var cmd tea.Cmd
+		m.viewport, cmd = m.viewport.Update(msg)
+		return m, cmd
+	case tea.KeyEnter:
+		text := strings.TrimSpace(m.input.Value())
+		m.input.SetValue("")
+		if text == "" {
+			return m, nil
+		}
+		if strings.HasPrefix(text, "/") {
+			return m, m.command(text)
+		}
+		m.chat(text)
+		return m, nil
+	}
+	var cmd tea.Cmd
+	m.input, cmd = m.input.Update(msg)
+	return m, cmd
+}
+
+// command 执行以 / 开头的命令
+func (m *Model) command(text string) tea.Cmd {
+	name, arg, _ := strings.Cut(strings.TrimPrefix(text, "/"), " ")
+	arg = strings.TrimSpace(arg)
+	switch name {
+	case "quit", "exit":
+		return tea.Quit
+	case "model", "models":
+		if arg == "" {
+			m.openPicker()
+		} else {
+			m.setModel(arg)
+		}
+	case "join":
+		if arg == "" {
+			m.system("用法: /join <房间>", true)
+			break
+		}
+		m.room = arg
+		m.members = 0
+		m.sendRoom(websocket.RoomJoin, arg)
+	case "leave":
+		if m.room == "" {
+			m.system("当前未加入房间", true)
+			break
+		}
+		m.room = ""
+		m.members = 0
+		m.sendRoom(websocket.RoomLeave, "")
+	case "members":
+		if m.room == "" {
+			m.system("当前未加入房间", true)
+			break
+		}
+		m.sendRoom(websocket.RoomMembers, m.room)
+	case "clear":
+		m.lines = nil
+		m.history = nil
+		m.watching = map[string]int{}
+		for _, r := range m.pending {
+			r.line = -1
+		}
+		m.render()
+	case "export":
+		if arg == "" {
+			m.system("用法: /export <文件>", true)
+			break
+		}
+		if err := chat.NewTranscript(m.model, m.history).Save(arg); err != nil {
+			m.system(err.Error(), true)
+			break
+		}
+		m.system(fmt.Sprintf("已导出 %d 条消息到 %s", len(m.history), arg), false)
+	case "import":
+		if arg == "" {
+			m.system("用法: /import <文件>", true)
+			break
+		}
+		t, err := chat.LoadTranscript(arg)
+		if err != nil {
+			m.system(err.Error(), true)
+			break
+		}
+		m.importTranscript(t)
+	case "help":
+		m.system("/model [名称] 选择模型 (也可按 Tab)，/join <房间> 加入房间，/leave 离开房间，/members 查看房间成员，/export <文件> 导出对话，/import <文件> 导入对话，/clear 清空对话，/quit 退出", false)
+	default:
+		m.system(fmt.Sprintf("未知命令: /%s", name), true)
+	}
+	return nil
+}
+
+// importTranscript 以导出的对话替换当前历史并显示，之后的请求携带这些历史
+func (m *Model) importTranscript(t *chat.Transcript) {
+	m.history = t.Messages
+	if t.Model != "" {
+		m.model = t.Model
+	}
+	for _, msg := range t.Messages {
+		who := m.model
+		switch msg.Role {
+		case "user":
+			who = m.displayUser()
+		case "system":
+			who = "system"
+		}
+		m.add(line{who: who, text: msg.Content})
+	}
+	m.system(fmt.Sprintf("已导入 %d 条消息", len(t.Messages)), false)
+}
+
+func (m *Model) openPicker() {
+	if len(m.models) == 0 {
+		m.system("尚未获取到模型列表", true)
+		return
+	}
+	m.picking = true
+	m.cursor = 0
+	for i, name := range m.models {
+		if name == m.model {
+			m.cursor = i
+		}
+	}
+}
+
+func (m *Model) pick(msg tea.KeyMsg) {
+	switch msg.String() {
+	case "up", "k":
+		m.cursor = max(m.cursor-1, 0)
+	case "down", "j":
+		m.cursor = min(m.cursor+1, len(m.models)-1)
+	case "enter":
+		m.picking = false
+		m.setModel(m.models[m.cursor])
+	case "esc", "tab", "q":
+		m.picking = false
+	}
+}
+
+func (m *Model) setModel(name string) {
+	m.model = name
+	m.system("使用模型 "+name, false)
+}
+
+// chat 发送完整对话历史，回复按 request_id 流式写入同一行
+func (m *Model) chat(text string) {
+	if m.model == "" {
+		m.system("尚未选择模型", true)
+		return
+	}
+	m.history = append(m.history, bridge.ChatMessage{Role: "user", Content: text})
+	m.add(line{who: m.displayUser(), text: text})
+
+	req := &bridge.CloudRequest{V: bridge.ProtocolVersion, Type: bridge.TypeServerToClient, Action: "chat", RequestID: uuid.New().String(), User: m.user}
+	req.Params.ModelName = m.model
+	req.Params.Messages = m.history
+	req.Params.Stream = true
+	req.Params.Credits = streamWindow
+	idx := m.add(line{who: m.model})
+	if err := m.conn.Send(req); err != nil {
+		m.fail(idx, fmt.Errorf("发送请求失败: %w", err))
+		return
+	}
+	m.pending[req.RequestID] = &request{action: "chat", line: idx}
+}
+
+func (m *Model) sendRoom(action, room string) {
+	f := websocket.RoomFrame{V: bridge.ProtocolVersion, Type: websocket.TypeRoom, Action: action}
+	if room != "" {
+		f.Params = &websocket.RoomInfo{Room: room}
+		if action == websocket.RoomJoin {
+			f.Params.Name = m.user
+		}
+	}
+	if err := m.conn.Send(f); err != nil && m.connected {
+		m.system(fmt.Sprintf("发送房间请求失败: %v", err), true)
+	}
+}
+
+func (m *Model) listModels() {
+	req := &bridge.CloudRequest{V: bridge.ProtocolVersion, Type: bridge.TypeServerToClient, Action: "list_model", RequestID: uuid.New().String()}
+	if err := m.conn.Send(req); err == nil {
+		m.pending[req.RequestID] = &request{action: "list_model", line: -1}
+	}
+}
+
+// onConnected 重新获取模型列表，断线前已加入的房间重新加入
+func (m *Model) onConnected() {
+	m.connected = true
+	m.connErr = nil
+	m.system("已连接", false)
+	m.listModels()
+	if m.room != "" {
+		m.sendRoom(websocket.RoomJoin, m.room)
+	}
+}
+
+// onDisconnected 未结束的请求随连接失效，标记为失败
+func (m *Model) onDisconnected(msg disconnectedMsg) {
+	wasConnected := m.connected
+	m.connected = false
+	m.connErr = msg.err
+	m.retry = msg.retry
+	m.members = 0
+	for id, r := range m.pending {
+		if r.line >= 0 {
+			m.fail(r.line, fmt.Errorf("连接断开"))
+		}
+		delete(m.pending, id)
+	}
+	if wasConnected {
+		m.system(fmt.Sprintf("连接断开: %v", msg.err), true)
+	}
+	m.render()
+}
+
+func (m *Model) onFrame(data []byte) {
+	var head struct {
+		Type string `json:"type"`
+	}
+	if err := json.Unmarshal(data, &head); err != nil {
+		return
+	}
+	if head.Type == websocket.TypeRoom {
+		var f websocket.RoomFrame
+		if err := json.Unmarshal(data, &f); err == nil && f.Data != nil {
+			m.onRoom(f)
+		}
+		return
+	}
+
+	var env bridge.Envelope
+	if err := json.Unmarshal(data, &env); err != nil {
+		return
+	}
+	switch env.Type {
+	case bridge.TypeHeartbeat:
+		var params bridge.CloudParams
+		if json.Unmarshal(env.Params, &params) == nil {
+			if params.Backend != nil {
+				m.backend = params.Backend
+			}
+			if params.Latency != nil {
+				m.latency = params.Latency
+			}
+		}
+	case bridge.TypeServerToClient:
+		m.onRequest(&env)
+	case bridge.TypeClientToServer:
+		if bridge.Migrate(&env) != nil {
+			return
+		}
+		if r, ok := m.pending[env.RequestID]; ok {
+			m.onResponse(env.RequestID, r, &env)
+		} else if idx, ok := m.watching[env.RequestID]; ok {
+			m.onWatched(env.RequestID, idx, &env)
+		}
+	}
+	m.render()
+}
+
+// onRoom 更新房间人数并显示成员的加入与离开，只关心当前所在的房间
+func (m *Model) onRoom(f websocket.RoomFrame) {
+	switch f.Action {
+	case websocket.RoomMembers:
+		names := make([]string, 0, len(f.Data.MemberList))
+		for _, member := range f.Data.MemberList {
+			names = append(names, memberName(member))
+		}
+		m.system(fmt.Sprintf("房间 %s 的成员 (%d 人): %s", f.Data.Room, f.Data.Members, strings.Join(names, "、")), false)
+		return
+	case websocket.RoomJoin, websocket.RoomLeave:
+	default:
+		return
+	}
+	if f.Data.Room != m.room {
+		if f.Action == websocket.RoomLeave && m.room == "" {
+			m.system("已离开房间 "+f.Data.Room, false)
+		}
+		return
+	}
+	if m.members == 0 {
+		m.system(fmt.Sprintf("已加入房间 %s", f.Data.Room), false)
+	} else if f.Data.Member != nil {
+		verb := "加入"
+		if f.Action == websocket.RoomLeave {
+			verb = "离开"
+		}
+		m.system(fmt.Sprintf("%s %s了房间 %s，现有 %d 人", memberName(*f.Data.Member), verb, f.Data.Room, f.Data.Members), false)
+	} else if f.Data.Members != m.members {
+		m.system(fmt.Sprintf("房间 %s 现有 %d 人", f.Data.Room, f.Data.Members), false)
+	}
+	m.members = f.Data.Members
+	m.render()
+}
+
+// memberName 成员未设置名称时显示 Hub 分配的 id
+func memberName(member websocket.RoomMember) string {
+	return cmp.Or(member.Name, member.ID)
+}
+
+// onRequest 显示房间中其他成员发出的对话，Hub 广播回来的本连接的请求忽略
+func (m *Model) onRequest(env *bridge.Envelope) {
+	if _, ok := m.pending[env.RequestID]; ok || env.Action != "chat" || env.RequestID == "" {
+		return
+	}
+	var params bridge.CloudParams
+	if json.Unmarshal(env.Params, &params) != nil || len(params.Messages) == 0 {
+		return
+	}
+	who := env.User
+	if who == "" {
+		who = "匿名"
+	}
+	m.add(line{who: who, text: params.Messages[len(params.Messages)-1].Content})
+	m.watching[env.RequestID] = m.add(line{who: params.ModelName})
+}
+
+func (m *Model) onResponse(id string, r *request, env *bridge.Envelope) {
+	if env.Status == bridge.StatusDuplicate || env.Status == bridge.StatusQueued {
+		return
+	}
+	if env.Status == bridge.StatusError {
+		delete(m.pending, id)
+		if r.line >= 0 {
+			m.fail(r.line, responseError(env))
+		} else {
+			m.system(responseError(env).Error(), true)
+		}
+		return
+	}
+	switch r.action {
+	case "list_model":
+		var models []bridge.ModelInfo
+		if err := json.Unmarshal(env.Data, &models); err != nil {
+			m.system(fmt.Sprintf("解析模型列表失败: %v", err), true)
+			break
+		}
+		m.models = m.models[:0]
+		for _, model := range models {
+			m.models = append(m.models, model.Name)
+		}
+		if m.model == "" && len(m.models) > 0 {
+			m.setModel(m.models[0])
+		}
+	case "chat":
+		token := chatContent(env)
+		if env.Status == bridge.StatusStreaming {
+			r.received = true
+			m.appendText(r.line, token)
+			if r.consumed++; r.consumed >= streamWindow/2 {
+				credit := &bridge.CloudRequest{V: bridge.ProtocolVersion, Type: bridge.TypeServerToClient, Action: bridge.ActionCredit, RequestID: id}
+				credit.Params.Credits = r.consumed
+				_ = m.conn.Send(credit)
+				r.consumed = 0
+			}
+			return
+		}
+		// done 帧携带完整回复；不支持流式的旧版桥接客户端只发送这一帧
+		if !r.received {
+			m.appendText(r.line, token)
+		}
+		if r.line >= 0 {
+			m.history = append(m.history, bridge.ChatMessage{Role: "assistant", Content: m.lines[r.line].text})
+		}
+	}
+	if env.Status != bridge.StatusStreaming {
+		delete(m.pending, id)
+	}
+}
+
+// onWatched 显示房间中其他成员的请求的回复
+func (m *Model) onWatched(id string, idx int, env *bridge.Envelope) {
+	switch env.Status {
+	case bridge.StatusStreaming:
+		m.appendText(idx, chatContent(env))
+	case bridge.StatusError:
+		m.fail(idx, responseError(env))
+		delete(m.watching, id)
+	case bridge.StatusDuplicate, bridge.StatusQueued:
+	default:
+		if idx < len(m.lines) && m.lines[idx].text == "" {
+			m.appendText(idx, chatContent(env))
+		}
+		delete(m.watching, id)
+	}
+}
+
+func chatContent(env *bridge.Envelope) string {
+	var data struct {
+		Message bridge.ChatMessage `json:"message"`
+	}
+	_ = json.Unmarshal(env.Data, &data)
+	return data.Message.Content
+}
+
+func responseError(env *bridge.Envelope) error {
+	if env.Sealed != nil {
+		return apperr.New(apperr.Auth, apperr.CodeDecryptFailed, "收到加密的响应，终端界面不支持端到端加密")
+	}
+	var data bridge.ErrorData
+	if err := json.Unmarshal(env.Data, &data); err != nil {
+		return apperr.Wrap(err, apperr.Protocol, apperr.CodeBadFrame, "解析错误响应失败")
+	}
+	return apperr.FromData(data)
+}
+
+func (m *Model) displayUser() string {
+	if m.user == "" {
+		return "我"
+	}
+	return m.user
+}
+
+// add 追加一行并返回其下标
+func (m *Model) add(l line) int {
+	m.lines = append(m.lines, l)
+	m.render()
+	return len(m.lines) - 1
+}
+
+func (m *Model) system(text string, isErr bool) {
+	m.add(line{text: text, err: isErr})
+}
+
+func (m *Model) appendText(idx int, text string) {
+	if idx >= 0 && idx < len(m.lines) {
+		m.lines[idx].text += text
+	}
+}
+
+func (m *Model) fail(idx int, err error) {
+	if idx >= 0 && idx < len(m.lines) {
+		m.lines[idx].err = true
+		m.lines[idx].text += fmt.Sprintf(" [%v]", err)
+	}
+	m.render()
+}
+
+// render 重新生成对话记录，原本停在底部时保持在底部
+func (m *Model) render() {
+	bottom := m.viewport.AtBottom()
+	wrap := lipgloss.NewStyle().Width(m.width)
+	out := make([]string, 0, len(m.lines))
+	for _, l := range m.lines {
+		var s string
+		switch {
+		case l.who == "" && l.err:
+			s = badStyle.Render(l.text)
+		case l.who == "":
+			s = systemStyle.Render(l.text)
+		case l.err:
+			s = whoStyle.Render(l.who+":") + " " + badStyle.Render(l.text)
+		default:
+			s = whoStyle.Render(l.who+":") + " " + l.text
+		}
+		out = append(out, wrap.Render(s))
+	}
+	m.viewport.SetContent(strings.Join(out, "\n"))
+	if bottom {
+		m.viewport.GotoBottom()
+	}
+}
+
+// View 对话记录 (选择模型时为模型列表)、状态栏与输入框
+func (m *Model) View() string {
+	if !m.ready {
+		return "正在初始化...\n"
+	}
+	body := m.viewport.View()
+	if m.picking {
+		body = m.pickerView()
+	}
+	return body + "\n" + m.statusView() + "\n" + m.input.View()
+}
+
+func (m *Model) pickerView() string {
+	var b strings.Builder
+	b.WriteString(systemStyle.Render("选择模型 (↑/↓ 移动，Enter 确认，Esc 取消)") + "\n")
+	rows := max(m.viewport.Height-1, 1)
+	start := max(min(m.cursor-rows/2, len(m.models)-rows), 0)
+	for i := start; i < len(m.models) && i < start+rows; i++ {
+		if i == m.cursor {
+			b.WriteString(cursorStyle.Render("> "+m.models[i]) + "\n")
+		} else {
+			b.WriteString("  " + m.models[i] + "\n")
+		}
+	}
+	// 补齐高度，保持状态栏位置不变
+	for n := strings.Count(b.String(), "\n"); n < m.viewport.Height; n++ {
+		b.WriteString("\n")
+	}
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+// statusView 连接状态、模型、房间与桥接客户端的后端状态
+func (m *Model) statusView() string {
+	parts := make([]string, 0, 4)
+	switch {
+	case m.connected:
+		parts = append(parts, okStyle.Render("● 已连接"))
+	case m.connErr != nil:
+		parts = append(parts, badStyle.Render(fmt.Sprintf("○ %v，%s 后重连", m.connErr, m.retry)))
+	default:
+		parts = append(parts, badStyle.Render("○ 连接中"))
+	}
+	model := m.model
+	if model == "" {
+		model = "未选择"
+	}
+	parts = append(parts, "模型 "+model)
+	if m.room != "" {
+		parts = append(parts, fmt.Sprintf("房间 %s (%d 人)", m.room, m.members))
+	}
+	if m.backend != nil {
+		backend := okStyle.Render("后端正常")
+		if !m.backend.Healthy {
+			backend = badStyle.Render("后端不可用")
+		}
+		if m.latency != nil && m.latency.Samples > 0 {
+			backend += fmt.Sprintf(" %.0fms", m.latency.LastMs)
+		}
+		parts = append(parts, backend)
+	}
+	return statusStyle.Width(m.width).Render(strings.Join(parts, " │ "))
+}
@@ -0,0 +1,208 @@
+package tui
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+
+	"ollama_dev/internal/bridge"
+	"ollama_dev/internal/plugins/websocket"
+)
+
+// fakeConn 记录发送的消息
+type fakeConn struct {
+	sent []map[string]any
+	err  error
+}
+
+func (c *fakeConn) Send(v any) error {
+	if c.err != nil {
+		return c.err
+	}
+	data, _ := json.Marshal(v)
+	var m map[string]any
+	_ = json.Unmarshal(data, &m)
+	c.sent = append(c.sent, m)
+	return nil
+}
+
+func (c *fakeConn) last() map[string]any {
+	return c.sent[len(c.sent)-1]
+}
+
+func frame(t *testing.T, v any) tea.Msg {
+	t.Helper()
+	data, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return frameMsg{data: data}
+}
+
+func response(id, status string, data any) bridge.Envelope {
+	raw, _ := json.Marshal(data)
+	return bridge.Envelope{Type: bridge.TypeClientToServer, Action: "chat", RequestID: id, Status: status, Data: raw}
+}
+
+func token(s string) any {
+	return map[string]any{"message": bridge.ChatMessage{Role: "assistant", Content: s}}
+}
+
+func TestConnectListsModelsAndRejoinsRoom(t *testing.T) {
+	conn := &fakeConn{}
+	m := New(conn, nil, Options{Room: "dev"})
+	m.Update(connectedMsg{})
+
+	if len(conn.sent) != 2 || conn.sent[0]["action"] != "list_model" || conn.sent[1]["type"] != websocket.TypeRoom {
+		t.Fatalf("unexpected frames on connect: %v", conn.sent)
+	}
+	id := conn.sent[0]["request_id"].(string)
+	models := bridge.Envelope{Type: bridge.TypeClientToServer, Action: "list_model", RequestID: id, Status: bridge.StatusDone,
+		Data: json.RawMessage(`[{"model_name":"llama3"},{"model_name":"qwen2"}]`)}
+	m.Update(frame(t, models))
+	if m.model != "llama3" || len(m.models) != 2 {
+		t.Errorf("expected first model selected, got %q %v", m.model, m.models)
+	}
+
+	m.Update(frame(t, websocket.RoomFrame{Type: websocket.TypeRoom, Action: websocket.RoomJoin, Status: "done", Data: &websocket.RoomInfo{Room: "dev", Members: 2}}))
+	if m.members != 2 {
+		t.Errorf("expected 2 members, got %d", m.members)
+	}
+	if s := m.statusView(); !strings.Contains(s, "房间 dev (2 人)") || !strings.Contains(s, "模型 llama3") {
+		t.Errorf("unexpected status bar %q", s)
+	}
+
+	// 其他成员加入时显示其名称，成员列表显示为一行
+	m.Update(frame(t, websocket.RoomFrame{Type: websocket.TypeRoom, Action: websocket.RoomJoin, Status: "done",
+		Data: &websocket.RoomInfo{Room: "dev", Members: 3, Member: &websocket.RoomMember{ID: "b1", Name: "bob"}}}))
+	if got := m.lines[len(m.lines)-1].text; got != "bob 加入了房间 dev，现有 3 人" {
+		t.Errorf("unexpected presence line %q", got)
+	}
+	m.Update(frame(t, websocket.RoomFrame{Type: websocket.TypeRoom, Action: websocket.RoomMembers, Status: "done",
+		Data: &websocket.RoomInfo{Room: "dev", Members: 2, MemberList: []websocket.RoomMember{{ID: "a1", Name: "alice"}, {ID: "c1"}}}}))
+	if got := m.lines[len(m.lines)-1].text; got != "房间 dev 的成员 (2 人): alice、c1" || m.members != 3 {
+		t.Errorf("unexpected members line %q (members=%d)", got, m.members)
+	}
+}
+
+func TestStreamingChat(t *testing.T) {
+	conn := &fakeConn{}
+	m := New(conn, nil, Options{Model: "llama3"})
+	m.Update(connectedMsg{})
+	m.input.SetValue("你好")
+	m.Update(tea.KeyMsg{Type: tea.KeyEnter})
+
+	req := conn.last()
+	if req["action"] != "chat" || req["params"].(map[string]any)["stream"] != true {
+		t.Fatalf("unexpected request %v", req)
+	}
+	id := req["request_id"].(string)
+
+	// Hub 广播回来的本连接的请求不显示
+	m.Update(frame(t, bridge.Envelope{Type: bridge.TypeServerToClient, Action: "chat", RequestID: id, Params: json.RawMessage(`{"messages":[{"role":"user","content":"你好"}]}`)}))
+	for i := range streamWindow / 2 {
+		m.Update(frame(t, response(id, bridge.StatusStreaming, token(string(rune('a'+i))))))
+	}
+	if credit := conn.last(); credit["action"] != bridge.ActionCredit || credit["request_id"] != id {
+		t.Errorf("expected credit frame, got %v", credit)
+	}
+	m.Update(frame(t, response(id, bridge.StatusDone, token("abcdefghijklmnop"))))
+
+	if _, ok := m.pending[id]; ok {
+		t.Error("request still pending after done")
+	}
+	if len(m.history) != 2 || m.history[1].Content != "abcdefghijklmnop" {
+		t.Errorf("unexpected history %v", m.history)
+	}
+	if got := m.lines[len(m.lines)-1]; got.who != "llama3" || got.text != "abcdefghijklmnop" {
+		t.Errorf("unexpected reply line %+v", got)
+	}
+}
+
+func TestChatErrorAndDisconnect(t *testing.T) {
+	conn := &fakeConn{}
+	m := New(conn, nil, Options{Model: "llama3"})
+	m.Update(connectedMsg{})
+	m.input.SetValue("one")
+	m.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	id := conn.last()["request_id"].(string)
+	m.Update(frame(t, response(id, bridge.StatusError, bridge.ErrorData{Message: "模型不存在", Category: "not_found", Code: "model_not_found"})))
+	if l := m.lines[len(m.lines)-1]; !l.err || !strings.Contains(l.text, "模型不存在") {
+		t.Errorf("expected error line, got %+v", l)
+	}
+
+	// 断开时未结束的请求标记为失败
+	m.input.SetValue("two")
+	m.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	m.Update(disconnectedMsg{err: errors.New("EOF"), retry: 2 * time.Second})
+	if len(m.pending) != 0 {
+		t.Errorf("pending requests not cleared: %v", m.pending)
+	}
+	if s := m.statusView(); !strings.Contains(s, "2s 后重连") {
+		t.Errorf("unexpected status bar %q", s)
+	}
+}
+
+func TestRoomMembersConversation(t *testing.T) {
+	m := New(&fakeConn{}, nil, Options{Room: "dev"})
+	m.Update(connectedMsg{})
+	m.Update(frame(t, bridge.Envelope{Type: bridge.TypeServerToClient, Action: "chat", RequestID: "r1", User: "alice",
+		Params: json.RawMessage(`{"model_name":"qwen2","messages":[{"role":"user","content":"hi"}]}`)}))
+	m.Update(frame(t, response("r1", bridge.StatusStreaming, token("hel"))))
+	m.Update(frame(t, response("r1", bridge.StatusStreaming, token("lo"))))
+	m.Update(frame(t, response("r1", bridge.StatusDone, token("hello"))))
+
+	n := len(m.lines)
+	if q, a := m.lines[n-2], m.lines[n-1]; q.who != "alice" || q.text != "hi" || a.who != "qwen2" || a.text != "hello" {
+		t.Errorf("unexpected lines %+v %+v", q, a)
+	}
+	if len(m.watching) != 0 {
+		t.Errorf("watched request not cleared: %v", m.watching)
+	}
+	// 其他成员的对话不进入本连接的对话历史
+	if len(m.history) != 0 {
+		t.Errorf("unexpected history %v", m.history)
+	}
+}
+
+func TestPickerAndCommands(t *testing.T) {
+	conn := &fakeConn{}
+	m := New(conn, nil, Options{})
+	m.models = []string{"llama3", "qwen2"}
+	m.model = "llama3"
+
+	m.Update(tea.KeyMsg{Type: tea.KeyTab})
+	if !m.picking {
+		t.Fatal("tab did not open the picker")
+	}
+	m.Update(tea.KeyMsg{Type: tea.KeyDown})
+	m.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	if m.picking || m.model != "qwen2" {
+		t.Errorf("expected qwen2 picked, got %q (picking=%v)", m.model, m.picking)
+	}
+
+	m.input.SetValue("/join ops")
+	m.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	if f := conn.last(); m.room != "ops" || f["action"] != websocket.RoomJoin || f["params"].(map[string]any)["room"] != "ops" {
+		t.Errorf("unexpected join frame %v", f)
+	}
+	m.input.SetValue("/leave")
+	m.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	if f := conn.last(); m.room != "" || f["action"] != websocket.RoomLeave {
+		t.Errorf("unexpected leave frame %v", f)
+	}
+
+	m.Update(frame(t, bridge.Envelope{Type: bridge.TypeHeartbeat, Action: "ping", Params: json.RawMessage(`{"backend":{"healthy":false},"latency":{"last_ms":12,"samples":1}}`)}))
+	if s := m.statusView(); !strings.Contains(s, "后端不可用") || !strings.Contains(s, "12ms") {
+		t.Errorf("unexpected status bar %q", s)
+	}
+
+	m.input.SetValue("/quit")
+	if _, cmd := m.Update(tea.KeyMsg{Type: tea.KeyEnter}); cmd == nil {
+		t.Error("expected quit command")
+	}
+}
@@ -0,0 +1,22 @@
+package tui
+
+import (
+	"context"
+
+	tea "github.com/charmbracelet/bubbletea"
+
+	"ollama_dev/internal/auth"
+	"ollama_dev/internal/config"
+)
+
+// Run 连接 url 并运行终端界面，直到用户退出或 ctx 结束
+func Run(ctx context.Context, url string, p auth.Provider, origin string, chunking config.ChunkingConfig, opts Options) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	conn := NewConn(url, p, origin, chunking)
+	go conn.Run(ctx)
+
+	_, err := tea.NewProgram(New(conn, conn.Events(), opts), tea.WithAltScreen(), tea.WithContext(ctx)).Run()
+	return err
+}
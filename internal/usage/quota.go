@@ -0,0 +1,106 @@
+package usage
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"ollama_dev/internal/apperr"
+	"ollama_dev/internal/auth"
+	"ollama_dev/internal/config"
+	"ollama_dev/internal/tenant"
+)
+
+// Caller 用量的归属：租户与鉴权得到的调用方 (JWT 的 sub 或管理员用户名)，静态 Token 与租户 Token 的 Subject 为空，
+// 此时按租户统计
+type Caller struct {
+	Tenant  string
+	Subject string
+}
+
+// CallerOf 读取 ctx 中通过鉴权的调用方
+func CallerOf(ctx context.Context) Caller {
+	id, _ := auth.FromContext(ctx)
+	return Caller{Tenant: tenant.FromContext(ctx), Subject: id.Subject}
+}
+
+// Summary 调用方某天 (UTC) 全部用户与模型的累计用量
+type Summary struct {
+	Date             string `json:"date"`
+	Tenant           string `json:"tenant"`
+	Subject          string `json:"subject,omitempty"`
+	Requests         int64  `json:"requests"`
+	PromptTokens     int64  `json:"prompt_tokens"`
+	CompletionTokens int64  `json:"completion_tokens"`
+	DurationMs       int64  `json:"duration_ms"`
+}
+
+// Daily 返回调用方 c 在 at 当天的累计用量
+func (s *Store) Daily(c Caller, at time.Time) (Summary, error) {
+	date := at.UTC().Format(DateLayout)
+	sum := Summary{Date: date, Tenant: c.Tenant, Subject: c.Subject}
+	err := s.db.QueryRow(`SELECT COALESCE(SUM(requests), 0), COALESCE(SUM(prompt_tokens), 0), COALESCE(SUM(completion_tokens), 0), COALESCE(SUM(duration_ms), 0)
+		FROM usage WHERE date = ? AND tenant = ? AND subject = ?`, date, c.Tenant, c.Subject).
+		Scan(&sum.Requests, &sum.PromptTokens, &sum.CompletionTokens, &sum.DurationMs)
+	return sum, err
+}
+
+// Quota 调用方当天的配额 (server.quota) 与剩余额度，上限为 0 的项不限制，其剩余额度省略
+type Quota struct {
+	DailyRequests     int64     `json:"daily_requests"`
+	DailyTokens       int64     `json:"daily_tokens"` // prompt 与 completion 之和
+	RemainingRequests *int64    `json:"remaining_requests,omitempty"`
+	RemainingTokens   *int64    `json:"remaining_tokens,omitempty"`
+	ResetAt           time.Time `json:"reset_at"` // 次日 0 点 (UTC)
+}
+
+// NewQuota 按当天的用量计算剩余额度，limit 各项均为 0 (不限制) 时返回 nil
+func NewQuota(sum Summary, limit config.QuotaLimit, now time.Time) *Quota {
+	if limit.DailyRequests <= 0 && limit.DailyTokens <= 0 {
+		return nil
+	}
+	q := &Quota{DailyRequests: limit.DailyRequests, DailyTokens: limit.DailyTokens, ResetAt: nextDay(now)}
+	if limit.DailyRequests > 0 {
+		q.RemainingRequests = new(int64)
+		*q.RemainingRequests = max(0, limit.DailyRequests-sum.Requests)
+	}
+	if limit.DailyTokens > 0 {
+		q.RemainingTokens = new(int64)
+		*q.RemainingTokens = max(0, limit.DailyTokens-sum.PromptTokens-sum.CompletionTokens)
+	}
+	return q
+}
+
+// Exceeded 是否已用完任一项额度
+func (q *Quota) Exceeded() bool {
+	if q == nil {
+		return false
+	}
+	return (q.RemainingRequests != nil && *q.RemainingRequests == 0) || (q.RemainingTokens != nil && *q.RemainingTokens == 0)
+}
+
+// Enforce 调用方 c 当天的用量已达 limit 的上限时返回 code 为 quota_exceeded 的错误与距额度重置的时长；
+// s 为 nil 或 limit 不限制时返回 nil。额度在对话开始前检查，进行中的对话可能使用量略超上限
+func (s *Store) Enforce(c Caller, limit config.QuotaLimit, now time.Time) (time.Duration, error) {
+	if s == nil || (limit.DailyRequests <= 0 && limit.DailyTokens <= 0) {
+		return 0, nil
+	}
+	sum, err := s.Daily(c, now)
+	if err != nil {
+		return 0, apperr.Wrap(err, apperr.Internal, apperr.CodeInternal, "查询用量失败")
+	}
+	q := NewQuota(sum, limit, now)
+	if !q.Exceeded() {
+		return 0, nil
+	}
+	msg := fmt.Sprintf("今日对话次数已达上限 (%d)", limit.DailyRequests)
+	if q.RemainingRequests == nil || *q.RemainingRequests > 0 {
+		msg = fmt.Sprintf("今日 token 用量已达上限 (%d)", limit.DailyTokens)
+	}
+	return q.ResetAt.Sub(now), apperr.New(apperr.Backend, apperr.CodeQuotaExceeded, msg+"，UTC 0 点后重置")
+}
+
+// nextDay 返回 now 次日 0 点 (UTC)
+func nextDay(now time.Time) time.Time {
+	return now.UTC().Truncate(24*time.Hour).AddDate(0, 0, 1)
+}
@@ -0,0 +1,132 @@
+package usage
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"ollama_dev/internal/apperr"
+	"ollama_dev/internal/auth"
+	"ollama_dev/internal/config"
+	"ollama_dev/internal/tenant"
+)
+
+func TestDailyPerCaller(t *testing.T) {
+	s, err := Open(filepath.Join(t.TempDir(), "usage.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	alice := Caller{Tenant: "acme", Subject: "alice"}
+	for _, r := range []struct {
+		c  Caller
+		t  Tokens
+		at string
+	}{
+		{alice, Tokens{Model: "llama3", PromptTokens: 10, CompletionTokens: 20, DurationMs: 300}, "2024-05-01"},
+		{alice, Tokens{Model: "qwen2", User: "bot", PromptTokens: 1, CompletionTokens: 2, DurationMs: 100}, "2024-05-01"},
+		{alice, Tokens{Model: "llama3", PromptTokens: 5}, "2024-05-02"},
+		{Caller{Tenant: "acme", Subject: "alice2"}, Tokens{Model: "llama3", PromptTokens: 7}, "2024-05-01"},
+		{Caller{Tenant: "acme"}, Tokens{Model: "llama3", PromptTokens: 9}, "2024-05-01"},
+	} {
+		if err := s.Record(r.c, r.t, day(r.at)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// 只累加同一天、同一调用方的记录，不同用户与模型合计
+	sum, err := s.Daily(alice, day("2024-05-01"))
+	want := Summary{Date: "2024-05-01", Tenant: "acme", Subject: "alice", Requests: 2, PromptTokens: 11, CompletionTokens: 22, DurationMs: 400}
+	if err != nil || sum != want {
+		t.Errorf("expected %+v, got %+v %v", want, sum, err)
+	}
+	if sum, _ := s.Daily(Caller{Tenant: "acme"}, day("2024-05-01")); sum.Requests != 1 || sum.PromptTokens != 9 {
+		t.Errorf("unexpected tenant-wide summary %+v", sum)
+	}
+
+	rows, err := s.Query(day("2024-05-01"), day("2024-05-01"), "acme")
+	if err != nil || len(rows) != 4 || rows[1].Subject != "alice" || rows[1].DurationMs != 300 {
+		t.Errorf("unexpected rows %+v %v", rows, err)
+	}
+}
+
+func TestUserWithNULCountsTowardsQuota(t *testing.T) {
+	s, err := Open(filepath.Join(t.TempDir(), "usage.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	// 请求方自报的 user 含 NUL 时同样计入配额，并原样导出
+	c := Caller{Tenant: "acme", Subject: "alice"}
+	now := day("2024-05-01")
+	if err := s.Record(c, Tokens{Model: "llama3", User: "x\x00y\x01z", PromptTokens: 10}, now); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Enforce(c, config.QuotaLimit{DailyRequests: 1}, now); apperr.CodeOf(err) != apperr.CodeQuotaExceeded {
+		t.Errorf("expected quota_exceeded, got %v", err)
+	}
+	rows, err := s.Query(now, now, "acme")
+	want := Row{Date: "2024-05-01", Tenant: "acme", Subject: "alice", User: "x\x00y\x01z", Model: "llama3", Requests: 1, PromptTokens: 10}
+	if err != nil || len(rows) != 1 || rows[0] != want {
+		t.Errorf("expected %+v, got %+v %v", want, rows, err)
+	}
+}
+
+func TestEnforce(t *testing.T) {
+	s, err := Open(filepath.Join(t.TempDir(), "usage.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	c := Caller{Tenant: "acme", Subject: "alice"}
+	now := day("2024-05-01") // 12:00 UTC
+	if err := s.Record(c, Tokens{Model: "llama3", PromptTokens: 60, CompletionTokens: 40}, now); err != nil {
+		t.Fatal(err)
+	}
+
+	for name, tt := range map[string]struct {
+		limit    config.QuotaLimit
+		exceeded bool
+	}{
+		"unlimited":       {config.QuotaLimit{}, false},
+		"requests left":   {config.QuotaLimit{DailyRequests: 2}, false},
+		"requests used":   {config.QuotaLimit{DailyRequests: 1}, true},
+		"tokens left":     {config.QuotaLimit{DailyTokens: 101}, false},
+		"tokens used":     {config.QuotaLimit{DailyTokens: 100}, true},
+		"either exceeded": {config.QuotaLimit{DailyRequests: 5, DailyTokens: 50}, true},
+	} {
+		wait, err := s.Enforce(c, tt.limit, now)
+		if tt.exceeded != (err != nil) {
+			t.Errorf("%s: expected exceeded=%v, got %v", name, tt.exceeded, err)
+			continue
+		}
+		if tt.exceeded && (apperr.CodeOf(err) != apperr.CodeQuotaExceeded || wait != 12*time.Hour) {
+			t.Errorf("%s: unexpected error %v, wait %v", name, err, wait)
+		}
+	}
+
+	// 次日额度重置
+	if _, err := s.Enforce(c, config.QuotaLimit{DailyRequests: 1}, now.Add(12*time.Hour)); err != nil {
+		t.Errorf("expected quota to reset the next day, got %v", err)
+	}
+	var nilStore *Store
+	if _, err := nilStore.Enforce(c, config.QuotaLimit{DailyRequests: 1}, now); err != nil {
+		t.Errorf("expected nil store to skip quotas, got %v", err)
+	}
+
+	q := NewQuota(Summary{Requests: 1, PromptTokens: 60, CompletionTokens: 40}, config.QuotaLimit{DailyRequests: 10}, now)
+	if q == nil || q.RemainingRequests == nil || *q.RemainingRequests != 9 || q.RemainingTokens != nil || !q.ResetAt.Equal(day("2024-05-02").Add(-12*time.Hour)) {
+		t.Errorf("unexpected quota %+v", q)
+	}
+}
+
+func TestCallerOf(t *testing.T) {
+	ctx := tenant.WithTenant(auth.WithIdentity(context.Background(), auth.Identity{Subject: "alice", Tenant: "acme"}), "acme")
+	if c := CallerOf(ctx); c != (Caller{Tenant: "acme", Subject: "alice"}) {
+		t.Errorf("unexpected caller %+v", c)
+	}
+}
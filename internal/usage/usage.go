@@ -0,0 +1,195 @@
+package usage
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	_ "modernc.org/sqlite"
+)
+
+// DateLayout 导出与存储使用的日期格式，按 UTC 自然日统计
+const DateLayout = "2006-01-02"
+
+// Tokens 单次对话的 token 用量，bridge 随 done 帧的 usage 字段上报
+type Tokens struct {
+	Model            string `json:"model"`
+	User             string `json:"user,omitempty"` // 请求方自报的用户，同一租户内区分使用者
+	PromptTokens     int    `json:"prompt_tokens"`
+	CompletionTokens int    `json:"completion_tokens"`
+	DurationMs       int64  `json:"duration_ms,omitempty"` // Ollama 报告的生成耗时 (total_duration)，毫秒
+}
+
+// Row 某天某调用方某用户某模型的累计用量
+type Row struct {
+	Date             string `json:"date"`
+	Tenant           string `json:"tenant"`
+	Subject          string `json:"subject,omitempty"` // 鉴权得到的调用方，见 Caller
+	User             string `json:"user"`
+	Model            string `json:"model"`
+	Requests         int64  `json:"requests"`
+	PromptTokens     int64  `json:"prompt_tokens"`
+	CompletionTokens int64  `json:"completion_tokens"`
+	DurationMs       int64  `json:"duration_ms"`
+}
+
+// Store 按天聚合的用量，持久化到 SQLite 文件；使用与消息历史相同的纯 Go 驱动
+type Store struct {
+	db *sql.DB
+}
+
+// usageSchema 每天每调用方每用户每模型一行
+const usageSchema = `
+CREATE TABLE IF NOT EXISTS usage (
+	date              TEXT    NOT NULL,
+	tenant            TEXT    NOT NULL,
+	subject           TEXT    NOT NULL,
+	user              TEXT    NOT NULL,
+	model             TEXT    NOT NULL,
+	requests          INTEGER NOT NULL DEFAULT 0,
+	prompt_tokens     INTEGER NOT NULL DEFAULT 0,
+	completion_tokens INTEGER NOT NULL DEFAULT 0,
+	duration_ms       INTEGER NOT NULL DEFAULT 0,
+	UNIQUE (date, tenant, subject, user, model)
+);
+`
+
+// Open 打开或创建用量文件
+func Open(path string) (*Store, error) {
+	if dir := filepath.Dir(path); dir != "." {
+		if err := os.MkdirAll(dir, 0o700); err != nil {
+			return nil, fmt.Errorf("创建用量目录失败: %w", err)
+		}
+	}
+	db, err := sql.Open("sqlite", "file:"+path+"?_pragma=journal_mode(WAL)&_pragma=busy_timeout(5000)")
+	if err != nil {
+		return nil, fmt.Errorf("打开用量文件失败: %w", err)
+	}
+	if _, err := db.Exec(usageSchema); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("初始化用量文件失败: %w", err)
+	}
+	return &Store{db: db}, nil
+}
+
+// Record 累加调用方 c 一次对话的用量，s 为 nil 时不记录
+func (s *Store) Record(c Caller, t Tokens, at time.Time) error {
+	if s == nil {
+		return nil
+	}
+	_, err := s.db.Exec(`INSERT INTO usage (date, tenant, subject, user, model, requests, prompt_tokens, completion_tokens, duration_ms)
+		VALUES (?, ?, ?, ?, ?, 1, ?, ?, ?)
+		ON CONFLICT (date, tenant, subject, user, model) DO UPDATE SET
+			requests = requests + 1,
+			prompt_tokens = prompt_tokens + excluded.prompt_tokens,
+			completion_tokens = completion_tokens + excluded.completion_tokens,
+			duration_ms = duration_ms + excluded.duration_ms`,
+		at.UTC().Format(DateLayout), c.Tenant, c.Subject, t.User, t.Model, t.PromptTokens, t.CompletionTokens, t.DurationMs)
+	return err
+}
+
+// Query 返回 [from, to] 日期范围内的用量，tenant 为空时返回全部租户
+func (s *Store) Query(from, to time.Time, tenant string) ([]Row, error) {
+	rows, err := s.db.Query(`SELECT date, tenant, subject, user, model, requests, prompt_tokens, completion_tokens, duration_ms
+		FROM usage WHERE date BETWEEN ? AND ? AND (? = '' OR tenant = ?)
+		ORDER BY date, tenant, subject, user, model`,
+		from.UTC().Format(DateLayout), to.UTC().Format(DateLayout), tenant, tenant)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []Row
+	for rows.Next() {
+		var r Row
+		if err := rows.Scan(&r.Date, &r.Tenant, &r.Subject, &r.User, &r.Model, &r.Requests, &r.PromptTokens, &r.CompletionTokens, &r.DurationMs); err != nil {
+			return nil, err
+		}
+		out = append(out, r)
+	}
+	return out, rows.Err()
+}
+
+// Close 关闭用量文件
+func (s *Store) Close() error {
+	if s == nil {
+		return nil
+	}
+	return s.db.Close()
+}
+
+// WriteCSV 以带表头的 CSV 输出，之后新增的列追加在末尾
+func WriteCSV(w io.Writer, rows []Row) error {
+	cw := csv.NewWriter(w)
+	_ = cw.Write([]string{"date", "tenant", "user", "model", "requests", "prompt_tokens", "completion_tokens", "subject", "duration_ms"})
+	for _, r := range rows {
+		_ = cw.Write([]string{
+			r.Date, r.Tenant, r.User, r.Model,
+			strconv.FormatInt(r.Requests, 10),
+			strconv.FormatInt(r.PromptTokens, 10),
+			strconv.FormatInt(r.CompletionTokens, 10),
+			r.Subject,
+			strconv.FormatInt(r.DurationMs, 10),
+		})
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// ParseFrame 从 bridge 的响应帧中取出 usage，不是携带用量的 done 帧时返回 false
+func ParseFrame(frame []byte) (Tokens, bool) {
+	if !bytes.Contains(frame, []byte(`"usage"`)) {
+		return Tokens{}, false
+	}
+	var f struct {
+		Type   string  `json:"type"`
+		Action string  `json:"action"`
+		Status string  `json:"status"`
+		Usage  *Tokens `json:"usage"`
+	}
+	if err := json.Unmarshal(frame, &f); err != nil || f.Usage == nil {
+		return Tokens{}, false
+	}
+	// 超过帧大小限制的 done 帧被拆分，用量随第一个分片发送
+	if f.Type != "client_to_server" || (f.Status != "done" && f.Action != "part") {
+		return Tokens{}, false
+	}
+	return *f.Usage, true
+}
+
+// DefaultDays 未指定 from 时导出最近的天数（含当天）
+const DefaultDays = 30
+
+// ErrInvalidRange 日期格式错误或 from 晚于 to
+var ErrInvalidRange = errors.New("日期范围无效")
+
+// ParseRange 解析 YYYY-MM-DD 格式的闭区间，to 为空时取 now 当天，from 为空时取 to 之前 DefaultDays 天
+func ParseRange(from, to string, now time.Time) (time.Time, time.Time, error) {
+	end := now.UTC().Truncate(24 * time.Hour)
+	if to != "" {
+		t, err := time.Parse(DateLayout, to)
+		if err != nil {
+			return time.Time{}, time.Time{}, fmt.Errorf("%w: to=%q", ErrInvalidRange, to)
+		}
+		end = t
+	}
+	start := end.AddDate(0, 0, -(DefaultDays - 1))
+	if from != "" {
+		t, err := time.Parse(DateLayout, from)
+		if err != nil {
+			return time.Time{}, time.Time{}, fmt.Errorf("%w: from=%q", ErrInvalidRange, from)
+		}
+		start = t
+	}
+	if start.After(end) {
+		return time.Time{}, time.Time{}, fmt.Errorf("%w: from 晚于 to", ErrInvalidRange)
+	}
+	return start, end, nil
+}
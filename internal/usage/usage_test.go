@@ -0,0 +1,116 @@
+package usage
+
+import (
+	"bytes"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func day(s string) time.Time {
+	t, _ := time.Parse(DateLayout, s)
+	return t.Add(12 * time.Hour)
+}
+
+func TestRecordAndQuery(t *testing.T) {
+	s, err := Open(filepath.Join(t.TempDir(), "usage.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	llama := Tokens{Model: "llama3", User: "alice", PromptTokens: 10, CompletionTokens: 20}
+	for _, r := range []struct {
+		tenant string
+		t      Tokens
+		at     string
+	}{
+		{"acme", llama, "2024-05-01"},
+		{"acme", llama, "2024-05-01"},
+		{"acme", Tokens{Model: "qwen2", PromptTokens: 1, CompletionTokens: 2}, "2024-05-02"},
+		{"other", llama, "2024-05-02"},
+		{"acme", llama, "2024-05-04"},
+	} {
+		if err := s.Record(Caller{Tenant: r.tenant}, r.t, day(r.at)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	rows, err := s.Query(day("2024-05-01"), day("2024-05-03"), "acme")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 同一天同一用户同一模型累加，范围外与其他租户的记录不返回
+	want := []Row{
+		{Date: "2024-05-01", Tenant: "acme", User: "alice", Model: "llama3", Requests: 2, PromptTokens: 20, CompletionTokens: 40},
+		{Date: "2024-05-02", Tenant: "acme", Model: "qwen2", Requests: 1, PromptTokens: 1, CompletionTokens: 2},
+	}
+	if len(rows) != len(want) || rows[0] != want[0] || rows[1] != want[1] {
+		t.Errorf("expected %+v, got %+v", want, rows)
+	}
+
+	all, err := s.Query(day("2024-05-01"), day("2024-05-04"), "")
+	if err != nil || len(all) != 4 {
+		t.Errorf("expected 4 rows across tenants, got %+v %v", all, err)
+	}
+}
+
+func TestNilStoreRecord(t *testing.T) {
+	var s *Store
+	if err := s.Record(Caller{Tenant: "acme"}, Tokens{}, time.Now()); err != nil {
+		t.Errorf("expected nil store to ignore records, got %v", err)
+	}
+}
+
+func TestWriteCSV(t *testing.T) {
+	var buf bytes.Buffer
+	err := WriteCSV(&buf, []Row{{Date: "2024-05-01", Tenant: "acme", Subject: "alice", User: "a,b", Model: "llama3", Requests: 2, PromptTokens: 20, CompletionTokens: 40, DurationMs: 1500}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "date,tenant,user,model,requests,prompt_tokens,completion_tokens,subject,duration_ms\n2024-05-01,acme,\"a,b\",llama3,2,20,40,alice,1500\n"
+	if buf.String() != want {
+		t.Errorf("unexpected csv:\n%s", buf.String())
+	}
+}
+
+func TestParseFrame(t *testing.T) {
+	tests := []struct {
+		frame string
+		ok    bool
+	}{
+		{`{"type":"client_to_server","status":"done","usage":{"model":"llama3","prompt_tokens":1,"completion_tokens":2}}`, true},
+		// 拆分后的第一个分片
+		{`{"type":"client_to_server","action":"part","usage":{"model":"llama3","prompt_tokens":1,"completion_tokens":2}}`, true},
+		{`{"type":"client_to_server","status":"streaming","data":"usage"}`, false},
+		{`{"type":"server_to_client","status":"done","usage":{"model":"llama3"}}`, false},
+		{`{"type":"client_to_server","status":"done"}`, false},
+	}
+	for _, tt := range tests {
+		u, ok := ParseFrame([]byte(tt.frame))
+		if ok != tt.ok {
+			t.Errorf("%s: expected ok=%v", tt.frame, tt.ok)
+		}
+		if ok && (u.Model != "llama3" || u.PromptTokens != 1 || u.CompletionTokens != 2) {
+			t.Errorf("%s: unexpected usage %+v", tt.frame, u)
+		}
+	}
+}
+
+func TestParseRange(t *testing.T) {
+	now := day("2024-05-31")
+	from, to, err := ParseRange("", "", now)
+	if err != nil || from.Format(DateLayout) != "2024-05-02" || to.Format(DateLayout) != "2024-05-31" {
+		t.Errorf("expected last 30 days, got %v %v %v", from, to, err)
+	}
+	from, to, err = ParseRange("2024-01-01", "2024-01-31", now)
+	if err != nil || from.Format(DateLayout) != "2024-01-01" || to.Format(DateLayout) != "2024-01-31" {
+		t.Errorf("unexpected range %v %v %v", from, to, err)
+	}
+	for _, r := range [][2]string{{"2024-02-01", "2024-01-01"}, {"yesterday", ""}, {"", "2024/01/01"}} {
+		if _, _, err := ParseRange(r[0], r[1], now); !errors.Is(err, ErrInvalidRange) {
+			t.Errorf("%v: expected ErrInvalidRange, got %v", r, err)
+		}
+	}
+}
@@ -0,0 +1,136 @@
+package util
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"runtime"
+
+	"golang.org/x/crypto/chacha20poly1305"
+	"golang.org/x/sys/cpu"
+)
+
+// Cipher 端到端加密使用的 AEAD 算法
+type Cipher string
+
+const (
+	AESGCM           Cipher = "aes-256-gcm"
+	ChaCha20Poly1305 Cipher = "chacha20-poly1305"
+)
+
+// ErrUnknownCipher 不支持的加密算法
+var ErrUnknownCipher = errors.New("不支持的加密算法")
+
+// ParseCipher 解析算法名称，空字符串表示按硬件自动选择
+func ParseCipher(name string) (Cipher, error) {
+	switch c := Cipher(name); c {
+	case "":
+		return PreferredCipher(), nil
+	case AESGCM, ChaCha20Poly1305:
+		return c, nil
+	default:
+		return "", fmt.Errorf("%w: %q", ErrUnknownCipher, name)
+	}
+}
+
+// HasAESHardware 判断 CPU 是否支持 AES 与 GCM 所需的乘法指令 (x86 AES-NI+PCLMULQDQ、ARMv8 AES+PMULL、s390x CPACF)
+func HasAESHardware() bool {
+	switch runtime.GOARCH {
+	case "amd64", "386":
+		return cpu.X86.HasAES && cpu.X86.HasPCLMULQDQ
+	case "arm64":
+		return cpu.ARM64.HasAES && cpu.ARM64.HasPMULL
+	case "s390x":
+		return cpu.S390X.HasAES && cpu.S390X.HasAESGCM
+	default:
+		return false
+	}
+}
+
+// PreferredCipher 有 AES 硬件加速时使用 AES-GCM，否则使用纯软件实现更快且常数时间的 ChaCha20-Poly1305
+func PreferredCipher() Cipher {
+	if HasAESHardware() {
+		return AESGCM
+	}
+	return ChaCha20Poly1305
+}
+
+// NewAEAD 按算法创建 AEAD，密钥长度须为 32 字节 (AES-GCM 同时接受 16/24 字节)
+func NewAEAD(c Cipher, key []byte) (cipher.AEAD, error) {
+	switch c {
+	case AESGCM:
+		block, err := aes.NewCipher(key)
+		if err != nil {
+			return nil, err
+		}
+		return cipher.NewGCM(block)
+	case ChaCha20Poly1305:
+		return chacha20poly1305.New(key)
+	default:
+		return nil, fmt.Errorf("%w: %q", ErrUnknownCipher, string(c))
+	}
+}
+
+// EncryptWith 使用指定算法加密，输出格式与 Encrypt 相同：base64(nonce || ciphertext)
+func EncryptWith(c Cipher, key []byte, plaintext string) (string, error) {
+	aead, err := NewAEAD(c, key)
+	if err != nil {
+		return "", err
+	}
+	return seal(aead, []byte(plaintext), nil)
+}
+
+// DecryptWith 使用指定算法解密 EncryptWith 的输出
+func DecryptWith(c Cipher, key []byte, ciphertext string) (string, error) {
+	aead, err := NewAEAD(c, key)
+	if err != nil {
+		return "", err
+	}
+	plaintext, err := open(aead, ciphertext, nil)
+	return string(plaintext), err
+}
+
+// SealWith 加密并认证附加数据 aad，解密时须提供相同的 aad，用于将密文绑定到租户与请求
+func SealWith(c Cipher, key, plaintext, aad []byte) (string, error) {
+	aead, err := NewAEAD(c, key)
+	if err != nil {
+		return "", err
+	}
+	return seal(aead, plaintext, aad)
+}
+
+// OpenWith 解密 SealWith 的输出，aad 不一致时失败
+func OpenWith(c Cipher, key []byte, ciphertext string, aad []byte) ([]byte, error) {
+	aead, err := NewAEAD(c, key)
+	if err != nil {
+		return nil, err
+	}
+	return open(aead, ciphertext, aad)
+}
+
+// seal 生成随机 nonce 并加密，nonce 拼接在密文前
+func seal(aead cipher.AEAD, plaintext, aad []byte) (string, error) {
+	nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(plaintext)+aead.Overhead())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return "", err
+	}
+	combined := aead.Seal(nonce, nonce, plaintext, aad)
+	return base64.URLEncoding.EncodeToString(combined), nil
+}
+
+// open 拆分 nonce 与密文并解密
+func open(aead cipher.AEAD, ciphertext string, aad []byte) ([]byte, error) {
+	decoded, err := base64.URLEncoding.DecodeString(ciphertext)
+	if err != nil {
+		return nil, err
+	}
+	if len(decoded) < aead.NonceSize() {
+		return nil, errors.New("ciphertext too short")
+	}
+	nonce, encrypted := decoded[:aead.NonceSize()], decoded[aead.NonceSize():]
+	return aead.Open(encrypted[:0], nonce, encrypted, aad)
+}
@@ -0,0 +1,146 @@
+package util
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+var benchSizes = []int{64, 1 << 10, 16 << 10, 256 << 10}
+
+func TestEncryptWithRoundTrip(t *testing.T) {
+	key := newKey(t)
+	for _, c := range []Cipher{AESGCM, ChaCha20Poly1305} {
+		encrypted, err := EncryptWith(c, key, "Hello, secure world!")
+		if err != nil {
+			t.Fatalf("%s: Encryption failed: %v", c, err)
+		}
+		decrypted, err := DecryptWith(c, key, encrypted)
+		if err != nil {
+			t.Fatalf("%s: Decryption failed: %v", c, err)
+		}
+		if decrypted != "Hello, secure world!" {
+			t.Errorf("%s: got %q", c, decrypted)
+		}
+	}
+}
+
+func TestEncryptCompatibleWithAESGCM(t *testing.T) {
+	key := newKey(t)
+
+	// Encrypt 的输出必须能被 DecryptWith(AESGCM) 解密，保持线上格式不变
+	encrypted, err := Encrypt(key, "compat")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err := DecryptWith(AESGCM, key, encrypted); err != nil || got != "compat" {
+		t.Fatalf("DecryptWith(AESGCM) = %q, %v", got, err)
+	}
+
+	// 算法不一致时必须解密失败而不是返回乱码
+	if _, err := DecryptWith(ChaCha20Poly1305, key, encrypted); err == nil {
+		t.Error("Expected an error when decrypting with a different cipher, but got none")
+	}
+}
+
+func TestChaCha20RejectsShortKey(t *testing.T) {
+	if _, err := EncryptWith(ChaCha20Poly1305, make([]byte, 16), "x"); err == nil {
+		t.Error("Expected an error for a 16-byte ChaCha20 key, but got none")
+	}
+}
+
+func TestParseCipher(t *testing.T) {
+	if c, err := ParseCipher(""); err != nil || c != PreferredCipher() {
+		t.Errorf("ParseCipher(\"\") = %q, %v", c, err)
+	}
+	if c, err := ParseCipher("chacha20-poly1305"); err != nil || c != ChaCha20Poly1305 {
+		t.Errorf("ParseCipher(chacha20-poly1305) = %q, %v", c, err)
+	}
+	if _, err := ParseCipher("des"); !errors.Is(err, ErrUnknownCipher) {
+		t.Errorf("ParseCipher(des) err = %v", err)
+	}
+}
+
+func TestPreferredCipher(t *testing.T) {
+	want := ChaCha20Poly1305
+	if HasAESHardware() {
+		want = AESGCM
+	}
+	if got := PreferredCipher(); got != want {
+		t.Errorf("PreferredCipher() = %q, want %q", got, want)
+	}
+	t.Logf("AES hardware: %v, preferred: %s", HasAESHardware(), PreferredCipher())
+}
+
+func BenchmarkEncrypt(b *testing.B) {
+	key := newKey(b)
+	for _, c := range []Cipher{AESGCM, ChaCha20Poly1305} {
+		for _, size := range benchSizes {
+			plaintext := strings.Repeat("a", size)
+			b.Run(fmt.Sprintf("%s/%d", c, size), func(b *testing.B) {
+				b.SetBytes(int64(size))
+				b.ReportAllocs()
+				for b.Loop() {
+					if _, err := EncryptWith(c, key, plaintext); err != nil {
+						b.Fatal(err)
+					}
+				}
+			})
+		}
+	}
+}
+
+func BenchmarkDecrypt(b *testing.B) {
+	key := newKey(b)
+	for _, c := range []Cipher{AESGCM, ChaCha20Poly1305} {
+		for _, size := range benchSizes {
+			encrypted, err := EncryptWith(c, key, strings.Repeat("a", size))
+			if err != nil {
+				b.Fatal(err)
+			}
+			b.Run(fmt.Sprintf("%s/%d", c, size), func(b *testing.B) {
+				b.SetBytes(int64(size))
+				b.ReportAllocs()
+				for b.Loop() {
+					if _, err := DecryptWith(c, key, encrypted); err != nil {
+						b.Fatal(err)
+					}
+				}
+			})
+		}
+	}
+}
+
+func FuzzOpenWith(f *testing.F) {
+	key := newKey(f)
+	aad := []byte("ollama_dev/e2e\x00acme\x00r1")
+	valid := map[Cipher]string{}
+	for _, c := range []Cipher{AESGCM, ChaCha20Poly1305} {
+		sealed, err := SealWith(c, key, []byte(`{"model_name":"llama3"}`), aad)
+		if err != nil {
+			f.Fatal(err)
+		}
+		valid[c] = sealed
+		f.Add(sealed, aad)
+		f.Add(sealed[:len(sealed)/2], aad)
+		f.Add(sealed, []byte("ollama_dev/e2e\x00other\x00r1"))
+	}
+	f.Add("", []byte(nil))
+	f.Add("not base64!", aad)
+
+	f.Fuzz(func(t *testing.T, payload string, fuzzAAD []byte) {
+		for _, c := range []Cipher{AESGCM, ChaCha20Poly1305} {
+			if _, err := OpenWith(c, key, payload, fuzzAAD); err == nil {
+				// 无法伪造：只有原样的密文与附加数据能解密（base64 末尾的填充位不参与比较）
+				got, _ := base64.URLEncoding.DecodeString(payload)
+				want, _ := base64.URLEncoding.DecodeString(valid[c])
+				if !bytes.Equal(got, want) || !bytes.Equal(fuzzAAD, aad) {
+					t.Fatalf("%s: forged payload %q opened", c, payload)
+				}
+			}
+		}
+	})
+}
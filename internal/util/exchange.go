@@ -0,0 +1,121 @@
+package util
+
+import (
+	"bytes"
+	"crypto/ecdh"
+	"crypto/hkdf"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Curve 密钥交换使用的椭圆曲线
+type Curve string
+
+const (
+	X25519 Curve = "x25519"
+	P256   Curve = "p256"
+)
+
+var (
+	// ErrUnknownCurve 不支持的曲线
+	ErrUnknownCurve = errors.New("不支持的密钥交换曲线")
+	// ErrInvalidPublicKey 对端的公钥格式无效或不在曲线上
+	ErrInvalidPublicKey = errors.New("公钥无效")
+)
+
+// sessionKeyLabel HKDF 的 info 前缀，与其他用途派生的密钥区分
+const sessionKeyLabel = "ollama_dev/session-key"
+
+func curveOf(c Curve) (ecdh.Curve, error) {
+	switch c {
+	case X25519:
+		return ecdh.X25519(), nil
+	case P256:
+		return ecdh.P256(), nil
+	default:
+		return nil, fmt.Errorf("%w: %q", ErrUnknownCurve, string(c))
+	}
+}
+
+// KeyExchange 一次握手使用的临时密钥对：双方交换 PublicKey 后各自调用 SessionKey，得到相同的会话密钥，
+// 可直接用于 NewAEAD；每个连接应生成新的 KeyExchange，用完即丢弃。
+// 交换本身不认证对端：公钥须经 TLS 直连或以双方已有的密钥签名后传递，经第三方转发时转发方可以替换双方的公钥
+type KeyExchange struct {
+	curve Curve
+	priv  *ecdh.PrivateKey
+}
+
+// NewKeyExchange 生成曲线 c 上的临时密钥对，c 为空时使用 X25519
+func NewKeyExchange(c Curve) (*KeyExchange, error) {
+	if c == "" {
+		c = X25519
+	}
+	curve, err := curveOf(c)
+	if err != nil {
+		return nil, err
+	}
+	priv, err := curve.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("生成密钥对失败: %w", err)
+	}
+	return &KeyExchange{curve: c, priv: priv}, nil
+}
+
+// PublicKey 返回发给对端的公钥，格式为 "<曲线>:<base64url>"，如 "x25519:…"
+func (x *KeyExchange) PublicKey() string {
+	return MarshalPublicKey(x.curve, x.priv.PublicKey())
+}
+
+// SessionKey 由本端私钥与对端公钥计算共享密钥，再以 HKDF-SHA256 派生 32 字节的会话密钥。
+// salt 可为空或为双方都知道的随机数 (如握手中交换的 nonce)，info 区分同一连接上的不同用途；
+// 双方公钥按字节序排列后加入 info，因此两端调用结果相同，而换了任一方的公钥都会得到不同的密钥
+func (x *KeyExchange) SessionKey(peer string, salt []byte, info string) ([]byte, error) {
+	c, pub, err := ParsePublicKey(peer)
+	if err != nil {
+		return nil, err
+	}
+	if c != x.curve {
+		return nil, fmt.Errorf("%w: 对端使用 %s，本端使用 %s", ErrInvalidPublicKey, c, x.curve)
+	}
+	shared, err := x.priv.ECDH(pub)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidPublicKey, err)
+	}
+	own, other := x.priv.PublicKey().Bytes(), pub.Bytes()
+	if bytes.Compare(own, other) > 0 {
+		own, other = other, own
+	}
+	label := sessionKeyLabel + "\x00" + info + "\x00" + string(own) + string(other)
+	return hkdf.Key(sha256.New, shared, salt, label, keySize)
+}
+
+// MarshalPublicKey 将公钥编码为 "<曲线>:<base64url>"
+func MarshalPublicKey(c Curve, pub *ecdh.PublicKey) string {
+	return string(c) + ":" + base64.RawURLEncoding.EncodeToString(pub.Bytes())
+}
+
+// ParsePublicKey 解析 MarshalPublicKey 的输出
+func ParsePublicKey(s string) (Curve, *ecdh.PublicKey, error) {
+	name, encoded, ok := strings.Cut(s, ":")
+	if !ok {
+		return "", nil, fmt.Errorf("%w: 缺少曲线名", ErrInvalidPublicKey)
+	}
+	c := Curve(name)
+	curve, err := curveOf(c)
+	if err != nil {
+		return "", nil, err
+	}
+	raw, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return "", nil, fmt.Errorf("%w: %v", ErrInvalidPublicKey, err)
+	}
+	pub, err := curve.NewPublicKey(raw)
+	if err != nil {
+		return "", nil, fmt.Errorf("%w: %v", ErrInvalidPublicKey, err)
+	}
+	return c, pub, nil
+}
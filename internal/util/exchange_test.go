@@ -0,0 +1,69 @@
+package util
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestKeyExchangeDerivesSameSessionKey(t *testing.T) {
+	for _, c := range []Curve{X25519, P256} {
+		client, err := NewKeyExchange(c)
+		if err != nil {
+			t.Fatal(err)
+		}
+		server, _ := NewKeyExchange(c)
+		salt := []byte("handshake-nonce")
+
+		k1, err := client.SessionKey(server.PublicKey(), salt, "bridge")
+		if err != nil {
+			t.Fatalf("%s: %v", c, err)
+		}
+		k2, err := server.SessionKey(client.PublicKey(), salt, "bridge")
+		if err != nil {
+			t.Fatalf("%s: %v", c, err)
+		}
+		if !bytes.Equal(k1, k2) || len(k1) != 32 {
+			t.Fatalf("%s: session keys differ", c)
+		}
+		// 会话密钥可直接用于 AEAD
+		sealed, err := EncryptWith(AESGCM, k1, "hello")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, err := DecryptWith(AESGCM, k2, sealed); err != nil || got != "hello" {
+			t.Errorf("%s: decrypt: %q, %v", c, got, err)
+		}
+
+		// 不同用途或不同的 salt 派生不同的密钥
+		other, _ := client.SessionKey(server.PublicKey(), salt, "files")
+		unsalted, _ := client.SessionKey(server.PublicKey(), nil, "bridge")
+		if bytes.Equal(k1, other) || bytes.Equal(k1, unsalted) {
+			t.Errorf("%s: expected distinct keys per info and salt", c)
+		}
+	}
+}
+
+func TestKeyExchangeRejectsBadPublicKeys(t *testing.T) {
+	x, _ := NewKeyExchange("")
+	p, _ := NewKeyExchange(P256)
+	cases := map[string]struct {
+		peer string
+		err  error
+	}{
+		"no curve":        {"AAAA", ErrInvalidPublicKey},
+		"unknown curve":   {"secp256k1:AAAA", ErrUnknownCurve},
+		"bad base64":      {"x25519:%%%", ErrInvalidPublicKey},
+		"wrong length":    {"x25519:AAAA", ErrInvalidPublicKey},
+		"curve mismatch":  {p.PublicKey(), ErrInvalidPublicKey},
+		"low order point": {"x25519:" + "AAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA", ErrInvalidPublicKey},
+	}
+	for name, c := range cases {
+		if _, err := x.SessionKey(c.peer, nil, ""); !errors.Is(err, c.err) {
+			t.Errorf("%s: expected %v, got %v", name, c.err, err)
+		}
+	}
+	if _, err := NewKeyExchange("p521"); !errors.Is(err, ErrUnknownCurve) {
+		t.Errorf("expected ErrUnknownCurve, got %v", err)
+	}
+}
@@ -0,0 +1,279 @@
+package util
+
+import (
+	"cmp"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrUnknownKey 密文使用的密钥不在密钥环中，通常是已被轮换删除
+	ErrUnknownKey = errors.New("密钥环中没有该密钥")
+	// ErrMalformedCiphertext 密文不是 Keyring 的输出或已损坏
+	ErrMalformedCiphertext = errors.New("密文格式无效")
+)
+
+const (
+	// ciphertextFormat 密文头部的格式版本，之后为 1 字节的算法编号与 4 字节的 key ID (大端)
+	ciphertextFormat = 1
+	headerSize       = 1 + 1 + 4
+)
+
+// cipherCodes 密文头部中的算法编号
+var cipherCodes = map[Cipher]byte{AESGCM: 1, ChaCha20Poly1305: 2}
+
+// KeyInfo 密钥环中一个密钥的元数据
+type KeyInfo struct {
+	ID        uint32    `json:"id"`
+	Cipher    Cipher    `json:"cipher"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type ringKey struct {
+	KeyInfo
+	Secret []byte `json:"secret"`
+}
+
+// Keyring 保存多个带 ID 的密钥：加密使用当前密钥，密文头部带算法与 key ID，解密时按 ID 选择密钥，
+// 因此轮换后仍可解密旧密文；可用口令加密后保存到文件
+type Keyring struct {
+	mu      sync.RWMutex
+	current uint32
+	keys    map[uint32]ringKey
+}
+
+// NewKeyring 创建只有一个密钥的密钥环，c 为空时按硬件选择算法
+func NewKeyring(c Cipher) (*Keyring, error) {
+	k := &Keyring{keys: map[uint32]ringKey{}}
+	if _, err := k.Rotate(c, 0); err != nil {
+		return nil, err
+	}
+	return k, nil
+}
+
+// Current 返回当前用于加密的密钥
+func (k *Keyring) Current() KeyInfo {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	return k.keys[k.current].KeyInfo
+}
+
+// Keys 返回全部密钥，按 ID 升序
+func (k *Keyring) Keys() []KeyInfo {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	out := make([]KeyInfo, 0, len(k.keys))
+	for _, key := range k.keys {
+		out = append(out, key.KeyInfo)
+	}
+	slices.SortFunc(out, func(a, b KeyInfo) int { return cmp.Compare(a.ID, b.ID) })
+	return out
+}
+
+// Rotate 生成新密钥并设为当前密钥，旧密钥保留用于解密；只保留最新的 keep 个，keep 不大于 0 时全部保留。
+// c 为空时沿用当前密钥的算法
+func (k *Keyring) Rotate(c Cipher, keep int) (KeyInfo, error) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if c == "" {
+		c = k.keys[k.current].Cipher
+	}
+	if c == "" {
+		c = PreferredCipher()
+	}
+	if _, err := ParseCipher(string(c)); err != nil {
+		return KeyInfo{}, err
+	}
+	secret, err := generateKey()
+	if err != nil {
+		return KeyInfo{}, fmt.Errorf("生成密钥失败: %w", err)
+	}
+	key := ringKey{KeyInfo: KeyInfo{ID: k.current + 1, Cipher: c, CreatedAt: time.Now().UTC()}, Secret: secret}
+	k.keys[key.ID] = key
+	k.current = key.ID
+	if keep > 0 {
+		for id := range k.keys {
+			if id+uint32(keep) <= k.current {
+				delete(k.keys, id)
+			}
+		}
+	}
+	return key.KeyInfo, nil
+}
+
+// Seal 使用当前密钥及其算法加密并认证 aad
+func (k *Keyring) Seal(plaintext, aad []byte) (string, error) {
+	return k.SealWith("", plaintext, aad)
+}
+
+// SealWith 使用当前密钥与算法 c 加密并认证 aad，c 为空时使用密钥自身的算法；
+// 输出 base64(格式版本 || 算法 || key ID || nonce || 密文)，头部同样受认证保护
+func (k *Keyring) SealWith(c Cipher, plaintext, aad []byte) (string, error) {
+	k.mu.RLock()
+	key := k.keys[k.current]
+	k.mu.RUnlock()
+	if c == "" {
+		c = key.Cipher
+	}
+	code, ok := cipherCodes[c]
+	if !ok {
+		return "", fmt.Errorf("%w: %q", ErrUnknownCipher, string(c))
+	}
+	aead, err := NewAEAD(c, key.Secret)
+	if err != nil {
+		return "", err
+	}
+	out := make([]byte, headerSize+aead.NonceSize(), headerSize+aead.NonceSize()+len(plaintext)+aead.Overhead())
+	out[0], out[1] = ciphertextFormat, code
+	binary.BigEndian.PutUint32(out[2:headerSize], key.ID)
+	nonce := out[headerSize:]
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	out = aead.Seal(out, nonce, plaintext, headerAAD(out[:headerSize], aad))
+	return base64.URLEncoding.EncodeToString(out), nil
+}
+
+// Open 按密文头部的算法与 key ID 选择密钥解密，aad 须与加密时相同
+func (k *Keyring) Open(ciphertext string, aad []byte) ([]byte, error) {
+	decoded, err := base64.URLEncoding.DecodeString(ciphertext)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrMalformedCiphertext, err)
+	}
+	c, id, err := parseHeader(decoded)
+	if err != nil {
+		return nil, err
+	}
+	k.mu.RLock()
+	key, ok := k.keys[id]
+	k.mu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("%w: key ID %d", ErrUnknownKey, id)
+	}
+	aead, err := NewAEAD(c, key.Secret)
+	if err != nil {
+		return nil, err
+	}
+	if len(decoded) < headerSize+aead.NonceSize() {
+		return nil, fmt.Errorf("%w: 长度不足", ErrMalformedCiphertext)
+	}
+	nonce, sealed := decoded[headerSize:headerSize+aead.NonceSize()], decoded[headerSize+aead.NonceSize():]
+	return aead.Open(nil, nonce, sealed, headerAAD(decoded[:headerSize], aad))
+}
+
+// Encrypt 与 Seal 相同，不带附加数据
+func (k *Keyring) Encrypt(plaintext string) (string, error) {
+	return k.Seal([]byte(plaintext), nil)
+}
+
+// Decrypt 解密 Encrypt 的输出
+func (k *Keyring) Decrypt(ciphertext string) (string, error) {
+	plaintext, err := k.Open(ciphertext, nil)
+	return string(plaintext), err
+}
+
+// KeyID 返回加密 ciphertext 所用密钥的 ID，不需要密钥，可用于判断是否需要用新密钥重新加密
+func KeyID(ciphertext string) (uint32, error) {
+	decoded, err := base64.URLEncoding.DecodeString(ciphertext)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %v", ErrMalformedCiphertext, err)
+	}
+	_, id, err := parseHeader(decoded)
+	return id, err
+}
+
+func parseHeader(decoded []byte) (Cipher, uint32, error) {
+	if len(decoded) < headerSize {
+		return "", 0, fmt.Errorf("%w: 长度不足", ErrMalformedCiphertext)
+	}
+	if decoded[0] != ciphertextFormat {
+		return "", 0, fmt.Errorf("%w: 未知的格式版本 %d", ErrMalformedCiphertext, decoded[0])
+	}
+	for c, code := range cipherCodes {
+		if code == decoded[1] {
+			return c, binary.BigEndian.Uint32(decoded[2:headerSize]), nil
+		}
+	}
+	return "", 0, fmt.Errorf("%w: 未知的算法编号 %d", ErrMalformedCiphertext, decoded[1])
+}
+
+// headerAAD 将密文头部加入附加数据，改写算法或 key ID 后无法解密
+func headerAAD(header, aad []byte) []byte {
+	return append(slices.Clip(header), aad...)
+}
+
+// keyringAAD 密钥环文件的附加数据，与其他用途的密文区分
+var keyringAAD = []byte("ollama_dev/keyring")
+
+type keyringData struct {
+	Current uint32    `json:"current"`
+	Keys    []ringKey `json:"keys"`
+}
+
+// MarshalJSON 输出包含密钥内容的明文 JSON，只应写入 SaveSealedFile 等加密存储
+func (k *Keyring) MarshalJSON() ([]byte, error) {
+	k.mu.RLock()
+	data := keyringData{Current: k.current}
+	for _, key := range k.keys {
+		data.Keys = append(data.Keys, key)
+	}
+	k.mu.RUnlock()
+	slices.SortFunc(data.Keys, func(a, b ringKey) int { return cmp.Compare(a.ID, b.ID) })
+	return json.Marshal(data)
+}
+
+// UnmarshalJSON 读取 MarshalJSON 的输出，缺少当前密钥时返回 ErrUnknownKey
+func (k *Keyring) UnmarshalJSON(raw []byte) error {
+	var data keyringData
+	if err := json.Unmarshal(raw, &data); err != nil {
+		return err
+	}
+	keys := make(map[uint32]ringKey, len(data.Keys))
+	for _, key := range data.Keys {
+		keys[key.ID] = key
+	}
+	if _, ok := keys[data.Current]; !ok {
+		return fmt.Errorf("%w: 缺少当前密钥 %d", ErrUnknownKey, data.Current)
+	}
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	k.current, k.keys = data.Current, keys
+	return nil
+}
+
+// LoadKeyring 读取 Save 保存的密钥环文件，口令错误时返回 ErrWrongPassphrase
+func LoadKeyring(path string, passphrase []byte) (*Keyring, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("读取密钥环文件失败: %w", err)
+	}
+	plaintext, err := OpenSealedFile(raw, passphrase, keyringAAD)
+	if err != nil {
+		return nil, err
+	}
+	k := &Keyring{}
+	if err := json.Unmarshal(plaintext, k); err != nil {
+		return nil, fmt.Errorf("解析密钥环失败: %w", err)
+	}
+	return k, nil
+}
+
+// Save 以口令加密后写入 path
+func (k *Keyring) Save(path string, passphrase []byte) error {
+	plaintext, err := json.Marshal(k)
+	if err != nil {
+		return err
+	}
+	if err := SaveSealedFile(path, passphrase, plaintext, keyringAAD); err != nil {
+		return fmt.Errorf("保存密钥环失败: %w", err)
+	}
+	return nil
+}
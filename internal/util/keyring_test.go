@@ -0,0 +1,121 @@
+package util
+
+import (
+	"encoding/base64"
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+func TestKeyringRotationKeepsOldKeys(t *testing.T) {
+	k, err := NewKeyring(AESGCM)
+	if err != nil {
+		t.Fatal(err)
+	}
+	old, err := k.Encrypt("旧密钥加密的数据")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id, _ := KeyID(old); id != 1 {
+		t.Fatalf("expected key ID 1 in the header, got %d", id)
+	}
+
+	info, err := k.Rotate(ChaCha20Poly1305, 2)
+	if err != nil || info.ID != 2 || k.Current().Cipher != ChaCha20Poly1305 {
+		t.Fatalf("rotate: %+v, %v", info, err)
+	}
+	fresh, _ := k.Encrypt("新密钥加密的数据")
+	if id, _ := KeyID(fresh); id != 2 {
+		t.Errorf("expected key ID 2 after rotation, got %d", id)
+	}
+	for _, c := range []struct{ ciphertext, want string }{{old, "旧密钥加密的数据"}, {fresh, "新密钥加密的数据"}} {
+		if got, err := k.Decrypt(c.ciphertext); err != nil || got != c.want {
+			t.Errorf("decrypt: %q, %v", got, err)
+		}
+	}
+
+	// 超出 keep 的旧密钥被删除，用它加密的数据不再能解密
+	if _, err := k.Rotate("", 2); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := k.Decrypt(old); !errors.Is(err, ErrUnknownKey) {
+		t.Errorf("expected ErrUnknownKey, got %v", err)
+	}
+	if keys := k.Keys(); len(keys) != 2 || keys[0].ID != 2 || keys[1].Cipher != ChaCha20Poly1305 {
+		t.Errorf("unexpected keys %+v", keys)
+	}
+}
+
+func TestKeyringRejectsTamperedCiphertext(t *testing.T) {
+	k, _ := NewKeyring("")
+	if _, err := k.Rotate("", 0); err != nil {
+		t.Fatal(err)
+	}
+	sealed, _ := k.Seal([]byte("payload"), []byte("tenant-a"))
+	if _, err := k.Open(sealed, []byte("tenant-b")); err == nil {
+		t.Error("expected an error for different aad")
+	}
+
+	// 把 key ID 改成另一个存在的密钥也无法通过认证
+	raw, _ := base64.URLEncoding.DecodeString(sealed)
+	raw[headerSize-1] = 1
+	if _, err := k.Open(base64.URLEncoding.EncodeToString(raw), []byte("tenant-a")); err == nil {
+		t.Error("expected an error for a rewritten key ID")
+	}
+	for name, c := range map[string]string{
+		"not base64":     "%%%",
+		"too short":      base64.URLEncoding.EncodeToString([]byte{1, 0}),
+		"unknown format": base64.URLEncoding.EncodeToString(make([]byte, 40)),
+		"unknown cipher": base64.URLEncoding.EncodeToString(append([]byte{ciphertextFormat, 9}, make([]byte, 40)...)),
+	} {
+		if _, err := k.Open(c, nil); !errors.Is(err, ErrMalformedCiphertext) {
+			t.Errorf("%s: expected ErrMalformedCiphertext, got %v", name, err)
+		}
+	}
+}
+
+func TestKeyringSealWithCipher(t *testing.T) {
+	k, _ := NewKeyring(AESGCM)
+	sealed, err := k.SealWith(ChaCha20Poly1305, []byte("payload"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 算法记录在头部，解密方不需要知道加密方的配置
+	if got, err := k.Open(sealed, nil); err != nil || string(got) != "payload" {
+		t.Errorf("open: %q, %v", got, err)
+	}
+	raw, _ := base64.URLEncoding.DecodeString(sealed)
+	raw[1] = cipherCodes[AESGCM]
+	if _, err := k.Open(base64.URLEncoding.EncodeToString(raw), nil); err == nil {
+		t.Error("expected an error for a rewritten cipher")
+	}
+}
+
+func TestKeyringSaveAndLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "keys", "keyring.json")
+	k, _ := NewKeyring(AESGCM)
+	old, _ := k.Encrypt("hello")
+	if _, err := k.Rotate("", 0); err != nil {
+		t.Fatal(err)
+	}
+	if err := k.Save(path, []byte("correct horse")); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := LoadKeyring(path, []byte("wrong")); !errors.Is(err, ErrWrongPassphrase) {
+		t.Fatalf("expected ErrWrongPassphrase, got %v", err)
+	}
+	loaded, err := LoadKeyring(path, []byte("correct horse"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loaded.Current() != k.Current() || len(loaded.Keys()) != 2 {
+		t.Errorf("loaded %+v, want current %+v", loaded.Keys(), k.Current())
+	}
+	if got, err := loaded.Decrypt(old); err != nil || got != "hello" {
+		t.Errorf("decrypt with loaded keyring: %q, %v", got, err)
+	}
+	if err := k.Save(path, nil); err == nil {
+		t.Error("expected an error for an empty passphrase")
+	}
+}
@@ -0,0 +1,92 @@
+package util
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"golang.org/x/crypto/argon2"
+)
+
+// ErrWrongPassphrase 口令错误或加密文件被篡改
+var ErrWrongPassphrase = errors.New("口令错误或加密文件已损坏")
+
+const (
+	sealedFileVersion = 1
+	saltSize          = 16
+)
+
+// sealedFile 以口令加密保存的文件：内容以口令派生的密钥 (Argon2id) 加密，Keyring 与租户密钥文件都使用这种格式
+type sealedFile struct {
+	Version int    `json:"version"`
+	KDF     string `json:"kdf"`
+	Salt    []byte `json:"salt"`
+	Cipher  Cipher `json:"cipher"`
+	Payload string `json:"payload"` // base64(nonce || 加密后的内容)
+}
+
+// deriveKey 由口令派生 32 字节的文件密钥
+func deriveKey(passphrase, salt []byte) []byte {
+	return argon2.IDKey(passphrase, salt, 1, 64*1024, 4, keySize)
+}
+
+// IsSealedFile 判断 raw 是否为 SaveSealedFile 写入的内容，用于拒绝未加密的文件
+func IsSealedFile(raw []byte) bool {
+	var f sealedFile
+	return json.Unmarshal(raw, &f) == nil && f.KDF != ""
+}
+
+// OpenSealedFile 解密 SaveSealedFile 写入的内容，aad 须与写入时相同；口令错误时返回 ErrWrongPassphrase
+func OpenSealedFile(raw, passphrase, aad []byte) ([]byte, error) {
+	var f sealedFile
+	if err := json.Unmarshal(raw, &f); err != nil {
+		return nil, fmt.Errorf("解析加密文件失败: %w", err)
+	}
+	if f.Version != sealedFileVersion || f.KDF != "argon2id" {
+		return nil, fmt.Errorf("不支持的加密文件版本 %d (%s)", f.Version, f.KDF)
+	}
+	plaintext, err := OpenWith(f.Cipher, deriveKey(passphrase, f.Salt), f.Payload, aad)
+	if err != nil {
+		if errors.Is(err, ErrUnknownCipher) {
+			return nil, err
+		}
+		return nil, ErrWrongPassphrase
+	}
+	return plaintext, nil
+}
+
+// SaveSealedFile 以口令加密 plaintext 并认证 aad，写入临时文件后重命名为 path，避免读取方看到写了一半的文件
+func SaveSealedFile(path string, passphrase, plaintext, aad []byte) error {
+	if len(passphrase) == 0 {
+		return errors.New("口令不能为空")
+	}
+	salt := make([]byte, saltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return fmt.Errorf("生成盐失败: %w", err)
+	}
+	f := sealedFile{Version: sealedFileVersion, KDF: "argon2id", Salt: salt, Cipher: PreferredCipher()}
+	var err error
+	if f.Payload, err = SealWith(f.Cipher, deriveKey(passphrase, salt), plaintext, aad); err != nil {
+		return fmt.Errorf("加密失败: %w", err)
+	}
+	raw, err := json.MarshalIndent(f, "", "  ")
+	if err != nil {
+		return err
+	}
+	if dir := filepath.Dir(path); dir != "." {
+		if err := os.MkdirAll(dir, 0o700); err != nil {
+			return fmt.Errorf("创建目录失败: %w", err)
+		}
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, raw, 0o600); err != nil {
+		return fmt.Errorf("写入文件失败: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("写入文件失败: %w", err)
+	}
+	return nil
+}
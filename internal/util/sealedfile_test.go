@@ -0,0 +1,36 @@
+package util
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSealedFileRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "keys", "sealed.json")
+	aad := []byte("ollama_dev/test")
+	if err := SaveSealedFile(path, []byte("correct horse"), []byte("secret"), aad); err != nil {
+		t.Fatal(err)
+	}
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !IsSealedFile(raw) || IsSealedFile([]byte(`{"tenants":{}}`)) {
+		t.Fatal("IsSealedFile misclassified the files")
+	}
+	if got, err := OpenSealedFile(raw, []byte("correct horse"), aad); err != nil || string(got) != "secret" {
+		t.Fatalf("OpenSealedFile = %q, %v", got, err)
+	}
+	// 口令或附加数据不同都无法解密
+	if _, err := OpenSealedFile(raw, []byte("wrong"), aad); !errors.Is(err, ErrWrongPassphrase) {
+		t.Errorf("wrong passphrase: expected ErrWrongPassphrase, got %v", err)
+	}
+	if _, err := OpenSealedFile(raw, []byte("correct horse"), []byte("other")); !errors.Is(err, ErrWrongPassphrase) {
+		t.Errorf("wrong aad: expected ErrWrongPassphrase, got %v", err)
+	}
+	if err := SaveSealedFile(path, nil, []byte("secret"), aad); err == nil {
+		t.Error("expected an error for an empty passphrase")
+	}
+}
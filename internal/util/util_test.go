@@ -4,9 +4,18 @@ import (
 	"testing"
 )
 
+func newKey(tb testing.TB) []byte {
+	tb.Helper()
+	key, err := NewDecryptKey()
+	if err != nil {
+		tb.Fatal(err)
+	}
+	return key
+}
+
 func TestEncryptDecrypt(t *testing.T) {
 	// Generate a test key
-	key := NewDecryptKey()
+	key := newKey(t)
 
 	// Test data
 	plaintext := "Hello, secure world!"
@@ -35,7 +44,7 @@ func TestEncryptDecrypt(t *testing.T) {
 
 func TestDecryptInvalidCiphertext(t *testing.T) {
 	// Generate a test key
-	key := NewDecryptKey()
+	key := newKey(t)
 
 	// Invalid ciphertext (shorter than nonce size)
 	invalidCiphertext := "short"
@@ -50,7 +59,7 @@ func TestDecryptInvalidCiphertext(t *testing.T) {
 
 func TestDecryptEmptyCiphertext(t *testing.T) {
 	// Generate a test key
-	key := NewDecryptKey()
+	key := newKey(t)
 
 	// Empty ciphertext
 	emptyCiphertext := ""
@@ -66,7 +75,7 @@ func TestDecryptEmptyCiphertext(t *testing.T) {
 
 func TestEncryptDecryptEmptyString(t *testing.T) {
 	// Generate a test key
-	key := NewDecryptKey()
+	key := newKey(t)
 
 	// Test data: empty string
 	plaintext := ""
@@ -95,7 +104,7 @@ func TestEncryptDecryptEmptyString(t *testing.T) {
 
 func TestEncryptDecryptLongString(t *testing.T) {
 	// Generate a test key
-	key := NewDecryptKey()
+	key := newKey(t)
 
 	// Test data: a long string
 	plaintext := "This is a very long string that will be encrypted and decrypted using AES-GCM. " +
@@ -125,9 +134,9 @@ func TestEncryptDecryptLongString(t *testing.T) {
 
 func TestEncryptDecryptWithDifferentKeys(t *testing.T) {
 	// Generate two different keys
-	key1 := NewDecryptKey()
+	key1 := newKey(t)
 
-	key2 := NewDecryptKey()
+	key2 := newKey(t)
 
 	// Test data
 	plaintext := "Hello, secure world!"
@@ -0,0 +1,194 @@
+package wsutils
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
+)
+
+// AckType 对端确认消息的类型，形如 {"type":"ack","data":{"id":"..."}}；该类型由 WebSocketManager 处理，不能再用 On 注册
+const AckType = "ack"
+
+var (
+	// ErrAckTimeout 重发次数用尽仍未收到确认
+	ErrAckTimeout = errors.New("重发次数用尽仍未收到确认")
+	// ErrClientGone 收到确认前连接已断开
+	ErrClientGone = errors.New("连接已断开")
+)
+
+const (
+	defaultAckTimeout = 5 * time.Second
+	defaultAckRetries = 3
+)
+
+// AckOptions 需要确认的消息的重发设置，零值使用默认值
+type AckOptions struct {
+	Timeout time.Duration        // 等待确认的时长，超时后重发，默认 5s
+	Retries int                  // 最多重发的次数，默认 3；小于 0 时不重发
+	Report  func(DeliveryReport) // 可为 nil；每个客户端在确认或放弃时调用一次，可能在不同 goroutine 中并发调用，不应阻塞
+}
+
+// DeliveryReport 一条需要确认的消息在一个客户端上的投递结果
+type DeliveryReport struct {
+	ID         string
+	RemoteAddr string
+	Attempts   int   // 写入发送队列的次数，含首次发送
+	Err        error // nil 表示已确认，否则为 ErrAckTimeout 或 ErrClientGone
+}
+
+// pendingAck 一个客户端上等待确认的消息
+type pendingAck struct {
+	msg      outbound
+	opts     AckOptions
+	attempts int
+	timer    *time.Timer
+}
+
+// SendAcked 向 conn 发送需要确认的消息并返回消息 ID。消息形如 {"type":1,"id":"...","data":...}，
+// 对端收到后回复 ack；超时未确认时重发，因此同一 ID 可能收到多次，对端应按 ID 去重
+func (m *WebSocketManager) SendAcked(conn *websocket.Conn, messageType int, data interface{}, opts AckOptions) (string, error) {
+	c := m.lookup(conn)
+	if c == nil {
+		return "", fmt.Errorf("连接不受管理，无法等待确认: %s", conn.RemoteAddr())
+	}
+	msg, id, err := newAckedMessage(messageType, data)
+	if err != nil {
+		return "", err
+	}
+	m.track(c, id, msg, opts)
+	return id, nil
+}
+
+// BroadcastAcked 向当前全部客户端发送同一条需要确认的消息并返回消息 ID，各客户端独立确认与重发，
+// opts.Report 对每个客户端各调用一次；与 Broadcast 不同，消息直接进入各客户端的发送队列
+func (m *WebSocketManager) BroadcastAcked(messageType int, data interface{}, opts AckOptions) (string, error) {
+	msg, id, err := newAckedMessage(messageType, data)
+	if err != nil {
+		return "", err
+	}
+	m.mu.Lock()
+	clients := make([]*client, 0, len(m.clients))
+	for _, c := range m.clients {
+		clients = append(clients, c)
+	}
+	m.mu.Unlock()
+
+	for _, c := range clients {
+		m.track(c, id, msg, opts)
+	}
+	return id, nil
+}
+
+func newAckedMessage(messageType int, data interface{}) (outbound, string, error) {
+	id := uuid.NewString()
+	buf, err := EncodeJSON(Message{Type: messageType, ID: id, Data: data})
+	if err != nil {
+		return outbound{}, "", fmt.Errorf("序列化消息失败: %w", err)
+	}
+	defer PutBuffer(buf)
+	return outbound{messageType: messageType, data: bytes.Clone(buf.Bytes())}, id, nil
+}
+
+// track 登记等待确认的消息并首次发送，客户端已关闭时直接报告 ErrClientGone
+func (m *WebSocketManager) track(c *client, id string, msg outbound, opts AckOptions) {
+	if opts.Timeout <= 0 {
+		opts.Timeout = defaultAckTimeout
+	}
+	if opts.Retries == 0 {
+		opts.Retries = defaultAckRetries
+	}
+	p := &pendingAck{msg: msg, opts: opts}
+	c.ackMu.Lock()
+	if c.pending == nil {
+		c.ackMu.Unlock()
+		c.report(id, p, ErrClientGone)
+		return
+	}
+	c.pending[id] = p
+	c.ackMu.Unlock()
+	m.attempt(c, id)
+}
+
+// attempt 发送或重发 id，超过重发次数时放弃；入队失败时按溢出策略断开客户端，未确认的消息随之报告
+func (m *WebSocketManager) attempt(c *client, id string) {
+	c.ackMu.Lock()
+	p, ok := c.pending[id]
+	if !ok {
+		c.ackMu.Unlock()
+		return
+	}
+	if p.attempts > max(p.opts.Retries, 0) {
+		delete(c.pending, id)
+		c.ackMu.Unlock()
+		c.report(id, p, ErrAckTimeout)
+		return
+	}
+	p.attempts++
+	p.timer = time.AfterFunc(p.opts.Timeout, func() { m.attempt(c, id) })
+	c.ackMu.Unlock()
+
+	if !c.enqueue(p.msg) {
+		m.logger.Warn("客户端发送队列已满，断开连接", "remote", c.conn.RemoteAddr())
+		m.remove(c)
+	}
+}
+
+// handleAck 处理对端的 ack，未知或已确认的 ID 忽略 (重发的消息会被重复确认)
+func (m *WebSocketManager) handleAck(ctx context.Context, conn *websocket.Conn, payload json.RawMessage) error {
+	var ack struct {
+		ID string `json:"id"`
+	}
+	if err := json.Unmarshal(payload, &ack); err != nil || ack.ID == "" {
+		return fmt.Errorf("确认消息缺少 id")
+	}
+	if c := m.lookup(conn); c != nil {
+		c.acked(ack.ID)
+	}
+	return nil
+}
+
+// acked 结束 id 的重发并报告确认
+func (c *client) acked(id string) {
+	c.ackMu.Lock()
+	p, ok := c.pending[id]
+	if ok {
+		p.timer.Stop()
+		delete(c.pending, id)
+	}
+	c.ackMu.Unlock()
+	if ok {
+		c.report(id, p, nil)
+	}
+}
+
+// failPending 连接关闭时报告全部未确认的消息，之后登记的消息直接报告 ErrClientGone
+func (c *client) failPending() {
+	c.ackMu.Lock()
+	pending := c.pending
+	c.pending = nil
+	c.ackMu.Unlock()
+	for id, p := range pending {
+		if p.timer != nil {
+			p.timer.Stop()
+		}
+		c.report(id, p, ErrClientGone)
+	}
+}
+
+// report 调用 Report 回调；p 已从 pending 中移除，attempts 不再变化
+func (c *client) report(id string, p *pendingAck, err error) {
+	if p.opts.Report == nil {
+		return
+	}
+	r := DeliveryReport{ID: id, Attempts: p.attempts, Err: err}
+	if c.conn != nil {
+		r.RemoteAddr = c.conn.RemoteAddr().String()
+	}
+	p.opts.Report(r)
+}
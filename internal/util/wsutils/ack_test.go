@@ -0,0 +1,109 @@
+package wsutils
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// readAcked 读取一条需要确认的消息
+func readAcked(t *testing.T, peer *websocket.Conn) Message {
+	t.Helper()
+	peer.SetReadDeadline(time.Now().Add(2 * time.Second))
+	_, raw, err := peer.ReadMessage()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var msg Message
+	if err := json.Unmarshal(raw, &msg); err != nil || msg.ID == "" {
+		t.Fatalf("收到 %s, err=%v", raw, err)
+	}
+	return msg
+}
+
+func waitReport(t *testing.T, reports <-chan DeliveryReport) DeliveryReport {
+	t.Helper()
+	select {
+	case r := <-reports:
+		return r
+	case <-time.After(2 * time.Second):
+		t.Fatal("没有收到投递结果")
+		return DeliveryReport{}
+	}
+}
+
+func TestBroadcastAckedRedeliversUntilAcked(t *testing.T) {
+	m, conns, url := newManagerServer(t, Config{})
+	peer, _, err := websocket.DefaultDialer.Dial(url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer peer.Close()
+	<-conns
+
+	reports := make(chan DeliveryReport, 1)
+	id, err := m.BroadcastAcked(TextMessage, "policy", AckOptions{Timeout: 50 * time.Millisecond, Report: func(r DeliveryReport) { reports <- r }})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 首次发送不确认，超时后收到同一 ID 的重发，确认后不再重发
+	first := readAcked(t, peer)
+	second := readAcked(t, peer)
+	if first.ID != id || second.ID != id || second.Data != "policy" {
+		t.Fatalf("unexpected messages %+v %+v", first, second)
+	}
+	if err := peer.WriteJSON(map[string]any{"type": AckType, "data": map[string]string{"id": id}}); err != nil {
+		t.Fatal(err)
+	}
+	if r := waitReport(t, reports); r.ID != id || r.Err != nil || r.Attempts < 2 {
+		t.Fatalf("unexpected report %+v", r)
+	}
+	// 重复的确认被忽略
+	if err := peer.WriteJSON(map[string]any{"type": AckType, "data": map[string]string{"id": id}}); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case r := <-reports:
+		t.Errorf("unexpected second report %+v", r)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestSendAckedGivesUp(t *testing.T) {
+	m, conns, url := newManagerServer(t, Config{})
+	peer, _, err := websocket.DefaultDialer.Dial(url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer peer.Close()
+	conn := <-conns
+
+	reports := make(chan DeliveryReport, 1)
+	report := func(r DeliveryReport) { reports <- r }
+
+	// 一直不确认时重发 Retries 次后放弃
+	id, err := m.SendAcked(conn, TextMessage, "policy", AckOptions{Timeout: 20 * time.Millisecond, Retries: 2, Report: report})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for range 3 {
+		readAcked(t, peer)
+	}
+	if r := waitReport(t, reports); r.ID != id || !errors.Is(r.Err, ErrAckTimeout) || r.Attempts != 3 {
+		t.Fatalf("unexpected report %+v", r)
+	}
+
+	// 连接断开时未确认的消息立即报告
+	id, _ = m.SendAcked(conn, TextMessage, "policy", AckOptions{Timeout: time.Minute, Report: report})
+	peer.Close()
+	if r := waitReport(t, reports); r.ID != id || !errors.Is(r.Err, ErrClientGone) || r.Attempts != 1 {
+		t.Fatalf("unexpected report %+v", r)
+	}
+	if _, err := m.SendAcked(conn, TextMessage, "policy", AckOptions{}); err == nil {
+		t.Error("expected an error for a closed connection")
+	}
+}
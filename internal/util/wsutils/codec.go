@@ -0,0 +1,116 @@
+package wsutils
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+
+	"github.com/gorilla/websocket"
+	"github.com/ugorji/go/codec"
+)
+
+// Codec 连接上的帧编码。程序内部的帧统一为 JSON，写入前由 Encode 转换，
+// 读取时二进制帧由 Decode 还原为 JSON，文本帧原样返回，因此对端可以混用两种帧
+type Codec interface {
+	Name() string
+	MessageType() int                    // 写入时使用的帧类型
+	Encode(frame []byte) ([]byte, error) // JSON 转为线上格式
+	Decode(data []byte) ([]byte, error)  // 线上格式 (二进制帧) 转为 JSON
+}
+
+// SubprotocolMsgpack 选用 Msgpack 编码的 WebSocket 子协议
+const SubprotocolMsgpack = "ollama.v1.msgpack"
+
+// 可选的编码
+var (
+	JSON    Codec = jsonCodec{}
+	Msgpack Codec = msgpackCodec{}
+)
+
+// CodecByName 按名称 (json、msgpack) 返回编码
+func CodecByName(name string) (Codec, bool) {
+	switch name {
+	case "json", "":
+		return JSON, true
+	case "msgpack":
+		return Msgpack, true
+	}
+	return nil, false
+}
+
+// CodecFor 返回握手协商的子协议对应的编码，未协商或为其他子协议时为 JSON
+func CodecFor(subprotocol string) Codec {
+	if subprotocol == SubprotocolMsgpack {
+		return Msgpack
+	}
+	return JSON
+}
+
+// DialCodec 按 c 在握手时请求对应的子协议，可作为 auth.Dial 的选项；对端不支持时连接照常建立，按 CodecFor 回退为 JSON
+func DialCodec(c Codec) func(*websocket.Dialer) {
+	return func(d *websocket.Dialer) {
+		if c == Msgpack {
+			d.Subprotocols = []string{SubprotocolMsgpack}
+		}
+	}
+}
+
+type jsonCodec struct{}
+
+func (jsonCodec) Name() string                        { return "json" }
+func (jsonCodec) MessageType() int                    { return websocket.TextMessage }
+func (jsonCodec) Encode(frame []byte) ([]byte, error) { return frame, nil }
+func (jsonCodec) Decode(data []byte) ([]byte, error)  { return data, nil }
+
+// msgpackCodec 以 MessagePack 二进制帧收发，字段名与 JSON 相同；数值与字符串按 MessagePack 的紧凑格式编码，
+// 适合带大量数值的帧 (例如嵌入向量)
+type msgpackCodec struct{}
+
+var msgpackHandle = func() *codec.MsgpackHandle {
+	h := &codec.MsgpackHandle{WriteExt: true}
+	h.MapType = reflect.TypeOf(map[string]any(nil))
+	h.RawToString = true
+	return h
+}()
+
+func (msgpackCodec) Name() string     { return "msgpack" }
+func (msgpackCodec) MessageType() int { return websocket.BinaryMessage }
+
+func (msgpackCodec) Encode(frame []byte) ([]byte, error) {
+	var v any
+	if err := json.Unmarshal(frame, &v); err != nil {
+		return nil, fmt.Errorf("帧不是有效的 JSON: %w", err)
+	}
+	var out []byte
+	if err := codec.NewEncoderBytes(&out, msgpackHandle).Encode(v); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (msgpackCodec) Decode(data []byte) ([]byte, error) {
+	var v any
+	if err := codec.NewDecoderBytes(data, msgpackHandle).Decode(&v); err != nil {
+		return nil, fmt.Errorf("解析 MessagePack 帧失败: %w", err)
+	}
+	return json.Marshal(v)
+}
+
+// ReadFrame 读取一条消息并按 c 还原为 JSON：二进制帧经 c.Decode 转换，文本帧原样返回
+func ReadFrame(conn *websocket.Conn, c Codec) ([]byte, error) {
+	messageType, data, err := ReadMessage(conn)
+	if err != nil || messageType != websocket.BinaryMessage {
+		return data, err
+	}
+	return c.Decode(data)
+}
+
+// WriteFrame 按 c 编码 JSON 帧并写入，连接协商了压缩时只压缩不小于 threshold 字节的帧
+func WriteFrame(conn *websocket.Conn, c Codec, frame []byte, threshold int) error {
+	data, err := c.Encode(frame)
+	if err != nil {
+		return err
+	}
+	conn.EnableWriteCompression(len(data) >= threshold)
+	return conn.WriteMessage(c.MessageType(), data)
+}
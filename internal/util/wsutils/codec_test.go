@@ -0,0 +1,110 @@
+package wsutils
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/websocket"
+
+	"ollama_dev/internal/config"
+)
+
+func TestMsgpackRoundTrip(t *testing.T) {
+	frame := []byte(`{"type":"client_to_server","request_id":"r1","data":{"embeddings":[[0.5,-1.25]],"count":3,"ok":true,"note":null}}`)
+	encoded, err := Msgpack.Encode(frame)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if json.Valid(encoded) {
+		t.Fatal("expected binary MessagePack output")
+	}
+	decoded, err := Msgpack.Decode(encoded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !sameJSON(t, decoded, frame) {
+		t.Errorf("round trip = %s, want %s", decoded, frame)
+	}
+
+	if _, err := Msgpack.Encode([]byte("not json")); err == nil {
+		t.Error("expected error for invalid JSON")
+	}
+}
+
+func sameJSON(t *testing.T, a, b []byte) bool {
+	t.Helper()
+	var va, vb any
+	if err := json.Unmarshal(a, &va); err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(b, &vb); err != nil {
+		t.Fatal(err)
+	}
+	return reflect.DeepEqual(va, vb)
+}
+
+func TestCodecSelection(t *testing.T) {
+	if CodecFor(SubprotocolMsgpack) != Msgpack || CodecFor("ollama.v1.json") != JSON || CodecFor("") != JSON {
+		t.Error("CodecFor picked the wrong codec")
+	}
+	if c, ok := CodecByName("msgpack"); !ok || c != Msgpack {
+		t.Error("CodecByName(msgpack) failed")
+	}
+	if _, ok := CodecByName("protobuf"); ok {
+		t.Error("unknown codec accepted")
+	}
+}
+
+// 压缩与编码均协商成功时，服务端读到的仍是原 JSON
+func TestCompressedMsgpackConnection(t *testing.T) {
+	cfg := config.CompressionConfig{Enabled: true, Level: 1, Threshold: 16}
+	received := make(chan []byte, 2)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		upgrader := websocket.Upgrader{EnableCompression: true, Subprotocols: []string{SubprotocolMsgpack}}
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		codec := CodecFor(conn.Subprotocol())
+		for {
+			frame, err := ReadFrame(conn, codec)
+			if err != nil {
+				return
+			}
+			received <- frame
+		}
+	}))
+	defer srv.Close()
+
+	dialer := *websocket.DefaultDialer
+	DialCodec(Msgpack)(&dialer)
+	DialCompression(cfg)(&dialer)
+	conn, resp, err := dialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	if !strings.Contains(resp.Header.Get("Sec-WebSocket-Extensions"), "permessage-deflate") {
+		t.Errorf("compression not negotiated: %v", resp.Header)
+	}
+	SetCompression(conn, cfg)
+	codec := CodecFor(conn.Subprotocol())
+	if codec != Msgpack {
+		t.Fatalf("subprotocol = %q", conn.Subprotocol())
+	}
+	large := `{"type":"client_to_server","data":"` + strings.Repeat("a", 4096) + `"}`
+	for _, frame := range []string{`{"type":"heartbeat"}`, large} {
+		if err := WriteFrame(conn, codec, []byte(frame), cfg.Threshold); err != nil {
+			t.Fatal(err)
+		}
+		// 经 MessagePack 转换后键按字母排序，内容不变
+		if got := <-received; !sameJSON(t, got, []byte(frame)) {
+			t.Errorf("received %.60s, want %.60s", got, frame)
+		}
+	}
+}
@@ -0,0 +1,21 @@
+package wsutils
+
+import (
+	"github.com/gorilla/websocket"
+
+	"ollama_dev/internal/config"
+)
+
+// DialCompression 按 cfg 在握手时请求 permessage-deflate，可作为 auth.Dial 的选项
+func DialCompression(cfg config.CompressionConfig) func(*websocket.Dialer) {
+	return func(d *websocket.Dialer) {
+		d.EnableCompression = cfg.Enabled
+	}
+}
+
+// SetCompression 按 cfg 设置已建立连接的压缩级别，对端未同意压缩时不生效
+func SetCompression(conn *websocket.Conn, cfg config.CompressionConfig) {
+	if cfg.Enabled {
+		_ = conn.SetCompressionLevel(cfg.Level)
+	}
+}
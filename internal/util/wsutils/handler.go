@@ -0,0 +1,101 @@
+package wsutils
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"runtime/debug"
+	"sync"
+
+	"github.com/gorilla/websocket"
+)
+
+// HandlerFunc 处理一条具名消息，payload 为消息的 data 字段；ctx 在连接断开或管理器关闭时结束，
+// 经 WebSocketManager 分发时附带该消息的请求 ID (logging.RequestID)
+type HandlerFunc func(ctx context.Context, conn *websocket.Conn, payload json.RawMessage) error
+
+// Middleware 包装 HandlerFunc，在调用 next 前后执行的逻辑即前置与后置钩子
+type Middleware func(next HandlerFunc) HandlerFunc
+
+type messageTypeKey struct{}
+
+// MessageType 返回 ctx 中正在处理的消息类型，不在处理流程中时返回空串
+func MessageType(ctx context.Context) string {
+	name, _ := ctx.Value(messageTypeKey{}).(string)
+	return name
+}
+
+// HandlerRegistry 按消息类型注册处理器，形如 {"type":"chat","data":{...}} 的消息交给 type 对应的处理器
+type HandlerRegistry struct {
+	mu         sync.RWMutex
+	handlers   map[string]HandlerFunc
+	middleware []Middleware
+	fallback   HandlerFunc
+}
+
+// NewHandlerRegistry 创建一个空的处理器注册表
+func NewHandlerRegistry() *HandlerRegistry {
+	return &HandlerRegistry{handlers: make(map[string]HandlerFunc)}
+}
+
+// On 注册消息类型的处理器；类型为空、处理器为 nil 或重复注册时 panic
+func (r *HandlerRegistry) On(name string, h HandlerFunc) {
+	if name == "" || h == nil {
+		panic("wsutils: 消息处理器缺少类型或处理函数")
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, dup := r.handlers[name]; dup {
+		panic(fmt.Sprintf("wsutils: 消息类型 %s 重复注册", name))
+	}
+	r.handlers[name] = h
+}
+
+// Use 追加中间件，先添加的在外层；对之后分发的所有消息（包括 Fallback）生效
+func (r *HandlerRegistry) Use(mw ...Middleware) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.middleware = append(r.middleware, mw...)
+}
+
+// Fallback 设置未注册类型的处理器，为 nil 时未注册类型返回错误
+func (r *HandlerRegistry) Fallback(h HandlerFunc) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.fallback = h
+}
+
+// Dispatch 经中间件调用 name 对应的处理器，ctx 中附带消息类型
+func (r *HandlerRegistry) Dispatch(ctx context.Context, conn *websocket.Conn, name string, payload json.RawMessage) error {
+	r.mu.RLock()
+	h, ok := r.handlers[name]
+	if !ok {
+		h = r.fallback
+	}
+	mw := r.middleware
+	r.mu.RUnlock()
+
+	if h == nil {
+		return fmt.Errorf("未注册的消息类型: %s", name)
+	}
+	for i := len(mw) - 1; i >= 0; i-- {
+		h = mw[i](h)
+	}
+	return h(context.WithValue(ctx, messageTypeKey{}, name), conn, payload)
+}
+
+// Recover 将处理器中的 panic 转为错误并记录堆栈，使读取循环可以继续处理后续消息
+func Recover(logger *slog.Logger) Middleware {
+	return func(next HandlerFunc) HandlerFunc {
+		return func(ctx context.Context, conn *websocket.Conn, payload json.RawMessage) (err error) {
+			defer func() {
+				if rec := recover(); rec != nil {
+					logger.ErrorContext(ctx, "消息处理器发生 panic", "type", MessageType(ctx), "panic", rec, "stack", string(debug.Stack()))
+					err = fmt.Errorf("处理 %s 消息时发生 panic: %v", MessageType(ctx), rec)
+				}
+			}()
+			return next(ctx, conn, payload)
+		}
+	}
+}
@@ -0,0 +1,144 @@
+package wsutils
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestHandlerRegistryMiddlewareOrder(t *testing.T) {
+	r := NewHandlerRegistry()
+	var calls []string
+	trace := func(tag string) Middleware {
+		return func(next HandlerFunc) HandlerFunc {
+			return func(ctx context.Context, conn *websocket.Conn, payload json.RawMessage) error {
+				calls = append(calls, tag+":pre:"+MessageType(ctx))
+				err := next(ctx, conn, payload)
+				calls = append(calls, tag+":post")
+				return err
+			}
+		}
+	}
+	r.Use(trace("a"), trace("b"))
+	r.On("chat", func(ctx context.Context, conn *websocket.Conn, payload json.RawMessage) error {
+		calls = append(calls, "chat:"+string(payload))
+		return nil
+	})
+
+	if err := r.Dispatch(context.Background(), nil, "chat", json.RawMessage(`{"x":1}`)); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"a:pre:chat", "b:pre:chat", `chat:{"x":1}`, "b:post", "a:post"}
+	if strings.Join(calls, ",") != strings.Join(want, ",") {
+		t.Errorf("calls = %v, want %v", calls, want)
+	}
+}
+
+func TestHandlerRegistryFallback(t *testing.T) {
+	r := NewHandlerRegistry()
+	if err := r.Dispatch(context.Background(), nil, "nope", nil); err == nil {
+		t.Fatal("未注册类型应返回错误")
+	}
+
+	var got string
+	r.Fallback(func(ctx context.Context, conn *websocket.Conn, payload json.RawMessage) error {
+		got = MessageType(ctx)
+		return nil
+	})
+	if err := r.Dispatch(context.Background(), nil, "nope", nil); err != nil {
+		t.Fatal(err)
+	}
+	if got != "nope" {
+		t.Errorf("Fallback 收到类型 %q", got)
+	}
+}
+
+func TestHandlerRegistryDuplicatePanics(t *testing.T) {
+	r := NewHandlerRegistry()
+	h := func(context.Context, *websocket.Conn, json.RawMessage) error { return nil }
+	r.On("chat", h)
+	defer func() {
+		if recover() == nil {
+			t.Error("重复注册应 panic")
+		}
+	}()
+	r.On("chat", h)
+}
+
+func TestRecoverMiddleware(t *testing.T) {
+	r := NewHandlerRegistry()
+	r.Use(Recover(slog.New(slog.NewTextHandler(io.Discard, nil))))
+	r.On("boom", func(context.Context, *websocket.Conn, json.RawMessage) error {
+		panic("bad")
+	})
+	sentinel := errors.New("sentinel")
+	r.On("fail", func(context.Context, *websocket.Conn, json.RawMessage) error {
+		return sentinel
+	})
+
+	if err := r.Dispatch(context.Background(), nil, "boom", nil); err == nil || !strings.Contains(err.Error(), "bad") {
+		t.Errorf("panic 应转为错误, got %v", err)
+	}
+	if err := r.Dispatch(context.Background(), nil, "fail", nil); !errors.Is(err, sentinel) {
+		t.Errorf("处理器错误应原样返回, got %v", err)
+	}
+}
+
+func TestManagerDispatchesNamedMessages(t *testing.T) {
+	m, conns, url := newManagerServer(t, Config{})
+	done := make(chan error, 1)
+	m.On("panic", func(context.Context, *websocket.Conn, json.RawMessage) error {
+		panic("handler bug")
+	})
+	m.On("echo", func(ctx context.Context, conn *websocket.Conn, payload json.RawMessage) error {
+		var p struct{ Text string }
+		if err := json.Unmarshal(payload, &p); err != nil {
+			return err
+		}
+		if err := m.SendMessage(conn, TextMessage, p.Text); err != nil {
+			return err
+		}
+		// ctx 在连接断开后结束
+		go func() {
+			<-ctx.Done()
+			done <- context.Cause(ctx)
+		}()
+		return nil
+	})
+
+	peer, _, err := websocket.DefaultDialer.Dial(url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	<-conns
+
+	// 前一条消息的处理器 panic 不影响后续消息
+	for _, raw := range []string{`{"type":"panic"}`, `{"type":"echo","data":{"text":"hi"}}`} {
+		if err := peer.WriteMessage(TextMessage, []byte(raw)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	peer.SetReadDeadline(time.Now().Add(2 * time.Second))
+	_, raw, err := peer.ReadMessage()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var msg Message
+	if err := json.Unmarshal(raw, &msg); err != nil || msg.Data != "hi" {
+		t.Fatalf("收到 %s, err=%v", raw, err)
+	}
+
+	peer.Close()
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("连接断开后 ctx 未结束")
+	}
+}
@@ -0,0 +1,156 @@
+package wsutils
+
+import (
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestEnqueueDropOldest(t *testing.T) {
+	c := newClient(nil, Config{QueueSize: 2})
+
+	for i := 0; i < 5; i++ {
+		if !c.enqueue(outbound{messageType: TextMessage, data: []byte{byte('0' + i)}}) {
+			t.Fatalf("enqueue %d 返回 false", i)
+		}
+	}
+
+	// 队列只保留最新的两条，其余计入丢弃
+	if got := c.stats(); got.QueueLen != 2 || got.QueueCap != 2 || got.Dropped != 3 {
+		t.Fatalf("stats = %+v", got)
+	}
+	if msg := <-c.send; string(msg.data) != "3" {
+		t.Errorf("队首 = %q, want 3", msg.data)
+	}
+}
+
+func TestEnqueueDisconnect(t *testing.T) {
+	c := newClient(nil, Config{QueueSize: 1, Overflow: OverflowDisconnect})
+
+	if !c.enqueue(outbound{data: []byte("a")}) {
+		t.Fatal("首条消息不应溢出")
+	}
+	if c.enqueue(outbound{data: []byte("b")}) {
+		t.Fatal("队列已满时应要求断开")
+	}
+	c.close()
+	if c.enqueue(outbound{data: []byte("c")}) {
+		t.Fatal("已关闭的客户端不应再入队")
+	}
+}
+
+func newManagerServer(t *testing.T, cfg Config) (*WebSocketManager, chan *websocket.Conn, string) {
+	t.Helper()
+	m := NewWebSocketManager(slog.New(slog.NewTextHandler(io.Discard, nil)))
+	conns := make(chan *websocket.Conn, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := m.Upgrade(w, r, cfg)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		conns <- conn
+	}))
+	t.Cleanup(func() {
+		m.Close()
+		srv.Close()
+	})
+	return m, conns, "ws" + strings.TrimPrefix(srv.URL, "http")
+}
+
+func TestSendMessageDelivered(t *testing.T) {
+	m, conns, url := newManagerServer(t, Config{})
+
+	peer, _, err := websocket.DefaultDialer.Dial(url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer peer.Close()
+	conn := <-conns
+
+	if err := m.SendMessage(conn, TextMessage, "hello"); err != nil {
+		t.Fatal(err)
+	}
+	peer.SetReadDeadline(time.Now().Add(2 * time.Second))
+	_, raw, err := peer.ReadMessage()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var msg Message
+	if err := json.Unmarshal(raw, &msg); err != nil || msg.Data != "hello" {
+		t.Fatalf("收到 %s, err=%v", raw, err)
+	}
+
+	stats := m.Stats()
+	if len(stats) != 1 || stats[0].QueueCap != defaultQueueSize {
+		t.Fatalf("Stats = %+v", stats)
+	}
+}
+
+func TestBroadcastNotBlockedBySlowClient(t *testing.T) {
+	m, conns, url := newManagerServer(t, Config{QueueSize: 4, WriteTimeout: 200 * time.Millisecond})
+	go m.ListenBroadcasts()
+
+	// 慢客户端从不读取，快客户端持续读取；固定两端的套接字缓冲区，
+	// 否则内核自动调大缓冲区，慢客户端何时写满取决于机器负载
+	slow, _, err := websocket.DefaultDialer.Dial(url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer slow.Close()
+	if err := slow.NetConn().(*net.TCPConn).SetReadBuffer(4 << 10); err != nil {
+		t.Fatal(err)
+	}
+	if err := (<-conns).NetConn().(*net.TCPConn).SetWriteBuffer(4 << 10); err != nil {
+		t.Fatal(err)
+	}
+	fast, _, err := websocket.DefaultDialer.Dial(url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fast.Close()
+	<-conns
+
+	payload := strings.Repeat("x", 256<<10)
+	var received atomic.Int64
+	go func() {
+		for {
+			if _, _, err := fast.ReadMessage(); err != nil {
+				return
+			}
+			received.Add(1)
+		}
+	}()
+
+	// 持续广播直到慢客户端的内核缓冲区写满、写入超时被断开；单次广播不应被它阻塞
+	deadline := time.Now().Add(10 * time.Second)
+	for len(m.Stats()) > 1 {
+		if time.Now().After(deadline) {
+			t.Fatalf("慢客户端未被断开，剩余 %d 个客户端", len(m.Stats()))
+		}
+		start := time.Now()
+		m.Broadcast(TextMessage, payload)
+		if d := time.Since(start); d > time.Second {
+			t.Fatalf("广播被慢客户端阻塞 %v", d)
+		}
+	}
+
+	// 快客户端照常收到消息
+	m.Broadcast(TextMessage, "last")
+	deadline = time.Now().Add(5 * time.Second)
+	for received.Load() == 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if received.Load() == 0 {
+		t.Fatal("快客户端没有收到任何消息")
+	}
+}
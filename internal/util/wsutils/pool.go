@@ -0,0 +1,67 @@
+package wsutils
+
+import (
+	"bytes"
+	"encoding/json"
+	"sync"
+
+	"github.com/gorilla/websocket"
+)
+
+// maxPooledBuffer 超过该容量的缓冲区不放回池中，避免偶发的大消息长期占用内存
+const maxPooledBuffer = 64 << 10
+
+var bufferPool = sync.Pool{
+	New: func() any { return new(bytes.Buffer) },
+}
+
+// GetBuffer 从池中取出一个已清空的缓冲区，用完后调用 PutBuffer 归还
+func GetBuffer() *bytes.Buffer {
+	buf := bufferPool.Get().(*bytes.Buffer)
+	buf.Reset()
+	return buf
+}
+
+// PutBuffer 归还缓冲区，归还后不得再使用其内容
+func PutBuffer(buf *bytes.Buffer) {
+	if buf == nil || buf.Cap() > maxPooledBuffer {
+		return
+	}
+	bufferPool.Put(buf)
+}
+
+// EncodeJSON 将 v 序列化到池化缓冲区，输出与 json.Marshal 一致 (不含末尾换行)
+func EncodeJSON(v any) (*bytes.Buffer, error) {
+	buf := GetBuffer()
+	if err := json.NewEncoder(buf).Encode(v); err != nil {
+		PutBuffer(buf)
+		return nil, err
+	}
+	buf.Truncate(buf.Len() - 1)
+	return buf, nil
+}
+
+// WriteJSON 经池化缓冲区序列化 v 并以 messageType 写入连接
+func WriteJSON(conn *websocket.Conn, messageType int, v any) error {
+	buf, err := EncodeJSON(v)
+	if err != nil {
+		return err
+	}
+	defer PutBuffer(buf)
+	return conn.WriteMessage(messageType, buf.Bytes())
+}
+
+// ReadMessage 经池化缓冲区读取一条消息，返回按实际长度分配的副本，
+// 相比 websocket.Conn.ReadMessage 省去逐步扩容产生的中间分配
+func ReadMessage(conn *websocket.Conn) (int, []byte, error) {
+	messageType, r, err := conn.NextReader()
+	if err != nil {
+		return messageType, nil, err
+	}
+	buf := GetBuffer()
+	defer PutBuffer(buf)
+	if _, err := buf.ReadFrom(r); err != nil {
+		return messageType, nil, err
+	}
+	return messageType, bytes.Clone(buf.Bytes()), nil
+}
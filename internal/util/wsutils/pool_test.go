@@ -0,0 +1,124 @@
+package wsutils
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/websocket"
+)
+
+type benchPayload struct {
+	Type      string `json:"type"`
+	Action    string `json:"action"`
+	RequestID string `json:"request_id"`
+	Data      string `json:"data"`
+}
+
+func TestEncodeJSONMatchesMarshal(t *testing.T) {
+	v := Message{Type: TextMessage, Data: map[string]any{"html": "<b>&</b>", "n": 1}}
+
+	want, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	buf, err := EncodeJSON(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer PutBuffer(buf)
+
+	// 输出必须与 json.Marshal 完全一致，包括 HTML 转义且不带末尾换行
+	if !bytes.Equal(buf.Bytes(), want) {
+		t.Errorf("EncodeJSON = %s, want %s", buf.Bytes(), want)
+	}
+}
+
+func TestEncodeJSONError(t *testing.T) {
+	if _, err := EncodeJSON(make(chan int)); err == nil {
+		t.Error("Expected an error when encoding a channel, but got none")
+	}
+}
+
+func TestPutBufferDropsLargeBuffers(t *testing.T) {
+	buf := GetBuffer()
+	buf.Grow(maxPooledBuffer + 1)
+	PutBuffer(buf)
+
+	// 大缓冲区不回池，GetBuffer 拿到的总是已清空的缓冲区
+	if got := GetBuffer(); got.Len() != 0 {
+		t.Errorf("GetBuffer() returned %d bytes of stale data", got.Len())
+	}
+}
+
+func TestReadWriteJSON(t *testing.T) {
+	upgrader := websocket.Upgrader{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		// 原样回显
+		for {
+			mt, msg, err := ReadMessage(conn)
+			if err != nil {
+				return
+			}
+			if err := conn.WriteMessage(mt, msg); err != nil {
+				return
+			}
+		}
+	}))
+	defer srv.Close()
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	for _, data := range []string{"short", strings.Repeat("x", 100<<10)} {
+		sent := benchPayload{Type: "chat", RequestID: "1", Data: data}
+		if err := WriteJSON(conn, TextMessage, sent); err != nil {
+			t.Fatal(err)
+		}
+		mt, msg, err := ReadMessage(conn)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got benchPayload
+		if err := json.Unmarshal(msg, &got); err != nil {
+			t.Fatal(err)
+		}
+		if mt != TextMessage || got != sent {
+			t.Errorf("echo mismatch for %d-byte payload", len(data))
+		}
+	}
+}
+
+func BenchmarkMarshal(b *testing.B) {
+	v := benchPayload{Type: "client_to_server", Action: "chat", RequestID: "req-1", Data: strings.Repeat("a", 1024)}
+
+	b.Run("json.Marshal", func(b *testing.B) {
+		b.ReportAllocs()
+		for b.Loop() {
+			if _, err := json.Marshal(v); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("EncodeJSON", func(b *testing.B) {
+		b.ReportAllocs()
+		for b.Loop() {
+			buf, err := EncodeJSON(v)
+			if err != nil {
+				b.Fatal(err)
+			}
+			PutBuffer(buf)
+		}
+	})
+}
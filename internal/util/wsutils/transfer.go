@@ -0,0 +1,346 @@
+package wsutils
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 分块传输：发送方先发 begin 帧声明文件名、大小与 SHA-256，再按偏移顺序发送带 CRC-32 的 chunk 帧，最后发送 end 帧。
+// 接收方把数据写入临时的 .part 文件，end 时校验大小与 SHA-256 后改名；中断后以相同的文件重新 begin，
+// 接收方回复已写入的偏移，发送方从该处续传
+const (
+	TransferBegin = "begin"
+	TransferChunk = "chunk"
+	TransferEnd   = "end"
+)
+
+// DefaultChunkSize 未指定时每个 chunk 帧携带的字节数
+const DefaultChunkSize = 256 << 10
+
+// transferIdle 该时长内重新 begin 即可从 .part 文件续传，超过后上传被遗忘，Sweep 删除其 .part 文件
+const transferIdle = time.Hour
+
+// 传输失败的原因，可用 errors.Is 判断
+var (
+	ErrTransferPath     = errors.New("文件路径无效")
+	ErrTransferTooLarge = errors.New("文件超过大小上限")
+	ErrTransferUnknown  = errors.New("传输不存在或已结束")
+	ErrTransferOffset   = errors.New("分块偏移与已写入的位置不一致")
+	ErrTransferChecksum = errors.New("校验和不一致")
+)
+
+// TransferFrame 分块传输的一帧，Phase 决定使用的字段
+type TransferFrame struct {
+	ID     string `json:"id"`               // 传输 id，同一文件的各帧共用
+	Phase  string `json:"phase"`            // begin、chunk 或 end
+	Name   string `json:"name,omitempty"`   // begin：相对传输目录的文件路径
+	Size   int64  `json:"size,omitempty"`   // begin：文件总字节数
+	SHA256 string `json:"sha256,omitempty"` // begin、end：整个文件的 SHA-256 (hex)
+	Offset int64  `json:"offset"`           // begin 的回复：续传的起点；chunk：Data 在文件中的偏移
+	Data   []byte `json:"data,omitempty"`   // chunk：文件内容，JSON 中以 base64 编码
+	CRC32  uint32 `json:"crc32,omitempty"`  // chunk：Data 的 CRC-32 (IEEE)
+}
+
+// NewChunk 构造 chunk 帧并计算 CRC-32
+func NewChunk(id string, offset int64, data []byte) TransferFrame {
+	return TransferFrame{ID: id, Phase: TransferChunk, Offset: offset, Data: data, CRC32: crc32.ChecksumIEEE(data)}
+}
+
+// Verify 校验 chunk 帧的 CRC-32
+func (f *TransferFrame) Verify() error {
+	if crc32.ChecksumIEEE(f.Data) != f.CRC32 {
+		return fmt.Errorf("%w: 偏移 %d 的分块", ErrTransferChecksum, f.Offset)
+	}
+	return nil
+}
+
+// Checksum 返回 r 全部内容的 SHA-256 (hex)
+func Checksum(r io.Reader) (string, error) {
+	h := sha256.New()
+	if _, err := io.Copy(h, r); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// TransferDir 在一个目录中接收与发送分块传输的文件，文件路径不能越出该目录；
+// 文件经 os.Root 打开，指向目录之外的符号链接同样无法读写
+type TransferDir struct {
+	root      *os.Root
+	maxSize   int64
+	chunkSize int
+
+	mu      sync.Mutex
+	uploads map[string]*upload
+}
+
+// upload 进行中的上传，已写入的偏移以 .part 文件的大小为准
+type upload struct {
+	name    string
+	part    string // 相对传输目录的临时文件路径
+	size    int64
+	sha256  string
+	touched time.Time
+}
+
+// NewTransferDir 创建 dir 并在其中收发文件；maxSize 为单个上传文件的上限，不大于 0 时不限；
+// chunkSize 为发送时每个分块的字节数，不大于 0 时为 DefaultChunkSize
+func NewTransferDir(dir string, maxSize int64, chunkSize int) (*TransferDir, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("创建传输目录失败: %w", err)
+	}
+	root, err := os.OpenRoot(dir)
+	if err != nil {
+		return nil, fmt.Errorf("打开传输目录失败: %w", err)
+	}
+	if chunkSize <= 0 {
+		chunkSize = DefaultChunkSize
+	}
+	return &TransferDir{root: root, maxSize: maxSize, chunkSize: chunkSize, uploads: map[string]*upload{}}, nil
+}
+
+// path 返回 name 相对传输目录的路径，拒绝绝对路径与含 .. 的路径；经符号链接越出目录的路径由 os.Root 在打开时拒绝
+func (d *TransferDir) path(name string) (string, error) {
+	if name == "" || !filepath.IsLocal(filepath.FromSlash(name)) {
+		return "", fmt.Errorf("%w: %q", ErrTransferPath, name)
+	}
+	return filepath.Clean(filepath.FromSlash(name)), nil
+}
+
+// Begin 开始或续传一次上传，返回的帧的 Offset 为已写入的字节数，发送方从该处继续发送 chunk；
+// 同一文件 (相同 name 与 sha256) 中断后重新 begin 即续传，id 可以不同
+func (d *TransferDir) Begin(f TransferFrame) (TransferFrame, error) {
+	if f.ID == "" {
+		return TransferFrame{}, fmt.Errorf("%w: 缺少 id", ErrTransferUnknown)
+	}
+	path, err := d.path(f.Name)
+	if err != nil {
+		return TransferFrame{}, err
+	}
+	if f.Size < 0 || d.maxSize > 0 && f.Size > d.maxSize {
+		return TransferFrame{}, fmt.Errorf("%w: %d 字节，上限 %d", ErrTransferTooLarge, f.Size, d.maxSize)
+	}
+	if sum, err := hex.DecodeString(f.SHA256); err != nil || len(sum) != sha256.Size {
+		return TransferFrame{}, fmt.Errorf("%w: sha256 应为 64 位十六进制", ErrTransferChecksum)
+	}
+	if err := d.root.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return TransferFrame{}, fmt.Errorf("创建目录失败: %w", err)
+	}
+
+	// 临时文件名包含 sha256，内容不同的同名文件不会续传到一起
+	part := fmt.Sprintf("%s.%s.part", path, f.SHA256[:16])
+	file, err := d.root.OpenFile(part, os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return TransferFrame{}, fmt.Errorf("创建临时文件失败: %w", err)
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		return TransferFrame{}, err
+	}
+	offset := info.Size()
+	if offset > f.Size {
+		if err := file.Truncate(0); err != nil {
+			return TransferFrame{}, err
+		}
+		offset = 0
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.uploads[f.ID] = &upload{name: f.Name, part: part, size: f.Size, sha256: f.SHA256, touched: time.Now()}
+	return TransferFrame{ID: f.ID, Phase: TransferBegin, Name: f.Name, Size: f.Size, SHA256: f.SHA256, Offset: offset}, nil
+}
+
+// Sweep 遗忘超过 transferIdle 没有新帧的上传并删除其 .part 文件，同时删除目录中同样久未修改、
+// 不属于任何进行中上传的 .part 文件 (如进程重启前留下的)，返回删除的文件数；供 janitor 定期调用
+func (d *TransferDir) Sweep(now time.Time) int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	active := make(map[string]bool, len(d.uploads))
+	for id, u := range d.uploads {
+		if now.Sub(u.touched) > transferIdle {
+			delete(d.uploads, id)
+			continue
+		}
+		active[u.part] = true
+	}
+	removed := 0
+	_ = fs.WalkDir(d.root.FS(), ".", func(path string, e fs.DirEntry, err error) error {
+		path = filepath.FromSlash(path)
+		if err != nil || e.IsDir() || !isPart(path) || active[path] {
+			return nil
+		}
+		info, err := e.Info()
+		if err != nil || now.Sub(info.ModTime()) <= transferIdle {
+			return nil
+		}
+		if d.root.Remove(path) == nil {
+			removed++
+		}
+		return nil
+	})
+	return removed
+}
+
+// isPart 判断 path 是否为 Begin 创建的临时文件 (<目标文件>.<sha256 前 16 位>.part)，避免误删名字以 .part 结尾的已完成文件
+func isPart(path string) bool {
+	base, ok := strings.CutSuffix(path, ".part")
+	if !ok {
+		return false
+	}
+	ext := filepath.Ext(base)
+	if len(ext) != 17 {
+		return false
+	}
+	_, err := hex.DecodeString(ext[1:])
+	return err == nil
+}
+
+// Chunk 校验 CRC-32 与偏移后写入分块，返回下一个分块的偏移；分块须按顺序发送，
+// 偏移不一致时返回 ErrTransferOffset，发送方重新 begin 取得续传的位置
+func (d *TransferDir) Chunk(f TransferFrame) (int64, error) {
+	if err := f.Verify(); err != nil {
+		return 0, err
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	u, ok := d.uploads[f.ID]
+	if !ok {
+		return 0, fmt.Errorf("%w: %s", ErrTransferUnknown, f.ID)
+	}
+	u.touched = time.Now()
+	file, err := d.root.OpenFile(u.part, os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return 0, fmt.Errorf("打开临时文件失败: %w", err)
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		return 0, err
+	}
+	if f.Offset != info.Size() {
+		return info.Size(), fmt.Errorf("%w: 收到 %d，应为 %d", ErrTransferOffset, f.Offset, info.Size())
+	}
+	if f.Offset+int64(len(f.Data)) > u.size {
+		return info.Size(), fmt.Errorf("%w: 超出 begin 声明的 %d 字节", ErrTransferTooLarge, u.size)
+	}
+	if _, err := file.Write(f.Data); err != nil {
+		return 0, fmt.Errorf("写入文件失败: %w", err)
+	}
+	return f.Offset + int64(len(f.Data)), nil
+}
+
+// End 校验大小与 SHA-256 后将临时文件改名为目标文件，已存在的同名文件被覆盖；
+// 校验失败时删除临时文件，需要重新上传
+func (d *TransferDir) End(f TransferFrame) (TransferFrame, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	u, ok := d.uploads[f.ID]
+	if !ok {
+		return TransferFrame{}, fmt.Errorf("%w: %s", ErrTransferUnknown, f.ID)
+	}
+	file, err := d.root.Open(u.part)
+	if err != nil {
+		return TransferFrame{}, fmt.Errorf("打开临时文件失败: %w", err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return TransferFrame{}, err
+	}
+	if info.Size() != u.size {
+		file.Close()
+		return TransferFrame{}, fmt.Errorf("%w: 已写入 %d 字节，应为 %d", ErrTransferOffset, info.Size(), u.size)
+	}
+	sum, err := Checksum(file)
+	file.Close()
+	if err != nil {
+		return TransferFrame{}, fmt.Errorf("读取临时文件失败: %w", err)
+	}
+	delete(d.uploads, f.ID)
+	if sum != u.sha256 {
+		_ = d.root.Remove(u.part)
+		return TransferFrame{}, fmt.Errorf("%w: 文件 %s", ErrTransferChecksum, u.name)
+	}
+	path, _ := d.path(u.name)
+	if err := d.root.Rename(u.part, path); err != nil {
+		return TransferFrame{}, fmt.Errorf("保存文件失败: %w", err)
+	}
+	return TransferFrame{ID: f.ID, Phase: TransferEnd, Name: u.name, Size: u.size, SHA256: sum, Offset: u.size}, nil
+}
+
+// Stat 返回下载 name 时的 begin 帧，包含大小与 SHA-256，不存在时返回 os.ErrNotExist
+func (d *TransferDir) Stat(id, name string) (TransferFrame, error) {
+	path, err := d.path(name)
+	if err != nil {
+		return TransferFrame{}, err
+	}
+	file, err := d.root.Open(path)
+	if err != nil {
+		return TransferFrame{}, err
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		return TransferFrame{}, err
+	}
+	if !info.Mode().IsRegular() {
+		return TransferFrame{}, fmt.Errorf("%w: %s 不是普通文件", ErrTransferPath, name)
+	}
+	sum, err := Checksum(file)
+	if err != nil {
+		return TransferFrame{}, err
+	}
+	return TransferFrame{ID: id, Phase: TransferBegin, Name: name, Size: info.Size(), SHA256: sum}, nil
+}
+
+// Send 发送 name 从 offset 开始的内容：依次以 begin 帧与各 chunk 帧调用 emit，返回 end 帧，由调用方作为最后一帧发送；
+// 接收方中断后以已收到的字节数作为 offset 重新请求即续传
+func (d *TransferDir) Send(ctx context.Context, id, name string, offset int64, emit func(TransferFrame) error) (TransferFrame, error) {
+	begin, err := d.Stat(id, name)
+	if err != nil {
+		return TransferFrame{}, err
+	}
+	if offset < 0 || offset > begin.Size {
+		return TransferFrame{}, fmt.Errorf("%w: 偏移 %d 超出文件大小 %d", ErrTransferOffset, offset, begin.Size)
+	}
+	begin.Offset = offset
+	if err := emit(begin); err != nil {
+		return TransferFrame{}, err
+	}
+
+	path, _ := d.path(name)
+	file, err := d.root.Open(path)
+	if err != nil {
+		return TransferFrame{}, err
+	}
+	defer file.Close()
+	buf := make([]byte, d.chunkSize)
+	for offset < begin.Size {
+		if err := ctx.Err(); err != nil {
+			return TransferFrame{}, err
+		}
+		n, err := file.ReadAt(buf[:min(int64(len(buf)), begin.Size-offset)], offset)
+		if n == 0 && err != nil {
+			return TransferFrame{}, fmt.Errorf("读取文件失败: %w", err)
+		}
+		// emit 可能在发送前保留数据，每个分块使用独立的切片
+		if err := emit(NewChunk(id, offset, append([]byte(nil), buf[:n]...))); err != nil {
+			return TransferFrame{}, err
+		}
+		offset += int64(n)
+	}
+	return TransferFrame{ID: id, Phase: TransferEnd, Name: name, Size: begin.Size, SHA256: begin.SHA256, Offset: begin.Size}, nil
+}
@@ -0,0 +1,236 @@
+package wsutils
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+func sha(t *testing.T, data []byte) string {
+	t.Helper()
+	sum, err := Checksum(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return sum
+}
+
+func TestUploadResumesAfterRestart(t *testing.T) {
+	dir := t.TempDir()
+	content := make([]byte, 1000)
+	_, _ = rand.Read(content)
+	begin := TransferFrame{ID: "t1", Phase: TransferBegin, Name: "models/llama.Modelfile", Size: int64(len(content)), SHA256: sha(t, content)}
+
+	d, err := NewTransferDir(dir, 1<<20, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	reply, err := d.Begin(begin)
+	if err != nil || reply.Offset != 0 {
+		t.Fatalf("begin: %+v, %v", reply, err)
+	}
+	if next, err := d.Chunk(NewChunk("t1", 0, content[:400])); err != nil || next != 400 {
+		t.Fatalf("chunk: %d, %v", next, err)
+	}
+
+	// 重启后以新的 id 重新 begin，从已写入的位置续传
+	d, _ = NewTransferDir(dir, 1<<20, 0)
+	begin.ID = "t2"
+	if _, err := d.Chunk(NewChunk("t1", 400, content[400:])); !errors.Is(err, ErrTransferUnknown) {
+		t.Errorf("expected ErrTransferUnknown before begin, got %v", err)
+	}
+	if reply, err = d.Begin(begin); err != nil || reply.Offset != 400 {
+		t.Fatalf("resumed begin: %+v, %v", reply, err)
+	}
+	if _, err := d.Chunk(NewChunk("t2", 0, content[:400])); !errors.Is(err, ErrTransferOffset) {
+		t.Errorf("expected ErrTransferOffset for a chunk already written, got %v", err)
+	}
+	bad := NewChunk("t2", 400, content[400:])
+	bad.CRC32++
+	if _, err := d.Chunk(bad); !errors.Is(err, ErrTransferChecksum) {
+		t.Errorf("expected ErrTransferChecksum, got %v", err)
+	}
+	if next, err := d.Chunk(NewChunk("t2", 400, content[400:])); err != nil || next != 1000 {
+		t.Fatalf("chunk: %d, %v", next, err)
+	}
+	end, err := d.End(TransferFrame{ID: "t2", Phase: TransferEnd})
+	if err != nil || end.SHA256 != begin.SHA256 {
+		t.Fatalf("end: %+v, %v", end, err)
+	}
+	got, err := os.ReadFile(filepath.Join(dir, "models", "llama.Modelfile"))
+	if err != nil || !bytes.Equal(got, content) {
+		t.Fatalf("saved file differs: %v", err)
+	}
+	if parts, _ := filepath.Glob(filepath.Join(dir, "models", "*.part")); len(parts) != 0 {
+		t.Errorf("temporary files left behind: %v", parts)
+	}
+}
+
+func TestUploadRejectsBadFiles(t *testing.T) {
+	dir := t.TempDir()
+	d, _ := NewTransferDir(dir, 10, 0)
+	sum := sha(t, []byte("hello"))
+	for name, f := range map[string]TransferFrame{
+		"escape":    {ID: "a", Name: "../evil", Size: 5, SHA256: sum},
+		"absolute":  {ID: "a", Name: "/etc/passwd", Size: 5, SHA256: sum},
+		"too large": {ID: "a", Name: "big", Size: 11, SHA256: sum},
+		"bad sha":   {ID: "a", Name: "x", Size: 5, SHA256: "abc"},
+	} {
+		if _, err := d.Begin(f); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+
+	// 内容与 begin 声明的 SHA-256 不一致时不保存，临时文件被删除
+	if _, err := d.Begin(TransferFrame{ID: "b", Name: "x", Size: 5, SHA256: sum}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := d.Chunk(NewChunk("b", 0, []byte("HELLO"))); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := d.End(TransferFrame{ID: "b"}); !errors.Is(err, ErrTransferChecksum) {
+		t.Fatalf("expected ErrTransferChecksum, got %v", err)
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+		t.Errorf("nothing must be left after a failed upload, got %d entries", len(entries))
+	}
+}
+
+func TestSendFromOffset(t *testing.T) {
+	dir := t.TempDir()
+	content := bytes.Repeat([]byte("0123456789"), 10)
+	if err := os.WriteFile(filepath.Join(dir, "bridge.log"), content, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	d, _ := NewTransferDir(dir, 0, 30)
+
+	var frames []TransferFrame
+	end, err := d.Send(context.Background(), "dl", "bridge.log", 25, func(f TransferFrame) error {
+		frames = append(frames, f)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(frames) != 4 || frames[0].Phase != TransferBegin || frames[0].Offset != 25 || frames[0].Size != 100 {
+		t.Fatalf("unexpected frames: %+v", frames)
+	}
+	received := append([]byte(nil), content[:25]...)
+	for _, f := range frames[1:] {
+		if err := f.Verify(); err != nil || f.Offset != int64(len(received)) {
+			t.Fatalf("bad chunk at %d: %v", f.Offset, err)
+		}
+		received = append(received, f.Data...)
+	}
+	if !bytes.Equal(received, content) || end.Phase != TransferEnd || end.SHA256 != sha(t, content) {
+		t.Errorf("download does not match the file: %+v", end)
+	}
+
+	if _, err := d.Send(context.Background(), "dl", "missing.log", 0, func(TransferFrame) error { return nil }); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("expected ErrNotExist, got %v", err)
+	}
+	if _, err := d.Send(context.Background(), "dl", "bridge.log", 101, func(TransferFrame) error { return nil }); !errors.Is(err, ErrTransferOffset) {
+		t.Errorf("expected ErrTransferOffset, got %v", err)
+	}
+}
+
+func TestSymlinksCannotEscape(t *testing.T) {
+	dir, outside := t.TempDir(), t.TempDir()
+	secret := filepath.Join(outside, "secret")
+	if err := os.WriteFile(secret, []byte("secret"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	// 传输目录中指向目录之外的文件与目录的符号链接
+	if err := os.Symlink(secret, filepath.Join(dir, "leak")); err != nil {
+		t.Skip("symlinks unsupported:", err)
+	}
+	if err := os.Symlink(outside, filepath.Join(dir, "out")); err != nil {
+		t.Fatal(err)
+	}
+	d, _ := NewTransferDir(dir, 1<<20, 0)
+
+	for _, name := range []string{"leak", "out/secret"} {
+		if _, err := d.Stat("s", name); err == nil {
+			t.Errorf("%s: expected the download to be refused", name)
+		}
+	}
+	content := []byte("evil")
+	if _, err := d.Begin(TransferFrame{ID: "u", Name: "out/planted", Size: 4, SHA256: sha(t, content)}); err == nil {
+		t.Error("expected the upload through a symlinked directory to be refused")
+	}
+	if _, err := os.Stat(filepath.Join(outside, "planted")); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("nothing must be written outside the directory, got %v", err)
+	}
+
+	// 上传到符号链接的名称时替换链接本身，不写入链接指向的文件
+	if _, err := d.Begin(TransferFrame{ID: "u", Name: "leak", Size: 4, SHA256: sha(t, content)}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := d.Chunk(NewChunk("u", 0, content)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := d.End(TransferFrame{ID: "u"}); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := os.ReadFile(secret); string(got) != "secret" {
+		t.Errorf("the file outside the directory was modified: %q", got)
+	}
+}
+
+func TestSweepRemovesAbandonedParts(t *testing.T) {
+	dir := t.TempDir()
+	d, _ := NewTransferDir(dir, 1<<20, 0)
+	content := []byte("hello")
+	if _, err := d.Begin(TransferFrame{ID: "stale", Name: "a.txt", Size: 5, SHA256: sha(t, content)}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := d.Chunk(NewChunk("stale", 0, content[:2])); err != nil {
+		t.Fatal(err)
+	}
+	other := []byte("world")
+	if _, err := d.Begin(TransferFrame{ID: "live", Name: "b.txt", Size: 5, SHA256: sha(t, other)}); err != nil {
+		t.Fatal(err)
+	}
+	// 重启前留下的临时文件与名字以 .part 结尾的普通文件
+	orphan := filepath.Join(dir, "c.txt.0123456789abcdef.part")
+	kept := filepath.Join(dir, "notes.part")
+	for _, p := range []string{orphan, kept} {
+		if err := os.WriteFile(p, []byte("x"), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	later := time.Now().Add(transferIdle + time.Minute)
+	for _, p := range []string{orphan, kept} {
+		if err := os.Chtimes(p, later.Add(-2*transferIdle), later.Add(-2*transferIdle)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// 未超时时不删除任何文件
+	if n := d.Sweep(time.Now()); n != 0 {
+		t.Fatalf("fresh sweep removed %d files", n)
+	}
+	// "live" 在清理前收到新分块，不应被删除
+	d.mu.Lock()
+	d.uploads["live"].touched = later
+	d.mu.Unlock()
+	if n := d.Sweep(later); n != 2 {
+		t.Errorf("removed %d files, want the stale upload and the orphan", n)
+	}
+	parts, _ := filepath.Glob(filepath.Join(dir, "*.part"))
+	if len(parts) != 2 || !slices.Contains(parts, kept) {
+		t.Errorf("remaining files = %v, want notes.part and the live upload", parts)
+	}
+	if _, err := d.Chunk(NewChunk("stale", 2, content[2:])); !errors.Is(err, ErrTransferUnknown) {
+		t.Errorf("expected ErrTransferUnknown after sweep, got %v", err)
+	}
+	if _, err := d.Chunk(NewChunk("live", 0, other)); err != nil {
+		t.Errorf("live upload: %v", err)
+	}
+}
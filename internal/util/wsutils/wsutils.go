@@ -1,15 +1,22 @@
 package wsutils
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
-	"log"
+	"log/slog"
 	"net/http"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/gorilla/websocket"
+	"go.opentelemetry.io/otel/trace"
+
+	"ollama_dev/internal/config"
+	"ollama_dev/internal/logging"
+	"ollama_dev/internal/tracing"
 )
 
 // WebSocket 消息类型
@@ -23,34 +30,158 @@ const (
 
 // Message 结构体，用于封装消息
 type Message struct {
-	Type int         `json:"type"` // 消息类型
-	Data interface{} `json:"data"` // 消息数据
+	Type int         `json:"type"`         // 消息类型
+	ID   string      `json:"id,omitempty"` // 需要确认的消息的 ID，见 SendAcked
+	Data interface{} `json:"data"`         // 消息数据
 }
 
+// 发送队列溢出策略
+const (
+	OverflowDropOldest = "drop_oldest" // 丢弃队列中最旧的消息，为新消息腾出位置
+	OverflowDisconnect = "disconnect"  // 直接断开跟不上的客户端
+)
+
+const (
+	defaultQueueSize    = 256
+	defaultWriteTimeout = 10 * time.Second
+	pingInterval        = 5 * time.Second
+)
+
 // Config 配置项
 type Config struct {
-	CheckOrigin func(r *http.Request) bool // 请求头校验函数
-	Header      http.Header                // 自定义请求头
+	CheckOrigin  func(r *http.Request) bool // 请求头校验函数
+	Header       http.Header                // 自定义请求头
+	Compression  config.CompressionConfig   // permessage-deflate 压缩，对端同意时生效
+	QueueSize    int                        // 每个客户端的发送队列长度，默认 256
+	WriteTimeout time.Duration              // 单次写入的截止时间，默认 10s
+	Overflow     string                     // 队列满时的策略，默认 OverflowDropOldest
+}
+
+// ClientStats 单个客户端的发送队列状态
+type ClientStats struct {
+	RemoteAddr string `json:"remote_addr"`
+	QueueLen   int    `json:"queue_len"`
+	QueueCap   int    `json:"queue_cap"`
+	Dropped    int64  `json:"dropped"` // 因队列溢出被丢弃的消息数
+}
+
+// outbound 排队等待写出的一帧
+type outbound struct {
+	messageType int
+	data        []byte
+}
+
+// client 一个受管连接及其发送队列，所有数据帧只由它自己的 writePump 写出
+type client struct {
+	conn         *websocket.Conn
+	send         chan outbound
+	overflow     string
+	writeTimeout time.Duration
+	dropped      atomic.Int64
+
+	mu        sync.Mutex // 串行化入队，保证丢弃最旧与写入新消息之间不被打断
+	done      chan struct{}
+	closeOnce sync.Once
+
+	ackMu   sync.Mutex
+	pending map[string]*pendingAck // 等待确认的消息，关闭后为 nil
+}
+
+func newClient(conn *websocket.Conn, cfg Config) *client {
+	size := cfg.QueueSize
+	if size <= 0 {
+		size = defaultQueueSize
+	}
+	timeout := cfg.WriteTimeout
+	if timeout <= 0 {
+		timeout = defaultWriteTimeout
+	}
+	overflow := cfg.Overflow
+	if overflow == "" {
+		overflow = OverflowDropOldest
+	}
+	return &client{
+		conn:         conn,
+		send:         make(chan outbound, size),
+		overflow:     overflow,
+		writeTimeout: timeout,
+		done:         make(chan struct{}),
+		pending:      make(map[string]*pendingAck),
+	}
+}
+
+// enqueue 非阻塞入队；队列满时按策略丢弃最旧消息，返回 false 表示应断开该客户端
+func (c *client) enqueue(msg outbound) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	select {
+	case <-c.done:
+		return false
+	default:
+	}
+	for {
+		select {
+		case c.send <- msg:
+			return true
+		default:
+		}
+		if c.overflow == OverflowDisconnect {
+			return false
+		}
+		select {
+		case <-c.send:
+			c.dropped.Add(1)
+		default:
+		}
+	}
+}
+
+// close 关闭连接并通知 writePump 退出，未确认的消息报告 ErrClientGone，可重复调用
+func (c *client) close() {
+	c.closeOnce.Do(func() {
+		close(c.done)
+		if c.conn != nil {
+			c.conn.Close()
+		}
+		c.failPending()
+	})
+}
+
+func (c *client) stats() ClientStats {
+	s := ClientStats{QueueLen: len(c.send), QueueCap: cap(c.send), Dropped: c.dropped.Load()}
+	if c.conn != nil {
+		s.RemoteAddr = c.conn.RemoteAddr().String()
+	}
+	return s
 }
 
 // WebSocketManager 管理 WebSocket 连接
 type WebSocketManager struct {
-	clients   map[*websocket.Conn]bool
+	clients   map[*websocket.Conn]*client
 	broadcast chan Message
 	mu        sync.Mutex
+	handlers  *HandlerRegistry
 	ctx       context.Context
 	cancel    context.CancelFunc
+	logger    *slog.Logger
 }
 
 // NewWebSocketManager 创建一个新的 WebSocketManager
-func NewWebSocketManager() *WebSocketManager {
+func NewWebSocketManager(logger *slog.Logger) *WebSocketManager {
 	ctx, cancel := context.WithCancel(context.Background())
-	return &WebSocketManager{
-		clients:   make(map[*websocket.Conn]bool),
+	handlers := NewHandlerRegistry()
+	handlers.Use(Recover(logger))
+	m := &WebSocketManager{
+		clients:   make(map[*websocket.Conn]*client),
 		broadcast: make(chan Message),
+		handlers:  handlers,
 		ctx:       ctx,
 		cancel:    cancel,
+		logger:    logger,
 	}
+	handlers.On(AckType, m.handleAck)
+	return m
 }
 
 // Upgrade 升级 HTTP 连接为 WebSocket 连接
@@ -64,6 +195,7 @@ func (m *WebSocketManager) Upgrade(w http.ResponseWriter, r *http.Request, confi
 			// 默认允许所有来源
 			return true
 		},
+		EnableCompression: config.Compression.Enabled,
 	}
 
 	// 添加自定义请求头
@@ -79,14 +211,16 @@ func (m *WebSocketManager) Upgrade(w http.ResponseWriter, r *http.Request, confi
 	if err != nil {
 		return nil, fmt.Errorf("升级 WebSocket 失败: %w", err)
 	}
+	SetCompression(conn, config.Compression)
 
 	// 注册客户端连接
+	c := newClient(conn, config)
 	m.mu.Lock()
-	m.clients[conn] = true
+	m.clients[conn] = c
 	m.mu.Unlock()
 
-	// 启动心跳机制
-	go m.startPingPong(conn)
+	// 写协程负责数据帧与心跳，慢客户端只会阻塞自己
+	go m.writePump(c)
 
 	// 启动消息接收处理
 	go m.receiveMessages(conn)
@@ -94,49 +228,137 @@ func (m *WebSocketManager) Upgrade(w http.ResponseWriter, r *http.Request, confi
 	return conn, nil
 }
 
-// SendMessage 发送消息到指定的 WebSocket 连接
+// SendMessage 发送消息到指定的 WebSocket 连接；受管连接只入队，不等待写出
 func (m *WebSocketManager) SendMessage(conn *websocket.Conn, messageType int, data interface{}) error {
-	payload, err := json.Marshal(Message{Type: messageType, Data: data})
+	buf, err := EncodeJSON(Message{Type: messageType, Data: data})
 	if err != nil {
 		return fmt.Errorf("序列化消息失败: %w", err)
 	}
-	return conn.WriteMessage(messageType, payload)
+	defer PutBuffer(buf)
+
+	c := m.lookup(conn)
+	if c == nil {
+		conn.SetWriteDeadline(time.Now().Add(defaultWriteTimeout))
+		return conn.WriteMessage(messageType, buf.Bytes())
+	}
+	// 缓冲区会被复用，入队前复制一份
+	msg := outbound{messageType: messageType, data: bytes.Clone(buf.Bytes())}
+	if !c.enqueue(msg) {
+		m.remove(c)
+		return fmt.Errorf("发送队列已满，连接已断开: %s", conn.RemoteAddr())
+	}
+	return nil
 }
 
 // Broadcast 广播消息到所有连接的客户端
 func (m *WebSocketManager) Broadcast(messageType int, data interface{}) {
 	msg := Message{Type: messageType, Data: data}
-	m.broadcast <- msg
+	select {
+	case m.broadcast <- msg:
+	case <-m.ctx.Done():
+	}
+}
+
+// On 注册具名消息的处理器，见 HandlerRegistry.On
+func (m *WebSocketManager) On(name string, h HandlerFunc) {
+	m.handlers.On(name, h)
+}
+
+// Use 追加消息处理中间件，默认已包含 Recover
+func (m *WebSocketManager) Use(mw ...Middleware) {
+	m.handlers.Use(mw...)
+}
+
+// Fallback 设置未注册消息类型的处理器
+func (m *WebSocketManager) Fallback(h HandlerFunc) {
+	m.handlers.Fallback(h)
+}
+
+// Stats 返回各客户端发送队列的长度、容量与丢弃计数
+func (m *WebSocketManager) Stats() []ClientStats {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	stats := make([]ClientStats, 0, len(m.clients))
+	for _, c := range m.clients {
+		stats = append(stats, c.stats())
+	}
+	return stats
 }
 
 // Close 关闭 WebSocketManager
 func (m *WebSocketManager) Close() {
 	m.cancel()
+	m.mu.Lock()
+	clients := make([]*client, 0, len(m.clients))
+	for conn, c := range m.clients {
+		clients = append(clients, c)
+		delete(m.clients, conn)
+	}
+	m.mu.Unlock()
+
+	// 关闭时会调用未确认消息的 Report 回调，不持有锁
+	for _, c := range clients {
+		c.close()
+	}
+}
+
+func (m *WebSocketManager) lookup(conn *websocket.Conn) *client {
 	m.mu.Lock()
 	defer m.mu.Unlock()
+	return m.clients[conn]
+}
 
-	for conn := range m.clients {
-		conn.Close()
-		delete(m.clients, conn)
+// remove 注销客户端并关闭连接
+func (m *WebSocketManager) remove(c *client) {
+	m.mu.Lock()
+	if m.clients[c.conn] == c {
+		delete(m.clients, c.conn)
 	}
+	m.mu.Unlock()
+	c.close()
 }
 
-// startPingPong 启动心跳机制
+// writePump 依次写出队列中的消息并定期发送 Ping，每次写入都带截止时间
+func (m *WebSocketManager) writePump(c *client) {
+	ticker := time.NewTicker(pingInterval)
+	defer func() {
+		ticker.Stop()
+		m.remove(c)
+	}()
+
+	for {
+		select {
+		case msg := <-c.send:
+			c.conn.SetWriteDeadline(time.Now().Add(c.writeTimeout))
+			if err := c.conn.WriteMessage(msg.messageType, msg.data); err != nil {
+				m.logger.Error("写入消息失败", "error", err, "remote", c.conn.RemoteAddr())
+				return
+			}
+		case <-ticker.C:
+			if err := c.conn.WriteControl(PingMessage, nil, time.Now().Add(c.writeTimeout)); err != nil {
+				m.logger.Error("发送 Ping 失败", "error", err)
+				return
+			}
+		case <-c.done:
+			return
+		case <-m.ctx.Done():
+			return
+		}
+	}
+}
+
+// startPingPong 为未受管的连接启动心跳机制，受管连接的心跳由 writePump 发送
 func (m *WebSocketManager) startPingPong(conn *websocket.Conn) {
-	ticker := time.NewTicker(5 * time.Second)
+	ticker := time.NewTicker(pingInterval)
 	defer ticker.Stop()
 
-	conn.SetPongHandler(func(string) error {
-		log.Println("收到 Pong")
-		return nil
-	})
-
 	for {
 		select {
 		case <-ticker.C:
-			err := conn.WriteMessage(PingMessage, []byte{})
+			err := conn.WriteControl(PingMessage, nil, time.Now().Add(defaultWriteTimeout))
 			if err != nil {
-				log.Println("发送 Ping 失败:", err)
+				m.logger.Error("发送 Ping 失败", "error", err)
 				return
 			}
 		case <-m.ctx.Done():
@@ -153,69 +375,112 @@ func (m *WebSocketManager) ReceiveMessages(conn *websocket.Conn) {
 	m.receiveMessages(conn)
 }
 
-// receiveMessages 接收消息并处理
+// envelope 收到的消息：type 为字符串时按具名消息分发，为数字时按 WebSocket 帧类型处理
+type envelope struct {
+	Type json.RawMessage `json:"type"`
+	Data json.RawMessage `json:"data"`
+}
+
+// receiveMessages 接收消息并处理，具名消息在读取循环中依次分发，处理器耗时较长时应自行启动 goroutine
 func (m *WebSocketManager) receiveMessages(conn *websocket.Conn) {
+	ctx, cancel := context.WithCancel(m.ctx)
+	defer cancel()
 	defer func() {
-		m.mu.Lock()
-		delete(m.clients, conn)
-		m.mu.Unlock()
+		if c := m.lookup(conn); c != nil {
+			m.remove(c)
+			return
+		}
 		conn.Close()
 	}()
 
+	conn.SetPongHandler(func(string) error {
+		m.logger.Debug("收到 Pong")
+		return nil
+	})
+
+	buf := GetBuffer()
+	defer PutBuffer(buf)
+
 	for {
-		_, message, err := conn.ReadMessage()
+		_, r, err := conn.NextReader()
+		if err == nil {
+			buf.Reset()
+			_, err = buf.ReadFrom(r)
+		}
 		if err != nil {
-			log.Println("读取消息失败:", err)
+			m.logger.Info("读取消息失败", "error", err)
 			return
 		}
 
-		// 处理消息
+		// 处理消息，解析结果不引用缓冲区，可在下一轮复用
+		var env envelope
+		err = json.Unmarshal(buf.Bytes(), &env)
+		if err != nil {
+			m.logger.Warn("解析消息失败", "error", err)
+			continue
+		}
+		var name string
+		if json.Unmarshal(env.Type, &name) == nil {
+			// 每条消息分配请求 ID 并创建 span，处理器经 logger.InfoContext 等输出的日志自动带上
+			msgCtx, span := tracing.Start(logging.WithRequestID(ctx, logging.NewRequestID()), "ws.message "+name, trace.SpanKindServer)
+			err := m.handlers.Dispatch(msgCtx, conn, name, env.Data)
+			if err != nil {
+				m.logger.WarnContext(msgCtx, "处理消息失败", "type", name, "error", err)
+			}
+			tracing.End(span, err)
+			continue
+		}
 		var msg Message
-		err = json.Unmarshal(message, &msg)
+		err = json.Unmarshal(buf.Bytes(), &msg)
 		if err != nil {
-			log.Println("解析消息失败:", err)
+			m.logger.Warn("解析消息失败", "error", err)
 			continue
 		}
 
 		// 根据消息类型进行处理
 		switch msg.Type {
 		case TextMessage:
-			log.Printf("收到文本消息: %s", msg.Data)
+			m.logger.Info("收到文本消息", "data", msg.Data)
 		case BinaryMessage:
-			log.Printf("收到二进制消息: %v", msg.Data)
+			m.logger.Info("收到二进制消息", "data", msg.Data)
 		case CloseMessage:
-			log.Println("收到关闭消息")
+			m.logger.Info("收到关闭消息")
 			return
 		case PingMessage:
-			log.Println("收到 Ping 消息")
-			err := conn.WriteMessage(PongMessage, []byte{})
+			m.logger.Debug("收到 Ping 消息")
+			err := conn.WriteControl(PongMessage, nil, time.Now().Add(defaultWriteTimeout))
 			if err != nil {
-				log.Println("发送 Pong 失败:", err)
+				m.logger.Error("发送 Pong 失败", "error", err)
 				return
 			}
 		case PongMessage:
-			log.Println("收到 Pong 消息")
+			m.logger.Debug("收到 Pong 消息")
 		default:
-			log.Printf("未知消息类型: %d", msg.Type)
+			m.logger.Warn("未知消息类型", "type", msg.Type)
 		}
 	}
 }
 
-// ListenBroadcasts 监听广播消息并发送给所有客户端
+// ListenBroadcasts 监听广播消息并投递到各客户端的发送队列，不会被慢客户端阻塞
 func (m *WebSocketManager) ListenBroadcasts() {
 	for {
 		select {
 		case msg := <-m.broadcast:
+			out := outbound{messageType: msg.Type, data: []byte(fmt.Sprintf("%v", msg.Data))}
+
 			m.mu.Lock()
-			for client := range m.clients {
-				err := client.WriteMessage(msg.Type, []byte(fmt.Sprintf("%v", msg.Data)))
-				if err != nil {
-					log.Println("广播消息失败:", err)
-					client.Close()
-					delete(m.clients, client)
-				}
+			clients := make([]*client, 0, len(m.clients))
+			for _, c := range m.clients {
+				clients = append(clients, c)
 			}
 			m.mu.Unlock()
+
+			for _, c := range clients {
+				if !c.enqueue(out) {
+					m.logger.Warn("客户端发送队列已满，断开连接", "remote", c.conn.RemoteAddr())
+					m.remove(c)
+				}
+			}
 		case <-m.ctx.Done():
 			return
 		}
@@ -0,0 +1,63 @@
+package version
+
+import (
+	"runtime"
+	"runtime/debug"
+)
+
+// 构建时通过 ldflags 注入，例如:
+//
+//	go build -ldflags "-X ollama_dev/internal/version.Version=v1.0.0 -X ollama_dev/internal/version.Commit=abc1234"
+var (
+	Version   = "dev"
+	Commit    = ""
+	BuildDate = ""
+)
+
+// Info 版本与构建信息
+type Info struct {
+	Version   string `json:"version"`
+	Commit    string `json:"commit"`
+	BuildDate string `json:"build_date"`
+	GoVersion string `json:"go_version"`
+	Platform  string `json:"platform"`
+}
+
+// Get 返回当前二进制的构建信息，未注入时回退到 Go 工具链记录的 VCS 信息
+func Get() Info {
+	info := Info{
+		Version:   Version,
+		Commit:    Commit,
+		BuildDate: BuildDate,
+		GoVersion: runtime.Version(),
+		Platform:  runtime.GOOS + "/" + runtime.GOARCH,
+	}
+
+	if bi, ok := debug.ReadBuildInfo(); ok {
+		for _, s := range bi.Settings {
+			switch s.Key {
+			case "vcs.revision":
+				if info.Commit == "" {
+					info.Commit = s.Value
+				}
+			case "vcs.time":
+				if info.BuildDate == "" {
+					info.BuildDate = s.Value
+				}
+			}
+		}
+	}
+
+	if info.Commit == "" {
+		info.Commit = "unknown"
+	}
+	if info.BuildDate == "" {
+		info.BuildDate = "unknown"
+	}
+	return info
+}
+
+// String 单行文本形式，用于命令行输出
+func (i Info) String() string {
+	return i.Version + " (commit " + i.Commit + ", built " + i.BuildDate + ", " + i.GoVersion + " " + i.Platform + ")"
+}
@@ -0,0 +1,17 @@
+package version
+
+import "testing"
+
+func TestGetUsesInjectedValues(t *testing.T) {
+	oldVersion, oldCommit, oldDate := Version, Commit, BuildDate
+	defer func() { Version, Commit, BuildDate = oldVersion, oldCommit, oldDate }()
+
+	Version, Commit, BuildDate = "v1.2.3", "abc1234", "2025-01-01T00:00:00Z"
+	info := Get()
+	if info.Version != "v1.2.3" || info.Commit != "abc1234" || info.BuildDate != "2025-01-01T00:00:00Z" {
+		t.Errorf("unexpected info: %+v", info)
+	}
+	if info.GoVersion == "" || info.Platform == "" {
+		t.Errorf("runtime fields should be filled: %+v", info)
+	}
+}
@@ -0,0 +1,342 @@
+// Package wasm 加载 WASM 模块 (wazero)，在沙箱中变换桥接客户端收到的请求与发出的响应。
+//
+// 模块需导出 memory 与 alloc(size i32) i32 (返回 size 字节的缓冲区)，并导出以下变换函数中的至少一个：
+//
+//	transform_request(ptr i32, len i32) i64   输入 {"action","request_id","tenant_id","params"}
+//	transform_response(ptr i32, len i32) i64  输入同上，另含 "data"
+//
+// 输入为写入 alloc 返回的缓冲区中的 JSON；返回值高 32 位为输出的地址、低 32 位为长度，0 表示不做修改。
+// 输出为 JSON 对象：请求变换返回 {"params": ...} 替换 params，响应变换返回 {"data": ...} 替换 data，
+// 二者均可返回 {"reject": "原因"} 拒绝请求。模块可以导入 ollama_dev.log(ptr i32, len i32) 写日志；
+// wasip1 模块可用的 WASI 函数不开放文件系统、网络与环境变量，时钟为伪造的时钟。
+package wasm
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/api"
+	"github.com/tetratelabs/wazero/imports/wasi_snapshot_preview1"
+
+	"ollama_dev/internal/config"
+	"ollama_dev/internal/script"
+)
+
+// 模块导出的变换函数
+const (
+	fnAlloc    = "alloc"
+	fnRequest  = "transform_request"
+	fnResponse = "transform_response"
+)
+
+const (
+	pageSize      = 64 << 10         // WASM 内存页的大小
+	warmupTimeout = 10 * time.Second // 加载时实例化模块的时限，包括 _initialize
+)
+
+// module 已编译的模块，实例不能并发使用，每次调用从池中取出一个
+type module struct {
+	name     string
+	compiled wazero.CompiledModule
+	request  bool // 导出了 transform_request
+	response bool // 导出了 transform_response
+
+	mu   sync.Mutex
+	idle []api.Module
+}
+
+// Runtime 已加载的变换模块，按文件名顺序依次变换
+type Runtime struct {
+	rt      wazero.Runtime
+	modules []*module
+	timeout time.Duration
+	logger  *slog.Logger
+}
+
+// Load 编译 cfg.Dir 中的 *.wasm (按文件名排序)，dir 为空时返回 nil
+func Load(ctx context.Context, cfg config.WASMConfig, logger *slog.Logger) (*Runtime, error) {
+	if cfg.Dir == "" {
+		return nil, nil
+	}
+	paths, err := filepath.Glob(filepath.Join(cfg.Dir, "*.wasm"))
+	if err != nil {
+		return nil, err
+	}
+	if len(paths) == 0 {
+		return nil, fmt.Errorf("目录 %s 中没有 .wasm 模块", cfg.Dir)
+	}
+	slices.Sort(paths)
+
+	rc := wazero.NewRuntimeConfig().
+		WithMemoryLimitPages(uint32(cfg.MemoryLimit) * (1 << 20 / pageSize)).
+		WithCloseOnContextDone(true)
+	r := &Runtime{rt: wazero.NewRuntimeWithConfig(ctx, rc), timeout: cfg.Timeout, logger: logger}
+	if err := r.init(ctx, paths); err != nil {
+		r.rt.Close(ctx)
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *Runtime) init(ctx context.Context, paths []string) error {
+	if _, err := wasi_snapshot_preview1.Instantiate(ctx, r.rt); err != nil {
+		return fmt.Errorf("初始化 WASI 失败: %w", err)
+	}
+	_, err := r.rt.NewHostModuleBuilder("ollama_dev").
+		NewFunctionBuilder().WithFunc(r.log).Export("log").
+		Instantiate(ctx)
+	if err != nil {
+		return fmt.Errorf("初始化宿主函数失败: %w", err)
+	}
+	for _, path := range paths {
+		m, err := r.compile(ctx, path)
+		if err != nil {
+			return err
+		}
+		r.modules = append(r.modules, m)
+	}
+	return nil
+}
+
+// compile 编译模块并检查导出的函数
+func (r *Runtime) compile(ctx context.Context, path string) (*module, error) {
+	name := filepath.Base(path)
+	bin, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("读取模块 %s 失败: %w", name, err)
+	}
+	compiled, err := r.rt.CompileModule(ctx, bin)
+	if err != nil {
+		return nil, fmt.Errorf("编译模块 %s 失败: %w", name, err)
+	}
+	fns := compiled.ExportedFunctions()
+	m := &module{name: name, compiled: compiled}
+	m.request = hasSignature(fns[fnRequest])
+	m.response = hasSignature(fns[fnResponse])
+	switch {
+	case compiled.ExportedMemories()["memory"] == nil:
+		return nil, fmt.Errorf("模块 %s 没有导出 memory", name)
+	case !slices.Equal(paramTypes(fns[fnAlloc]), []api.ValueType{api.ValueTypeI32}) || !slices.Equal(resultTypes(fns[fnAlloc]), []api.ValueType{api.ValueTypeI32}):
+		return nil, fmt.Errorf("模块 %s 没有导出 alloc(i32) i32", name)
+	case !m.request && !m.response:
+		return nil, fmt.Errorf("模块 %s 没有导出 %s(i32, i32) i64 或 %s(i32, i32) i64", name, fnRequest, fnResponse)
+	}
+
+	// 预先实例化一个，检查导入并避免第一次变换计入初始化的耗时
+	ctx, cancel := context.WithTimeout(ctx, warmupTimeout)
+	defer cancel()
+	inst, err := r.get(ctx, m)
+	if err != nil {
+		return nil, fmt.Errorf("实例化模块 %s 失败: %w", name, err)
+	}
+	m.put(inst)
+	return m, nil
+}
+
+// hasSignature 变换函数的签名是否为 (i32, i32) i64
+func hasSignature(fn api.FunctionDefinition) bool {
+	return slices.Equal(paramTypes(fn), []api.ValueType{api.ValueTypeI32, api.ValueTypeI32}) &&
+		slices.Equal(resultTypes(fn), []api.ValueType{api.ValueTypeI64})
+}
+
+func paramTypes(fn api.FunctionDefinition) []api.ValueType {
+	if fn == nil {
+		return nil
+	}
+	return fn.ParamTypes()
+}
+
+func resultTypes(fn api.FunctionDefinition) []api.ValueType {
+	if fn == nil {
+		return nil
+	}
+	return fn.ResultTypes()
+}
+
+// moduleKey 调用期间 ctx 中的模块名，供宿主函数写日志
+type moduleKey struct{}
+
+// log 宿主函数 ollama_dev.log
+func (r *Runtime) log(ctx context.Context, mod api.Module, ptr, size uint32) {
+	msg, ok := mod.Memory().Read(ptr, size)
+	if !ok {
+		return
+	}
+	name, _ := ctx.Value(moduleKey{}).(string)
+	r.logger.Info("WASM 模块输出", "module", name, "message", string(msg))
+}
+
+// HasBefore 是否有模块导出了 transform_request
+func (r *Runtime) HasBefore() bool {
+	return r != nil && slices.ContainsFunc(r.modules, func(m *module) bool { return m.request })
+}
+
+// HasAfter 是否有模块导出了 transform_response
+func (r *Runtime) HasAfter() bool {
+	return r != nil && slices.ContainsFunc(r.modules, func(m *module) bool { return m.response })
+}
+
+// input 传给变换函数的 JSON
+type input struct {
+	Action    string `json:"action"`
+	RequestID string `json:"request_id"`
+	TenantID  string `json:"tenant_id"`
+	Params    any    `json:"params"`
+	Data      any    `json:"data,omitempty"`
+}
+
+// output 变换函数返回的 JSON，未出现的字段不做修改
+type output struct {
+	Params json.RawMessage `json:"params"`
+	Data   json.RawMessage `json:"data"`
+	Reject string          `json:"reject"`
+}
+
+// Before 按模块顺序调用 transform_request，可替换 req.Params；拒绝时返回 *script.Rejected
+func (r *Runtime) Before(ctx context.Context, req *script.Request) error {
+	for _, m := range r.modules {
+		if !m.request {
+			continue
+		}
+		out, err := r.call(ctx, m, fnRequest, input{Action: req.Action, RequestID: req.RequestID, TenantID: req.TenantID, Params: req.Params})
+		if err != nil {
+			return err
+		}
+		if out == nil {
+			continue
+		}
+		if out.Reject != "" {
+			return &script.Rejected{Script: m.name, Reason: out.Reject}
+		}
+		if out.Params != nil {
+			if err := json.Unmarshal(out.Params, &req.Params); err != nil {
+				return fmt.Errorf("模块 %s: 解析 params 失败: %w", m.name, err)
+			}
+		}
+	}
+	return nil
+}
+
+// After 按模块顺序调用 transform_response，返回替换后的 data
+func (r *Runtime) After(ctx context.Context, req *script.Request, data any) (any, error) {
+	for _, m := range r.modules {
+		if !m.response {
+			continue
+		}
+		out, err := r.call(ctx, m, fnResponse, input{Action: req.Action, RequestID: req.RequestID, TenantID: req.TenantID, Params: req.Params, Data: data})
+		if err != nil {
+			return nil, err
+		}
+		if out == nil {
+			continue
+		}
+		if out.Reject != "" {
+			return nil, &script.Rejected{Script: m.name, Reason: out.Reject}
+		}
+		if out.Data != nil {
+			if err := json.Unmarshal(out.Data, &data); err != nil {
+				return nil, fmt.Errorf("模块 %s: 解析 data 失败: %w", m.name, err)
+			}
+		}
+	}
+	return data, nil
+}
+
+// call 在 timeout 内调用模块的变换函数，返回 nil 表示不做修改；超时返回包装 context.DeadlineExceeded 的错误
+func (r *Runtime) call(ctx context.Context, m *module, fn string, in input) (*output, error) {
+	payload, err := json.Marshal(in)
+	if err != nil {
+		return nil, fmt.Errorf("编码变换输入失败: %w", err)
+	}
+	ctx, cancel := context.WithTimeout(context.WithValue(ctx, moduleKey{}, m.name), r.timeout)
+	defer cancel()
+
+	inst, err := r.get(ctx, m)
+	if err != nil {
+		return nil, r.callError(ctx, m, err)
+	}
+	out, err := invoke(ctx, inst, fn, payload)
+	if err != nil {
+		// 中断或出错的实例可能处于不一致的状态，直接丢弃
+		inst.Close(context.Background())
+		return nil, r.callError(ctx, m, err)
+	}
+	m.put(inst)
+	if out == nil {
+		return nil, nil
+	}
+	var res output
+	if err := json.Unmarshal(out, &res); err != nil {
+		return nil, fmt.Errorf("模块 %s: 输出不是 JSON 对象: %w", m.name, err)
+	}
+	return &res, nil
+}
+
+// invoke 将 payload 写入实例的内存并调用 fn，返回输出的副本
+func invoke(ctx context.Context, inst api.Module, fn string, payload []byte) ([]byte, error) {
+	res, err := inst.ExportedFunction(fnAlloc).Call(ctx, uint64(len(payload)))
+	if err != nil {
+		return nil, err
+	}
+	ptr := uint32(res[0])
+	if !inst.Memory().Write(ptr, payload) {
+		return nil, fmt.Errorf("alloc 返回的地址 %d 越界", ptr)
+	}
+	res, err = inst.ExportedFunction(fn).Call(ctx, uint64(ptr), uint64(len(payload)))
+	if err != nil {
+		return nil, err
+	}
+	if res[0] == 0 {
+		return nil, nil
+	}
+	outPtr, outLen := uint32(res[0]>>32), uint32(res[0])
+	out, ok := inst.Memory().Read(outPtr, outLen)
+	if !ok {
+		return nil, fmt.Errorf("输出 [%d, +%d) 越界", outPtr, outLen)
+	}
+	return slices.Clone(out), nil
+}
+
+// callError 超时的调用包装 context.DeadlineExceeded，其余错误带上模块名
+func (r *Runtime) callError(ctx context.Context, m *module, err error) error {
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return fmt.Errorf("模块 %s: %w", m.name, ctxErr)
+	}
+	return fmt.Errorf("模块 %s: %w", m.name, err)
+}
+
+// get 从池中取出实例，没有空闲的时实例化一个；wasip1 的 reactor 模块在实例化时运行 _initialize
+func (r *Runtime) get(ctx context.Context, m *module) (api.Module, error) {
+	m.mu.Lock()
+	if n := len(m.idle); n > 0 {
+		inst := m.idle[n-1]
+		m.idle = m.idle[:n-1]
+		m.mu.Unlock()
+		return inst, nil
+	}
+	m.mu.Unlock()
+	cfg := wazero.NewModuleConfig().WithName("").WithStartFunctions("_initialize")
+	return r.rt.InstantiateModule(ctx, m.compiled, cfg)
+}
+
+func (m *module) put(inst api.Module) {
+	m.mu.Lock()
+	m.idle = append(m.idle, inst)
+	m.mu.Unlock()
+}
+
+// Close 关闭全部实例与运行时，r 可为 nil
+func (r *Runtime) Close() error {
+	if r == nil {
+		return nil
+	}
+	return r.rt.Close(context.Background())
+}
@@ -0,0 +1,189 @@
+package wasm
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"ollama_dev/internal/config"
+	"ollama_dev/internal/script"
+)
+
+// 测试模块的固定地址：请求变换的输出、响应变换的输出与 alloc 返回的输入缓冲区
+const (
+	requestOut  = 0
+	responseOut = 4096
+	inputBuf    = 8192
+)
+
+// loop 作为输出时，变换函数陷入死循环
+const loop = "\x00loop"
+
+// buildModule 手工编码一个最小的模块：alloc 返回固定的缓冲区，两个变换函数返回数据段中的固定输出，
+// 输出为空时返回 0 (不做修改)
+func buildModule(reqOut, respOut string) []byte {
+	const i32, i64 = 0x7f, 0x7e
+	types := vec([]byte{0x60, 1, i32, 1, i32}, []byte{0x60, 2, i32, i32, 1, i64})
+	funcs := vec([]byte{0}, []byte{1}, []byte{1})
+	memory := vec([]byte{0x00, 1})
+	exports := vec(export("memory", 0x02, 0), export("alloc", 0x00, 0), export("transform_request", 0x00, 1), export("transform_response", 0x00, 2))
+
+	allocBody := append(append([]byte{0x00, 0x41}, sleb(inputBuf)...), 0x0b)
+	code := vec(sized(allocBody), sized(transformBody(reqOut, requestOut)), sized(transformBody(respOut, responseOut)))
+
+	var segments [][]byte
+	for _, s := range []struct {
+		out string
+		at  int64
+	}{{reqOut, requestOut}, {respOut, responseOut}} {
+		if s.out == "" || s.out == loop {
+			continue
+		}
+		seg := append([]byte{0x00, 0x41}, sleb(s.at)...)
+		seg = append(seg, 0x0b)
+		segments = append(segments, append(seg, sized([]byte(s.out))...))
+	}
+
+	bin := []byte{0x00, 'a', 's', 'm', 0x01, 0x00, 0x00, 0x00}
+	for _, s := range []struct {
+		id      byte
+		content []byte
+	}{{1, types}, {3, funcs}, {5, memory}, {7, exports}, {10, code}, {11, vec(segments...)}} {
+		bin = append(append(bin, s.id), sized(s.content)...)
+	}
+	return bin
+}
+
+// transformBody 返回 (at<<32 | len(out)) 的函数体，out 为 loop 时死循环
+func transformBody(out string, at int64) []byte {
+	body := []byte{0x00}
+	switch out {
+	case loop:
+		body = append(body, 0x03, 0x40, 0x0c, 0x00, 0x0b, 0x42, 0x00)
+	case "":
+		body = append(body, 0x42, 0x00)
+	default:
+		body = append(append(body, 0x42), sleb(at<<32|int64(len(out)))...)
+	}
+	return append(body, 0x0b)
+}
+
+func export(name string, kind byte, index byte) []byte {
+	return append(sized([]byte(name)), kind, index)
+}
+
+func vec(items ...[]byte) []byte {
+	out := uleb(uint64(len(items)))
+	for _, it := range items {
+		out = append(out, it...)
+	}
+	return out
+}
+
+func sized(b []byte) []byte {
+	return append(uleb(uint64(len(b))), b...)
+}
+
+func uleb(v uint64) []byte {
+	var out []byte
+	for {
+		b := byte(v & 0x7f)
+		v >>= 7
+		if v == 0 {
+			return append(out, b)
+		}
+		out = append(out, b|0x80)
+	}
+}
+
+func sleb(v int64) []byte {
+	var out []byte
+	for {
+		b := byte(v & 0x7f)
+		v >>= 7
+		if (v == 0 && b&0x40 == 0) || (v == -1 && b&0x40 != 0) {
+			return append(out, b)
+		}
+		out = append(out, b|0x80)
+	}
+}
+
+// load 将模块写入临时目录并加载
+func load(t *testing.T, modules map[string][]byte) (*Runtime, error) {
+	t.Helper()
+	dir := t.TempDir()
+	for name, bin := range modules {
+		if err := os.WriteFile(filepath.Join(dir, name), bin, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	cfg := config.WASMConfig{Dir: dir, Timeout: 200 * time.Millisecond, MemoryLimit: 1}
+	r, err := Load(context.Background(), cfg, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	if r != nil {
+		t.Cleanup(func() { r.Close() })
+	}
+	return r, err
+}
+
+func TestTransforms(t *testing.T) {
+	r, err := load(t, map[string][]byte{
+		"10-model.wasm": buildModule(`{"params":{"model_name":"llama3","stream":true}}`, ""),
+		"20-data.wasm":  buildModule("", `{"data":{"redacted":true}}`),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !r.HasBefore() || !r.HasAfter() {
+		t.Fatal("expected both hooks")
+	}
+
+	req := &script.Request{Action: "chat", RequestID: "r1", Params: map[string]any{"model_name": "default"}}
+	for range 3 { // 实例复用
+		if err := r.Before(context.Background(), req); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if want := map[string]any{"model_name": "llama3", "stream": true}; !reflect.DeepEqual(req.Params, want) {
+		t.Errorf("expected params %v, got %v", want, req.Params)
+	}
+	data, err := r.After(context.Background(), req, map[string]any{"message": "secret"})
+	if want := map[string]any{"redacted": true}; err != nil || !reflect.DeepEqual(data, want) {
+		t.Errorf("expected data %v, got %v %v", want, data, err)
+	}
+}
+
+func TestRejectAndTimeout(t *testing.T) {
+	r, err := load(t, map[string][]byte{"guard.wasm": buildModule(`{"reject":"模型不可用"}`, loop)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	req := &script.Request{Action: "chat", Params: map[string]any{}}
+
+	var rejected *script.Rejected
+	if err := r.Before(context.Background(), req); !errors.As(err, &rejected) || rejected.Script != "guard.wasm" || rejected.Reason != "模型不可用" {
+		t.Errorf("expected rejection, got %v", err)
+	}
+	// 死循环在时限内被中断
+	start := time.Now()
+	if _, err := r.After(context.Background(), req, nil); !errors.Is(err, context.DeadlineExceeded) || time.Since(start) > 2*time.Second {
+		t.Errorf("expected a deadline error, got %v after %s", err, time.Since(start))
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	if r, err := Load(context.Background(), config.WASMConfig{}, nil); r != nil || err != nil {
+		t.Errorf("expected nil runtime without dir, got %v %v", r, err)
+	}
+	if _, err := load(t, nil); err == nil {
+		t.Error("expected an error for a directory without modules")
+	}
+	if _, err := load(t, map[string][]byte{"bad.wasm": []byte("not wasm")}); err == nil {
+		t.Error("expected an error for an invalid module")
+	}
+}
@@ -0,0 +1,129 @@
+package wsbench
+
+import (
+	"encoding/json"
+	"errors"
+	"sync"
+
+	"github.com/gorilla/websocket"
+
+	"ollama_dev/internal/bridge"
+	"ollama_dev/internal/config"
+)
+
+// errClosed 连接已断开
+var errClosed = errors.New("连接已断开")
+
+// call 一个等待响应的请求
+type call struct {
+	frames chan *bridge.Envelope // 该请求的响应帧，连接断开时关闭
+	done   chan struct{}         // 请求结束后关闭，之后到达的帧丢弃
+}
+
+// conn 一条压测连接，同时进行的多个请求按 request_id 分发响应帧
+type conn struct {
+	ws           *websocket.Conn
+	maxFrameSize int
+	reassembler  *bridge.Reassembler
+	writeMu      sync.Mutex
+
+	mu      sync.Mutex
+	pending map[string]*call // 连接断开后为 nil
+	closed  chan struct{}
+}
+
+func newConn(ws *websocket.Conn, chunking config.ChunkingConfig) *conn {
+	c := &conn{
+		ws:           ws,
+		maxFrameSize: chunking.MaxFrameSize,
+		reassembler:  bridge.NewReassembler(chunking),
+		pending:      make(map[string]*call),
+		closed:       make(chan struct{}),
+	}
+	go c.readLoop()
+	return c
+}
+
+// readLoop 读取并重组帧，只分发等待中的请求的响应帧；hub 广播来的其他连接的请求、响应与心跳直接丢弃
+func (c *conn) readLoop() {
+	defer func() {
+		c.mu.Lock()
+		for _, p := range c.pending {
+			close(p.frames)
+		}
+		c.pending = nil
+		c.mu.Unlock()
+		close(c.closed)
+	}()
+	for {
+		_, frame, err := c.ws.ReadMessage()
+		if err != nil {
+			return
+		}
+		msg, err := c.reassembler.Add(frame)
+		if err != nil || msg == nil {
+			continue
+		}
+		var env bridge.Envelope
+		if json.Unmarshal(msg, &env) != nil || env.Type != bridge.TypeClientToServer || env.RequestID == "" {
+			continue
+		}
+		c.mu.Lock()
+		p := c.pending[env.RequestID]
+		c.mu.Unlock()
+		if p == nil {
+			continue
+		}
+		select {
+		case p.frames <- &env:
+		case <-p.done:
+		}
+	}
+}
+
+// register 登记等待响应的请求，连接已断开时返回 errClosed
+func (c *conn) register(id string) (*call, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.pending == nil {
+		return nil, errClosed
+	}
+	p := &call{frames: make(chan *bridge.Envelope, 16), done: make(chan struct{})}
+	c.pending[id] = p
+	return p, nil
+}
+
+// unregister 结束等待
+func (c *conn) unregister(id string, p *call) {
+	close(p.done)
+	c.mu.Lock()
+	if c.pending != nil {
+		delete(c.pending, id)
+	}
+	c.mu.Unlock()
+}
+
+// send 序列化 v 并按帧大小限制分片发送
+func (c *conn) send(v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	frames, err := bridge.SplitFrame(data, c.maxFrameSize)
+	if err != nil {
+		return err
+	}
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	for _, f := range frames {
+		if err := c.ws.WriteMessage(websocket.TextMessage, f); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// close 关闭连接
+func (c *conn) close() error {
+	return c.ws.Close()
+}
@@ -0,0 +1,201 @@
+package wsbench
+
+import (
+	"fmt"
+	"io"
+	"slices"
+	"sync"
+	"text/tabwriter"
+	"time"
+)
+
+// 请求未以错误帧结束时记录的错误码
+const (
+	CodeTimeout      = "timeout"      // 超过 Options.Timeout 仍未收到 done 或 error
+	CodeDisconnected = "disconnected" // 收到响应前连接断开
+	CodeSendFailed   = "send_failed"  // 请求写入连接失败
+)
+
+// Latency 耗时分位数 (毫秒)
+type Latency struct {
+	P50Ms float64 `json:"p50_ms"`
+	P90Ms float64 `json:"p90_ms"`
+	P99Ms float64 `json:"p99_ms"`
+	MaxMs float64 `json:"max_ms"`
+}
+
+// ActionStats 一种请求的统计
+type ActionStats struct {
+	Requests         int64            `json:"requests"`
+	OK               int64            `json:"ok"`
+	Errors           map[string]int64 `json:"errors,omitempty"` // 错误码 -> 次数
+	Latency          Latency          `json:"latency"`          // 成功请求从发送到 done 帧的耗时
+	FirstChunk       *Latency         `json:"first_chunk,omitempty"`
+	PromptTokens     int64            `json:"prompt_tokens,omitempty"`
+	CompletionTokens int64            `json:"completion_tokens,omitempty"`
+}
+
+// Report 一次压测的结果
+type Report struct {
+	Conns      int                     `json:"conns"`       // 建立成功的连接数
+	DialErrors int                     `json:"dial_errors"` // 建立失败的连接数
+	Elapsed    time.Duration           `json:"elapsed"`     // 第一个请求发送到最后一个请求结束
+	Sent       int64                   `json:"sent"`
+	OK         int64                   `json:"ok"`
+	Failed     int64                   `json:"failed"`
+	Missed     int64                   `json:"missed"` // 按速率到期时所有请求槽位都在等待响应，未能发送的请求
+	Throughput float64                 `json:"throughput"`
+	Actions    map[string]*ActionStats `json:"actions"`
+}
+
+// ErrorRate 失败请求占已发送请求的比例
+func (r *Report) ErrorRate() float64 {
+	if r.Sent == 0 {
+		return 0
+	}
+	return float64(r.Failed) / float64(r.Sent)
+}
+
+// result 单个请求的结果
+type result struct {
+	action     string
+	code       string // 空表示成功
+	latency    time.Duration
+	firstChunk time.Duration // 流式请求收到第一个分片的耗时，非流式为 0
+	prompt     int64
+	completion int64
+}
+
+// recorder 汇总各 worker 的请求结果
+type recorder struct {
+	mu      sync.Mutex
+	actions map[string]*samples
+}
+
+type samples struct {
+	stats      ActionStats
+	latencies  []time.Duration
+	firstChunk []time.Duration
+}
+
+func newRecorder() *recorder {
+	return &recorder{actions: make(map[string]*samples)}
+}
+
+func (r *recorder) add(res result) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	s := r.actions[res.action]
+	if s == nil {
+		s = &samples{}
+		r.actions[res.action] = s
+	}
+	s.stats.Requests++
+	if res.code != "" {
+		if s.stats.Errors == nil {
+			s.stats.Errors = make(map[string]int64)
+		}
+		s.stats.Errors[res.code]++
+		return
+	}
+	s.stats.OK++
+	s.stats.PromptTokens += res.prompt
+	s.stats.CompletionTokens += res.completion
+	s.latencies = append(s.latencies, res.latency)
+	if res.firstChunk > 0 {
+		s.firstChunk = append(s.firstChunk, res.firstChunk)
+	}
+}
+
+// report 计算分位数并填充 rep 的各项统计
+func (r *recorder) report(rep *Report) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	rep.Actions = make(map[string]*ActionStats, len(r.actions))
+	for name, s := range r.actions {
+		stats := s.stats
+		stats.Latency = percentiles(s.latencies)
+		if len(s.firstChunk) > 0 {
+			first := percentiles(s.firstChunk)
+			stats.FirstChunk = &first
+		}
+		rep.Actions[name] = &stats
+		rep.Sent += stats.Requests
+		rep.OK += stats.OK
+		rep.Failed += stats.Requests - stats.OK
+	}
+	if rep.Elapsed > 0 {
+		rep.Throughput = float64(rep.OK) / rep.Elapsed.Seconds()
+	}
+}
+
+// percentiles 按最近秩法计算分位数，会对 d 排序
+func percentiles(d []time.Duration) Latency {
+	if len(d) == 0 {
+		return Latency{}
+	}
+	slices.Sort(d)
+	at := func(p float64) float64 {
+		i := int(float64(len(d))*p+0.5) - 1
+		return ms(d[min(max(i, 0), len(d)-1)])
+	}
+	return Latency{P50Ms: at(0.5), P90Ms: at(0.9), P99Ms: at(0.99), MaxMs: ms(d[len(d)-1])}
+}
+
+func ms(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+// Print 以表格形式输出压测结果
+func Print(w io.Writer, r *Report) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+
+	fmt.Fprintf(tw, "连接\t%d (失败 %d)\n", r.Conns, r.DialErrors)
+	fmt.Fprintf(tw, "时长\t%s\n", r.Elapsed.Round(time.Millisecond))
+	fmt.Fprintf(tw, "请求\t%d (成功 %d，失败 %d，错误率 %.2f%%)\n", r.Sent, r.OK, r.Failed, r.ErrorRate()*100)
+	if r.Missed > 0 {
+		fmt.Fprintf(tw, "未发送\t%d (请求槽位已满，可增加 --conns 或 --inflight)\n", r.Missed)
+	}
+	fmt.Fprintf(tw, "吞吐\t%.1f 请求/秒\n", r.Throughput)
+
+	names := make([]string, 0, len(r.Actions))
+	for name := range r.Actions {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+
+	fmt.Fprintln(tw)
+	fmt.Fprintln(tw, "动作\t请求\t失败\tp50(ms)\tp90(ms)\tp99(ms)\t最大(ms)\t首个分片 p50/p99(ms)\ttoken")
+	for _, name := range names {
+		a := r.Actions[name]
+		first := "-"
+		if a.FirstChunk != nil {
+			first = fmt.Sprintf("%.1f/%.1f", a.FirstChunk.P50Ms, a.FirstChunk.P99Ms)
+		}
+		latency := "-\t-\t-\t-"
+		if a.OK > 0 {
+			latency = fmt.Sprintf("%.1f\t%.1f\t%.1f\t%.1f", a.Latency.P50Ms, a.Latency.P90Ms, a.Latency.P99Ms, a.Latency.MaxMs)
+		}
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%s\t%s\t%d\n", name, a.Requests, a.Requests-a.OK, latency, first, a.PromptTokens+a.CompletionTokens)
+	}
+
+	byCode := map[string]int64{}
+	for _, a := range r.Actions {
+		for code, n := range a.Errors {
+			byCode[code] += n
+		}
+	}
+	if len(byCode) > 0 {
+		codes := make([]string, 0, len(byCode))
+		for code := range byCode {
+			codes = append(codes, code)
+		}
+		slices.Sort(codes)
+		fmt.Fprintln(tw)
+		fmt.Fprintln(tw, "错误码\t次数")
+		for _, code := range codes {
+			fmt.Fprintf(tw, "%s\t%d\n", code, byCode[code])
+		}
+	}
+	return tw.Flush()
+}
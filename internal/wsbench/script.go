@@ -0,0 +1,130 @@
+package wsbench
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/rand/v2"
+	"os"
+	"strconv"
+	"strings"
+
+	"ollama_dev/internal/bridge"
+)
+
+// DefaultMix 未指定脚本时的请求组合
+const DefaultMix = "chat=6,list_model=3,embeddings=1"
+
+// Step 脚本中的一种请求，按 Weight 占全部权重的比例随机选取
+type Step struct {
+	Action string             `json:"action"`
+	Weight int                `json:"weight"`
+	Params bridge.CloudParams `json:"params"` // chat 与 embeddings 未指定模型、消息或输入时按 Options 补全
+}
+
+// ParseMix 解析形如 chat=6,list_model=3,embeddings=1 的请求组合，省略权重时为 1
+func ParseMix(s string) ([]Step, error) {
+	var steps []Step
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		action, weight, found := strings.Cut(part, "=")
+		step := Step{Action: strings.TrimSpace(action), Weight: 1}
+		if found {
+			n, err := strconv.Atoi(strings.TrimSpace(weight))
+			if err != nil {
+				return nil, fmt.Errorf("无效的权重 %q", part)
+			}
+			step.Weight = n
+		}
+		steps = append(steps, step)
+	}
+	return steps, validateSteps(steps)
+}
+
+// LoadScript 读取 JSON 格式的脚本文件，内容为 Step 数组，params 与请求帧的 params 相同
+func LoadScript(path string) ([]Step, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("读取脚本失败: %w", err)
+	}
+	var steps []Step
+	if err := json.Unmarshal(data, &steps); err != nil {
+		return nil, fmt.Errorf("解析脚本 %s 失败: %w", path, err)
+	}
+	return steps, validateSteps(steps)
+}
+
+func validateSteps(steps []Step) error {
+	if len(steps) == 0 {
+		return fmt.Errorf("请求组合为空")
+	}
+	for _, s := range steps {
+		if s.Action == "" {
+			return fmt.Errorf("请求缺少 action")
+		}
+		if s.Weight <= 0 {
+			return fmt.Errorf("%s 的权重必须为正数", s.Action)
+		}
+	}
+	return nil
+}
+
+// picker 按权重随机选取请求
+type picker struct {
+	steps []Step
+	total int
+}
+
+func newPicker(steps []Step) *picker {
+	p := &picker{steps: steps}
+	for _, s := range steps {
+		p.total += s.Weight
+	}
+	return p
+}
+
+func (p *picker) pick() Step {
+	n := rand.IntN(p.total)
+	for _, s := range p.steps {
+		if n < s.Weight {
+			return s
+		}
+		n -= s.Weight
+	}
+	return p.steps[len(p.steps)-1]
+}
+
+// needsModel 请求是否需要模型
+func needsModel(action string) bool {
+	return action == "chat" || action == "generate" || action == "embeddings"
+}
+
+// fill 用 opts 补全 step 中缺少的模型与输入
+func fill(step Step, opts Options) Step {
+	p := step.Params
+	if needsModel(step.Action) && p.ModelName == "" {
+		p.ModelName = opts.Model
+	}
+	switch step.Action {
+	case "chat":
+		if len(p.Messages) == 0 {
+			p.Messages = []bridge.ChatMessage{{Role: "user", Content: opts.Prompt}}
+		}
+		p.Stream = p.Stream || opts.Stream
+		p.NoCache = p.NoCache || opts.NoCache
+	case "generate":
+		if p.Prompt == "" {
+			p.Prompt = opts.Prompt
+		}
+		p.Stream = p.Stream || opts.Stream
+		p.NoCache = p.NoCache || opts.NoCache
+	case "embeddings":
+		if len(p.Input) == 0 {
+			p.Input = []string{opts.Prompt}
+		}
+	}
+	step.Params = p
+	return step
+}
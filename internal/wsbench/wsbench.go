@@ -0,0 +1,287 @@
+// Package wsbench 对服务器的 WebSocket 接口进行压测：建立多条连接，按目标速率发送脚本化的请求组合，
+// 统计各动作的耗时分位数、错误码与吞吐，用于检验 hub 的背压与桥接客户端的并发处理
+package wsbench
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
+
+	"ollama_dev/internal/bridge"
+	"ollama_dev/internal/config"
+)
+
+// DialFunc 建立一条到服务器的 WebSocket 连接
+type DialFunc func(ctx context.Context) (*websocket.Conn, error)
+
+// Options 压测参数
+type Options struct {
+	Conns    int           // 并发连接数
+	Inflight int           // 每条连接同时等待响应的请求数
+	Rate     float64       // 全部连接合计的目标发送速率 (请求/秒)；0 表示请求结束后立即发送下一个
+	Duration time.Duration // 发送请求的时长，之后等待进行中的请求结束
+	Requests int64         // 请求总数上限，0 表示不限
+	Timeout  time.Duration // 单个请求等待 done 或 error 的时限
+	Steps    []Step        // 请求组合
+	Model    string        // chat、generate 与 embeddings 使用的模型，为空时使用 list_model 返回的第一个
+	Prompt   string        // 未在脚本中指定消息或输入时使用的提示词
+	Stream   bool          // chat 与 generate 以流式返回，统计首个分片的耗时
+	NoCache  bool          // chat 与 generate 不使用 bridge 的结果缓存
+	Chunking config.ChunkingConfig
+}
+
+// Run 按 opts 压测，ctx 取消时中止进行中的请求并返回已有的统计；没有一条连接建立成功时返回错误
+func Run(ctx context.Context, dial DialFunc, opts Options) (*Report, error) {
+	opts.Conns = max(opts.Conns, 1)
+	opts.Inflight = max(opts.Inflight, 1)
+	rep := &Report{}
+
+	conns := dialAll(ctx, dial, opts, rep)
+	if len(conns) == 0 {
+		return nil, fmt.Errorf("%d 条连接均建立失败", rep.DialErrors)
+	}
+	defer func() {
+		for _, c := range conns {
+			c.close()
+		}
+	}()
+
+	if opts.Model == "" && needsDefaultModel(opts.Steps) {
+		model, err := firstModel(ctx, conns[0], opts.Timeout)
+		if err != nil {
+			return nil, err
+		}
+		opts.Model = model
+	}
+
+	b := &bench{opts: opts, picker: newPicker(opts.Steps), rec: newRecorder(), jobs: make(chan Step)}
+	var wg sync.WaitGroup
+	for _, c := range conns {
+		for range opts.Inflight {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				b.work(ctx, c)
+			}()
+		}
+	}
+	idle := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(idle)
+	}()
+
+	start := time.Now()
+	window := ctx
+	if opts.Duration > 0 {
+		var cancel context.CancelFunc
+		window, cancel = context.WithTimeout(ctx, opts.Duration)
+		defer cancel()
+	}
+	if opts.Rate > 0 {
+		b.paced(window, idle)
+	} else {
+		b.closed(window, idle)
+	}
+	close(b.jobs)
+	<-idle
+
+	rep.Elapsed = time.Since(start)
+	rep.Missed = b.missed.Load()
+	b.rec.report(rep)
+	return rep, nil
+}
+
+// dialAll 并发建立全部连接，失败的计入 rep.DialErrors
+func dialAll(ctx context.Context, dial DialFunc, opts Options, rep *Report) []*conn {
+	var (
+		mu    sync.Mutex
+		wg    sync.WaitGroup
+		conns []*conn
+	)
+	for range opts.Conns {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ws, err := dial(ctx)
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				rep.DialErrors++
+				return
+			}
+			conns = append(conns, newConn(ws, opts.Chunking))
+		}()
+	}
+	wg.Wait()
+	rep.Conns = len(conns)
+	return conns
+}
+
+// needsDefaultModel 是否有请求需要由 list_model 选出模型
+func needsDefaultModel(steps []Step) bool {
+	for _, s := range steps {
+		if needsModel(s.Action) && s.Params.ModelName == "" {
+			return true
+		}
+	}
+	return false
+}
+
+// firstModel 经 c 发送 list_model，返回第一个模型
+func firstModel(ctx context.Context, c *conn, timeout time.Duration) (string, error) {
+	env, code := exchange(ctx, c, Step{Action: "list_model"}, timeout, nil)
+	if code != "" {
+		return "", fmt.Errorf("list_model 失败: %s", code)
+	}
+	var models []bridge.ModelInfo
+	if err := json.Unmarshal(env.Data, &models); err != nil || len(models) == 0 {
+		return "", fmt.Errorf("模型列表为空，使用 --model 指定")
+	}
+	return models[0].Name, nil
+}
+
+// bench 一次压测的共享状态
+type bench struct {
+	opts   Options
+	picker *picker
+	rec    *recorder
+	jobs   chan Step // 无缓冲，空闲的 worker 接收
+	sent   atomic.Int64
+	missed atomic.Int64
+}
+
+// limited 是否已达请求总数上限
+func (b *bench) limited() bool {
+	return b.opts.Requests > 0 && b.sent.Load() >= b.opts.Requests
+}
+
+// closed 闭环发送：每个空闲的请求槽位立即得到下一个请求
+func (b *bench) closed(ctx context.Context, idle <-chan struct{}) {
+	for !b.limited() {
+		select {
+		case b.jobs <- b.picker.pick():
+			b.sent.Add(1)
+		case <-ctx.Done():
+			return
+		case <-idle:
+			return
+		}
+	}
+}
+
+// paced 开环发送：按 Rate 计算到期的请求数，没有空闲槽位的请求不等待，计入 missed，
+// 从而暴露服务器变慢时的积压而不是降低发送速率
+func (b *bench) paced(ctx context.Context, idle <-chan struct{}) {
+	tick := min(time.Duration(float64(time.Second)/b.opts.Rate), 10*time.Millisecond)
+	ticker := time.NewTicker(tick)
+	defer ticker.Stop()
+	start := time.Now()
+	var due int64
+	for !b.limited() {
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		case <-idle:
+			return
+		}
+		target := int64(time.Since(start).Seconds() * b.opts.Rate)
+		for ; due < target && !b.limited(); due++ {
+			select {
+			case b.jobs <- b.picker.pick():
+				b.sent.Add(1)
+			default:
+				b.missed.Add(1)
+			}
+		}
+	}
+}
+
+// work 在 c 上逐个执行请求，连接断开后退出
+func (b *bench) work(ctx context.Context, c *conn) {
+	for step := range b.jobs {
+		res := b.do(ctx, c, fill(step, b.opts))
+		b.rec.add(res)
+		if res.code == CodeDisconnected || res.code == CodeSendFailed {
+			return
+		}
+	}
+}
+
+// do 发送一个请求并等待结果
+func (b *bench) do(ctx context.Context, c *conn, step Step) result {
+	res := result{action: step.Action}
+	start := time.Now()
+	env, code := exchange(ctx, c, step, b.opts.Timeout, func() {
+		if res.firstChunk == 0 {
+			res.firstChunk = time.Since(start)
+		}
+	})
+	res.latency, res.code = time.Since(start), code
+	if env != nil && env.Usage != nil {
+		res.prompt, res.completion = int64(env.Usage.PromptTokens), int64(env.Usage.CompletionTokens)
+	}
+	return res
+}
+
+// exchange 发送 step 并读取响应帧直到 done 或 error，每个 streaming 帧调用 onChunk (可为 nil)；
+// 返回最后一帧与错误码，成功时错误码为空。超时后发送 cancel 帧，避免流式响应继续占用桥接客户端
+func exchange(ctx context.Context, c *conn, step Step, timeout time.Duration, onChunk func()) (*bridge.Envelope, string) {
+	req := &bridge.CloudRequest{
+		V: bridge.ProtocolVersion, Type: bridge.TypeServerToClient, Action: step.Action,
+		RequestID: uuid.NewString(), Params: step.Params,
+	}
+	p, err := c.register(req.RequestID)
+	if err != nil {
+		return nil, CodeDisconnected
+	}
+	defer c.unregister(req.RequestID, p)
+	if err := c.send(req); err != nil {
+		return nil, CodeSendFailed
+	}
+
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			_ = c.send(&bridge.CloudRequest{V: bridge.ProtocolVersion, Type: bridge.TypeServerToClient, Action: bridge.ActionCancel, RequestID: req.RequestID})
+			return nil, CodeTimeout
+		case env, ok := <-p.frames:
+			if !ok {
+				return nil, CodeDisconnected
+			}
+			switch env.Status {
+			case bridge.StatusStreaming:
+				if onChunk != nil {
+					onChunk()
+				}
+			case bridge.StatusDuplicate, bridge.StatusQueued:
+				// 相同 request_id 的请求仍在处理中，或请求在任务队列中等待
+			case bridge.StatusError:
+				return env, errorCode(env)
+			default:
+				return env, ""
+			}
+		}
+	}
+}
+
+// errorCode 错误帧的错误码，无法解析时为 bridge.StatusError
+func errorCode(env *bridge.Envelope) string {
+	var data bridge.ErrorData
+	if json.Unmarshal(env.Data, &data) != nil || data.Code == "" {
+		return bridge.StatusError
+	}
+	return data.Code
+}
@@ -0,0 +1,176 @@
+package wsbench
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"ollama_dev/internal/bridge"
+	"ollama_dev/internal/config"
+)
+
+// fakeServer 扮演 hub 与桥接客户端：list_model 返回一个模型，chat 流式返回两个分片，
+// embeddings 返回 busy 错误，slow 不回复；其他连接的请求不会转发过来
+type fakeServer struct {
+	cancels atomic.Int64
+}
+
+func (f *fakeServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	ws, err := (&websocket.Upgrader{}).Upgrade(w, r, nil)
+	if err != nil {
+		return
+	}
+	defer ws.Close()
+	out := make(chan any, 64)
+	go func() {
+		for v := range out {
+			if ws.WriteJSON(v) != nil {
+				return
+			}
+		}
+	}()
+	defer close(out)
+	for {
+		var req bridge.CloudRequest
+		if err := ws.ReadJSON(&req); err != nil {
+			return
+		}
+		resp := map[string]any{"type": bridge.TypeClientToServer, "action": req.Action, "request_id": req.RequestID, "status": bridge.StatusDone}
+		switch req.Action {
+		case "list_model":
+			resp["data"] = []bridge.ModelInfo{{Name: "llama3:latest"}}
+		case "chat":
+			if req.Params.ModelName != "llama3:latest" || !req.Params.Stream {
+				resp["status"], resp["data"] = bridge.StatusError, bridge.ErrorData{Code: "invalid_params"}
+				break
+			}
+			for range 2 {
+				out <- map[string]any{"type": bridge.TypeClientToServer, "action": "chat", "request_id": req.RequestID, "status": bridge.StatusStreaming}
+			}
+			resp["usage"] = bridge.Usage{Model: req.Params.ModelName, PromptTokens: 3, CompletionTokens: 4}
+		case "embeddings":
+			resp["status"], resp["data"] = bridge.StatusError, bridge.ErrorData{Code: "busy"}
+		case bridge.ActionCancel:
+			f.cancels.Add(1)
+			continue
+		case "slow":
+			continue
+		}
+		out <- resp
+	}
+}
+
+func newBench(t *testing.T) (*fakeServer, DialFunc) {
+	f := &fakeServer{}
+	srv := httptest.NewServer(f)
+	t.Cleanup(srv.Close)
+	url := "ws" + strings.TrimPrefix(srv.URL, "http")
+	return f, func(ctx context.Context) (*websocket.Conn, error) {
+		ws, _, err := websocket.DefaultDialer.DialContext(ctx, url, nil)
+		return ws, err
+	}
+}
+
+func TestRunReportsPerAction(t *testing.T) {
+	_, dial := newBench(t)
+	steps, err := ParseMix("chat=1,embeddings=1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rep, err := Run(context.Background(), dial, Options{
+		Conns: 2, Inflight: 2, Requests: 40, Timeout: time.Second,
+		Steps: steps, Prompt: "hi", Stream: true, Chunking: config.Default().Chunking,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rep.Conns != 2 || rep.Sent != 40 || rep.Missed != 0 || rep.Throughput <= 0 {
+		t.Fatalf("unexpected report %+v", rep)
+	}
+
+	// 模型由 list_model 选出；流式对话统计首个分片与 token，busy 按错误码计数
+	chat, embed := rep.Actions["chat"], rep.Actions["embeddings"]
+	if chat == nil || embed == nil || chat.Requests+embed.Requests != 40 {
+		t.Fatalf("unexpected actions %+v", rep.Actions)
+	}
+	if chat.OK != chat.Requests || chat.FirstChunk == nil || chat.PromptTokens != 3*chat.OK || chat.CompletionTokens != 4*chat.OK {
+		t.Errorf("unexpected chat stats %+v", chat)
+	}
+	if embed.OK != 0 || embed.Errors["busy"] != embed.Requests || rep.Failed != embed.Requests {
+		t.Errorf("unexpected embeddings stats %+v", embed)
+	}
+}
+
+func TestRunPacedCountsMissed(t *testing.T) {
+	f, dial := newBench(t)
+	rep, err := Run(context.Background(), dial, Options{
+		Conns: 1, Rate: 200, Duration: 300 * time.Millisecond, Timeout: 100 * time.Millisecond,
+		Steps: []Step{{Action: "slow", Weight: 1}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 唯一的槽位一直在等待响应，按速率到期的其余请求计入 missed；超时的请求发送 cancel
+	slow := rep.Actions["slow"]
+	if slow == nil || slow.Requests == 0 || slow.Errors[CodeTimeout] != slow.Requests || rep.Missed == 0 {
+		t.Fatalf("unexpected report %+v %+v", rep, slow)
+	}
+	deadline := time.Now().Add(time.Second)
+	for f.cancels.Load() != slow.Requests && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if n := f.cancels.Load(); n != slow.Requests {
+		t.Errorf("expected %d cancel frames, got %d", slow.Requests, n)
+	}
+}
+
+func TestRunFailsWithoutConnections(t *testing.T) {
+	dial := func(ctx context.Context) (*websocket.Conn, error) { return nil, context.DeadlineExceeded }
+	if _, err := Run(context.Background(), dial, Options{Conns: 3, Steps: []Step{{Action: "version", Weight: 1}}}); err == nil {
+		t.Error("expected an error when no connection is established")
+	}
+}
+
+func TestParseMix(t *testing.T) {
+	steps, err := ParseMix(" chat=6, list_model ,embeddings=1")
+	if err != nil || len(steps) != 3 || steps[0].Weight != 6 || steps[1].Action != "list_model" || steps[1].Weight != 1 {
+		t.Errorf("unexpected steps %+v %v", steps, err)
+	}
+	for _, bad := range []string{"", "chat=x", "chat=0", "=2"} {
+		if _, err := ParseMix(bad); err == nil {
+			t.Errorf("expected %q to be rejected", bad)
+		}
+	}
+}
+
+func TestPercentilesAndPrint(t *testing.T) {
+	var d []time.Duration
+	for i := 100; i >= 1; i-- {
+		d = append(d, time.Duration(i)*time.Millisecond)
+	}
+	if l := percentiles(d); l != (Latency{P50Ms: 50, P90Ms: 90, P99Ms: 99, MaxMs: 100}) {
+		t.Errorf("unexpected percentiles %+v", l)
+	}
+
+	rep := &Report{Conns: 1, Elapsed: time.Second, Sent: 2, OK: 1, Failed: 1, Actions: map[string]*ActionStats{
+		"chat": {Requests: 2, OK: 1, Errors: map[string]int64{"rate_limited": 1}},
+	}}
+	var b strings.Builder
+	if err := Print(&b, rep); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(b.String(), "错误率 50.00%") || !strings.Contains(b.String(), "rate_limited") {
+		t.Errorf("unexpected output:\n%s", b.String())
+	}
+	if _, err := json.Marshal(rep); err != nil {
+		t.Fatal(err)
+	}
+}
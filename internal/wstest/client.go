@@ -0,0 +1,51 @@
+package wstest
+
+import (
+	"bufio"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+
+	"github.com/gorilla/websocket"
+
+	"ollama_dev/internal/util/wsutils"
+)
+
+// RunClient 连接到测试服务器，并将标准输入逐行发送出去
+func RunClient(url, origin string, logger *slog.Logger) error {
+	// 自定义 Dialer，设置 Origin 请求头
+	dialer := websocket.Dialer{}
+
+	// 设置自定义请求头
+	header := http.Header{}
+	header.Add("Origin", origin)                 // 设置为服务端允许的 Origin
+	header.Add("X-Custom-Header", "ClientValue") // 自定义请求头
+
+	// 连接到 WebSocket 服务器
+	conn, resp, err := dialer.Dial(url, header)
+	if err != nil {
+		return fmt.Errorf("连接失败: %w, 响应: %v", err, resp)
+	}
+	defer conn.Close()
+
+	logger.Info("已连接到服务器", "url", url)
+
+	m := wsutils.NewWebSocketManager(logger)
+
+	m.ReceiveMessages(conn)
+
+	go m.StartPingPong(conn)
+
+	// 发送消息
+	scanner := bufio.NewScanner(os.Stdin)
+	for scanner.Scan() {
+		text := scanner.Text()
+		err := conn.WriteMessage(websocket.TextMessage, []byte(text))
+		if err != nil {
+			return fmt.Errorf("发送消息失败: %w", err)
+		}
+		logger.Info("已发送消息", "text", text)
+	}
+	return scanner.Err()
+}
@@ -1,13 +1,17 @@
-package main
+package wstest
 
 import (
+	"context"
 	"encoding/json"
-	"log"
+	"log/slog"
 	"net/http"
 	"sync"
 	"time"
 
 	"github.com/gorilla/websocket"
+
+	"ollama_dev/internal/config"
+	"ollama_dev/internal/janitor"
 )
 
 // 定义 WebSocket 消息结构
@@ -20,24 +24,41 @@ type WebSocketMessage struct {
 	TotalConnections int    `json:"total_connections"` // 总连接数
 }
 
+// messagePool 复用收发的消息结构，降低高消息速率下的 GC 压力
+var messagePool = sync.Pool{New: func() any { return new(WebSocketMessage) }}
+
+// acquireMessage 从池中取出已清空的消息
+func acquireMessage() *WebSocketMessage {
+	return messagePool.Get().(*WebSocketMessage)
+}
+
+// releaseMessage 清空消息并放回池中
+func releaseMessage(m *WebSocketMessage) {
+	*m = WebSocketMessage{}
+	messagePool.Put(m)
+}
+
 // 定义 WebSocket 连接管理器
 type ConnectionManager struct {
 	connections map[*websocket.Conn]*ConnectionInfo
 	groups      map[string]map[*websocket.Conn]bool // 分组管理：组名 -> 连接集合
 	mu          sync.Mutex
+	logger      *slog.Logger
 }
 
 // 连接信息结构
 type ConnectionInfo struct {
-	Username string // 用户名
-	Group    string // 所属分组
+	Username string    // 用户名
+	Group    string    // 所属分组
+	LastSeen time.Time // 最近一次收到 Pong 或消息的时间
 }
 
 // 初始化连接管理器
-func NewConnectionManager() *ConnectionManager {
+func NewConnectionManager(logger *slog.Logger) *ConnectionManager {
 	return &ConnectionManager{
 		connections: make(map[*websocket.Conn]*ConnectionInfo),
 		groups:      make(map[string]map[*websocket.Conn]bool),
+		logger:      logger,
 	}
 }
 
@@ -49,6 +70,7 @@ func (cm *ConnectionManager) AddConnection(conn *websocket.Conn, username string
 	cm.connections[conn] = &ConnectionInfo{
 		Username: username,
 		Group:    group,
+		LastSeen: time.Now(),
 	}
 
 	// 初始化分组（如果不存在）
@@ -63,6 +85,11 @@ func (cm *ConnectionManager) RemoveConnection(conn *websocket.Conn) {
 	cm.mu.Lock()
 	defer cm.mu.Unlock()
 
+	cm.removeLocked(conn)
+}
+
+// removeLocked 移除连接，调用方需持有 cm.mu
+func (cm *ConnectionManager) removeLocked(conn *websocket.Conn) {
 	if info, exists := cm.connections[conn]; exists {
 		delete(cm.connections, conn)
 
@@ -76,6 +103,18 @@ func (cm *ConnectionManager) RemoveConnection(conn *websocket.Conn) {
 	}
 }
 
+// connectionInfo 返回连接信息的副本
+func (cm *ConnectionManager) connectionInfo(conn *websocket.Conn) (ConnectionInfo, bool) {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+
+	info, exists := cm.connections[conn]
+	if !exists {
+		return ConnectionInfo{}, false
+	}
+	return *info, true
+}
+
 // 获取当前连接数
 func (cm *ConnectionManager) GetTotalConnections() int {
 	cm.mu.Lock()
@@ -107,20 +146,76 @@ func (cm *ConnectionManager) BroadcastToGroup(group string, message *WebSocketMe
 	}
 }
 
-// 向单个连接发送消息
+// SendTo 向单个连接发送消息，与广播、心跳串行写入
+func (cm *ConnectionManager) SendTo(conn *websocket.Conn, message *WebSocketMessage) {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+
+	cm.sendMessage(conn, message)
+}
+
+// touch 记录收到连接的 Pong 或消息
+func (cm *ConnectionManager) touch(conn *websocket.Conn, now time.Time) {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+
+	if info, exists := cm.connections[conn]; exists {
+		info.LastSeen = now
+	}
+}
+
+// StartJanitor 每隔 interval 向全部连接发送心跳，并断开连续 maxMissed 个间隔没有回应的连接，ctx 结束时停止
+func (cm *ConnectionManager) StartJanitor(ctx context.Context, interval time.Duration, maxMissed int) {
+	j := janitor.New(interval)
+	j.Register("wstest_stale_connections", func(now time.Time) int {
+		return cm.reapStale(now, interval*time.Duration(maxMissed), interval)
+	})
+	go j.Run(ctx, cm.logger)
+}
+
+// reapStale 断开超过 timeout 没有回应的连接并返回数量，其余连接发送 Ping 与心跳消息
+func (cm *ConnectionManager) reapStale(now time.Time, timeout, writeWait time.Duration) int {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+
+	reaped := 0
+	for conn, info := range cm.connections {
+		if now.Sub(info.LastSeen) > timeout {
+			cm.logger.Info("断开失联连接", "username", info.Username, "group", info.Group)
+			conn.Close()
+			cm.removeLocked(conn)
+			reaped++
+			continue
+		}
+		if err := conn.WriteControl(websocket.PingMessage, nil, now.Add(writeWait)); err != nil {
+			continue
+		}
+		cm.sendMessage(conn, &WebSocketMessage{
+			Type:             "heartbeat",
+			Content:          "ping",
+			Username:         info.Username,
+			Group:            info.Group,
+			GroupSize:        len(cm.groups[info.Group]),
+			TotalConnections: len(cm.connections),
+		})
+	}
+	return reaped
+}
+
+// 向单个连接发送消息，调用方需持有 cm.mu
 func (cm *ConnectionManager) sendMessage(conn *websocket.Conn, message *WebSocketMessage) {
 	// 将消息序列化为 JSON
 	msgBytes, err := json.Marshal(message)
 	if err != nil {
-		log.Println("json marshal error:", err)
+		cm.logger.Error("消息序列化失败", "error", err)
 		return
 	}
 
 	// 发送消息
 	if err := conn.WriteMessage(websocket.TextMessage, msgBytes); err != nil {
-		log.Println("write error:", err)
+		cm.logger.Error("消息发送失败", "error", err)
 		conn.Close()
-		delete(cm.connections, conn)
+		cm.removeLocked(conn)
 	}
 }
 
@@ -131,7 +226,7 @@ func handleWebSocketConnection(w http.ResponseWriter, r *http.Request, cm *Conne
 		CheckOrigin: func(r *http.Request) bool {
 			// // 请求头校验：检查特定的请求头字段
 			// if r.Header.Get("X-Custom-Header") != "expected-value" {
-			// 	log.Println("Invalid custom header")
+			// 	cm.logger.Warn("无效的自定义请求头")
 			// 	return false
 			// }
 			return true // 允许跨域请求
@@ -139,7 +234,7 @@ func handleWebSocketConnection(w http.ResponseWriter, r *http.Request, cm *Conne
 	}
 	conn, err := upgrader.Upgrade(w, r, nil)
 	if err != nil {
-		log.Println("upgrade error:", err)
+		cm.logger.Error("WebSocket 升级失败", "error", err)
 		return
 	}
 	defer conn.Close()
@@ -148,7 +243,7 @@ func handleWebSocketConnection(w http.ResponseWriter, r *http.Request, cm *Conne
 	username := r.URL.Query().Get("username")
 	group := r.URL.Query().Get("group")
 	if username == "" || group == "" {
-		log.Println("Username or group is missing")
+		cm.logger.Warn("缺少用户名或分组参数")
 		return
 	}
 
@@ -156,92 +251,91 @@ func handleWebSocketConnection(w http.ResponseWriter, r *http.Request, cm *Conne
 	cm.AddConnection(conn, username, group)
 	defer cm.RemoveConnection(conn)
 
-	// 自动心跳维持
-	go func() {
-		ticker := time.NewTicker(30 * time.Second)
-		defer ticker.Stop()
-		for {
-			select {
-			case <-ticker.C:
-				// 发送心跳消息
-				cm.sendMessage(conn, &WebSocketMessage{
-					Type:             "heartbeat",
-					Content:          "ping",
-					Username:         cm.connections[conn].Username,
-					Group:            cm.connections[conn].Group,
-					GroupSize:        cm.GetGroupSize(cm.connections[conn].Group),
-					TotalConnections: cm.GetTotalConnections(),
-				})
-			}
-		}
-	}()
+	// 心跳由 StartJanitor 统一发送，收到 Pong 或任何消息都视为连接存活
+	conn.SetPongHandler(func(string) error {
+		cm.touch(conn, time.Now())
+		return nil
+	})
 
 	// 循环读取客户端发送的消息
 	for {
 		_, messageBytes, err := conn.ReadMessage()
 		if err != nil {
-			log.Println("read error:", err)
+			cm.logger.Info("读取消息结束", "error", err)
 			break
 		}
+		cm.touch(conn, time.Now())
 
 		// 解析消息
-		var receivedMessage WebSocketMessage
-		if err := json.Unmarshal(messageBytes, &receivedMessage); err != nil {
-			log.Println("json unmarshal error:", err)
+		receivedMessage := acquireMessage()
+		if err := json.Unmarshal(messageBytes, receivedMessage); err != nil {
+			cm.logger.Warn("消息解析失败", "error", err)
+			releaseMessage(receivedMessage)
 			continue
 		}
 
-		// 触发消息处理
+		// 触发消息处理，回复消息同样取自池中，发送后归还
+		reply := acquireMessage()
 		switch receivedMessage.Type {
 		case "join-group":
 			// 更新分组信息
 			cm.RemoveConnection(conn) // 先从旧分组移除
 			cm.AddConnection(conn, receivedMessage.Username, receivedMessage.Group)
-			cm.BroadcastToGroup(receivedMessage.Group, &WebSocketMessage{
+			*reply = WebSocketMessage{
 				Type:             "group_update",
 				Content:          receivedMessage.Username + " joined the group.",
 				Username:         receivedMessage.Username,
 				Group:            receivedMessage.Group,
 				GroupSize:        cm.GetGroupSize(receivedMessage.Group),
 				TotalConnections: cm.GetTotalConnections(),
-			})
+			}
+			cm.BroadcastToGroup(receivedMessage.Group, reply)
 		case "leave-group":
 			// 更新分组信息
-			cm.BroadcastToGroup(receivedMessage.Group, &WebSocketMessage{
+			*reply = WebSocketMessage{
 				Type:             "group_update",
 				Content:          receivedMessage.Username + " left the group.",
 				Username:         receivedMessage.Username,
 				Group:            receivedMessage.Group,
 				GroupSize:        cm.GetGroupSize(receivedMessage.Group),
 				TotalConnections: cm.GetTotalConnections(),
-			})
+			}
+			cm.BroadcastToGroup(receivedMessage.Group, reply)
 			cm.RemoveConnection(conn)
 		default:
-			// 默认回复消息，携带分组信息和连接信息
-			cm.sendMessage(conn, &WebSocketMessage{
+			// 默认回复消息，携带分组信息和连接信息；连接已被心跳检查断开时不再回复
+			info, ok := cm.connectionInfo(conn)
+			if !ok {
+				break
+			}
+			*reply = WebSocketMessage{
 				Type:             "chat",
 				Content:          receivedMessage.Content,
-				Username:         cm.connections[conn].Username,
-				Group:            cm.connections[conn].Group,
-				GroupSize:        cm.GetGroupSize(cm.connections[conn].Group),
+				Username:         info.Username,
+				Group:            info.Group,
+				GroupSize:        cm.GetGroupSize(info.Group),
 				TotalConnections: cm.GetTotalConnections(),
-			})
+			}
+			cm.SendTo(conn, reply)
 		}
+		releaseMessage(reply)
+		releaseMessage(receivedMessage)
 	}
 }
 
-func main() {
+// ListenAndServe 启动分组测试 WebSocket 服务器
+func ListenAndServe(cfg config.WSTestConfig, logger *slog.Logger) error {
 	// 初始化连接管理器
-	cm := NewConnectionManager()
+	cm := NewConnectionManager(logger)
+	cm.StartJanitor(context.Background(), cfg.HeartbeatInterval, cfg.MaxMissed)
 
 	// 注册 WebSocket 处理函数
-	http.HandleFunc("/ws", func(w http.ResponseWriter, r *http.Request) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/ws", func(w http.ResponseWriter, r *http.Request) {
 		handleWebSocketConnection(w, r, cm)
 	})
 
-	// 启动 HTTP 服务器，监听 8080 端口
-	log.Println("Server started at :8080")
-	if err := http.ListenAndServe(":8080", nil); err != nil {
-		log.Fatal("ListenAndServe error:", err)
-	}
+	// 启动 HTTP 服务器
+	logger.Info("测试服务器启动", "addr", cfg.Addr)
+	return http.ListenAndServe(cfg.Addr, mux)
 }
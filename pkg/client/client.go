@@ -0,0 +1,103 @@
+// Package client ollama_dev REST 接口的 Go 客户端，接口方法与类型由 OpenAPI 文档生成 (client_gen.go)
+package client
+
+//go:generate go run ../../cmd/ollama_dev openapi --client client_gen.go --package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+)
+
+// Client REST 接口客户端
+type Client struct {
+	BaseURL       string // 例如 http://127.0.0.1:8080
+	Token         string // 租户 Token，用于 /api 接口，未配置鉴权时可为空
+	AdminUser     string // 管理员账号，用于 /admin 接口
+	AdminPassword string
+	HTTP          *http.Client // 为 nil 时使用 http.DefaultClient
+}
+
+// New 创建客户端
+func New(baseURL, token string) *Client {
+	return &Client{BaseURL: strings.TrimSuffix(baseURL, "/"), Token: token}
+}
+
+// APIError 接口返回了非预期的状态码，响应体为 ErrorResponse 时填写 Category、Code 与 Message
+type APIError struct {
+	StatusCode int
+	Category   string
+	Code       string
+	Message    string
+}
+
+func (e *APIError) Error() string {
+	if e.Code == "" {
+		return fmt.Sprintf("HTTP %d: %s", e.StatusCode, e.Message)
+	}
+	return fmt.Sprintf("HTTP %d %s: %s", e.StatusCode, e.Code, e.Message)
+}
+
+// do 发送请求，状态码在 accepted 中时将响应体解码到 out，否则返回 *APIError
+func (c *Client) do(ctx context.Context, method, path string, query url.Values, body any, security string, accepted []int, out any) error {
+	u := strings.TrimSuffix(c.BaseURL, "/") + path
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return fmt.Errorf("编码请求体失败: %w", err)
+		}
+		reader = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, u, reader)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	switch security {
+	case "tenant":
+		if c.Token != "" {
+			req.Header.Set("Authorization", "Bearer "+c.Token)
+		}
+	case "admin":
+		req.SetBasicAuth(c.AdminUser, c.AdminPassword)
+	}
+
+	httpClient := c.HTTP
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("读取响应失败: %w", err)
+	}
+	if !slices.Contains(accepted, resp.StatusCode) {
+		apiErr := &APIError{StatusCode: resp.StatusCode, Message: strings.TrimSpace(string(data))}
+		var e ErrorResponse
+		if json.Unmarshal(data, &e) == nil && e.Code != "" {
+			apiErr.Category, apiErr.Code, apiErr.Message = e.Category, e.Code, e.Error
+		}
+		return apiErr
+	}
+	if err := json.Unmarshal(data, out); err != nil {
+		return fmt.Errorf("解析响应失败: %w", err)
+	}
+	return nil
+}
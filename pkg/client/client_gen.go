@@ -0,0 +1,410 @@
+// Code generated by ollama_dev openapi; DO NOT EDIT.
+
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// BroadcastRequest 对应 OpenAPI 文档中的 schema BroadcastRequest
+type BroadcastRequest struct {
+	Message string `json:"message"`
+	Room    string `json:"room,omitempty"`
+	Tenant  string `json:"tenant,omitempty"`
+}
+
+// BroadcastResponse 对应 OpenAPI 文档中的 schema BroadcastResponse
+type BroadcastResponse struct {
+	Delivered int64 `json:"delivered"`
+}
+
+// ChatMessage 对应 OpenAPI 文档中的 schema ChatMessage
+type ChatMessage struct {
+	Content   string     `json:"content"`
+	Images    []string   `json:"images,omitempty"`
+	Role      string     `json:"role"`
+	ToolCalls []ToolCall `json:"tool_calls,omitempty"`
+}
+
+// ChatRequest 对应 OpenAPI 文档中的 schema ChatRequest
+type ChatRequest struct {
+	Messages  []ChatMessage `json:"messages"`
+	ModelName string        `json:"model_name"`
+	Stream    bool          `json:"stream,omitempty"`
+	User      string        `json:"user,omitempty"`
+}
+
+// ChatResponse 对应 OpenAPI 文档中的 schema ChatResponse
+type ChatResponse struct {
+	Message ChatMessage `json:"message"`
+	Usage   *Tokens     `json:"usage,omitempty"`
+}
+
+// ConnectionInfo 对应 OpenAPI 文档中的 schema ConnectionInfo
+type ConnectionInfo struct {
+	ConnectedAt      time.Time `json:"connected_at"`
+	ID               string    `json:"id"`
+	MessagesReceived int64     `json:"messages_received"`
+	MessagesSent     int64     `json:"messages_sent"`
+	Name             string    `json:"name,omitempty"`
+	QueueLen         int64     `json:"queue_len"`
+	RemoteAddr       string    `json:"remote_addr"`
+	Room             string    `json:"room,omitempty"`
+	Subject          string    `json:"subject,omitempty"`
+	Tenant           string    `json:"tenant"`
+}
+
+// Connections 对应 OpenAPI 文档中的 schema Connections
+type Connections struct {
+	Disconnects   int64            `json:"disconnects"`
+	MaxQueueDepth int64            `json:"max_queue_depth"`
+	QueueCapacity int64            `json:"queue_capacity"`
+	QueueDepth    int64            `json:"queue_depth"`
+	Rooms         map[string]int64 `json:"rooms,omitempty"`
+	Tenants       map[string]int64 `json:"tenants,omitempty"`
+	Total         int64            `json:"total"`
+}
+
+// ConnectionsResponse 对应 OpenAPI 文档中的 schema ConnectionsResponse
+type ConnectionsResponse struct {
+	Connections []ConnectionInfo `json:"connections"`
+}
+
+// EmbeddingsRequest 对应 OpenAPI 文档中的 schema EmbeddingsRequest
+type EmbeddingsRequest struct {
+	Input     []string       `json:"input"`
+	ModelName string         `json:"model_name"`
+	Options   map[string]any `json:"options,omitempty"`
+	User      string         `json:"user,omitempty"`
+}
+
+// EmbeddingsResponse 对应 OpenAPI 文档中的 schema EmbeddingsResponse
+type EmbeddingsResponse struct {
+	Embeddings [][]float64 `json:"embeddings"`
+	Usage      *Tokens     `json:"usage,omitempty"`
+}
+
+// ErrorEvent 对应 OpenAPI 文档中的 schema ErrorEvent
+type ErrorEvent struct {
+	Code    string    `json:"code"`
+	Message string    `json:"message"`
+	Source  string    `json:"source"`
+	Time    time.Time `json:"time"`
+}
+
+// ErrorResponse 对应 OpenAPI 文档中的 schema ErrorResponse
+type ErrorResponse struct {
+	Category  string `json:"category"`
+	Code      string `json:"code"`
+	Error     string `json:"error"`
+	RequestID string `json:"request_id,omitempty"`
+}
+
+// FeatureUpdate 对应 OpenAPI 文档中的 schema FeatureUpdate
+type FeatureUpdate struct {
+	Enabled bool `json:"enabled"`
+}
+
+// ModelLatency 对应 OpenAPI 文档中的 schema ModelLatency
+type ModelLatency struct {
+	AvgMs  float64 `json:"avg_ms"`
+	Calls  int64   `json:"calls"`
+	Errors int64   `json:"errors"`
+	LastMs float64 `json:"last_ms"`
+	MaxMs  float64 `json:"max_ms"`
+}
+
+// ModelsInfo 对应 OpenAPI 文档中的 schema ModelsInfo
+type ModelsInfo struct {
+	Digest            string    `json:"digest"`
+	Family            string    `json:"family,omitempty"`
+	Loaded            bool      `json:"loaded"`
+	ModelName         string    `json:"model_name"`
+	ModifiedAt        time.Time `json:"modified_at"`
+	ParameterSize     string    `json:"parameter_size,omitempty"`
+	QuantizationLevel string    `json:"quantization_level,omitempty"`
+	Size              int64     `json:"size"`
+}
+
+// ModelsResponse 对应 OpenAPI 文档中的 schema ModelsResponse
+type ModelsResponse struct {
+	Models []ModelsInfo `json:"models"`
+}
+
+// Quota 对应 OpenAPI 文档中的 schema Quota
+type Quota struct {
+	DailyRequests     int64     `json:"daily_requests"`
+	DailyTokens       int64     `json:"daily_tokens"`
+	RemainingRequests int64     `json:"remaining_requests,omitempty"`
+	RemainingTokens   int64     `json:"remaining_tokens,omitempty"`
+	ResetAt           time.Time `json:"reset_at"`
+}
+
+// ReadyStatus 对应 OpenAPI 文档中的 schema ReadyStatus
+type ReadyStatus struct {
+	CheckedAt     time.Time `json:"checked_at,omitzero"`
+	Error         string    `json:"error,omitempty"`
+	MissingModels []string  `json:"missing_models,omitempty"`
+	Mode          string    `json:"mode"`
+	Status        string    `json:"status"`
+}
+
+// ReloadResponse 对应 OpenAPI 文档中的 schema ReloadResponse
+type ReloadResponse struct {
+	Status string `json:"status"`
+}
+
+// Row 对应 OpenAPI 文档中的 schema Row
+type Row struct {
+	CompletionTokens int64  `json:"completion_tokens"`
+	Date             string `json:"date"`
+	DurationMs       int64  `json:"duration_ms"`
+	Model            string `json:"model"`
+	PromptTokens     int64  `json:"prompt_tokens"`
+	Requests         int64  `json:"requests"`
+	Subject          string `json:"subject,omitempty"`
+	Tenant           string `json:"tenant"`
+	User             string `json:"user"`
+}
+
+// Snapshot 对应 OpenAPI 文档中的 schema Snapshot
+type Snapshot struct {
+	Connections *Connections            `json:"connections,omitempty"`
+	Errors      []ErrorEvent            `json:"errors,omitempty"`
+	ErrorsTotal int64                   `json:"errors_total"`
+	Goroutines  int64                   `json:"goroutines"`
+	Models      map[string]ModelLatency `json:"models,omitempty"`
+	Uptime      string                  `json:"uptime"`
+}
+
+// Status 对应 OpenAPI 文档中的 schema Status
+type Status struct {
+	Status string `json:"status"`
+	Uptime string `json:"uptime"`
+}
+
+// Summary 对应 OpenAPI 文档中的 schema Summary
+type Summary struct {
+	CompletionTokens int64  `json:"completion_tokens"`
+	Date             string `json:"date"`
+	DurationMs       int64  `json:"duration_ms"`
+	PromptTokens     int64  `json:"prompt_tokens"`
+	Requests         int64  `json:"requests"`
+	Subject          string `json:"subject,omitempty"`
+	Tenant           string `json:"tenant"`
+}
+
+// Tokens 对应 OpenAPI 文档中的 schema Tokens
+type Tokens struct {
+	CompletionTokens int64  `json:"completion_tokens"`
+	DurationMs       int64  `json:"duration_ms,omitempty"`
+	Model            string `json:"model"`
+	PromptTokens     int64  `json:"prompt_tokens"`
+	User             string `json:"user,omitempty"`
+}
+
+// ToolCall 对应 OpenAPI 文档中的 schema ToolCall
+type ToolCall struct {
+	Function ToolCallFunction `json:"function"`
+}
+
+// ToolCallFunction 对应 OpenAPI 文档中的 schema ToolCallFunction
+type ToolCallFunction struct {
+	Arguments map[string]any `json:"arguments"`
+	Index     int64          `json:"index,omitempty"`
+	Name      string         `json:"name"`
+}
+
+// UsageExport 对应 OpenAPI 文档中的 schema UsageExport
+type UsageExport struct {
+	From string `json:"from"`
+	Rows []Row  `json:"rows"`
+	To   string `json:"to"`
+}
+
+// UsageResponse 对应 OpenAPI 文档中的 schema UsageResponse
+type UsageResponse struct {
+	Quota *Quota  `json:"quota,omitempty"`
+	Usage Summary `json:"usage"`
+}
+
+// VersionInfo 对应 OpenAPI 文档中的 schema VersionInfo
+type VersionInfo struct {
+	BuildDate string `json:"build_date"`
+	Commit    string `json:"commit"`
+	GoVersion string `json:"go_version"`
+	Platform  string `json:"platform"`
+	Version   string `json:"version"`
+}
+
+// BroadcastAnnouncement 向全部连接或一个房间推送系统公告
+func (c *Client) BroadcastAnnouncement(ctx context.Context, body BroadcastRequest) (*BroadcastResponse, error) {
+	var out BroadcastResponse
+	if err := c.do(ctx, http.MethodPost, "/admin/broadcast", nil, body, "admin", []int{200}, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// ListConnections /ws 的当前连接
+func (c *Client) ListConnections(ctx context.Context) (*ConnectionsResponse, error) {
+	var out ConnectionsResponse
+	if err := c.do(ctx, http.MethodGet, "/admin/connections", nil, nil, "admin", []int{200}, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// ListFeatures 功能开关状态
+func (c *Client) ListFeatures(ctx context.Context) (map[string]bool, error) {
+	var out map[string]bool
+	if err := c.do(ctx, http.MethodGet, "/admin/features", nil, nil, "admin", []int{200}, &out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// SetFeature 运行时开启或关闭功能开关，重新加载配置后恢复为配置值
+func (c *Client) SetFeature(ctx context.Context, name string, body FeatureUpdate) (map[string]bool, error) {
+	var out map[string]bool
+	if err := c.do(ctx, http.MethodPut, "/admin/features/"+url.PathEscape(name), nil, body, "admin", []int{200}, &out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// ReloadConfig 重新加载配置文件
+func (c *Client) ReloadConfig(ctx context.Context) (*ReloadResponse, error) {
+	var out ReloadResponse
+	if err := c.do(ctx, http.MethodPost, "/admin/reload", nil, nil, "admin", []int{200}, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// GetStats 运行统计快照
+func (c *Client) GetStats(ctx context.Context) (*Snapshot, error) {
+	var out Snapshot
+	if err := c.do(ctx, http.MethodGet, "/admin/stats", nil, nil, "admin", []int{200}, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// ExportAllUsageParams ExportAllUsage 的查询参数，空字符串表示不传
+type ExportAllUsageParams struct {
+	Tenant string // 只导出该租户
+	From   string // 起始日期 YYYY-MM-DD，默认 30 天前
+	To     string // 结束日期 YYYY-MM-DD，默认今天
+}
+
+// ExportAllUsage 导出全部租户的 token 用量
+func (c *Client) ExportAllUsage(ctx context.Context, params *ExportAllUsageParams) (*UsageExport, error) {
+	q := url.Values{}
+	if params != nil {
+		if params.Tenant != "" {
+			q.Set("tenant", params.Tenant)
+		}
+		if params.From != "" {
+			q.Set("from", params.From)
+		}
+		if params.To != "" {
+			q.Set("to", params.To)
+		}
+	}
+	var out UsageExport
+	if err := c.do(ctx, http.MethodGet, "/admin/usage/export", q, nil, "admin", []int{200}, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// Chat 对话，与 WebSocket 的 chat 动作相同
+func (c *Client) Chat(ctx context.Context, body ChatRequest) (*ChatResponse, error) {
+	var out ChatResponse
+	if err := c.do(ctx, http.MethodPost, "/api/chat", nil, body, "tenant", []int{200}, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// Embeddings 计算文本向量，与 WebSocket 的 embed 动作相同
+func (c *Client) Embeddings(ctx context.Context, body EmbeddingsRequest) (*EmbeddingsResponse, error) {
+	var out EmbeddingsResponse
+	if err := c.do(ctx, http.MethodPost, "/api/embeddings", nil, body, "tenant", []int{200}, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// ListModels 列出 Ollama 上的模型
+func (c *Client) ListModels(ctx context.Context) (*ModelsResponse, error) {
+	var out ModelsResponse
+	if err := c.do(ctx, http.MethodGet, "/api/models", nil, nil, "tenant", []int{200}, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// GetUsage 调用方当天 (UTC) 的用量与配额
+func (c *Client) GetUsage(ctx context.Context) (*UsageResponse, error) {
+	var out UsageResponse
+	if err := c.do(ctx, http.MethodGet, "/api/usage", nil, nil, "tenant", []int{200}, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// ExportUsageParams ExportUsage 的查询参数，空字符串表示不传
+type ExportUsageParams struct {
+	From string // 起始日期 YYYY-MM-DD，默认 30 天前
+	To   string // 结束日期 YYYY-MM-DD，默认今天
+}
+
+// ExportUsage 导出调用方租户的 token 用量
+func (c *Client) ExportUsage(ctx context.Context, params *ExportUsageParams) (*UsageExport, error) {
+	q := url.Values{}
+	if params != nil {
+		if params.From != "" {
+			q.Set("from", params.From)
+		}
+		if params.To != "" {
+			q.Set("to", params.To)
+		}
+	}
+	var out UsageExport
+	if err := c.do(ctx, http.MethodGet, "/api/usage/export", q, nil, "tenant", []int{200}, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// GetVersion 版本与构建信息
+func (c *Client) GetVersion(ctx context.Context) (*VersionInfo, error) {
+	var out VersionInfo
+	if err := c.do(ctx, http.MethodGet, "/api/version", nil, nil, "tenant", []int{200}, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// GetHealth 存活检查
+func (c *Client) GetHealth(ctx context.Context) (*Status, error) {
+	var out Status
+	if err := c.do(ctx, http.MethodGet, "/healthz", nil, nil, "", []int{200, 503}, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// GetReadiness 就绪检查
+func (c *Client) GetReadiness(ctx context.Context) (*ReadyStatus, error) {
+	var out ReadyStatus
+	if err := c.do(ctx, http.MethodGet, "/readyz", nil, nil, "", []int{200, 503}, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
@@ -0,0 +1,87 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"ollama_dev/internal/config"
+	"ollama_dev/internal/feature"
+	"ollama_dev/internal/health"
+	"ollama_dev/internal/models"
+	"ollama_dev/internal/openapi"
+	"ollama_dev/internal/router"
+)
+
+// 生成的代码需与当前路由的文档一致，修改接口后运行 go generate ./pkg/client
+func TestGeneratedClientUpToDate(t *testing.T) {
+	want, err := openapi.Generate(router.Spec(), "client")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := os.ReadFile("client_gen.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Fatal("client_gen.go 已过期，请运行 go generate ./pkg/client")
+	}
+}
+
+func TestClientAgainstServer(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	cfg := config.Default()
+	cfg.Admin.Username, cfg.Admin.Password = "admin", "secret"
+	r := gin.New()
+	router.SetupRoutes(slog.New(slog.NewTextHandler(io.Discard, nil)), r, config.NewStore("", cfg), router.Deps{
+		Lifecycle: health.NewLifecycle(),
+		Flags:     feature.New(cfg.Features),
+		Models: func(context.Context) ([]models.Info, error) {
+			return []models.Info{{Name: "llama3:latest", Loaded: true}}, nil
+		},
+	})
+	srv := httptest.NewServer(r)
+	defer srv.Close()
+
+	ctx := context.Background()
+	c := New(srv.URL, cfg.Auth.Token)
+	c.AdminUser, c.AdminPassword = "admin", "secret"
+
+	if _, err := c.GetVersion(ctx); err != nil {
+		t.Fatal(err)
+	}
+	health, err := c.GetHealth(ctx)
+	if err != nil || health.Status != "ok" {
+		t.Fatalf("health = %+v, %v", health, err)
+	}
+	list, err := c.ListModels(ctx)
+	if err != nil || len(list.Models) != 1 || list.Models[0].ModelName != "llama3:latest" || !list.Models[0].Loaded {
+		t.Fatalf("models = %+v, %v", list, err)
+	}
+
+	name := feature.Names()[0]
+	flags, err := c.SetFeature(ctx, name, FeatureUpdate{Enabled: false})
+	if err != nil || flags[name] {
+		t.Fatalf("features = %v, %v", flags, err)
+	}
+
+	// 错误响应解码为 APIError
+	_, err = c.SetFeature(ctx, "no_such_feature", FeatureUpdate{Enabled: true})
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != 400 || apiErr.Code != "unknown_feature" {
+		t.Fatalf("err = %v", err)
+	}
+
+	// 管理员账号错误时没有响应体
+	c.AdminPassword = "wrong"
+	if _, err := c.ListFeatures(ctx); !errors.As(err, &apiErr) || apiErr.StatusCode != 401 {
+		t.Fatalf("err = %v", err)
+	}
+}